@@ -0,0 +1,525 @@
+package cache
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Compression is the compression applied to a cache archive.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd shells out to the zstd binary, which must be on PATH.
+	CompressionZstd Compression = "zstd"
+)
+
+// CompressionFromPath picks the compression matching an archive's extension.
+func CompressionFromPath(p string) (Compression, error) {
+	switch {
+	case strings.HasSuffix(p, ".tar.gz"), strings.HasSuffix(p, ".tgz"):
+		return CompressionGzip, nil
+	case strings.HasSuffix(p, ".tar.zst"), strings.HasSuffix(p, ".tzst"):
+		return CompressionZstd, nil
+	case strings.HasSuffix(p, ".tar"):
+		return CompressionNone, nil
+	default:
+		return "", fmt.Errorf("unsupported archive %q: expected .tar, .tar.gz, .tgz, .tar.zst or .tzst", p)
+	}
+}
+
+// ArchiveRequest selects the cache entries to export or import. Without modes
+// or paths, all entries are used.
+type ArchiveRequest struct {
+	Modes []string
+	Paths []string // mount paths
+	// Overwrite replaces entries that already exist in the cache root on
+	// import. Otherwise they are skipped.
+	Overwrite bool
+}
+
+type ArchiveResponse struct {
+	CacheRoot string         `json:"cache_root"`
+	Archive   string         `json:"archive"`
+	DryRun    bool           `json:"dry_run,omitzero"`
+	Entries   []ArchiveEntry `json:"entries,omitzero"`
+}
+
+type ArchiveEntry struct {
+	Mode      string  `json:"mode,omitzero"`
+	CachePath string  `json:"cache_path"` // relative to the cache root
+	MountPath string  `json:"mount_path"`
+	Size      DirSize `json:"size"`
+	Skipped   bool    `json:"skipped,omitzero"` // already present on import
+}
+
+// Export writes the selected cache entries, along with their metadata, to an
+// archive at archivePath.
+func (m Mounter) Export(ctx context.Context, archivePath string, compression Compression, req ArchiveRequest) (ArchiveResponse, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return ArchiveResponse{}, err
+	}
+
+	exported := CacheMetadata{Version: metadataVersion, UpdatedAt: metadata.UpdatedAt, UserRequest: map[string]CacheMetadataEntry{}}
+	result := ArchiveResponse{CacheRoot: m.CacheRoot, Archive: archivePath}
+	for mountPath, entry := range metadata.UserRequest {
+		if !req.selects(mountPath, entry) {
+			continue
+		}
+		if _, err := m.Exec.Stat(entry.Source); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return ArchiveResponse{}, fmt.Errorf("stat cache path %q: %w", entry.Source, err)
+		}
+
+		rel, err := filepath.Rel(m.CacheRoot, entry.Source)
+		if err != nil || !isWithin(m.CacheRoot, entry.Source) {
+			return ArchiveResponse{}, fmt.Errorf("cache path %q is not within cache root %q", entry.Source, m.CacheRoot)
+		}
+
+		// Sources are stored relative to the cache root, so that the archive
+		// can be imported into a volume mounted elsewhere.
+		entry.Source = filepath.ToSlash(rel)
+		exported.UserRequest[mountPath] = entry
+
+		e := ArchiveEntry{CachePath: entry.Source, MountPath: mountPath}
+		if entry.CacheFramework != nil {
+			e.Mode = *entry.CacheFramework
+		}
+		result.Entries = append(result.Entries, e)
+	}
+	slices.SortFunc(result.Entries, func(a, b ArchiveEntry) int {
+		return cmp.Compare(a.CachePath, b.CachePath)
+	})
+
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return ArchiveResponse{}, fmt.Errorf("creating archive: %w", err)
+	}
+	defer f.Close()
+
+	cw, err := compressWriter(ctx, f, compression)
+	if err != nil {
+		return ArchiveResponse{}, err
+	}
+
+	tw := tar.NewWriter(cw)
+	if err := writeArchive(tw, m.CacheRoot, exported, result.Entries); err != nil {
+		return ArchiveResponse{}, fmt.Errorf("writing archive: %w", err)
+	}
+	if err := errors.Join(tw.Close(), cw.Close(), f.Close()); err != nil {
+		return ArchiveResponse{}, fmt.Errorf("writing archive: %w", err)
+	}
+
+	return result, nil
+}
+
+func writeArchive(tw *tar.Writer, cacheRoot string, metadata CacheMetadata, entries []ArchiveEntry) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache metadata: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     filepath.ToSlash(MetadataPath),
+		Mode:     0o644,
+		Size:     int64(len(data)),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		root := filepath.Join(cacheRoot, filepath.FromSlash(e.CachePath))
+		size, err := writeTree(tw, cacheRoot, root)
+		if err != nil {
+			return err
+		}
+		entries[i].Size = size
+	}
+
+	return nil
+}
+
+// writeTree adds dir and everything beneath it to tw, named relative to
+// base. base itself is left out.
+func writeTree(tw *tar.Writer, base, dir string) (DirSize, error) {
+	var size DirSize
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !d.IsDir() && !d.Type().IsRegular() {
+			// Sockets, devices and the like have no place in a cache.
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		// Ownership is not carried over, files belong to whoever imports them.
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		n, err := io.Copy(tw, f)
+		if err != nil {
+			return err
+		}
+		size.Bytes += n
+		size.Files++
+		return nil
+	})
+	if err != nil {
+		return DirSize{}, fmt.Errorf("adding %q: %w", dir, err)
+	}
+	return size, nil
+}
+
+// Import extracts the selected entries of an archive created by Export into
+// the cache root and records them in the metadata file. Without
+// DestructiveMode it only reports what would be imported.
+func (m Mounter) Import(ctx context.Context, archivePath string, req ArchiveRequest) (ArchiveResponse, error) {
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return ArchiveResponse{}, err
+	}
+	defer unlock()
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return ArchiveResponse{}, fmt.Errorf("opening archive: %w", err)
+	}
+	defer f.Close()
+
+	r, err := decompressReader(ctx, f)
+	if err != nil {
+		return ArchiveResponse{}, err
+	}
+	defer r.Close()
+
+	root, err := os.OpenRoot(m.CacheRoot)
+	if err != nil {
+		return ArchiveResponse{}, fmt.Errorf("opening cache root: %w", err)
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(r)
+
+	// The metadata is always written first, so that entries can be selected
+	// before their contents are read.
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != filepath.ToSlash(MetadataPath) {
+		return ArchiveResponse{}, fmt.Errorf("%s is not a cache archive: missing metadata", archivePath)
+	}
+	var metadata CacheMetadata
+	if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+		return ArchiveResponse{}, fmt.Errorf("parsing archive metadata: %w", err)
+	}
+
+	result := ArchiveResponse{CacheRoot: m.CacheRoot, Archive: archivePath, DryRun: !m.DestructiveMode}
+	for mountPath, entry := range metadata.UserRequest {
+		if !req.selects(mountPath, entry) {
+			continue
+		}
+		metadataDir := path.Dir(filepath.ToSlash(MetadataPath))
+		if !fs.ValidPath(entry.Source) || entry.Source == "." || entry.Source == metadataDir || strings.HasPrefix(entry.Source, metadataDir+"/") {
+			return ArchiveResponse{}, fmt.Errorf("archive entry %q has an invalid cache path %q", mountPath, entry.Source)
+		}
+
+		e := ArchiveEntry{CachePath: entry.Source, MountPath: mountPath}
+		if entry.CacheFramework != nil {
+			e.Mode = *entry.CacheFramework
+		}
+
+		dest := filepath.Join(m.CacheRoot, filepath.FromSlash(entry.Source))
+		if _, err := m.Exec.Stat(dest); err == nil {
+			e.Skipped = !req.Overwrite
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return ArchiveResponse{}, fmt.Errorf("stat cache path %q: %w", dest, err)
+		}
+		result.Entries = append(result.Entries, e)
+	}
+	slices.SortFunc(result.Entries, func(a, b ArchiveEntry) int {
+		return cmp.Compare(a.CachePath, b.CachePath)
+	})
+
+	// entryFor returns the imported entry that an archive path belongs to.
+	entryFor := func(name string) *ArchiveEntry {
+		for i := range result.Entries {
+			e := &result.Entries[i]
+			if !e.Skipped && (name == e.CachePath || strings.HasPrefix(name, e.CachePath+"/")) {
+				return e
+			}
+		}
+		return nil
+	}
+
+	if m.DestructiveMode {
+		for _, e := range result.Entries {
+			if e.Skipped {
+				continue
+			}
+			dest := filepath.Join(m.CacheRoot, filepath.FromSlash(e.CachePath))
+			slog.Debug("importing cache entry", slog.String("path", dest))
+			if err := m.Exec.RemoveAll(dest); err != nil {
+				return ArchiveResponse{}, fmt.Errorf("removing %q: %w", dest, err)
+			}
+		}
+	}
+
+	var dirs []*tar.Header
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ArchiveResponse{}, fmt.Errorf("reading archive: %w", err)
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		e := entryFor(name)
+		if e == nil {
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			e.Size.Bytes += hdr.Size
+			e.Size.Files++
+		}
+		if !m.DestructiveMode {
+			continue
+		}
+
+		if err := extractEntry(root, name, hdr, tr); err != nil {
+			return ArchiveResponse{}, fmt.Errorf("extracting %q: %w", name, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+	}
+
+	if err := applyDirModes(root, dirs); err != nil {
+		return ArchiveResponse{}, err
+	}
+
+	if !m.DestructiveMode {
+		return result, nil
+	}
+
+	var mounts []MountResult
+	for _, e := range result.Entries {
+		if e.Skipped {
+			continue
+		}
+		mounts = append(mounts, MountResult{
+			Mode:      e.Mode,
+			CachePath: filepath.Join(m.CacheRoot, filepath.FromSlash(e.CachePath)),
+			MountPath: e.MountPath,
+		})
+	}
+	if err := m.updateMetadata(ctx, mounts); err != nil {
+		return ArchiveResponse{}, err
+	}
+
+	return result, nil
+}
+
+// extractEntry writes a single archive entry beneath root. Going through
+// os.Root keeps links in the archive from redirecting writes outside of it.
+func extractEntry(root *os.Root, name string, hdr *tar.Header, r io.Reader) error {
+	name = filepath.FromSlash(name)
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return root.MkdirAll(name, 0o755)
+	case tar.TypeReg:
+		f, err := root.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fs.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		return root.Symlink(hdr.Linkname, name)
+	default:
+		return nil
+	}
+
+	return root.Chtimes(name, hdr.ModTime, hdr.ModTime)
+}
+
+// applyDirModes sets the permissions and times of extracted directories.
+// Directories may be read-only, like Go's module cache, so this is done once
+// everything beneath them is in place.
+func applyDirModes(root *os.Root, dirs []*tar.Header) error {
+	for _, hdr := range slices.Backward(dirs) {
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if err := errors.Join(
+			root.Chmod(name, fs.FileMode(hdr.Mode).Perm()),
+			root.Chtimes(name, hdr.ModTime, hdr.ModTime),
+		); err != nil {
+			return fmt.Errorf("extracting %q: %w", hdr.Name, err)
+		}
+	}
+	return nil
+}
+
+func (req ArchiveRequest) selects(mountPath string, entry CacheMetadataEntry) bool {
+	if len(req.Modes) == 0 && len(req.Paths) == 0 {
+		return true
+	}
+	if entry.CacheFramework != nil && slices.Contains(req.Modes, *entry.CacheFramework) {
+		return true
+	}
+	return slices.ContainsFunc(req.Paths, func(p string) bool {
+		resolved, err := resolveHome(p)
+		return err == nil && filepath.Clean(resolved) == filepath.Clean(mountPath)
+	})
+}
+
+type cmdWriteCloser struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (c cmdWriteCloser) Close() error {
+	if err := c.WriteCloser.Close(); err != nil {
+		return err
+	}
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %w: %s", c.cmd.Path, err, strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
+
+func compressWriter(ctx context.Context, w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "zstd", "-q", "-c", "-T0")
+		cmd.Stdout = w
+		cmd.Stderr = &stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("starting zstd: %w", err)
+		}
+		return cmdWriteCloser{WriteCloser: stdin, cmd: cmd, stderr: &stderr}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type cmdReadCloser struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c cmdReadCloser) Close() error {
+	c.ReadCloser.Close()
+	return c.cmd.Wait()
+}
+
+// decompressReader detects the compression of an archive from its magic
+// bytes.
+func decompressReader(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip archive: %w", err)
+		}
+		return gr, nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		cmd := exec.CommandContext(ctx, "zstd", "-q", "-d", "-c")
+		cmd.Stdin = br
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("starting zstd: %w", err)
+		}
+		return cmdReadCloser{ReadCloser: stdout, cmd: cmd}, nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
@@ -0,0 +1,200 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestCompressionFromPath(t *testing.T) {
+	for path, want := range map[string]cache.Compression{
+		"cache.tar":     cache.CompressionNone,
+		"cache.tar.gz":  cache.CompressionGzip,
+		"cache.tgz":     cache.CompressionGzip,
+		"cache.tar.zst": cache.CompressionZstd,
+		"cache.tzst":    cache.CompressionZstd,
+	} {
+		got, err := cache.CompressionFromPath(path)
+		require.NoError(t, err, path)
+		require.Equal(t, want, got, path)
+	}
+
+	_, err := cache.CompressionFromPath("cache.zip")
+	require.ErrorContains(t, err, "unsupported archive")
+}
+
+func TestMounter_ExportImport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+
+	newMounter := func(t *testing.T, cacheRoot string) cache.Mounter {
+		return cache.Mounter{
+			CacheRoot: cacheRoot,
+			Exec: &cache.ExecutorMock{
+				MkdirAllFunc:  os.MkdirAll,
+				ReadFileFunc:  os.ReadFile,
+				RemoveAllFunc: os.RemoveAll,
+				StatFunc:      os.Stat,
+				LockFunc:      noLock,
+				WriteFileFunc: os.WriteFile,
+			},
+		}
+	}
+
+	// source creates a cache root with a go and an apt entry. The go entry
+	// has a read-only directory, like Go's module cache, and a symlink.
+	source := func(t *testing.T) cache.Mounter {
+		cacheRoot := t.TempDir()
+
+		goDir := filepath.Join(cacheRoot, "root/go/pkg/mod")
+		require.NoError(t, os.MkdirAll(filepath.Join(goDir, "example.com@v1"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(goDir, "example.com@v1", "go.mod"), []byte("module example.com"), 0o444))
+		require.NoError(t, os.Chmod(filepath.Join(goDir, "example.com@v1"), 0o555))
+		t.Cleanup(func() { os.Chmod(filepath.Join(goDir, "example.com@v1"), 0o755) })
+		require.NoError(t, os.Symlink("example.com@v1/go.mod", filepath.Join(goDir, "latest")))
+
+		aptDir := filepath.Join(cacheRoot, "var/cache/apt")
+		require.NoError(t, os.MkdirAll(aptDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(aptDir, "pkgcache.bin"), []byte("apt"), 0o644))
+
+		goMode, aptMode := "go", "apt"
+		metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{
+			"/root/go/pkg/mod": {CacheFramework: &goMode, MountTarget: []string{"/root/go/pkg/mod"}, Source: goDir},
+			"/var/cache/apt":   {CacheFramework: &aptMode, MountTarget: []string{"/var/cache/apt"}, Source: aptDir},
+		}}
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		return newMounter(t, cacheRoot)
+	}
+
+	roundTrip := func(t *testing.T, archive string, compression cache.Compression) {
+		src := source(t)
+
+		exported, err := src.Export(t.Context(), archive, compression, cache.ArchiveRequest{})
+		require.NoError(t, err)
+		require.Equal(t, []cache.ArchiveEntry{
+			{Mode: "go", CachePath: "root/go/pkg/mod", MountPath: "/root/go/pkg/mod", Size: cache.DirSize{Bytes: 18, Files: 1}},
+			{Mode: "apt", CachePath: "var/cache/apt", MountPath: "/var/cache/apt", Size: cache.DirSize{Bytes: 3, Files: 1}},
+		}, exported.Entries)
+
+		dst := newMounter(t, t.TempDir())
+		dst.DestructiveMode = true
+
+		imported, err := dst.Import(t.Context(), archive, cache.ArchiveRequest{})
+		require.NoError(t, err)
+		require.Equal(t, exported.Entries, imported.Entries)
+
+		goDir := filepath.Join(dst.CacheRoot, "root/go/pkg/mod")
+		data, err := os.ReadFile(filepath.Join(goDir, "latest"))
+		require.NoError(t, err)
+		require.Equal(t, "module example.com", string(data))
+
+		info, err := os.Stat(filepath.Join(goDir, "example.com@v1"))
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o555), info.Mode().Perm())
+		t.Cleanup(func() { os.Chmod(filepath.Join(goDir, "example.com@v1"), 0o755) })
+
+		link, err := os.Readlink(filepath.Join(goDir, "latest"))
+		require.NoError(t, err)
+		require.Equal(t, "example.com@v1/go.mod", link)
+
+		metadata, err := cache.ReadMetadata(dst.Exec, dst.CacheRoot)
+		require.NoError(t, err)
+		require.Len(t, metadata.UserRequest, 2)
+		require.Equal(t, goDir, metadata.UserRequest["/root/go/pkg/mod"].Source)
+		require.Equal(t, "go", *metadata.UserRequest["/root/go/pkg/mod"].CacheFramework)
+	}
+
+	t.Run("gzip round trip", func(t *testing.T) {
+		roundTrip(t, filepath.Join(t.TempDir(), "cache.tar.gz"), cache.CompressionGzip)
+	})
+
+	t.Run("uncompressed round trip", func(t *testing.T) {
+		roundTrip(t, filepath.Join(t.TempDir(), "cache.tar"), cache.CompressionNone)
+	})
+
+	t.Run("zstd round trip", func(t *testing.T) {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			t.Skip("zstd not installed")
+		}
+		roundTrip(t, filepath.Join(t.TempDir(), "cache.tar.zst"), cache.CompressionZstd)
+	})
+
+	t.Run("selects entries by mode", func(t *testing.T) {
+		src := source(t)
+		archive := filepath.Join(t.TempDir(), "cache.tgz")
+
+		exported, err := src.Export(t.Context(), archive, cache.CompressionGzip, cache.ArchiveRequest{Modes: []string{"apt"}})
+		require.NoError(t, err)
+		require.Len(t, exported.Entries, 1)
+
+		dst := newMounter(t, t.TempDir())
+		dst.DestructiveMode = true
+		_, err = dst.Import(t.Context(), archive, cache.ArchiveRequest{})
+		require.NoError(t, err)
+
+		require.FileExists(t, filepath.Join(dst.CacheRoot, "var/cache/apt/pkgcache.bin"))
+		require.NoDirExists(t, filepath.Join(dst.CacheRoot, "root"))
+	})
+
+	t.Run("existing entries are skipped unless overwritten", func(t *testing.T) {
+		src := source(t)
+		archive := filepath.Join(t.TempDir(), "cache.tgz")
+		_, err := src.Export(t.Context(), archive, cache.CompressionGzip, cache.ArchiveRequest{Paths: []string{"/var/cache/apt"}})
+		require.NoError(t, err)
+
+		dst := newMounter(t, t.TempDir())
+		dst.DestructiveMode = true
+		existing := filepath.Join(dst.CacheRoot, "var/cache/apt")
+		require.NoError(t, os.MkdirAll(existing, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(existing, "local"), []byte("local"), 0o644))
+
+		result, err := dst.Import(t.Context(), archive, cache.ArchiveRequest{})
+		require.NoError(t, err)
+		require.True(t, result.Entries[0].Skipped)
+		require.FileExists(t, filepath.Join(existing, "local"))
+		require.NoFileExists(t, filepath.Join(existing, "pkgcache.bin"))
+
+		result, err = dst.Import(t.Context(), archive, cache.ArchiveRequest{Overwrite: true})
+		require.NoError(t, err)
+		require.False(t, result.Entries[0].Skipped)
+		require.NoFileExists(t, filepath.Join(existing, "local"))
+		require.FileExists(t, filepath.Join(existing, "pkgcache.bin"))
+	})
+
+	t.Run("dry run import", func(t *testing.T) {
+		src := source(t)
+		archive := filepath.Join(t.TempDir(), "cache.tgz")
+		_, err := src.Export(t.Context(), archive, cache.CompressionGzip, cache.ArchiveRequest{})
+		require.NoError(t, err)
+
+		dst := newMounter(t, t.TempDir())
+		result, err := dst.Import(t.Context(), archive, cache.ArchiveRequest{})
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Len(t, result.Entries, 2)
+
+		entries, err := os.ReadDir(dst.CacheRoot)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("rejects archives without metadata", func(t *testing.T) {
+		archive := filepath.Join(t.TempDir(), "other.tar")
+		require.NoError(t, os.WriteFile(archive, nil, 0o644))
+
+		_, err := newMounter(t, t.TempDir()).Import(t.Context(), archive, cache.ArchiveRequest{})
+		require.ErrorContains(t, err, "not a cache archive")
+	})
+}
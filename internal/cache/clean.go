@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// CleanRequest selects the cache entries to delete, by mode, by the path
+// they are mounted at, or by scope.
+type CleanRequest struct {
+	Modes  []string
+	Paths  []string
+	Scopes []string
+}
+
+type CleanResponse struct {
+	CacheRoot  string       `json:"cache_root"`
+	DryRun     bool         `json:"dry_run"`
+	FreedBytes int64        `json:"freed_bytes"`
+	Deleted    []CleanEntry `json:"deleted,omitzero"`
+}
+
+type CleanEntry struct {
+	Mode      string  `json:"mode,omitzero"`
+	CachePath string  `json:"cache_path"`
+	Size      DirSize `json:"size"`
+}
+
+// Clean deletes the cache entries of the requested modes and paths, leaving
+// the rest of the cache root untouched. A mode's entries are the paths its
+// plan mounts now, its keyed caches, plus any recorded for it in the
+// metadata file. Without DestructiveMode it only reports what would be
+// deleted.
+func (m Mounter) Clean(ctx context.Context, req CleanRequest) (CleanResponse, error) {
+	if len(req.Modes) == 0 && len(req.Paths) == 0 && len(req.Scopes) == 0 {
+		return CleanResponse{}, errors.New("at least one cache mode, path or scope must be specified")
+	}
+
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return CleanResponse{}, err
+	}
+	defer unlock()
+
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return CleanResponse{}, err
+	}
+
+	var candidates []CleanEntry
+	add := func(modeName, cachePath string) {
+		if !slices.ContainsFunc(candidates, func(e CleanEntry) bool { return e.CachePath == cachePath }) {
+			candidates = append(candidates, CleanEntry{Mode: modeName, CachePath: cachePath})
+		}
+	}
+
+	if len(req.Modes) > 0 {
+		modes, err := m.Modes.Filter(req.Modes)
+		if err != nil {
+			return CleanResponse{}, err
+		}
+
+		plan, err := modes.Plan(ctx, mode.PlanRequest{CacheRoot: m.CacheRoot, Exec: m.modeExec()})
+		if err != nil {
+			return CleanResponse{}, err
+		}
+
+		for _, modeName := range plan.Order {
+			for _, subdir := range plan.Results[modeName].CacheDirs {
+				add(modeName, filepath.Join(m.CacheRoot, subdir))
+			}
+			for _, path := range plan.Results[modeName].MountPaths {
+				cachePath, err := m.cachePathFor(path)
+				if err != nil {
+					return CleanResponse{}, err
+				}
+				add(modeName, cachePath)
+			}
+
+			keys, err := keyDirs(filepath.Join(m.CacheRoot, modeName))
+			if err != nil {
+				return CleanResponse{}, fmt.Errorf("listing keys of mode %q: %w", modeName, err)
+			}
+			for _, key := range keys {
+				add(modeName, filepath.Join(m.CacheRoot, modeName, key))
+			}
+		}
+
+		for _, entry := range metadata.UserRequest {
+			if entry.CacheFramework != nil && slices.Contains(req.Modes, *entry.CacheFramework) {
+				add(*entry.CacheFramework, entry.Source)
+			}
+		}
+	}
+
+	for _, path := range req.Paths {
+		cachePath, err := m.cachePathFor(path)
+		if err != nil {
+			return CleanResponse{}, err
+		}
+		add("", cachePath)
+	}
+
+	for _, scope := range req.Scopes {
+		if scope == m.DefaultScope {
+			return CleanResponse{}, fmt.Errorf("refusing to delete default scope %q: select its entries by mode or path", scope)
+		}
+		add("", m.scopeRoot(scope))
+	}
+
+	result := CleanResponse{
+		CacheRoot: m.CacheRoot,
+		DryRun:    !m.DestructiveMode,
+	}
+
+	for _, e := range candidates {
+		if err := m.checkCleanable(e.CachePath); err != nil {
+			return CleanResponse{}, err
+		}
+		// Deleting a directory also deletes the entries nested in it.
+		if slices.ContainsFunc(candidates, func(o CleanEntry) bool { return isWithin(o.CachePath, e.CachePath) }) {
+			continue
+		}
+		if _, err := m.Exec.Stat(e.CachePath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return CleanResponse{}, fmt.Errorf("stat cache path %q: %w", e.CachePath, err)
+		}
+		result.Deleted = append(result.Deleted, e)
+	}
+
+	paths := make([]string, len(result.Deleted))
+	for i, e := range result.Deleted {
+		paths[i] = e.CachePath
+	}
+	sizes, err := dirSizes(ctx, paths, runtime.GOMAXPROCS(0))
+	if err != nil {
+		return CleanResponse{}, err
+	}
+	for i := range result.Deleted {
+		result.Deleted[i].Size = sizes[i]
+		result.FreedBytes += sizes[i].Bytes
+	}
+
+	if !m.DestructiveMode {
+		for _, e := range result.Deleted {
+			slog.Debug("dry-run: would delete cache entry", slog.String("path", e.CachePath))
+		}
+		return result, nil
+	}
+
+	var forget []string
+	var removeErr error
+	for _, e := range result.Deleted {
+		slog.Debug("deleting cache entry", slog.String("path", e.CachePath))
+
+		if err := m.Exec.RemoveAll(e.CachePath); err != nil {
+			removeErr = fmt.Errorf("removing %q: %w", e.CachePath, err)
+			break
+		}
+		for _, entry := range metadata.UserRequest {
+			if entry.Source == e.CachePath || isWithin(e.CachePath, entry.Source) {
+				forget = append(forget, entry.Source)
+			}
+		}
+	}
+
+	// Entries deleted before a failure are gone either way, so keep the
+	// metadata in sync with them.
+	if err := errors.Join(removeErr, m.forgetMetadata(ctx, forget)); err != nil {
+		return CleanResponse{}, err
+	}
+
+	return result, nil
+}
+
+// cachePathFor returns where the cache for a mount path lives, matching
+// mountPath.
+func (m Mounter) cachePathFor(path string) (string, error) {
+	path, err := resolveHome(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving path: %w", err)
+	}
+	return filepath.Join(m.CacheRoot, m.cacheSubpath(path)), nil
+}
+
+// checkCleanable guards against deleting the cache root itself, anything
+// outside of it, or the metadata that describes it.
+func (m Mounter) checkCleanable(cachePath string) error {
+	if !isWithin(m.CacheRoot, cachePath) {
+		return fmt.Errorf("refusing to delete %q: not within cache root %q", cachePath, m.CacheRoot)
+	}
+
+	metadataDir := filepath.Join(m.CacheRoot, filepath.Dir(MetadataPath))
+	if cachePath == metadataDir || isWithin(metadataDir, cachePath) {
+		return fmt.Errorf("refusing to delete %q: holds cache metadata", cachePath)
+	}
+
+	return nil
+}
+
+// isWithin reports whether path is strictly beneath dir.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." {
+		return false
+	}
+	return !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
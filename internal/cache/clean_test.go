@@ -0,0 +1,160 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestMounter_Clean(t *testing.T) {
+	newMounter := func(t *testing.T) (cache.Mounter, *cache.ExecutorMock) {
+		cacheRoot := t.TempDir()
+		for _, dir := range []string{"work/go", "go-dirs/build", "work/pnpm", "opt/tools", "work/old-go"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, dir, "data"), []byte("cached"), 0o644))
+		}
+
+		goMode := "go"
+		metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{
+			"/work/go":     {CacheFramework: &goMode, Source: filepath.Join(cacheRoot, "work/go")},
+			"/work/old-go": {CacheFramework: &goMode, Source: filepath.Join(cacheRoot, "work/old-go")},
+			"/opt/tools":   {Source: filepath.Join(cacheRoot, "opt/tools")},
+		}}
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		exec := &cache.ExecutorMock{
+			MkdirAllFunc:  os.MkdirAll,
+			ReadFileFunc:  os.ReadFile,
+			RemoveAllFunc: os.RemoveAll,
+			StatFunc:      os.Stat,
+			LockFunc:      noLock,
+			WriteFileFunc: os.WriteFile,
+		}
+
+		return cache.Mounter{
+			CacheRoot: cacheRoot,
+			Exec:      exec,
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "go" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{
+							MountPaths: []string{"/work/go"},
+							CacheDirs:  []string{"go-dirs/build"},
+						}, nil
+					},
+				},
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "pnpm" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{"/work/pnpm"}}, nil
+					},
+				},
+			},
+		}, exec
+	}
+
+	t.Run("deletes planned and recorded paths of a mode", func(t *testing.T) {
+		m, exec := newMounter(t)
+		m.DestructiveMode = true
+
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Modes: []string{"go"}})
+		require.NoError(t, err)
+		require.False(t, result.DryRun)
+		require.ElementsMatch(t, []cache.CleanEntry{
+			{Mode: "go", CachePath: filepath.Join(m.CacheRoot, "go-dirs/build"), Size: cache.DirSize{Bytes: 6, Files: 1}},
+			{Mode: "go", CachePath: filepath.Join(m.CacheRoot, "work/go"), Size: cache.DirSize{Bytes: 6, Files: 1}},
+			{Mode: "go", CachePath: filepath.Join(m.CacheRoot, "work/old-go"), Size: cache.DirSize{Bytes: 6, Files: 1}},
+		}, result.Deleted)
+		require.Equal(t, int64(18), result.FreedBytes)
+
+		require.NoDirExists(t, filepath.Join(m.CacheRoot, "work/go"))
+		require.NoDirExists(t, filepath.Join(m.CacheRoot, "go-dirs/build"))
+		require.NoDirExists(t, filepath.Join(m.CacheRoot, "work/old-go"))
+		require.DirExists(t, filepath.Join(m.CacheRoot, "work/pnpm"))
+		require.DirExists(t, filepath.Join(m.CacheRoot, "opt/tools"))
+
+		metadata, err := cache.ReadMetadata(exec, m.CacheRoot)
+		require.NoError(t, err)
+		require.Len(t, metadata.UserRequest, 1)
+		require.Contains(t, metadata.UserRequest, "/opt/tools")
+	})
+
+	t.Run("deletes paths", func(t *testing.T) {
+		m, _ := newMounter(t)
+		m.DestructiveMode = true
+
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Paths: []string{"/opt/tools", "/opt/missing"}})
+		require.NoError(t, err)
+		require.Len(t, result.Deleted, 1)
+		require.Equal(t, filepath.Join(m.CacheRoot, "opt/tools"), result.Deleted[0].CachePath)
+
+		require.NoDirExists(t, filepath.Join(m.CacheRoot, "opt/tools"))
+		require.DirExists(t, filepath.Join(m.CacheRoot, "work/go"))
+	})
+
+	t.Run("nested paths are deleted with their parent", func(t *testing.T) {
+		m, _ := newMounter(t)
+		m.DestructiveMode = true
+
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Modes: []string{"pnpm"}, Paths: []string{"/work"}})
+		require.NoError(t, err)
+		require.Len(t, result.Deleted, 1)
+		require.Equal(t, filepath.Join(m.CacheRoot, "work"), result.Deleted[0].CachePath)
+		require.Equal(t, int64(18), result.FreedBytes)
+	})
+
+	t.Run("dry run keeps everything", func(t *testing.T) {
+		m, exec := newMounter(t)
+
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Modes: []string{"pnpm"}})
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Len(t, result.Deleted, 1)
+
+		require.DirExists(t, filepath.Join(m.CacheRoot, "work/pnpm"))
+		require.Empty(t, exec.RemoveAllCalls())
+		require.Empty(t, exec.WriteFileCalls())
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		m, _ := newMounter(t)
+
+		_, err := m.Clean(t.Context(), cache.CleanRequest{Modes: []string{"maven"}})
+		require.ErrorContains(t, err, "unknown mode: maven")
+	})
+
+	t.Run("refuses to delete the cache root", func(t *testing.T) {
+		m, _ := newMounter(t)
+		m.DestructiveMode = true
+
+		_, err := m.Clean(t.Context(), cache.CleanRequest{Paths: []string{"/"}})
+		require.ErrorContains(t, err, "not within cache root")
+		require.DirExists(t, filepath.Join(m.CacheRoot, "work/go"))
+	})
+
+	t.Run("refuses to delete the metadata", func(t *testing.T) {
+		m, _ := newMounter(t)
+		m.DestructiveMode = true
+
+		_, err := m.Clean(t.Context(), cache.CleanRequest{Paths: []string{"/.ns"}})
+		require.ErrorContains(t, err, "holds cache metadata")
+	})
+
+	t.Run("requires modes or paths", func(t *testing.T) {
+		m, _ := newMounter(t)
+
+		_, err := m.Clean(t.Context(), cache.CleanRequest{})
+		require.ErrorContains(t, err, "at least one cache mode, path or scope must be specified")
+	})
+}
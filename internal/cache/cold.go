@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// coldArchivePrefix names the archive that a compressed cache entry is kept
+// in, inside its cache path, so that the entry stays where every command
+// looks for it and is deleted along with it.
+const coldArchivePrefix = ".ns-cold.tar"
+
+// coldCompressions are the compressions of cold archives, by extension.
+var coldCompressions = map[string]Compression{
+	".zst": CompressionZstd,
+	".gz":  CompressionGzip,
+}
+
+// CompressedCache describes a cache entry that was compressed because it was
+// rarely used. It is decompressed on its next mount.
+type CompressedCache struct {
+	CompressedAt  string      `json:"compressedAt"`
+	Compression   Compression `json:"compression"`
+	Bytes         int64       `json:"bytes"`
+	OriginalBytes int64       `json:"originalBytes"`
+}
+
+// coldArchiveName returns the name of the archive for compression.
+func coldArchiveName(compression Compression) (string, error) {
+	for ext, c := range coldCompressions {
+		if c == compression {
+			return coldArchivePrefix + ext, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported compression %q for cold caches, expected zstd or gzip", compression)
+}
+
+// coldArchive returns the archive of cachePath if it is compressed.
+func (m Mounter) coldArchive(cachePath string) (string, bool, error) {
+	for ext := range coldCompressions {
+		archive := filepath.Join(cachePath, coldArchivePrefix+ext)
+		if _, err := m.Exec.Stat(archive); err == nil {
+			return archive, true, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", false, fmt.Errorf("stat %q: %w", archive, err)
+		}
+	}
+	return "", false, nil
+}
+
+// compress replaces the contents of cachePath with an archive of them. The
+// archive is written next to cachePath and moved in, so that a cache path
+// holding an archive always holds all of its contents. Times are set to
+// lastUsed, so that eviction still sees when the cache was last used.
+func (m Mounter) compress(ctx context.Context, cachePath string, compression Compression, lastUsed time.Time) (CompressedCache, error) {
+	name, err := coldArchiveName(compression)
+	if err != nil {
+		return CompressedCache{}, err
+	}
+
+	tmp := cachePath + name + ".tmp"
+	size, err := writeColdArchive(ctx, tmp, cachePath, compression)
+	if err != nil {
+		return CompressedCache{}, errors.Join(err, m.Exec.RemoveAll(tmp))
+	}
+
+	children, err := os.ReadDir(cachePath)
+	if err != nil {
+		return CompressedCache{}, errors.Join(err, m.Exec.RemoveAll(tmp))
+	}
+	archive := filepath.Join(cachePath, name)
+	if err := m.Exec.Rename(tmp, archive); err != nil {
+		return CompressedCache{}, errors.Join(fmt.Errorf("moving archive into %q: %w", cachePath, err), m.Exec.RemoveAll(tmp))
+	}
+	for _, child := range children {
+		if err := m.Exec.RemoveAll(filepath.Join(cachePath, child.Name())); err != nil {
+			return CompressedCache{}, fmt.Errorf("removing compressed files: %w", err)
+		}
+	}
+
+	info, err := m.Exec.Stat(archive)
+	if err != nil {
+		return CompressedCache{}, err
+	}
+	if err := errors.Join(os.Chtimes(archive, lastUsed, lastUsed), os.Chtimes(cachePath, lastUsed, lastUsed)); err != nil {
+		slog.Debug("failed to keep the last use of a compressed cache", slog.String("path", cachePath), slog.Any("error", err))
+	}
+
+	return CompressedCache{
+		CompressedAt:  time.Now().UTC().Format(time.RFC3339),
+		Compression:   compression,
+		Bytes:         info.Size(),
+		OriginalBytes: size.Bytes,
+	}, nil
+}
+
+func writeColdArchive(ctx context.Context, path, cachePath string, compression Compression) (DirSize, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return DirSize{}, fmt.Errorf("creating archive: %w", err)
+	}
+	defer f.Close()
+
+	cw, err := compressWriter(ctx, f, compression)
+	if err != nil {
+		return DirSize{}, err
+	}
+	tw := tar.NewWriter(cw)
+	size, err := writeTree(tw, cachePath, cachePath)
+	if err != nil {
+		return DirSize{}, err
+	}
+	if err := errors.Join(tw.Close(), cw.Close(), f.Close()); err != nil {
+		return DirSize{}, fmt.Errorf("writing archive: %w", err)
+	}
+	return size, nil
+}
+
+// decompress extracts the archive of a compressed cache path in place, and
+// removes it.
+func (m Mounter) decompress(ctx context.Context, cachePath, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+	defer f.Close()
+
+	r, err := decompressReader(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	root, err := os.OpenRoot(cachePath)
+	if err != nil {
+		return fmt.Errorf("opening cache path: %w", err)
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(r)
+	var dirs []*tar.Header
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if err := extractEntry(root, name, hdr, tr); err != nil {
+			return fmt.Errorf("extracting %q: %w", name, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+	}
+	if err := applyDirModes(root, dirs); err != nil {
+		return err
+	}
+
+	if err := errors.Join(r.Close(), f.Close()); err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	return m.Exec.RemoveAll(archive)
+}
+
+// thaw decompresses a cache path that was compressed as it was rarely used,
+// before it is mounted. In dry-run mode it is only reported.
+func (m Mounter) thaw(ctx context.Context, cachePath string, mount *MountResult) error {
+	archive, ok, err := m.coldArchive(cachePath)
+	if err != nil || !ok {
+		return err
+	}
+	mount.Decompressed = true
+	if !m.DestructiveMode {
+		slog.Debug("dry-run: would decompress cache path", slog.String("path", cachePath))
+		return nil
+	}
+
+	slog.Debug("decompressing cache path", slog.String("path", cachePath))
+	if err := m.decompress(ctx, cachePath, archive); err != nil {
+		return fmt.Errorf("decompressing %q: %w", cachePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// DefaultProjectConfigFile is the checked-in project config that `cache mount`
+// picks up from the working directory.
+var DefaultProjectConfigFile = filepath.Join(".spacectl", "cache.yaml")
+
+// ProjectConfig holds the defaults for `cache mount` declared by a project.
+// Command line flags take precedence over every field.
+type ProjectConfig struct {
+	CacheRoot    string   `yaml:"cache_root"`
+	Detect       []string `yaml:"detect"`
+	Modes        []string `yaml:"modes"`
+	ExcludeModes []string `yaml:"exclude_modes"`
+	Paths        []string `yaml:"paths"`
+	EvalFile     string   `yaml:"eval_file"`
+	// EvalFormat is the syntax of the eval file: bash, fish, powershell,
+	// dotenv or github_env.
+	EvalFormat   string `yaml:"eval_format"`
+	ModesFile    string `yaml:"modes_file"`
+	Keyed        bool   `yaml:"keyed"`
+	Scope        string `yaml:"scope"`
+	DefaultScope string `yaml:"default_scope"`
+	// MountStrategy is how mount paths are mounted: auto, bind, symlink,
+	// copy or overlay.
+	MountStrategy string `yaml:"mount_strategy"`
+	// MountStrategies overrides MountStrategy per mode.
+	MountStrategies map[string]string `yaml:"mount_strategies"`
+	// Exclude maps mode names, or "*" for every mount, to glob patterns
+	// that are dropped from the cache on save.
+	Exclude map[string][]string `yaml:"exclude"`
+	// ReadOnly lists modes, "*" for every mount, or mount paths whose caches
+	// are mounted read-only.
+	ReadOnly []string `yaml:"read_only"`
+	// PreserveOwnership is whether caches take on the owner and mode of
+	// their mount paths: none, top or recursive.
+	PreserveOwnership string `yaml:"preserve_ownership"`
+	// AllowedRoots restricts the paths that are mounted over or removed to
+	// those beneath one of these directories.
+	AllowedRoots []string `yaml:"allowed_roots"`
+	// RequireVolume fails mounting when the cache root is not a mounted
+	// volume.
+	RequireVolume bool `yaml:"require_volume"`
+	// MinFree is the space, e.g. 5G, that the cache volume should have
+	// available before mounting.
+	MinFree string `yaml:"min_free"`
+	// RequireFree fails mounting when the cache volume has less than MinFree
+	// available.
+	RequireFree bool `yaml:"require_free"`
+	// EvictHighWater is the usage of the cache volume, in percent, above
+	// which the least recently used caches are evicted before mounting.
+	EvictHighWater float64 `yaml:"evict_high_water"`
+	// EvictLowWater is the usage that eviction frees the volume down to.
+	EvictLowWater float64 `yaml:"evict_low_water"`
+	// WorkDirs are the directories of the projects that modes are detected
+	// and planned in, e.g. subprojects of a monorepo.
+	WorkDirs []string `yaml:"workdirs"`
+	// Recursive also detects and plans modes in subprojects beneath the
+	// working directories, down to MaxDepth directories.
+	Recursive bool `yaml:"recursive"`
+	MaxDepth  int  `yaml:"max_depth"`
+	// ModeConfig maps modes to overrides of the environment they are planned
+	// with and the paths they mount.
+	ModeConfig map[string]ModeConfig `yaml:"mode_config"`
+	// Hooks maps modes, or "*" for every mount, to shell commands run
+	// before and after mounting.
+	Hooks map[string]Hooks `yaml:"hooks"`
+	// Telemetry opts the project in to uploading anonymized mount metrics
+	// to TelemetryEndpoint: on or off.
+	Telemetry         string `yaml:"telemetry"`
+	TelemetryEndpoint string `yaml:"telemetry_endpoint"`
+	// TelemetryPool names the runner pool that metrics are grouped by.
+	TelemetryPool string `yaml:"telemetry_pool"`
+	// MetricsFile is where mount metrics are written in the Prometheus text
+	// format.
+	MetricsFile string `yaml:"metrics_file"`
+}
+
+// LoadProjectConfig reads a project config from path. A missing file yields
+// an empty config when allowMissing is set. Relative files named in the
+// config are resolved against the directory of path.
+func LoadProjectConfig(path string, allowMissing bool) (ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if allowMissing && errors.Is(err, os.ErrNotExist) {
+			return ProjectConfig{}, nil
+		}
+		return ProjectConfig{}, fmt.Errorf("read project config: %w", err)
+	}
+
+	cfg, err := ParseProjectConfig(data)
+	if err != nil {
+		return ProjectConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for _, file := range []*string{&cfg.ModesFile, &cfg.EvalFile, &cfg.MetricsFile} {
+		*file = inConfigDir(dir, *file)
+	}
+	return cfg, nil
+}
+
+// inConfigDir resolves a file named in a config against the directory of the
+// config. Absolute paths and paths in the home directory are kept as they are.
+func inConfigDir(dir, file string) string {
+	if file == "" || filepath.IsAbs(file) || strings.HasPrefix(file, "~") {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+// ParseProjectConfig parses a project config, rejecting unknown fields so
+// that typos do not silently disable caching.
+func ParseProjectConfig(data []byte) (ProjectConfig, error) {
+	var cfg ProjectConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return ProjectConfig{}, fmt.Errorf("parse project config: %w", err)
+	}
+	return cfg, nil
+}
+
+// ModeStrategies parses the per-mode mount strategies.
+func (cfg ProjectConfig) ModeStrategies() (map[string]MountStrategy, error) {
+	if len(cfg.MountStrategies) == 0 {
+		return nil, nil
+	}
+
+	strategies := map[string]MountStrategy{}
+	for m, name := range cfg.MountStrategies {
+		s, err := ParseMountStrategy(name)
+		if err != nil {
+			return nil, fmt.Errorf("mount_strategies: %s: %w", m, err)
+		}
+		strategies[m] = s
+	}
+	return strategies, nil
+}
+
+// Validate checks that all modes referenced by the config are available.
+func (cfg ProjectConfig) Validate(available mode.Modes) error {
+	names := available.Names()
+
+	var errs []error
+	check := func(field string, modes []string) {
+		for _, m := range modes {
+			if !slices.Contains(names, m) {
+				errs = append(errs, fmt.Errorf("%s: unknown mode: %s", field, m))
+			}
+		}
+	}
+
+	if !slices.Equal(cfg.Detect, []string{"*"}) {
+		check("detect", cfg.Detect)
+	}
+	check("modes", cfg.Modes)
+	check("exclude_modes", cfg.ExcludeModes)
+
+	if _, err := ParseMountStrategy(cfg.MountStrategy); err != nil {
+		errs = append(errs, fmt.Errorf("mount_strategy: %w", err))
+	}
+	for _, m := range slices.Sorted(maps.Keys(cfg.MountStrategies)) {
+		check("mount_strategies", []string{m})
+		if _, err := ParseMountStrategy(cfg.MountStrategies[m]); err != nil {
+			errs = append(errs, fmt.Errorf("mount_strategies: %s: %w", m, err))
+		}
+	}
+
+	// Entries that do not look like paths name modes.
+	for _, entry := range cfg.ReadOnly {
+		if entry != AllModes && !strings.ContainsAny(entry, `/\~`) {
+			check("read_only", []string{entry})
+		}
+	}
+
+	if _, err := ParseOwnershipMode(cfg.PreserveOwnership); err != nil {
+		errs = append(errs, fmt.Errorf("preserve_ownership: %w", err))
+	}
+
+	if _, err := ParseEvalFormat(cfg.EvalFormat); err != nil {
+		errs = append(errs, fmt.Errorf("eval_format: %w", err))
+	}
+
+	for _, m := range slices.Sorted(maps.Keys(cfg.Exclude)) {
+		if m != AllModes {
+			check("exclude", []string{m})
+		}
+		if err := ValidateExcludes(cfg.Exclude[m]); err != nil {
+			errs = append(errs, fmt.Errorf("exclude: %s: %w", m, err))
+		}
+	}
+
+	for _, m := range slices.Sorted(maps.Keys(cfg.Hooks)) {
+		if m != AllModes {
+			check("hooks", []string{m})
+		}
+	}
+
+	if cfg.Telemetry != "" && cfg.Telemetry != "on" && cfg.Telemetry != "off" {
+		errs = append(errs, fmt.Errorf("telemetry: %q is not on or off", cfg.Telemetry))
+	}
+
+	for _, m := range slices.Sorted(maps.Keys(cfg.ModeConfig)) {
+		check("mode_config", []string{m})
+		for _, key := range slices.Sorted(maps.Keys(cfg.ModeConfig[m].Env)) {
+			if !envNamePattern.MatchString(key) {
+				errs = append(errs, fmt.Errorf("mode_config: %s: %q is not an environment variable", m, key))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,162 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestParseProjectConfig(t *testing.T) {
+	t.Run("parses all fields", func(t *testing.T) {
+		cfg, err := cache.ParseProjectConfig([]byte(`
+cache_root: /cache
+detect: ["*"]
+modes: [go]
+exclude_modes: [apt]
+paths: [/opt/tools]
+eval_file: cache.env
+eval_format: dotenv
+modes_file: .spacectl/modes.yaml
+keyed: true
+scope: auto
+default_scope: main
+mount_strategy: copy
+mount_strategies:
+  go: overlay
+exclude:
+  "*": ["**/*.log"]
+  go: [fuzz]
+read_only: [go, /opt/tools]
+preserve_ownership: top
+allowed_roots: ["~/work", /var/cache]
+`))
+		require.NoError(t, err)
+		require.Equal(t, cache.ProjectConfig{
+			CacheRoot:       "/cache",
+			Detect:          []string{"*"},
+			Modes:           []string{"go"},
+			ExcludeModes:    []string{"apt"},
+			Paths:           []string{"/opt/tools"},
+			EvalFile:        "cache.env",
+			EvalFormat:      "dotenv",
+			ModesFile:       ".spacectl/modes.yaml",
+			Keyed:           true,
+			Scope:           "auto",
+			DefaultScope:    "main",
+			MountStrategy:   "copy",
+			MountStrategies: map[string]string{"go": "overlay"},
+			Exclude: map[string][]string{
+				"*":  {"**/*.log"},
+				"go": {"fuzz"},
+			},
+			ReadOnly:          []string{"go", "/opt/tools"},
+			PreserveOwnership: "top",
+			AllowedRoots:      []string{"~/work", "/var/cache"},
+		}, cfg)
+	})
+
+	t.Run("empty config", func(t *testing.T) {
+		cfg, err := cache.ParseProjectConfig(nil)
+		require.NoError(t, err)
+		require.Equal(t, cache.ProjectConfig{}, cfg)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := cache.ParseProjectConfig([]byte("mode: [go]\n"))
+		require.ErrorContains(t, err, "field mode not found")
+	})
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	t.Run("missing file allowed", func(t *testing.T) {
+		cfg, err := cache.LoadProjectConfig(filepath.Join(t.TempDir(), "cache.yaml"), true)
+		require.NoError(t, err)
+		require.Equal(t, cache.ProjectConfig{}, cfg)
+	})
+
+	t.Run("missing file not allowed", func(t *testing.T) {
+		_, err := cache.LoadProjectConfig(filepath.Join(t.TempDir(), "cache.yaml"), false)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("reads file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("modes: [go]\n"), 0o644))
+
+		cfg, err := cache.LoadProjectConfig(path, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"go"}, cfg.Modes)
+	})
+
+	t.Run("files are relative to the config", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), ".spacectl")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		path := filepath.Join(dir, "cache.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("modes_file: modes.yaml\neval_file: ../cache.env\nmetrics_file: ~/spacectl.prom\n"), 0o644))
+
+		cfg, err := cache.LoadProjectConfig(path, false)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, "modes.yaml"), cfg.ModesFile)
+		require.Equal(t, filepath.Join(filepath.Dir(dir), "cache.env"), cfg.EvalFile)
+		require.Equal(t, "~/spacectl.prom", cfg.MetricsFile)
+	})
+}
+
+func TestProjectConfig_Validate(t *testing.T) {
+	available := mode.Modes{mode.AptProvider{}, mode.GoProvider{}}
+
+	t.Run("valid config", func(t *testing.T) {
+		cfg := cache.ProjectConfig{
+			Detect:        []string{"*"},
+			Modes:         []string{"go"},
+			ExcludeModes:  []string{"apt"},
+			Exclude:       map[string][]string{"*": {"**/*.log"}, "go": {"fuzz/**"}},
+			MountStrategy: "symlink",
+		}
+		require.NoError(t, cfg.Validate(available))
+	})
+
+	t.Run("reports all unknown modes", func(t *testing.T) {
+		cfg := cache.ProjectConfig{
+			Detect:            []string{"go", "rust"},
+			Modes:             []string{"gradle"},
+			ExcludeModes:      []string{"apt", "*"},
+			Exclude:           map[string][]string{"rust": {"target"}, "go": {"/abs", "[bad"}},
+			MountStrategy:     "overlay2",
+			MountStrategies:   map[string]string{"rust": "overlay", "go": "fuse"},
+			ReadOnly:          []string{"*", "go", "~/.cache", "rust"},
+			PreserveOwnership: "all",
+			EvalFormat:        "zsh",
+			ModeConfig: map[string]cache.ModeConfig{
+				"rust": {Paths: []string{"/target"}},
+				"go":   {Env: map[string]string{"GO MODCACHE": "/mnt/gomod"}},
+			},
+			Hooks:     map[string]cache.Hooks{"*": {PreMount: []string{"true"}}, "rust": {}},
+			Telemetry: "yes",
+		}
+
+		err := cfg.Validate(available)
+		require.ErrorContains(t, err, "detect: unknown mode: rust")
+		require.ErrorContains(t, err, "modes: unknown mode: gradle")
+		require.ErrorContains(t, err, "exclude_modes: unknown mode: *")
+		require.ErrorContains(t, err, "exclude: unknown mode: rust")
+		require.ErrorContains(t, err, `exclude: go: invalid exclude pattern "/abs"`)
+		require.ErrorContains(t, err, `mount_strategy: unknown mount strategy "overlay2"`)
+		require.ErrorContains(t, err, "mount_strategies: unknown mode: rust")
+		require.ErrorContains(t, err, `mount_strategies: go: unknown mount strategy "fuse"`)
+		require.ErrorContains(t, err, "read_only: unknown mode: rust")
+		require.NotContains(t, err.Error(), "read_only: unknown mode: ~/.cache")
+		require.ErrorContains(t, err, `preserve_ownership: unknown ownership mode "all"`)
+		require.ErrorContains(t, err, `eval_format: unknown eval format "zsh"`)
+		require.ErrorContains(t, err, "mode_config: unknown mode: rust")
+		require.ErrorContains(t, err, `mode_config: go: "GO MODCACHE" is not an environment variable`)
+		require.ErrorContains(t, err, "hooks: unknown mode: rust")
+		require.NotContains(t, err.Error(), "hooks: unknown mode: *")
+		require.ErrorContains(t, err, `telemetry: "yes" is not on or off`)
+	})
+}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DedupeRequest selects the cache entries whose identical files are hard
+// linked. Without modes, all entries in the metadata file are used.
+type DedupeRequest struct {
+	Modes []string
+	// MinSize skips files smaller than this many bytes, which save little
+	// when linked. Empty files are always skipped.
+	MinSize int64
+}
+
+type DedupeResponse struct {
+	CacheRoot string `json:"cache_root"`
+	DryRun    bool   `json:"dry_run"`
+	// Files is how many files were considered.
+	Files int `json:"files"`
+	// Linked is how many files were replaced with a hard link to an
+	// identical one.
+	Linked         int          `json:"linked"`
+	ReclaimedBytes int64        `json:"reclaimed_bytes"`
+	Modes          []ModeDedupe `json:"modes,omitzero"`
+}
+
+// ModeDedupe sums the files of a mode that were linked. Paths mounted with
+// --path have no mode.
+type ModeDedupe struct {
+	Mode           string `json:"mode"`
+	Linked         int    `json:"linked"`
+	ReclaimedBytes int64  `json:"reclaimed_bytes"`
+}
+
+// dedupeFile is a regular file in a cache entry.
+type dedupeFile struct {
+	mode string
+	path string
+	info fs.FileInfo
+	hash string
+}
+
+// owner is the owner and permissions of the file, which linked files share.
+func (f *dedupeFile) owner() FileOwner {
+	if owner, ok := fileOwner(f.info); ok {
+		return owner
+	}
+	return FileOwner{Mode: f.info.Mode().Perm()}
+}
+
+// Dedupe replaces identical files across the selected cache entries, e.g.
+// the same module in the Go module cache of different scopes, with hard
+// links to one of them. Files are identical when their contents, owner and
+// permissions are. Only read-only files are linked, like those of the
+// content-addressed stores that tools keep read-only, as a write to a linked
+// file would change every cache it is linked into. Without DestructiveMode it
+// only reports what would be linked.
+func (m Mounter) Dedupe(ctx context.Context, req DedupeRequest) (DedupeResponse, error) {
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return DedupeResponse{}, err
+	}
+	defer unlock()
+
+	return m.dedupe(ctx, req)
+}
+
+// dedupe is Dedupe for callers that hold the lock on the cache root.
+func (m Mounter) dedupe(ctx context.Context, req DedupeRequest) (DedupeResponse, error) {
+	files, err := m.dedupeFiles(req)
+	if err != nil {
+		return DedupeResponse{}, err
+	}
+	result := DedupeResponse{CacheRoot: m.CacheRoot, DryRun: !m.DestructiveMode, Files: len(files)}
+
+	// Only files of the same size can be identical, so only those are hashed.
+	bySize := map[int64][]*dedupeFile{}
+	for _, f := range files {
+		bySize[f.info.Size()] = append(bySize[f.info.Size()], f)
+	}
+	if err := hashFiles(ctx, bySize); err != nil {
+		return DedupeResponse{}, err
+	}
+
+	type key struct {
+		hash  string
+		owner FileOwner
+	}
+	groups := map[key][]*dedupeFile{}
+	for _, f := range files {
+		if f.hash != "" {
+			k := key{hash: f.hash, owner: f.owner()}
+			groups[k] = append(groups[k], f)
+		}
+	}
+
+	modes := map[string]*ModeDedupe{}
+	for _, f := range files {
+		group := groups[key{hash: f.hash, owner: f.owner()}]
+		if f.hash == "" || group[0] == f || os.SameFile(group[0].info, f.info) {
+			continue
+		}
+
+		if m.DestructiveMode {
+			if err := linkFile(group[0].path, f.path); err != nil {
+				// Entries may be on different devices, which cannot be linked.
+				slog.Debug("failed to link duplicate file", slog.String("path", f.path), slog.Any("error", err))
+				continue
+			}
+		} else {
+			slog.Debug("dry-run: would link duplicate file", slog.String("path", f.path), slog.String("to", group[0].path))
+		}
+
+		stats, ok := modes[f.mode]
+		if !ok {
+			stats = &ModeDedupe{Mode: f.mode}
+			modes[f.mode] = stats
+		}
+		stats.Linked++
+		stats.ReclaimedBytes += f.info.Size()
+		result.Linked++
+		result.ReclaimedBytes += f.info.Size()
+	}
+
+	for _, stats := range modes {
+		result.Modes = append(result.Modes, *stats)
+	}
+	slices.SortFunc(result.Modes, func(a, b ModeDedupe) int {
+		return cmp.Or(cmp.Compare(b.ReclaimedBytes, a.ReclaimedBytes), cmp.Compare(a.Mode, b.Mode))
+	})
+	return result, nil
+}
+
+// dedupeFiles lists the read-only regular files of the selected entries that
+// are large enough, sorted by path so that the same file is kept on every run.
+func (m Mounter) dedupeFiles(req DedupeRequest) ([]*dedupeFile, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct{ mode, path string }
+	var entries []entry
+	for _, e := range metadata.UserRequest {
+		var modeName string
+		if e.CacheFramework != nil {
+			modeName = *e.CacheFramework
+		}
+		if len(req.Modes) > 0 && !slices.Contains(req.Modes, modeName) {
+			continue
+		}
+		if !slices.ContainsFunc(entries, func(o entry) bool { return o.path == e.Source }) {
+			entries = append(entries, entry{mode: modeName, path: e.Source})
+		}
+	}
+
+	minSize := max(req.MinSize, 1)
+	var files []*dedupeFile
+	for _, e := range entries {
+		// Entries nested in others are walked with them.
+		if slices.ContainsFunc(entries, func(o entry) bool { return o.path != e.path && isWithin(o.path, e.path) }) {
+			continue
+		}
+		err := filepath.WalkDir(e.path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() >= minSize && info.Mode().Perm()&0o222 == 0 {
+				files = append(files, &dedupeFile{mode: e.mode, path: path, info: info})
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("walking %q: %w", e.path, err)
+		}
+	}
+
+	slices.SortFunc(files, func(a, b *dedupeFile) int { return cmp.Compare(a.path, b.path) })
+	return files, nil
+}
+
+// hashFiles hashes, in parallel, the files that share their size with
+// another.
+func hashFiles(ctx context.Context, bySize map[int64][]*dedupeFile) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.GOMAXPROCS(0))
+	for _, group := range bySize {
+		if len(group) < 2 {
+			continue
+		}
+		for _, f := range group {
+			eg.Go(func() error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				sum, err := checksumFile(f.path)
+				if err != nil {
+					return err
+				}
+				f.hash = sum.SHA256
+				return nil
+			})
+		}
+	}
+	return eg.Wait()
+}
+
+// linkFile replaces path with a hard link to target. The link is made next to
+// path and renamed over it, so that path is never missing.
+func linkFile(target, path string) error {
+	tmp := path + ".ns-dedupe"
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Dedupe(t *testing.T) {
+	// setup records a go and a cargo entry, which share a read-only file and
+	// a writable one.
+	setup := func(t *testing.T) (cache.Mounter, string) {
+		cacheRoot := t.TempDir()
+		files := map[string]string{
+			"go/mod/a":        "shared contents",
+			"go/mod/b":        "go only",
+			"cargo/crates/a":  "shared contents",
+			"cargo/crates/c":  "x",
+			"cargo/crates/b2": "go onlY",
+		}
+		for name, data := range files {
+			path := filepath.Join(cacheRoot, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o444))
+		}
+		// Build outputs are written to in place, so they are never linked.
+		for _, name := range []string{"go/target/a", "cargo/target/a"} {
+			path := filepath.Join(cacheRoot, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, []byte("shared contents"), 0o644))
+		}
+
+		metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{}}
+		for _, name := range []string{"go", "cargo"} {
+			mode := name
+			metadata.UserRequest["/work/"+name] = cache.CacheMetadataEntry{CacheFramework: &mode, Source: filepath.Join(cacheRoot, name)}
+		}
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		return cache.Mounter{CacheRoot: cacheRoot, Exec: &cache.ExecutorMock{ReadFileFunc: os.ReadFile, LockFunc: noLock}}, cacheRoot
+	}
+
+	t.Run("links identical files", func(t *testing.T) {
+		m, cacheRoot := setup(t)
+		m.DestructiveMode = true
+
+		result, err := m.Dedupe(t.Context(), cache.DedupeRequest{MinSize: 2})
+		require.NoError(t, err)
+		require.Equal(t, 4, result.Files)
+		require.Equal(t, 1, result.Linked)
+		require.Equal(t, int64(len("shared contents")), result.ReclaimedBytes)
+		require.Equal(t, []cache.ModeDedupe{{Mode: "go", Linked: 1, ReclaimedBytes: int64(len("shared contents"))}}, result.Modes)
+
+		a, err := os.Stat(filepath.Join(cacheRoot, "cargo/crates/a"))
+		require.NoError(t, err)
+		b, err := os.Stat(filepath.Join(cacheRoot, "go/mod/a"))
+		require.NoError(t, err)
+		require.True(t, os.SameFile(a, b))
+
+		// Linked files are not linked again.
+		result, err = m.Dedupe(t.Context(), cache.DedupeRequest{})
+		require.NoError(t, err)
+		require.Zero(t, result.Linked)
+	})
+
+	t.Run("dry run links nothing", func(t *testing.T) {
+		m, cacheRoot := setup(t)
+
+		result, err := m.Dedupe(t.Context(), cache.DedupeRequest{Modes: []string{"go", "cargo"}})
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Equal(t, 1, result.Linked)
+
+		a, err := os.Stat(filepath.Join(cacheRoot, "cargo/crates/a"))
+		require.NoError(t, err)
+		b, err := os.Stat(filepath.Join(cacheRoot, "go/mod/a"))
+		require.NoError(t, err)
+		require.False(t, os.SameFile(a, b))
+	})
+}
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// DoctorStatus is the outcome of a doctor check.
+type DoctorStatus string
+
+const (
+	DoctorOK   DoctorStatus = "ok"
+	DoctorWarn DoctorStatus = "warn"
+	DoctorFail DoctorStatus = "fail"
+	// DoctorSkip checks do not apply, e.g. on another OS.
+	DoctorSkip DoctorStatus = "skip"
+)
+
+// maxClockSkew is how far the clock may drift from the time that the cache
+// volume stamps files with before ages in metadata and pruning are off.
+const maxClockSkew = time.Minute
+
+// dockerCleanPath is the apt hook of Debian and Ubuntu container images that
+// empties the apt cache after every install.
+const dockerCleanPath = "/etc/apt/apt.conf.d/docker-clean"
+
+type DoctorResponse struct {
+	CacheRoot string        `json:"cache_root"`
+	Checks    []DoctorCheck `json:"checks"`
+}
+
+// DoctorCheck is a diagnosis of the environment that caches are mounted in.
+type DoctorCheck struct {
+	Name    string       `json:"name"`
+	Status  DoctorStatus `json:"status"`
+	Message string       `json:"message"`
+	// Fix suggests how to resolve a failure or a warning.
+	Fix string `json:"fix,omitzero"`
+}
+
+// Failed returns the checks that failed.
+func (r DoctorResponse) Failed() []DoctorCheck {
+	var failed []DoctorCheck
+	for _, check := range r.Checks {
+		if check.Status == DoctorFail {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// mountProber is implemented by executors that can check whether mounts are
+// permitted without mounting over any path of the job.
+type mountProber interface {
+	probeMount(ctx context.Context) error
+}
+
+// Doctor diagnoses the environment that caches are mounted in: sudo, mount
+// permissions, the cache root and its volume, the clock, tools known to
+// defeat caches, and the tools of the modes detected in the project. Every
+// check that does not pass comes with a suggested fix. The cache root need
+// not exist, which is reported.
+func (m Mounter) Doctor(ctx context.Context) DoctorResponse {
+	resp := DoctorResponse{CacheRoot: m.CacheRoot}
+	resp.Checks = append(resp.Checks, m.doctorSudo(ctx), m.doctorMount(ctx))
+	resp.Checks = append(resp.Checks, m.doctorCacheRoot()...)
+	resp.Checks = append(resp.Checks, m.doctorDockerClean())
+	resp.Checks = append(resp.Checks, m.doctorTools(ctx)...)
+	return resp
+}
+
+func (m Mounter) doctorSudo(ctx context.Context) DoctorCheck {
+	check := DoctorCheck{Name: "sudo"}
+	switch {
+	case runtime.GOOS == "windows":
+		check.Status, check.Message = DoctorSkip, "sudo is not used on windows"
+	case os.Geteuid() == 0:
+		check.Status, check.Message = DoctorOK, "running as root"
+	case DetectRootless(ctx):
+		check.Status, check.Message = DoctorWarn, "sudo is not installed or asks for a password, so caches are mounted rootless"
+		check.Fix = "Allow passwordless sudo for this user, or install bindfs so that rootless mounts are bind mounts rather than symlinks."
+	default:
+		check.Status, check.Message = DoctorOK, "passwordless sudo is available"
+	}
+	return check
+}
+
+func (m Mounter) doctorMount(ctx context.Context) DoctorCheck {
+	check := DoctorCheck{Name: "mount"}
+	if runtime.GOOS != "linux" {
+		check.Status, check.Message = DoctorSkip, fmt.Sprintf("bind mounts are not supported on %s, caches are symlinked", runtime.GOOS)
+		return check
+	}
+	prober, ok := m.Exec.(mountProber)
+	if !ok {
+		check.Status, check.Message = DoctorSkip, "the executor cannot be probed"
+		return check
+	}
+	if err := prober.probeMount(ctx); err != nil {
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("bind mounts are not permitted, caches fall back to symlinks: %v", err)
+		check.Fix = "Run with sudo or as root, or grant the container CAP_SYS_ADMIN, so that tools which do not follow symlinks see their caches."
+		return check
+	}
+	check.Status, check.Message = DoctorOK, "bind mounts are permitted"
+	return check
+}
+
+// doctorCacheRoot checks that the cache root is a writable directory on a
+// volume of its own, and that the clock agrees with the volume.
+func (m Mounter) doctorCacheRoot() []DoctorCheck {
+	root := DoctorCheck{Name: "cache_root"}
+	if m.CacheRoot == "" {
+		root.Status, root.Message = DoctorFail, "no cache root is configured"
+		root.Fix = "Attach a cache volume to the runner, which sets $NSC_CACHE_PATH, or pass --cache_root."
+		return []DoctorCheck{root}
+	}
+
+	info, err := m.Exec.Stat(m.CacheRoot)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		root.Status, root.Message = DoctorFail, fmt.Sprintf("%s does not exist", m.CacheRoot)
+		root.Fix = "Check that a cache volume is attached to the runner and mounted at the cache root."
+		return []DoctorCheck{root}
+	case err != nil:
+		root.Status, root.Message = DoctorFail, fmt.Sprintf("cannot stat %s: %v", m.CacheRoot, err)
+		return []DoctorCheck{root}
+	case !info.IsDir():
+		root.Status, root.Message = DoctorFail, fmt.Sprintf("%s is not a directory", m.CacheRoot)
+		root.Fix = "Point --cache_root at the directory the cache volume is mounted at."
+		return []DoctorCheck{root}
+	}
+
+	// A probe file checks that the root is writable, and tells the time of
+	// the volume.
+	probe := filepath.Join(m.CacheRoot, ".ns", fmt.Sprintf("doctor-%d", os.Getpid()))
+	clock := DoctorCheck{Name: "clock", Status: DoctorSkip, Message: "the cache root is not writable"}
+	written := time.Now()
+	if err := m.writeProbe(probe); err != nil {
+		root.Status, root.Message = DoctorFail, fmt.Sprintf("%s is not writable: %v", m.CacheRoot, err)
+		root.Fix = "Make the cache root writable by this user, or run with passwordless sudo."
+	} else {
+		root.Status, root.Message = DoctorOK, fmt.Sprintf("%s is writable", m.CacheRoot)
+		clock = m.doctorClock(probe, written)
+	}
+	if err := m.Exec.RemoveAll(probe); err != nil {
+		slog.Debug("failed to remove probe file", slog.String("path", probe), slog.Any("error", err))
+	}
+
+	return []DoctorCheck{root, m.doctorVolume(), clock}
+}
+
+func (m Mounter) writeProbe(path string) error {
+	if err := m.Exec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return m.Exec.WriteFile(path, nil, 0o644)
+}
+
+// doctorVolume checks that the cache root is on a mounted volume, as one on
+// the root disk caches nothing across jobs and fills the disk.
+func (m Mounter) doctorVolume() DoctorCheck {
+	check := DoctorCheck{Name: "volume"}
+	mounted, err := m.isVolume()
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		check.Status, check.Message = DoctorSkip, fmt.Sprintf("volumes cannot be told apart on %s", runtime.GOOS)
+	case err != nil:
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("cannot tell whether %s is on a mounted volume: %v", m.CacheRoot, err)
+	case !mounted:
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("%s is a plain directory, not a mounted volume, so caches do not outlive the runner", m.CacheRoot)
+		check.Fix = "Attach a cache volume to the runner, and check that the cache root is on it."
+	default:
+		check.Status, check.Message = DoctorOK, fmt.Sprintf("%s is on a mounted volume", m.CacheRoot)
+	}
+	return check
+}
+
+// doctorClock compares the time the volume stamped the probe with the local
+// time it was written at.
+func (m Mounter) doctorClock(probe string, written time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "clock"}
+	info, err := m.Exec.Stat(probe)
+	if err != nil {
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("cannot stat %s: %v", probe, err)
+		return check
+	}
+
+	skew := info.ModTime().Sub(written).Round(time.Second)
+	if skew.Abs() > maxClockSkew {
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("the clock is %s off the cache volume", skew.Abs())
+		check.Fix = "Synchronize the clock with NTP, as cache ages and pruning compare local times with the times of the volume."
+		return check
+	}
+	check.Status, check.Message = DoctorOK, "the clock agrees with the cache volume"
+	return check
+}
+
+func (m Mounter) doctorDockerClean() DoctorCheck {
+	check := DoctorCheck{Name: "docker-clean"}
+	if _, err := m.Exec.Stat(dockerCleanPath); err != nil {
+		check.Status, check.Message = DoctorOK, "no known tools clear caches"
+		return check
+	}
+	check.Status, check.Message = DoctorWarn, fmt.Sprintf("%s empties the apt cache after every install", dockerCleanPath)
+	check.Fix = "Enable the apt mode, which removes the hook when mounting, or delete the file."
+	return check
+}
+
+// versionArgs are the arguments that print the version of tools that do not
+// take --version.
+var versionArgs = map[string][]string{
+	"go":   {"version"},
+	"helm": {"version", "--short"},
+	"zig":  {"version"},
+}
+
+// doctorTools reports the version of the tool of each mode detected in the
+// project, as found on PATH by detection.
+func (m Mounter) doctorTools(ctx context.Context) []DoctorCheck {
+	projectDir, err := m.projectDir()
+	if err != nil {
+		return []DoctorCheck{{Name: "tools", Status: DoctorWarn, Message: fmt.Sprintf("cannot resolve the project dir: %v", err)}}
+	}
+
+	exec := m.modeExec()
+	results, err := m.Modes.DetectAll(ctx, mode.DetectRequest{Exec: exec, WorkDir: projectDir})
+	if err != nil {
+		return []DoctorCheck{{Name: "tools", Status: DoctorWarn, Message: fmt.Sprintf("detecting modes: %v", err)}}
+	}
+
+	var checks []DoctorCheck
+	for i, p := range m.Modes {
+		if !results[i].Detected {
+			continue
+		}
+		for _, c := range results[i].Checks {
+			if c.Kind != mode.CheckBinary || !c.Found {
+				continue
+			}
+			checks = append(checks, toolVersion(ctx, exec, p.Name(), c.Name))
+			break
+		}
+	}
+	return checks
+}
+
+func toolVersion(ctx context.Context, exec mode.Executor, modeName, binary string) DoctorCheck {
+	check := DoctorCheck{Name: "tool:" + modeName}
+	args, ok := versionArgs[binary]
+	if !ok {
+		args = []string{"--version"}
+	}
+
+	out, err := exec.Run(ctx, mode.CommandSpec{Name: binary, Args: args})
+	if err != nil {
+		check.Status, check.Message = DoctorWarn, fmt.Sprintf("%s was found but does not run: %v", binary, err)
+		check.Fix = fmt.Sprintf("Reinstall %s, or disable the %s mode with --exclude_mode.", binary, modeName)
+		return check
+	}
+
+	version, _, _ := strings.Cut(string(bytes.TrimSpace(out)), "\n")
+	check.Status, check.Message = DoctorOK, cmp.Or(version, binary+" runs")
+	return check
+}
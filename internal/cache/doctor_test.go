@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestMounter_Doctor(t *testing.T) {
+	checks := func(resp cache.DoctorResponse) map[string]cache.DoctorCheck {
+		byName := make(map[string]cache.DoctorCheck, len(resp.Checks))
+		for _, check := range resp.Checks {
+			byName[check.Name] = check
+		}
+		return byName
+	}
+	exec := func() *cache.ExecutorMock {
+		return &cache.ExecutorMock{
+			StatFunc:      os.Stat,
+			MkdirAllFunc:  os.MkdirAll,
+			WriteFileFunc: os.WriteFile,
+			RemoveAllFunc: os.RemoveAll,
+		}
+	}
+	noModes := mode.Modes{}
+
+	t.Run("missing cache root fails", func(t *testing.T) {
+		m := cache.Mounter{CacheRoot: filepath.Join(t.TempDir(), "missing"), Exec: exec(), Modes: noModes}
+		resp := m.Doctor(t.Context())
+
+		root := checks(resp)["cache_root"]
+		require.Equal(t, cache.DoctorFail, root.Status)
+		require.NotEmpty(t, root.Fix)
+		require.Equal(t, []cache.DoctorCheck{root}, resp.Failed())
+		require.NotContains(t, checks(resp), "clock")
+	})
+
+	t.Run("writable cache root on the root disk", func(t *testing.T) {
+		root := t.TempDir()
+		m := cache.Mounter{CacheRoot: root, Exec: exec(), Modes: noModes}
+		resp := m.Doctor(t.Context())
+		byName := checks(resp)
+
+		require.Equal(t, cache.DoctorOK, byName["cache_root"].Status)
+		require.Equal(t, cache.DoctorOK, byName["clock"].Status)
+		if runtime.GOOS != "windows" {
+			require.Equal(t, cache.DoctorWarn, byName["volume"].Status)
+			require.NotEmpty(t, byName["volume"].Fix)
+		}
+		require.Empty(t, resp.Failed())
+
+		entries, err := os.ReadDir(filepath.Join(root, ".ns"))
+		require.NoError(t, err)
+		require.Empty(t, entries, "the probe file is removed")
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		root := t.TempDir()
+		e := exec()
+		e.StatFunc = func(name string) (os.FileInfo, error) {
+			info, err := os.Stat(name)
+			if err != nil || filepath.Dir(name) != filepath.Join(root, ".ns") {
+				return info, err
+			}
+			return skewedInfo{info, time.Hour}, nil
+		}
+		m := cache.Mounter{CacheRoot: root, Exec: e, Modes: noModes}
+
+		clock := checks(m.Doctor(t.Context()))["clock"]
+		require.Equal(t, cache.DoctorWarn, clock.Status)
+		require.Equal(t, "the clock is 1h0m0s off the cache volume", clock.Message)
+	})
+
+	t.Run("tool versions of detected modes", func(t *testing.T) {
+		goBin, err := osexec.LookPath("go")
+		if err != nil {
+			t.Skip("go is not installed")
+		}
+		t.Setenv("PATH", filepath.Dir(goBin))
+
+		project := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(project, "go.mod"), []byte("module example.com/x\n"), 0o644))
+		m := cache.Mounter{CacheRoot: t.TempDir(), ProjectDir: project, Exec: exec(), Modes: mode.Modes{mode.GoProvider{}, mode.NpmProvider{}}}
+
+		byName := checks(m.Doctor(t.Context()))
+		require.Equal(t, cache.DoctorOK, byName["tool:go"].Status)
+		require.Contains(t, byName["tool:go"].Message, "go version go")
+		require.NotContains(t, byName, "tool:npm", "npm is not detected")
+	})
+}
+
+// skewedInfo is file info whose modification time is off by skew.
+type skewedInfo struct {
+	os.FileInfo
+	skew time.Duration
+}
+
+func (i skewedInfo) ModTime() time.Time {
+	return i.FileInfo.ModTime().Add(i.skew)
+}
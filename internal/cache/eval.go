@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EvalFormat is the syntax of the environment variables written to an eval
+// file after mounting.
+type EvalFormat string
+
+const (
+	// EvalBash writes `export KEY='value'` lines, for bash, zsh and sh.
+	EvalBash EvalFormat = "bash"
+	// EvalFish writes `set -gx KEY 'value'` lines.
+	EvalFish EvalFormat = "fish"
+	// EvalPowerShell writes `$env:KEY = 'value'` lines.
+	EvalPowerShell EvalFormat = "powershell"
+	// EvalDotenv writes KEY=value lines, quoting values that need it.
+	EvalDotenv EvalFormat = "dotenv"
+	// EvalGitHubEnv writes the format of GitHub Actions' $GITHUB_ENV file,
+	// which later steps of the job read their environment from.
+	EvalGitHubEnv EvalFormat = "github_env"
+)
+
+var evalFormats = []EvalFormat{EvalBash, EvalFish, EvalPowerShell, EvalDotenv, EvalGitHubEnv}
+
+// ParseEvalFormat validates an eval format name. An empty name is EvalBash.
+func ParseEvalFormat(name string) (EvalFormat, error) {
+	if name == "" {
+		return EvalBash, nil
+	}
+	if f := EvalFormat(name); slices.Contains(evalFormats, f) {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown eval format %q, expected one of %v", name, evalFormats)
+}
+
+// Appends reports whether files of this format are appended to rather than
+// replaced. $GITHUB_ENV is shared by all steps of a job.
+func (f EvalFormat) Appends() bool {
+	return f == EvalGitHubEnv
+}
+
+// Variables summarizing a mount, exported along with those of the modes.
+const (
+	EvalMountedEnv = "SPACECTL_CACHE_MOUNTED"
+	EvalHitsEnv    = "SPACECTL_CACHE_HITS"
+	EvalMissesEnv  = "SPACECTL_CACHE_MISSES"
+	EvalHitRateEnv = "SPACECTL_CACHE_HIT_RATE"
+)
+
+// EvalEnvs returns the environment variables to export after mounting: those
+// requested by modes, plus the number of mounted caches, their hits and
+// misses, and the hit rate as a percentage.
+func (r MountResponse) EvalEnvs() map[string]string {
+	envs := maps.Clone(r.Output.AddEnvs)
+	if envs == nil {
+		envs = map[string]string{}
+	}
+
+	var hits int
+	for _, m := range r.Output.Mounts {
+		if m.CacheHit {
+			hits++
+		}
+	}
+	mounted := len(r.Output.Mounts)
+
+	var rate int
+	if mounted > 0 {
+		rate = hits * 100 / mounted
+	}
+
+	envs[EvalMountedEnv] = strconv.Itoa(mounted)
+	envs[EvalHitsEnv] = strconv.Itoa(hits)
+	envs[EvalMissesEnv] = strconv.Itoa(mounted - hits)
+	envs[EvalHitRateEnv] = strconv.Itoa(rate)
+	return envs
+}
+
+// WriteEval writes envs to w in the given format, sorted by name.
+func WriteEval(w io.Writer, format EvalFormat, envs map[string]string) error {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(envs)) {
+		v := envs[k]
+		switch format {
+		case EvalBash, "":
+			fmt.Fprintf(&b, "export %s=%s\n", k, singleQuote(v, `'\''`, false))
+		case EvalFish:
+			fmt.Fprintf(&b, "set -gx %s %s\n", k, singleQuote(v, `\'`, true))
+		case EvalPowerShell:
+			fmt.Fprintf(&b, "$env:%s = %s\n", k, singleQuote(v, `''`, false))
+		case EvalDotenv:
+			fmt.Fprintf(&b, "%s=%s\n", k, dotenvValue(v))
+		case EvalGitHubEnv:
+			b.WriteString(githubEnvLine(k, v))
+		default:
+			return fmt.Errorf("unknown eval format %q", format)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// singleQuote quotes v in single quotes, replacing the quotes within it.
+// Fish also needs backslashes escaped.
+func singleQuote(v, quote string, escapeBackslash bool) string {
+	if escapeBackslash {
+		v = strings.ReplaceAll(v, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(v, "'", quote) + "'"
+}
+
+var dotenvSafe = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// dotenvValue leaves plain values unquoted, as not every .env reader handles
+// quotes, and double quotes the others.
+func dotenvValue(v string) string {
+	if dotenvSafe.MatchString(v) {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// githubEnvLine writes KEY=value, or a heredoc with a delimiter absent from
+// the value for multi-line values.
+func githubEnvLine(k, v string) string {
+	if !strings.ContainsAny(v, "\r\n") {
+		return k + "=" + v + "\n"
+	}
+	delim := "SPACECTL_EOF"
+	for strings.Contains(v, delim) {
+		delim += "_"
+	}
+	return k + "<<" + delim + "\n" + v + "\n" + delim + "\n"
+}
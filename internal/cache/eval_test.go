@@ -0,0 +1,79 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMountResponse_EvalEnvs(t *testing.T) {
+	t.Run("adds a summary to the envs of modes", func(t *testing.T) {
+		var result cache.MountResponse
+		result.Output.AddEnvs = map[string]string{"GOCACHE": "/cache/go"}
+		result.Output.Mounts = []cache.MountResult{
+			{MountPath: "/a", CacheHit: true},
+			{MountPath: "/b", CacheHit: true},
+			{MountPath: "/c"},
+		}
+
+		require.Equal(t, map[string]string{
+			"GOCACHE":                 "/cache/go",
+			"SPACECTL_CACHE_MOUNTED":  "3",
+			"SPACECTL_CACHE_HITS":     "2",
+			"SPACECTL_CACHE_MISSES":   "1",
+			"SPACECTL_CACHE_HIT_RATE": "66",
+		}, result.EvalEnvs())
+	})
+
+	t.Run("nothing mounted", func(t *testing.T) {
+		envs := cache.MountResponse{}.EvalEnvs()
+		require.Equal(t, "0", envs[cache.EvalMountedEnv])
+		require.Equal(t, "0", envs[cache.EvalHitRateEnv])
+	})
+}
+
+func TestWriteEval(t *testing.T) {
+	envs := map[string]string{
+		"A": "/cache/a",
+		"B": `it's $HOME\x`,
+	}
+
+	for _, tc := range []struct {
+		format cache.EvalFormat
+		want   string
+	}{
+		{cache.EvalBash, "export A='/cache/a'\nexport B='it'\\''s $HOME\\x'\n"},
+		{cache.EvalFish, "set -gx A '/cache/a'\nset -gx B 'it\\'s $HOME\\\\x'\n"},
+		{cache.EvalPowerShell, "$env:A = '/cache/a'\n$env:B = 'it''s $HOME\\x'\n"},
+		{cache.EvalDotenv, "A=/cache/a\nB=\"it's \\$HOME\\\\x\"\n"},
+		{cache.EvalGitHubEnv, "A=/cache/a\nB=it's $HOME\\x\n"},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			var b strings.Builder
+			require.NoError(t, cache.WriteEval(&b, tc.format, envs))
+			require.Equal(t, tc.want, b.String())
+		})
+	}
+
+	t.Run("multi-line github_env values use a heredoc", func(t *testing.T) {
+		var b strings.Builder
+		require.NoError(t, cache.WriteEval(&b, cache.EvalGitHubEnv, map[string]string{"A": "one\nSPACECTL_EOF"}))
+		require.Equal(t, "A<<SPACECTL_EOF_\none\nSPACECTL_EOF\nSPACECTL_EOF_\n", b.String())
+	})
+}
+
+func TestParseEvalFormat(t *testing.T) {
+	f, err := cache.ParseEvalFormat("")
+	require.NoError(t, err)
+	require.Equal(t, cache.EvalBash, f)
+
+	f, err = cache.ParseEvalFormat("powershell")
+	require.NoError(t, err)
+	require.Equal(t, cache.EvalPowerShell, f)
+
+	_, err = cache.ParseEvalFormat("zsh")
+	require.ErrorContains(t, err, `unknown eval format "zsh"`)
+}
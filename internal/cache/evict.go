@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// defaultEvictMargin is how many percentage points below EvictHighWater
+// eviction frees the volume down to, when EvictLowWater is unset.
+const defaultEvictMargin = 10
+
+// evictLowWater returns the usage, in percent of the volume, that eviction
+// frees it down to.
+func (m Mounter) evictLowWater() float64 {
+	if m.EvictLowWater > 0 {
+		return m.EvictLowWater
+	}
+	return max(m.EvictHighWater-defaultEvictMargin, 0)
+}
+
+// ValidateEviction checks that the water marks are percentages, with the
+// low-water mark below the high-water mark.
+func (m Mounter) ValidateEviction() error {
+	if m.EvictHighWater < 0 || m.EvictHighWater > 100 {
+		return fmt.Errorf("high-water mark %v is not a percentage", m.EvictHighWater)
+	}
+	if m.EvictLowWater < 0 || m.EvictLowWater > 100 {
+		return fmt.Errorf("low-water mark %v is not a percentage", m.EvictLowWater)
+	}
+	if m.EvictHighWater > 0 && m.evictLowWater() >= m.EvictHighWater {
+		return fmt.Errorf("low-water mark %v is not below the high-water mark %v", m.EvictLowWater, m.EvictHighWater)
+	}
+	return nil
+}
+
+// evict deletes the least recently used cache entries when the usage of the
+// cache volume exceeds EvictHighWater, until it is down to the low-water
+// mark. The caches of the plan are kept, as they are about to be mounted, and
+// so are those that other jobs may have mounted, as the lock on the cache
+// root is only held while mounting.
+// Without DestructiveMode it only reports what would be evicted.
+func (m Mounter) evict(ctx context.Context, plan Plan, result *MountResponse) error {
+	if m.EvictHighWater <= 0 {
+		return nil
+	}
+	usage, err := m.Exec.DiskUsage(ctx, m.CacheRoot)
+	if err != nil {
+		slog.Debug("cannot read the disk usage of the cache root", slog.String("cache_root", m.CacheRoot), slog.Any("error", err))
+		return nil
+	}
+	if usage.UsagePercent <= m.EvictHighWater {
+		return nil
+	}
+
+	// Usage is relative to the space that is used or available, like df.
+	capacity := usage.UsedBytes + usage.AvailableBytes
+	target := uint64(float64(capacity) * m.evictLowWater() / 100)
+	if usage.UsedBytes <= target {
+		return nil
+	}
+	req := PruneRequest{Free: int64(usage.UsedBytes - target), InUse: inUseWindow}
+	for _, mount := range plan.Mounts {
+		req.Keep = append(req.Keep, mount.Path)
+		if mount.CachePath != "" {
+			req.Keep = append(req.Keep, mount.CachePath)
+		}
+	}
+
+	pruned, err := m.prune(ctx, req, time.Now())
+	if err != nil {
+		return fmt.Errorf("evicting caches: %w", err)
+	}
+	result.Output.Evicted = pruned.Deleted
+
+	slog.Debug("evicted caches", slog.Float64("usage_percent", usage.UsagePercent), slog.Int("count", len(pruned.Deleted)), slog.Int64("freed_bytes", pruned.FreedBytes))
+	if pruned.FreedBytes < req.Free {
+		result.Output.Warnings = append(result.Output.Warnings, MountIssue{
+			Path:        m.CacheRoot,
+			Message:     fmt.Sprintf("evicting caches freed %s of the %s needed to bring the volume down to %.0f%%", FormatSize(pruned.FreedBytes), FormatSize(req.Free), m.evictLowWater()),
+			Recoverable: true,
+		})
+	}
+	return nil
+}
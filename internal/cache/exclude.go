@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// AllModes is the key of exclude patterns that apply to every mount.
+const AllModes = "*"
+
+// ValidateExcludes checks the syntax of exclude patterns.
+func ValidateExcludes(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" || path.IsAbs(pattern) || strings.HasPrefix(pattern, "../") {
+			return fmt.Errorf("invalid exclude pattern %q: must be relative to the cached path", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchExclude reports whether the slash-separated path rel, relative to
+// a cached path, matches pattern. Patterns use path.Match syntax per
+// element, and a "**" element matches any number of elements.
+func matchExclude(pattern, rel string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// excludedPaths returns the files and directories beneath root that match
+// any of the patterns. Matching directories are not descended into.
+func excludedPaths(root string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range patterns {
+			if matchExclude(pattern, filepath.ToSlash(rel)) {
+				matches = append(matches, p)
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("matching excludes in %q: %w", root, err)
+	}
+	return matches, nil
+}
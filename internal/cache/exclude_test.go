@@ -0,0 +1,129 @@
+package cache_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestValidateExcludes(t *testing.T) {
+	require.NoError(t, cache.ValidateExcludes([]string{"*.log", "**/tmp", "node_modules/.cache/**"}))
+
+	require.ErrorContains(t, cache.ValidateExcludes([]string{"/tmp"}), "must be relative")
+	require.ErrorContains(t, cache.ValidateExcludes([]string{"../x"}), "must be relative")
+	require.ErrorContains(t, cache.ValidateExcludes([]string{""}), "must be relative")
+	require.ErrorContains(t, cache.ValidateExcludes([]string{"[x"}), "syntax error")
+}
+
+func TestMounter_SaveExcludes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+
+	files := []string{
+		"build.log",
+		"pkg/a.go",
+		"pkg/debug.log",
+		"node_modules/.cache/tmp/x",
+		"node_modules/.cache/keep",
+		"node_modules/lib/index.js",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		exclude map[string][]string
+		remains []string
+	}{
+		{
+			name:    "top-level pattern",
+			exclude: map[string][]string{cache.AllModes: {"*.log"}},
+			remains: []string{"node_modules/.cache/keep", "node_modules/.cache/tmp/x", "node_modules/lib/index.js", "pkg/a.go", "pkg/debug.log"},
+		},
+		{
+			name:    "pattern at any depth",
+			exclude: map[string][]string{cache.AllModes: {"**/*.log"}},
+			remains: []string{"node_modules/.cache/keep", "node_modules/.cache/tmp/x", "node_modules/lib/index.js", "pkg/a.go"},
+		},
+		{
+			name:    "directory",
+			exclude: map[string][]string{"npm": {"node_modules/.cache/tmp"}},
+			remains: []string{"build.log", "node_modules/.cache/keep", "node_modules/lib/index.js", "pkg/a.go", "pkg/debug.log"},
+		},
+		{
+			name:    "other modes' patterns do not apply",
+			exclude: map[string][]string{"go": {"**"}},
+			remains: files,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cacheRoot := t.TempDir()
+			mountPath := filepath.Join(t.TempDir(), "npm")
+
+			m := cache.Mounter{
+				DestructiveMode: true,
+				CacheRoot:       cacheRoot,
+				Exec: &cache.ExecutorMock{
+					DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+						return cache.DiskUsage{}, nil
+					},
+					MkdirAllFunc:  os.MkdirAll,
+					ReadFileFunc:  os.ReadFile,
+					RemoveAllFunc: os.RemoveAll,
+					StatFunc:      os.Stat,
+					SyncFunc:      func(ctx context.Context) error { return nil },
+					LockFunc:      noLock,
+					WriteFileFunc: os.WriteFile,
+					MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+						if err := os.MkdirAll(from, 0o755); err != nil {
+							return "", err
+						}
+						return cache.MountSymlink, os.Symlink(from, to)
+					},
+				},
+				Modes: mode.Modes{&mode.ModeProviderMock{
+					NameFunc: func() string { return "npm" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{mountPath}}, nil
+					},
+				}},
+			}
+
+			_, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"npm"}, Exclude: tc.exclude})
+			require.NoError(t, err)
+			for _, f := range files {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(mountPath, f)), 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(mountPath, f), []byte("x"), 0o644))
+			}
+
+			result, err := m.Save(t.Context(), cache.SaveRequest{})
+			require.NoError(t, err)
+			require.Equal(t, len(tc.remains), result.Total.Files)
+
+			var remains []string
+			require.NoError(t, filepath.WalkDir(mountPath+"/", func(p string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(mountPath, p)
+					remains = append(remains, filepath.ToSlash(rel))
+				}
+				return err
+			}))
+			slices.Sort(remains)
+			require.Equal(t, slices.Sorted(slices.Values(tc.remains)), remains)
+		})
+	}
+
+	t.Run("rejects invalid patterns", func(t *testing.T) {
+		m := cache.Mounter{Modes: mode.Modes{}}
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"/x"}, Exclude: map[string][]string{"*": {"/abs"}}})
+		require.ErrorContains(t, err, "invalid exclude pattern")
+	})
+}
@@ -4,22 +4,25 @@ package cache
 
 import (
 	"context"
-	"fmt"
-	"path/filepath"
+	"errors"
 )
 
-func mount(ctx context.Context, from, to string) error {
-	if err := sudoMkdirP(ctx, filepath.Dir(to)); err != nil {
-		return err
+// mountAuto symlinks, as macOS has no bind mounts.
+func (e DefaultExecutor) mountAuto(ctx context.Context, from, to string, readOnly bool) (MountStrategy, error) {
+	if readOnly {
+		return "", errors.New("read-only mounts are not supported on darwin")
 	}
+	return MountSymlink, e.symlink(ctx, from, to)
+}
 
-	if _, err := run(ctx, "sudo", "rm", "-rf", to); err != nil {
-		return fmt.Errorf("removing to path %q: %w", to, err)
-	}
+func (e DefaultExecutor) bind(ctx context.Context, from, to string, readOnly bool) error {
+	return errors.New("bind mounts are not supported on darwin")
+}
 
-	if _, err := run(ctx, "sudo", "ln", "-sfn", from, to); err != nil {
-		return fmt.Errorf("symlinking from %q to %q: %w", from, to, err)
-	}
+func (e DefaultExecutor) probeMount(ctx context.Context) error {
+	return errors.New("bind mounts are not supported on darwin")
+}
 
-	return chownSelf(ctx, to)
+func (e DefaultExecutor) overlay(ctx context.Context, from, to string, readOnly bool) error {
+	return errors.New("overlay mounts are not supported on darwin")
 }
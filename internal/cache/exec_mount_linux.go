@@ -6,28 +6,186 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
-func mount(ctx context.Context, from, to string) error {
-	// existing files can't be mounted over, so we'll need to remove first
-	mountPathInfo, err := os.Lstat(to)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("stating to path %q: %w", to, err)
+// mountAuto bind mounts, and falls back to a symlink where bind mounts are
+// not permitted, e.g. in unprivileged containers or without bindfs. Whether
+// they are is probed once, so that a bind mount that fails for another
+// reason is retried and reported rather than replacing the mount path, which
+// a symlink deletes. Symlinks cannot be read-only, so read-only mounts do not
+// fall back.
+func (e DefaultExecutor) mountAuto(ctx context.Context, from, to string, readOnly bool) (MountStrategy, error) {
+	permitted, err := e.bindPermitted(ctx)
+	if err != nil {
+		return "", err
 	}
-	if mountPathInfo != nil && !mountPathInfo.IsDir() {
-		if _, err := run(ctx, "sudo", "rm", "-rf", to); err != nil {
-			return fmt.Errorf("removing non-directory to path %q: %w", to, err)
+	if permitted || readOnly {
+		return MountBind, e.bind(ctx, from, to, readOnly)
+	}
+	return MountSymlink, e.symlink(ctx, from, to)
+}
+
+// bindProbe records whether bind mounts are permitted, once probed.
+type bindProbe struct {
+	mu     sync.Mutex
+	probed bool
+	err    error
+}
+
+// bindProbes are kept per process, for sudo and for bindfs.
+var bindProbes = map[bool]*bindProbe{false: {}, true: {}}
+
+// bindPermitted probes whether bind mounts are permitted, the first time it
+// is called. A probe cut short by ctx is not recorded.
+func (e DefaultExecutor) bindPermitted(ctx context.Context) (bool, error) {
+	p := bindProbes[e.Rootless]
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.probed {
+		err := e.probeMount(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return false, ctxErr
 		}
+		if err != nil {
+			slog.Debug("bind mounts are not permitted, falling back to symlinks", slog.Any("error", err))
+		}
+		p.probed, p.err = true, err
 	}
+	return p.err == nil, nil
+}
 
-	if err := sudoMkdirP(ctx, to); err != nil {
+// bind bind mounts from onto to. Rootless, it uses bindfs, a FUSE filesystem
+// that unprivileged users can mount.
+func (e DefaultExecutor) bind(ctx context.Context, from, to string, readOnly bool) error {
+	if err := e.mountPoint(ctx, to); err != nil {
 		return err
 	}
 
-	if _, err := run(ctx, "sudo", "mount", "--bind", from, to); err != nil {
+	if e.Rootless {
+		args := []string{"--no-allow-other", from, to}
+		if readOnly {
+			args = append([]string{"-r"}, args...)
+		}
+		if _, err := run(ctx, "bindfs", args...); err != nil {
+			return fmt.Errorf("binding from %q to %q with bindfs: %w", from, to, err)
+		}
+		return nil
+	}
+
+	if _, err := e.sudo(ctx, "mount", "--bind", from, to); err != nil {
 		return fmt.Errorf("binding from %q to %q: %w", from, to, err)
 	}
 
+	// Bind mounts take the read-only flag only when remounted.
+	if readOnly {
+		if _, err := e.sudo(ctx, "mount", "-o", "remount,bind,ro", to); err != nil {
+			return errors.Join(fmt.Errorf("remounting %q read-only: %w", to, err), e.unmount(ctx, to))
+		}
+	}
+
+	return nil
+}
+
+// unmount unmounts to, so that a mount that could not be completed does not
+// stay behind.
+func (e DefaultExecutor) unmount(ctx context.Context, to string) error {
+	// Unprivileged users unmount FUSE filesystems, like bindfs, with
+	// fusermount.
+	if e.Rootless {
+		if _, err := run(ctx, "fusermount", "-u", to); err != nil {
+			return fmt.Errorf("unmounting %q: %w", to, err)
+		}
+		return nil
+	}
+	if _, err := e.sudo(ctx, "umount", to); err != nil {
+		return fmt.Errorf("unmounting %q: %w", to, err)
+	}
+	return nil
+}
+
+// probeMount bind mounts a temporary directory onto another and unmounts it,
+// to check that mounts are permitted.
+func (e DefaultExecutor) probeMount(ctx context.Context) error {
+	dir, err := os.MkdirTemp("", "spacectl-probe")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+	if err := os.Mkdir(from, 0o755); err != nil {
+		return err
+	}
+	if err := e.bind(ctx, from, to, false); err != nil {
+		return err
+	}
+	return e.unmount(ctx, to)
+}
+
+// overlay mounts an overlayfs at to, with from as the read-only lower layer
+// and a job-local upper layer. Read-only, it has no upper layer at all.
+// Rootless, it uses fuse-overlayfs.
+func (e DefaultExecutor) overlay(ctx context.Context, from, to string, readOnly bool) error {
+	dir := overlayDir(to)
+	upper, work := filepath.Join(dir, "upper"), filepath.Join(dir, "work")
+
+	// overlayfs options separate layers with colons and options with commas.
+	for _, p := range []string{from, upper, work} {
+		if strings.ContainsAny(p, ",:") {
+			return fmt.Errorf("overlay layer %q must not contain ',' or ':'", p)
+		}
+	}
+
+	// A previous job's writes must not leak into this one.
+	if err := e.RemoveAll(dir); err != nil {
+		return fmt.Errorf("removing overlay dir %q: %w", dir, err)
+	}
+
+	opts := "lowerdir=" + from
+	if !readOnly {
+		for _, d := range []string{upper, work} {
+			if err := os.MkdirAll(d, 0o755); err != nil {
+				return fmt.Errorf("creating overlay dir %q: %w", d, err)
+			}
+		}
+		opts += fmt.Sprintf(",upperdir=%s,workdir=%s", upper, work)
+	}
+
+	if err := e.mountPoint(ctx, to); err != nil {
+		return err
+	}
+
+	if e.Rootless {
+		if _, err := run(ctx, "fuse-overlayfs", "-o", opts, to); err != nil {
+			return fmt.Errorf("mounting overlay of %q at %q with fuse-overlayfs: %w", from, to, err)
+		}
+		return nil
+	}
+
+	if _, err := e.sudo(ctx, "mount", "-t", "overlay", "overlay", "-o", opts, to); err != nil {
+		return fmt.Errorf("mounting overlay of %q at %q: %w", from, to, err)
+	}
 	return nil
 }
+
+// mountPoint prepares to as a directory to mount over.
+func (e DefaultExecutor) mountPoint(ctx context.Context, to string) error {
+	// existing files can't be mounted over, so we'll need to remove first
+	mountPathInfo, err := os.Lstat(to)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stating to path %q: %w", to, err)
+	}
+	if mountPathInfo != nil && !mountPathInfo.IsDir() {
+		if _, err := e.sudo(ctx, "rm", "-rf", to); err != nil {
+			return fmt.Errorf("removing non-directory to path %q: %w", to, err)
+		}
+	}
+
+	return e.mkdirP(ctx, to)
+}
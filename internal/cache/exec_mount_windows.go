@@ -4,12 +4,35 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 )
 
-func mount(ctx context.Context, from, to string) error {
+// mountAuto creates a directory junction, which needs no elevation, so
+// Rootless makes no difference on Windows.
+func (e DefaultExecutor) mountAuto(ctx context.Context, from, to string, readOnly bool) (MountStrategy, error) {
+	if readOnly {
+		return "", errors.New("read-only mounts are not supported on windows")
+	}
+	return MountSymlink, e.symlink(ctx, from, to)
+}
+
+func (e DefaultExecutor) bind(ctx context.Context, from, to string, readOnly bool) error {
+	return errors.New("bind mounts are not supported on windows")
+}
+
+func (e DefaultExecutor) probeMount(ctx context.Context) error {
+	return errors.New("bind mounts are not supported on windows")
+}
+
+func (e DefaultExecutor) overlay(ctx context.Context, from, to string, readOnly bool) error {
+	return errors.New("overlay mounts are not supported on windows")
+}
+
+// symlink replaces to with a directory junction to from.
+func (e DefaultExecutor) symlink(ctx context.Context, from, to string) error {
 	// cmd.exe's mklink parses forward slashes as switch delimiters, so a path
 	// like "./target" would be read as an invalid "/target" switch. Normalize
 	// to backslashes before invoking it.
@@ -30,3 +53,20 @@ func mount(ctx context.Context, from, to string) error {
 
 	return nil
 }
+
+// copyTo replaces to with a copy of from.
+func (e DefaultExecutor) copyTo(ctx context.Context, from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return fmt.Errorf("creating parent of to path %q: %w", to, err)
+	}
+
+	if err := os.RemoveAll(to); err != nil {
+		return fmt.Errorf("removing existing to path %q: %w", to, err)
+	}
+
+	if err := e.CopyDir(ctx, from, to); err != nil {
+		return fmt.Errorf("copying from %q to %q: %w", from, to, err)
+	}
+
+	return nil
+}
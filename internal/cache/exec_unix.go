@@ -7,46 +7,178 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
-	"strings"
+	"syscall"
+	"time"
 )
 
 func (e DefaultExecutor) RemoveAll(name string) error {
-	_, err := run(context.Background(), "sudo", "rm", "-rf", name)
+	_, err := e.sudo(context.Background(), "rm", "-rf", name)
 	return err
 }
 
-func (e DefaultExecutor) DiskUsage(ctx context.Context, path string) (DiskUsage, error) {
-	output, err := run(ctx, "df", "-h", path)
+func (e DefaultExecutor) Rename(from, to string) error {
+	_, err := e.sudo(context.Background(), "mv", from, to)
+	return err
+}
+
+// CopyDir copies a directory tree, preserving ownership, modes and times.
+func (e DefaultExecutor) CopyDir(ctx context.Context, from, to string) error {
+	_, err := e.sudo(ctx, "cp", "-a", from, to)
+	return err
+}
+
+// DetectRootless reports whether sudo is unavailable: not installed, or
+// asking for a password. Root needs no sudo.
+func DetectRootless(ctx context.Context) bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return true
+	}
+	_, err := run(ctx, "sudo", "-n", "true")
+	return err != nil
+}
+
+// shellCommand runs command with sh.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// sudo runs a command with sudo, or directly when running rootless or as root.
+func (e DefaultExecutor) sudo(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if e.Rootless || os.Geteuid() == 0 {
+		return run(ctx, name, args...)
+	}
+	return run(ctx, "sudo", append([]string{name}, args...)...)
+}
+
+// symlink replaces to with a symlink to from.
+func (e DefaultExecutor) symlink(ctx context.Context, from, to string) error {
+	if err := e.mkdirP(ctx, filepath.Dir(to)); err != nil {
+		return err
+	}
+
+	if _, err := e.sudo(ctx, "rm", "-rf", to); err != nil {
+		return fmt.Errorf("removing to path %q: %w", to, err)
+	}
+
+	if _, err := e.sudo(ctx, "ln", "-sfn", from, to); err != nil {
+		return fmt.Errorf("symlinking from %q to %q: %w", from, to, err)
+	}
+
+	if e.Rootless {
+		return nil
+	}
+	return e.chownSelf(ctx, to)
+}
+
+// copyTo replaces to with a copy of from.
+func (e DefaultExecutor) copyTo(ctx context.Context, from, to string) error {
+	if err := e.mkdirP(ctx, filepath.Dir(to)); err != nil {
+		return err
+	}
+
+	if _, err := e.sudo(ctx, "rm", "-rf", to); err != nil {
+		return fmt.Errorf("removing to path %q: %w", to, err)
+	}
+
+	if err := e.CopyDir(ctx, from, to); err != nil {
+		return fmt.Errorf("copying from %q to %q: %w", from, to, err)
+	}
+
+	return nil
+}
+
+// Lock takes an exclusive flock on path, polling until ctx is done.
+func (e DefaultExecutor) Lock(ctx context.Context, path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
 	if err != nil {
-		return DiskUsage{}, fmt.Errorf("running df: %w", err)
+		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
-		return DiskUsage{}, errors.New("unexpected df output: missing data line")
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return f.Close, nil // closing releases the lock
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("flock %q: %w", path, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for lock on %q: %w", path, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
 	}
+}
 
-	columns := strings.Fields(lines[1])
-	if len(columns) < 3 {
-		return DiskUsage{}, errors.New("unexpected df output: insufficient columns")
+// Sync flushes written data to the underlying volumes.
+func (e DefaultExecutor) Sync(ctx context.Context) error {
+	_, err := run(ctx, "sync")
+	return err
+}
+
+func (e DefaultExecutor) DiskUsage(_ context.Context, path string) (DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskUsage{}, fmt.Errorf("statfs %q: %w", path, err)
 	}
 
-	return DiskUsage{
-		Total: columns[1],
-		Used:  columns[2],
-	}, nil
+	bsize := uint64(st.Bsize)
+	return newDiskUsage(st.Blocks*bsize, st.Bfree*bsize, st.Bavail*bsize), nil
+}
+
+// Chown applies owner to path, and to everything beneath it when recursive.
+// The mode is only applied to path itself.
+func (e DefaultExecutor) Chown(ctx context.Context, path string, owner FileOwner, recursive bool) error {
+	args := []string{fmt.Sprintf("%d:%d", owner.UID, owner.GID), path}
+	if recursive {
+		args = append([]string{"-R"}, args...)
+	}
+	if _, err := e.sudo(ctx, "chown", args...); err != nil {
+		return fmt.Errorf("chown %q: %w", path, err)
+	}
+
+	if _, err := e.sudo(ctx, "chmod", fmt.Sprintf("%04o", owner.Mode.Perm()), path); err != nil {
+		return fmt.Errorf("chmod %q: %w", path, err)
+	}
+	return nil
+}
+
+// fileOwner returns the owner and mode of a path from its stat info.
+func fileOwner(info os.FileInfo) (FileOwner, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileOwner{}, false
+	}
+	return FileOwner{UID: int(st.Uid), GID: int(st.Gid), Mode: info.Mode().Perm()}, true
+}
+
+// fileDevice returns the device of a path from its stat info. It differs from
+// the device of the parent directory at mount points.
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
 }
 
 // chownSelf changes the ownership of the given path to the current user.
-func chownSelf(ctx context.Context, path string) error {
+func (e DefaultExecutor) chownSelf(ctx context.Context, path string) error {
 	currentUser, err := user.Current()
 	if err != nil {
 		return fmt.Errorf("getting current user: %w", err)
 	}
 
-	_, err = run(ctx, "sudo", "chown", fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid), path)
+	_, err = e.sudo(ctx, "chown", fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid), path)
 	if err != nil {
 		return fmt.Errorf("sudo chown failed: %w", err)
 	}
@@ -54,8 +186,12 @@ func chownSelf(ctx context.Context, path string) error {
 	return nil
 }
 
-// sudoMkdirP creates all ancestor directories of the given path using sudo.
-func sudoMkdirP(ctx context.Context, path string) error {
+// mkdirP creates all ancestor directories of the given path using sudo.
+func (e DefaultExecutor) mkdirP(ctx context.Context, path string) error {
+	if e.Rootless {
+		return os.MkdirAll(path, 0o755)
+	}
+
 	for _, p := range ancestors(path) {
 		// Check if directory already exists
 		_, err := os.Stat(p)
@@ -69,12 +205,16 @@ func sudoMkdirP(ctx context.Context, path string) error {
 		}
 
 		// Directory doesn't exist, try to create it
-		if _, err := run(ctx, "sudo", "mkdir", p); err != nil {
+		if _, err := e.sudo(ctx, "mkdir", p); err != nil {
+			// A concurrent mount may have created it in the meantime.
+			if info, statErr := os.Stat(p); statErr == nil && info.IsDir() {
+				continue
+			}
 			return fmt.Errorf("sudo mkdir directory `%s`: %w", p, err)
 		}
 
 		// Change ownership to current user
-		if err := chownSelf(ctx, p); err != nil {
+		if err := e.chownSelf(ctx, p); err != nil {
 			return fmt.Errorf("chown %q: %w", p, err)
 		}
 	}
@@ -4,21 +4,159 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
 var (
 	kernel32               = syscall.NewLazyDLL("kernel32.dll")
 	procGetDiskFreeSpaceEx = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procLockFileEx         = kernel32.NewProc("LockFileEx")
 )
 
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// shellCommand runs command with cmd.exe.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
+
 func (e DefaultExecutor) RemoveAll(name string) error {
 	return os.RemoveAll(name)
 }
 
+func (e DefaultExecutor) Rename(from, to string) error {
+	return os.Rename(from, to)
+}
+
+// CopyDir copies a directory tree, preserving modes and modification times.
+func (e DefaultExecutor) CopyDir(ctx context.Context, from, to string) error {
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := copyRegularFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			// Links and special files are not part of caches on windows.
+			return nil
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func copyRegularFile(from, to string, perm os.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Chown is not supported: windows files have ACLs rather than an owner and
+// mode.
+func (e DefaultExecutor) Chown(context.Context, string, FileOwner, bool) error {
+	return errors.New("changing ownership is not supported on windows")
+}
+
+// fileOwner reports that windows files have no owner to preserve.
+func fileOwner(os.FileInfo) (FileOwner, bool) {
+	return FileOwner{}, false
+}
+
+// fileDevice reports that windows stat info has no device.
+func fileDevice(os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// DetectRootless reports whether sudo is unavailable. Windows never uses it.
+func DetectRootless(context.Context) bool {
+	return false
+}
+
+// Lock takes an exclusive LockFileEx lock on path, polling until ctx is done.
+func (e DefaultExecutor) Lock(ctx context.Context, path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var overlapped syscall.Overlapped
+		r, _, callErr := procLockFileEx.Call(
+			f.Fd(),
+			lockfileExclusiveLock|lockfileFailImmediately,
+			0,
+			1, 0,
+			uintptr(unsafe.Pointer(&overlapped)),
+		)
+		if r != 0 {
+			return f.Close, nil // closing releases the lock
+		}
+		if !errors.Is(callErr, errorLockViolation) {
+			f.Close()
+			return nil, fmt.Errorf("LockFileEx %q: %w", path, callErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for lock on %q: %w", path, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Sync is a no-op: windows has no cheap way to flush all volumes, and
+// writes reach the volume when files are closed.
+func (e DefaultExecutor) Sync(context.Context) error {
+	return nil
+}
+
 func (e DefaultExecutor) DiskUsage(_ context.Context, path string) (DiskUsage, error) {
 	pathPtr, err := syscall.UTF16PtrFromString(path)
 	if err != nil {
@@ -36,28 +174,5 @@ func (e DefaultExecutor) DiskUsage(_ context.Context, path string) (DiskUsage, e
 		return DiskUsage{}, fmt.Errorf("GetDiskFreeSpaceEx %q: %w", path, callErr)
 	}
 
-	return DiskUsage{
-		Total: humanizeBytes(totalBytes),
-		Used:  humanizeBytes(totalBytes - totalFree),
-	}, nil
-}
-
-func humanizeBytes(b uint64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%dB", b)
-	}
-
-	div, exp := uint64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-
-	val := float64(b) / float64(div)
-	suffix := []string{"K", "M", "G", "T", "P", "E"}[exp]
-	if val < 10 {
-		return fmt.Sprintf("%.1f%s", val, suffix)
-	}
-	return fmt.Sprintf("%.0f%s", val, suffix)
+	return newDiskUsage(totalBytes, totalFree, freeAvailable), nil
 }
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrColdCache is returned by HitPolicy.Check when mounted caches missed
+// more often than the policy allows, e.g. to trigger a warm job.
+var ErrColdCache = errors.New("cache is colder than expected")
+
+// HitPolicy decides how many cache misses a mount tolerates.
+type HitPolicy struct {
+	// FailOnMiss fails when any mounted path missed its cache.
+	FailOnMiss bool
+	// MinHitRate fails when fewer than this fraction of the mounted paths,
+	// from 0 to 1, hit their cache. Zero disables the check.
+	MinHitRate float64
+}
+
+// Validate checks that the hit rate is a fraction.
+func (p HitPolicy) Validate() error {
+	if p.MinHitRate < 0 || p.MinHitRate > 1 {
+		return fmt.Errorf("minimum hit rate %v is not between 0 and 1", p.MinHitRate)
+	}
+	return nil
+}
+
+// Check fails with ErrColdCache when the mounts of resp violate the policy.
+// Mounts without any paths never fail.
+func (p HitPolicy) Check(resp MountResponse) error {
+	hits, total := resp.Hits()
+	if total == 0 {
+		return nil
+	}
+
+	if p.FailOnMiss && hits < total {
+		var missed []string
+		for _, mount := range resp.Output.Mounts {
+			if !mount.CacheHit {
+				missed = append(missed, mount.MountPath)
+			}
+		}
+		return fmt.Errorf("%w: %d of %d path(s) missed: %s", ErrColdCache, total-hits, total, strings.Join(missed, ", "))
+	}
+	if rate := float64(hits) / float64(total); rate < p.MinHitRate {
+		return fmt.Errorf("%w: hit rate %.2f is below %.2f (%d/%d)", ErrColdCache, rate, p.MinHitRate, hits, total)
+	}
+	return nil
+}
+
+// Hits returns how many mounted paths hit their cache, out of all mounted
+// paths.
+func (r MountResponse) Hits() (hits, total int) {
+	for _, mount := range r.Output.Mounts {
+		if mount.CacheHit {
+			hits++
+		}
+	}
+	return hits, len(r.Output.Mounts)
+}
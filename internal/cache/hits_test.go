@@ -0,0 +1,47 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestHitPolicy_Check(t *testing.T) {
+	resp := func(hits ...bool) cache.MountResponse {
+		var r cache.MountResponse
+		for i, hit := range hits {
+			r.Output.Mounts = append(r.Output.Mounts, cache.MountResult{MountPath: string(rune('a' + i)), CacheHit: hit})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		policy cache.HitPolicy
+		resp   cache.MountResponse
+		err    string
+	}{
+		{name: "no policy", resp: resp(false, false)},
+		{name: "no mounts", policy: cache.HitPolicy{FailOnMiss: true, MinHitRate: 1}, resp: resp()},
+		{name: "all hit", policy: cache.HitPolicy{FailOnMiss: true}, resp: resp(true, true)},
+		{name: "miss", policy: cache.HitPolicy{FailOnMiss: true}, resp: resp(true, false, false), err: "cache is colder than expected: 2 of 3 path(s) missed: b, c"},
+		{name: "rate met", policy: cache.HitPolicy{MinHitRate: 0.5}, resp: resp(true, false)},
+		{name: "rate missed", policy: cache.HitPolicy{MinHitRate: 0.8}, resp: resp(true, true, true, false), err: "cache is colder than expected: hit rate 0.75 is below 0.80 (3/4)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.resp)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.err)
+			require.ErrorIs(t, err, cache.ErrColdCache)
+		})
+	}
+
+	require.Error(t, cache.HitPolicy{MinHitRate: 80}.Validate())
+	require.NoError(t, cache.HitPolicy{MinHitRate: 0.8}.Validate())
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// HookStage is when a hook runs.
+type HookStage string
+
+const (
+	HookPreMount  HookStage = "pre_mount"
+	HookPostMount HookStage = "post_mount"
+)
+
+// Hooks are shell commands run before and after mounting, e.g. to configure
+// a tool or warm its cache.
+type Hooks struct {
+	PreMount  []string `yaml:"pre_mount"`
+	PostMount []string `yaml:"post_mount"`
+}
+
+// PlannedHook is a hook that Apply runs.
+type PlannedHook struct {
+	// Mode is the mode the hook was declared for, or empty for hooks of
+	// every mount.
+	Mode    string    `json:"mode,omitzero"`
+	Stage   HookStage `json:"stage"`
+	Command string    `json:"command"`
+}
+
+// HookResult is the outcome of a hook, with its output.
+type HookResult struct {
+	Mode     string    `json:"mode,omitzero"`
+	Stage    HookStage `json:"stage"`
+	Command  string    `json:"command"`
+	ExitCode int       `json:"exit_code"`
+	Stdout   string    `json:"stdout,omitzero"`
+	Stderr   string    `json:"stderr,omitzero"`
+}
+
+// validateHooks checks that hooks are declared for available modes, or for
+// AllModes.
+func validateHooks(hooks map[string]Hooks, available mode.Modes) error {
+	names := available.Names()
+	for _, modeName := range slices.Sorted(maps.Keys(hooks)) {
+		if modeName != AllModes && !slices.Contains(names, modeName) {
+			return fmt.Errorf("hooks: unknown mode: %s", modeName)
+		}
+	}
+	return nil
+}
+
+// planHooks returns the hooks to run for the modes in the order they are
+// mounted. Hooks of every mount run before the pre-mount hooks of modes, and
+// after their post-mount hooks. Hooks of modes that are not mounted are
+// dropped.
+func planHooks(hooks map[string]Hooks, modeOrder []string) []PlannedHook {
+	var planned []PlannedHook
+	add := func(modeName string, stage HookStage, commands []string) {
+		for _, command := range commands {
+			planned = append(planned, PlannedHook{Mode: modeName, Stage: stage, Command: command})
+		}
+	}
+
+	add("", HookPreMount, hooks[AllModes].PreMount)
+	for _, modeName := range modeOrder {
+		add(modeName, HookPreMount, hooks[modeName].PreMount)
+	}
+	for _, modeName := range modeOrder {
+		add(modeName, HookPostMount, hooks[modeName].PostMount)
+	}
+	add("", HookPostMount, hooks[AllModes].PostMount)
+	return planned
+}
+
+// runHooks runs the hooks of a stage in the project directory, with the
+// environment the plan exports, and returns the errors of those that
+// failed. A failed hook fails the mount, but the remaining hooks and mounts
+// still run. Without DestructiveMode, hooks are only logged.
+func (m Mounter) runHooks(ctx context.Context, plan Plan, stage HookStage, result *MountResponse) []string {
+	var failed []string
+	var env []string
+	for _, k := range slices.Sorted(maps.Keys(plan.AddEnvs)) {
+		env = append(env, k+"="+plan.AddEnvs[k])
+	}
+
+	for _, hook := range plan.Hooks {
+		if hook.Stage != stage {
+			continue
+		}
+		if !m.DestructiveMode {
+			slog.Info("would run hook", slog.String("stage", string(stage)), slog.String("command", hook.Command))
+			continue
+		}
+
+		slog.Debug("running hook", slog.String("mode", hook.Mode), slog.String("stage", string(stage)), slog.String("command", hook.Command))
+		stdout, stderr, err := m.Exec.Shell(ctx, m.ProjectDir, hook.Command, env)
+		hr := HookResult{
+			Mode:    hook.Mode,
+			Stage:   hook.Stage,
+			Command: hook.Command,
+			Stdout:  string(stdout),
+			Stderr:  string(stderr),
+		}
+		if err != nil {
+			hr.ExitCode = -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				hr.ExitCode = exitErr.ExitCode()
+			}
+
+			message := fmt.Sprintf("%s hook %q: %v", stage, hook.Command, err)
+			if out := strings.TrimSpace(hr.Stderr); out != "" {
+				message += ": " + out
+			}
+			result.Output.Errors = append(result.Output.Errors, MountIssue{Mode: hook.Mode, Message: message})
+			failed = append(failed, message)
+		}
+		result.Output.Hooks = append(result.Output.Hooks, hr)
+	}
+	return failed
+}
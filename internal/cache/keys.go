@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// keyLength is the number of hex characters of a cache key.
+const keyLength = 16
+
+type KeysResponse struct {
+	Keys []ModeKey `json:"keys"`
+}
+
+// ModeKey is the cache key of a mode and the files it was computed from.
+// Modes without key files, or whose key files do not exist, have no key and
+// keep their caches unkeyed.
+type ModeKey struct {
+	Mode string `json:"mode"`
+	// WorkDir is the working directory the key was computed in, when not
+	// the current directory.
+	WorkDir string   `json:"work_dir,omitzero"`
+	Key     string   `json:"key,omitzero"`
+	Files   []string `json:"files,omitzero"`
+}
+
+// Keys computes the cache keys of the modes enabled by the request.
+func (m Mounter) Keys(ctx context.Context, req MountRequest) (KeysResponse, error) {
+	dirs, err := req.enabledModesByDir(ctx, m.Modes, m.modeExec(), m.observer())
+	if err != nil {
+		return KeysResponse{}, err
+	}
+
+	var result KeysResponse
+	for _, dir := range dirs {
+		plan, err := dir.Modes.Plan(ctx, mode.PlanRequest{CacheRoot: m.CacheRoot, Exec: m.modeExec(), WorkDir: dir.Dir})
+		if err != nil {
+			return KeysResponse{}, err
+		}
+
+		for _, modeName := range plan.Order {
+			key, files, err := ComputeKey(m.Exec, plan.Results[modeName].KeyFiles)
+			if err != nil {
+				return KeysResponse{}, fmt.Errorf("computing key of mode %q: %w", modeName, err)
+			}
+			result.Keys = append(result.Keys, ModeKey{Mode: modeName, WorkDir: dir.Dir, Key: key, Files: files})
+		}
+	}
+	return result, nil
+}
+
+// ComputeKey hashes the names and contents of the files matching patterns,
+// returning the key and the files that went into it. The key is empty when
+// no file matches.
+func ComputeKey(exec Executor, patterns []string) (string, []string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid key file pattern %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			if !slices.Contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return "", nil, nil
+	}
+	slices.Sort(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		data, err := exec.ReadFile(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("reading key file: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s\x00%x\n", filepath.ToSlash(file), sum)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:keyLength], files, nil
+}
+
+// cacheLocation returns where a cache is kept under root, beneath the mode's
+// key for keyed caches.
+func cacheLocation(root, modeName, key, subpath string) string {
+	if key == "" {
+		return filepath.Join(root, subpath)
+	}
+	return filepath.Join(root, modeName, key, subpath)
+}
+
+// latestKey returns the most recently created key of a mode under root that
+// holds a cache for subpath, other than the given key.
+func (m Mounter) latestKey(root, modeName, key, subpath string) (string, error) {
+	keys, err := keyDirs(filepath.Join(root, modeName))
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, k := range keys {
+		if k == key {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(root, modeName, k))
+		if err != nil {
+			return "", err
+		}
+		if _, err := m.Exec.Stat(cacheLocation(root, modeName, k, subpath)); err != nil {
+			continue
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = k, info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// keyDirs lists the key directories in a mode's keyed cache directory.
+func keyDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []string
+	for _, e := range entries {
+		if e.IsDir() && isKey(e.Name()) {
+			keys = append(keys, e.Name())
+		}
+	}
+	return keys, nil
+}
+
+func isKey(name string) bool {
+	if len(name) != keyLength {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
@@ -0,0 +1,135 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestComputeKey(t *testing.T) {
+	t.Chdir(t.TempDir())
+	exec := &cache.ExecutorMock{ReadFileFunc: os.ReadFile}
+
+	key, files, err := cache.ComputeKey(exec, []string{"go.sum"})
+	require.NoError(t, err)
+	require.Empty(t, key)
+	require.Empty(t, files)
+
+	require.NoError(t, os.WriteFile("go.sum", []byte("a v1"), 0o644))
+	require.NoError(t, os.WriteFile("requirements-dev.txt", []byte("b"), 0o644))
+
+	key, files, err = cache.ComputeKey(exec, []string{"requirements*.txt", "go.sum", "go.work.sum"})
+	require.NoError(t, err)
+	require.Len(t, key, 16)
+	require.Equal(t, []string{"go.sum", "requirements-dev.txt"}, files)
+
+	again, _, err := cache.ComputeKey(exec, []string{"go.sum", "requirements*.txt"})
+	require.NoError(t, err)
+	require.Equal(t, key, again, "key must not depend on pattern order")
+
+	require.NoError(t, os.WriteFile("go.sum", []byte("a v2"), 0o644))
+	changed, _, err := cache.ComputeKey(exec, []string{"go.sum", "requirements*.txt"})
+	require.NoError(t, err)
+	require.NotEqual(t, key, changed)
+
+	_, _, err = cache.ComputeKey(exec, []string{"[invalid"})
+	require.ErrorContains(t, err, "invalid key file pattern")
+}
+
+func TestMounter_Keyed(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("go.sum", []byte("a v1"), 0o644))
+
+	cacheRoot := t.TempDir()
+	mountPath := t.TempDir()
+
+	newMounter := func(destructive bool) (cache.Mounter, *cache.ExecutorMock) {
+		exec := &cache.ExecutorMock{
+			CopyDirFunc: func(ctx context.Context, from, to string) error {
+				return os.CopyFS(to, os.DirFS(from))
+			},
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+			MkdirAllFunc: os.MkdirAll,
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, os.MkdirAll(from, 0o755)
+			},
+			ReadFileFunc:  os.ReadFile,
+			RemoveAllFunc: os.RemoveAll,
+			RenameFunc:    os.Rename,
+			StatFunc:      os.Stat,
+			LockFunc:      noLock,
+			WriteFileFunc: os.WriteFile,
+		}
+		return cache.Mounter{
+			DestructiveMode: destructive,
+			CacheRoot:       cacheRoot,
+			Exec:            exec,
+			Keyed:           true,
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "go" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{mountPath}, KeyFiles: []string{"go.sum"}}, nil
+					},
+				},
+			},
+		}, exec
+	}
+
+	m, _ := newMounter(true)
+	first, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go"}})
+	require.NoError(t, err)
+
+	mount := first.Output.Mounts[0]
+	require.Len(t, mount.Key, 16)
+	require.Equal(t, filepath.Join(cacheRoot, "go", mount.Key, cache.RootSubpath(mountPath)), mount.CachePath)
+	require.False(t, mount.CacheHit)
+	require.Empty(t, mount.RestoredFrom)
+	require.NoError(t, os.WriteFile(filepath.Join(mount.CachePath, "module.zip"), []byte("zip"), 0o644))
+
+	t.Run("same key hits", func(t *testing.T) {
+		m, _ := newMounter(true)
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go"}})
+		require.NoError(t, err)
+		require.Equal(t, mount.CachePath, result.Output.Mounts[0].CachePath)
+		require.True(t, result.Output.Mounts[0].CacheHit)
+	})
+
+	require.NoError(t, os.WriteFile("go.sum", []byte("a v2"), 0o644))
+
+	t.Run("dry run does not restore", func(t *testing.T) {
+		m, exec := newMounter(false)
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go"}})
+		require.NoError(t, err)
+		require.Equal(t, mount.CachePath, result.Output.Mounts[0].RestoredFrom)
+		require.Empty(t, exec.CopyDirCalls())
+	})
+
+	t.Run("new key restores from the latest key", func(t *testing.T) {
+		m, _ := newMounter(true)
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go"}})
+		require.NoError(t, err)
+
+		restored := result.Output.Mounts[0]
+		require.NotEqual(t, mount.Key, restored.Key)
+		require.False(t, restored.CacheHit)
+		require.Equal(t, mount.CachePath, restored.RestoredFrom)
+		require.FileExists(t, filepath.Join(restored.CachePath, "module.zip"))
+	})
+
+	t.Run("clean deletes every key", func(t *testing.T) {
+		m, _ := newMounter(true)
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Modes: []string{"go"}})
+		require.NoError(t, err)
+		require.Len(t, result.Deleted, 2)
+		require.NoDirExists(t, filepath.Join(cacheRoot, "go", mount.Key))
+	})
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/namespacelabs/spacectl/internal/tracing"
+)
+
+// rootLockPath is held by jobs that change the cache root, so that jobs
+// sharing a volume do not mount, save or delete caches at the same time.
+var rootLockPath = filepath.Join(".ns", "cache-root.lock")
+
+// ErrLocked is returned when another job holds the cache root lock for
+// longer than Mounter.LockTimeout.
+var ErrLocked = errors.New("cache root is locked by another job")
+
+// acquireRootLock takes the cache root lock when LockRoot is set, waiting
+// up to LockTimeout for other jobs to release it. Dry runs change nothing
+// and do not lock.
+func (m Mounter) acquireRootLock(ctx context.Context) (func() error, error) {
+	if !m.LockRoot || !m.DestructiveMode {
+		return func() error { return nil }, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "lock")
+	defer span.End(nil)
+
+	path := filepath.Join(m.CacheRoot, rootLockPath)
+	if err := m.Exec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache root lock dir: %w", err)
+	}
+
+	// Try once without waiting, so that only contended locks are logged.
+	tryCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if unlock, err := m.Exec.Lock(tryCtx, path); err == nil {
+		return unlock, nil
+	}
+
+	slog.Info("Waiting for another job to release the cache root lock", "path", path, "timeout", m.LockTimeout)
+	waitCtx := ctx
+	if m.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, m.LockTimeout)
+		defer cancel()
+	}
+
+	unlock, err := m.Exec.Lock(waitCtx, path)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: waited %s for %q", ErrLocked, m.LockTimeout, path)
+		}
+		return nil, fmt.Errorf("locking cache root: %w", err)
+	}
+	return unlock, nil
+}
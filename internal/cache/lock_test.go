@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_RootLock(t *testing.T) {
+	// setup returns a mounter whose cache root is locked by another job
+	// until the returned release func is called.
+	setup := func(t *testing.T) (cache.Mounter, func() error) {
+		cacheRoot := t.TempDir()
+		lockPath := filepath.Join(cacheRoot, ".ns", "cache-root.lock")
+		require.NoError(t, os.MkdirAll(filepath.Dir(lockPath), 0o755))
+
+		release, err := cache.DefaultExecutor{}.Lock(t.Context(), lockPath)
+		require.NoError(t, err)
+		t.Cleanup(func() { release() })
+
+		return cache.Mounter{
+			DestructiveMode: true,
+			CacheRoot:       cacheRoot,
+			Exec:            cache.DefaultExecutor{},
+			LockRoot:        true,
+			LockTimeout:     100 * time.Millisecond,
+		}, release
+	}
+
+	t.Run("times out while another job holds the lock", func(t *testing.T) {
+		m, _ := setup(t)
+
+		_, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: time.Hour}, time.Now())
+		require.ErrorIs(t, err, cache.ErrLocked)
+	})
+
+	t.Run("proceeds once the lock is released", func(t *testing.T) {
+		m, release := setup(t)
+		m.LockTimeout = 0
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			release()
+		}()
+
+		_, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: time.Hour}, time.Now())
+		require.NoError(t, err)
+	})
+
+	t.Run("does not lock without LockRoot", func(t *testing.T) {
+		m, _ := setup(t)
+		m.LockRoot = false
+
+		_, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: time.Hour}, time.Now())
+		require.NoError(t, err)
+	})
+
+	t.Run("dry runs do not lock", func(t *testing.T) {
+		m, _ := setup(t)
+		m.DestructiveMode = false
+
+		_, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: time.Hour}, time.Now())
+		require.NoError(t, err)
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		m, _ := setup(t)
+		m.LockTimeout = 0
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := m.Prune(ctx, cache.PruneRequest{MaxAge: time.Hour}, time.Now())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotErrorIs(t, err, cache.ErrLocked)
+	})
+}
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/tracing"
+)
+
+// MetadataPath is where the cache metadata lives, relative to the cache root.
+var MetadataPath = filepath.Join(".ns", "cache-metadata.json")
+
+// metadataLockPath guards updates of the metadata, so that concurrent
+// invocations against the same cache root do not drop each other's changes.
+var metadataLockPath = filepath.Join(".ns", "cache-metadata.lock")
+
+// lockPollInterval is how often a held lock is retried.
+const lockPollInterval = 50 * time.Millisecond
+
+// metadataVersion 2 added mount times and durations, and the tool version.
+// Version 1 files are read as is, as the fields they lack are optional.
+const metadataVersion = 2
+
+// CacheMetadata records which paths have been mounted from a cache root, so
+// that later commands can report on them.
+type CacheMetadata struct {
+	UpdatedAt string `json:"updatedAt"`
+	Version   int    `json:"version"`
+	// ToolVersion is the version of spacectl that last wrote the metadata.
+	ToolVersion string                        `json:"toolVersion,omitzero"`
+	UserRequest map[string]CacheMetadataEntry `json:"userRequest"`
+}
+
+// CacheMetadataEntry describes a single cache path, keyed by its mount path.
+type CacheMetadataEntry struct {
+	CacheFramework *string  `json:"cacheFramework"`
+	MountTarget    []string `json:"mountTarget"`
+	Source         string   `json:"source"`
+	CacheHit       bool     `json:"cacheHit"`
+	// Exclude lists glob patterns that `cache save` drops from the cache.
+	Exclude []string `json:"exclude,omitzero"`
+	// Strategy is how the cache was mounted. Copies are saved back to
+	// the source by `cache save`.
+	Strategy MountStrategy `json:"strategy,omitzero"`
+	// MountedAt and MountDurationMs describe the latest mount.
+	MountedAt       string `json:"mountedAt,omitzero"`
+	MountDurationMs int64  `json:"mountDurationMs,omitzero"`
+	// Stats are recorded by `cache save` at the end of a job, and kept
+	// across mounts.
+	Stats CacheEntryStats `json:"stats,omitzero"`
+	// Compressed is set while the cache is compressed, as it was rarely
+	// used.
+	Compressed *CompressedCache `json:"compressed,omitzero"`
+}
+
+type CacheEntryStats struct {
+	SavedAt string `json:"savedAt"`
+	Bytes   int64  `json:"bytes"`
+	// GrowthBytes is how much the cache grew since the save before, which
+	// estimates how much it grows during a job.
+	GrowthBytes int64 `json:"growthBytes,omitzero"`
+	Files       int   `json:"files"`
+	Hits        int   `json:"hits"`
+	Misses      int   `json:"misses"`
+}
+
+// ReadMetadata reads the metadata file from cacheRoot, migrated to the
+// current version. A missing file yields empty metadata.
+func ReadMetadata(exec Executor, cacheRoot string) (CacheMetadata, error) {
+	metadata, _, err := readMetadata(exec, cacheRoot)
+	return metadata, err
+}
+
+// readMetadata also returns the version of the file before migration, which
+// is zero when there is no file.
+func readMetadata(exec Executor, cacheRoot string) (CacheMetadata, int, error) {
+	metadata := CacheMetadata{
+		Version:     metadataVersion,
+		UserRequest: map[string]CacheMetadataEntry{},
+	}
+
+	data, err := exec.ReadFile(filepath.Join(cacheRoot, MetadataPath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return metadata, 0, nil
+		}
+		return CacheMetadata{}, 0, fmt.Errorf("reading cache metadata: %w", err)
+	}
+
+	metadata.Version = 0
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return CacheMetadata{}, 0, fmt.Errorf("parsing cache metadata: %w", err)
+	}
+	if metadata.UserRequest == nil {
+		metadata.UserRequest = map[string]CacheMetadataEntry{}
+	}
+
+	// Files written before versioning are version 1.
+	if metadata.Version == 0 {
+		metadata.Version = 1
+	}
+	sourceVersion := metadata.Version
+	if err := migrateMetadata(&metadata); err != nil {
+		return CacheMetadata{}, 0, err
+	}
+	return metadata, sourceVersion, nil
+}
+
+// migrateMetadata upgrades metadata written by older versions of spacectl.
+// Metadata from newer versions is refused, so that it is not overwritten
+// with fields dropped.
+func migrateMetadata(metadata *CacheMetadata) error {
+	if metadata.Version > metadataVersion {
+		return fmt.Errorf("cache metadata version %d is newer than supported version %d, upgrade spacectl", metadata.Version, metadataVersion)
+	}
+
+	// Version 2 only added optional fields.
+	metadata.Version = metadataVersion
+	return nil
+}
+
+// MetadataResponse is the metadata file of a cache root.
+type MetadataResponse struct {
+	Path string `json:"path"`
+	// SourceVersion is the version of the file before it was migrated, or
+	// zero when there is no file.
+	SourceVersion int           `json:"source_version"`
+	Metadata      CacheMetadata `json:"metadata"`
+}
+
+// Metadata reads the metadata file of the cache root, for inspection.
+func (m Mounter) Metadata() (MetadataResponse, error) {
+	metadata, sourceVersion, err := readMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return MetadataResponse{}, err
+	}
+
+	return MetadataResponse{
+		Path:          filepath.Join(m.CacheRoot, MetadataPath),
+		SourceVersion: sourceVersion,
+		Metadata:      metadata,
+	}, nil
+}
+
+// updateMetadata merges mounts into the metadata file, so that paths mounted
+// by earlier invocations against the same cache root are kept.
+func (m Mounter) updateMetadata(ctx context.Context, mounts []MountResult) error {
+	if len(mounts) == 0 {
+		return nil
+	}
+
+	mountedAt := time.Now().UTC().Format(time.RFC3339)
+	return m.modifyMetadata(ctx, func(metadata *CacheMetadata) error {
+		for _, mount := range mounts {
+			var framework *string
+			if mount.Mode != "" {
+				framework = &mount.Mode
+			}
+			metadata.UserRequest[mount.MountPath] = CacheMetadataEntry{
+				CacheFramework:  framework,
+				MountTarget:     []string{mount.MountPath},
+				Source:          mount.CachePath,
+				CacheHit:        mount.CacheHit,
+				Exclude:         mount.Exclude,
+				Strategy:        mount.Strategy,
+				MountedAt:       mountedAt,
+				MountDurationMs: mount.DurationMs,
+				Stats:           metadata.UserRequest[mount.MountPath].Stats,
+			}
+		}
+		return nil
+	})
+}
+
+// forgetMetadata drops the entries of the given cache paths from the
+// metadata file, along with their manifests. Entries are forgotten by cache
+// path, as other caches of the same mount path, e.g. of another key, may be
+// the one recorded.
+func (m Mounter) forgetMetadata(ctx context.Context, cachePaths []string) error {
+	if len(cachePaths) == 0 {
+		return nil
+	}
+
+	return m.modifyMetadata(ctx, func(metadata *CacheMetadata) error {
+		for _, source := range cachePaths {
+			if err := m.Exec.RemoveAll(m.manifestPath(source)); err != nil {
+				return fmt.Errorf("removing manifest for %q: %w", source, err)
+			}
+			for mountPath, entry := range metadata.UserRequest {
+				if entry.Source == source {
+					delete(metadata.UserRequest, mountPath)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// modifyMetadata applies fn to the metadata file while holding its lock, so
+// that the read, change and write are not interleaved with another update.
+func (m Mounter) modifyMetadata(ctx context.Context, fn func(*CacheMetadata) error) (err error) {
+	ctx, span := tracing.Start(ctx, "metadata.write")
+	defer func() { span.End(err) }()
+
+	lockPath := filepath.Join(m.CacheRoot, metadataLockPath)
+	if err := m.Exec.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+		return fmt.Errorf("creating cache metadata dir: %w", err)
+	}
+	unlock, err := m.Exec.Lock(ctx, lockPath)
+	if err != nil {
+		return fmt.Errorf("locking cache metadata: %w", err)
+	}
+	defer unlock()
+
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return err
+	}
+	if err := fn(&metadata); err != nil {
+		return err
+	}
+	return m.writeMetadata(metadata)
+}
+
+// writeMetadata replaces the metadata file. Executor.WriteFile replaces files
+// atomically, so readers never see a partial file.
+func (m Mounter) writeMetadata(metadata CacheMetadata) error {
+	metadata.Version = metadataVersion
+	metadata.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if m.ToolVersion != "" {
+		metadata.ToolVersion = m.ToolVersion
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cache metadata: %w", err)
+	}
+
+	path := filepath.Join(m.CacheRoot, MetadataPath)
+	if err := m.Exec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cache metadata dir: %w", err)
+	}
+	if err := m.Exec.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing cache metadata: %w", err)
+	}
+
+	return nil
+}
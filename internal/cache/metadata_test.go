@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Metadata(t *testing.T) {
+	setup := func(t *testing.T, data string) cache.Mounter {
+		cacheRoot := t.TempDir()
+		if data != "" {
+			path := filepath.Join(cacheRoot, cache.MetadataPath)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+		}
+		return cache.Mounter{CacheRoot: cacheRoot, Exec: cache.DefaultExecutor{}}
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		m := setup(t, "")
+		result, err := m.Metadata()
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(m.CacheRoot, cache.MetadataPath), result.Path)
+		require.Zero(t, result.SourceVersion)
+		require.Empty(t, result.Metadata.UserRequest)
+	})
+
+	t.Run("migrates version 1", func(t *testing.T) {
+		m := setup(t, `{"version":1,"updatedAt":"2025-01-01T00:00:00Z","userRequest":{"/work/a":{"source":"/cache/a","cacheHit":true}}}`)
+		result, err := m.Metadata()
+		require.NoError(t, err)
+		require.Equal(t, 1, result.SourceVersion)
+		require.Equal(t, 2, result.Metadata.Version)
+		require.Equal(t, "/cache/a", result.Metadata.UserRequest["/work/a"].Source)
+		require.True(t, result.Metadata.UserRequest["/work/a"].CacheHit)
+	})
+
+	t.Run("unversioned file is version 1", func(t *testing.T) {
+		m := setup(t, `{"userRequest":{}}`)
+		result, err := m.Metadata()
+		require.NoError(t, err)
+		require.Equal(t, 1, result.SourceVersion)
+		require.Equal(t, 2, result.Metadata.Version)
+	})
+
+	t.Run("refuses newer versions", func(t *testing.T) {
+		m := setup(t, `{"version":3,"userRequest":{}}`)
+		_, err := m.Metadata()
+		require.ErrorContains(t, err, "cache metadata version 3 is newer than supported version 2")
+	})
+}
@@ -0,0 +1,115 @@
+package mode
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CatalogEntry documents a mode: what detects it and what it mounts by
+// default.
+type CatalogEntry struct {
+	Name string `json:"name"`
+	// Binaries are the binaries that detection looks up, and Files the
+	// project files it checks, in order.
+	Binaries []string `json:"binaries,omitzero"`
+	Files    []string `json:"files,omitzero"`
+	// Paths, CacheDirs and Env are planned statically in an empty
+	// environment, so they are the defaults of the tools. CacheDirs and the
+	// paths in Env are relative to $NSC_CACHE_PATH.
+	Paths     []string          `json:"paths,omitzero"`
+	CacheDirs []string          `json:"cache_dirs,omitzero"`
+	Env       map[string]string `json:"env,omitzero"`
+	// PlanError is set when the mode cannot be planned statically, e.g.
+	// because it needs the output of its tools.
+	PlanError string `json:"plan_error,omitzero"`
+	Trims     bool   `json:"trims,omitzero"`
+	Warms     bool   `json:"warms,omitzero"`
+}
+
+// catalogCacheRoot stands for the cache root in the catalog.
+const catalogCacheRoot = "$NSC_CACHE_PATH"
+
+// Catalog documents modes, e.g. for reference docs. Plugins are left out,
+// as what they check and plan is up to them.
+func (modes Modes) Catalog(ctx context.Context) []CatalogEntry {
+	// Clear the environment, so that the catalog does not depend on the
+	// machine that generates it.
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		env[key] = ""
+	}
+
+	var entries []CatalogEntry
+	for _, m := range modes {
+		if _, plugin := m.(PluginProvider); plugin {
+			continue
+		}
+
+		entry := CatalogEntry{Name: m.Name()}
+		_, entry.Trims = m.(Trimmer)
+		_, entry.Warms = m.(Warmer)
+
+		// Every binary is found and no file exists, so detection checks
+		// all the binaries and files that could detect the mode.
+		res, err := m.Detect(ctx, DetectRequest{Exec: catalogExecutor{}})
+		if err == nil {
+			for _, check := range res.Checks {
+				switch {
+				case check.Kind == CheckBinary && !slices.Contains(entry.Binaries, check.Name):
+					entry.Binaries = append(entry.Binaries, check.Name)
+				case check.Kind == CheckFile && !slices.Contains(entry.Files, check.Name):
+					entry.Files = append(entry.Files, check.Name)
+				}
+			}
+		}
+
+		plan, err := Modes{m}.Plan(ctx, PlanRequest{
+			CacheRoot: catalogCacheRoot,
+			Exec:      catalogExecutor{},
+			Env:       env,
+			Static:    true,
+		})
+		if err != nil {
+			entry.PlanError = err.Error()
+		} else if result, ok := plan.Results[m.Name()]; ok {
+			for _, path := range result.MountPaths {
+				entry.Paths = append(entry.Paths, filepath.Clean(path))
+			}
+			entry.CacheDirs = result.CacheDirs
+			if len(result.AddEnvs) > 0 {
+				entry.Env = maps.Clone(result.AddEnvs)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// catalogExecutor finds every binary and no file, and runs nothing.
+type catalogExecutor struct{}
+
+func (catalogExecutor) LookPath(file string) (string, error) { return file, nil }
+
+func (catalogExecutor) Run(_ context.Context, spec CommandSpec) ([]byte, error) {
+	return nil, fmt.Errorf("%s: %w", spec, ErrOffline)
+}
+
+func (catalogExecutor) Stat(name string) (os.FileInfo, error) {
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (catalogExecutor) ReadDir(name string) ([]os.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+}
+
+func (catalogExecutor) ReadFile(name string) ([]byte, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
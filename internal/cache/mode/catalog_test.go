@@ -0,0 +1,38 @@
+package mode_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestModes_Catalog(t *testing.T) {
+	t.Setenv("GOMODCACHE", "/elsewhere/mod")
+
+	failing := &mode.ModeProviderMock{
+		NameFunc:   func() string { return "failing" },
+		DetectFunc: func(context.Context, mode.DetectRequest) (mode.DetectResult, error) { return mode.DetectResult{}, nil },
+		PlanFunc: func(context.Context, mode.PlanRequest) (mode.PlanResult, error) {
+			return mode.PlanResult{}, errors.New("needs its tool")
+		},
+	}
+	catalog := mode.Modes{mode.GoProvider{}, failing, mode.PluginProvider{}}.Catalog(t.Context())
+	require.Len(t, catalog, 2)
+
+	goEntry := catalog[0]
+	require.Equal(t, "go", goEntry.Name)
+	require.Equal(t, []string{"go"}, goEntry.Binaries)
+	require.Equal(t, []string{"go.mod", "go.work"}, goEntry.Files)
+	// The environment of the machine is ignored.
+	require.NotEmpty(t, goEntry.Paths)
+	require.NotContains(t, goEntry.Paths, "/elsewhere/mod")
+	require.True(t, goEntry.Trims)
+	require.True(t, goEntry.Warms)
+
+	require.Equal(t, "failing", catalog[1].Name)
+	require.Contains(t, catalog[1].PlanError, "needs its tool")
+}
@@ -0,0 +1,179 @@
+package mode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// customModeNameRegex restricts custom mode names to what can be passed to
+// --mode and --detect unambiguously.
+var customModeNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// DefaultCustomModesFile returns the path of the custom modes file that is
+// loaded when none is given explicitly.
+func DefaultCustomModesFile() string {
+	configDir := ConfigDir()
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Join(configDir, "modes.yaml")
+}
+
+// CustomModesFile is the format of a custom modes definition file:
+//
+//	modes:
+//	  - name: bazel
+//	    detect:
+//	      binaries: [bazel]
+//	      files: [MODULE.bazel, WORKSPACE]
+//	    mount_paths: [~/.cache/bazel]
+//	    key_files: [MODULE.bazel.lock]
+//	    envs:
+//	      BAZEL_OPTS: --disk_cache=~/.cache/bazel-disk
+//	    remove_paths: [./bazel-out]
+type CustomModesFile struct {
+	Modes []CustomModeDefinition `yaml:"modes"`
+}
+
+type CustomModeDefinition struct {
+	Name        string            `yaml:"name"`
+	Detect      CustomModeDetect  `yaml:"detect"`
+	MountPaths  []string          `yaml:"mount_paths"`
+	Envs        map[string]string `yaml:"envs"`
+	RemovePaths []string          `yaml:"remove_paths"`
+	KeyFiles    []string          `yaml:"key_files"`
+}
+
+// CustomModeDetect describes when a custom mode is detected: all binaries
+// must be on PATH, and at least one of the files must exist. A mode without
+// any detection rules is never detected and must be enabled with --mode.
+type CustomModeDetect struct {
+	Binaries []string `yaml:"binaries"`
+	Files    []string `yaml:"files"`
+}
+
+// LoadCustomModes reads custom mode definitions from path. A missing file is
+// not an error when allowMissing is set, so the default location is optional.
+func LoadCustomModes(path string, allowMissing bool) (Modes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if allowMissing && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read custom modes file: %w", err)
+	}
+
+	modes, err := ParseCustomModes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return modes, nil
+}
+
+// ParseCustomModes parses and validates a custom modes definition file.
+func ParseCustomModes(data []byte) (Modes, error) {
+	var file CustomModesFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse custom modes: %w", err)
+	}
+
+	modes := make(Modes, 0, len(file.Modes))
+	seen := make(map[string]bool, len(file.Modes))
+	for i, def := range file.Modes {
+		if !customModeNameRegex.MatchString(def.Name) {
+			return nil, fmt.Errorf("custom mode %d: invalid name %q", i, def.Name)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("custom mode %q defined more than once", def.Name)
+		}
+		if len(def.MountPaths) == 0 && len(def.Envs) == 0 && len(def.RemovePaths) == 0 {
+			return nil, fmt.Errorf("custom mode %q: no mount_paths, envs or remove_paths", def.Name)
+		}
+
+		seen[def.Name] = true
+		modes = append(modes, CustomProvider{Definition: def})
+	}
+
+	return modes, nil
+}
+
+// WithCustomModes returns modes extended by custom modes. Unlike plugins,
+// custom modes are explicitly configured, so a name clash is an error.
+func (modes Modes) WithCustomModes(custom Modes) (Modes, error) {
+	names := modes.Names()
+	for _, m := range custom {
+		if slices.Contains(names, m.Name()) {
+			return nil, fmt.Errorf("custom mode %q conflicts with an existing mode", m.Name())
+		}
+	}
+	return append(slices.Clone(modes), custom...), nil
+}
+
+// CustomProvider is a ModeProvider defined in a custom modes file.
+type CustomProvider struct {
+	Definition CustomModeDefinition
+}
+
+func (p CustomProvider) Name() string {
+	return p.Definition.Name
+}
+
+func (p CustomProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	detect := p.Definition.Detect
+	if len(detect.Binaries) == 0 && len(detect.Files) == 0 {
+		result := d.result(false)
+		result.Details = "the mode has no detection rules, enable it with --mode"
+		return result, nil
+	}
+
+	for _, bin := range detect.Binaries {
+		if _, err := req.Exec.LookPath(bin); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return d.result(false), nil
+			}
+			return DetectResult{}, fmt.Errorf("lookpath %s: %w", bin, err)
+		}
+	}
+
+	if len(detect.Files) == 0 {
+		return d.result(true), nil
+	}
+
+	for _, file := range detect.Files {
+		if _, err := req.Exec.Stat(file); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", file, err)
+		}
+	}
+
+	return d.result(false), nil
+}
+
+func (p CustomProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	var envs map[string]string
+	if len(p.Definition.Envs) > 0 {
+		envs = maps.Clone(p.Definition.Envs)
+	}
+
+	return PlanResult{
+		AddEnvs:     envs,
+		MountPaths:  slices.Clone(p.Definition.MountPaths),
+		RemovePaths: slices.Clone(p.Definition.RemovePaths),
+		KeyFiles:    slices.Clone(p.Definition.KeyFiles),
+	}, nil
+}
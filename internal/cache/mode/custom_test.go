@@ -0,0 +1,233 @@
+package mode_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestParseCustomModes(t *testing.T) {
+	t.Run("parses mode definitions", func(t *testing.T) {
+		modes, err := mode.ParseCustomModes([]byte(`
+modes:
+  - name: bazel
+    detect:
+      binaries: [bazel]
+      files: [MODULE.bazel, WORKSPACE]
+    mount_paths: [~/.cache/bazel]
+    envs:
+      BAZEL_OPTS: --disk_cache=/cache/bazel-disk
+    remove_paths: [./bazel-out]
+  - name: scons
+    mount_paths: [./.sconf_temp]
+`))
+		require.NoError(t, err)
+		require.Equal(t, mode.Modes{
+			mode.CustomProvider{Definition: mode.CustomModeDefinition{
+				Name: "bazel",
+				Detect: mode.CustomModeDetect{
+					Binaries: []string{"bazel"},
+					Files:    []string{"MODULE.bazel", "WORKSPACE"},
+				},
+				MountPaths:  []string{"~/.cache/bazel"},
+				Envs:        map[string]string{"BAZEL_OPTS": "--disk_cache=/cache/bazel-disk"},
+				RemovePaths: []string{"./bazel-out"},
+			}},
+			mode.CustomProvider{Definition: mode.CustomModeDefinition{
+				Name:       "scons",
+				MountPaths: []string{"./.sconf_temp"},
+			}},
+		}, modes)
+	})
+
+	t.Run("empty file has no modes", func(t *testing.T) {
+		modes, err := mode.ParseCustomModes(nil)
+		require.NoError(t, err)
+		require.Empty(t, modes)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := mode.ParseCustomModes([]byte(`
+modes:
+  - name: bazel
+    mount_path: [~/.cache/bazel]
+`))
+		require.ErrorContains(t, err, "field mount_path not found")
+	})
+
+	t.Run("rejects invalid names", func(t *testing.T) {
+		_, err := mode.ParseCustomModes([]byte(`
+modes:
+  - name: Bazel Cache
+    mount_paths: [~/.cache/bazel]
+`))
+		require.ErrorContains(t, err, `invalid name "Bazel Cache"`)
+	})
+
+	t.Run("rejects duplicate names", func(t *testing.T) {
+		_, err := mode.ParseCustomModes([]byte(`
+modes:
+  - name: bazel
+    mount_paths: [~/.cache/bazel]
+  - name: bazel
+    mount_paths: [./bazel-out]
+`))
+		require.ErrorContains(t, err, `custom mode "bazel" defined more than once`)
+	})
+
+	t.Run("rejects modes without effect", func(t *testing.T) {
+		_, err := mode.ParseCustomModes([]byte(`
+modes:
+  - name: bazel
+    detect:
+      files: [WORKSPACE]
+`))
+		require.ErrorContains(t, err, "no mount_paths, envs or remove_paths")
+	})
+}
+
+func TestLoadCustomModes(t *testing.T) {
+	t.Run("missing file allowed", func(t *testing.T) {
+		modes, err := mode.LoadCustomModes(filepath.Join(t.TempDir(), "modes.yaml"), true)
+		require.NoError(t, err)
+		require.Empty(t, modes)
+	})
+
+	t.Run("missing file not allowed", func(t *testing.T) {
+		_, err := mode.LoadCustomModes(filepath.Join(t.TempDir(), "modes.yaml"), false)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("error includes file path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "modes.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("modes: [{name: x}]"), 0o644))
+
+		_, err := mode.LoadCustomModes(path, false)
+		require.ErrorContains(t, err, path)
+	})
+}
+
+func TestModes_WithCustomModes(t *testing.T) {
+	custom := mode.CustomProvider{Definition: mode.CustomModeDefinition{Name: "bazel"}}
+
+	t.Run("appends custom modes", func(t *testing.T) {
+		modes, err := mode.Modes{mode.AptProvider{}}.WithCustomModes(mode.Modes{custom})
+		require.NoError(t, err)
+		require.Equal(t, []string{"apt", "bazel"}, modes.Names())
+	})
+
+	t.Run("rejects conflicts with existing modes", func(t *testing.T) {
+		conflicting := mode.CustomProvider{Definition: mode.CustomModeDefinition{Name: "apt"}}
+		_, err := mode.Modes{mode.AptProvider{}}.WithCustomModes(mode.Modes{conflicting})
+		require.ErrorContains(t, err, `custom mode "apt" conflicts with an existing mode`)
+	})
+}
+
+func TestCustomProvider_Detect(t *testing.T) {
+	p := mode.CustomProvider{Definition: mode.CustomModeDefinition{
+		Name: "bazel",
+		Detect: mode.CustomModeDetect{
+			Binaries: []string{"bazel"},
+			Files:    []string{"MODULE.bazel", "WORKSPACE"},
+		},
+	}}
+
+	t.Run("detected when binary and any file exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/bazel", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "WORKSPACE" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/bazel", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("detected by binary alone", func(t *testing.T) {
+		p := mode.CustomProvider{Definition: mode.CustomModeDefinition{
+			Name:   "bazel",
+			Detect: mode.CustomModeDetect{Binaries: []string{"bazel"}},
+		}}
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/bazel", nil
+				},
+			},
+		}
+
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("never detected without rules", func(t *testing.T) {
+		p := mode.CustomProvider{Definition: mode.CustomModeDefinition{Name: "bazel"}}
+
+		detected, err := p.Detect(t.Context(), mode.DetectRequest{Exec: &mode.ExecutorMock{}})
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestCustomProvider_Plan(t *testing.T) {
+	p := mode.CustomProvider{Definition: mode.CustomModeDefinition{
+		Name:        "bazel",
+		MountPaths:  []string{"~/.cache/bazel"},
+		Envs:        map[string]string{"BAZEL_OPTS": "--disk_cache=/cache/bazel-disk"},
+		RemovePaths: []string{"./bazel-out"},
+	}}
+
+	result, err := p.Plan(t.Context(), mode.PlanRequest{Exec: &mode.ExecutorMock{}})
+	require.NoError(t, err)
+	require.Equal(t, mode.PlanResult{
+		AddEnvs:     map[string]string{"BAZEL_OPTS": "--disk_cache=/cache/bazel-disk"},
+		MountPaths:  []string{"~/.cache/bazel"},
+		RemovePaths: []string{"./bazel-out"},
+	}, result)
+}
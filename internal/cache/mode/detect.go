@@ -0,0 +1,240 @@
+package mode
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// DetectResult is the outcome of detecting a mode, along with what was
+// checked, so that users can tell why a mode was not detected.
+type DetectResult struct {
+	Detected bool `json:"detected"`
+	// Confidence tells how much the detection can be trusted.
+	Confidence Confidence `json:"confidence,omitzero"`
+	// Reason tells why the mode was not detected.
+	Reason DetectReason `json:"reason,omitzero"`
+	// Missing is the binary or file whose absence stopped detection.
+	Missing string `json:"missing,omitzero"`
+	// Details explains the outcome, when the provider knows more than its
+	// checks tell, e.g. a plugin's own explanation.
+	Details string `json:"details,omitzero"`
+	// Warnings are problems the provider ran into without failing, e.g. a
+	// project file that could not be parsed.
+	Warnings []string `json:"warnings,omitzero"`
+	// Checks lists the binaries looked up and the paths read, in order.
+	Checks []DetectCheck `json:"checks,omitzero"`
+	// DurationMs is how long detection took, in milliseconds. Modes.DetectAll
+	// fills it in.
+	DurationMs int64 `json:"duration_ms,omitzero"`
+}
+
+// DetectReason is why a mode was not detected.
+type DetectReason string
+
+const (
+	// ReasonMissingBinary means a binary the mode needs is not on PATH.
+	ReasonMissingBinary DetectReason = "missing_binary"
+	// ReasonMissingFile means no project file of the mode was found, e.g. a
+	// lockfile.
+	ReasonMissingFile DetectReason = "missing_file"
+	// ReasonCommandFailed means a command run to inspect the project failed.
+	ReasonCommandFailed DetectReason = "command_failed"
+	// ReasonNotUsed means the project files exist, but do not use the mode,
+	// e.g. a package.json without a packageManager field.
+	ReasonNotUsed DetectReason = "not_used"
+)
+
+// Confidence is how much a detection can be trusted.
+type Confidence string
+
+const (
+	// ConfidenceHigh means files of the project use the mode, e.g. a
+	// lockfile was found.
+	ConfidenceHigh Confidence = "high"
+	// ConfidenceLow means only the tools of the mode were found on the
+	// machine, e.g. a system package manager, which the project may not use.
+	ConfidenceLow Confidence = "low"
+)
+
+// DetectCheck is a binary looked up, a path read or a command run while
+// detecting a mode.
+type DetectCheck struct {
+	Kind CheckKind `json:"kind"`
+	// Name is the binary, path or command as the provider gave it.
+	Name string `json:"name"`
+	// Path is where the binary was found, or the absolute path checked.
+	Path  string `json:"path,omitzero"`
+	Found bool   `json:"found"`
+}
+
+func (c DetectCheck) String() string {
+	switch {
+	case !c.Found && c.Kind == CheckCommand:
+		return fmt.Sprintf("%s %s: failed", c.Kind, c.Name)
+	case !c.Found:
+		return fmt.Sprintf("%s %s: not found", c.Kind, c.Name)
+	case c.Path != "":
+		return fmt.Sprintf("%s %s: %s", c.Kind, c.Name, c.Path)
+	default:
+		return fmt.Sprintf("%s %s: found", c.Kind, c.Name)
+	}
+}
+
+// CheckKind is what a DetectCheck looked for.
+type CheckKind string
+
+const (
+	CheckBinary  CheckKind = "binary"
+	CheckFile    CheckKind = "file"
+	CheckCommand CheckKind = "command"
+)
+
+// detection records the checks that a provider makes through its executor
+// while detecting or planning, to explain the result.
+type detection struct {
+	Executor
+
+	dir      string // working directory that relative paths are checked in
+	mu       sync.Mutex
+	checks   []DetectCheck
+	warnings []string
+}
+
+// detecting records the checks made through req from now on.
+func detecting(req *DetectRequest) *detection {
+	return recording(&req.Exec, req.WorkDir)
+}
+
+// recording records the checks made through exec, which works in dir, from
+// now on.
+func recording(exec *Executor, dir string) *detection {
+	d := &detection{Executor: *exec, dir: dir}
+	*exec = d
+	return d
+}
+
+// warn records a problem that detection worked around.
+func (d *detection) warn(format string, args ...any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.warnings = append(d.warnings, fmt.Sprintf(format, args...))
+}
+
+func (d *detection) recorded() []DetectCheck {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.checks)
+}
+
+func (d *detection) record(kind CheckKind, name, path string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checks = append(d.checks, DetectCheck{Kind: kind, Name: name, Path: path, Found: err == nil})
+}
+
+func (d *detection) LookPath(file string) (string, error) {
+	path, err := d.Executor.LookPath(file)
+	d.record(CheckBinary, file, path, err)
+	return path, err
+}
+
+func (d *detection) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	out, err := d.Executor.Run(ctx, spec)
+	d.record(CheckCommand, spec.String(), "", err)
+	return out, err
+}
+
+func (d *detection) Stat(name string) (os.FileInfo, error) {
+	info, err := d.Executor.Stat(name)
+	d.record(CheckFile, name, d.absPath(name), err)
+	return info, err
+}
+
+func (d *detection) ReadDir(name string) ([]os.DirEntry, error) {
+	entries, err := d.Executor.ReadDir(name)
+	d.record(CheckFile, name, d.absPath(name), err)
+	return entries, err
+}
+
+func (d *detection) ReadFile(name string) ([]byte, error) {
+	data, err := d.Executor.ReadFile(name)
+	d.record(CheckFile, name, d.absPath(name), err)
+	return data, err
+}
+
+// result explains the outcome of detection from the checks made. The last
+// failed check is what stopped detection; when every check passed, the
+// files exist but do not use the mode.
+func (d *detection) result(detected bool) DetectResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res := DetectResult{Detected: detected, Warnings: d.warnings, Checks: d.checks}
+	if detected {
+		// Providers that only look up binaries detect the tools of the
+		// machine rather than of the project.
+		res.Confidence = ConfidenceLow
+		for _, check := range d.checks {
+			if check.Kind == CheckFile && check.Found {
+				res.Confidence = ConfidenceHigh
+				break
+			}
+		}
+		return res
+	}
+
+	res.Reason = ReasonNotUsed
+	for i := len(d.checks) - 1; i >= 0; i-- {
+		check := d.checks[i]
+		if check.Found {
+			continue
+		}
+		switch check.Kind {
+		case CheckBinary:
+			res.Reason = ReasonMissingBinary
+		case CheckFile:
+			res.Reason = ReasonMissingFile
+		case CheckCommand:
+			res.Reason = ReasonCommandFailed
+		}
+		res.Missing = check.Name
+		break
+	}
+	return res
+}
+
+func (r DetectResult) log(mode string) {
+	if r.Detected {
+		logChecks(mode, "detected", r.Checks, slog.String("confidence", string(r.Confidence)))
+	} else {
+		logChecks(mode, "not detected", r.Checks, slog.String("reason", string(r.Reason)), slog.String("missing", r.Missing))
+	}
+	for _, warning := range r.Warnings {
+		slog.Debug("detection warning", slog.String("mode", mode), slog.String("warning", warning))
+	}
+}
+
+func logChecks(mode, msg string, checks []DetectCheck, attrs ...slog.Attr) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	logged := make([]string, 0, len(checks))
+	for _, check := range checks {
+		logged = append(logged, check.String())
+	}
+	attrs = append(attrs, slog.String("mode", mode), slog.Any("checks", logged))
+	slog.LogAttrs(context.Background(), slog.LevelDebug, msg, attrs...)
+}
+
+func (d *detection) absPath(name string) string {
+	path, err := filepath.Abs(inWorkDir(d.dir, name))
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,114 @@
+package mode_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestDetectResult(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	executor := func(binaries map[string]string, files map[string]string) *mode.ExecutorMock {
+		return &mode.ExecutorMock{
+			LookPathFunc: func(file string) (string, error) {
+				if path, ok := binaries[file]; ok {
+					return path, nil
+				}
+				return "", exec.ErrNotFound
+			},
+			StatFunc: func(name string) (os.FileInfo, error) {
+				if _, ok := files[name]; ok {
+					return nil, nil
+				}
+				return nil, os.ErrNotExist
+			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				if data, ok := files[name]; ok {
+					return []byte(data), nil
+				}
+				return nil, os.ErrNotExist
+			},
+		}
+	}
+
+	t.Run("missing binary", func(t *testing.T) {
+		result, err := mode.BunProvider{}.Detect(t.Context(), mode.DetectRequest{Exec: executor(nil, nil)})
+		require.NoError(t, err)
+		require.Equal(t, mode.DetectResult{
+			Reason:  mode.ReasonMissingBinary,
+			Missing: "bun",
+			Checks:  []mode.DetectCheck{{Kind: mode.CheckBinary, Name: "bun"}},
+		}, result)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		result, err := mode.BunProvider{}.Detect(t.Context(), mode.DetectRequest{Exec: executor(map[string]string{"bun": "/usr/bin/bun"}, nil)})
+		require.NoError(t, err)
+		require.Equal(t, mode.DetectResult{
+			Reason:  mode.ReasonMissingFile,
+			Missing: "bun.lock",
+			Checks: []mode.DetectCheck{
+				{Kind: mode.CheckBinary, Name: "bun", Path: "/usr/bin/bun", Found: true},
+				{Kind: mode.CheckFile, Name: "bun.lock", Path: filepath.Join(wd, "bun.lock")},
+			},
+		}, result)
+	})
+
+	t.Run("detected", func(t *testing.T) {
+		result, err := mode.BunProvider{}.Detect(t.Context(), mode.DetectRequest{Exec: executor(map[string]string{"bun": "/usr/bin/bun"}, map[string]string{"bun.lock": ""})})
+		require.NoError(t, err)
+		require.True(t, result.Detected)
+		require.Equal(t, mode.ConfidenceHigh, result.Confidence)
+		require.Empty(t, result.Reason)
+		require.Len(t, result.Checks, 2)
+	})
+
+	t.Run("files do not use the mode", func(t *testing.T) {
+		result, err := mode.CorepackProvider{}.Detect(t.Context(), mode.DetectRequest{Exec: executor(nil, map[string]string{"package.json": `{"name": "app"}`})})
+		require.NoError(t, err)
+		require.False(t, result.Detected)
+		require.Equal(t, mode.ReasonNotUsed, result.Reason)
+		require.Empty(t, result.Missing)
+	})
+
+	t.Run("unparsable files warn", func(t *testing.T) {
+		result, err := mode.CorepackProvider{}.Detect(t.Context(), mode.DetectRequest{Exec: executor(nil, map[string]string{"package.json": `{`})})
+		require.NoError(t, err)
+		require.False(t, result.Detected)
+		require.Len(t, result.Warnings, 1)
+		require.Contains(t, result.Warnings[0], "package.json could not be parsed")
+	})
+
+	t.Run("all modes", func(t *testing.T) {
+		modes, err := mode.DefaultModes().Filter([]string{"bun", "apt"})
+		require.NoError(t, err)
+		results, err := modes.DetectAll(t.Context(), mode.DetectRequest{Exec: executor(map[string]string{"apt-config": "/usr/bin/apt-config"}, nil)})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, mode.ReasonMissingBinary, results[0].Reason)
+		require.True(t, results[1].Detected)
+		// apt is detected from its binary alone.
+		require.Equal(t, mode.ConfidenceLow, results[1].Confidence)
+	})
+
+	t.Run("planning records checks", func(t *testing.T) {
+		modes, err := mode.DefaultModes().Filter([]string{"bun"})
+		require.NoError(t, err)
+		e := executor(nil, nil)
+		e.RunFunc = func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+			return []byte("/home/user/.bun/install/cache\n"), nil
+		}
+		plan, err := modes.Plan(t.Context(), mode.PlanRequest{Exec: e})
+		require.NoError(t, err)
+		require.NotEmpty(t, plan.Results["bun"].Checks)
+		require.Equal(t, mode.CheckCommand, plan.Results["bun"].Checks[0].Kind)
+	})
+}
@@ -2,42 +2,91 @@
 package mode
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
+// DefaultModes returns the built-in modes, followed by the modes added with
+// Register.
 func DefaultModes() Modes {
+	return append(builtinModes(), registeredModes()...)
+}
+
+func builtinModes() Modes {
 	return Modes{
 		AptProvider{},
+		AsdfProvider{},
 		BrewProvider{},
+		Buck2Provider{},
 		BunProvider{},
+		CarthageProvider{},
+		CMakeProvider{},
 		CocoapodsProvider{},
 		ComposerProvider{},
+		ConanProvider{},
+		CorepackProvider{},
+		CypressProvider{},
 		DenoProvider{},
+		ElixirProvider{},
+		FastlaneProvider{},
+		FoundryProvider{},
 		GoProvider{},
 		GolangCILintProvider{},
+		GoreleaserProvider{},
 		GradleProvider{},
+		HardhatProvider{},
+		HaskellProvider{},
+		HelmProvider{},
+		JestProvider{},
+		JuliaProvider{},
 		KotlinNativeProvider{},
+		LernaProvider{},
 		MavenProvider{},
 		MiseProvider{},
 		NixProvider{},
 		NpmProvider{},
+		NvmProvider{},
+		NxProvider{},
+		OpamProvider{},
+		PantsProvider{},
+		PipenvProvider{},
+		PixiProvider{},
 		PlaywrightProvider{},
+		PleaseProvider{},
 		PnpmProvider{},
 		PoetryProvider{},
+		PreCommitProvider{},
 		PythonProvider{},
+		PythonToolsProvider{},
+		RenvProvider{},
 		RubyProvider{},
+		RushProvider{},
 		RustProvider{},
 		SwiftPMProvider{},
+		TerraformProvider{},
+		TuistProvider{},
+		TurboProvider{},
 		UVProvider{},
+		VcpkgProvider{},
+		ViteProvider{},
+		WebpackProvider{},
 		XcodeProvider{},
 		YarnProvider{},
+		ZigProvider{},
 	}
 }
 
@@ -78,25 +127,40 @@ func (modes Modes) Filter(include []string) (Modes, error) {
 
 // Detect runs detection for all modes in parallel and returns those that were detected.
 func (modes Modes) Detect(ctx context.Context, req DetectRequest) (Modes, error) {
-	if req.Exec == nil {
-		req.Exec = DefaultExecutor{}
+	results, err := modes.DetectAll(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
-	var m sync.Mutex
 	filtered := make(Modes, 0, len(modes))
+	for i, mode := range modes {
+		if results[i].Detected {
+			filtered = append(filtered, mode)
+		}
+	}
+	return filtered, nil
+}
 
+// DetectAll runs detection for all modes in parallel and returns the result
+// of each mode, in the order of modes.
+func (modes Modes) DetectAll(ctx context.Context, req DetectRequest) ([]DetectResult, error) {
+	if req.Exec == nil {
+		req.Exec = DefaultExecutor{}
+	}
+	req.Exec = inDir(req.Exec, req.WorkDir)
+
+	results := make([]DetectResult, len(modes))
 	eg, ctx := errgroup.WithContext(ctx)
-	for _, mode := range modes {
+	for i, mode := range modes {
 		eg.Go(func() error {
-			detected, err := mode.Detect(ctx, req)
+			start := time.Now()
+			result, err := mode.Detect(ctx, req)
 			if err != nil {
 				return fmt.Errorf("detecting %s: %w", mode.Name(), err)
 			}
-			if detected {
-				m.Lock()
-				defer m.Unlock()
-				filtered = append(filtered, mode)
-			}
+			result.DurationMs = time.Since(start).Milliseconds()
+			result.log(mode.Name())
+			results[i] = result
 			return nil
 		})
 	}
@@ -105,55 +169,320 @@ func (modes Modes) Detect(ctx context.Context, req DetectRequest) (Modes, error)
 		return nil, err
 	}
 
-	return filtered, nil
+	return results, nil
 }
 
-// Plan runs planning for all modes in parallel and returns their results.
-func (modes Modes) Plan(ctx context.Context, req PlanRequest) (map[string]PlanResult, error) {
-	req.EnabledModes = modes.Names()
+// Plan resolves the order of modes, then runs planning for the ordered modes
+// in parallel and returns their results.
+func (modes Modes) Plan(ctx context.Context, req PlanRequest) (Plan, error) {
+	order, err := modes.Order()
+	if err != nil {
+		return Plan{}, err
+	}
+
+	req.EnabledModes = order.Modes.Names()
 	if req.Exec == nil {
 		req.Exec = DefaultExecutor{}
 	}
+	req.Exec = inDir(req.Exec, req.WorkDir)
 
 	var m sync.Mutex
-	plans := make(map[string]PlanResult, len(modes))
+	results := make(map[string]PlanResult, len(order.Modes))
 
 	eg, ctx := errgroup.WithContext(ctx)
-	for _, mode := range modes {
+	for _, mode := range order.Modes {
 		eg.Go(func() error {
+			req := req
+			if env := req.ModeEnv[mode.Name()]; len(env) > 0 {
+				merged := make(map[string]string, len(req.Env)+len(env))
+				maps.Copy(merged, req.Env)
+				maps.Copy(merged, env)
+				req.Env = merged
+			}
+			req.Exec = withEnv(req.Exec, req.Env)
+			var refused *atomic.Bool
+			if _, plugin := mode.(PluginProvider); req.Static || (req.Offline && !plugin) {
+				o := offline(req.Exec)
+				req.Exec, refused = o, o.refused
+				if req.Static {
+					req.Offline = true
+					req.Exec = static{o}
+				}
+			}
+			req.provenance = new(provenance)
+			req.Exec = req.provenance.tracing(req.Exec)
+			d := recording(&req.Exec, req.WorkDir)
+			start := time.Now()
 			result, err := mode.Plan(ctx, req)
 			if err != nil {
 				return fmt.Errorf("planning %s: %w", mode.Name(), err)
 			}
+			result.Duration = time.Since(start)
+			if refused != nil && refused.Load() {
+				result.Assumed = true
+			}
+			result.Sources = req.provenance.sources(result.MountPaths)
+			result = result.rebase(req.WorkDir)
+			result.Checks = d.recorded()
+			logChecks(mode.Name(), "planned", result.Checks)
 
 			m.Lock()
-			plans[mode.Name()] = result
+			results[mode.Name()] = result
 			m.Unlock()
 			return nil
 		})
 	}
 
 	if err := eg.Wait(); err != nil {
-		return nil, err
+		return Plan{}, err
 	}
 
-	return plans, nil
+	return Plan{
+		Order:   order.Names(),
+		Skipped: order.Skipped,
+		After:   order.After,
+		Results: results,
+	}, nil
+}
+
+// Order resolves conflicts and ordering between modes. Modes that conflict
+// with another mode in the set are skipped, and the rest are sorted so that
+// each mode comes after the modes it declares in After. Modes without
+// constraints between them are sorted by name.
+func (modes Modes) Order() (Ordering, error) {
+	byName := make(map[string]ModeProvider, len(modes))
+	for _, mode := range modes {
+		byName[mode.Name()] = mode
+	}
+	names := modes.Names()
+
+	// A mode is skipped when a mode it conflicts with is kept, so a mode
+	// that is skipped itself cannot push out others.
+	skipped := make(map[string]string)
+	resolved := make(map[string]bool, len(names))
+	var resolve func(name string) bool
+	resolve = func(name string) bool {
+		if done, ok := resolved[name]; ok {
+			return !done || skipped[name] == ""
+		}
+		resolved[name] = false // conflicts in a cycle do not skip each other
+		if c, ok := byName[name].(Conflicter); ok {
+			for _, other := range c.ConflictsWith() {
+				if _, enabled := byName[other]; enabled && other != name && resolve(other) {
+					skipped[name] = other
+					break
+				}
+			}
+		}
+		resolved[name] = true
+		return skipped[name] == ""
+	}
+	for _, name := range names {
+		resolve(name)
+	}
+	if len(skipped) == 0 {
+		skipped = nil
+	}
+
+	var after map[string][]string
+	var kept []string
+	for _, name := range names {
+		if skipped[name] != "" {
+			continue
+		}
+		kept = append(kept, name)
+		o, ok := byName[name].(Orderer)
+		if !ok {
+			continue
+		}
+		for _, before := range slices.Compact(slices.Sorted(slices.Values(o.After()))) {
+			if _, enabled := byName[before]; !enabled || skipped[before] != "" || before == name {
+				continue
+			}
+			if after == nil {
+				after = make(map[string][]string)
+			}
+			after[name] = append(after[name], before)
+		}
+	}
+
+	sorted, err := SortAfter(kept, after)
+	if err != nil {
+		return Ordering{}, err
+	}
+	ordered := make(Modes, 0, len(sorted))
+	for _, name := range sorted {
+		ordered = append(ordered, byName[name])
+	}
+
+	return Ordering{Modes: ordered, Skipped: skipped, After: after}, nil
+}
+
+// SortAfter sorts the names of modes so that each comes after the modes that
+// after lists for it, which must be among names. Modes without constraints
+// between them keep the order of names. It fails when the order is cyclic.
+func SortAfter(names []string, after map[string][]string) ([]string, error) {
+	// Kahn's algorithm, always picking the first ready mode so the order is
+	// stable.
+	pending := make(map[string]int, len(names))
+	dependents := make(map[string][]string, len(names))
+	for _, name := range names {
+		pending[name] = 0
+	}
+	for _, name := range names {
+		for _, before := range after[name] {
+			pending[name]++
+			dependents[before] = append(dependents[before], name)
+		}
+	}
+
+	sorted := make([]string, 0, len(pending))
+	for len(pending) > 0 {
+		next := ""
+		for _, name := range names {
+			if n, ok := pending[name]; ok && n == 0 {
+				next = name
+				break
+			}
+		}
+		if next == "" {
+			cycle := slices.Sorted(maps.Keys(pending))
+			return nil, fmt.Errorf("modes have a cyclic order: %s", strings.Join(cycle, ", "))
+		}
+
+		delete(pending, next)
+		for _, dep := range dependents[next] {
+			pending[dep]--
+		}
+		sorted = append(sorted, next)
+	}
+	return sorted, nil
+}
+
+// Ordering is the outcome of resolving the order of modes.
+type Ordering struct {
+	// Modes are the modes to plan and mount, in order.
+	Modes Modes
+	// Skipped maps each mode that was dropped to the mode it conflicts with.
+	Skipped map[string]string
+	// After maps ordered modes to the ordered modes they must follow.
+	After map[string][]string
+}
+
+// Names returns the names of the ordered modes, in order.
+func (o Ordering) Names() []string {
+	names := make([]string, 0, len(o.Modes))
+	for _, mode := range o.Modes {
+		names = append(names, mode.Name())
+	}
+	return names
+}
+
+// Plan holds the results of planning a set of modes.
+type Plan struct {
+	// Order lists the planned modes, in the order their caches are mounted.
+	Order []string `json:"order"`
+	// Skipped maps modes that were not planned to the mode they conflict with.
+	Skipped map[string]string `json:"skipped,omitempty"`
+	// After maps planned modes to the planned modes they must follow.
+	After   map[string][]string   `json:"after,omitempty"`
+	Results map[string]PlanResult `json:"results"`
 }
 
 type ModeProvider interface {
 	Name() string
-	Detect(ctx context.Context, req DetectRequest) (bool, error)
+	Detect(ctx context.Context, req DetectRequest) (DetectResult, error)
 	Plan(ctx context.Context, req PlanRequest) (PlanResult, error)
 }
 
+// Trimmer is implemented by providers whose tools can drop stale entries
+// from their caches, so that caches do not grow without bound.
+type Trimmer interface {
+	Trim(ctx context.Context, req TrimRequest) error
+}
+
+// Warmer is implemented by providers whose tools can fill their caches
+// ahead of builds, e.g. by downloading the dependencies of the project, so
+// that a dedicated job can populate a fresh volume.
+type Warmer interface {
+	Warm(ctx context.Context, req WarmRequest) error
+}
+
+// Orderer is implemented by providers whose caches must be mounted after
+// those of other modes, e.g. because they nest inside them. Modes in After
+// that are not enabled are ignored.
+type Orderer interface {
+	After() []string
+}
+
+// Conflicter is implemented by providers whose caches are already covered by
+// other modes. When any mode in ConflictsWith is enabled, this mode is skipped.
+type Conflicter interface {
+	ConflictsWith() []string
+}
+
+type TrimRequest struct {
+	Exec Executor
+}
+
+type WarmRequest struct {
+	Exec Executor
+	// WorkDir is the directory of the project to warm the caches of, when
+	// it is not the current directory. Warm commands run in it.
+	WorkDir string
+	// Concurrency is the maximum number of modes that Modes.Warm warms at
+	// once. Zero means no limit.
+	Concurrency int
+}
+
 type DetectRequest struct {
 	Exec Executor
+	// WorkDir is the directory of the project to detect, when it is not the
+	// current directory. Modes resolve relative paths and run commands in it
+	// through Exec.
+	WorkDir string
 }
 
 type PlanRequest struct {
 	CacheRoot    string
 	EnabledModes []string
 	Exec         Executor
+	// WorkDir is the directory of the project to plan, when it is not the
+	// current directory. Modes resolve relative paths and run commands in it
+	// through Exec, and make the relative paths of the results relative to
+	// the current directory.
+	WorkDir string
+	// Env overrides environment variables for planning. Modes read the
+	// environment through Getenv, and their commands run with Env on top of
+	// the environment of the process.
+	Env map[string]string
+	// ModeEnv overrides environment variables for planning single modes, on
+	// top of Env.
+	ModeEnv map[string]map[string]string
+	// Offline plans without running commands, which may reach the network.
+	// Exec fails them with ErrOffline, and providers assume the default
+	// locations of their tools instead. Plugins are still run, and are asked
+	// to plan offline themselves.
+	Offline bool
+	// Static plans without running anything, not even plugins, for early
+	// boot phases before toolchains are installed. It implies Offline, and
+	// Exec also assumes that every binary looked up is installed, so paths
+	// derive from the environment and the defaults of the tools alone.
+	Static bool
+
+	provenance *provenance
+}
+
+// Getenv returns the value of an environment variable, as overridden by Env.
+func (r PlanRequest) Getenv(key string) string {
+	value, ok := r.Env[key]
+	if !ok {
+		value = os.Getenv(key)
+	}
+	if r.provenance != nil {
+		r.provenance.getenv(key, value)
+	}
+	return value
 }
 
 type PlanResult struct {
@@ -161,23 +490,151 @@ type PlanResult struct {
 	CacheDirs   []string
 	MountPaths  []string
 	RemovePaths []string
+	// KeyFiles are files, relative to the working directory, whose contents
+	// key the cache of MountPaths when keyed caches are enabled. Glob
+	// patterns are allowed and files that do not exist are ignored.
+	KeyFiles []string
+	// Warnings are problems the provider worked around while planning, e.g.
+	// a tool version that could not be determined, so a default was used.
+	Warnings []string
+	// Checks lists the binaries looked up, the paths read and the commands
+	// run while planning. Modes.Plan fills it in.
+	Checks []DetectCheck
+	// Assumed is set when planning offline assumed default paths, which
+	// differ from the actual ones if the tool is configured otherwise.
+	// Modes.Plan sets it when a command was not run.
+	Assumed bool
+	// Sources maps mount paths to where they came from: the environment
+	// variables read through Getenv, the output of commands, or defaults.
+	// Modes.Plan fills it in.
+	Sources map[string]Source
+	// Duration is how long planning took. Modes.Plan fills it in.
+	Duration time.Duration
+}
+
+// CommandSpec describes a command for Executor.Run. Executors run it with
+// the working directory, environment and timeout of the planning request, so
+// that providers only say what to run.
+type CommandSpec struct {
+	// Name is the binary to run, looked up on PATH unless it is a path.
+	Name string
+	Args []string
+	// Dir is the directory to run in. When empty, it is the working
+	// directory of the executor.
+	Dir string
+	// Env overrides environment variables on top of those of the executor
+	// and the process.
+	Env map[string]string
+	// Stdin is the standard input of the command.
+	Stdin []byte
+	// Timeout kills the command when it runs longer. When zero, the timeout
+	// of the executor applies.
+	Timeout time.Duration
+}
+
+// String returns the command line, e.g. for checks and errors.
+func (s CommandSpec) String() string {
+	return strings.Join(append([]string{s.Name}, s.Args...), " ")
 }
 
 type Executor interface {
 	LookPath(file string) (string, error)
-	Output(*exec.Cmd) ([]byte, error)
+	// Run runs a command and returns its standard output. Errors of
+	// commands that ran wrap *exec.ExitError, and include the standard
+	// error of the command.
+	Run(ctx context.Context, spec CommandSpec) ([]byte, error)
 	Stat(name string) (os.FileInfo, error)
 	ReadDir(name string) ([]os.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+// envExecutor runs commands with environment variables overridden.
+type envExecutor struct {
+	Executor
+	env map[string]string
+}
+
+// withEnv returns an executor running commands with env on top of the
+// environment of the process, or exec itself when env is empty.
+func withEnv(exec Executor, env map[string]string) Executor {
+	if len(env) == 0 {
+		return exec
+	}
+	return envExecutor{Executor: exec, env: env}
 }
 
-type DefaultExecutor struct{}
+func (e envExecutor) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	env := maps.Clone(e.env)
+	maps.Copy(env, spec.Env)
+	spec.Env = env
+	return e.Executor.Run(ctx, spec)
+}
+
+type DefaultExecutor struct {
+	// CommandTimeout kills commands that run longer, so that a broken
+	// toolchain cannot stall detection or planning. Zero means no limit.
+	CommandTimeout time.Duration
+}
 
 func (e DefaultExecutor) LookPath(file string) (string, error) {
 	return exec.LookPath(file)
 }
 
-func (e DefaultExecutor) Output(cmd *exec.Cmd) ([]byte, error) {
-	return cmd.Output()
+func (e DefaultExecutor) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	timeout := cmp.Or(spec.Timeout, e.CommandTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, spec.Name, spec.Args...)
+	cmd.Dir = spec.Dir
+	if len(spec.Env) > 0 {
+		cmd.Env = os.Environ()
+		for _, key := range slices.Sorted(maps.Keys(spec.Env)) {
+			cmd.Env = append(cmd.Env, key+"="+spec.Env[key])
+		}
+	}
+	if spec.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(spec.Stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Children of a killed command may keep its output open.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if err != nil && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s: timed out after %s", spec, timeout)
+	}
+
+	msg := stderrTail(stderr.Bytes())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+		if msg != "" {
+			return stdout.Bytes(), fmt.Errorf("%w: %s", err, msg)
+		}
+	} else if err == nil && msg != "" {
+		// Tools warn on stderr, e.g. about deprecated config, which helps
+		// explaining unexpected plans.
+		slog.Debug("command wrote to stderr", slog.String("command", spec.String()), slog.String("stderr", msg))
+	}
+	return stdout.Bytes(), err
+}
+
+// maxStderr is how much of the standard error of a command is kept in errors
+// and logs. The end is kept, as tools print the cause last.
+const maxStderr = 4096
+
+func stderrTail(stderr []byte) string {
+	stderr = bytes.TrimSpace(stderr)
+	if len(stderr) > maxStderr {
+		stderr = append([]byte("…"), stderr[len(stderr)-maxStderr:]...)
+	}
+	return string(stderr)
 }
 
 func (e DefaultExecutor) Stat(name string) (os.FileInfo, error) {
@@ -187,3 +644,7 @@ func (e DefaultExecutor) Stat(name string) (os.FileInfo, error) {
 func (e DefaultExecutor) ReadDir(name string) ([]os.DirEntry, error) {
 	return os.ReadDir(name)
 }
+
+func (e DefaultExecutor) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
@@ -6,7 +6,6 @@ package mode
 import (
 	"context"
 	"os"
-	"os/exec"
 	"sync"
 )
 
@@ -23,12 +22,15 @@ var _ Executor = &ExecutorMock{}
 //			LookPathFunc: func(file string) (string, error) {
 //				panic("mock out the LookPath method")
 //			},
-//			OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-//				panic("mock out the Output method")
-//			},
 //			ReadDirFunc: func(name string) ([]os.DirEntry, error) {
 //				panic("mock out the ReadDir method")
 //			},
+//			ReadFileFunc: func(name string) ([]byte, error) {
+//				panic("mock out the ReadFile method")
+//			},
+//			RunFunc: func(ctx context.Context, spec CommandSpec) ([]byte, error) {
+//				panic("mock out the Run method")
+//			},
 //			StatFunc: func(name string) (os.FileInfo, error) {
 //				panic("mock out the Stat method")
 //			},
@@ -42,12 +44,15 @@ type ExecutorMock struct {
 	// LookPathFunc mocks the LookPath method.
 	LookPathFunc func(file string) (string, error)
 
-	// OutputFunc mocks the Output method.
-	OutputFunc func(cmd *exec.Cmd) ([]byte, error)
-
 	// ReadDirFunc mocks the ReadDir method.
 	ReadDirFunc func(name string) ([]os.DirEntry, error)
 
+	// ReadFileFunc mocks the ReadFile method.
+	ReadFileFunc func(name string) ([]byte, error)
+
+	// RunFunc mocks the Run method.
+	RunFunc func(ctx context.Context, spec CommandSpec) ([]byte, error)
+
 	// StatFunc mocks the Stat method.
 	StatFunc func(name string) (os.FileInfo, error)
 
@@ -58,16 +63,23 @@ type ExecutorMock struct {
 			// File is the file argument value.
 			File string
 		}
-		// Output holds details about calls to the Output method.
-		Output []struct {
-			// Cmd is the cmd argument value.
-			Cmd *exec.Cmd
-		}
 		// ReadDir holds details about calls to the ReadDir method.
 		ReadDir []struct {
 			// Name is the name argument value.
 			Name string
 		}
+		// ReadFile holds details about calls to the ReadFile method.
+		ReadFile []struct {
+			// Name is the name argument value.
+			Name string
+		}
+		// Run holds details about calls to the Run method.
+		Run []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Spec is the spec argument value.
+			Spec CommandSpec
+		}
 		// Stat holds details about calls to the Stat method.
 		Stat []struct {
 			// Name is the name argument value.
@@ -75,8 +87,9 @@ type ExecutorMock struct {
 		}
 	}
 	lockLookPath sync.RWMutex
-	lockOutput   sync.RWMutex
 	lockReadDir  sync.RWMutex
+	lockReadFile sync.RWMutex
+	lockRun      sync.RWMutex
 	lockStat     sync.RWMutex
 }
 
@@ -112,38 +125,6 @@ func (mock *ExecutorMock) LookPathCalls() []struct {
 	return calls
 }
 
-// Output calls OutputFunc.
-func (mock *ExecutorMock) Output(cmd *exec.Cmd) ([]byte, error) {
-	if mock.OutputFunc == nil {
-		panic("ExecutorMock.OutputFunc: method is nil but Executor.Output was just called")
-	}
-	callInfo := struct {
-		Cmd *exec.Cmd
-	}{
-		Cmd: cmd,
-	}
-	mock.lockOutput.Lock()
-	mock.calls.Output = append(mock.calls.Output, callInfo)
-	mock.lockOutput.Unlock()
-	return mock.OutputFunc(cmd)
-}
-
-// OutputCalls gets all the calls that were made to Output.
-// Check the length with:
-//
-//	len(mockedExecutor.OutputCalls())
-func (mock *ExecutorMock) OutputCalls() []struct {
-	Cmd *exec.Cmd
-} {
-	var calls []struct {
-		Cmd *exec.Cmd
-	}
-	mock.lockOutput.RLock()
-	calls = mock.calls.Output
-	mock.lockOutput.RUnlock()
-	return calls
-}
-
 // ReadDir calls ReadDirFunc.
 func (mock *ExecutorMock) ReadDir(name string) ([]os.DirEntry, error) {
 	if mock.ReadDirFunc == nil {
@@ -176,6 +157,74 @@ func (mock *ExecutorMock) ReadDirCalls() []struct {
 	return calls
 }
 
+// ReadFile calls ReadFileFunc.
+func (mock *ExecutorMock) ReadFile(name string) ([]byte, error) {
+	if mock.ReadFileFunc == nil {
+		panic("ExecutorMock.ReadFileFunc: method is nil but Executor.ReadFile was just called")
+	}
+	callInfo := struct {
+		Name string
+	}{
+		Name: name,
+	}
+	mock.lockReadFile.Lock()
+	mock.calls.ReadFile = append(mock.calls.ReadFile, callInfo)
+	mock.lockReadFile.Unlock()
+	return mock.ReadFileFunc(name)
+}
+
+// ReadFileCalls gets all the calls that were made to ReadFile.
+// Check the length with:
+//
+//	len(mockedExecutor.ReadFileCalls())
+func (mock *ExecutorMock) ReadFileCalls() []struct {
+	Name string
+} {
+	var calls []struct {
+		Name string
+	}
+	mock.lockReadFile.RLock()
+	calls = mock.calls.ReadFile
+	mock.lockReadFile.RUnlock()
+	return calls
+}
+
+// Run calls RunFunc.
+func (mock *ExecutorMock) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	if mock.RunFunc == nil {
+		panic("ExecutorMock.RunFunc: method is nil but Executor.Run was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Spec CommandSpec
+	}{
+		Ctx:  ctx,
+		Spec: spec,
+	}
+	mock.lockRun.Lock()
+	mock.calls.Run = append(mock.calls.Run, callInfo)
+	mock.lockRun.Unlock()
+	return mock.RunFunc(ctx, spec)
+}
+
+// RunCalls gets all the calls that were made to Run.
+// Check the length with:
+//
+//	len(mockedExecutor.RunCalls())
+func (mock *ExecutorMock) RunCalls() []struct {
+	Ctx  context.Context
+	Spec CommandSpec
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Spec CommandSpec
+	}
+	mock.lockRun.RLock()
+	calls = mock.calls.Run
+	mock.lockRun.RUnlock()
+	return calls
+}
+
 // Stat calls StatFunc.
 func (mock *ExecutorMock) Stat(name string) (os.FileInfo, error) {
 	if mock.StatFunc == nil {
@@ -218,7 +267,7 @@ var _ ModeProvider = &ModeProviderMock{}
 //
 //		// make and configure a mocked ModeProvider
 //		mockedModeProvider := &ModeProviderMock{
-//			DetectFunc: func(ctx context.Context, req DetectRequest) (bool, error) {
+//			DetectFunc: func(ctx context.Context, req DetectRequest) (DetectResult, error) {
 //				panic("mock out the Detect method")
 //			},
 //			NameFunc: func() string {
@@ -235,7 +284,7 @@ var _ ModeProvider = &ModeProviderMock{}
 //	}
 type ModeProviderMock struct {
 	// DetectFunc mocks the Detect method.
-	DetectFunc func(ctx context.Context, req DetectRequest) (bool, error)
+	DetectFunc func(ctx context.Context, req DetectRequest) (DetectResult, error)
 
 	// NameFunc mocks the Name method.
 	NameFunc func() string
@@ -269,7 +318,7 @@ type ModeProviderMock struct {
 }
 
 // Detect calls DetectFunc.
-func (mock *ModeProviderMock) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (mock *ModeProviderMock) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
 	if mock.DetectFunc == nil {
 		panic("ModeProviderMock.DetectFunc: method is nil but ModeProvider.Detect was just called")
 	}
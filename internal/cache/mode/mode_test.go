@@ -1,11 +1,17 @@
 package mode_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -79,16 +85,22 @@ func TestModes_Detect(t *testing.T) {
 	t.Run("all modes detected", func(t *testing.T) {
 		modes := mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode1" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode1" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode2" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode2" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode3" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode3" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 		}
 		detected, err := modes.Detect(t.Context(), mode.DetectRequest{})
@@ -99,12 +111,16 @@ func TestModes_Detect(t *testing.T) {
 	t.Run("no modes detected", func(t *testing.T) {
 		modes := mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode1" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "mode1" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode2" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "mode2" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		}
 		detected, err := modes.Detect(t.Context(), mode.DetectRequest{})
@@ -115,16 +131,22 @@ func TestModes_Detect(t *testing.T) {
 	t.Run("some modes detected", func(t *testing.T) {
 		modes := mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode1" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode1" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode2" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "mode2" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode3" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode3" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 		}
 		detected, err := modes.Detect(t.Context(), mode.DetectRequest{})
@@ -136,13 +158,15 @@ func TestModes_Detect(t *testing.T) {
 	t.Run("detection error returns error", func(t *testing.T) {
 		modes := mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "mode1" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "mode1" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
 				NameFunc: func() string { return "mode2" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-					return false, fmt.Errorf("detection failed")
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{}, fmt.Errorf("detection failed")
 				},
 			},
 		}
@@ -160,8 +184,8 @@ func TestModes_Detect(t *testing.T) {
 		modes := mode.Modes{
 			&mode.ModeProviderMock{
 				NameFunc: func() string { return "mode1" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-					return false, ctx.Err()
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{}, ctx.Err()
 				},
 			},
 		}
@@ -173,11 +197,11 @@ func TestModes_Detect(t *testing.T) {
 }
 
 func TestModes_Plan(t *testing.T) {
-	t.Run("empty modes returns empty map", func(t *testing.T) {
+	t.Run("empty modes returns empty plan", func(t *testing.T) {
 		var modes mode.Modes
 		plans, err := modes.Plan(t.Context(), mode.PlanRequest{})
 		require.NoError(t, err)
-		require.Empty(t, plans)
+		require.Empty(t, plans.Results)
 	})
 
 	t.Run("plans all modes", func(t *testing.T) {
@@ -203,10 +227,38 @@ func TestModes_Plan(t *testing.T) {
 		}
 		plans, err := modes.Plan(t.Context(), mode.PlanRequest{})
 		require.NoError(t, err)
-		require.Len(t, plans, 3)
-		require.Equal(t, []string{"/cache1"}, plans["mode1"].MountPaths)
-		require.Equal(t, []string{"/cache2"}, plans["mode2"].MountPaths)
-		require.Equal(t, []string{"/cache3"}, plans["mode3"].MountPaths)
+		require.Len(t, plans.Results, 3)
+		require.Equal(t, []string{"/cache1"}, plans.Results["mode1"].MountPaths)
+		require.Equal(t, []string{"/cache2"}, plans.Results["mode2"].MountPaths)
+		require.Equal(t, []string{"/cache3"}, plans.Results["mode3"].MountPaths)
+	})
+
+	t.Run("mode env overrides", func(t *testing.T) {
+		t.Setenv("GOFLAGS", "-mod=mod")
+
+		var commandEnv map[string]string
+		modes := mode.Modes{
+			mode.GoProvider{},
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "other" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					return mode.PlanResult{MountPaths: []string{req.Getenv("GOMODCACHE"), req.Getenv("GOFLAGS")}}, nil
+				},
+			},
+		}
+		plans, err := modes.Plan(t.Context(), mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					commandEnv = cmd.Env
+					return []byte(`{"GOCACHE": "/gocache", "GOMODCACHE": "/mnt/gomod"}`), nil
+				},
+			},
+			ModeEnv: map[string]map[string]string{"go": {"GOMODCACHE": "/mnt/gomod"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"GOMODCACHE": "/mnt/gomod"}, commandEnv)
+		// Overrides of one mode do not leak into others.
+		require.Equal(t, []string{"", "-mod=mod"}, plans.Results["other"].MountPaths)
 	})
 
 	t.Run("planning error returns error", func(t *testing.T) {
@@ -228,7 +280,7 @@ func TestModes_Plan(t *testing.T) {
 		require.Error(t, err)
 		require.ErrorContains(t, err, "planning mode2")
 		require.ErrorContains(t, err, "planning failed")
-		require.Nil(t, plans)
+		require.Zero(t, plans)
 	})
 
 	t.Run("context cancellation", func(t *testing.T) {
@@ -246,7 +298,7 @@ func TestModes_Plan(t *testing.T) {
 		plans, err := modes.Plan(ctx, mode.PlanRequest{})
 		require.Error(t, err)
 		require.ErrorIs(t, err, context.Canceled)
-		require.Nil(t, plans)
+		require.Zero(t, plans)
 	})
 
 	t.Run("collects all plan result fields", func(t *testing.T) {
@@ -264,10 +316,10 @@ func TestModes_Plan(t *testing.T) {
 		}
 		plans, err := modes.Plan(t.Context(), mode.PlanRequest{})
 		require.NoError(t, err)
-		require.Len(t, plans, 1)
-		require.Equal(t, map[string]string{"KEY1": "value1"}, plans["mode1"].AddEnvs)
-		require.Equal(t, []string{"/cache1", "/cache2"}, plans["mode1"].MountPaths)
-		require.Equal(t, []string{"/remove1"}, plans["mode1"].RemovePaths)
+		require.Len(t, plans.Results, 1)
+		require.Equal(t, map[string]string{"KEY1": "value1"}, plans.Results["mode1"].AddEnvs)
+		require.Equal(t, []string{"/cache1", "/cache2"}, plans.Results["mode1"].MountPaths)
+		require.Equal(t, []string{"/remove1"}, plans.Results["mode1"].RemovePaths)
 	})
 
 	t.Run("multiple modes with different results", func(t *testing.T) {
@@ -300,11 +352,162 @@ func TestModes_Plan(t *testing.T) {
 		}
 		plans, err := modes.Plan(t.Context(), mode.PlanRequest{})
 		require.NoError(t, err)
-		require.Len(t, plans, 3)
-		require.Equal(t, map[string]string{"KEY1": "value1"}, plans["mode1"].AddEnvs)
-		require.Equal(t, []string{"/cache1"}, plans["mode1"].MountPaths)
-		require.Empty(t, plans["mode2"].MountPaths)
-		require.Equal(t, []string{"/remove1", "/remove2"}, plans["mode2"].RemovePaths)
-		require.Equal(t, []string{"/cache3"}, plans["mode3"].MountPaths)
+		require.Len(t, plans.Results, 3)
+		require.Equal(t, map[string]string{"KEY1": "value1"}, plans.Results["mode1"].AddEnvs)
+		require.Equal(t, []string{"/cache1"}, plans.Results["mode1"].MountPaths)
+		require.Empty(t, plans.Results["mode2"].MountPaths)
+		require.Equal(t, []string{"/remove1", "/remove2"}, plans.Results["mode2"].RemovePaths)
+		require.Equal(t, []string{"/cache3"}, plans.Results["mode3"].MountPaths)
+	})
+}
+
+// orderedMode is a mode that declares ordering and conflicts.
+type orderedMode struct {
+	mode.ModeProviderMock
+	after, conflicts []string
+}
+
+func (m *orderedMode) After() []string         { return m.after }
+func (m *orderedMode) ConflictsWith() []string { return m.conflicts }
+
+func newOrderedMode(name string, after, conflicts []string) *orderedMode {
+	return &orderedMode{
+		ModeProviderMock: mode.ModeProviderMock{
+			NameFunc: func() string { return name },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{MountPaths: []string{"/" + name}}, nil
+			},
+		},
+		after:     after,
+		conflicts: conflicts,
+	}
+}
+
+func TestModes_Order(t *testing.T) {
+	t.Run("sorts unconstrained modes by name", func(t *testing.T) {
+		modes := mode.Modes{newOrderedMode("b", nil, nil), newOrderedMode("a", nil, nil)}
+		order, err := modes.Order()
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b"}, order.Names())
+		require.Empty(t, order.Skipped)
+		require.Empty(t, order.After)
+	})
+
+	t.Run("places modes after their dependencies", func(t *testing.T) {
+		modes := mode.Modes{
+			newOrderedMode("a", []string{"c"}, nil),
+			newOrderedMode("b", nil, nil),
+			newOrderedMode("c", []string{"b", "missing"}, nil),
+		}
+		order, err := modes.Order()
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "c", "a"}, order.Names())
+		require.Equal(t, map[string][]string{"a": {"c"}, "c": {"b"}}, order.After)
+	})
+
+	t.Run("skips conflicting modes", func(t *testing.T) {
+		modes := mode.Modes{
+			newOrderedMode("a", nil, []string{"b"}),
+			newOrderedMode("b", nil, []string{"c"}),
+			newOrderedMode("c", []string{"a"}, nil),
+		}
+		order, err := modes.Order()
+		require.NoError(t, err)
+		// b is skipped for c, so it no longer pushes out a.
+		require.Equal(t, []string{"a", "c"}, order.Names())
+		require.Equal(t, map[string]string{"b": "c"}, order.Skipped)
+	})
+
+	t.Run("reports cycles", func(t *testing.T) {
+		modes := mode.Modes{
+			newOrderedMode("a", []string{"b"}, nil),
+			newOrderedMode("b", []string{"a"}, nil),
+			newOrderedMode("c", nil, nil),
+		}
+		_, err := modes.Order()
+		require.ErrorContains(t, err, "modes have a cyclic order: a, b")
+	})
+
+	t.Run("plan reports order and skips conflicts", func(t *testing.T) {
+		var enabled []string
+		a := newOrderedMode("a", []string{"b"}, nil)
+		a.PlanFunc = func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+			enabled = req.EnabledModes
+			return mode.PlanResult{}, nil
+		}
+		modes := mode.Modes{a, newOrderedMode("b", nil, nil), newOrderedMode("c", nil, []string{"a"})}
+
+		plans, err := modes.Plan(t.Context(), mode.PlanRequest{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "a"}, plans.Order)
+		require.Equal(t, map[string]string{"c": "a"}, plans.Skipped)
+		require.Equal(t, map[string][]string{"a": {"b"}}, plans.After)
+		require.Len(t, plans.Results, 2)
+		require.Equal(t, []string{"a", "b"}, enabled)
+	})
+}
+
+func TestDefaultExecutor_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	e := mode.DefaultExecutor{CommandTimeout: 50 * time.Millisecond}
+	sh := func(script string) mode.CommandSpec {
+		return mode.CommandSpec{Name: "sh", Args: []string{"-c", script}}
+	}
+
+	t.Run("kills commands that hang", func(t *testing.T) {
+		start := time.Now()
+		_, err := e.Run(t.Context(), sh("sleep 5"))
+		require.ErrorContains(t, err, "sh -c sleep 5: timed out after 50ms")
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("commands can extend the timeout", func(t *testing.T) {
+		spec := sh("sleep 0.1; echo done")
+		spec.Timeout = 5 * time.Second
+		out, err := e.Run(t.Context(), spec)
+		require.NoError(t, err)
+		require.Equal(t, "done\n", string(out))
+	})
+
+	t.Run("returns output and stderr", func(t *testing.T) {
+		out, err := e.Run(t.Context(), sh("echo out"))
+		require.NoError(t, err)
+		require.Equal(t, "out\n", string(out))
+
+		_, err = e.Run(t.Context(), sh("echo broken >&2; exit 3"))
+		require.EqualError(t, err, "exit status 3: broken")
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, "broken\n", string(exitErr.Stderr))
+	})
+
+	t.Run("logs stderr of successful commands", func(t *testing.T) {
+		var buf bytes.Buffer
+		prev := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		defer slog.SetDefault(prev)
+
+		out, err := e.Run(t.Context(), sh("echo out; echo deprecated >&2"))
+		require.NoError(t, err)
+		require.Equal(t, "out\n", string(out))
+		require.Contains(t, buf.String(), `msg="command wrote to stderr" command="sh -c echo out; echo deprecated >&2" stderr=deprecated`)
+	})
+
+	t.Run("runs with dir, env and stdin", func(t *testing.T) {
+		t.Setenv("SPACECTL_KEPT", "kept")
+		dir := t.TempDir()
+		spec := sh(`pwd -P; echo "$SPACECTL_KEPT $SPACECTL_SET"; cat`)
+		spec.Dir = dir
+		spec.Env = map[string]string{"SPACECTL_SET": "set"}
+		spec.Stdin = []byte("input\n")
+
+		out, err := e.Run(t.Context(), spec)
+		require.NoError(t, err)
+		resolved, err := filepath.EvalSymlinks(dir)
+		require.NoError(t, err)
+		require.Equal(t, resolved+"\nkept set\ninput\n", string(out))
 	})
 }
@@ -0,0 +1,114 @@
+package mode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrOffline is returned by the executor of offline planning for every
+// command, so that providers assume the default locations of their tools
+// instead of asking them.
+var ErrOffline = errors.New("commands are not run offline")
+
+// offlineExecutor refuses to run commands, as package managers may reach the
+// network when run, e.g. to check for updates or to fetch themselves through
+// corepack. It records whether any command was refused, which makes the plan
+// assumed.
+type offlineExecutor struct {
+	Executor
+	refused *atomic.Bool
+}
+
+func offline(exec Executor) offlineExecutor {
+	return offlineExecutor{Executor: exec, refused: new(atomic.Bool)}
+}
+
+func (e offlineExecutor) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	e.refused.Store(true)
+	return nil, fmt.Errorf("%s: %w", spec, ErrOffline)
+}
+
+// static is the executor of static planning, which runs nothing and assumes
+// that every binary is installed, as toolchains may not be yet.
+type static struct {
+	offlineExecutor
+}
+
+func (static) LookPath(file string) (string, error) {
+	return file, nil
+}
+
+// userCacheDir is where tools keep their caches unless configured otherwise,
+// as os.UserCacheDir but with the environment of the request:
+// $XDG_CACHE_HOME or ~/.cache on Linux, ~/Library/Caches on macOS and
+// %LocalAppData% on Windows.
+func userCacheDir(req PlanRequest) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "~/Library/Caches", nil
+	case "windows":
+		return localAppData(req)
+	default:
+		return xdgCacheDir(req)
+	}
+}
+
+// userDataDir is where tools keep their data unless configured otherwise:
+// $XDG_DATA_HOME or ~/.local/share on Linux, ~/Library on macOS and
+// %LocalAppData% on Windows.
+func userDataDir(req PlanRequest) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "~/Library", nil
+	case "windows":
+		return localAppData(req)
+	default:
+		if dir := req.Getenv("XDG_DATA_HOME"); dir != "" {
+			return dir, nil
+		}
+		return "~/.local/share", nil
+	}
+}
+
+// xdgCacheDir is where tools following the XDG spec on macOS too keep their
+// caches: %LocalAppData% on Windows, and $XDG_CACHE_HOME or ~/.cache
+// elsewhere.
+func xdgCacheDir(req PlanRequest) (string, error) {
+	if runtime.GOOS == "windows" {
+		return localAppData(req)
+	}
+	if dir := req.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return dir, nil
+	}
+	return "~/.cache", nil
+}
+
+func localAppData(req PlanRequest) (string, error) {
+	if dir := req.Getenv("LOCALAPPDATA"); dir != "" {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get user home dir: %w", err)
+	}
+	return filepath.Join(homeDir, "AppData", "Local"), nil
+}
+
+// defaultCacheDir is the cache dir assumed offline for tools that keep their
+// cache in name in the user cache dir, unless the environment variable key
+// sets it.
+func defaultCacheDir(req PlanRequest, key, name string) (string, error) {
+	if dir := req.Getenv(key); dir != "" {
+		return dir, nil
+	}
+	cacheHome, err := userCacheDir(req)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheHome, name), nil
+}
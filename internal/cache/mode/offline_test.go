@@ -0,0 +1,95 @@
+package mode_test
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestModes_PlanOffline(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("default paths differ by OS")
+	}
+
+	// The environment of the test does not move the default paths.
+	env := map[string]string{"XDG_CACHE_HOME": "/xdg", "XDG_DATA_HOME": ""}
+	for _, key := range []string{"GOCACHE", "GOMODCACHE", "GOPATH", "npm_config_cache", "PNPM_CONFIG_STORE_DIR", "NPM_CONFIG_STORE_DIR", "PNPM_HOME", "YARN_CACHE_FOLDER"} {
+		env[key] = ""
+	}
+
+	tests := []struct {
+		mode  string
+		files []string
+		env   map[string]string
+		want  []string
+	}{
+		{mode: "apt", want: []string{"/var/cache/apt//archives/"}},
+		{mode: "go", want: []string{"/xdg/go-build", "~/go/pkg/mod"}},
+		{mode: "go", env: map[string]string{"GOPATH": "/gopath:/other", "GOCACHE": "/gocache"}, want: []string{"/gocache", "/gopath/pkg/mod"}},
+		{mode: "npm", want: []string{"~/.npm"}},
+		{mode: "pnpm", want: []string{"~/.local/share/pnpm/store"}},
+		{mode: "pnpm", env: map[string]string{"PNPM_CONFIG_STORE_DIR": "/store"}, want: []string{"/store"}},
+		{mode: "uv", want: []string{"/xdg/uv"}},
+		{mode: "yarn", want: []string{"/xdg/yarn/v6"}},
+		{mode: "yarn", files: []string{".yarnrc.yml"}, want: []string{"~/.yarn/berry/cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			modes, err := mode.DefaultModes().Filter([]string{tt.mode})
+			require.NoError(t, err)
+
+			reqEnv := maps.Clone(env)
+			maps.Copy(reqEnv, tt.env)
+			plan, err := modes.Plan(t.Context(), mode.PlanRequest{
+				Offline: true,
+				Env:     reqEnv,
+				Exec: &mode.ExecutorMock{
+					LookPathFunc: func(file string) (string, error) {
+						return "/usr/bin/" + file, nil
+					},
+					StatFunc: func(name string) (os.FileInfo, error) {
+						for _, file := range tt.files {
+							if name == file {
+								return nil, nil
+							}
+						}
+						return nil, os.ErrNotExist
+					},
+					RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+						t.Fatalf("ran %s offline", cmd)
+						return nil, exec.ErrNotFound
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			result := plan.Results[tt.mode]
+			require.Equal(t, tt.want, result.MountPaths)
+			require.True(t, result.Assumed)
+			// Commands that were not run are checks that failed.
+			require.True(t, slices.ContainsFunc(result.Checks, func(c mode.DetectCheck) bool {
+				return c.Kind == mode.CheckCommand && !c.Found
+			}))
+		})
+	}
+
+	t.Run("modes that run no commands are not assumed", func(t *testing.T) {
+		modes := mode.Modes{&mode.ModeProviderMock{
+			NameFunc: func() string { return "static" },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{MountPaths: []string{"~/.static"}}, nil
+			},
+		}}
+		plan, err := modes.Plan(t.Context(), mode.PlanRequest{Offline: true})
+		require.NoError(t, err)
+		require.False(t, plan.Results["static"].Assumed)
+	})
+}
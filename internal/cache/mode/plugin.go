@@ -0,0 +1,230 @@
+package mode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	// PluginPrefix is the file name prefix of executables that provide
+	// additional cache modes, e.g. spacectl-cache-mode-bazel provides "bazel".
+	PluginPrefix = "spacectl-cache-mode-"
+
+	// PluginProtocolVersion is sent with every plugin request so plugins can
+	// reject requests they do not understand.
+	PluginProtocolVersion = 1
+
+	pluginXdgConfigHomeKey = "XDG_CONFIG_HOME"
+)
+
+// PluginDirs returns the directories searched for mode plugins, in order of
+// precedence: the spacectl plugin dir, followed by every PATH entry.
+func PluginDirs() []string {
+	var dirs []string
+	if configDir := ConfigDir(); configDir != "" {
+		dirs = append(dirs, filepath.Join(configDir, "plugins"))
+	}
+
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// ConfigDir returns the spacectl user config dir, $XDG_CONFIG_HOME/spacectl
+// or ~/.config/spacectl, or an empty string if neither can be determined.
+func ConfigDir() string {
+	if configHome := os.Getenv(pluginXdgConfigHomeKey); configHome != "" {
+		return filepath.Join(configHome, "spacectl")
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(homeDir, ".config", "spacectl")
+	}
+	return ""
+}
+
+// DiscoverPlugins returns a PluginProvider for every executable named
+// spacectl-cache-mode-<name> in dirs. When several dirs contain a plugin of
+// the same name, the first one wins, like command lookup on PATH.
+func DiscoverPlugins(dirs []string) Modes {
+	seen := make(map[string]bool)
+	var plugins Modes
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Debug("failed to read plugin dir", "dir", dir, "error", err)
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginModeName(entry.Name())
+			if !ok || seen[name] {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+
+			seen[name] = true
+			plugins = append(plugins, PluginProvider{ModeName: name, Path: path})
+		}
+	}
+
+	return plugins
+}
+
+// pluginModeName extracts the mode name from a plugin file name.
+func pluginModeName(fileName string) (string, bool) {
+	name, ok := strings.CutPrefix(fileName, PluginPrefix)
+	if !ok {
+		return "", false
+	}
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode()&0o111 != 0
+}
+
+// PluginProvider is a ModeProvider backed by an external executable.
+//
+// The executable is invoked as `<path> detect` or `<path> plan` with a JSON
+// request on stdin, and must write a JSON response to stdout:
+//
+//	detect: {"protocol_version":1}
+//	     -> {"detected":true}
+//	plan:   {"protocol_version":1,"cache_root":"...","enabled_modes":["..."]}
+//	     -> {"add_envs":{},"cache_dirs":[],"mount_paths":[],"remove_paths":[]}
+type PluginProvider struct {
+	ModeName string
+	Path     string
+}
+
+type pluginDetectRequest struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+type pluginDetectResponse struct {
+	Detected bool   `json:"detected"`
+	Reason   string `json:"reason"`
+}
+
+type pluginPlanRequest struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	CacheRoot       string   `json:"cache_root"`
+	EnabledModes    []string `json:"enabled_modes"`
+	// Offline asks the plugin not to run commands that may reach the
+	// network, and to assume default paths instead.
+	Offline bool `json:"offline,omitempty"`
+}
+
+type pluginPlanResponse struct {
+	AddEnvs     map[string]string `json:"add_envs"`
+	CacheDirs   []string          `json:"cache_dirs"`
+	MountPaths  []string          `json:"mount_paths"`
+	RemovePaths []string          `json:"remove_paths"`
+	KeyFiles    []string          `json:"key_files"`
+	Warnings    []string          `json:"warnings"`
+	Assumed     bool              `json:"assumed"`
+}
+
+func (p PluginProvider) Name() string {
+	return p.ModeName
+}
+
+func (p PluginProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	var resp pluginDetectResponse
+	if err := p.call(ctx, req.Exec, "detect", pluginDetectRequest{
+		ProtocolVersion: PluginProtocolVersion,
+	}, &resp); err != nil {
+		return DetectResult{}, err
+	}
+
+	result := DetectResult{Detected: resp.Detected, Details: resp.Reason}
+	if !resp.Detected {
+		result.Reason = ReasonNotUsed
+	}
+	return result, nil
+}
+
+func (p PluginProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	var resp pluginPlanResponse
+	if err := p.call(ctx, req.Exec, "plan", pluginPlanRequest{
+		ProtocolVersion: PluginProtocolVersion,
+		CacheRoot:       req.CacheRoot,
+		EnabledModes:    req.EnabledModes,
+		Offline:         req.Offline,
+	}, &resp); err != nil {
+		if errors.Is(err, ErrOffline) {
+			// Plugins are not run when planning statically.
+			return PlanResult{Assumed: true, Warnings: []string{"plugin not run, as it is a command"}}, nil
+		}
+		return PlanResult{}, err
+	}
+
+	return PlanResult{
+		AddEnvs:     resp.AddEnvs,
+		CacheDirs:   resp.CacheDirs,
+		MountPaths:  resp.MountPaths,
+		RemovePaths: resp.RemovePaths,
+		KeyFiles:    resp.KeyFiles,
+		Warnings:    resp.Warnings,
+		Assumed:     resp.Assumed,
+	}, nil
+}
+
+func (p PluginProvider) call(ctx context.Context, executor Executor, command string, req, resp any) error {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encode plugin %s request: %w", command, err)
+	}
+
+	output, err := executor.Run(ctx, CommandSpec{Name: p.Path, Args: []string{command}, Stdin: input})
+	if err != nil {
+		return fmt.Errorf("plugin %s %s: %w", p.Path, command, err)
+	}
+
+	if err := json.Unmarshal(output, resp); err != nil {
+		return fmt.Errorf("parse plugin %s %s output: %w", p.Path, command, err)
+	}
+
+	return nil
+}
+
+// WithPlugins returns modes extended by plugins. Plugins that share a name
+// with an existing mode are skipped, so built-in modes cannot be shadowed.
+func (modes Modes) WithPlugins(plugins Modes) Modes {
+	names := modes.Names()
+	merged := slices.Clone(modes)
+	for _, plugin := range plugins {
+		if slices.Contains(names, plugin.Name()) {
+			slog.Warn("ignoring plugin that shadows an existing mode", "mode", plugin.Name())
+			continue
+		}
+		merged = append(merged, plugin)
+	}
+	return merged
+}
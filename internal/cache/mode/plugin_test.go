@@ -0,0 +1,191 @@
+package mode_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestDiscoverPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin discovery relies on unix file modes")
+	}
+
+	writePlugin := func(t *testing.T, dir, name string, perm os.FileMode) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), perm))
+	}
+
+	t.Run("finds executables with the plugin prefix", func(t *testing.T) {
+		dir := t.TempDir()
+		writePlugin(t, dir, "spacectl-cache-mode-bazel", 0o755)
+		writePlugin(t, dir, "spacectl-cache-mode-notexec", 0o644)
+		writePlugin(t, dir, "other-tool", 0o755)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "spacectl-cache-mode-dir"), 0o755))
+
+		plugins := mode.DiscoverPlugins([]string{dir})
+		require.Equal(t, mode.Modes{
+			mode.PluginProvider{ModeName: "bazel", Path: filepath.Join(dir, "spacectl-cache-mode-bazel")},
+		}, plugins)
+	})
+
+	t.Run("first dir wins on name conflicts", func(t *testing.T) {
+		first := t.TempDir()
+		second := t.TempDir()
+		writePlugin(t, first, "spacectl-cache-mode-bazel", 0o755)
+		writePlugin(t, second, "spacectl-cache-mode-bazel", 0o755)
+		writePlugin(t, second, "spacectl-cache-mode-scons", 0o755)
+
+		plugins := mode.DiscoverPlugins([]string{first, "", filepath.Join(first, "missing"), second})
+		require.Equal(t, mode.Modes{
+			mode.PluginProvider{ModeName: "bazel", Path: filepath.Join(first, "spacectl-cache-mode-bazel")},
+			mode.PluginProvider{ModeName: "scons", Path: filepath.Join(second, "spacectl-cache-mode-scons")},
+		}, plugins)
+	})
+}
+
+func TestPluginDirs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join("opt", "config"))
+	t.Setenv("PATH", string(filepath.ListSeparator)+filepath.Join("usr", "bin"))
+
+	require.Equal(t, []string{
+		filepath.Join("opt", "config", "spacectl", "plugins"),
+		"",
+		filepath.Join("usr", "bin"),
+	}, mode.PluginDirs())
+}
+
+func TestModes_WithPlugins(t *testing.T) {
+	modes := mode.Modes{mode.AptProvider{}}.WithPlugins(mode.Modes{
+		mode.PluginProvider{ModeName: "apt", Path: "/plugins/spacectl-cache-mode-apt"},
+		mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"},
+	})
+
+	require.Equal(t, []string{"apt", "bazel"}, modes.Names())
+	require.Equal(t, mode.AptProvider{}, modes[0])
+}
+
+func TestPluginProvider_Detect(t *testing.T) {
+	t.Run("sends detect request", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					require.Equal(t, "/plugins/spacectl-cache-mode-bazel detect", cmd.String())
+
+					require.JSONEq(t, `{"protocol_version":1}`, string(cmd.Stdin))
+
+					return []byte(`{"detected":true}`), nil
+				},
+			},
+		}
+
+		p := mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("reports the plugin's reason", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"detected":false,"reason":"no WORKSPACE file"}`), nil
+				},
+			},
+		}
+
+		p := mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, mode.DetectResult{Reason: mode.ReasonNotUsed, Details: "no WORKSPACE file"}, detected)
+	})
+
+	t.Run("returns error when plugin fails", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return nil, fmt.Errorf("exit status 1")
+				},
+			},
+		}
+
+		p := mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}
+		_, err := p.Detect(t.Context(), req)
+		require.ErrorContains(t, err, "plugin /plugins/spacectl-cache-mode-bazel detect")
+	})
+
+	t.Run("returns error on invalid output", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("yes"), nil
+				},
+			},
+		}
+
+		p := mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}
+		_, err := p.Detect(t.Context(), req)
+		require.ErrorContains(t, err, "parse plugin")
+	})
+}
+
+func TestPluginProvider_Plan(t *testing.T) {
+	req := mode.PlanRequest{
+		CacheRoot:    "/cache",
+		EnabledModes: []string{"bazel", "go"},
+		Exec: &mode.ExecutorMock{
+			RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+				require.Equal(t, "/plugins/spacectl-cache-mode-bazel plan", cmd.String())
+
+				var input map[string]any
+				require.NoError(t, json.Unmarshal(cmd.Stdin, &input))
+				require.Equal(t, map[string]any{
+					"protocol_version": float64(1),
+					"cache_root":       "/cache",
+					"enabled_modes":    []any{"bazel", "go"},
+				}, input)
+
+				return []byte(`{
+					"add_envs": {"BAZEL_DISK_CACHE": "/cache/bazel-disk"},
+					"cache_dirs": ["bazel-disk"],
+					"mount_paths": ["~/.cache/bazel"],
+					"remove_paths": ["./bazel-out"]
+				}`), nil
+			},
+		},
+	}
+
+	p := mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}
+	result, err := p.Plan(t.Context(), req)
+	require.NoError(t, err)
+	require.Equal(t, mode.PlanResult{
+		AddEnvs:     map[string]string{"BAZEL_DISK_CACHE": "/cache/bazel-disk"},
+		CacheDirs:   []string{"bazel-disk"},
+		MountPaths:  []string{"~/.cache/bazel"},
+		RemovePaths: []string{"./bazel-out"},
+	}, result)
+}
+
+func TestPluginProvider_PlanOffline(t *testing.T) {
+	modes := mode.Modes{mode.PluginProvider{ModeName: "bazel", Path: "/plugins/spacectl-cache-mode-bazel"}}
+	plan, err := modes.Plan(t.Context(), mode.PlanRequest{
+		Offline: true,
+		Exec: &mode.ExecutorMock{
+			RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+				// Plugins are still run, and plan offline themselves.
+				require.Contains(t, string(cmd.Stdin), `"offline":true`)
+				return []byte(`{"mount_paths": ["~/.cache/bazel"], "assumed": true}`), nil
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, plan.Results["bazel"].Assumed)
+}
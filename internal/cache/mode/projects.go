@@ -0,0 +1,153 @@
+package mode
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxDepth is how many directories deep FindProjects looks by default.
+const DefaultMaxDepth = 3
+
+// skippedDirs are never walked into, whether or not git ignores them.
+var skippedDirs = []string{".git", "node_modules"}
+
+type FindProjectsRequest struct {
+	Exec Executor
+	// WorkDirs are the directories to walk. Defaults to the current
+	// directory.
+	WorkDirs []string
+	// MaxDepth is how many directories below each working directory are
+	// walked. Zero means DefaultMaxDepth.
+	MaxDepth int
+}
+
+// FindProjects walks the working directories for subprojects that use any
+// of the modes, e.g. the services of a monorepo, and returns their
+// directories, each working directory first. A directory is a project when
+// it holds a file that one of the modes looks for when detecting, e.g. a
+// lockfile. Directories that git ignores are skipped.
+func (modes Modes) FindProjects(ctx context.Context, req FindProjectsRequest) ([]string, error) {
+	if req.Exec == nil {
+		req.Exec = DefaultExecutor{}
+	}
+	if req.MaxDepth <= 0 {
+		req.MaxDepth = DefaultMaxDepth
+	}
+	workDirs := req.WorkDirs
+	if len(workDirs) == 0 {
+		workDirs = []string{""}
+	}
+
+	var projects []string
+	for _, workDir := range workDirs {
+		found, err := modes.findProjects(ctx, req.Exec, workDir, req.MaxDepth)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range found {
+			if !slices.Contains(projects, dir) {
+				projects = append(projects, dir)
+			}
+		}
+	}
+	return projects, nil
+}
+
+func (modes Modes) findProjects(ctx context.Context, executor Executor, root string, maxDepth int) ([]string, error) {
+	markers, err := modes.projectFiles(ctx, executor, root)
+	if err != nil {
+		return nil, err
+	}
+	ignored := gitIgnoredDirs(ctx, executor, root)
+
+	projects := []string{root}
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		entries, err := executor.ReadDir(cmp.Or(dir, "."))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return fmt.Errorf("read dir %s: %w", cmp.Or(dir, "."), err)
+		}
+
+		if dir != root && slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return slices.Contains(markers, e.Name()) }) {
+			projects = append(projects, dir)
+		}
+		if depth == maxDepth {
+			return nil
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || slices.Contains(skippedDirs, entry.Name()) {
+				continue
+			}
+			sub := filepath.Join(dir, entry.Name())
+			if ignored[filepath.ToSlash(sub)] {
+				continue
+			}
+			if err := walk(sub, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, 0); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// projectFiles returns the names of the files in a directory that the modes
+// check for when detecting. Files of modes whose tools are missing
+// are not checked, which is fine, as those modes are not detected anywhere.
+func (modes Modes) projectFiles(ctx context.Context, exec Executor, dir string) ([]string, error) {
+	results, err := modes.DetectAll(ctx, DetectRequest{Exec: exec, WorkDir: dir})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, result := range results {
+		for _, check := range result.Checks {
+			name := path.Clean(filepath.ToSlash(check.Name))
+			if check.Kind != CheckFile || name == "." || strings.ContainsAny(name, "/~*?[") {
+				continue
+			}
+			if !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
+}
+
+// gitIgnoredDirs returns the directories beneath dir that git ignores,
+// relative to the current directory with forward slashes. Outside a git
+// checkout, nothing is ignored.
+func gitIgnoredDirs(ctx context.Context, executor Executor, dir string) map[string]bool {
+	out, err := executor.Run(ctx, CommandSpec{
+		Name: "git",
+		Args: []string{"ls-files", "--others", "--ignored", "--exclude-standard", "--directory"},
+		Dir:  dir,
+	})
+	if err != nil {
+		return nil
+	}
+
+	ignored := make(map[string]bool)
+	for line := range strings.Lines(string(out)) {
+		if line = strings.TrimSpace(line); strings.HasSuffix(line, "/") {
+			ignored[filepath.ToSlash(filepath.Join(dir, line))] = true
+		}
+	}
+	return ignored
+}
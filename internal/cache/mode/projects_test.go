@@ -0,0 +1,66 @@
+package mode_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestFindProjects(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{
+		"go.mod",
+		"services/api/go.mod",
+		"services/api/internal/README.md",
+		"web/bun.lock",
+		"build/gen/go.mod",
+		"node_modules/pkg/bun.lock",
+		"a/b/c/d/go.mod",
+	} {
+		path := filepath.Join(root, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+	t.Chdir(root)
+
+	executor := &mode.ExecutorMock{
+		LookPathFunc: func(file string) (string, error) {
+			return "/usr/bin/" + file, nil
+		},
+		RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+			require.Equal(t, "git ls-files --others --ignored --exclude-standard --directory", cmd.String())
+			return []byte("build/\n"), nil
+		},
+		StatFunc:    mode.DefaultExecutor{}.Stat,
+		ReadDirFunc: mode.DefaultExecutor{}.ReadDir,
+	}
+	modes := mode.Modes{mode.GoProvider{}, mode.BunProvider{}}
+
+	t.Run("walks the working directory", func(t *testing.T) {
+		projects, err := modes.FindProjects(t.Context(), mode.FindProjectsRequest{Exec: executor})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"", filepath.Join("services", "api"), "web"}, projects)
+		require.Equal(t, "", projects[0])
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		projects, err := modes.FindProjects(t.Context(), mode.FindProjectsRequest{Exec: executor, MaxDepth: 4})
+		require.NoError(t, err)
+		require.Contains(t, projects, filepath.Join("a", "b", "c", "d"))
+
+		projects, err = modes.FindProjects(t.Context(), mode.FindProjectsRequest{Exec: executor, MaxDepth: 1})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"", "web"}, projects)
+	})
+
+	t.Run("walks other working directories", func(t *testing.T) {
+		projects, err := modes.FindProjects(t.Context(), mode.FindProjectsRequest{Exec: executor, WorkDirs: []string{"services"}})
+		require.NoError(t, err)
+		require.Equal(t, []string{"services", filepath.Join("services", "api")}, projects)
+	})
+}
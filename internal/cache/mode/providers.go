@@ -3,19 +3,20 @@ package mode
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 
 	"golang.org/x/mod/semver"
@@ -30,6 +31,14 @@ const (
 	aptDirEtcPartsKey      = "Dir::Etc::parts"
 )
 
+// aptDefaultConfig is the part of `apt-config dump` that is assumed offline,
+// apt's defaults.
+const aptDefaultConfig = `Dir::Cache "var/cache/apt/";
+Dir::Cache::archives "archives/";
+Dir::Etc "etc/apt/";
+Dir::Etc::parts "apt.conf.d";
+`
+
 var aptConfigRegex = regexp.MustCompile(`(.+)\s"(.*)";`)
 
 type AptProvider struct{}
@@ -38,19 +47,22 @@ func (p AptProvider) Name() string {
 	return "apt"
 }
 
-func (p AptProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p AptProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("apt-config"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath apt-config: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath apt-config: %w", err)
 	}
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p AptProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "apt-config", "dump")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "apt-config", Args: []string{"dump"}})
+	if errors.Is(err, ErrOffline) {
+		output, err = []byte(aptDefaultConfig), nil
+	}
 	if err != nil {
 		return PlanResult{}, err
 	}
@@ -107,9 +119,62 @@ func (p AptProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, err
 	return result, nil
 }
 
+// AsdfProvider
+
+const (
+	asdfDataDirKey   = "ASDF_DATA_DIR"
+	asdfDefaultPath  = "~/.asdf"
+	asdfToolVersions = ".tool-versions"
+)
+
+type AsdfProvider struct{}
+
+func (p AsdfProvider) Name() string {
+	return "asdf"
+}
+
+func (p AsdfProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("asdf"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath asdf: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(asdfToolVersions); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", asdfToolVersions, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mounts installed tool versions and their downloads, but not the whole
+// data dir, which may also be asdf's own checkout.
+func (p AsdfProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	dataDir := req.Getenv(asdfDataDirKey)
+	if dataDir == "" {
+		dataDir = asdfDefaultPath
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			filepath.Join(dataDir, "installs"),
+			filepath.Join(dataDir, "downloads"),
+		},
+	}, nil
+}
+
 // BrewProvider
 
-const brewfile = "Brewfile"
+const (
+	brewfile         = "Brewfile"
+	brewCacheKey     = "HOMEBREW_CACHE"
+	brewCacheDirName = "Homebrew"
+)
 
 type BrewProvider struct{}
 
@@ -117,27 +182,33 @@ func (p BrewProvider) Name() string {
 	return "brew"
 }
 
-func (p BrewProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p BrewProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("brew"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath brew: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath brew: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(brewfile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", brewfile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", brewfile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p BrewProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "brew", "--cache")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "brew", Args: []string{"--cache"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, HOMEBREW_CACHE or Homebrew in the user cache dir is assumed.
+		var cacheDir string
+		cacheDir, err = defaultCacheDir(req, brewCacheKey, brewCacheDirName)
+		output = []byte(cacheDir)
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("brew --cache: %w", err)
 	}
@@ -152,9 +223,55 @@ func (p BrewProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	}, nil
 }
 
+// Buck2Provider
+
+const (
+	buck2ConfigFile = ".buckconfig"
+	buck2OutPath    = "./buck-out"
+)
+
+type Buck2Provider struct{}
+
+func (p Buck2Provider) Name() string {
+	return "buck2"
+}
+
+// Detect looks for .buckconfig rather than BUCK files, since the former marks
+// the cell root that buck-out is created in.
+func (p Buck2Provider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("buck2"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath buck2: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(buck2ConfigFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", buck2ConfigFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p Buck2Provider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{buck2OutPath},
+	}, nil
+}
+
 // BunProvider
 
-const bunLockFile = "bun.lock"
+const (
+	bunLockFile       = "bun.lock"
+	bunBinaryLockFile = "bun.lockb"
+	bunCacheDirKey    = "BUN_INSTALL_CACHE_DIR"
+	bunInstallKey     = "BUN_INSTALL"
+	bunDefaultInstall = "~/.bun"
+)
 
 type BunProvider struct{}
 
@@ -162,27 +279,31 @@ func (p BunProvider) Name() string {
 	return "bun"
 }
 
-func (p BunProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p BunProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("bun"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath bun: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath bun: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(bunLockFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", bunLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", bunLockFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p BunProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "bun", "pm", "cache")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "bun", Args: []string{"pm", "cache"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, BUN_INSTALL_CACHE_DIR or install/cache in BUN_INSTALL is assumed.
+		output, err = []byte(cmp.Or(req.Getenv(bunCacheDirKey), filepath.Join(cmp.Or(req.Getenv(bunInstallKey), bunDefaultInstall), "install", "cache"))), nil
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("bun pm cache: %w", err)
 	}
@@ -194,14 +315,174 @@ func (p BunProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, err
 
 	return PlanResult{
 		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{bunLockFile, bunBinaryLockFile},
+	}, nil
+}
+
+// CarthageProvider
+
+const (
+	carthageCachePath = "~/Library/Caches/org.carthage.CarthageKit"
+	carthageBuildPath = "./Carthage/Build"
+	carthageCartfile  = "Cartfile"
+)
+
+type CarthageProvider struct{}
+
+func (p CarthageProvider) Name() string {
+	return "carthage"
+}
+
+func (p CarthageProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("carthage"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath carthage: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(carthageCartfile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", carthageCartfile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p CarthageProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{
+			carthageCachePath,
+			carthageBuildPath,
+		},
 	}, nil
 }
 
+// CMakeProvider
+
+const (
+	cmakeListsFile         = "CMakeLists.txt"
+	cmakePresetsFile       = "CMakePresets.json"
+	cmakeDefaultBuildDir   = "./build"
+	cmakeCPMSourceCacheKey = "CPM_SOURCE_CACHE"
+	cmakeCacheVolumeCPMDir = "cpm-sources"
+	cmakeSourceDirMacro    = "${sourceDir}"
+	cmakePresetNameMacro   = "${presetName}"
+)
+
+type CMakeProvider struct{}
+
+func (p CMakeProvider) Name() string {
+	return "cmake"
+}
+
+func (p CMakeProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("cmake"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath cmake: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(cmakeListsFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", cmakeListsFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p CMakeProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	buildDirs, warnings, err := cmakePresetBuildDirs(req.Exec)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	if len(buildDirs) == 0 {
+		buildDirs = []string{cmakeDefaultBuildDir}
+	}
+
+	result := PlanResult{
+		MountPaths: buildDirs,
+		Warnings:   warnings,
+	}
+
+	// CPM.cmake and FetchContent re-download sources into the build dir unless
+	// CPM_SOURCE_CACHE points them at a shared location.
+	if sourceCache := req.Getenv(cmakeCPMSourceCacheKey); sourceCache != "" {
+		result.MountPaths = append(result.MountPaths, sourceCache)
+	} else if req.CacheRoot != "" {
+		result.AddEnvs = map[string]string{
+			cmakeCPMSourceCacheKey: filepath.Join(req.CacheRoot, cmakeCacheVolumeCPMDir),
+		}
+		result.CacheDirs = []string{cmakeCacheVolumeCPMDir}
+	}
+
+	return result, nil
+}
+
+// cmakePresetBuildDirs returns the binary dirs of the configure presets in
+// CMakePresets.json. Presets using macros other than ${sourceDir} and
+// ${presetName} (such as $env{...}) are skipped, as resolving them requires
+// cmake itself, with a warning.
+func cmakePresetBuildDirs(executor Executor) ([]string, []string, error) {
+	data, err := executor.ReadFile(cmakePresetsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("read %s: %w", cmakePresetsFile, err)
+	}
+
+	var presets struct {
+		ConfigurePresets []struct {
+			Name      string `json:"name"`
+			Hidden    bool   `json:"hidden"`
+			BinaryDir string `json:"binaryDir"`
+		} `json:"configurePresets"`
+	}
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, []string{fmt.Sprintf("%s could not be parsed, using the default build dir: %v", cmakePresetsFile, err)}, nil
+	}
+
+	var buildDirs, warnings []string
+	for _, preset := range presets.ConfigurePresets {
+		if preset.Hidden || preset.BinaryDir == "" {
+			continue
+		}
+
+		dir := strings.ReplaceAll(preset.BinaryDir, cmakePresetNameMacro, preset.Name)
+		if rest, ok := strings.CutPrefix(dir, cmakeSourceDirMacro); ok {
+			dir = "./" + strings.TrimLeft(rest, "/")
+		} else if !filepath.IsAbs(dir) {
+			dir = "./" + dir
+		}
+		if strings.Contains(dir, "$") {
+			warnings = append(warnings, fmt.Sprintf("binary dir of preset %q needs cmake to resolve, not caching it", preset.Name))
+			continue
+		}
+
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "." || slices.Contains(buildDirs, dir) {
+			continue
+		}
+		buildDirs = append(buildDirs, dir)
+	}
+
+	return buildDirs, warnings, nil
+}
+
 // CocoapodsProvider
 
 const (
 	cocoapodsCachePath = "~/Library/Caches/CocoaPods"
 	cocoapodsPodfile   = "Podfile"
+	cocoapodsLockFile  = "Podfile.lock"
 )
 
 type CocoapodsProvider struct{}
@@ -210,22 +491,23 @@ func (p CocoapodsProvider) Name() string {
 	return "cocoapods"
 }
 
-func (p CocoapodsProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p CocoapodsProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("pod"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath pod: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath pod: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(cocoapodsPodfile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", cocoapodsPodfile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", cocoapodsPodfile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p CocoapodsProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
@@ -234,12 +516,17 @@ func (p CocoapodsProvider) Plan(ctx context.Context, req PlanRequest) (PlanResul
 			"./Pods",
 			cocoapodsCachePath,
 		},
+		KeyFiles: []string{cocoapodsLockFile},
 	}, nil
 }
 
 // ComposerProvider
 
-const composerJsonFile = "composer.json"
+const (
+	composerJsonFile = "composer.json"
+	composerLockFile = "composer.lock"
+	composerCacheKey = "COMPOSER_CACHE_DIR"
+)
 
 type ComposerProvider struct{}
 
@@ -247,27 +534,34 @@ func (p ComposerProvider) Name() string {
 	return "composer"
 }
 
-func (p ComposerProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p ComposerProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("composer"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath composer: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath composer: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(composerJsonFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", composerJsonFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", composerJsonFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p ComposerProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "composer", "config", "--global", "cache-files-dir")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "composer", Args: []string{"config", "--global", "cache-files-dir"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, files in COMPOSER_CACHE_DIR or in composer in the user
+		// cache dir are assumed.
+		var cacheDir string
+		cacheDir, err = defaultCacheDir(req, composerCacheKey, "composer")
+		output = []byte(filepath.Join(cacheDir, "files"))
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("composer config --global cache-files-dir: %w", err)
 	}
@@ -279,165 +573,660 @@ func (p ComposerProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult
 
 	return PlanResult{
 		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{composerLockFile},
 	}, nil
 }
 
-// DenoProvider
+// ConanProvider
 
 const (
-	denoDirKey   = "denoDir"
-	denoLockFile = "deno.lock"
+	conanHomeKey     = "CONAN_HOME"
+	conanHomeDirName = ".conan2"
 )
 
-type DenoProvider struct{}
+var conanProjectFiles = []string{
+	"conanfile.txt",
+	"conanfile.py",
+}
 
-func (p DenoProvider) Name() string {
-	return "deno"
+type ConanProvider struct{}
+
+func (p ConanProvider) Name() string {
+	return "conan"
 }
 
-func (p DenoProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("deno"); err != nil {
+func (p ConanProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("conan"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath deno: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath conan: %w", err)
 	}
 
-	if _, err := req.Exec.Stat(denoLockFile); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+	for _, projectFile := range conanProjectFiles {
+		if _, err := req.Exec.Stat(projectFile); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", projectFile, err)
 		}
-		return false, fmt.Errorf("stat %s: %w", denoLockFile, err)
 	}
 
-	return true, nil
+	return d.result(false), nil
 }
 
-func (p DenoProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "deno", "info", "--json")
-	output, err := req.Exec.Output(cmd)
-	if err != nil {
-		return PlanResult{}, fmt.Errorf("deno info --json: %w", err)
-	}
-
-	var denoInfo map[string]any
-	if err := json.Unmarshal(output, &denoInfo); err != nil {
-		return PlanResult{}, fmt.Errorf("parse deno info output: %w", err)
-	}
-
-	denoDir, ok := denoInfo[denoDirKey].(string)
-	if !ok || denoDir == "" {
-		return PlanResult{}, fmt.Errorf("denoDir not found in deno info output")
+func (p ConanProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	conanHome := req.Getenv(conanHomeKey)
+	if conanHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+		}
+		conanHome = filepath.Join(home, conanHomeDirName)
 	}
 
+	// Only the package cache is mounted: the rest of CONAN_HOME holds
+	// profiles and remotes that CI setup steps usually write.
 	return PlanResult{
-		MountPaths: []string{denoDir},
+		AddEnvs: map[string]string{
+			conanHomeKey: conanHome,
+		},
+		MountPaths: []string{filepath.Join(conanHome, "p")},
 	}, nil
 }
 
-// GoProvider
+// CorepackProvider
 
 const (
-	goCacheKey     = "GOCACHE"
-	goModeCacheKey = "GOMODCACHE"
-	goModFile      = "go.mod"
-	goWorkFile     = "go.work"
+	corepackHomeKey        = "COREPACK_HOME"
+	corepackXdgCacheKey    = "XDG_CACHE_HOME"
+	corepackLocalAppData   = "LOCALAPPDATA"
+	corepackDefaultPath    = "~/.cache/node/corepack"
+	corepackPackageJSON    = "package.json"
+	corepackPackageManager = "packageManager"
 )
 
-type GoProvider struct{}
+type CorepackProvider struct{}
 
-func (p GoProvider) Name() string {
-	return "go"
+func (p CorepackProvider) Name() string {
+	return "corepack"
 }
 
-func (p GoProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("go"); err != nil {
-		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+// Detect looks for a packageManager field in package.json, which is what
+// makes corepack download and pin a package manager release.
+func (p CorepackProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	data, err := req.Exec.ReadFile(corepackPackageJSON)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath go: %w", err)
+		return DetectResult{}, fmt.Errorf("read %s: %w", corepackPackageJSON, err)
 	}
 
-	if _, err := req.Exec.Stat(goModFile); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", goModFile, err)
+	var pkg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		d.warn("%s could not be parsed: %v", corepackPackageJSON, err)
+		return d.result(false), nil
 	}
 
-	if _, err := req.Exec.Stat(goWorkFile); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", goWorkFile, err)
+	var packageManager string
+	if err := json.Unmarshal(pkg[corepackPackageManager], &packageManager); err != nil {
+		return d.result(false), nil
 	}
 
-	return false, nil
+	return d.result(packageManager != ""), nil
 }
 
-func (p GoProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "go", "env", "-json", goCacheKey, goModeCacheKey)
-	output, err := req.Exec.Output(cmd)
-	if err != nil {
-		return PlanResult{}, fmt.Errorf("go env: %w", err)
+// Plan mounts corepack's install dir. Current corepack releases keep it in
+// node/corepack under the platform cache dir rather than ~/.cache/corepack.
+func (p CorepackProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	var mountTarget string
+	if home := req.Getenv(corepackHomeKey); home != "" {
+		mountTarget = home
+	} else if xdgCacheHome := req.Getenv(corepackXdgCacheKey); xdgCacheHome != "" {
+		mountTarget = filepath.Join(xdgCacheHome, "node", "corepack")
+	} else if runtime.GOOS == "windows" {
+		localAppData := req.Getenv(corepackLocalAppData)
+		if localAppData == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+			}
+			localAppData = filepath.Join(homeDir, "AppData", "Local")
+		}
+		mountTarget = filepath.Join(localAppData, "node", "corepack")
+	} else {
+		mountTarget = corepackDefaultPath
 	}
 
-	var goEnv map[string]string
-	if err := json.Unmarshal(output, &goEnv); err != nil {
-		return PlanResult{}, fmt.Errorf("parse go env output: %w", err)
-	}
+	return PlanResult{
+		MountPaths: []string{mountTarget},
+	}, nil
+}
 
-	if _, ok := goEnv[goCacheKey]; !ok {
-		return PlanResult{}, fmt.Errorf(goCacheKey + " not found in go env output")
+// CypressProvider
+
+const (
+	cypressCacheFolderKey   = "CYPRESS_CACHE_FOLDER"
+	cypressLocalAppDataKey  = "LOCALAPPDATA"
+	cypressDefaultCachePath = "~/.cache/Cypress"
+	cypressDarwinCachePath  = "~/Library/Caches/Cypress"
+	cypressConfigPrefix     = "cypress.config."
+)
+
+type CypressProvider struct{}
+
+func (p CypressProvider) Name() string {
+	return "cypress"
+}
+
+// Detect looks for cypress.config.* only, see detectNodeToolConfig.
+func (p CypressProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, cypressConfigPrefix)
+}
+
+func (p CypressProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	if cacheFolder := req.Getenv(cypressCacheFolderKey); cacheFolder != "" {
+		return PlanResult{
+			MountPaths: []string{cacheFolder},
+		}, nil
 	}
-	if _, ok := goEnv[goModeCacheKey]; !ok {
-		return PlanResult{}, fmt.Errorf(goModeCacheKey + " not found in go env output")
+
+	var mountTarget string
+	switch runtime.GOOS {
+	case "darwin":
+		mountTarget = cypressDarwinCachePath
+	case "windows":
+		localAppData := req.Getenv(cypressLocalAppDataKey)
+		if localAppData == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+			}
+			localAppData = filepath.Join(homeDir, "AppData", "Local")
+		}
+		mountTarget = filepath.Join(localAppData, "Cypress", "Cache")
+	default:
+		mountTarget = cypressDefaultCachePath
 	}
 
 	return PlanResult{
-		MountPaths: []string{goEnv[goCacheKey], goEnv[goModeCacheKey]},
+		MountPaths: []string{mountTarget},
 	}, nil
 }
 
-// GolangCILintProvider
+// DenoProvider
 
 const (
-	golangCILintCacheDirPrefix = "dir:"
-	golangCILintCacheDirName   = "golangci-lint"
-	golangCILintConfigYml      = ".golangci.yml"
-	golangCILintConfigYaml     = ".golangci.yaml"
+	denoDirKey      = "denoDir"
+	denoNpmCacheKey = "npmCache"
+	denoDirEnvKey   = "DENO_DIR"
+	denoLockFile    = "deno.lock"
+	denoVendorDir   = "./vendor"
 )
 
-type GolangCILintProvider struct{}
+// denoConfigFiles lists the config files deno picks up, in lookup order.
+var denoConfigFiles = []string{"deno.json", "deno.jsonc"}
 
-func (p GolangCILintProvider) Name() string {
-	return "golangci-lint"
+// denoVendorRegex matches `"vendor": true`. deno.jsonc may contain comments,
+// so the config is not parsed as JSON.
+var denoVendorRegex = regexp.MustCompile(`"vendor"\s*:\s*true\b`)
+
+type DenoProvider struct{}
+
+func (p DenoProvider) Name() string {
+	return "deno"
 }
 
-func (p GolangCILintProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("golangci-lint"); err != nil {
+func (p DenoProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("deno"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath golangci-lint: %w", err)
-	}
-
-	if _, err := req.Exec.Stat(golangCILintConfigYml); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", golangCILintConfigYml, err)
+		return DetectResult{}, fmt.Errorf("lookpath deno: %w", err)
 	}
 
-	if _, err := req.Exec.Stat(golangCILintConfigYaml); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", golangCILintConfigYaml, err)
+	if _, err := req.Exec.Stat(denoLockFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", denoLockFile, err)
 	}
 
-	return false, nil
+	return d.result(true), nil
 }
 
-func (p GolangCILintProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "golangci-lint", "cache", "status")
-	output, err := req.Exec.Output(cmd)
+func (p DenoProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "deno", Args: []string{"info", "--json"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, DENO_DIR or deno in the user cache dir is assumed, with
+		// the npm cache inside it.
+		denoDir, dirErr := defaultCacheDir(req, denoDirEnvKey, "deno")
+		if dirErr != nil {
+			return PlanResult{}, dirErr
+		}
+		output, err = json.Marshal(map[string]string{denoDirKey: denoDir})
+	}
+	if err != nil {
+		return PlanResult{}, fmt.Errorf("deno info --json: %w", err)
+	}
+
+	var denoInfo map[string]any
+	if err := json.Unmarshal(output, &denoInfo); err != nil {
+		return PlanResult{}, fmt.Errorf("parse deno info output: %w", err)
+	}
+
+	denoDir, ok := denoInfo[denoDirKey].(string)
+	if !ok || denoDir == "" {
+		return PlanResult{}, fmt.Errorf("denoDir not found in deno info output")
+	}
+
+	mountPaths := []string{denoDir}
+
+	// The npm cache lives inside denoDir by default, but can be moved with NPM_CONFIG_CACHE.
+	if npmCache, ok := denoInfo[denoNpmCacheKey].(string); ok && npmCache != "" && !isDescendant(npmCache, denoDir) {
+		mountPaths = append(mountPaths, npmCache)
+	}
+
+	vendor, err := denoVendorEnabled(req.Exec)
+	if err != nil {
+		return PlanResult{}, err
+	}
+	if vendor {
+		mountPaths = append(mountPaths, denoVendorDir)
+	}
+
+	return PlanResult{
+		MountPaths: mountPaths,
+		KeyFiles:   []string{denoLockFile},
+	}, nil
+}
+
+// denoVendorEnabled reports whether the first deno config file found sets
+// "vendor": true, in which case remote modules are kept in ./vendor.
+func denoVendorEnabled(executor Executor) (bool, error) {
+	for _, configFile := range denoConfigFiles {
+		data, err := executor.ReadFile(configFile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return false, fmt.Errorf("read %s: %w", configFile, err)
+		}
+		return denoVendorRegex.Match(data), nil
+	}
+
+	return false, nil
+}
+
+// ElixirProvider
+
+const (
+	elixirHexHomeKey     = "HEX_HOME"
+	elixirMixHomeKey     = "MIX_HOME"
+	elixirHexDefaultPath = "~/.hex"
+	elixirMixDefaultPath = "~/.mix"
+	elixirMixLockFile    = "mix.lock"
+)
+
+type ElixirProvider struct{}
+
+func (p ElixirProvider) Name() string {
+	return "elixir"
+}
+
+func (p ElixirProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("mix"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath mix: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(elixirMixLockFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", elixirMixLockFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p ElixirProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	hexHome := elixirHexDefaultPath
+	if dir := req.Getenv(elixirHexHomeKey); dir != "" {
+		hexHome = dir
+	}
+
+	mixHome := elixirMixDefaultPath
+	if dir := req.Getenv(elixirMixHomeKey); dir != "" {
+		mixHome = dir
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			hexHome,
+			mixHome,
+			"./_build", // Compiled dependencies and project artifacts
+			"./deps",   // Output of `mix deps.get`
+		},
+		KeyFiles: []string{elixirMixLockFile},
+	}, nil
+}
+
+// FastlaneProvider
+
+const (
+	fastlaneCachePath = "~/.fastlane"
+	fastlaneGemHome   = "GEM_HOME"
+	// fastlaneDefaultGemHome is the gem home assumed offline. Its exact
+	// location does not matter, as GEM_HOME is pointed at it.
+	fastlaneDefaultGemHome = "~/.local/share/gem"
+)
+
+// fastlaneFastfiles lists the locations fastlane looks up its Fastfile in.
+var fastlaneFastfiles = []string{"fastlane/Fastfile", "Fastfile"}
+
+type FastlaneProvider struct{}
+
+func (p FastlaneProvider) Name() string {
+	return "fastlane"
+}
+
+func (p FastlaneProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	// fastlane is commonly pinned in the Gemfile and run with `bundle exec`,
+	// so it may not be on PATH itself.
+	found := false
+	for _, bin := range []string{"fastlane", "bundle"} {
+		if _, err := req.Exec.LookPath(bin); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				continue
+			}
+			return DetectResult{}, fmt.Errorf("lookpath %s: %w", bin, err)
+		}
+		found = true
+		break
+	}
+	if !found {
+		return d.result(false), nil
+	}
+
+	for _, file := range fastlaneFastfiles {
+		if _, err := req.Exec.Stat(file); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return DetectResult{}, fmt.Errorf("stat %s: %w", file, err)
+		}
+		return d.result(true), nil
+	}
+
+	return d.result(false), nil
+}
+
+func (p FastlaneProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	result := PlanResult{
+		MountPaths: []string{fastlaneCachePath},
+	}
+
+	gemHome := req.Getenv(fastlaneGemHome)
+	if gemHome == "" {
+		if _, err := req.Exec.LookPath("gem"); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return result, nil
+			}
+			return PlanResult{}, fmt.Errorf("lookpath gem: %w", err)
+		}
+
+		// The system gem dir is usually root owned, so install into the
+		// per-user gem home instead and point GEM_HOME at it.
+		output, err := req.Exec.Run(ctx, CommandSpec{Name: "gem", Args: []string{"env", "user_gemhome"}})
+		if errors.Is(err, ErrOffline) {
+			output, err = []byte(fastlaneDefaultGemHome), nil
+		}
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("gem env user_gemhome: %w", err)
+		}
+
+		gemHome = strings.TrimSpace(string(output))
+		if gemHome == "" {
+			return PlanResult{}, fmt.Errorf("empty gem home from gem env user_gemhome")
+		}
+	}
+
+	result.AddEnvs = map[string]string{fastlaneGemHome: gemHome}
+	result.MountPaths = append(result.MountPaths, gemHome)
+	return result, nil
+}
+
+// FoundryProvider
+
+const (
+	foundryConfigFile   = "foundry.toml"
+	foundryCachePath    = "~/.foundry/cache"
+	foundryCacheDirKey  = "FOUNDRY_CACHE_PATH"
+	foundryOutDirKey    = "FOUNDRY_OUT"
+	foundryDefaultCache = "./cache"
+	foundryDefaultOut   = "./out"
+)
+
+type FoundryProvider struct{}
+
+func (p FoundryProvider) Name() string {
+	return "foundry"
+}
+
+func (p FoundryProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("forge"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath forge: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(foundryConfigFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", foundryConfigFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p FoundryProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	cacheDir := req.Getenv(foundryCacheDirKey)
+	if cacheDir == "" {
+		cacheDir = foundryDefaultCache
+	}
+
+	outDir := req.Getenv(foundryOutDirKey)
+	if outDir == "" {
+		outDir = foundryDefaultOut
+	}
+
+	// Do not cache the whole ~/.foundry dir as it contains bin/, where forge lives.
+	return PlanResult{
+		MountPaths: []string{
+			foundryCachePath, // RPC and block explorer responses
+			cacheDir,
+			outDir,
+		},
+	}, nil
+}
+
+// GoProvider
+
+const (
+	goCacheKey     = "GOCACHE"
+	goModeCacheKey = "GOMODCACHE"
+	goPathKey      = "GOPATH"
+	goDefaultPath  = "~/go"
+	goModFile      = "go.mod"
+	goWorkFile     = "go.work"
+	goSumFile      = "go.sum"
+	goWorkSumFile  = "go.work.sum"
+)
+
+type GoProvider struct{}
+
+func (p GoProvider) Name() string {
+	return "go"
+}
+
+func (p GoProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("go"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath go: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(goModFile); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", goModFile, err)
+	}
+
+	if _, err := req.Exec.Stat(goWorkFile); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", goWorkFile, err)
+	}
+
+	return d.result(false), nil
+}
+
+func (p GoProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "go", Args: []string{"env", "-json", goCacheKey, goModeCacheKey}})
+	if errors.Is(err, ErrOffline) {
+		output, err = goDefaultEnv(req)
+	}
+	if err != nil {
+		return PlanResult{}, fmt.Errorf("go env: %w", err)
+	}
+
+	var goEnv map[string]string
+	if err := json.Unmarshal(output, &goEnv); err != nil {
+		return PlanResult{}, fmt.Errorf("parse go env output: %w", err)
+	}
+
+	if _, ok := goEnv[goCacheKey]; !ok {
+		return PlanResult{}, fmt.Errorf(goCacheKey + " not found in go env output")
+	}
+	if _, ok := goEnv[goModeCacheKey]; !ok {
+		return PlanResult{}, fmt.Errorf(goModeCacheKey + " not found in go env output")
+	}
+
+	return PlanResult{
+		MountPaths: []string{goEnv[goCacheKey], goEnv[goModeCacheKey]},
+		KeyFiles:   []string{goSumFile, goWorkSumFile},
+	}, nil
+}
+
+// goDefaultEnv is the output of `go env -json GOCACHE GOMODCACHE` assumed
+// offline: the variables if set, or go-build in the user cache dir and
+// pkg/mod in the first GOPATH entry, ~/go by default.
+func goDefaultEnv(req PlanRequest) ([]byte, error) {
+	goCache, err := defaultCacheDir(req, goCacheKey, "go-build")
+	if err != nil {
+		return nil, err
+	}
+
+	goModCache := req.Getenv(goModeCacheKey)
+	if goModCache == "" {
+		goPath := goDefaultPath
+		if list := filepath.SplitList(req.Getenv(goPathKey)); len(list) > 0 && list[0] != "" {
+			goPath = list[0]
+		}
+		goModCache = filepath.Join(goPath, "pkg", "mod")
+	}
+
+	return json.Marshal(map[string]string{goCacheKey: goCache, goModeCacheKey: goModCache})
+}
+
+// Trim expires cached test results, so that tests are rerun by each job.
+// Go trims unused build cache entries by itself.
+func (p GoProvider) Trim(ctx context.Context, req TrimRequest) error {
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "go", Args: []string{"clean", "-testcache"}}); err != nil {
+		return fmt.Errorf("go clean -testcache: %w", err)
+	}
+	return nil
+}
+
+// Warm downloads the modules of the module or workspace.
+func (p GoProvider) Warm(ctx context.Context, req WarmRequest) error {
+	mod, err := hasFile(req.Exec, goModFile)
+	if err != nil {
+		return err
+	}
+	work, err := hasFile(req.Exec, goWorkFile)
+	if err != nil || !mod && !work {
+		return err
+	}
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "go", Args: []string{"mod", "download"}}); err != nil {
+		return fmt.Errorf("go mod download: %w", err)
+	}
+	return nil
+}
+
+// GolangCILintProvider
+
+const (
+	golangCILintCacheDirPrefix = "dir:"
+	golangCILintCacheDirName   = "golangci-lint"
+	golangCILintCacheKey       = "GOLANGCI_LINT_CACHE"
+	golangCILintConfigYml      = ".golangci.yml"
+	golangCILintConfigYaml     = ".golangci.yaml"
+)
+
+type GolangCILintProvider struct{}
+
+func (p GolangCILintProvider) Name() string {
+	return "golangci-lint"
+}
+
+func (p GolangCILintProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("golangci-lint"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath golangci-lint: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(golangCILintConfigYml); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", golangCILintConfigYml, err)
+	}
+
+	if _, err := req.Exec.Stat(golangCILintConfigYaml); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", golangCILintConfigYaml, err)
+	}
+
+	return d.result(false), nil
+}
+
+func (p GolangCILintProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "golangci-lint", Args: []string{"cache", "status"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, GOLANGCI_LINT_CACHE or the default location below is
+		// assumed.
+		output, err = nil, nil
+		if dir := req.Getenv(golangCILintCacheKey); dir != "" {
+			output = []byte(golangCILintCacheDirPrefix + " " + dir)
+		}
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("golangci-lint cache status: %w", err)
 	}
@@ -451,69 +1240,461 @@ func (p GolangCILintProvider) Plan(ctx context.Context, req PlanRequest) (PlanRe
 			break
 		}
 	}
-	if scanner.Err() != nil {
-		return PlanResult{}, fmt.Errorf("scanning golangci-lint output: %w", scanner.Err())
+	if scanner.Err() != nil {
+		return PlanResult{}, fmt.Errorf("scanning golangci-lint output: %w", scanner.Err())
+	}
+
+	// Fall back to golangci-lint's own default location, which is derived from
+	// the OS user cache dir (e.g. ~/.cache on Linux, %LocalAppData% on Windows).
+	if cacheDir == "" {
+		cacheHome, err := os.UserCacheDir()
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("get user cache dir: %w", err)
+		}
+		cacheDir = filepath.Join(cacheHome, golangCILintCacheDirName)
+	}
+
+	return PlanResult{
+		MountPaths: []string{cacheDir},
+	}, nil
+}
+
+// GoreleaserProvider
+
+const (
+	goreleaserXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	goreleaserDefaultCachePath = "~/.cache/goreleaser"
+	goreleaserGoBinKey         = "GOBIN"
+	goreleaserGoPathKey        = "GOPATH"
+)
+
+var goreleaserConfigFiles = []string{
+	".goreleaser.yaml",
+	".goreleaser.yml",
+	"goreleaser.yaml",
+	"goreleaser.yml",
+}
+
+type GoreleaserProvider struct{}
+
+func (p GoreleaserProvider) Name() string {
+	return "goreleaser"
+}
+
+func (p GoreleaserProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("goreleaser"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath goreleaser: %w", err)
+	}
+
+	for _, configFile := range goreleaserConfigFiles {
+		if _, err := req.Exec.Stat(configFile); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", configFile, err)
+		}
+	}
+
+	return d.result(false), nil
+}
+
+// Plan mounts goreleaser's cache dir and, when it is still empty, the go
+// tool bin dir so tools installed with `go install` survive between runs.
+// The dist dir is not cached: goreleaser refuses to run with a non-empty dist
+// unless --clean is passed, which then deletes it anyway.
+func (p GoreleaserProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	cacheDir := goreleaserDefaultCachePath
+	if xdgCacheHome := req.Getenv(goreleaserXdgCacheHomeKey); xdgCacheHome != "" {
+		cacheDir = filepath.Join(xdgCacheHome, "goreleaser")
+	}
+
+	result := PlanResult{
+		MountPaths: []string{cacheDir},
+	}
+
+	if _, err := req.Exec.LookPath("go"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return result, nil
+		}
+		return PlanResult{}, fmt.Errorf("lookpath go: %w", err)
+	}
+
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "go", Args: []string{"env", "-json", goreleaserGoBinKey, goreleaserGoPathKey}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, GOBIN or bin in GOPATH, ~/go by default, is assumed.
+		output, err = json.Marshal(map[string]string{
+			goreleaserGoBinKey:  req.Getenv(goreleaserGoBinKey),
+			goreleaserGoPathKey: cmp.Or(req.Getenv(goreleaserGoPathKey), goDefaultPath),
+		})
+	}
+	if err != nil {
+		return PlanResult{}, fmt.Errorf("go env: %w", err)
+	}
+
+	var goEnv map[string]string
+	if err := json.Unmarshal(output, &goEnv); err != nil {
+		return PlanResult{}, fmt.Errorf("parse go env output: %w", err)
+	}
+
+	goBin := goEnv[goreleaserGoBinKey]
+	if goBin == "" {
+		goPath := filepath.SplitList(goEnv[goreleaserGoPathKey])
+		if len(goPath) == 0 || goPath[0] == "" {
+			return result, nil
+		}
+		goBin = filepath.Join(goPath[0], "bin")
+	}
+
+	// Mounting over a populated bin dir would hide the tools already in it.
+	entries, err := req.Exec.ReadDir(goBin)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return PlanResult{}, fmt.Errorf("readdir %s: %w", goBin, err)
+	}
+	if len(entries) == 0 {
+		result.MountPaths = append(result.MountPaths, goBin)
+	}
+
+	return result, nil
+}
+
+// GradleProvider
+
+const (
+	gradleCachesPath             = "~/.gradle/caches"
+	gradleWrapperPath            = "~/.gradle/wrapper"
+	gradleConfigurationCachePath = "./.gradle/configuration-cache"
+	gradlewFile                  = "gradlew"
+	buildGradleFile              = "build.gradle"
+)
+
+type GradleProvider struct{}
+
+func (p GradleProvider) Name() string {
+	return "gradle"
+}
+
+func (p GradleProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("gradle"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath gradle: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(gradlewFile); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", gradlewFile, err)
+	}
+
+	if _, err := req.Exec.Stat(buildGradleFile); err == nil {
+		return d.result(true), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return DetectResult{}, fmt.Errorf("stat %s: %w", buildGradleFile, err)
+	}
+
+	return d.result(false), nil
+}
+
+// Plan mounts the caches dir, which holds the local build cache as well, so
+// the build cache is cached whether or not org.gradle.caching is on.
+func (p GradleProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{
+			gradleCachesPath,
+			gradleWrapperPath,
+			gradleConfigurationCachePath,
+		},
+	}, nil
+}
+
+// HardhatProvider
+
+const (
+	hardhatConfigPrefix     = "hardhat.config."
+	hardhatCachePath        = "./cache"
+	hardhatArtifactsPath    = "./artifacts"
+	hardhatXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	hardhatLocalAppDataKey  = "LOCALAPPDATA"
+	hardhatDefaultCompilers = "~/.cache/hardhat-nodejs"
+	hardhatDarwinCompilers  = "~/Library/Caches/hardhat-nodejs"
+)
+
+type HardhatProvider struct{}
+
+func (p HardhatProvider) Name() string {
+	return "hardhat"
+}
+
+// Detect looks for hardhat.config.* only, see detectNodeToolConfig.
+func (p HardhatProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, hardhatConfigPrefix)
+}
+
+func (p HardhatProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	// Downloaded solc compilers live in hardhat's global cache dir.
+	var compilersDir string
+	switch runtime.GOOS {
+	case "darwin":
+		compilersDir = hardhatDarwinCompilers
+	case "windows":
+		localAppData := req.Getenv(hardhatLocalAppDataKey)
+		if localAppData == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+			}
+			localAppData = filepath.Join(homeDir, "AppData", "Local")
+		}
+		compilersDir = filepath.Join(localAppData, "hardhat-nodejs", "Cache")
+	default:
+		if xdgCacheHome := req.Getenv(hardhatXdgCacheHomeKey); xdgCacheHome != "" {
+			compilersDir = filepath.Join(xdgCacheHome, "hardhat-nodejs")
+		} else {
+			compilersDir = hardhatDefaultCompilers
+		}
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			hardhatCachePath,
+			hardhatArtifactsPath,
+			compilersDir,
+		},
+	}, nil
+}
+
+// HaskellProvider
+
+const (
+	haskellStackRootKey     = "STACK_ROOT"
+	haskellCabalDirKey      = "CABAL_DIR"
+	haskellStackDefaultPath = "~/.stack"
+	haskellCabalDefaultPath = "~/.cabal"
+	haskellStackYamlFile    = "stack.yaml"
+	haskellCabalProjectFile = "cabal.project"
+	haskellCabalSuffix      = ".cabal"
+)
+
+type HaskellProvider struct{}
+
+func (p HaskellProvider) Name() string {
+	return "haskell"
+}
+
+func (p HaskellProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("stack"); err == nil {
+		if _, err := req.Exec.Stat(haskellStackYamlFile); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", haskellStackYamlFile, err)
+		}
+	} else if !errors.Is(err, exec.ErrNotFound) {
+		return DetectResult{}, fmt.Errorf("lookpath stack: %w", err)
+	}
+
+	if _, err := req.Exec.LookPath("cabal"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath cabal: %w", err)
+	}
+
+	entries, err := req.Exec.ReadDir(".")
+	if err != nil {
+		return DetectResult{}, fmt.Errorf("readdir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == haskellCabalProjectFile || strings.HasSuffix(entry.Name(), haskellCabalSuffix) {
+			return d.result(true), nil
+		}
+	}
+
+	return d.result(false), nil
+}
+
+func (p HaskellProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	// Stack projects also ship .cabal files, but stack keeps its own package
+	// store and never touches ~/.cabal, so only mount what the build tool uses.
+	if _, err := req.Exec.Stat(haskellStackYamlFile); err == nil {
+		stackRoot := haskellStackDefaultPath
+		if dir := req.Getenv(haskellStackRootKey); dir != "" {
+			stackRoot = dir
+		}
+
+		return PlanResult{
+			MountPaths: []string{
+				stackRoot,
+				"./.stack-work",
+			},
+		}, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return PlanResult{}, fmt.Errorf("stat %s: %w", haskellStackYamlFile, err)
+	}
+
+	cabalDir := haskellCabalDefaultPath
+	if dir := req.Getenv(haskellCabalDirKey); dir != "" {
+		cabalDir = dir
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			cabalDir + "/store",
+		},
+	}, nil
+}
+
+// HelmProvider
+
+const (
+	helmCacheHomeKey     = "HELM_CACHE_HOME"
+	helmXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	helmDefaultCachePath = "~/.cache/helm"
+	helmDarwinCachePath  = "~/Library/Caches/helm"
+	helmChartFile        = "Chart.yaml"
+	helmChartLockFile    = "Chart.lock"
+)
+
+type HelmProvider struct{}
+
+func (p HelmProvider) Name() string {
+	return "helm"
+}
+
+func (p HelmProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("helm"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath helm: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(helmChartFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", helmChartFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mounts helm's cache home, which holds both the repository indexes and
+// downloaded chart archives.
+func (p HelmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	if cacheHome := req.Getenv(helmCacheHomeKey); cacheHome != "" {
+		return PlanResult{
+			MountPaths: []string{cacheHome},
+			KeyFiles:   []string{helmChartLockFile},
+		}, nil
 	}
 
-	// Fall back to golangci-lint's own default location, which is derived from
-	// the OS user cache dir (e.g. ~/.cache on Linux, %LocalAppData% on Windows).
-	if cacheDir == "" {
-		cacheHome, err := os.UserCacheDir()
-		if err != nil {
-			return PlanResult{}, fmt.Errorf("get user cache dir: %w", err)
+	var mountTarget string
+	switch runtime.GOOS {
+	case "darwin":
+		mountTarget = helmDarwinCachePath
+	case "windows":
+		mountTarget = filepath.Join(os.TempDir(), "helm")
+	default:
+		if xdgCacheHome := req.Getenv(helmXdgCacheHomeKey); xdgCacheHome != "" {
+			mountTarget = filepath.Join(xdgCacheHome, "helm")
+		} else {
+			mountTarget = helmDefaultCachePath
 		}
-		cacheDir = filepath.Join(cacheHome, golangCILintCacheDirName)
 	}
 
 	return PlanResult{
-		MountPaths: []string{cacheDir},
+		MountPaths: []string{mountTarget},
+		KeyFiles:   []string{helmChartLockFile},
 	}, nil
 }
 
-// GradleProvider
+// JestProvider
 
 const (
-	gradleCachesPath  = "~/.gradle/caches"
-	gradleWrapperPath = "~/.gradle/wrapper"
-	gradlewFile       = "gradlew"
-	buildGradleFile   = "build.gradle"
+	jestConfigPrefix = "jest.config."
+	jestCacheDirName = "jest_"
 )
 
-type GradleProvider struct{}
+type JestProvider struct{}
 
-func (p GradleProvider) Name() string {
-	return "gradle"
+func (p JestProvider) Name() string {
+	return "jest"
 }
 
-func (p GradleProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("gradle"); err != nil {
-		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
-		}
-		return false, fmt.Errorf("lookpath gradle: %w", err)
+// Detect looks for jest.config.* only, see detectNodeToolConfig.
+func (p JestProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, jestConfigPrefix)
+}
+
+// Plan mirrors jest's default cacheDirectory, <tmpdir>/jest_<uid in base 36>.
+// A cacheDirectory set in the jest config is not picked up.
+func (p JestProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	uid := "0" // Node has no getuid on Windows
+	if runtime.GOOS != "windows" {
+		uid = strconv.FormatInt(int64(os.Getuid()), 36)
 	}
 
-	if _, err := req.Exec.Stat(gradlewFile); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", gradlewFile, err)
+	return PlanResult{
+		MountPaths: []string{filepath.Join(os.TempDir(), jestCacheDirName+uid)},
+	}, nil
+}
+
+// JuliaProvider
+
+const (
+	juliaDepotPathKey     = "JULIA_DEPOT_PATH"
+	juliaDefaultDepotPath = "~/.julia"
+)
+
+var juliaProjectFiles = []string{
+	"Project.toml",
+	"Manifest.toml",
+}
+
+type JuliaProvider struct{}
+
+func (p JuliaProvider) Name() string {
+	return "julia"
+}
+
+func (p JuliaProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("julia"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath julia: %w", err)
 	}
 
-	if _, err := req.Exec.Stat(buildGradleFile); err == nil {
-		return true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
-		return false, fmt.Errorf("stat %s: %w", buildGradleFile, err)
+	for _, projectFile := range juliaProjectFiles {
+		if _, err := req.Exec.Stat(projectFile); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", projectFile, err)
+		}
 	}
 
-	return false, nil
+	return d.result(false), nil
 }
 
-func (p GradleProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+func (p JuliaProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	// JULIA_DEPOT_PATH is a list; Julia writes packages, artifacts and
+	// compiled files to the first entry. An empty entry stands for the default.
+	mountTarget := juliaDefaultDepotPath
+	if depots := filepath.SplitList(req.Getenv(juliaDepotPathKey)); len(depots) > 0 && depots[0] != "" {
+		mountTarget = depots[0]
+	}
+
 	return PlanResult{
-		MountPaths: []string{
-			gradleCachesPath,
-			gradleWrapperPath,
-		},
+		MountPaths: []string{mountTarget},
 	}, nil
 }
 
@@ -530,21 +1711,22 @@ func (p KotlinNativeProvider) Name() string {
 	return "kotlin-native"
 }
 
-func (p KotlinNativeProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p KotlinNativeProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	for _, bin := range []string{"kotlinc-native", "konanc"} {
 		if _, err := req.Exec.LookPath(bin); err == nil {
-			return true, nil
+			return d.result(true), nil
 		} else if !errors.Is(err, exec.ErrNotFound) {
-			return false, fmt.Errorf("lookpath %s: %w", bin, err)
+			return DetectResult{}, fmt.Errorf("lookpath %s: %w", bin, err)
 		}
 	}
 
-	return false, nil
+	return d.result(false), nil
 }
 
 func (p KotlinNativeProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
 	mountTarget := kotlinNativeDefaultPath
-	if dir := os.Getenv(kotlinNativeDataDirKey); dir != "" {
+	if dir := req.Getenv(kotlinNativeDataDirKey); dir != "" {
 		mountTarget = dir
 	}
 
@@ -553,6 +1735,31 @@ func (p KotlinNativeProvider) Plan(ctx context.Context, req PlanRequest) (PlanRe
 	}, nil
 }
 
+// LernaProvider
+
+const lernaJsonFile = "lerna.json"
+
+type LernaProvider struct{}
+
+func (p LernaProvider) Name() string {
+	return "lerna"
+}
+
+// Detect only looks for lerna.json, see detectNodeToolConfig.
+func (p LernaProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, lernaJsonFile)
+}
+
+// ConflictsWith skips lerna when nx is enabled: Lerna 6+ runs tasks through
+// Nx, so `nx` mode already mounts the same cache.
+func (p LernaProvider) ConflictsWith() []string {
+	return []string{(NxProvider{}).Name()}
+}
+
+func (p LernaProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return NxProvider{}.Plan(ctx, req)
+}
+
 // MavenProvider
 
 const (
@@ -566,30 +1773,43 @@ func (p MavenProvider) Name() string {
 	return "maven"
 }
 
-func (p MavenProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p MavenProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("mvn"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath mvn: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath mvn: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(mavenPomFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", mavenPomFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", mavenPomFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p MavenProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
 	return PlanResult{
 		MountPaths: []string{mavenRepositoryPath},
+		KeyFiles:   []string{mavenPomFile},
 	}, nil
 }
 
+// Warm downloads the plugins and dependencies of the project.
+func (p MavenProvider) Warm(ctx context.Context, req WarmRequest) error {
+	if ok, err := hasFile(req.Exec, mavenPomFile); !ok {
+		return err
+	}
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "mvn", Args: []string{"--batch-mode", "dependency:go-offline"}}); err != nil {
+		return fmt.Errorf("mvn dependency:go-offline: %w", err)
+	}
+	return nil
+}
+
 // MiseProvider
 
 const (
@@ -615,43 +1835,348 @@ func (p MiseProvider) Name() string {
 	return "mise"
 }
 
-func (p MiseProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p MiseProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("mise"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath mise: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath mise: %w", err)
 	}
 
 	for _, configFile := range miseConfigFiles {
 		if _, err := req.Exec.Stat(configFile); err == nil {
-			return true, nil
+			return d.result(true), nil
 		} else if !errors.Is(err, os.ErrNotExist) {
-			return false, fmt.Errorf("stat %s: %w", configFile, err)
+			return DetectResult{}, fmt.Errorf("stat %s: %w", configFile, err)
 		}
 	}
 
-	return false, nil
+	return d.result(false), nil
 }
 
 func (p MiseProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
 	var mountTarget string
-	if dir := os.Getenv(miseDataDirKey); dir != "" {
+	if dir := req.Getenv(miseDataDirKey); dir != "" {
 		mountTarget = dir
-	} else if xdgDataHome := os.Getenv(miseXdgDataHomeKey); xdgDataHome != "" {
+	} else if xdgDataHome := req.Getenv(miseXdgDataHomeKey); xdgDataHome != "" {
 		mountTarget = filepath.Join(xdgDataHome, miseDefaultPath)
 	} else if runtime.GOOS == "windows" {
-		if localAppData := os.Getenv(miseLocalAppDataKey); localAppData != "" {
+		if localAppData := req.Getenv(miseLocalAppDataKey); localAppData != "" {
 			mountTarget = filepath.Join(localAppData, miseDefaultPath)
 		}
 	}
 
-	if mountTarget == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+	if mountTarget == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+		}
+		mountTarget = filepath.Join(homeDir, ".local", "share", miseDefaultPath)
+	}
+
+	return PlanResult{
+		MountPaths: []string{mountTarget},
+	}, nil
+}
+
+// NixProvider
+
+const nixCachePath = "~/.cache/nix"
+
+var nixProjectFiles = []string{
+	"flake.nix",
+	"shell.nix",
+	"default.nix",
+}
+
+type NixProvider struct{}
+
+func (p NixProvider) Name() string {
+	return "nix"
+}
+
+func (p NixProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("nix"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath nix: %w", err)
+	}
+
+	for _, projectFile := range nixProjectFiles {
+		if _, err := req.Exec.Stat(projectFile); err == nil {
+			return d.result(true), nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return DetectResult{}, fmt.Errorf("stat %s: %w", projectFile, err)
+		}
+	}
+
+	return d.result(false), nil
+}
+
+func (p NixProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{
+			nixCachePath,
+			"/nix",
+		},
+	}, nil
+}
+
+// NpmProvider
+
+const (
+	npmLockFile     = "package-lock.json"
+	npmCacheKey     = "npm_config_cache"
+	npmDefaultCache = "~/.npm"
+)
+
+// npmDefaultCacheDir is the cache dir of npm assumed offline:
+// npm_config_cache, or ~/.npm and %LocalAppData%/npm-cache on Windows.
+func npmDefaultCacheDir(req PlanRequest) (string, error) {
+	if dir := req.Getenv(npmCacheKey); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS != "windows" {
+		return npmDefaultCache, nil
+	}
+	appData, err := localAppData(req)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appData, "npm-cache"), nil
+}
+
+type NpmProvider struct{}
+
+func (p NpmProvider) Name() string {
+	return "npm"
+}
+
+func (p NpmProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("npm"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath npm: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(npmLockFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", npmLockFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p NpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "npm", Args: []string{"config", "get", "cache"}})
+	if errors.Is(err, ErrOffline) {
+		var cacheDir string
+		cacheDir, err = npmDefaultCacheDir(req)
+		output = []byte(cacheDir)
+	}
+	if err != nil {
+		return PlanResult{}, fmt.Errorf("npm config get cache: %w", err)
+	}
+
+	cacheDir := strings.TrimSpace(string(output))
+	if cacheDir == "" {
+		return PlanResult{}, fmt.Errorf("empty cache dir from npm config")
+	}
+
+	return PlanResult{
+		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{npmLockFile},
+	}, nil
+}
+
+// Trim garbage collects unneeded data from the npm cache.
+func (p NpmProvider) Trim(ctx context.Context, req TrimRequest) error {
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "npm", Args: []string{"cache", "verify"}}); err != nil {
+		return fmt.Errorf("npm cache verify: %w", err)
+	}
+	return nil
+}
+
+// NvmProvider
+
+const (
+	nvmDirKey      = "NVM_DIR"
+	nvmDefaultPath = "~/.nvm/versions"
+	nvmRcFile      = ".nvmrc"
+)
+
+type NvmProvider struct{}
+
+func (p NvmProvider) Name() string {
+	return "nvm"
+}
+
+// Detect looks for .nvmrc only: nvm is a shell function rather than a binary,
+// so it cannot be found on PATH.
+func (p NvmProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.Stat(nvmRcFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", nvmRcFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mounts only the installed node versions, not the whole NVM_DIR, which
+// also holds nvm's own scripts.
+func (p NvmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	mountTarget := nvmDefaultPath
+	if dir := req.Getenv(nvmDirKey); dir != "" {
+		mountTarget = filepath.Join(dir, "versions")
+	}
+
+	return PlanResult{
+		MountPaths: []string{mountTarget},
+	}, nil
+}
+
+// NxProvider
+
+const (
+	nxCacheDirectoryKey = "NX_CACHE_DIRECTORY"
+	nxJsonFile          = "nx.json"
+)
+
+type NxProvider struct{}
+
+func (p NxProvider) Name() string {
+	return "nx"
+}
+
+// Detect only looks for nx.json, see detectNodeToolConfig.
+func (p NxProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, nxJsonFile)
+}
+
+func (p NxProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	if dir := req.Getenv(nxCacheDirectoryKey); dir != "" {
+		return PlanResult{
+			MountPaths: []string{dir},
+		}, nil
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			"./.nx/cache",              // Nx 17+
+			"./node_modules/.cache/nx", // Nx < 17
+		},
+	}, nil
+}
+
+// OpamProvider
+
+const (
+	opamRootKey         = "OPAMROOT"
+	opamDefaultRootPath = "~/.opam"
+	opamFile            = "opam"
+	opamFileSuffix      = ".opam"
+	opamDuneProjectFile = "dune-project"
+)
+
+type OpamProvider struct{}
+
+func (p OpamProvider) Name() string {
+	return "opam"
+}
+
+func (p OpamProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("opam"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath opam: %w", err)
+	}
+
+	entries, err := req.Exec.ReadDir(".")
+	if err != nil {
+		return DetectResult{}, fmt.Errorf("readdir: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == opamFile || name == opamDuneProjectFile || strings.HasSuffix(name, opamFileSuffix) {
+			return d.result(true), nil
+		}
+	}
+
+	return d.result(false), nil
+}
+
+func (p OpamProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	result := PlanResult{
+		MountPaths: []string{
+			opamDefaultRootPath,
+			"./_build", // dune build directory
+		},
+	}
+
+	// A relocated opam root is only picked up by processes that inherit
+	// OPAMROOT, so export it to keep later steps on the mounted root.
+	if root := req.Getenv(opamRootKey); root != "" {
+		result.MountPaths[0] = root
+		result.AddEnvs = map[string]string{
+			opamRootKey: root,
+		}
+	}
+
+	return result, nil
+}
+
+// PantsProvider
+
+const (
+	pantsConfigFile       = "pants.toml"
+	pantsXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	pantsDefaultCachePath = "~/.cache/pants"
+)
+
+type PantsProvider struct{}
+
+func (p PantsProvider) Name() string {
+	return "pants"
+}
+
+func (p PantsProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("pants"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath pants: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(pantsConfigFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
 		}
-		mountTarget = filepath.Join(homeDir, ".local", "share", miseDefaultPath)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pantsConfigFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mounts the shared pants cache, which holds the local process cache,
+// named caches and the pants distributions downloaded by the launcher.
+func (p PantsProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	mountTarget := pantsDefaultCachePath
+	if xdgCacheHome := req.Getenv(pantsXdgCacheHomeKey); xdgCacheHome != "" {
+		mountTarget = filepath.Join(xdgCacheHome, "pants")
 	}
 
 	return PlanResult{
@@ -659,92 +2184,154 @@ func (p MiseProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	}, nil
 }
 
-// NixProvider
-
-const nixCachePath = "~/.cache/nix"
+// PipenvProvider
 
-var nixProjectFiles = []string{
-	"flake.nix",
-	"shell.nix",
-	"default.nix",
-}
+const (
+	pipenvCacheDirKey  = "PIPENV_CACHE_DIR"
+	pipenvCacheDirName = "pipenv"
+	pipenvLockFile     = "Pipfile.lock"
+)
 
-type NixProvider struct{}
+type PipenvProvider struct{}
 
-func (p NixProvider) Name() string {
-	return "nix"
+func (p PipenvProvider) Name() string {
+	return "pipenv"
 }
 
-func (p NixProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("nix"); err != nil {
+func (p PipenvProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("pipenv"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath nix: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath pipenv: %w", err)
 	}
 
-	for _, projectFile := range nixProjectFiles {
-		if _, err := req.Exec.Stat(projectFile); err == nil {
-			return true, nil
-		} else if !errors.Is(err, os.ErrNotExist) {
-			return false, fmt.Errorf("stat %s: %w", projectFile, err)
+	if _, err := req.Exec.Stat(pipenvLockFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
 		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pipenvLockFile, err)
 	}
 
-	return false, nil
+	return d.result(true), nil
 }
 
-func (p NixProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+func (p PipenvProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	// Pipenv follows the OS user cache dir (e.g. ~/.cache on Linux) unless PIPENV_CACHE_DIR is set.
+	cacheDir := req.Getenv(pipenvCacheDirKey)
+	if cacheDir == "" {
+		cacheHome, err := os.UserCacheDir()
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("get user cache dir: %w", err)
+		}
+		cacheDir = filepath.Join(cacheHome, pipenvCacheDirName)
+	}
+
+	mountPaths := []string{cacheDir}
+
+	// Pipenv installs packages through pip, which keeps its own wheel cache.
+	if _, err := req.Exec.LookPath("pip"); err == nil {
+		output, err := req.Exec.Run(ctx, CommandSpec{Name: "pip", Args: []string{"cache", "dir"}})
+		if errors.Is(err, ErrOffline) {
+			// Offline, PIP_CACHE_DIR or pip in the user cache dir is assumed.
+			var cacheDir string
+			cacheDir, err = defaultCacheDir(req, pipCacheDirKey, "pip")
+			output = []byte(cacheDir)
+		}
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("pip cache dir: %w", err)
+		}
+		if pipCacheDir := strings.TrimSpace(string(output)); pipCacheDir != "" && pipCacheDir != cacheDir {
+			mountPaths = append(mountPaths, pipCacheDir)
+		}
+	}
+
+	// Pin the cache dir so pipenv keeps using the mounted path even if the
+	// user cache dir resolves differently later in the job.
 	return PlanResult{
-		MountPaths: []string{
-			nixCachePath,
-			"/nix",
+		AddEnvs: map[string]string{
+			pipenvCacheDirKey: cacheDir,
 		},
+		MountPaths: mountPaths,
+		KeyFiles:   []string{pipenvLockFile},
 	}, nil
 }
 
-// NpmProvider
+// PixiProvider
 
-const npmLockFile = "package-lock.json"
+const (
+	pixiCacheDirKey      = "PIXI_CACHE_DIR"
+	pixiRattlerCacheKey  = "RATTLER_CACHE_DIR"
+	pixiXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	pixiLocalAppDataKey  = "LOCALAPPDATA"
+	pixiDefaultCachePath = "~/.cache/rattler/cache"
+	pixiDarwinCachePath  = "~/Library/Caches/rattler/cache"
+	pixiEnvPath          = "./.pixi"
+	pixiManifest         = "pixi.toml"
+)
 
-type NpmProvider struct{}
+type PixiProvider struct{}
 
-func (p NpmProvider) Name() string {
-	return "npm"
+func (p PixiProvider) Name() string {
+	return "pixi"
 }
 
-func (p NpmProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
-	if _, err := req.Exec.LookPath("npm"); err != nil {
+func (p PixiProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("pixi"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath npm: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath pixi: %w", err)
 	}
 
-	if _, err := req.Exec.Stat(npmLockFile); err != nil {
+	if _, err := req.Exec.Stat(pixiManifest); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", npmLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pixiManifest, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
-func (p NpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "npm", "config", "get", "cache")
-	output, err := req.Exec.Output(cmd)
-	if err != nil {
-		return PlanResult{}, fmt.Errorf("npm config get cache: %w", err)
-	}
-
-	cacheDir := strings.TrimSpace(string(output))
-	if cacheDir == "" {
-		return PlanResult{}, fmt.Errorf("empty cache dir from npm config")
+// Plan mounts the shared rattler package cache and the project environments.
+// ~/.pixi is left alone as it contains bin/, where pixi itself is installed.
+func (p PixiProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	var cacheDir string
+	if dir := req.Getenv(pixiCacheDirKey); dir != "" {
+		cacheDir = dir
+	} else if dir := req.Getenv(pixiRattlerCacheKey); dir != "" {
+		cacheDir = dir
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			cacheDir = pixiDarwinCachePath
+		case "windows":
+			localAppData := req.Getenv(pixiLocalAppDataKey)
+			if localAppData == "" {
+				homeDir, err := os.UserHomeDir()
+				if err != nil {
+					return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+				}
+				localAppData = filepath.Join(homeDir, "AppData", "Local")
+			}
+			cacheDir = filepath.Join(localAppData, "rattler", "cache")
+		default:
+			if xdgCacheHome := req.Getenv(pixiXdgCacheHomeKey); xdgCacheHome != "" {
+				cacheDir = filepath.Join(xdgCacheHome, "rattler", "cache")
+			} else {
+				cacheDir = pixiDefaultCachePath
+			}
+		}
 	}
 
 	return PlanResult{
-		MountPaths: []string{cacheDir},
+		MountPaths: []string{
+			cacheDir,
+			pixiEnvPath,
+		},
 	}, nil
 }
 
@@ -764,18 +2351,19 @@ func (p PlaywrightProvider) Name() string {
 	return "playwright"
 }
 
-func (p PlaywrightProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p PlaywrightProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("playwright"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath playwright: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath playwright: %w", err)
 	}
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p PlaywrightProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	if browsersPath := os.Getenv(playwrightBrowsersPathKey); browsersPath != "" {
+	if browsersPath := req.Getenv(playwrightBrowsersPathKey); browsersPath != "" {
 		return PlanResult{
 			MountPaths: []string{browsersPath},
 		}, nil
@@ -786,7 +2374,7 @@ func (p PlaywrightProvider) Plan(ctx context.Context, req PlanRequest) (PlanResu
 	case "darwin":
 		mountTarget = playwrightDarwinCachePath
 	case "windows":
-		if localAppData := os.Getenv(playwrightLocalAppDataKey); localAppData != "" {
+		if localAppData := req.Getenv(playwrightLocalAppDataKey); localAppData != "" {
 			mountTarget = filepath.Join(localAppData, playwrightCacheDir)
 		} else {
 			homeDir, err := os.UserHomeDir()
@@ -804,6 +2392,56 @@ func (p PlaywrightProvider) Plan(ctx context.Context, req PlanRequest) (PlanResu
 	}, nil
 }
 
+// PleaseProvider
+
+const (
+	pleaseConfigFile       = ".plzconfig"
+	pleaseOutPath          = "./plz-out"
+	pleaseXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	pleaseDefaultCachePath = "~/.cache/please"
+)
+
+type PleaseProvider struct{}
+
+func (p PleaseProvider) Name() string {
+	return "please"
+}
+
+// Detect looks for .plzconfig rather than BUILD files, which are shared with
+// Bazel and Pants.
+func (p PleaseProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("plz"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath plz: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(pleaseConfigFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pleaseConfigFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p PleaseProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	cacheDir := pleaseDefaultCachePath
+	if xdgCacheHome := req.Getenv(pleaseXdgCacheHomeKey); xdgCacheHome != "" {
+		cacheDir = filepath.Join(xdgCacheHome, "please")
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			pleaseOutPath,
+			cacheDir,
+		},
+	}, nil
+}
+
 // PnpmProvider
 
 const (
@@ -826,27 +2464,32 @@ func (p PnpmProvider) Name() string {
 	return "pnpm"
 }
 
-func (p PnpmProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p PnpmProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("pnpm"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath pnpm: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath pnpm: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(pnpmLockFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", pnpmLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pnpmLockFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p PnpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	versionCmd := exec.CommandContext(ctx, "pnpm", "--version")
-	versionOutput, err := req.Exec.Output(versionCmd)
+	versionOutput, err := req.Exec.Run(ctx, CommandSpec{Name: "pnpm", Args: []string{"--version"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, the version is unknown, which only matters for redirecting
+		// the store.
+		versionOutput, err = nil, nil
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("pnpm --version: %w", err)
 	}
@@ -854,8 +2497,10 @@ func (p PnpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	versionLines := strings.Split(strings.TrimSpace(string(versionOutput)), "\n")
 	version := "v" + strings.TrimSpace(versionLines[len(versionLines)-1])
 
-	cmd := exec.CommandContext(ctx, "pnpm", "store", "path", "--loglevel", "error")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "pnpm", Args: []string{"store", "path", "--loglevel", "error"}})
+	if errors.Is(err, ErrOffline) {
+		output, err = pnpmDefaultStoreDir(req)
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("pnpm store path: %w", err)
 	}
@@ -892,26 +2537,25 @@ func (p PnpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	// PNPM_HOME with backing content at <PNPM_HOME>/store/v<N>/. Bind-mounting
 	// any path under PNPM_HOME shadows the binary, so when that would happen
 	// we redirect the store onto the cache volume instead.
-	pnpmHome := strings.TrimSpace(os.Getenv(pnpmHomeEnvKey))
+	pnpmHome := strings.TrimSpace(req.Getenv(pnpmHomeEnvKey))
 	storeWouldShadowBinary := pnpmHome != "" && isDescendant(cacheDir, pnpmHome)
-	userOverride := strings.TrimSpace(os.Getenv(storeDirEnvKey)) != ""
+	userOverride := strings.TrimSpace(req.Getenv(storeDirEnvKey)) != ""
 
 	if !storeWouldShadowBinary {
 		return PlanResult{
 			AddEnvs:    addEnvs,
 			MountPaths: []string{cacheDir},
+			KeyFiles:   []string{pnpmLockFile},
 		}, nil
 	}
 
 	if userOverride {
-		slog.Warn("user-set pnpm store dir is inside PNPM_HOME; the pnpm binary may stop working after the cache mount",
-			slog.String("env_key", storeDirEnvKey),
-			slog.String("store_dir", cacheDir),
-			slog.String("pnpm_home", pnpmHome),
-		)
 		return PlanResult{
 			AddEnvs:    addEnvs,
 			MountPaths: []string{cacheDir},
+			KeyFiles:   []string{pnpmLockFile},
+			Warnings: []string{fmt.Sprintf("user-set pnpm store dir %s (%s) is inside PNPM_HOME %s; the pnpm binary may stop working after the cache mount",
+				cacheDir, storeDirEnvKey, pnpmHome)},
 		}, nil
 	}
 
@@ -926,12 +2570,54 @@ func (p PnpmProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	return PlanResult{
 		AddEnvs:   addEnvs,
 		CacheDirs: []string{pnpmCacheVolumeStoreSubdir},
+		KeyFiles:  []string{pnpmLockFile},
 	}, nil
 }
 
+// pnpmDefaultStoreDir is the store dir assumed offline: the store dir
+// setting, or store in PNPM_HOME or in pnpm in the user data dir. pnpm
+// keeps a subdirectory per store version in it, which the mount covers.
+func pnpmDefaultStoreDir(req PlanRequest) ([]byte, error) {
+	for _, key := range []string{pnpmStoreDirEnvKey, pnpmLegacyStoreDirEnvKey, pnpmHomeEnvKey} {
+		if dir := strings.TrimSpace(req.Getenv(key)); dir != "" {
+			if key == pnpmHomeEnvKey {
+				dir = filepath.Join(dir, "store")
+			}
+			return []byte(dir), nil
+		}
+	}
+	dataHome, err := userDataDir(req)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(filepath.Join(dataHome, "pnpm", "store")), nil
+}
+
+// Trim removes packages from the store that no project references.
+func (p PnpmProvider) Trim(ctx context.Context, req TrimRequest) error {
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "pnpm", Args: []string{"store", "prune"}}); err != nil {
+		return fmt.Errorf("pnpm store prune: %w", err)
+	}
+	return nil
+}
+
+// Warm fetches the packages of the lockfile into the store.
+func (p PnpmProvider) Warm(ctx context.Context, req WarmRequest) error {
+	if ok, err := hasFile(req.Exec, pnpmLockFile); !ok {
+		return err
+	}
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "pnpm", Args: []string{"fetch"}}); err != nil {
+		return fmt.Errorf("pnpm fetch: %w", err)
+	}
+	return nil
+}
+
 // PoetryProvider
 
-const poetryLockFile = "poetry.lock"
+const (
+	poetryLockFile = "poetry.lock"
+	poetryCacheKey = "POETRY_CACHE_DIR"
+)
 
 type PoetryProvider struct{}
 
@@ -939,27 +2625,34 @@ func (p PoetryProvider) Name() string {
 	return "poetry"
 }
 
-func (p PoetryProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p PoetryProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("poetry"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath poetry: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath poetry: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(poetryLockFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", poetryLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", poetryLockFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p PoetryProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "poetry", "config", "cache-dir")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "poetry", Args: []string{"config", "cache-dir"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, POETRY_CACHE_DIR or pypoetry in the user cache dir is
+		// assumed.
+		var cacheDir string
+		cacheDir, err = defaultCacheDir(req, poetryCacheKey, "pypoetry")
+		output = []byte(cacheDir)
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("poetry config cache-dir: %w", err)
 	}
@@ -971,12 +2664,63 @@ func (p PoetryProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult,
 
 	return PlanResult{
 		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{poetryLockFile},
+	}, nil
+}
+
+// PreCommitProvider
+
+const (
+	preCommitHomeKey         = "PRE_COMMIT_HOME"
+	preCommitXdgCacheHomeKey = "XDG_CACHE_HOME"
+	preCommitDefaultPath     = "~/.cache/pre-commit"
+	preCommitConfigFile      = ".pre-commit-config.yaml"
+)
+
+type PreCommitProvider struct{}
+
+func (p PreCommitProvider) Name() string {
+	return "pre-commit"
+}
+
+func (p PreCommitProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("pre-commit"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath pre-commit: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(preCommitConfigFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", preCommitConfigFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p PreCommitProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	mountTarget := preCommitDefaultPath
+	if dir := req.Getenv(preCommitHomeKey); dir != "" {
+		mountTarget = dir
+	} else if xdgCacheHome := req.Getenv(preCommitXdgCacheHomeKey); xdgCacheHome != "" {
+		mountTarget = filepath.Join(xdgCacheHome, "pre-commit")
+	}
+
+	return PlanResult{
+		MountPaths: []string{mountTarget},
 	}, nil
 }
 
 // PythonProvider
 
-const pythonRequirementsFile = "requirements.txt"
+const (
+	pythonRequirementsFile = "requirements.txt"
+	pipCacheDirKey         = "PIP_CACHE_DIR"
+)
 
 type PythonProvider struct{}
 
@@ -984,27 +2728,33 @@ func (p PythonProvider) Name() string {
 	return "python"
 }
 
-func (p PythonProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p PythonProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("pip"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath pip: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath pip: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(pythonRequirementsFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", pythonRequirementsFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", pythonRequirementsFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p PythonProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "pip", "cache", "dir")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "pip", Args: []string{"cache", "dir"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, PIP_CACHE_DIR or pip in the user cache dir is assumed.
+		var cacheDir string
+		cacheDir, err = defaultCacheDir(req, pipCacheDirKey, "pip")
+		output = []byte(cacheDir)
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("pip cache dir: %w", err)
 	}
@@ -1015,13 +2765,269 @@ func (p PythonProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult,
 	}
 
 	return PlanResult{
-		MountPaths: []string{cacheDir},
+		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{pythonRequirementsFile},
+	}, nil
+}
+
+// Warm downloads the requirements, which fills the pip cache. The downloaded
+// files themselves are dropped.
+func (p PythonProvider) Warm(ctx context.Context, req WarmRequest) error {
+	if ok, err := hasFile(req.Exec, pythonRequirementsFile); !ok {
+		return err
+	}
+
+	dest, err := os.MkdirTemp("", "spacectl-pip-download")
+	if err != nil {
+		return fmt.Errorf("create download dir: %w", err)
+	}
+	defer os.RemoveAll(dest)
+
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "pip", Args: []string{"download", "--requirement", pythonRequirementsFile, "--dest", dest}}); err != nil {
+		return fmt.Errorf("pip download: %w", err)
+	}
+	return nil
+}
+
+// PythonToolsProvider
+
+const pythonToolsPyprojectFile = "pyproject.toml"
+
+type pythonTool struct {
+	section     string   // pyproject.toml table prefix, e.g. "[tool.ruff"
+	configFiles []string // standalone config files
+	cacheDirKey string   // env var overriding the cache dir, if supported
+	cacheDir    string
+}
+
+var pythonTools = []pythonTool{
+	{
+		section:     "[tool.pytest",
+		configFiles: []string{"pytest.ini"},
+		cacheDir:    "./.pytest_cache",
+	},
+	{
+		section:     "[tool.mypy",
+		configFiles: []string{"mypy.ini", ".mypy.ini"},
+		cacheDirKey: "MYPY_CACHE_DIR",
+		cacheDir:    "./.mypy_cache",
+	},
+	{
+		section:     "[tool.ruff",
+		configFiles: []string{"ruff.toml", ".ruff.toml"},
+		cacheDirKey: "RUFF_CACHE_DIR",
+		cacheDir:    "./.ruff_cache",
+	},
+}
+
+type PythonToolsProvider struct{}
+
+func (p PythonToolsProvider) Name() string {
+	return "python-tools"
+}
+
+func (p PythonToolsProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	tools, err := configuredPythonTools(req.Exec)
+	if err != nil {
+		return DetectResult{}, err
+	}
+	return d.result(len(tools) > 0), nil
+}
+
+func (p PythonToolsProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	tools, err := configuredPythonTools(req.Exec)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	// Enabled explicitly without any configuration found: cache every tool.
+	if len(tools) == 0 {
+		tools = pythonTools
+	}
+
+	mountPaths := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		cacheDir := tool.cacheDir
+		if tool.cacheDirKey != "" {
+			if dir := req.Getenv(tool.cacheDirKey); dir != "" {
+				cacheDir = dir
+			}
+		}
+		mountPaths = append(mountPaths, cacheDir)
+	}
+
+	return PlanResult{
+		MountPaths: mountPaths,
+	}, nil
+}
+
+// configuredPythonTools returns the tools that are configured in the current
+// directory, either through a [tool.*] table in pyproject.toml or their own
+// config file.
+func configuredPythonTools(executor Executor) ([]pythonTool, error) {
+	var pyproject []string
+	data, err := executor.ReadFile(pythonToolsPyprojectFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read %s: %w", pythonToolsPyprojectFile, err)
+	}
+	for line := range strings.Lines(string(data)) {
+		pyproject = append(pyproject, strings.TrimSpace(line))
+	}
+
+	var configured []pythonTool
+	for _, tool := range pythonTools {
+		found := slices.ContainsFunc(pyproject, func(line string) bool {
+			return strings.HasPrefix(line, tool.section+"]") || strings.HasPrefix(line, tool.section+".")
+		})
+
+		for _, configFile := range tool.configFiles {
+			if found {
+				break
+			}
+			if _, err := executor.Stat(configFile); err == nil {
+				found = true
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("stat %s: %w", configFile, err)
+			}
+		}
+
+		if found {
+			configured = append(configured, tool)
+		}
+	}
+
+	return configured, nil
+}
+
+// RenvProvider
+
+const (
+	renvPathsCacheKey    = "RENV_PATHS_CACHE"
+	renvUserCacheDirKey  = "R_USER_CACHE_DIR"
+	renvXdgCacheHomeKey  = "XDG_CACHE_HOME"
+	renvLocalAppDataKey  = "LOCALAPPDATA"
+	renvDarwinCachePath  = "~/Library/Caches/org.R-project.R/R/renv"
+	renvDefaultCachePath = "~/.cache/R/renv"
+	renvLockFile         = "renv.lock"
+)
+
+type RenvProvider struct{}
+
+func (p RenvProvider) Name() string {
+	return "renv"
+}
+
+func (p RenvProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("Rscript"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath Rscript: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(renvLockFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", renvLockFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mirrors tools::R_user_dir("renv", "cache"), which renv uses as its
+// global cache root unless RENV_PATHS_CACHE is set.
+func (p RenvProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	if cacheDir := req.Getenv(renvPathsCacheKey); cacheDir != "" {
+		return PlanResult{
+			MountPaths: []string{cacheDir},
+			KeyFiles:   []string{renvLockFile},
+		}, nil
+	}
+
+	var mountTarget string
+	if dir := req.Getenv(renvUserCacheDirKey); dir != "" {
+		mountTarget = filepath.Join(dir, "R", "renv")
+	} else if dir := req.Getenv(renvXdgCacheHomeKey); dir != "" {
+		mountTarget = filepath.Join(dir, "R", "renv")
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			mountTarget = renvDarwinCachePath
+		case "windows":
+			localAppData := req.Getenv(renvLocalAppDataKey)
+			if localAppData == "" {
+				homeDir, err := os.UserHomeDir()
+				if err != nil {
+					return PlanResult{}, fmt.Errorf("get user home dir: %w", err)
+				}
+				localAppData = filepath.Join(homeDir, "AppData", "Local")
+			}
+			mountTarget = filepath.Join(localAppData, "R", "cache", "R", "renv")
+		default:
+			mountTarget = renvDefaultCachePath
+		}
+	}
+
+	return PlanResult{
+		MountPaths: []string{mountTarget},
+		KeyFiles:   []string{renvLockFile},
+	}, nil
+}
+
+// RushProvider
+
+const (
+	rushJsonFile         = "rush.json"
+	rushBuildCachePath   = "./common/temp/build-cache"
+	rushPnpmStorePath    = "./common/temp/pnpm-store"
+	rushPnpmStorePathKey = "RUSH_PNPM_STORE_PATH"
+)
+
+type RushProvider struct{}
+
+func (p RushProvider) Name() string {
+	return "rush"
+}
+
+// Detect only looks for rush.json: Rush is commonly bootstrapped through
+// install-run-rush.js rather than being installed on PATH.
+func (p RushProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.Stat(rushJsonFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", rushJsonFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+// Plan mounts the local build cache and Rush's own pnpm store, which lives
+// under common/temp rather than in the regular pnpm store.
+func (p RushProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	pnpmStore := req.Getenv(rushPnpmStorePathKey)
+	if pnpmStore == "" {
+		pnpmStore = rushPnpmStorePath
+	}
+
+	return PlanResult{
+		MountPaths: []string{
+			rushBuildCachePath,
+			pnpmStore,
+		},
 	}, nil
 }
 
 // RubyProvider
 
-const rubyGemfile = "Gemfile"
+const (
+	rubyGemfile     = "Gemfile"
+	rubyGemfileLock = "Gemfile.lock"
+)
 
 type RubyProvider struct{}
 
@@ -1029,22 +3035,23 @@ func (p RubyProvider) Name() string {
 	return "ruby"
 }
 
-func (p RubyProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p RubyProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("bundle"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath bundle: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath bundle: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(rubyGemfile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", rubyGemfile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", rubyGemfile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p RubyProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
@@ -1053,15 +3060,25 @@ func (p RubyProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 			"./vendor/bundle", // Caches output of `bundle install`
 			"./vendor/cache",  // Caches output of `bundle cache` (less common)
 		},
+		KeyFiles: []string{rubyGemfileLock},
 	}, nil
 }
 
 // RustProvider
 
 const (
-	rustCargoToml     = "Cargo.toml"
-	cargoHomeKey      = "CARGO_HOME"
-	cargoTargetDirKey = "CARGO_TARGET_DIR"
+	rustCargoToml         = "Cargo.toml"
+	rustCargoLock         = "Cargo.lock"
+	cargoHomeKey          = "CARGO_HOME"
+	cargoTargetDirKey     = "CARGO_TARGET_DIR"
+	cargoIncrementalKey   = "CARGO_INCREMENTAL"
+	rustcWrapperKey       = "RUSTC_WRAPPER"
+	sccacheDirKey         = "SCCACHE_DIR"
+	sccacheXdgCacheKey    = "XDG_CACHE_HOME"
+	sccacheLocalAppData   = "LOCALAPPDATA"
+	sccacheDefaultPath    = "~/.cache/sccache"
+	sccacheDarwinPath     = "~/Library/Caches/Mozilla.sccache"
+	sccacheWrapperBinName = "sccache"
 )
 
 type RustProvider struct{}
@@ -1070,27 +3087,28 @@ func (p RustProvider) Name() string {
 	return "rust"
 }
 
-func (p RustProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p RustProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("cargo"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath cargo: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath cargo: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(rustCargoToml); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", rustCargoToml, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", rustCargoToml, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p RustProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
 	// CARGO_HOME overrides the conventional ~/.cargo.
-	cargoHome := os.Getenv(cargoHomeKey)
+	cargoHome := req.Getenv(cargoHomeKey)
 	if cargoHome == "" {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -1100,14 +3118,83 @@ func (p RustProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 	}
 
 	// Do not cache the whole cargo home dir as it contains bin/, where the cargo binary lives.
-	return PlanResult{
+	result := PlanResult{
 		MountPaths: []string{
 			filepath.Join(cargoHome, "registry"),
 			filepath.Join(cargoHome, "git"),
 			rustTargetDir(ctx, req),
 			filepath.Join(cargoHome, ".global-cache"), // Cache cleaning feature uses SQLite file: https://blog.rust-lang.org/2023/12/11/cargo-cache-cleaning.html
 		},
-	}, nil
+		KeyFiles: []string{rustCargoLock},
+	}
+
+	if rustUsesSccache(req) {
+		sccacheDir, err := rustSccacheDir(req)
+		if err != nil {
+			return PlanResult{}, err
+		}
+
+		// sccache cannot cache incrementally compiled crates, so incremental
+		// builds would bypass the mounted cache entirely.
+		result.AddEnvs = map[string]string{
+			cargoIncrementalKey: "0",
+			sccacheDirKey:       sccacheDir,
+		}
+		result.MountPaths = append(result.MountPaths, sccacheDir)
+	}
+
+	return result, nil
+}
+
+// Warm fetches the dependencies of the package or workspace.
+func (p RustProvider) Warm(ctx context.Context, req WarmRequest) error {
+	if ok, err := hasFile(req.Exec, rustCargoToml); !ok {
+		return err
+	}
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "cargo", Args: []string{"fetch"}}); err != nil {
+		return fmt.Errorf("cargo fetch: %w", err)
+	}
+	return nil
+}
+
+// rustUsesSccache reports whether RUSTC_WRAPPER points at sccache, either by
+// name or by path.
+func rustUsesSccache(req PlanRequest) bool {
+	wrapper := strings.TrimSpace(req.Getenv(rustcWrapperKey))
+	if wrapper == "" {
+		return false
+	}
+	// filepath.Base does not split on backslashes outside Windows.
+	wrapper = wrapper[strings.LastIndexAny(wrapper, `/\`)+1:]
+	return strings.TrimSuffix(wrapper, ".exe") == sccacheWrapperBinName
+}
+
+// rustSccacheDir resolves sccache's local disk cache, following SCCACHE_DIR
+// and then the platform cache dir sccache uses by default.
+func rustSccacheDir(req PlanRequest) (string, error) {
+	if dir := req.Getenv(sccacheDirKey); dir != "" {
+		return dir, nil
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return sccacheDarwinPath, nil
+	case "windows":
+		localAppData := req.Getenv(sccacheLocalAppData)
+		if localAppData == "" {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("get user home dir: %w", err)
+			}
+			localAppData = filepath.Join(homeDir, "AppData", "Local")
+		}
+		return filepath.Join(localAppData, "Mozilla", "sccache", "cache"), nil
+	default:
+		if xdgCacheHome := req.Getenv(sccacheXdgCacheKey); xdgCacheHome != "" {
+			return filepath.Join(xdgCacheHome, "sccache"), nil
+		}
+		return sccacheDefaultPath, nil
+	}
 }
 
 // rustTargetDir resolves cargo's target directory via `cargo metadata`, falling
@@ -1118,20 +3205,20 @@ func rustTargetDir(ctx context.Context, req PlanRequest) string {
 	const defaultTargetDir = "./target"
 
 	if _, err := req.Exec.LookPath("cargo"); err == nil {
-		cmd := exec.CommandContext(ctx, "cargo", "metadata", "--format-version", "1", "--no-deps", "--offline")
-		if out, err := req.Exec.Output(cmd); err == nil {
+		cmd := CommandSpec{Name: "cargo", Args: []string{"metadata", "--format-version", "1", "--no-deps", "--offline"}}
+		if out, err := req.Exec.Run(ctx, cmd); err == nil {
 			var meta struct {
 				TargetDirectory string `json:"target_directory"`
 			}
 			if json.Unmarshal(out, &meta) == nil && meta.TargetDirectory != "" {
-				if cwd, err := os.Getwd(); err == nil && meta.TargetDirectory == filepath.Join(cwd, "target") {
+				if dir, err := filepath.Abs(req.WorkDir); err == nil && meta.TargetDirectory == filepath.Join(dir, "target") {
 					return defaultTargetDir
 				}
 				return meta.TargetDirectory
 			}
 		}
 	}
-	if dir := os.Getenv(cargoTargetDirKey); dir != "" {
+	if dir := req.Getenv(cargoTargetDirKey); dir != "" {
 		return dir
 	}
 	return defaultTargetDir
@@ -1139,7 +3226,10 @@ func rustTargetDir(ctx context.Context, req PlanRequest) string {
 
 // SwiftPMProvider
 
-const swiftPackageFile = "Package.swift"
+const (
+	swiftPackageFile         = "Package.swift"
+	swiftPackageResolvedFile = "Package.resolved"
+)
 
 type SwiftPMProvider struct{}
 
@@ -1147,22 +3237,23 @@ func (p SwiftPMProvider) Name() string {
 	return "swiftpm"
 }
 
-func (p SwiftPMProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p SwiftPMProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("swift"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath swift: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath swift: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(swiftPackageFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", swiftPackageFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", swiftPackageFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p SwiftPMProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
@@ -1180,6 +3271,149 @@ func (p SwiftPMProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult,
 
 	return PlanResult{
 		MountPaths: mountPaths,
+		KeyFiles:   []string{swiftPackageResolvedFile},
+	}, nil
+}
+
+// TerraformProvider
+
+const (
+	terraformModulesPath    = "./.terraform/modules"
+	terraformPluginCacheKey = "TF_PLUGIN_CACHE_DIR"
+	terraformFileSuffix     = ".tf"
+	terragruntConfigFile    = "terragrunt.hcl"
+	terraformLockFile       = ".terraform.lock.hcl"
+)
+
+type TerraformProvider struct{}
+
+func (p TerraformProvider) Name() string {
+	return "terraform"
+}
+
+func (p TerraformProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	found := false
+	for _, bin := range []string{"terraform", "terragrunt", "tofu"} {
+		if _, err := req.Exec.LookPath(bin); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				continue
+			}
+			return DetectResult{}, fmt.Errorf("lookpath %s: %w", bin, err)
+		}
+		found = true
+		break
+	}
+	if !found {
+		return d.result(false), nil
+	}
+
+	entries, err := req.Exec.ReadDir(".")
+	if err != nil {
+		return DetectResult{}, fmt.Errorf("readdir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.Name() == terragruntConfigFile || strings.HasSuffix(entry.Name(), terraformFileSuffix) {
+			return d.result(true), nil
+		}
+	}
+
+	return d.result(false), nil
+}
+
+func (p TerraformProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	mountPaths := []string{terraformModulesPath}
+
+	// Provider plugins are only shared across working dirs when a plugin
+	// cache has been configured explicitly.
+	if pluginCache := req.Getenv(terraformPluginCacheKey); pluginCache != "" {
+		mountPaths = append(mountPaths, pluginCache)
+	}
+
+	return PlanResult{
+		MountPaths: mountPaths,
+		KeyFiles:   []string{terraformLockFile},
+	}, nil
+}
+
+// TuistProvider
+
+const (
+	tuistCachePath     = "~/.tuist/Cache"
+	tuistProjectFile   = "Project.swift"
+	tuistWorkspaceFile = "Workspace.swift"
+)
+
+type TuistProvider struct{}
+
+func (p TuistProvider) Name() string {
+	return "tuist"
+}
+
+func (p TuistProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("tuist"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath tuist: %w", err)
+	}
+
+	for _, file := range []string{tuistProjectFile, tuistWorkspaceFile} {
+		if _, err := req.Exec.Stat(file); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return DetectResult{}, fmt.Errorf("stat %s: %w", file, err)
+		}
+		return d.result(true), nil
+	}
+
+	return d.result(false), nil
+}
+
+func (p TuistProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{tuistCachePath},
+	}, nil
+}
+
+// TurboProvider
+
+const (
+	turboCacheDirKey     = "TURBO_CACHE_DIR"
+	turboDefaultCacheDir = "./.turbo/cache"
+	turboJsonFile        = "turbo.json"
+)
+
+type TurboProvider struct{}
+
+func (p TurboProvider) Name() string {
+	return "turbo"
+}
+
+// Detect only looks for turbo.json, see detectNodeToolConfig.
+func (p TurboProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, turboJsonFile)
+}
+
+func (p TurboProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	cacheDir := turboDefaultCacheDir
+	if dir := req.Getenv(turboCacheDirKey); dir != "" {
+		cacheDir = dir
+	}
+
+	// turbo v1 defaults to node_modules/.cache/turbo and v2 to .turbo/cache.
+	// Pin the directory so both versions use the mounted path.
+	return PlanResult{
+		AddEnvs: map[string]string{
+			turboCacheDirKey: cacheDir,
+		},
+		MountPaths: []string{cacheDir},
 	}, nil
 }
 
@@ -1189,6 +3423,7 @@ const (
 	uvLinkModeKey   = "UV_LINK_MODE"
 	uvLinkModeValue = "symlink"
 	uvLockFile      = "uv.lock"
+	uvCacheDirKey   = "UV_CACHE_DIR"
 )
 
 type UVProvider struct{}
@@ -1197,27 +3432,30 @@ func (p UVProvider) Name() string {
 	return "uv"
 }
 
-func (p UVProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p UVProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("uv"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath uv: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath uv: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(uvLockFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", uvLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", uvLockFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p UVProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	cmd := exec.CommandContext(ctx, "uv", "cache", "dir")
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "uv", Args: []string{"cache", "dir"}})
+	if errors.Is(err, ErrOffline) {
+		output, err = uvDefaultCacheDir(req)
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("uv cache dir: %w", err)
 	}
@@ -1234,6 +3472,171 @@ func (p UVProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, erro
 			uvLinkModeKey: uvLinkModeValue,
 		},
 		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{uvLockFile},
+	}, nil
+}
+
+// uvDefaultCacheDir is the cache dir assumed offline: UV_CACHE_DIR, or uv in
+// $XDG_CACHE_HOME or ~/.cache, on macOS too, and uv/cache in %LocalAppData%
+// on Windows.
+func uvDefaultCacheDir(req PlanRequest) ([]byte, error) {
+	if dir := req.Getenv(uvCacheDirKey); dir != "" {
+		return []byte(dir), nil
+	}
+	cacheHome, err := xdgCacheDir(req)
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS == "windows" {
+		return []byte(filepath.Join(cacheHome, "uv", "cache")), nil
+	}
+	return []byte(filepath.Join(cacheHome, "uv")), nil
+}
+
+// Trim removes cache entries that are cheap to rebuild, keeping built
+// wheels, as recommended for CI.
+func (p UVProvider) Trim(ctx context.Context, req TrimRequest) error {
+	if _, err := req.Exec.Run(ctx, CommandSpec{Name: "uv", Args: []string{"cache", "prune", "--ci"}}); err != nil {
+		return fmt.Errorf("uv cache prune: %w", err)
+	}
+	return nil
+}
+
+// VcpkgProvider
+
+const (
+	vcpkgBinaryCacheKey         = "VCPKG_DEFAULT_BINARY_CACHE"
+	vcpkgDownloadsKey           = "VCPKG_DOWNLOADS"
+	vcpkgXdgCacheHomeKey        = "XDG_CACHE_HOME"
+	vcpkgLocalAppDataKey        = "LOCALAPPDATA"
+	vcpkgCacheVolumeDownloadDir = "vcpkg-downloads"
+	vcpkgManifestFile           = "vcpkg.json"
+)
+
+type VcpkgProvider struct{}
+
+func (p VcpkgProvider) Name() string {
+	return "vcpkg"
+}
+
+func (p VcpkgProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("vcpkg"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath vcpkg: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(vcpkgManifestFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", vcpkgManifestFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p VcpkgProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	binaryCache, err := vcpkgBinaryCacheDir(req)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	// vcpkg only uses the default binary cache if the directory already
+	// exists, so export it explicitly to make the mounted path authoritative.
+	result := PlanResult{
+		AddEnvs: map[string]string{
+			vcpkgBinaryCacheKey: binaryCache,
+		},
+		MountPaths: []string{binaryCache},
+		KeyFiles:   []string{vcpkgManifestFile},
+	}
+
+	// Downloads default to <vcpkg root>/downloads, next to the vcpkg binary.
+	// Rather than mounting over the tool itself, redirect them onto the cache volume.
+	if downloads := req.Getenv(vcpkgDownloadsKey); downloads != "" {
+		result.MountPaths = append(result.MountPaths, downloads)
+	} else if req.CacheRoot != "" {
+		result.AddEnvs[vcpkgDownloadsKey] = filepath.Join(req.CacheRoot, vcpkgCacheVolumeDownloadDir)
+		result.CacheDirs = []string{vcpkgCacheVolumeDownloadDir}
+	}
+
+	return result, nil
+}
+
+// vcpkgBinaryCacheDir resolves vcpkg's default binary cache location.
+func vcpkgBinaryCacheDir(req PlanRequest) (string, error) {
+	if dir := req.Getenv(vcpkgBinaryCacheKey); dir != "" {
+		return dir, nil
+	}
+
+	if runtime.GOOS == "windows" {
+		if localAppData := req.Getenv(vcpkgLocalAppDataKey); localAppData != "" {
+			return filepath.Join(localAppData, "vcpkg", "archives"), nil
+		}
+	} else if xdgCacheHome := req.Getenv(vcpkgXdgCacheHomeKey); xdgCacheHome != "" {
+		return filepath.Join(xdgCacheHome, "vcpkg", "archives"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get user home dir: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "AppData", "Local", "vcpkg", "archives"), nil
+	}
+	return filepath.Join(home, ".cache", "vcpkg", "archives"), nil
+}
+
+// ViteProvider
+
+const (
+	viteConfigPrefix = "vite.config."
+	viteCacheDir     = "./node_modules/.vite"
+)
+
+type ViteProvider struct{}
+
+func (p ViteProvider) Name() string {
+	return "vite"
+}
+
+// Detect looks for vite.config.* only, see detectNodeToolConfig.
+func (p ViteProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, viteConfigPrefix)
+}
+
+func (p ViteProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{viteCacheDir},
+	}, nil
+}
+
+// WebpackProvider
+
+const (
+	webpackConfigPrefix = "webpack.config."
+	webpackCacheDir     = "./node_modules/.cache/webpack"
+)
+
+type WebpackProvider struct{}
+
+func (p WebpackProvider) Name() string {
+	return "webpack"
+}
+
+// Detect looks for webpack.config.* only, see detectNodeToolConfig.
+func (p WebpackProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	return detectNodeToolConfig(req, webpackConfigPrefix)
+}
+
+// Plan returns the default location of webpack's filesystem cache, which is
+// only used when the config enables `cache: { type: 'filesystem' }`.
+func (p WebpackProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	return PlanResult{
+		MountPaths: []string{webpackCacheDir},
 	}, nil
 }
 
@@ -1254,26 +3657,27 @@ func (p XcodeProvider) Name() string {
 	return "xcode"
 }
 
-func (p XcodeProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p XcodeProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("xcodebuild"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath xcodebuild: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath xcodebuild: %w", err)
 	}
 
 	entries, err := req.Exec.ReadDir(".")
 	if err != nil {
-		return false, fmt.Errorf("readdir: %w", err)
+		return DetectResult{}, fmt.Errorf("readdir: %w", err)
 	}
 
 	for _, entry := range entries {
 		if strings.HasSuffix(entry.Name(), xcodeProjSuffix) || strings.HasSuffix(entry.Name(), xcodeWorkspaceSuffix) {
-			return true, nil
+			return d.result(true), nil
 		}
 	}
 
-	return false, nil
+	return d.result(false), nil
 }
 
 // Experimental: Xcode compilation cache can be huge.
@@ -1284,7 +3688,7 @@ func (p XcodeProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, e
 	// CompilationCache.noindex inside the project-specific DerivedData directory
 	// rather than the global location. Also mount that path so caching works
 	// regardless of whether -derivedDataPath is used.
-	if projectCachePath := xcodeProjectCachePath(req.Exec); projectCachePath != "" {
+	if projectCachePath := xcodeProjectCachePath(req.Exec, req.WorkDir); projectCachePath != "" {
 		mountPaths = append(mountPaths, projectCachePath)
 	}
 
@@ -1307,7 +3711,7 @@ func (p XcodeProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, e
 // relied upon by Fastlane, xcode-build-server, and other tools.
 //
 // Validated by CI: see .github/workflows/test-xcode-hash.yml.
-func xcodeProjectCachePath(executor Executor) string {
+func xcodeProjectCachePath(executor Executor, workDir string) string {
 	entries, err := executor.ReadDir(".")
 	if err != nil {
 		return ""
@@ -1333,7 +3737,7 @@ func xcodeProjectCachePath(executor Executor) string {
 		return ""
 	}
 
-	absPath, err := filepath.Abs(projectFile)
+	absPath, err := filepath.Abs(filepath.Join(workDir, projectFile))
 	if err != nil {
 		return ""
 	}
@@ -1372,8 +3776,11 @@ func xcodeDerivedDataHash(path string) string {
 // YarnProvider
 
 const (
-	yarnV1Prefix = "1."
-	yarnLockFile = "yarn.lock"
+	yarnV1Prefix       = "1."
+	yarnLockFile       = "yarn.lock"
+	yarnRcFile         = ".yarnrc.yml"
+	yarnCacheFolderKey = "YARN_CACHE_FOLDER"
+	yarnBerryCache     = "~/.yarn/berry/cache"
 )
 
 type YarnProvider struct{}
@@ -1382,41 +3789,52 @@ func (p YarnProvider) Name() string {
 	return "yarn"
 }
 
-func (p YarnProvider) Detect(ctx context.Context, req DetectRequest) (bool, error) {
+func (p YarnProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
 	if _, err := req.Exec.LookPath("yarn"); err != nil {
 		if errors.Is(err, exec.ErrNotFound) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("lookpath yarn: %w", err)
+		return DetectResult{}, fmt.Errorf("lookpath yarn: %w", err)
 	}
 
 	if _, err := req.Exec.Stat(yarnLockFile); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
+			return d.result(false), nil
 		}
-		return false, fmt.Errorf("stat %s: %w", yarnLockFile, err)
+		return DetectResult{}, fmt.Errorf("stat %s: %w", yarnLockFile, err)
 	}
 
-	return true, nil
+	return d.result(true), nil
 }
 
 func (p YarnProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
-	versionCmd := exec.CommandContext(ctx, "yarn", "--version")
-	versionOutput, err := req.Exec.Output(versionCmd)
+	var warnings []string
+	versionOutput, err := req.Exec.Run(ctx, CommandSpec{Name: "yarn", Args: []string{"--version"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, yarn v1 is assumed unless .yarnrc.yml configures yarn v2+.
+		versionOutput, err = nil, nil
+		if _, statErr := req.Exec.Stat(yarnRcFile); errors.Is(statErr, os.ErrNotExist) {
+			versionOutput = []byte(yarnV1Prefix)
+		}
+	}
 	if err != nil {
-		return PlanResult{}, fmt.Errorf("yarn --version: %w", err)
+		warnings = append(warnings, fmt.Sprintf("yarn version could not be determined, using the yarn v2+ cache folder: %v", err))
 	}
 	version := strings.TrimSpace(string(versionOutput))
 
 	// Yarn v1.x uses "yarn cache dir", v2+ uses "yarn config get cacheFolder"
-	var cmd *exec.Cmd
+	var cmd CommandSpec
 	if strings.HasPrefix(version, yarnV1Prefix) {
-		cmd = exec.CommandContext(ctx, "yarn", "cache", "dir")
+		cmd = CommandSpec{Name: "yarn", Args: []string{"cache", "dir"}}
 	} else {
-		cmd = exec.CommandContext(ctx, "yarn", "config", "get", "cacheFolder")
+		cmd = CommandSpec{Name: "yarn", Args: []string{"config", "get", "cacheFolder"}}
 	}
 
-	output, err := req.Exec.Output(cmd)
+	output, err := req.Exec.Run(ctx, cmd)
+	if errors.Is(err, ErrOffline) {
+		output, err = yarnDefaultCacheDir(req, strings.HasPrefix(version, yarnV1Prefix))
+	}
 	if err != nil {
 		return PlanResult{}, fmt.Errorf("yarn cache dir: %w", err)
 	}
@@ -1428,9 +3846,157 @@ func (p YarnProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, er
 
 	return PlanResult{
 		MountPaths: []string{cacheDir},
+		KeyFiles:   []string{yarnLockFile},
+		Warnings:   warnings,
+	}, nil
+}
+
+// yarnDefaultCacheDir is the cache dir assumed offline: YARN_CACHE_FOLDER, or
+// the global cache of yarn v2+ in ~/.yarn/berry/cache, or Yarn/v6 in the
+// user cache dir for yarn v1 (yarn/v6 in $XDG_CACHE_HOME or ~/.cache on
+// Linux, Yarn/Cache/v6 in %LocalAppData% on Windows).
+func yarnDefaultCacheDir(req PlanRequest, v1 bool) ([]byte, error) {
+	if dir := req.Getenv(yarnCacheFolderKey); dir != "" {
+		return []byte(dir), nil
+	}
+	if !v1 {
+		return []byte(yarnBerryCache), nil
+	}
+	cacheHome, err := userCacheDir(req)
+	if err != nil {
+		return nil, err
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return []byte(filepath.Join(cacheHome, "Yarn", "v6")), nil
+	case "windows":
+		return []byte(filepath.Join(cacheHome, "Yarn", "Cache", "v6")), nil
+	default:
+		return []byte(filepath.Join(cacheHome, "yarn", "v6")), nil
+	}
+}
+
+// ZigProvider
+
+const (
+	zigGlobalCacheDirKey  = "global_cache_dir"
+	zigBuildFile          = "build.zig"
+	zigLocalCacheDir      = "./.zig-cache"
+	zigLegacyLocalDirName = "zig-cache"
+	zigGlobalCacheEnvKey  = "ZIG_GLOBAL_CACHE_DIR"
+)
+
+// zig >= 0.15 prints `zig env` as ZON instead of JSON.
+var zigZonGlobalCacheDirRegex = regexp.MustCompile(`\.global_cache_dir\s*=\s*"((?:[^"\\]|\\.)*)"`)
+
+type ZigProvider struct{}
+
+func (p ZigProvider) Name() string {
+	return "zig"
+}
+
+func (p ZigProvider) Detect(ctx context.Context, req DetectRequest) (DetectResult, error) {
+	d := detecting(&req)
+	if _, err := req.Exec.LookPath("zig"); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("lookpath zig: %w", err)
+	}
+
+	if _, err := req.Exec.Stat(zigBuildFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", zigBuildFile, err)
+	}
+
+	return d.result(true), nil
+}
+
+func (p ZigProvider) Plan(ctx context.Context, req PlanRequest) (PlanResult, error) {
+	output, err := req.Exec.Run(ctx, CommandSpec{Name: "zig", Args: []string{"env"}})
+	if errors.Is(err, ErrOffline) {
+		// Offline, ZIG_GLOBAL_CACHE_DIR or zig in $XDG_CACHE_HOME or
+		// ~/.cache, on macOS too, and in %LocalAppData% on Windows is
+		// assumed.
+		globalCacheDir := req.Getenv(zigGlobalCacheEnvKey)
+		if globalCacheDir == "" {
+			cacheHome, dirErr := xdgCacheDir(req)
+			if dirErr != nil {
+				return PlanResult{}, dirErr
+			}
+			globalCacheDir = filepath.Join(cacheHome, "zig")
+		}
+		output, err = json.Marshal(map[string]string{zigGlobalCacheDirKey: globalCacheDir})
+	}
+	if err != nil {
+		return PlanResult{}, fmt.Errorf("zig env: %w", err)
+	}
+
+	var globalCacheDir string
+	var zigEnv map[string]any
+	if err := json.Unmarshal(output, &zigEnv); err == nil {
+		globalCacheDir, _ = zigEnv[zigGlobalCacheDirKey].(string)
+	} else if match := zigZonGlobalCacheDirRegex.FindSubmatch(output); match != nil {
+		unquoted, err := strconv.Unquote(`"` + string(match[1]) + `"`)
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("parse zig env output: %w", err)
+		}
+		globalCacheDir = unquoted
+	}
+	if globalCacheDir == "" {
+		return PlanResult{}, fmt.Errorf(zigGlobalCacheDirKey + " not found in zig env output")
+	}
+
+	// zig < 0.12 used zig-cache without the leading dot; keep using it if present.
+	localCacheDir := zigLocalCacheDir
+	if _, err := req.Exec.Stat(zigLegacyLocalDirName); err == nil {
+		localCacheDir = "./" + zigLegacyLocalDirName
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return PlanResult{}, fmt.Errorf("stat %s: %w", zigLegacyLocalDirName, err)
+	}
+
+	return PlanResult{
+		MountPaths: []string{globalCacheDir, localCacheDir},
 	}, nil
 }
 
+// detectNodeToolConfig detects a JavaScript tool by its config file in the
+// project directory: name is either a file name, e.g. "turbo.json", or a
+// prefix ending in a dot, e.g. "vite.config." for vite.config.{js,ts,mjs}.
+// Such tools are usually installed into node_modules, which does not exist
+// yet when caches are mounted, so the config file is all they are detected by.
+func detectNodeToolConfig(req DetectRequest, name string) (DetectResult, error) {
+	d := detecting(&req)
+	if strings.HasSuffix(name, ".") {
+		entries, err := req.Exec.ReadDir(".")
+		if err != nil {
+			return DetectResult{}, fmt.Errorf("readdir: %w", err)
+		}
+		return d.result(hasEntryWithPrefix(entries, name)), nil
+	}
+
+	if _, err := req.Exec.Stat(name); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return d.result(false), nil
+		}
+		return DetectResult{}, fmt.Errorf("stat %s: %w", name, err)
+	}
+	return d.result(true), nil
+}
+
+// hasEntryWithPrefix reports whether any of the directory entries has a name
+// starting with prefix, e.g. "vite.config." for vite.config.{js,ts,mjs}.
+func hasEntryWithPrefix(entries []os.DirEntry, prefix string) bool {
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // isDescendant reports whether path is equal to ancestor or lives underneath
 // it, using lexical comparison (so /foo/.bin does not match /foo/.bin-other).
 func isDescendant(path, ancestor string) bool {
@@ -1,10 +1,13 @@
 package mode_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,7 +30,7 @@ func TestAptProvider_Detect(t *testing.T) {
 		p := mode.AptProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected", func(t *testing.T) {
@@ -42,7 +45,7 @@ func TestAptProvider_Detect(t *testing.T) {
 		p := mode.AptProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
@@ -57,7 +60,7 @@ func TestAptProvider_Plan(t *testing.T) {
 	t.Run("cache path extracted", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					return defaultAptConfig, nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
@@ -77,7 +80,7 @@ func TestAptProvider_Plan(t *testing.T) {
 	t.Run("docker-clean removed", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					return defaultAptConfig, nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
@@ -95,26 +98,26 @@ func TestAptProvider_Plan(t *testing.T) {
 	})
 }
 
-// BrewProvider tests
+// AsdfProvider tests
 
-func TestBrewProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and Brewfile exist", func(t *testing.T) {
+func TestAsdfProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and .tool-versions exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/brew", nil
+					return "/usr/local/bin/asdf", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "Brewfile", name)
+					require.Equal(t, ".tool-versions", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.BrewProvider{}
+		p := mode.AsdfProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -126,17 +129,17 @@ func TestBrewProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.BrewProvider{}
+		p := mode.AsdfProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when Brewfile missing", func(t *testing.T) {
+	t.Run("not detected when .tool-versions missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/brew", nil
+					return "/usr/local/bin/asdf", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -144,65 +147,67 @@ func TestBrewProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.BrewProvider{}
+		p := mode.AsdfProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestBrewProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
+func TestAsdfProvider_Plan(t *testing.T) {
+	t.Run("returns default mount paths", func(t *testing.T) {
+		t.Setenv("ASDF_DATA_DIR", "")
+
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/Users/user/Library/Caches/Homebrew\n"), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.BrewProvider{}
+		p := mode.AsdfProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/Users/user/Library/Caches/Homebrew"}, result.MountPaths)
+		require.Equal(t, []string{
+			filepath.Join("~/.asdf", "installs"),
+			filepath.Join("~/.asdf", "downloads"),
+		}, result.MountPaths)
 	})
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
+	t.Run("honors ASDF_DATA_DIR", func(t *testing.T) {
+		t.Setenv("ASDF_DATA_DIR", filepath.Join("opt", "asdf"))
+
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(""), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.BrewProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
+		p := mode.AsdfProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join("opt", "asdf", "installs"),
+			filepath.Join("opt", "asdf", "downloads"),
+		}, result.MountPaths)
 	})
 }
 
-// BunProvider tests
+// BrewProvider tests
 
-func TestBunProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and lock file exist", func(t *testing.T) {
+func TestBrewProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Brewfile exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/bun", nil
+					return "/usr/local/bin/brew", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "bun.lock", name)
+					require.Equal(t, "Brewfile", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.BunProvider{}
+		p := mode.BrewProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -214,17 +219,17 @@ func TestBunProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.BunProvider{}
+		p := mode.BrewProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when lock file missing", func(t *testing.T) {
+	t.Run("not detected when Brewfile missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/bun", nil
+					return "/usr/local/bin/brew", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -232,65 +237,65 @@ func TestBunProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.BunProvider{}
+		p := mode.BrewProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestBunProvider_Plan(t *testing.T) {
+func TestBrewProvider_Plan(t *testing.T) {
 	t.Run("cache path extracted", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.bun/install/cache\n"), nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/Users/user/Library/Caches/Homebrew\n"), nil
 				},
 			},
 		}
 
-		p := mode.BunProvider{}
+		p := mode.BrewProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.bun/install/cache"}, result.MountPaths)
+		require.Equal(t, []string{"/Users/user/Library/Caches/Homebrew"}, result.MountPaths)
 	})
 
 	t.Run("empty cache dir returns error", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					return []byte(""), nil
 				},
 			},
 		}
 
-		p := mode.BunProvider{}
+		p := mode.BrewProvider{}
 		_, err := p.Plan(t.Context(), req)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
 
-// CocoapodsProvider tests
+// Buck2Provider tests
 
-func TestCocoapodsProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and Podfile exist", func(t *testing.T) {
+func TestBuck2Provider_Detect(t *testing.T) {
+	t.Run("detected when binary and .buckconfig exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/pod", nil
+					return "/usr/local/bin/buck2", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "Podfile", name)
+					require.Equal(t, ".buckconfig", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.CocoapodsProvider{}
+		p := mode.Buck2Provider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -302,17 +307,17 @@ func TestCocoapodsProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.CocoapodsProvider{}
+		p := mode.Buck2Provider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when Podfile missing", func(t *testing.T) {
+	t.Run("not detected when .buckconfig missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/pod", nil
+					return "/usr/local/bin/buck2", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -320,48 +325,46 @@ func TestCocoapodsProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.CocoapodsProvider{}
+		p := mode.Buck2Provider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestCocoapodsProvider_Plan(t *testing.T) {
+func TestBuck2Provider_Plan(t *testing.T) {
 	t.Run("returns mount paths", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.CocoapodsProvider{}
+		p := mode.Buck2Provider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 2)
-		require.Equal(t, "./Pods", result.MountPaths[0])
-		require.Equal(t, "~/Library/Caches/CocoaPods", result.MountPaths[1])
+		require.Equal(t, []string{"./buck-out"}, result.MountPaths)
 	})
 }
 
-// ComposerProvider tests
+// BunProvider tests
 
-func TestComposerProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and composer.json exist", func(t *testing.T) {
+func TestBunProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and lock file exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/composer", nil
+					return "/usr/local/bin/bun", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "composer.json", name)
+					require.Equal(t, "bun.lock", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.ComposerProvider{}
+		p := mode.BunProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -373,17 +376,17 @@ func TestComposerProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.ComposerProvider{}
+		p := mode.BunProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when composer.json missing", func(t *testing.T) {
+	t.Run("not detected when lock file missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/composer", nil
+					return "/usr/local/bin/bun", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -391,65 +394,65 @@ func TestComposerProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.ComposerProvider{}
+		p := mode.BunProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestComposerProvider_Plan(t *testing.T) {
+func TestBunProvider_Plan(t *testing.T) {
 	t.Run("cache path extracted", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.composer/cache/files\n"), nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.bun/install/cache\n"), nil
 				},
 			},
 		}
 
-		p := mode.ComposerProvider{}
+		p := mode.BunProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.composer/cache/files"}, result.MountPaths)
+		require.Equal(t, []string{"/home/user/.bun/install/cache"}, result.MountPaths)
 	})
 
 	t.Run("empty cache dir returns error", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					return []byte(""), nil
 				},
 			},
 		}
 
-		p := mode.ComposerProvider{}
+		p := mode.BunProvider{}
 		_, err := p.Plan(t.Context(), req)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
 
-// DenoProvider tests
+// CarthageProvider tests
 
-func TestDenoProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and lock file exist", func(t *testing.T) {
+func TestCarthageProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Cartfile exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/deno", nil
+					return "/usr/local/bin/carthage", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "deno.lock", name)
+					require.Equal(t, "Cartfile", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.DenoProvider{}
+		p := mode.CarthageProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -461,17 +464,17 @@ func TestDenoProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.DenoProvider{}
+		p := mode.CarthageProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when lock file missing", func(t *testing.T) {
+	t.Run("not detected when Cartfile missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/deno", nil
+					return "/usr/local/bin/carthage", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -479,188 +482,197 @@ func TestDenoProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.DenoProvider{}
+		p := mode.CarthageProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestDenoProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
+func TestCarthageProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(`{"denoDir":"/home/user/.cache/deno"}`), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.DenoProvider{}
+		p := mode.CarthageProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.cache/deno"}, result.MountPaths)
+		require.Equal(t, []string{
+			"~/Library/Caches/org.carthage.CarthageKit",
+			"./Carthage/Build",
+		}, result.MountPaths)
 	})
+}
 
-	t.Run("missing denoDir returns error", func(t *testing.T) {
-		req := mode.PlanRequest{
+// CMakeProvider tests
+
+func TestCMakeProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and CMakeLists.txt exist", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(`{"otherKey":"value"}`), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/cmake", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "CMakeLists.txt", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.DenoProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "denoDir not found")
+		p := mode.CMakeProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
 	})
-}
 
-// GoProvider tests
-
-func TestGoProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and go.mod exist", func(t *testing.T) {
+	t.Run("not detected when binary missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/go", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "go.mod" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.GoProvider{}
+		p := mode.CMakeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("detected when binary and go.work exist", func(t *testing.T) {
+	t.Run("not detected when CMakeLists.txt missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/go", nil
+					return "/usr/bin/cmake", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "go.work" {
-						return nil, nil
-					}
 					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.GoProvider{}
+		p := mode.CMakeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
-		req := mode.DetectRequest{
+func TestCMakeProvider_Plan(t *testing.T) {
+	t.Run("defaults to ./build without presets", func(t *testing.T) {
+		t.Setenv("CPM_SOURCE_CACHE", "")
+
+		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				ReadFileFunc: func(name string) ([]byte, error) {
+					require.Equal(t, "CMakePresets.json", name)
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.GoProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.CMakeProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.Equal(t, []string{"./build"}, result.MountPaths)
+		require.Empty(t, result.CacheDirs)
+		require.Nil(t, result.AddEnvs)
 	})
 
-	t.Run("not detected when go.mod and go.work missing", func(t *testing.T) {
-		req := mode.DetectRequest{
+	t.Run("uses binary dirs from CMakePresets.json", func(t *testing.T) {
+		t.Setenv("CPM_SOURCE_CACHE", "")
+
+		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/go", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return []byte(`{
+						"version": 6,
+						"configurePresets": [
+							{"name": "base", "hidden": true, "binaryDir": "${sourceDir}/out/base"},
+							{"name": "debug", "binaryDir": "${sourceDir}/out/${presetName}"},
+							{"name": "release", "binaryDir": "out/${presetName}/"},
+							{"name": "debug-again", "binaryDir": "${sourceDir}/out/debug"},
+							{"name": "env", "binaryDir": "$env{BUILD_DIR}"},
+							{"name": "inherited", "inherits": "base"}
+						]
+					}`), nil
 				},
 			},
 		}
 
-		p := mode.GoProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.CMakeProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.Equal(t, []string{"./out/debug", "./out/release"}, result.MountPaths)
+		require.Equal(t, []string{`binary dir of preset "env" needs cmake to resolve, not caching it`}, result.Warnings)
 	})
-}
 
-func TestGoProvider_Plan(t *testing.T) {
-	t.Run("cache paths extracted", func(t *testing.T) {
-		goEnvOutput := []byte(`{"GOCACHE":"/home/user/.cache/go-build","GOMODCACHE":"/home/user/go/pkg/mod"}`)
+	t.Run("mounts CPM_SOURCE_CACHE when set", func(t *testing.T) {
+		t.Setenv("CPM_SOURCE_CACHE", "/opt/cpm")
 
 		req := mode.PlanRequest{
+			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return goEnvOutput, nil
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.GoProvider{}
+		p := mode.CMakeProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, 2, len(result.MountPaths))
-		require.Equal(t, "/home/user/.cache/go-build", result.MountPaths[0])
-		require.Equal(t, "/home/user/go/pkg/mod", result.MountPaths[1])
+		require.Equal(t, []string{"./build", "/opt/cpm"}, result.MountPaths)
+		require.Empty(t, result.CacheDirs)
+		require.Nil(t, result.AddEnvs)
 	})
-}
 
-// GolangCILintProvider tests
+	t.Run("redirects CPM_SOURCE_CACHE onto the cache volume", func(t *testing.T) {
+		t.Setenv("CPM_SOURCE_CACHE", "")
 
-func TestGolangCILintProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and .golangci.yml exist", func(t *testing.T) {
-		req := mode.DetectRequest{
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/golangci-lint", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == ".golangci.yml" {
-						return nil, nil
-					}
+				ReadFileFunc: func(name string) ([]byte, error) {
 					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.CMakeProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.Equal(t, []string{"./build"}, result.MountPaths)
+		require.Equal(t, []string{"cpm-sources"}, result.CacheDirs)
+		require.Equal(t, map[string]string{
+			"CPM_SOURCE_CACHE": filepath.Join("/cache", "cpm-sources"),
+		}, result.AddEnvs)
 	})
+}
 
-	t.Run("detected when binary and .golangci.yaml exist", func(t *testing.T) {
+// CocoapodsProvider tests
+
+func TestCocoapodsProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Podfile exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/golangci-lint", nil
+					return "/usr/local/bin/pod", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == ".golangci.yaml" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
+					require.Equal(t, "Podfile", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
+		p := mode.CocoapodsProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -672,17 +684,17 @@ func TestGolangCILintProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
+		p := mode.CocoapodsProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when config files missing", func(t *testing.T) {
+	t.Run("not detected when Podfile missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/golangci-lint", nil
+					return "/usr/local/bin/pod", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -690,69 +702,127 @@ func TestGolangCILintProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
+		p := mode.CocoapodsProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestGolangCILintProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
-		cacheStatusOutput := []byte(`
-			Dir: /home/user/.cache/golangci-lint
-			Size: 123MB
-		`)
-
+func TestCocoapodsProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
 		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.CocoapodsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 2)
+		require.Equal(t, "./Pods", result.MountPaths[0])
+		require.Equal(t, "~/Library/Caches/CocoaPods", result.MountPaths[1])
+	})
+}
+
+// ComposerProvider tests
+
+func TestComposerProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and composer.json exist", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return cacheStatusOutput, nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/composer", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "composer.json", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.ComposerProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, 1, len(result.MountPaths))
-		require.Equal(t, "/home/user/.cache/golangci-lint", result.MountPaths[0])
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("uses default path when dir not in output", func(t *testing.T) {
-		cacheStatusOutput := []byte(`
-			Size: 123MB
-		`)
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.ComposerProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when composer.json missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/composer", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.ComposerProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
 
+func TestComposerProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return cacheStatusOutput, nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.composer/cache/files\n"), nil
 				},
 			},
 		}
 
-		p := mode.GolangCILintProvider{}
+		p := mode.ComposerProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.composer/cache/files"}, result.MountPaths)
+	})
 
-		cacheHome, err := os.UserCacheDir()
-		require.NoError(t, err)
-		require.Equal(t, []string{filepath.Join(cacheHome, "golangci-lint")}, result.MountPaths)
+	t.Run("empty cache dir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(""), nil
+				},
+			},
+		}
+
+		p := mode.ComposerProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
 
-// GradleProvider tests
+// ConanProvider tests
 
-func TestGradleProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and gradlew exist", func(t *testing.T) {
+func TestConanProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and conanfile.txt exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/gradle", nil
+					return "/usr/local/bin/conan", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "gradlew" {
+					if name == "conanfile.txt" {
 						return nil, nil
 					}
 					return nil, os.ErrNotExist
@@ -760,20 +830,20 @@ func TestGradleProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GradleProvider{}
+		p := mode.ConanProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("detected when binary and build.gradle exist", func(t *testing.T) {
+	t.Run("detected when binary and conanfile.py exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/gradle", nil
+					return "/usr/local/bin/conan", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "build.gradle" {
+					if name == "conanfile.py" {
 						return nil, nil
 					}
 					return nil, os.ErrNotExist
@@ -781,10 +851,10 @@ func TestGradleProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GradleProvider{}
+		p := mode.ConanProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -796,17 +866,17 @@ func TestGradleProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GradleProvider{}
+		p := mode.ConanProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when gradlew and build.gradle missing", func(t *testing.T) {
+	t.Run("not detected when conanfile missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/gradle", nil
+					return "/usr/local/bin/conan", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -814,223 +884,244 @@ func TestGradleProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.GradleProvider{}
+		p := mode.ConanProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestGradleProvider_Plan(t *testing.T) {
-	t.Run("returns mount paths", func(t *testing.T) {
+func TestConanProvider_Plan(t *testing.T) {
+	t.Run("uses CONAN_HOME when set", func(t *testing.T) {
+		t.Setenv("CONAN_HOME", "/custom/conan")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.GradleProvider{}
+		p := mode.ConanProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 2)
-		require.Equal(t, "~/.gradle/caches", result.MountPaths[0])
-		require.Equal(t, "~/.gradle/wrapper", result.MountPaths[1])
+		require.Equal(t, []string{filepath.Join("/custom/conan", "p")}, result.MountPaths)
+		require.Equal(t, map[string]string{"CONAN_HOME": "/custom/conan"}, result.AddEnvs)
+	})
+
+	t.Run("uses default path when CONAN_HOME not set", func(t *testing.T) {
+		t.Setenv("CONAN_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.ConanProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Contains(t, result.MountPaths[0], filepath.Join(".conan2", "p"))
+		require.Equal(t, filepath.Dir(result.MountPaths[0]), result.AddEnvs["CONAN_HOME"])
 	})
 }
 
-// KotlinNativeProvider tests
+// CorepackProvider tests
 
-func TestKotlinNativeProvider_Detect(t *testing.T) {
-	t.Run("detected when kotlinc-native exists", func(t *testing.T) {
+func TestCorepackProvider_Detect(t *testing.T) {
+	t.Run("detected when packageManager is set", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					require.Equal(t, "kotlinc-native", file)
-					return "/usr/local/bin/kotlinc-native", nil
+				ReadFileFunc: func(name string) ([]byte, error) {
+					require.Equal(t, "package.json", name)
+					return []byte(`{"name":"app","packageManager":"pnpm@9.1.0"}`), nil
 				},
 			},
 		}
 
-		p := mode.KotlinNativeProvider{}
+		p := mode.CorepackProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("detected when only konanc exists", func(t *testing.T) {
+	t.Run("not detected without packageManager", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					if file == "konanc" {
-						return "/usr/local/bin/konanc", nil
-					}
-					return "", exec.ErrNotFound
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return []byte(`{"name":"app"}`), nil
 				},
 			},
 		}
 
-		p := mode.KotlinNativeProvider{}
+		p := mode.CorepackProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when no binary exists", func(t *testing.T) {
+	t.Run("not detected when package.json missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.KotlinNativeProvider{}
+		p := mode.CorepackProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when package.json is invalid", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return []byte(`{`), nil
+				},
+			},
+		}
+
+		p := mode.CorepackProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestKotlinNativeProvider_Plan(t *testing.T) {
-	t.Run("returns default konan path", func(t *testing.T) {
-		t.Setenv("KONAN_DATA_DIR", "")
+func TestCorepackProvider_Plan(t *testing.T) {
+	t.Run("honors COREPACK_HOME", func(t *testing.T) {
+		t.Setenv("COREPACK_HOME", "/opt/corepack")
 
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.KotlinNativeProvider{}
+		p := mode.CorepackProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 1)
-		require.Equal(t, "~/.konan", result.MountPaths[0])
+		require.Equal(t, []string{"/opt/corepack"}, result.MountPaths)
 	})
 
-	t.Run("honors KONAN_DATA_DIR override", func(t *testing.T) {
-		t.Setenv("KONAN_DATA_DIR", "/custom/konan")
+	t.Run("honors XDG_CACHE_HOME", func(t *testing.T) {
+		t.Setenv("COREPACK_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", filepath.Join("opt", "cache"))
 
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.KotlinNativeProvider{}
+		p := mode.CorepackProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 1)
-		require.Equal(t, "/custom/konan", result.MountPaths[0])
+		require.Equal(t, []string{filepath.Join("opt", "cache", "node", "corepack")}, result.MountPaths)
 	})
-}
 
-// MavenProvider tests
+	t.Run("falls back to the default cache dir", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("default corepack dir is platform specific")
+		}
+		t.Setenv("COREPACK_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
 
-func TestMavenProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and pom.xml exist", func(t *testing.T) {
-		req := mode.DetectRequest{
-			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/mvn", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "pom.xml", name)
-					return nil, nil
-				},
-			},
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.MavenProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.CorepackProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.Equal(t, []string{"~/.cache/node/corepack"}, result.MountPaths)
 	})
+}
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+// CypressProvider tests
+
+func TestCypressProvider_Detect(t *testing.T) {
+	t.Run("detected when cypress.config.ts exists", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "cypress", isDir: true},
+						mockDirEntry{name: "cypress.config.ts"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.MavenProvider{}
+		p := mode.CypressProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when pom.xml missing", func(t *testing.T) {
+	t.Run("not detected when config missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/mvn", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "cypress", isDir: true},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.MavenProvider{}
+		p := mode.CypressProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestMavenProvider_Plan(t *testing.T) {
-	t.Run("returns mount path", func(t *testing.T) {
+func TestCypressProvider_Plan(t *testing.T) {
+	t.Run("uses CYPRESS_CACHE_FOLDER when set", func(t *testing.T) {
+		t.Setenv("CYPRESS_CACHE_FOLDER", "/custom/cypress")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.MavenProvider{}
+		p := mode.CypressProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"~/.m2/repository"}, result.MountPaths)
+		require.Equal(t, []string{"/custom/cypress"}, result.MountPaths)
 	})
-}
 
-// MiseProvider tests
+	t.Run("uses default path when no env var set", func(t *testing.T) {
+		t.Setenv("CYPRESS_CACHE_FOLDER", "")
 
-func TestMiseProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and mise.toml exist", func(t *testing.T) {
-		req := mode.DetectRequest{
-			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/mise", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "mise.toml" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
-				},
-			},
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.MiseProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.CypressProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.Len(t, result.MountPaths, 1)
+		require.Contains(t, result.MountPaths[0], "Cypress")
+		require.NotContains(t, result.MountPaths[0], "%")
 	})
+}
 
-	t.Run("detected when binary and .tool-versions exist", func(t *testing.T) {
+// DenoProvider tests
+
+func TestDenoProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and lock file exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/mise", nil
+					return "/usr/local/bin/deno", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == ".tool-versions" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
+					require.Equal(t, "deno.lock", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -1042,17 +1133,17 @@ func TestMiseProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when config files missing", func(t *testing.T) {
+	t.Run("not detected when lock file missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/mise", nil
+					return "/usr/local/bin/deno", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -1060,122 +1151,144 @@ func TestMiseProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestMiseProvider_Plan(t *testing.T) {
-	t.Run("uses MISE_DATA_DIR when set", func(t *testing.T) {
-		t.Setenv("MISE_DATA_DIR", "/custom/mise/dir")
-
-		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{},
+func TestDenoProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"denoDir":"/home/user/.cache/deno"}`), nil
+				},
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/custom/mise/dir"}, result.MountPaths)
+		require.Equal(t, []string{"/home/user/.cache/deno"}, result.MountPaths)
 	})
 
-	t.Run("uses XDG_DATA_HOME when MISE_DATA_DIR not set", func(t *testing.T) {
-		t.Setenv("MISE_DATA_DIR", "")
-		t.Setenv("XDG_DATA_HOME", "/custom/xdg/data")
-
+	t.Run("npm cache inside denoDir is not mounted separately", func(t *testing.T) {
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{},
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"denoDir":"/home/user/.cache/deno","npmCache":"/home/user/.cache/deno/npm"}`), nil
+				},
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{filepath.Join("/custom/xdg/data", "mise")}, result.MountPaths)
+		require.Equal(t, []string{"/home/user/.cache/deno"}, result.MountPaths)
 	})
 
-	t.Run("uses default path when no env vars set", func(t *testing.T) {
-		t.Setenv("MISE_DATA_DIR", "")
-		t.Setenv("XDG_DATA_HOME", "")
-		t.Setenv("LOCALAPPDATA", "")
-
+	t.Run("npm cache outside denoDir is mounted", func(t *testing.T) {
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{},
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"denoDir":"/home/user/.cache/deno","npmCache":"/opt/npm-cache"}`), nil
+				},
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+			},
 		}
 
-		p := mode.MiseProvider{}
+		p := mode.DenoProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 1)
-		require.Contains(t, result.MountPaths[0], filepath.Join(".local", "share", "mise"))
+		require.Equal(t, []string{"/home/user/.cache/deno", "/opt/npm-cache"}, result.MountPaths)
 	})
-}
 
-// NixProvider tests
-
-func TestNixProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and flake.nix exist", func(t *testing.T) {
-		req := mode.DetectRequest{
+	t.Run("vendor dir mounted when enabled in deno.jsonc", func(t *testing.T) {
+		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/nix/var/nix/profiles/default/bin/nix", nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"denoDir":"/home/user/.cache/deno"}`), nil
 				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "flake.nix" {
-						return nil, nil
+				ReadFileFunc: func(name string) ([]byte, error) {
+					if name == "deno.jsonc" {
+						return []byte("{\n  // keep remote modules in the repo\n  \"vendor\": true\n}"), nil
 					}
 					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.NixProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.DenoProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.Equal(t, []string{"/home/user/.cache/deno", "./vendor"}, result.MountPaths)
 	})
 
-	t.Run("detected when binary and shell.nix exist", func(t *testing.T) {
-		req := mode.DetectRequest{
+	t.Run("vendor dir not mounted when disabled", func(t *testing.T) {
+		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/nix/var/nix/profiles/default/bin/nix", nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"denoDir":"/home/user/.cache/deno"}`), nil
 				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "shell.nix" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
+				ReadFileFunc: func(name string) ([]byte, error) {
+					require.Equal(t, "deno.json", name)
+					return []byte(`{"vendor": false, "tasks": {}}`), nil
 				},
 			},
 		}
 
-		p := mode.NixProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.DenoProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.Equal(t, []string{"/home/user/.cache/deno"}, result.MountPaths)
 	})
 
-	t.Run("detected when binary and default.nix exist", func(t *testing.T) {
+	t.Run("missing denoDir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"otherKey":"value"}`), nil
+				},
+			},
+		}
+
+		p := mode.DenoProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "denoDir not found")
+	})
+}
+
+// ElixirProvider tests
+
+func TestElixirProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and mix.lock exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/nix/var/nix/profiles/default/bin/nix", nil
+					return "/usr/local/bin/mix", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					if name == "default.nix" {
-						return nil, nil
-					}
-					return nil, os.ErrNotExist
+					require.Equal(t, "mix.lock", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.NixProvider{}
+		p := mode.ElixirProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -1187,17 +1300,17 @@ func TestNixProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.NixProvider{}
+		p := mode.ElixirProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when project files missing", func(t *testing.T) {
+	t.Run("not detected when mix.lock missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/nix/var/nix/profiles/default/bin/nix", nil
+					return "/usr/local/bin/mix", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -1205,49 +1318,91 @@ func TestNixProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.NixProvider{}
+		p := mode.ElixirProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestNixProvider_Plan(t *testing.T) {
-	t.Run("returns mount paths", func(t *testing.T) {
+func TestElixirProvider_Plan(t *testing.T) {
+	t.Run("returns default mount paths", func(t *testing.T) {
+		t.Setenv("HEX_HOME", "")
+		t.Setenv("MIX_HOME", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.NixProvider{}
+		p := mode.ElixirProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"~/.cache/nix", "/nix"}, result.MountPaths)
+		require.Equal(t, []string{"~/.hex", "~/.mix", "./_build", "./deps"}, result.MountPaths)
+	})
+
+	t.Run("honors HEX_HOME and MIX_HOME overrides", func(t *testing.T) {
+		t.Setenv("HEX_HOME", "/custom/hex")
+		t.Setenv("MIX_HOME", "/custom/mix")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.ElixirProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/hex", "/custom/mix", "./_build", "./deps"}, result.MountPaths)
 	})
 }
 
-// NpmProvider tests
+// FastlaneProvider tests
 
-func TestNpmProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and package-lock.json exist", func(t *testing.T) {
+func TestFastlaneProvider_Detect(t *testing.T) {
+	t.Run("detected when fastlane and fastlane/Fastfile exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/npm", nil
+					require.Equal(t, "fastlane", file)
+					return "/usr/local/bin/fastlane", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "package-lock.json", name)
+					require.Equal(t, "fastlane/Fastfile", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.NpmProvider{}
+		p := mode.FastlaneProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+	t.Run("detected with bundler and a top-level Fastfile", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					if file == "bundle" {
+						return "/usr/local/bin/bundle", nil
+					}
+					return "", exec.ErrNotFound
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "Fastfile" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.FastlaneProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when fastlane and bundler missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
@@ -1256,17 +1411,17 @@ func TestNpmProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.NpmProvider{}
+		p := mode.FastlaneProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when package-lock.json missing", func(t *testing.T) {
+	t.Run("not detected when Fastfile missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/npm", nil
+					return "/usr/local/bin/fastlane", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -1274,79 +1429,111 @@ func TestNpmProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.NpmProvider{}
+		p := mode.FastlaneProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestNpmProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted from npm config", func(t *testing.T) {
+func TestFastlaneProvider_Plan(t *testing.T) {
+	t.Run("mounts GEM_HOME when set", func(t *testing.T) {
+		t.Setenv("GEM_HOME", "/opt/gems")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.FastlaneProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.fastlane", "/opt/gems"}, result.MountPaths)
+		require.Equal(t, map[string]string{"GEM_HOME": "/opt/gems"}, result.AddEnvs)
+	})
+
+	t.Run("uses the user gem home reported by gem", func(t *testing.T) {
+		t.Setenv("GEM_HOME", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.npm\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/gem", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					require.Equal(t, "gem env user_gemhome", cmd.String())
+					return []byte("/home/runner/.local/share/gem/ruby/3.3.0\n"), nil
 				},
 			},
 		}
 
-		p := mode.NpmProvider{}
+		p := mode.FastlaneProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.npm"}, result.MountPaths)
+		require.Equal(t, []string{"~/.fastlane", "/home/runner/.local/share/gem/ruby/3.3.0"}, result.MountPaths)
+		require.Equal(t, "/home/runner/.local/share/gem/ruby/3.3.0", result.AddEnvs["GEM_HOME"])
 	})
 
-	t.Run("npm config get cache error is returned", func(t *testing.T) {
+	t.Run("only mounts fastlane dir when gem is missing", func(t *testing.T) {
+		t.Setenv("GEM_HOME", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return nil, fmt.Errorf("npm config failed")
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.NpmProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "npm config get cache")
+		p := mode.FastlaneProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.fastlane"}, result.MountPaths)
+		require.Nil(t, result.AddEnvs)
 	})
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
+	t.Run("returns error when gem env fails", func(t *testing.T) {
+		t.Setenv("GEM_HOME", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(""), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/gem", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return nil, fmt.Errorf("gem failed")
 				},
 			},
 		}
 
-		p := mode.NpmProvider{}
+		p := mode.FastlaneProvider{}
 		_, err := p.Plan(t.Context(), req)
 		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
 
-// PlaywrightProvider tests
+// FoundryProvider tests
 
-func TestPlaywrightProvider_Detect(t *testing.T) {
-	t.Run("detected", func(t *testing.T) {
+func TestFoundryProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and foundry.toml exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/playwright", nil
+					return "/home/runner/.foundry/bin/forge", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "foundry.toml", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.PlaywrightProvider{}
+		p := mode.FoundryProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected", func(t *testing.T) {
+	t.Run("not detected when binary missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
@@ -1355,464 +1542,3646 @@ func TestPlaywrightProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.PlaywrightProvider{}
+		p := mode.FoundryProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when foundry.toml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/home/runner/.foundry/bin/forge", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.FoundryProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestPlaywrightProvider_Plan(t *testing.T) {
-	t.Run("uses PLAYWRIGHT_BROWSERS_PATH when set", func(t *testing.T) {
-		t.Setenv("PLAYWRIGHT_BROWSERS_PATH", "/custom/playwright/path")
+func TestFoundryProvider_Plan(t *testing.T) {
+	t.Run("returns default mount paths", func(t *testing.T) {
+		t.Setenv("FOUNDRY_CACHE_PATH", "")
+		t.Setenv("FOUNDRY_OUT", "")
 
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.PlaywrightProvider{}
+		p := mode.FoundryProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/custom/playwright/path"}, result.MountPaths)
+		require.Equal(t, []string{"~/.foundry/cache", "./cache", "./out"}, result.MountPaths)
 	})
 
-	t.Run("uses default path when no env var set", func(t *testing.T) {
-		t.Setenv("PLAYWRIGHT_BROWSERS_PATH", "")
+	t.Run("honors FOUNDRY_CACHE_PATH and FOUNDRY_OUT", func(t *testing.T) {
+		t.Setenv("FOUNDRY_CACHE_PATH", "forge-cache")
+		t.Setenv("FOUNDRY_OUT", "build")
 
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.PlaywrightProvider{}
+		p := mode.FoundryProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Len(t, result.MountPaths, 1)
-		require.Contains(t, result.MountPaths[0], "ms-playwright")
-		require.NotContains(t, result.MountPaths[0], "%")
+		require.Equal(t, []string{"~/.foundry/cache", "forge-cache", "build"}, result.MountPaths)
 	})
 }
 
-// PnpmProvider tests
+// GoProvider tests
 
-func TestPnpmProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and pnpm-lock.yaml exist", func(t *testing.T) {
+func TestGoProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and go.mod exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/pnpm", nil
+					return "/usr/bin/go", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "pnpm-lock.yaml", name)
-					return nil, nil
+					if name == "go.mod" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.GoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+	t.Run("detected when binary and go.work exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+					return "/usr/bin/go", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "go.work" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.GoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when pnpm-lock.yaml missing", func(t *testing.T) {
+	t.Run("not detected when binary missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/pnpm", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.GoProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
-}
 
-func TestPnpmProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted with new version", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-		callCount := 0
+	t.Run("not detected when go.mod and go.work missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/go", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GoProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestGoProvider_Plan(t *testing.T) {
+	t.Run("cache paths extracted", func(t *testing.T) {
+		goEnvOutput := []byte(`{"GOCACHE":"/home/user/.cache/go-build","GOMODCACHE":"/home/user/go/pkg/mod"}`)
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("9.7.0\n"), nil // version
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return goEnvOutput, nil
+				},
+			},
+		}
+
+		p := mode.GoProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(result.MountPaths))
+		require.Equal(t, "/home/user/.cache/go-build", result.MountPaths[0])
+		require.Equal(t, "/home/user/go/pkg/mod", result.MountPaths[1])
+		require.Equal(t, []string{"go.sum", "go.work.sum"}, result.KeyFiles)
+	})
+}
+
+func TestProviders_Trim(t *testing.T) {
+	for _, tc := range []struct {
+		provider mode.ModeProvider
+		command  string
+	}{
+		{mode.GoProvider{}, "go clean -testcache"},
+		{mode.NpmProvider{}, "npm cache verify"},
+		{mode.PnpmProvider{}, "pnpm store prune"},
+		{mode.UVProvider{}, "uv cache prune --ci"},
+	} {
+		t.Run(tc.provider.Name(), func(t *testing.T) {
+			trimmer, ok := tc.provider.(mode.Trimmer)
+			require.True(t, ok)
+
+			var commands []string
+			err := trimmer.Trim(t.Context(), mode.TrimRequest{
+				Exec: &mode.ExecutorMock{
+					RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+						commands = append(commands, cmd.String())
+						return nil, nil
+					},
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{tc.command}, commands)
+		})
+	}
+}
+
+// GolangCILintProvider tests
+
+func TestGolangCILintProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and .golangci.yml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/golangci-lint", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == ".golangci.yml" {
+						return nil, nil
 					}
-					return []byte("/home/user/.local/share/pnpm/store/v3\n"), nil
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.GolangCILintProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and .golangci.yaml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/golangci-lint", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == ".golangci.yaml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GolangCILintProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.GolangCILintProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when config files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/golangci-lint", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GolangCILintProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestGolangCILintProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
+		cacheStatusOutput := []byte(`
+			Dir: /home/user/.cache/golangci-lint
+			Size: 123MB
+		`)
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return cacheStatusOutput, nil
+				},
+			},
+		}
+
+		p := mode.GolangCILintProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
-		require.Equal(t, map[string]string{"npm_config_package_import_method": "copy"}, result.AddEnvs)
+		require.Equal(t, 1, len(result.MountPaths))
+		require.Equal(t, "/home/user/.cache/golangci-lint", result.MountPaths[0])
 	})
 
-	t.Run("redirects store via PNPM_CONFIG_STORE_DIR for pnpm v11+", func(t *testing.T) {
-		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
-		t.Setenv("PNPM_HOME", pnpmHome)
-		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
-		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+	t.Run("uses default path when dir not in output", func(t *testing.T) {
+		cacheStatusOutput := []byte(`
+			Size: 123MB
+		`)
 
-		callCount := 0
 		req := mode.PlanRequest{
-			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return cacheStatusOutput, nil
+				},
+			},
+		}
+
+		p := mode.GolangCILintProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+
+		cacheHome, err := os.UserCacheDir()
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(cacheHome, "golangci-lint")}, result.MountPaths)
+	})
+}
+
+// GoreleaserProvider tests
+
+func TestGoreleaserProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and .goreleaser.yaml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/goreleaser", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == ".goreleaser.yaml" {
+						return nil, nil
 					}
-					return []byte(pnpmHome + "/store/v11\n"), nil
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.GoreleaserProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and goreleaser.yml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/goreleaser", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "goreleaser.yml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when config missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/goreleaser", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestGoreleaserProvider_Plan(t *testing.T) {
+	t.Run("mounts only the cache dir when go is missing", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Empty(t, result.MountPaths)
-		require.Equal(t, []string{"pnpm-store"}, result.CacheDirs)
-		require.Equal(t, filepath.Join(req.CacheRoot, "pnpm-store"), result.AddEnvs["PNPM_CONFIG_STORE_DIR"])
-		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
-		require.Equal(t, "copy", result.AddEnvs["npm_config_package_import_method"])
+		require.Equal(t, []string{"~/.cache/goreleaser"}, result.MountPaths)
 	})
 
-	t.Run("redirects store via NPM_CONFIG_STORE_DIR for pnpm <11", func(t *testing.T) {
-		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
-		t.Setenv("PNPM_HOME", pnpmHome)
-		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
-		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+	t.Run("mounts GOPATH/bin when it does not exist yet", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", filepath.Join("opt", "cache"))
 
-		callCount := 0
 		req := mode.PlanRequest{
-			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("10.0.0\n"), nil
-					}
-					return []byte(pnpmHome + "/store/v10\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/go/bin/go", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					require.Equal(t, "go env -json GOBIN GOPATH", cmd.String())
+					return []byte(`{"GOBIN":"","GOPATH":"/home/runner/go"}`), nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					require.Equal(t, filepath.Join("/home/runner/go", "bin"), name)
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join("opt", "cache", "goreleaser"),
+			filepath.Join("/home/runner/go", "bin"),
+		}, result.MountPaths)
+	})
+
+	t.Run("prefers GOBIN", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/go/bin/go", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"GOBIN":"/opt/gobin","GOPATH":"/home/runner/go"}`), nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					require.Equal(t, "/opt/gobin", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/goreleaser", "/opt/gobin"}, result.MountPaths)
+	})
+
+	t.Run("skips a populated bin dir", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/go/bin/go", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"GOBIN":"","GOPATH":"/home/runner/go"}`), nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{mockDirEntry{name: "golangci-lint"}}, nil
+				},
+			},
+		}
+
+		p := mode.GoreleaserProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/goreleaser"}, result.MountPaths)
+	})
+}
+
+// GradleProvider tests
+
+func TestGradleProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and gradlew exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/gradle", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "gradlew" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GradleProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and build.gradle exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/gradle", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "build.gradle" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GradleProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.GradleProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when gradlew and build.gradle missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/gradle", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.GradleProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestGradleProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
+		p := mode.GradleProvider{}
+		result, err := p.Plan(t.Context(), mode.PlanRequest{})
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 3)
+		require.Equal(t, "~/.gradle/caches", result.MountPaths[0])
+		require.Equal(t, "~/.gradle/wrapper", result.MountPaths[1])
+		require.Equal(t, "./.gradle/configuration-cache", result.MountPaths[2])
+	})
+}
+
+// HardhatProvider tests
+
+func TestHardhatProvider_Detect(t *testing.T) {
+	t.Run("detected when hardhat.config.ts exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+						mockDirEntry{name: "hardhat.config.ts"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.HardhatProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when config missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.HardhatProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestHardhatProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("hardhat compilers dir is platform specific")
+		}
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.HardhatProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./cache", "./artifacts", "~/.cache/hardhat-nodejs"}, result.MountPaths)
+	})
+
+	t.Run("honors XDG_CACHE_HOME", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("XDG_CACHE_HOME is only used on linux")
+		}
+		t.Setenv("XDG_CACHE_HOME", "/opt/cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.HardhatProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, "/opt/cache/hardhat-nodejs", result.MountPaths[2])
+	})
+}
+
+// HaskellProvider tests
+
+func TestHaskellProvider_Detect(t *testing.T) {
+	t.Run("detected when stack and stack.yaml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					require.Equal(t, "stack", file)
+					return "/usr/local/bin/stack", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "stack.yaml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when cabal and .cabal file exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					if file == "cabal" {
+						return "/usr/local/bin/cabal", nil
+					}
+					return "", exec.ErrNotFound
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "my-app.cabal"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binaries missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when project files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/" + file, nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "README.md"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestHaskellProvider_Plan(t *testing.T) {
+	t.Run("returns stack paths when stack.yaml exists", func(t *testing.T) {
+		t.Setenv("STACK_ROOT", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.stack", "./.stack-work"}, result.MountPaths)
+	})
+
+	t.Run("honors STACK_ROOT override", func(t *testing.T) {
+		t.Setenv("STACK_ROOT", "/custom/stack")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/stack", "./.stack-work"}, result.MountPaths)
+	})
+
+	t.Run("returns cabal store without stack.yaml", func(t *testing.T) {
+		t.Setenv("CABAL_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cabal/store"}, result.MountPaths)
+	})
+
+	t.Run("honors CABAL_DIR override", func(t *testing.T) {
+		t.Setenv("CABAL_DIR", "/custom/cabal")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.HaskellProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/cabal/store"}, result.MountPaths)
+	})
+}
+
+// HelmProvider tests
+
+func TestHelmProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Chart.yaml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/helm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "Chart.yaml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.HelmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.HelmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when Chart.yaml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/helm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.HelmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestHelmProvider_Plan(t *testing.T) {
+	t.Run("honors HELM_CACHE_HOME", func(t *testing.T) {
+		t.Setenv("HELM_CACHE_HOME", "/opt/helm-cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.HelmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/opt/helm-cache"}, result.MountPaths)
+	})
+
+	t.Run("honors XDG_CACHE_HOME", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("XDG_CACHE_HOME is only used on linux")
+		}
+		t.Setenv("HELM_CACHE_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "/opt/cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.HelmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/opt/cache/helm"}, result.MountPaths)
+	})
+
+	t.Run("falls back to the default cache dir", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("default helm cache dir is platform specific")
+		}
+		t.Setenv("HELM_CACHE_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.HelmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/helm"}, result.MountPaths)
+	})
+}
+
+// JestProvider tests
+
+func TestJestProvider_Detect(t *testing.T) {
+	t.Run("detected when jest.config.ts exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+						mockDirEntry{name: "jest.config.ts"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.JestProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when config missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.JestProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestJestProvider_Plan(t *testing.T) {
+	t.Run("returns default cache directory", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.JestProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Equal(t, os.TempDir(), filepath.Dir(result.MountPaths[0]))
+		require.Contains(t, filepath.Base(result.MountPaths[0]), "jest_")
+	})
+}
+
+// JuliaProvider tests
+
+func TestJuliaProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Project.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/julia", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "Project.toml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.JuliaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and Manifest.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/julia", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "Manifest.toml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.JuliaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.JuliaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when project files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/julia", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.JuliaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestJuliaProvider_Plan(t *testing.T) {
+	t.Run("returns default depot path", func(t *testing.T) {
+		t.Setenv("JULIA_DEPOT_PATH", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.JuliaProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.julia"}, result.MountPaths)
+	})
+
+	t.Run("uses first entry of JULIA_DEPOT_PATH", func(t *testing.T) {
+		t.Setenv("JULIA_DEPOT_PATH", "/custom/julia"+string(os.PathListSeparator)+"/shared/julia")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.JuliaProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/julia"}, result.MountPaths)
+	})
+
+	t.Run("empty first entry falls back to default", func(t *testing.T) {
+		t.Setenv("JULIA_DEPOT_PATH", string(os.PathListSeparator)+"/shared/julia")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.JuliaProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.julia"}, result.MountPaths)
+	})
+}
+
+// KotlinNativeProvider tests
+
+func TestKotlinNativeProvider_Detect(t *testing.T) {
+	t.Run("detected when kotlinc-native exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					require.Equal(t, "kotlinc-native", file)
+					return "/usr/local/bin/kotlinc-native", nil
+				},
+			},
+		}
+
+		p := mode.KotlinNativeProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when only konanc exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					if file == "konanc" {
+						return "/usr/local/bin/konanc", nil
+					}
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.KotlinNativeProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when no binary exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.KotlinNativeProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestKotlinNativeProvider_Plan(t *testing.T) {
+	t.Run("returns default konan path", func(t *testing.T) {
+		t.Setenv("KONAN_DATA_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.KotlinNativeProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Equal(t, "~/.konan", result.MountPaths[0])
+	})
+
+	t.Run("honors KONAN_DATA_DIR override", func(t *testing.T) {
+		t.Setenv("KONAN_DATA_DIR", "/custom/konan")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.KotlinNativeProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Equal(t, "/custom/konan", result.MountPaths[0])
+	})
+}
+
+// LernaProvider tests
+
+func TestLernaProvider_Detect(t *testing.T) {
+	t.Run("detected when lerna.json exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "lerna.json", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.LernaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when lerna.json missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.LernaProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestLernaProvider_Plan(t *testing.T) {
+	t.Run("mounts the nx cache", func(t *testing.T) {
+		t.Setenv("NX_CACHE_DIRECTORY", "")
+
+		req := mode.PlanRequest{
+			EnabledModes: []string{"lerna"},
+			Exec:         &mode.ExecutorMock{},
+		}
+
+		p := mode.LernaProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./.nx/cache", "./node_modules/.cache/nx"}, result.MountPaths)
+	})
+
+	t.Run("defers to nx mode when enabled", func(t *testing.T) {
+		order, err := mode.Modes{mode.LernaProvider{}, mode.NxProvider{}}.Order()
+		require.NoError(t, err)
+		require.Equal(t, []string{"nx"}, order.Names())
+		require.Equal(t, map[string]string{"lerna": "nx"}, order.Skipped)
+	})
+}
+
+// MavenProvider tests
+
+func TestMavenProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and pom.xml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/mvn", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "pom.xml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.MavenProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.MavenProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when pom.xml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/mvn", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.MavenProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestMavenProvider_Plan(t *testing.T) {
+	t.Run("returns mount path", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.MavenProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.m2/repository"}, result.MountPaths)
+	})
+}
+
+// MiseProvider tests
+
+func TestMiseProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and mise.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/mise", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "mise.toml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.MiseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and .tool-versions exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/mise", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == ".tool-versions" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.MiseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.MiseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when config files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/mise", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.MiseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestMiseProvider_Plan(t *testing.T) {
+	t.Run("uses MISE_DATA_DIR when set", func(t *testing.T) {
+		t.Setenv("MISE_DATA_DIR", "/custom/mise/dir")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.MiseProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/mise/dir"}, result.MountPaths)
+	})
+
+	t.Run("uses XDG_DATA_HOME when MISE_DATA_DIR not set", func(t *testing.T) {
+		t.Setenv("MISE_DATA_DIR", "")
+		t.Setenv("XDG_DATA_HOME", "/custom/xdg/data")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.MiseProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("/custom/xdg/data", "mise")}, result.MountPaths)
+	})
+
+	t.Run("uses default path when no env vars set", func(t *testing.T) {
+		t.Setenv("MISE_DATA_DIR", "")
+		t.Setenv("XDG_DATA_HOME", "")
+		t.Setenv("LOCALAPPDATA", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.MiseProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Contains(t, result.MountPaths[0], filepath.Join(".local", "share", "mise"))
+	})
+}
+
+// NixProvider tests
+
+func TestNixProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and flake.nix exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/nix/var/nix/profiles/default/bin/nix", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "flake.nix" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NixProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and shell.nix exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/nix/var/nix/profiles/default/bin/nix", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "shell.nix" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NixProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and default.nix exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/nix/var/nix/profiles/default/bin/nix", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "default.nix" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NixProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.NixProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when project files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/nix/var/nix/profiles/default/bin/nix", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NixProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestNixProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.NixProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/nix", "/nix"}, result.MountPaths)
+	})
+}
+
+// NpmProvider tests
+
+func TestNpmProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and package-lock.json exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/npm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "package-lock.json", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when package-lock.json missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/npm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestNpmProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted from npm config", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.npm\n"), nil
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.npm"}, result.MountPaths)
+	})
+
+	t.Run("npm config get cache error is returned", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return nil, fmt.Errorf("npm config failed")
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "npm config get cache")
+	})
+
+	t.Run("empty cache dir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(""), nil
+				},
+			},
+		}
+
+		p := mode.NpmProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
+	})
+}
+
+// NvmProvider tests
+
+func TestNvmProvider_Detect(t *testing.T) {
+	t.Run("detected when .nvmrc exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, ".nvmrc", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.NvmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when .nvmrc missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NvmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestNvmProvider_Plan(t *testing.T) {
+	t.Run("returns default versions dir", func(t *testing.T) {
+		t.Setenv("NVM_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.NvmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.nvm/versions"}, result.MountPaths)
+	})
+
+	t.Run("honors NVM_DIR", func(t *testing.T) {
+		t.Setenv("NVM_DIR", filepath.Join("opt", "nvm"))
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.NvmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("opt", "nvm", "versions")}, result.MountPaths)
+	})
+}
+
+// NxProvider tests
+
+func TestNxProvider_Detect(t *testing.T) {
+	t.Run("detected when nx.json exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "nx.json", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.NxProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when nx.json missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.NxProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestNxProvider_Plan(t *testing.T) {
+	t.Run("returns default cache paths", func(t *testing.T) {
+		t.Setenv("NX_CACHE_DIRECTORY", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.NxProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./.nx/cache", "./node_modules/.cache/nx"}, result.MountPaths)
+	})
+
+	t.Run("honors NX_CACHE_DIRECTORY override", func(t *testing.T) {
+		t.Setenv("NX_CACHE_DIRECTORY", "/custom/nx")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.NxProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/nx"}, result.MountPaths)
+	})
+}
+
+// OpamProvider tests
+
+func TestOpamProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and dune-project exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/opam", nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "dune-project"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.OpamProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when binary and .opam file exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/opam", nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "mylib.opam"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.OpamProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.OpamProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when project files missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/opam", nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "main.ml"},
+					}, nil
+				},
+			},
+		}
+
+		p := mode.OpamProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestOpamProvider_Plan(t *testing.T) {
+	t.Run("returns default mount paths", func(t *testing.T) {
+		t.Setenv("OPAMROOT", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.OpamProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.opam", "./_build"}, result.MountPaths)
+		require.Empty(t, result.AddEnvs)
+	})
+
+	t.Run("exports OPAMROOT when relocated", func(t *testing.T) {
+		t.Setenv("OPAMROOT", "/custom/opam")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.OpamProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/opam", "./_build"}, result.MountPaths)
+		require.Equal(t, map[string]string{"OPAMROOT": "/custom/opam"}, result.AddEnvs)
+	})
+}
+
+// PantsProvider tests
+
+func TestPantsProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and pants.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pants", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "pants.toml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PantsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PantsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when pants.toml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pants", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PantsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPantsProvider_Plan(t *testing.T) {
+	t.Run("returns default cache dir", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PantsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/pants"}, result.MountPaths)
+	})
+
+	t.Run("honors XDG_CACHE_HOME", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", filepath.Join("opt", "cache"))
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PantsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("opt", "cache", "pants")}, result.MountPaths)
+	})
+}
+
+// PipenvProvider tests
+
+func TestPipenvProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Pipfile.lock exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/pipenv", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "Pipfile.lock", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when Pipfile.lock missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/pipenv", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPipenvProvider_Plan(t *testing.T) {
+	t.Run("uses PIPENV_CACHE_DIR and pip cache dir", func(t *testing.T) {
+		t.Setenv("PIPENV_CACHE_DIR", "/custom/pipenv")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					require.Equal(t, "pip", file)
+					return "/usr/bin/pip", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.cache/pip\n"), nil
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/pipenv", "/home/user/.cache/pip"}, result.MountPaths)
+		require.Equal(t, map[string]string{"PIPENV_CACHE_DIR": "/custom/pipenv"}, result.AddEnvs)
+	})
+
+	t.Run("uses default path and skips pip when missing", func(t *testing.T) {
+		t.Setenv("PIPENV_CACHE_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Equal(t, "pipenv", filepath.Base(result.MountPaths[0]))
+		require.Equal(t, result.MountPaths[0], result.AddEnvs["PIPENV_CACHE_DIR"])
+	})
+
+	t.Run("pip cache dir error is returned", func(t *testing.T) {
+		t.Setenv("PIPENV_CACHE_DIR", "/custom/pipenv")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/pip", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return nil, fmt.Errorf("pip failed")
+				},
+			},
+		}
+
+		p := mode.PipenvProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "pip cache dir")
+	})
+}
+
+// PixiProvider tests
+
+func TestPixiProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and pixi.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/home/runner/.pixi/bin/pixi", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "pixi.toml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PixiProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PixiProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when pixi.toml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/home/runner/.pixi/bin/pixi", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PixiProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPixiProvider_Plan(t *testing.T) {
+	t.Run("honors PIXI_CACHE_DIR", func(t *testing.T) {
+		t.Setenv("PIXI_CACHE_DIR", "/opt/pixi-cache")
+		t.Setenv("RATTLER_CACHE_DIR", "/opt/rattler-cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PixiProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/opt/pixi-cache", "./.pixi"}, result.MountPaths)
+	})
+
+	t.Run("honors RATTLER_CACHE_DIR", func(t *testing.T) {
+		t.Setenv("PIXI_CACHE_DIR", "")
+		t.Setenv("RATTLER_CACHE_DIR", "/opt/rattler-cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PixiProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/opt/rattler-cache", "./.pixi"}, result.MountPaths)
+	})
+
+	t.Run("falls back to the default cache dir", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("default rattler cache dir is platform specific")
+		}
+		t.Setenv("PIXI_CACHE_DIR", "")
+		t.Setenv("RATTLER_CACHE_DIR", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PixiProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/rattler/cache", "./.pixi"}, result.MountPaths)
+	})
+}
+
+// PlaywrightProvider tests
+
+func TestPlaywrightProvider_Detect(t *testing.T) {
+	t.Run("detected", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/playwright", nil
+				},
+			},
+		}
+
+		p := mode.PlaywrightProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PlaywrightProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPlaywrightProvider_Plan(t *testing.T) {
+	t.Run("uses PLAYWRIGHT_BROWSERS_PATH when set", func(t *testing.T) {
+		t.Setenv("PLAYWRIGHT_BROWSERS_PATH", "/custom/playwright/path")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PlaywrightProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/playwright/path"}, result.MountPaths)
+	})
+
+	t.Run("uses default path when no env var set", func(t *testing.T) {
+		t.Setenv("PLAYWRIGHT_BROWSERS_PATH", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PlaywrightProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Contains(t, result.MountPaths[0], "ms-playwright")
+		require.NotContains(t, result.MountPaths[0], "%")
+	})
+}
+
+// PleaseProvider tests
+
+func TestPleaseProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and .plzconfig exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/plz", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, ".plzconfig", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PleaseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PleaseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when .plzconfig missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/plz", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PleaseProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPleaseProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PleaseProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./plz-out", "~/.cache/please"}, result.MountPaths)
+	})
+
+	t.Run("honors XDG_CACHE_HOME", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", filepath.Join("opt", "cache"))
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PleaseProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./plz-out", filepath.Join("opt", "cache", "please")}, result.MountPaths)
+	})
+}
+
+// PnpmProvider tests
+
+func TestPnpmProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and pnpm-lock.yaml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pnpm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "pnpm-lock.yaml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when pnpm-lock.yaml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pnpm", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPnpmProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted with new version", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("9.7.0\n"), nil // version
+					}
+					return []byte("/home/user/.local/share/pnpm/store/v3\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+		require.Equal(t, map[string]string{"npm_config_package_import_method": "copy"}, result.AddEnvs)
+	})
+
+	t.Run("redirects store via PNPM_CONFIG_STORE_DIR for pnpm v11+", func(t *testing.T) {
+		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
+		t.Setenv("PNPM_HOME", pnpmHome)
+		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
+		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte(pnpmHome + "/store/v11\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Empty(t, result.MountPaths)
+		require.Equal(t, []string{"pnpm-store"}, result.CacheDirs)
+		require.Equal(t, filepath.Join(req.CacheRoot, "pnpm-store"), result.AddEnvs["PNPM_CONFIG_STORE_DIR"])
+		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
+		require.Equal(t, "copy", result.AddEnvs["npm_config_package_import_method"])
+	})
+
+	t.Run("redirects store via NPM_CONFIG_STORE_DIR for pnpm <11", func(t *testing.T) {
+		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
+		t.Setenv("PNPM_HOME", pnpmHome)
+		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
+		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("10.0.0\n"), nil
+					}
+					return []byte(pnpmHome + "/store/v10\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Empty(t, result.MountPaths)
+		require.Equal(t, []string{"pnpm-store"}, result.CacheDirs)
+		require.Equal(t, filepath.Join(req.CacheRoot, "pnpm-store"), result.AddEnvs["NPM_CONFIG_STORE_DIR"])
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.Equal(t, "copy", result.AddEnvs["npm_config_package_import_method"])
+	})
+
+	t.Run("uses original mount when store path is outside PNPM_HOME", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "/home/runner/setup-pnpm/node_modules/.bin")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte("/home/runner/.local/share/pnpm/store/v11\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/runner/.local/share/pnpm/store/v11"}, result.MountPaths)
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+	})
+
+	t.Run("uses original mount when PNPM_HOME is unset", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte("/home/runner/setup-pnpm/node_modules/.bin/store/v11\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/runner/setup-pnpm/node_modules/.bin/store/v11"}, result.MountPaths)
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+	})
+
+	t.Run("uses original mount when path shares prefix but is not a descendant", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "/home/runner/setup-pnpm/node_modules/.bin")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte("/home/runner/setup-pnpm/node_modules/.bin-other/store/v11\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/runner/setup-pnpm/node_modules/.bin-other/store/v11"}, result.MountPaths)
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+	})
+
+	t.Run("respects user-set PNPM_CONFIG_STORE_DIR", func(t *testing.T) {
+		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
+		t.Setenv("PNPM_HOME", pnpmHome)
+		t.Setenv("PNPM_CONFIG_STORE_DIR", pnpmHome+"/custom-store")
+		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte(pnpmHome + "/custom-store\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{pnpmHome + "/custom-store"}, result.MountPaths)
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
+		require.Len(t, result.Warnings, 1)
+		require.Contains(t, result.Warnings[0], "inside PNPM_HOME")
+	})
+
+	t.Run("respects user-set NPM_CONFIG_STORE_DIR (legacy pnpm < 11)", func(t *testing.T) {
+		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
+		t.Setenv("PNPM_HOME", pnpmHome)
+		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
+		t.Setenv("NPM_CONFIG_STORE_DIR", pnpmHome+"/custom-store")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			CacheRoot: "/cache",
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("10.0.0\n"), nil
+					}
+					return []byte(pnpmHome + "/custom-store\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{pnpmHome + "/custom-store"}, result.MountPaths)
+		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
+	})
+
+	t.Run("errors when cache root is unset and store would shadow binary", func(t *testing.T) {
+		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
+		t.Setenv("PNPM_HOME", pnpmHome)
+		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
+		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("11.0.0\n"), nil
+					}
+					return []byte(pnpmHome + "/store/v11\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "cache root")
+	})
+
+	t.Run("old version extracts version from last line when warnings present", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						// pnpm < 9.7.0 prints warnings to stdout, version is on last line
+						return []byte("\u2009WARN\u2009 some warning\n9.6.0\n"), nil
+					}
+					return []byte("\u2009WARN\u2009 some warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+	})
+
+	t.Run("cache path extracted with old version drops single warning", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("9.6.0\n"), nil // old version
+					}
+					// pnpm uses thin spaces (\u2009) around WARN
+					return []byte("\u2009WARN\u2009 some warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+	})
+
+	t.Run("cache path extracted with old version drops multiple warnings", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("9.5.0\n"), nil // old version
+					}
+					// Multiple warning lines with thin spaces
+					return []byte("\u2009WARN\u2009 deprecated package\n\u2009WARN\u2009 another warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+	})
+
+	t.Run("new version does not filter warnings", func(t *testing.T) {
+		t.Setenv("PNPM_HOME", "")
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("9.7.0\n"), nil // new version
+					}
+					// New versions don't print warnings to stdout with --loglevel error
+					return []byte("/home/user/.local/share/pnpm/store/v3\n"), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+	})
+
+	t.Run("empty cache dir returns error", func(t *testing.T) {
+		callCount := 0
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					callCount++
+					if callCount == 1 {
+						return []byte("9.7.0\n"), nil
+					}
+					return []byte(""), nil
+				},
+			},
+		}
+
+		p := mode.PnpmProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
+	})
+}
+
+// PoetryProvider tests
+
+func TestPoetryProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and poetry.lock exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/poetry", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "poetry.lock", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PoetryProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PoetryProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when poetry.lock missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/poetry", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PoetryProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPoetryProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.cache/pypoetry\n"), nil
+				},
+			},
+		}
+
+		p := mode.PoetryProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.cache/pypoetry"}, result.MountPaths)
+	})
+
+	t.Run("empty cache dir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(""), nil
+				},
+			},
+		}
+
+		p := mode.PoetryProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
+	})
+}
+
+// PreCommitProvider tests
+
+func TestPreCommitProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and config exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pre-commit", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, ".pre-commit-config.yaml", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PreCommitProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PreCommitProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when config missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/pre-commit", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PreCommitProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPreCommitProvider_Plan(t *testing.T) {
+	t.Run("uses PRE_COMMIT_HOME when set", func(t *testing.T) {
+		t.Setenv("PRE_COMMIT_HOME", "/custom/pre-commit")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PreCommitProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/pre-commit"}, result.MountPaths)
+	})
+
+	t.Run("uses XDG_CACHE_HOME when PRE_COMMIT_HOME not set", func(t *testing.T) {
+		t.Setenv("PRE_COMMIT_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "/custom/xdg")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PreCommitProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("/custom/xdg", "pre-commit")}, result.MountPaths)
+	})
+
+	t.Run("uses default path when no env vars set", func(t *testing.T) {
+		t.Setenv("PRE_COMMIT_HOME", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.PreCommitProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"~/.cache/pre-commit"}, result.MountPaths)
+	})
+}
+
+// PythonProvider tests
+
+func TestPythonProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and requirements.txt exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/pip", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "requirements.txt", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.PythonProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.PythonProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when requirements.txt missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/pip", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestPythonProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.cache/pip\n"), nil
+				},
+			},
+		}
+
+		p := mode.PythonProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.cache/pip"}, result.MountPaths)
+	})
+
+	t.Run("empty cache dir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(""), nil
+				},
+			},
+		}
+
+		p := mode.PythonProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
+	})
+}
+
+// PythonToolsProvider tests
+
+func TestPythonToolsProvider_Detect(t *testing.T) {
+	t.Run("detected when pyproject.toml configures ruff", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					require.Equal(t, "pyproject.toml", name)
+					return []byte("[project]\nname = \"app\"\n\n[tool.ruff.lint]\nselect = [\"E\"]\n"), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("detected when pytest.ini exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "pytest.ini" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected without tool configuration", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return []byte("[project]\nname = \"app\"\n[tool.rufflike]\n"), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("read error is returned", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrPermission
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		_, err := p.Detect(t.Context(), req)
+		require.ErrorIs(t, err, os.ErrPermission)
+	})
+}
+
+func TestPythonToolsProvider_Plan(t *testing.T) {
+	t.Run("mounts caches of configured tools", func(t *testing.T) {
+		t.Setenv("MYPY_CACHE_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return []byte("[tool.pytest.ini_options]\n[tool.mypy]\n"), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./.pytest_cache", "./.mypy_cache"}, result.MountPaths)
+	})
+
+	t.Run("honors cache dir overrides", func(t *testing.T) {
+		t.Setenv("RUFF_CACHE_DIR", "/custom/ruff")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "ruff.toml" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/ruff"}, result.MountPaths)
+	})
+
+	t.Run("mounts all caches when nothing is configured", func(t *testing.T) {
+		t.Setenv("MYPY_CACHE_DIR", "")
+		t.Setenv("RUFF_CACHE_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.PythonToolsProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./.pytest_cache", "./.mypy_cache", "./.ruff_cache"}, result.MountPaths)
+	})
+}
+
+// RenvProvider tests
+
+func TestRenvProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and renv.lock exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					require.Equal(t, "Rscript", file)
+					return "/usr/bin/Rscript", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "renv.lock", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.RenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.RenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when renv.lock missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/Rscript", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.RenvProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestRenvProvider_Plan(t *testing.T) {
+	t.Run("uses RENV_PATHS_CACHE when set", func(t *testing.T) {
+		t.Setenv("RENV_PATHS_CACHE", "/custom/renv")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RenvProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/renv"}, result.MountPaths)
+	})
+
+	t.Run("uses R_USER_CACHE_DIR when RENV_PATHS_CACHE not set", func(t *testing.T) {
+		t.Setenv("RENV_PATHS_CACHE", "")
+		t.Setenv("R_USER_CACHE_DIR", "/custom/r-cache")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RenvProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("/custom/r-cache", "R", "renv")}, result.MountPaths)
+	})
+
+	t.Run("uses default path when no env vars set", func(t *testing.T) {
+		t.Setenv("RENV_PATHS_CACHE", "")
+		t.Setenv("R_USER_CACHE_DIR", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RenvProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 1)
+		require.Contains(t, result.MountPaths[0], "renv")
+	})
+}
+
+// RushProvider tests
+
+func TestRushProvider_Detect(t *testing.T) {
+	t.Run("detected when rush.json exists", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "rush.json", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.RushProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when rush.json missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.RushProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestRushProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
+		t.Setenv("RUSH_PNPM_STORE_PATH", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RushProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./common/temp/build-cache", "./common/temp/pnpm-store"}, result.MountPaths)
+	})
+
+	t.Run("honors RUSH_PNPM_STORE_PATH", func(t *testing.T) {
+		t.Setenv("RUSH_PNPM_STORE_PATH", "/opt/rush-store")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RushProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./common/temp/build-cache", "/opt/rush-store"}, result.MountPaths)
+	})
+}
+
+// RubyProvider tests
+
+func TestRubyProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Gemfile exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/bundle", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "Gemfile", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.RubyProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.RubyProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when Gemfile missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/bundle", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.RubyProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestRubyProvider_Plan(t *testing.T) {
+	t.Run("returns vendor paths", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.RubyProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./vendor/bundle", "./vendor/cache"}, result.MountPaths)
+	})
+}
+
+// RustProvider tests
+
+func TestRustProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Cargo.toml exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/cargo", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "Cargo.toml", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.RustProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Empty(t, result.MountPaths)
-		require.Equal(t, []string{"pnpm-store"}, result.CacheDirs)
-		require.Equal(t, filepath.Join(req.CacheRoot, "pnpm-store"), result.AddEnvs["NPM_CONFIG_STORE_DIR"])
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
-		require.Equal(t, "copy", result.AddEnvs["npm_config_package_import_method"])
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("uses original mount when store path is outside PNPM_HOME", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "/home/runner/setup-pnpm/node_modules/.bin")
-
-		callCount := 0
-		req := mode.PlanRequest{
-			CacheRoot: "/cache",
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
-					}
-					return []byte("/home/runner/.local/share/pnpm/store/v11\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.RustProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/runner/.local/share/pnpm/store/v11"}, result.MountPaths)
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("uses original mount when PNPM_HOME is unset", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-
-		callCount := 0
-		req := mode.PlanRequest{
-			CacheRoot: "/cache",
+	t.Run("not detected when Cargo.toml missing", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
-					}
-					return []byte("/home/runner/setup-pnpm/node_modules/.bin/store/v11\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/cargo", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.RustProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/runner/setup-pnpm/node_modules/.bin/store/v11"}, result.MountPaths)
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("uses original mount when path shares prefix but is not a descendant", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "/home/runner/setup-pnpm/node_modules/.bin")
+func TestRustProvider_Plan(t *testing.T) {
+	t.Run("falls back to defaults when cargo is absent", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("USERPROFILE", home) // os.UserHomeDir reads USERPROFILE on Windows
+		t.Setenv("CARGO_HOME", "")
+		t.Setenv("CARGO_TARGET_DIR", "")
+		t.Setenv("RUSTC_WRAPPER", "")
 
-		callCount := 0
 		req := mode.PlanRequest{
-			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
-					}
-					return []byte("/home/runner/setup-pnpm/node_modules/.bin-other/store/v11\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/runner/setup-pnpm/node_modules/.bin-other/store/v11"}, result.MountPaths)
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
+		require.Equal(t, []string{
+			filepath.Join(home, ".cargo", "registry"),
+			filepath.Join(home, ".cargo", "git"),
+			"./target",
+			filepath.Join(home, ".cargo", ".global-cache"),
+		}, result.MountPaths)
 	})
 
-	t.Run("respects user-set PNPM_CONFIG_STORE_DIR", func(t *testing.T) {
-		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
-		t.Setenv("PNPM_HOME", pnpmHome)
-		t.Setenv("PNPM_CONFIG_STORE_DIR", pnpmHome+"/custom-store")
-		t.Setenv("NPM_CONFIG_STORE_DIR", "")
-
-		callCount := 0
+	t.Run("uses target directory reported by cargo metadata", func(t *testing.T) {
 		req := mode.PlanRequest{
-			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
-					}
-					return []byte(pnpmHome + "/custom-store\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/cargo", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"target_directory":"/workspace/root/target"}`), nil
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{pnpmHome + "/custom-store"}, result.MountPaths)
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
-		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
+		require.Equal(t, "/workspace/root/target", result.MountPaths[2])
 	})
 
-	t.Run("respects user-set NPM_CONFIG_STORE_DIR (legacy pnpm < 11)", func(t *testing.T) {
-		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
-		t.Setenv("PNPM_HOME", pnpmHome)
-		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
-		t.Setenv("NPM_CONFIG_STORE_DIR", pnpmHome+"/custom-store")
+	t.Run("keeps relative ./target when cargo reports the default <cwd>/target", func(t *testing.T) {
+		// The old hardcoded "./target" already handled this case correctly, so
+		// the mount path (and thus the cache location) must stay "./target" to
+		// avoid invalidating existing caches.
+		cwd := t.TempDir()
+		t.Chdir(cwd)
 
-		callCount := 0
 		req := mode.PlanRequest{
-			CacheRoot: "/cache",
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("10.0.0\n"), nil
-					}
-					return []byte(pnpmHome + "/custom-store\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/cargo", nil
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					meta := fmt.Sprintf(`{"target_directory":%q}`, filepath.Join(cwd, "target"))
+					return []byte(meta), nil
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{pnpmHome + "/custom-store"}, result.MountPaths)
-		require.NotContains(t, result.AddEnvs, "PNPM_CONFIG_STORE_DIR")
-		require.NotContains(t, result.AddEnvs, "NPM_CONFIG_STORE_DIR")
+		require.Equal(t, "./target", result.MountPaths[2])
 	})
 
-	t.Run("errors when cache root is unset and store would shadow binary", func(t *testing.T) {
-		pnpmHome := "/home/runner/setup-pnpm/node_modules/.bin"
-		t.Setenv("PNPM_HOME", pnpmHome)
-		t.Setenv("PNPM_CONFIG_STORE_DIR", "")
-		t.Setenv("NPM_CONFIG_STORE_DIR", "")
+	t.Run("falls back to CARGO_TARGET_DIR when cargo metadata fails", func(t *testing.T) {
+		t.Setenv("CARGO_TARGET_DIR", "/custom/target")
 
-		callCount := 0
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("11.0.0\n"), nil
-					}
-					return []byte(pnpmHome + "/store/v11\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/cargo", nil
 				},
-			},
-		}
-
-		p := mode.PnpmProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "cache root")
-	})
-
-	t.Run("old version extracts version from last line when warnings present", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-		callCount := 0
-		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						// pnpm < 9.7.0 prints warnings to stdout, version is on last line
-						return []byte("\u2009WARN\u2009 some warning\n9.6.0\n"), nil
-					}
-					return []byte("\u2009WARN\u2009 some warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return nil, fmt.Errorf("cargo metadata failed")
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+		require.Equal(t, "/custom/target", result.MountPaths[2])
 	})
 
-	t.Run("cache path extracted with old version drops single warning", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-		callCount := 0
+	t.Run("honors CARGO_HOME", func(t *testing.T) {
+		t.Setenv("CARGO_HOME", filepath.Join("opt", "cargo"))
+		t.Setenv("CARGO_TARGET_DIR", "")
+		t.Setenv("RUSTC_WRAPPER", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("9.6.0\n"), nil // old version
-					}
-					// pnpm uses thin spaces (\u2009) around WARN
-					return []byte("\u2009WARN\u2009 some warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+		require.Equal(t, []string{
+			filepath.Join("opt", "cargo", "registry"),
+			filepath.Join("opt", "cargo", "git"),
+			"./target",
+			filepath.Join("opt", "cargo", ".global-cache"),
+		}, result.MountPaths)
+		require.Nil(t, result.AddEnvs)
 	})
 
-	t.Run("cache path extracted with old version drops multiple warnings", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-		callCount := 0
+	t.Run("mounts SCCACHE_DIR when RUSTC_WRAPPER is sccache", func(t *testing.T) {
+		t.Setenv("CARGO_HOME", filepath.Join("opt", "cargo"))
+		t.Setenv("CARGO_TARGET_DIR", "")
+		t.Setenv("RUSTC_WRAPPER", "/usr/local/bin/sccache")
+		t.Setenv("SCCACHE_DIR", filepath.Join("opt", "sccache"))
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("9.5.0\n"), nil // old version
-					}
-					// Multiple warning lines with thin spaces
-					return []byte("\u2009WARN\u2009 deprecated package\n\u2009WARN\u2009 another warning\n/home/user/.local/share/pnpm/store/v3\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+		require.Equal(t, []string{
+			filepath.Join("opt", "cargo", "registry"),
+			filepath.Join("opt", "cargo", "git"),
+			"./target",
+			filepath.Join("opt", "cargo", ".global-cache"),
+			filepath.Join("opt", "sccache"),
+		}, result.MountPaths)
+		require.Equal(t, map[string]string{
+			"CARGO_INCREMENTAL": "0",
+			"SCCACHE_DIR":       filepath.Join("opt", "sccache"),
+		}, result.AddEnvs)
 	})
 
-	t.Run("new version does not filter warnings", func(t *testing.T) {
-		t.Setenv("PNPM_HOME", "")
-		callCount := 0
+	t.Run("falls back to the default sccache dir", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("default sccache dir is platform specific")
+		}
+		t.Setenv("CARGO_TARGET_DIR", "")
+		t.Setenv("RUSTC_WRAPPER", "sccache")
+		t.Setenv("SCCACHE_DIR", "")
+		t.Setenv("XDG_CACHE_HOME", "")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("9.7.0\n"), nil // new version
-					}
-					// New versions don't print warnings to stdout with --loglevel error
-					return []byte("/home/user/.local/share/pnpm/store/v3\n"), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.PnpmProvider{}
+		p := mode.RustProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.local/share/pnpm/store/v3"}, result.MountPaths)
+		require.Equal(t, "~/.cache/sccache", result.MountPaths[len(result.MountPaths)-1])
+		require.Equal(t, "~/.cache/sccache", result.AddEnvs["SCCACHE_DIR"])
 	})
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
-		callCount := 0
+	t.Run("ignores other RUSTC_WRAPPER values", func(t *testing.T) {
+		t.Setenv("CARGO_TARGET_DIR", "")
+		t.Setenv("RUSTC_WRAPPER", "/usr/bin/cachepot")
+
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					callCount++
-					if callCount == 1 {
-						return []byte("9.7.0\n"), nil
-					}
-					return []byte(""), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
 				},
 			},
 		}
-
-		p := mode.PnpmProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
+
+		p := mode.RustProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Len(t, result.MountPaths, 4)
+		require.Nil(t, result.AddEnvs)
 	})
 }
 
-// PoetryProvider tests
+// SwiftPMProvider tests
 
-func TestPoetryProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and poetry.lock exist", func(t *testing.T) {
+func TestSwiftPMProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Package.swift exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/poetry", nil
+					return "/usr/bin/swift", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "poetry.lock", name)
+					require.Equal(t, "Package.swift", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.PoetryProvider{}
+		p := mode.SwiftPMProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -1824,17 +5193,17 @@ func TestPoetryProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.PoetryProvider{}
+		p := mode.SwiftPMProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when poetry.lock missing", func(t *testing.T) {
+	t.Run("not detected when Package.swift missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/poetry", nil
+					return "/usr/bin/swift", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -1842,68 +5211,95 @@ func TestPoetryProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.PoetryProvider{}
+		p := mode.SwiftPMProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestPoetryProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
+func TestSwiftPMProvider_Plan(t *testing.T) {
+	t.Run("returns all paths when xcode mode not enabled", func(t *testing.T) {
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.cache/pypoetry\n"), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.PoetryProvider{}
+		p := mode.SwiftPMProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.cache/pypoetry"}, result.MountPaths)
+		require.Equal(t, []string{
+			"./.build",
+			"~/Library/Caches/org.swift.swiftpm",
+			"~/Library/org.swift.swiftpm",
+			"~/Library/Developer/Xcode/DerivedData/ModuleCache.noindex",
+		}, result.MountPaths)
 	})
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
+	t.Run("excludes module cache when xcode mode enabled", func(t *testing.T) {
 		req := mode.PlanRequest{
+			EnabledModes: []string{"swiftpm", "xcode"},
+			Exec:         &mode.ExecutorMock{},
+		}
+
+		p := mode.SwiftPMProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"./.build",
+			"~/Library/Caches/org.swift.swiftpm",
+			"~/Library/org.swift.swiftpm",
+		}, result.MountPaths)
+	})
+}
+
+// TerraformProvider tests
+
+func TestTerraformProvider_Detect(t *testing.T) {
+	t.Run("detected when terraform and .tf files exist", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(""), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/terraform", nil
+				},
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "README.md"},
+						mockDirEntry{name: "main.tf"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.PoetryProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
+		p := mode.TerraformProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
 	})
-}
-
-// PythonProvider tests
 
-func TestPythonProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and requirements.txt exist", func(t *testing.T) {
+	t.Run("detected when terragrunt and terragrunt.hcl exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/pip", nil
+					if file == "terragrunt" {
+						return "/usr/local/bin/terragrunt", nil
+					}
+					return "", exec.ErrNotFound
 				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "requirements.txt", name)
-					return nil, nil
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "terragrunt.hcl"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.PythonProvider{}
+		p := mode.TerraformProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+	t.Run("not detected when binaries missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
@@ -1912,83 +5308,105 @@ func TestPythonProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.PythonProvider{}
+		p := mode.TerraformProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when requirements.txt missing", func(t *testing.T) {
+	t.Run("not detected without terraform files", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/pip", nil
+					return "/usr/local/bin/terraform", nil
 				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "modules.tf", isDir: true},
+						mockDirEntry{name: "README.md"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.PythonProvider{}
+		p := mode.TerraformProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestPythonProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
+func TestTerraformProvider_Plan(t *testing.T) {
+	t.Run("mounts the modules dir", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.cache/pip\n"), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.PythonProvider{}
+		p := mode.TerraformProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.cache/pip"}, result.MountPaths)
+		require.Equal(t, []string{"./.terraform/modules"}, result.MountPaths)
 	})
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
+	t.Run("mounts TF_PLUGIN_CACHE_DIR when set", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "/opt/terraform-plugins")
+
 		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.TerraformProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./.terraform/modules", "/opt/terraform-plugins"}, result.MountPaths)
+	})
+}
+
+// TuistProvider tests
+
+func TestTuistProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and Project.swift exist", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(""), nil
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/tuist", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == "Project.swift" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.PythonProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
+		p := mode.TuistProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
 	})
-}
-
-// RubyProvider tests
 
-func TestRubyProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and Gemfile exist", func(t *testing.T) {
+	t.Run("detected when binary and Workspace.swift exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/bundle", nil
+					return "/usr/local/bin/tuist", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "Gemfile", name)
-					return nil, nil
+					if name == "Workspace.swift" {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.RubyProvider{}
+		p := mode.TuistProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -2000,17 +5418,17 @@ func TestRubyProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.RubyProvider{}
+		p := mode.TuistProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when Gemfile missing", func(t *testing.T) {
+	t.Run("not detected when project manifests missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/bundle", nil
+					return "/usr/local/bin/tuist", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -2018,216 +5436,200 @@ func TestRubyProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.RubyProvider{}
+		p := mode.TuistProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestRubyProvider_Plan(t *testing.T) {
-	t.Run("returns vendor paths", func(t *testing.T) {
+func TestTuistProvider_Plan(t *testing.T) {
+	t.Run("returns mount paths", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.RubyProvider{}
+		p := mode.TuistProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"./vendor/bundle", "./vendor/cache"}, result.MountPaths)
+		require.Equal(t, []string{"~/.tuist/Cache"}, result.MountPaths)
 	})
 }
 
-// RustProvider tests
+// TurboProvider tests
 
-func TestRustProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and Cargo.toml exist", func(t *testing.T) {
+func TestTurboProvider_Detect(t *testing.T) {
+	t.Run("detected when turbo.json exists", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/cargo", nil
-				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "Cargo.toml", name)
+					require.Equal(t, "turbo.json", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
+		p := mode.TurboProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+	t.Run("not detected when turbo.json missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
+		p := mode.TurboProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("not detected when Cargo.toml missing", func(t *testing.T) {
-		req := mode.DetectRequest{
-			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/cargo", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
-				},
-			},
+func TestTurboProvider_Plan(t *testing.T) {
+	t.Run("pins default cache dir", func(t *testing.T) {
+		t.Setenv("TURBO_CACHE_DIR", "")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.RustProvider{}
-		detected, err := p.Detect(t.Context(), req)
+		p := mode.TurboProvider{}
+		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.Equal(t, []string{"./.turbo/cache"}, result.MountPaths)
+		require.Equal(t, map[string]string{"TURBO_CACHE_DIR": "./.turbo/cache"}, result.AddEnvs)
 	})
-}
 
-func TestRustProvider_Plan(t *testing.T) {
-	t.Run("falls back to defaults when cargo is absent", func(t *testing.T) {
-		home := t.TempDir()
-		t.Setenv("HOME", home)
-		t.Setenv("USERPROFILE", home) // os.UserHomeDir reads USERPROFILE on Windows
-		t.Setenv("CARGO_HOME", "")
-		t.Setenv("CARGO_TARGET_DIR", "")
+	t.Run("honors TURBO_CACHE_DIR override", func(t *testing.T) {
+		t.Setenv("TURBO_CACHE_DIR", "/custom/turbo")
 
 		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.TurboProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/custom/turbo"}, result.MountPaths)
+		require.Equal(t, map[string]string{"TURBO_CACHE_DIR": "/custom/turbo"}, result.AddEnvs)
+	})
+}
+
+// UVProvider tests
+
+func TestUVProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and uv.lock exist", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+					return "/usr/bin/uv", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "uv.lock", name)
+					return nil, nil
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.UVProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{
-			filepath.Join(home, ".cargo", "registry"),
-			filepath.Join(home, ".cargo", "git"),
-			"./target",
-			filepath.Join(home, ".cargo", ".global-cache"),
-		}, result.MountPaths)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("uses target directory reported by cargo metadata", func(t *testing.T) {
-		req := mode.PlanRequest{
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/cargo", nil
-				},
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(`{"target_directory":"/workspace/root/target"}`), nil
+					return "", exec.ErrNotFound
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.UVProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, "/workspace/root/target", result.MountPaths[2])
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("keeps relative ./target when cargo reports the default <cwd>/target", func(t *testing.T) {
-		// The old hardcoded "./target" already handled this case correctly, so
-		// the mount path (and thus the cache location) must stay "./target" to
-		// avoid invalidating existing caches.
-		cwd := t.TempDir()
-		t.Chdir(cwd)
-
-		req := mode.PlanRequest{
+	t.Run("not detected when uv.lock missing", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/cargo", nil
+					return "/usr/bin/uv", nil
 				},
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					meta := fmt.Sprintf(`{"target_directory":%q}`, filepath.Join(cwd, "target"))
-					return []byte(meta), nil
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.UVProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, "./target", result.MountPaths[2])
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("falls back to CARGO_TARGET_DIR when cargo metadata fails", func(t *testing.T) {
-		t.Setenv("CARGO_TARGET_DIR", "/custom/target")
-
+func TestUVProvider_Plan(t *testing.T) {
+	t.Run("cache path extracted", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/local/bin/cargo", nil
-				},
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return nil, fmt.Errorf("cargo metadata failed")
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte("/home/user/.cache/uv\n"), nil
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
+		p := mode.UVProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, "/custom/target", result.MountPaths[2])
+		require.Equal(t, []string{"/home/user/.cache/uv"}, result.MountPaths)
+		require.Equal(t, map[string]string{"UV_LINK_MODE": "symlink"}, result.AddEnvs)
 	})
 
-	t.Run("honors CARGO_HOME", func(t *testing.T) {
-		t.Setenv("CARGO_HOME", filepath.Join("opt", "cargo"))
-		t.Setenv("CARGO_TARGET_DIR", "")
-
+	t.Run("empty cache dir returns error", func(t *testing.T) {
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(""), nil
 				},
 			},
 		}
 
-		p := mode.RustProvider{}
-		result, err := p.Plan(t.Context(), req)
-		require.NoError(t, err)
-		require.Equal(t, []string{
-			filepath.Join("opt", "cargo", "registry"),
-			filepath.Join("opt", "cargo", "git"),
-			"./target",
-			filepath.Join("opt", "cargo", ".global-cache"),
-		}, result.MountPaths)
+		p := mode.UVProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
 
-// SwiftPMProvider tests
+// VcpkgProvider tests
 
-func TestSwiftPMProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and Package.swift exist", func(t *testing.T) {
+func TestVcpkgProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and vcpkg.json exist", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/swift", nil
+					return "/usr/local/bin/vcpkg", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "Package.swift", name)
+					require.Equal(t, "vcpkg.json", name)
 					return nil, nil
 				},
 			},
 		}
 
-		p := mode.SwiftPMProvider{}
+		p := mode.VcpkgProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -2239,17 +5641,17 @@ func TestSwiftPMProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.SwiftPMProvider{}
+		p := mode.VcpkgProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
-	t.Run("not detected when Package.swift missing", func(t *testing.T) {
+	t.Run("not detected when vcpkg.json missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
 				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/swift", nil
+					return "/usr/local/bin/vcpkg", nil
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -2257,133 +5659,168 @@ func TestSwiftPMProvider_Detect(t *testing.T) {
 			},
 		}
 
-		p := mode.SwiftPMProvider{}
+		p := mode.VcpkgProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
-func TestSwiftPMProvider_Plan(t *testing.T) {
-	t.Run("returns all paths when xcode mode not enabled", func(t *testing.T) {
+func TestVcpkgProvider_Plan(t *testing.T) {
+	t.Run("mounts user-set binary cache and downloads", func(t *testing.T) {
+		t.Setenv("VCPKG_DEFAULT_BINARY_CACHE", "/custom/vcpkg/archives")
+		t.Setenv("VCPKG_DOWNLOADS", "/custom/vcpkg/downloads")
+
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{},
+			CacheRoot: "/cache",
+			Exec:      &mode.ExecutorMock{},
 		}
 
-		p := mode.SwiftPMProvider{}
+		p := mode.VcpkgProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{
-			"./.build",
-			"~/Library/Caches/org.swift.swiftpm",
-			"~/Library/org.swift.swiftpm",
-			"~/Library/Developer/Xcode/DerivedData/ModuleCache.noindex",
-		}, result.MountPaths)
+		require.Equal(t, []string{"/custom/vcpkg/archives", "/custom/vcpkg/downloads"}, result.MountPaths)
+		require.Empty(t, result.CacheDirs)
+		require.Equal(t, map[string]string{"VCPKG_DEFAULT_BINARY_CACHE": "/custom/vcpkg/archives"}, result.AddEnvs)
 	})
 
-	t.Run("excludes module cache when xcode mode enabled", func(t *testing.T) {
+	t.Run("redirects downloads onto the cache volume", func(t *testing.T) {
+		t.Setenv("VCPKG_DEFAULT_BINARY_CACHE", "/custom/vcpkg/archives")
+		t.Setenv("VCPKG_DOWNLOADS", "")
+
 		req := mode.PlanRequest{
-			EnabledModes: []string{"swiftpm", "xcode"},
-			Exec:         &mode.ExecutorMock{},
+			CacheRoot: "/cache",
+			Exec:      &mode.ExecutorMock{},
 		}
 
-		p := mode.SwiftPMProvider{}
+		p := mode.VcpkgProvider{}
 		result, err := p.Plan(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{
-			"./.build",
-			"~/Library/Caches/org.swift.swiftpm",
-			"~/Library/org.swift.swiftpm",
-		}, result.MountPaths)
+		require.Equal(t, []string{"/custom/vcpkg/archives"}, result.MountPaths)
+		require.Equal(t, []string{"vcpkg-downloads"}, result.CacheDirs)
+		require.Equal(t, filepath.Join("/cache", "vcpkg-downloads"), result.AddEnvs["VCPKG_DOWNLOADS"])
+	})
+
+	t.Run("skips downloads without cache root", func(t *testing.T) {
+		t.Setenv("VCPKG_DEFAULT_BINARY_CACHE", "")
+		t.Setenv("VCPKG_DOWNLOADS", "")
+		t.Setenv("XDG_CACHE_HOME", "/custom/xdg")
+		t.Setenv("LOCALAPPDATA", "/custom/xdg")
+
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.VcpkgProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("/custom/xdg", "vcpkg", "archives")}, result.MountPaths)
+		require.Empty(t, result.CacheDirs)
+		require.NotContains(t, result.AddEnvs, "VCPKG_DOWNLOADS")
 	})
 }
 
-// UVProvider tests
+// ViteProvider tests
 
-func TestUVProvider_Detect(t *testing.T) {
-	t.Run("detected when binary and uv.lock exist", func(t *testing.T) {
+func TestViteProvider_Detect(t *testing.T) {
+	t.Run("detected when vite.config.mts exists", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/uv", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					require.Equal(t, "uv.lock", name)
-					return nil, nil
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+						mockDirEntry{name: "vite.config.mts"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.UVProvider{}
+		p := mode.ViteProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
-	t.Run("not detected when binary missing", func(t *testing.T) {
+	t.Run("not detected when config missing", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "", exec.ErrNotFound
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "vitest.config.ts"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.UVProvider{}
+		p := mode.ViteProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("not detected when uv.lock missing", func(t *testing.T) {
+func TestViteProvider_Plan(t *testing.T) {
+	t.Run("returns mount path", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{},
+		}
+
+		p := mode.ViteProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./node_modules/.vite"}, result.MountPaths)
+	})
+}
+
+// WebpackProvider tests
+
+func TestWebpackProvider_Detect(t *testing.T) {
+	t.Run("detected when webpack.config.js exists", func(t *testing.T) {
 		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				LookPathFunc: func(file string) (string, error) {
-					return "/usr/bin/uv", nil
-				},
-				StatFunc: func(name string) (os.FileInfo, error) {
-					return nil, os.ErrNotExist
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "package.json"},
+						mockDirEntry{name: "webpack.config.js"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.UVProvider{}
+		p := mode.WebpackProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.True(t, detected.Detected)
 	})
-}
 
-func TestUVProvider_Plan(t *testing.T) {
-	t.Run("cache path extracted", func(t *testing.T) {
-		req := mode.PlanRequest{
+	t.Run("not detected when config missing", func(t *testing.T) {
+		req := mode.DetectRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte("/home/user/.cache/uv\n"), nil
+				ReadDirFunc: func(name string) ([]os.DirEntry, error) {
+					return []os.DirEntry{
+						mockDirEntry{name: "webpack.js"},
+					}, nil
 				},
 			},
 		}
 
-		p := mode.UVProvider{}
-		result, err := p.Plan(t.Context(), req)
+		p := mode.WebpackProvider{}
+		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.Equal(t, []string{"/home/user/.cache/uv"}, result.MountPaths)
-		require.Equal(t, map[string]string{"UV_LINK_MODE": "symlink"}, result.AddEnvs)
+		require.False(t, detected.Detected)
 	})
+}
 
-	t.Run("empty cache dir returns error", func(t *testing.T) {
+func TestWebpackProvider_Plan(t *testing.T) {
+	t.Run("returns mount path", func(t *testing.T) {
 		req := mode.PlanRequest{
-			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
-					return []byte(""), nil
-				},
-			},
+			Exec: &mode.ExecutorMock{},
 		}
 
-		p := mode.UVProvider{}
-		_, err := p.Plan(t.Context(), req)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "empty cache dir")
+		p := mode.WebpackProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"./node_modules/.cache/webpack"}, result.MountPaths)
 	})
 }
 
@@ -2417,7 +5854,7 @@ func TestXcodeProvider_Detect(t *testing.T) {
 		p := mode.XcodeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("detected when binary and .xcworkspace exist", func(t *testing.T) {
@@ -2437,7 +5874,7 @@ func TestXcodeProvider_Detect(t *testing.T) {
 		p := mode.XcodeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -2452,7 +5889,7 @@ func TestXcodeProvider_Detect(t *testing.T) {
 		p := mode.XcodeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
 	t.Run("not detected when no .xcodeproj exists", func(t *testing.T) {
@@ -2473,7 +5910,7 @@ func TestXcodeProvider_Detect(t *testing.T) {
 		p := mode.XcodeProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
@@ -2570,7 +6007,7 @@ func TestYarnProvider_Detect(t *testing.T) {
 		p := mode.YarnProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.True(t, detected)
+		require.True(t, detected.Detected)
 	})
 
 	t.Run("not detected when binary missing", func(t *testing.T) {
@@ -2585,7 +6022,7 @@ func TestYarnProvider_Detect(t *testing.T) {
 		p := mode.YarnProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 
 	t.Run("not detected when lock file missing", func(t *testing.T) {
@@ -2603,7 +6040,7 @@ func TestYarnProvider_Detect(t *testing.T) {
 		p := mode.YarnProvider{}
 		detected, err := p.Detect(t.Context(), req)
 		require.NoError(t, err)
-		require.False(t, detected)
+		require.False(t, detected.Detected)
 	})
 }
 
@@ -2612,7 +6049,7 @@ func TestYarnProvider_Plan(t *testing.T) {
 		callCount := 0
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					callCount++
 					if callCount == 1 {
 						return []byte("1.22.19\n"), nil
@@ -2632,7 +6069,7 @@ func TestYarnProvider_Plan(t *testing.T) {
 		callCount := 0
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					callCount++
 					if callCount == 1 {
 						return []byte("4.0.2\n"), nil
@@ -2648,11 +6085,32 @@ func TestYarnProvider_Plan(t *testing.T) {
 		require.Equal(t, []string{"/home/user/.yarn/cache"}, result.MountPaths)
 	})
 
+	t.Run("unknown version warns and uses config get cacheFolder command", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					if cmd.String() == "yarn --version" {
+						return nil, errors.New("exit status 1")
+					}
+					require.Equal(t, "yarn config get cacheFolder", cmd.String())
+					return []byte("/home/user/.yarn/cache\n"), nil
+				},
+			},
+		}
+
+		p := mode.YarnProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.yarn/cache"}, result.MountPaths)
+		require.Len(t, result.Warnings, 1)
+		require.Contains(t, result.Warnings[0], "yarn version could not be determined")
+	})
+
 	t.Run("empty cache dir returns error", func(t *testing.T) {
 		callCount := 0
 		req := mode.PlanRequest{
 			Exec: &mode.ExecutorMock{
-				OutputFunc: func(cmd *exec.Cmd) ([]byte, error) {
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
 					callCount++
 					if callCount == 1 {
 						return []byte("1.22.19\n"), nil
@@ -2668,3 +6126,131 @@ func TestYarnProvider_Plan(t *testing.T) {
 		require.Contains(t, err.Error(), "empty cache dir")
 	})
 }
+
+// ZigProvider tests
+
+func TestZigProvider_Detect(t *testing.T) {
+	t.Run("detected when binary and build.zig exist", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/zig", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "build.zig", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.True(t, detected.Detected)
+	})
+
+	t.Run("not detected when binary missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+
+	t.Run("not detected when build.zig missing", func(t *testing.T) {
+		req := mode.DetectRequest{
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/local/bin/zig", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		detected, err := p.Detect(t.Context(), req)
+		require.NoError(t, err)
+		require.False(t, detected.Detected)
+	})
+}
+
+func TestZigProvider_Plan(t *testing.T) {
+	t.Run("global cache dir extracted from JSON output", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"zig_exe": "/usr/bin/zig", "global_cache_dir": "/home/user/.cache/zig"}`), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.cache/zig", "./.zig-cache"}, result.MountPaths)
+	})
+
+	t.Run("global cache dir extracted from ZON output", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(".{\n    .zig_exe = \"/usr/bin/zig\",\n    .global_cache_dir = \"/home/user/.cache/zig\",\n}\n"), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.cache/zig", "./.zig-cache"}, result.MountPaths)
+	})
+
+	t.Run("legacy zig-cache used when present", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"global_cache_dir": "/home/user/.cache/zig"}`), nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					require.Equal(t, "zig-cache", name)
+					return nil, nil
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		result, err := p.Plan(t.Context(), req)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/home/user/.cache/zig", "./zig-cache"}, result.MountPaths)
+	})
+
+	t.Run("missing global cache dir returns error", func(t *testing.T) {
+		req := mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"zig_exe": "/usr/bin/zig"}`), nil
+				},
+			},
+		}
+
+		p := mode.ZigProvider{}
+		_, err := p.Plan(t.Context(), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "global_cache_dir not found")
+	})
+}
@@ -0,0 +1,39 @@
+package mode
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	registryMu sync.Mutex
+	registered Modes
+)
+
+// Register adds a provider to the modes returned by DefaultModes, so that
+// programs embedding the cache packages can detect and plan modes of their
+// own, typically from an init function. A provider cannot replace a
+// built-in or registered mode of the same name.
+func Register(p ModeProvider) error {
+	name := p.Name()
+	if name == "" {
+		return errors.New("register mode: empty name")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if slices.Contains(builtinModes().Names(), name) || slices.Contains(registered.Names(), name) {
+		return fmt.Errorf("register mode %q: a mode with that name exists", name)
+	}
+	registered = append(registered, p)
+	return nil
+}
+
+func registeredModes() Modes {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return slices.Clone(registered)
+}
@@ -0,0 +1,19 @@
+package mode_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestRegister(t *testing.T) {
+	named := func(name string) mode.ModeProvider {
+		return &mode.ModeProviderMock{NameFunc: func() string { return name }}
+	}
+
+	require.ErrorContains(t, mode.Register(named("")), "empty name")
+	require.ErrorContains(t, mode.Register(named("go")), `register mode "go"`)
+	require.NotContains(t, mode.DefaultModes().Names(), "")
+}
@@ -0,0 +1,123 @@
+package mode
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SourceKind says where a planned path came from.
+type SourceKind string
+
+const (
+	// SourceEnv paths are, or are inside, the value of the environment
+	// variable Name.
+	SourceEnv SourceKind = "env"
+	// SourceCommand paths were printed by the command Name, e.g. `go env`.
+	SourceCommand SourceKind = "command"
+	// SourceDefault paths are the defaults of the tool or of the mode.
+	SourceDefault SourceKind = "default"
+	// SourceConfig paths were set by the user, e.g. with a path override.
+	SourceConfig SourceKind = "config"
+)
+
+// Source is where a planned path came from, so that plans can be reviewed,
+// e.g. to tell paths that a static plan derived from the environment from
+// those it assumed.
+type Source struct {
+	Kind SourceKind `json:"kind"`
+	Name string     `json:"name,omitzero"`
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return string(s.Kind) + " " + s.Name
+}
+
+// provenance records the environment variables that a mode read, and the
+// output of the commands it ran, while planning.
+type provenance struct {
+	mu       sync.Mutex
+	env      map[string]string
+	commands []commandOutput
+}
+
+type commandOutput struct {
+	command string
+	output  string
+}
+
+func (p *provenance) getenv(key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.env == nil {
+		p.env = make(map[string]string)
+	}
+	p.env[key] = value
+}
+
+// tracing returns an executor recording the output of commands in p.
+func (p *provenance) tracing(exec Executor) Executor {
+	return tracingExecutor{Executor: exec, provenance: p}
+}
+
+type tracingExecutor struct {
+	Executor
+	provenance *provenance
+}
+
+func (e tracingExecutor) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	out, err := e.Executor.Run(ctx, spec)
+	if err == nil {
+		e.provenance.mu.Lock()
+		e.provenance.commands = append(e.provenance.commands, commandOutput{command: spec.String(), output: string(out)})
+		e.provenance.mu.Unlock()
+	}
+	return out, err
+}
+
+// sources tells where each path came from: the environment variable whose
+// value contains it, preferring the longest value, or else the command that
+// printed it. Other paths are defaults.
+func (p *provenance) sources(paths []string) map[string]Source {
+	if len(paths) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Only values that are paths can contain paths, which keeps e.g. "."
+	// from claiming every relative path. Lists like GOPATH count each entry.
+	type candidate struct{ key, dir string }
+	var candidates []candidate
+	for key, value := range p.env {
+		for _, dir := range filepath.SplitList(value) {
+			if filepath.IsAbs(dir) || dir == "~" || strings.HasPrefix(dir, "~/") {
+				candidates = append(candidates, candidate{key: key, dir: dir})
+			}
+		}
+	}
+	// Longest values first, then by name for stable results.
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if d := len(b.dir) - len(a.dir); d != 0 {
+			return d
+		}
+		return strings.Compare(a.key, b.key)
+	})
+
+	sources := make(map[string]Source, len(paths))
+	for _, path := range paths {
+		source := Source{Kind: SourceDefault}
+		if i := slices.IndexFunc(candidates, func(c candidate) bool { return isDescendant(path, c.dir) }); i >= 0 {
+			source = Source{Kind: SourceEnv, Name: candidates[i].key}
+		} else if i := slices.IndexFunc(p.commands, func(c commandOutput) bool { return strings.Contains(c.output, path) }); i >= 0 {
+			source = Source{Kind: SourceCommand, Name: p.commands[i].command}
+		}
+		sources[path] = source
+	}
+	return sources
+}
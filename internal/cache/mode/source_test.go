@@ -0,0 +1,72 @@
+package mode_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestModes_PlanSources(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are not absolute on Windows")
+	}
+
+	goMode, err := mode.DefaultModes().Filter([]string{"go"})
+	require.NoError(t, err)
+
+	t.Run("command output", func(t *testing.T) {
+		plan, err := goMode.Plan(t.Context(), mode.PlanRequest{
+			Exec: &mode.ExecutorMock{
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					return []byte(`{"GOCACHE":"/root/.cache/go-build","GOMODCACHE":"/go/pkg/mod"}`), nil
+				},
+			},
+		})
+		require.NoError(t, err)
+		want := mode.Source{Kind: mode.SourceCommand, Name: "go env -json GOCACHE GOMODCACHE"}
+		require.Equal(t, map[string]mode.Source{"/root/.cache/go-build": want, "/go/pkg/mod": want}, plan.Results["go"].Sources)
+	})
+
+	t.Run("static", func(t *testing.T) {
+		plan, err := goMode.Plan(t.Context(), mode.PlanRequest{
+			Static: true,
+			Env:    map[string]string{"GOCACHE": "", "GOMODCACHE": "", "GOPATH": "/gopath:/other", "XDG_CACHE_HOME": ""},
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "", exec.ErrNotFound
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					t.Fatalf("ran %s statically", cmd)
+					return nil, exec.ErrNotFound
+				},
+			},
+		})
+		require.NoError(t, err)
+		result := plan.Results["go"]
+		require.True(t, result.Assumed)
+		require.Equal(t, mode.Source{Kind: mode.SourceEnv, Name: "GOPATH"}, result.Sources["/gopath/pkg/mod"])
+		if runtime.GOOS == "linux" {
+			require.Equal(t, mode.Source{Kind: mode.SourceDefault}, result.Sources["~/.cache/go-build"])
+		}
+	})
+
+	t.Run("static plugins are not run", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, mode.PluginPrefix+"bazel")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nexit 1\n"), 0o755))
+
+		plan, err := mode.DiscoverPlugins([]string{dir}).Plan(t.Context(), mode.PlanRequest{Static: true})
+		require.NoError(t, err)
+		result := plan.Results["bazel"]
+		require.True(t, result.Assumed)
+		require.Empty(t, result.MountPaths)
+		require.Len(t, result.Warnings, 1)
+	})
+}
@@ -0,0 +1,78 @@
+package mode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WarmResult reports on warming the caches of a mode.
+type WarmResult struct {
+	// Checks lists the files looked at and the commands run. Modes that
+	// ran no commands had nothing to warm.
+	Checks   []DetectCheck
+	Duration time.Duration
+	Err      error
+}
+
+// Warm warms the caches of the modes that implement Warmer, at most
+// req.Concurrency at once, and returns the result of each by mode name. A
+// mode that fails to warm does not stop the others.
+func (modes Modes) Warm(ctx context.Context, req WarmRequest) map[string]WarmResult {
+	if req.Exec == nil {
+		req.Exec = DefaultExecutor{}
+	}
+	req.Exec = inDir(req.Exec, req.WorkDir)
+
+	var m sync.Mutex
+	results := make(map[string]WarmResult, len(modes))
+
+	var eg errgroup.Group
+	if req.Concurrency > 0 {
+		eg.SetLimit(req.Concurrency)
+	}
+	for _, mode := range modes {
+		warmer, ok := mode.(Warmer)
+		if !ok {
+			continue
+		}
+		eg.Go(func() error {
+			req := req
+			d := recording(&req.Exec, req.WorkDir)
+			start := time.Now()
+			err := warmer.Warm(ctx, req)
+			result := WarmResult{Checks: d.recorded(), Duration: time.Since(start), Err: err}
+			if err != nil {
+				slog.Debug("failed to warm cache", slog.String("mode", mode.Name()), slog.Any("error", err))
+			} else {
+				slog.Debug("warmed cache", slog.String("mode", mode.Name()), slog.Duration("duration", result.Duration))
+			}
+
+			m.Lock()
+			results[mode.Name()] = result
+			m.Unlock()
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return results
+}
+
+// hasFile reports whether the project has a file, e.g. the lockfile that a
+// warm command reads.
+func hasFile(exec Executor, name string) (bool, error) {
+	if _, err := exec.Stat(name); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat %s: %w", name, err)
+	}
+	return true, nil
+}
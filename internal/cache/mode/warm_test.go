@@ -0,0 +1,55 @@
+package mode_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestModes_Warm(t *testing.T) {
+	files := []string{"go.mod", "pnpm-lock.yaml", "requirements.txt"}
+
+	var mu sync.Mutex
+	var ran []string
+	exec := &mode.ExecutorMock{
+		StatFunc: func(name string) (os.FileInfo, error) {
+			if slices.Contains(files, name) {
+				return nil, nil
+			}
+			return nil, os.ErrNotExist
+		},
+		RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, cmd.Name+" "+cmd.Args[0])
+			if cmd.Name == "pnpm" {
+				return nil, errors.New("exit status 1: no network")
+			}
+			return nil, nil
+		},
+	}
+
+	modes, err := mode.DefaultModes().Filter([]string{"go", "pnpm", "python", "rust", "npm"})
+	require.NoError(t, err)
+	results := modes.Warm(t.Context(), mode.WarmRequest{Exec: exec, Concurrency: 2})
+
+	require.ElementsMatch(t, []string{"go mod", "pnpm fetch", "pip download"}, ran)
+	require.Len(t, results, 4, "npm cannot be warmed")
+	require.NoError(t, results["go"].Err)
+	require.EqualError(t, results["pnpm"].Err, "pnpm fetch: exit status 1: no network")
+	require.NoError(t, results["rust"].Err)
+	require.False(t, slices.ContainsFunc(results["rust"].Checks, func(c mode.DetectCheck) bool {
+		return c.Kind == mode.CheckCommand
+	}), "rust has no Cargo.toml to warm")
+	require.True(t, slices.ContainsFunc(results["python"].Checks, func(c mode.DetectCheck) bool {
+		return c.Kind == mode.CheckCommand && strings.HasPrefix(c.Name, "pip download --requirement requirements.txt --dest ")
+	}))
+}
@@ -0,0 +1,83 @@
+package mode
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirExecutor resolves relative paths and runs commands in a working
+// directory other than the current one, so that providers detect and plan a
+// subproject without changing the directory of the process.
+type dirExecutor struct {
+	Executor
+	dir string
+}
+
+// inDir returns an executor working in dir, or exec itself when dir is the
+// current directory.
+func inDir(exec Executor, dir string) Executor {
+	if dir == "" || dir == "." {
+		return exec
+	}
+	return dirExecutor{Executor: exec, dir: dir}
+}
+
+func (e dirExecutor) Run(ctx context.Context, spec CommandSpec) ([]byte, error) {
+	if spec.Dir == "" {
+		spec.Dir = e.dir
+	}
+	return e.Executor.Run(ctx, spec)
+}
+
+func (e dirExecutor) Stat(name string) (os.FileInfo, error) {
+	return e.Executor.Stat(inWorkDir(e.dir, name))
+}
+
+func (e dirExecutor) ReadDir(name string) ([]os.DirEntry, error) {
+	return e.Executor.ReadDir(inWorkDir(e.dir, name))
+}
+
+func (e dirExecutor) ReadFile(name string) ([]byte, error) {
+	return e.Executor.ReadFile(inWorkDir(e.dir, name))
+}
+
+// inWorkDir resolves a path relative to the working directory dir. Absolute
+// paths and paths in the home directory are kept as they are.
+func inWorkDir(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// rebase makes the relative paths of a plan relative to the current
+// directory, as the mounter resolves them there.
+func (r PlanResult) rebase(dir string) PlanResult {
+	if dir == "" || dir == "." {
+		return r
+	}
+
+	rebased := func(paths []string) []string {
+		if paths == nil {
+			return nil
+		}
+		out := make([]string, len(paths))
+		for i, path := range paths {
+			out[i] = inWorkDir(dir, path)
+		}
+		return out
+	}
+	if r.Sources != nil {
+		sources := make(map[string]Source, len(r.Sources))
+		for path, source := range r.Sources {
+			sources[inWorkDir(dir, path)] = source
+		}
+		r.Sources = sources
+	}
+	r.MountPaths = rebased(r.MountPaths)
+	r.RemovePaths = rebased(r.RemovePaths)
+	r.KeyFiles = rebased(r.KeyFiles)
+	return r
+}
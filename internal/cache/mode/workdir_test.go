@@ -0,0 +1,68 @@
+package mode_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestWorkDir(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	t.Run("detects in the working directory", func(t *testing.T) {
+		modes := mode.Modes{mode.BunProvider{}}
+		results, err := modes.DetectAll(t.Context(), mode.DetectRequest{
+			WorkDir: filepath.Join("services", "api"),
+			Exec: &mode.ExecutorMock{
+				LookPathFunc: func(file string) (string, error) {
+					return "/usr/bin/bun", nil
+				},
+				StatFunc: func(name string) (os.FileInfo, error) {
+					if name == filepath.Join("services", "api", "bun.lock") {
+						return nil, nil
+					}
+					return nil, os.ErrNotExist
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, results[0].Detected)
+		require.Equal(t, mode.DetectCheck{
+			Kind:  mode.CheckFile,
+			Name:  "bun.lock",
+			Path:  filepath.Join(wd, "services", "api", "bun.lock"),
+			Found: true,
+		}, results[0].Checks[1])
+	})
+
+	t.Run("plans in the working directory", func(t *testing.T) {
+		t.Setenv("CPM_SOURCE_CACHE", "")
+
+		modes := mode.Modes{mode.CMakeProvider{}, mode.BunProvider{}}
+		plan, err := modes.Plan(t.Context(), mode.PlanRequest{
+			WorkDir: "app",
+			Exec: &mode.ExecutorMock{
+				ReadFileFunc: func(name string) ([]byte, error) {
+					require.Equal(t, filepath.Join("app", "CMakePresets.json"), name)
+					return nil, os.ErrNotExist
+				},
+				RunFunc: func(ctx context.Context, cmd mode.CommandSpec) ([]byte, error) {
+					require.Equal(t, "app", cmd.Dir)
+					return []byte("/home/user/.bun/install/cache\n"), nil
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		// Relative paths are relative to the current directory again.
+		require.Equal(t, []string{filepath.Join("app", "build")}, plan.Results["cmake"].MountPaths)
+		require.Equal(t, []string{"/home/user/.bun/install/cache"}, plan.Results["bun"].MountPaths)
+		require.Equal(t, []string{filepath.Join("app", "bun.lock"), filepath.Join("app", "bun.lockb")}, plan.Results["bun"].KeyFiles)
+	})
+}
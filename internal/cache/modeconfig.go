@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// ModeConfig overrides what a mode plans, e.g. when a team keeps a cache in
+// another place than the tool's default.
+type ModeConfig struct {
+	// Env overrides environment variables while the mode is planned, so
+	// that the mode plans the paths they point at, and adds them to the
+	// environment that is exported.
+	Env map[string]string `yaml:"env"`
+	// Paths replace the mount paths that the mode plans.
+	Paths []string `yaml:"paths"`
+	// DropPaths are mount paths of the mode that are not mounted.
+	DropPaths []string `yaml:"drop_paths"`
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseModeConfig parses overrides of the form mode.KEY=value. KEY is an
+// environment variable, or path and drop_path to replace or drop a mount
+// path; those may be repeated. The mode may be a custom mode with dots in its
+// name, e.g. acme.tools.path=/opt/acme.
+func ParseModeConfig(specs []string) (map[string]ModeConfig, error) {
+	configs := map[string]ModeConfig{}
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		// Split at the last dot: custom mode names may have dots, keys do not.
+		dot := strings.LastIndex(name, ".")
+		if !ok || dot <= 0 || dot == len(name)-1 {
+			return nil, fmt.Errorf("invalid mode config %q, expected mode.KEY=value", spec)
+		}
+		modeName, key := name[:dot], name[dot+1:]
+
+		c := configs[modeName]
+		switch key {
+		case "path":
+			c.Paths = append(c.Paths, value)
+		case "drop_path":
+			c.DropPaths = append(c.DropPaths, value)
+		default:
+			if !envNamePattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid mode config %q: %q is not an environment variable", spec, key)
+			}
+			if c.Env == nil {
+				c.Env = map[string]string{}
+			}
+			c.Env[key] = value
+		}
+		configs[modeName] = c
+	}
+	return configs, nil
+}
+
+// MergeModeConfigs merges overrides, with those of later configs taking
+// precedence. Paths set by a later config replace earlier ones.
+func MergeModeConfigs(configs ...map[string]ModeConfig) map[string]ModeConfig {
+	merged := map[string]ModeConfig{}
+	for _, config := range configs {
+		for modeName, c := range config {
+			m := merged[modeName]
+			if len(c.Env) > 0 {
+				if m.Env == nil {
+					m.Env = map[string]string{}
+				}
+				maps.Copy(m.Env, c.Env)
+			}
+			if len(c.Paths) > 0 {
+				m.Paths = c.Paths
+			}
+			m.DropPaths = append(m.DropPaths, c.DropPaths...)
+			merged[modeName] = m
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// validateModeConfigs checks that overrides refer to available modes and
+// environment variables.
+func validateModeConfigs(configs map[string]ModeConfig, available mode.Modes) error {
+	names := available.Names()
+	for _, modeName := range slices.Sorted(maps.Keys(configs)) {
+		if !slices.Contains(names, modeName) {
+			return fmt.Errorf("mode config: unknown mode: %s", modeName)
+		}
+		for key := range configs[modeName].Env {
+			if !envNamePattern.MatchString(key) {
+				return fmt.Errorf("mode config: %s: %q is not an environment variable", modeName, key)
+			}
+		}
+	}
+	return nil
+}
+
+// modeEnv returns the environment overrides of each mode.
+func modeEnv(configs map[string]ModeConfig) map[string]map[string]string {
+	env := map[string]map[string]string{}
+	for modeName, c := range configs {
+		if len(c.Env) > 0 {
+			env[modeName] = c.Env
+		}
+	}
+	return env
+}
+
+// apply merges the overrides into what a mode planned.
+func (c ModeConfig) apply(p mode.PlanResult) mode.PlanResult {
+	if len(c.Env) > 0 {
+		envs := make(map[string]string, len(p.AddEnvs)+len(c.Env))
+		maps.Copy(envs, p.AddEnvs)
+		maps.Copy(envs, c.Env)
+		p.AddEnvs = envs
+	}
+	if len(c.Paths) > 0 {
+		p.MountPaths = slices.Clone(c.Paths)
+		p.Sources = make(map[string]mode.Source, len(c.Paths))
+		for _, path := range c.Paths {
+			p.Sources[path] = mode.Source{Kind: mode.SourceConfig}
+		}
+	}
+	if len(c.DropPaths) > 0 {
+		p.MountPaths = slices.DeleteFunc(slices.Clone(p.MountPaths), func(path string) bool {
+			return slices.ContainsFunc(c.DropPaths, func(drop string) bool {
+				target := mountTarget(drop)
+				return drop == path || (target != "" && target == mountTarget(path))
+			})
+		})
+	}
+	return p
+}
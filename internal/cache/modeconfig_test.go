@@ -0,0 +1,55 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestParseModeConfig(t *testing.T) {
+	configs, err := cache.ParseModeConfig([]string{
+		"go.GOMODCACHE=/mnt/gomod",
+		"rust.path=target",
+		"rust.path=/sccache",
+		"bun.drop_path=~/.bun/install/cache",
+		"go.GOFLAGS=-mod=mod",
+		"acme.tools.path=/opt/acme",
+		"acme.tools.ACME_HOME=/opt/acme",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]cache.ModeConfig{
+		"go":   {Env: map[string]string{"GOMODCACHE": "/mnt/gomod", "GOFLAGS": "-mod=mod"}},
+		"rust": {Paths: []string{"target", "/sccache"}},
+		"bun":  {DropPaths: []string{"~/.bun/install/cache"}},
+		"acme.tools": {
+			Env:   map[string]string{"ACME_HOME": "/opt/acme"},
+			Paths: []string{"/opt/acme"},
+		},
+	}, configs)
+
+	for _, spec := range []string{"GOMODCACHE=/mnt", "go=/mnt", ".GOMODCACHE=/mnt", "go.GOMODCACHE", "go.GO-MODCACHE=/mnt", "go.=/mnt"} {
+		_, err := cache.ParseModeConfig([]string{spec})
+		require.Error(t, err, spec)
+	}
+}
+
+func TestMergeModeConfigs(t *testing.T) {
+	require.Nil(t, cache.MergeModeConfigs(nil, nil))
+
+	merged := cache.MergeModeConfigs(
+		map[string]cache.ModeConfig{
+			"go":   {Env: map[string]string{"GOMODCACHE": "/gomod", "GOFLAGS": "-mod=mod"}, DropPaths: []string{"/a"}},
+			"rust": {Paths: []string{"target"}},
+		},
+		map[string]cache.ModeConfig{
+			"go":   {Env: map[string]string{"GOMODCACHE": "/mnt/gomod"}, DropPaths: []string{"/b"}},
+			"rust": {Paths: []string{"/mnt/target"}},
+		},
+	)
+	require.Equal(t, map[string]cache.ModeConfig{
+		"go":   {Env: map[string]string{"GOMODCACHE": "/mnt/gomod", "GOFLAGS": "-mod=mod"}, DropPaths: []string{"/a", "/b"}},
+		"rust": {Paths: []string{"/mnt/target"}},
+	}, merged)
+}
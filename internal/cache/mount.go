@@ -2,54 +2,182 @@
 package cache
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/namespacelabs/spacectl/internal/cache/mode"
+	"github.com/namespacelabs/spacectl/internal/tracing"
 )
 
 type MountRequest struct {
 	DetectAllModes bool
 	DetectModes    []string
+	ExcludeModes   []string // skipped during detection, explicit modes are unaffected
 	ManualModes    []string
 	ManualPaths    []string
+	// Exclude maps mode names to glob patterns, relative to each of the
+	// mode's cached paths, that are dropped from the cache by `cache save`.
+	// Patterns of AllModes apply to every mount, including manual paths.
+	Exclude map[string][]string
+	// WorkDirs are the directories of the projects that modes are detected
+	// and planned in, e.g. subprojects of a monorepo. Defaults to the
+	// current directory.
+	WorkDirs []string
+	// Recursive also detects and plans modes in the subprojects found
+	// beneath each working directory, down to MaxDepth directories.
+	Recursive bool
+	MaxDepth  int
+	// ModeConfig maps mode names to overrides of what they plan.
+	ModeConfig map[string]ModeConfig
+	// Hooks maps mode names, or AllModes for every mount, to commands run
+	// before and after mounting.
+	Hooks map[string]Hooks
+	// Offline plans modes without running their tools, which may reach the
+	// network, assuming the default paths of the tools instead.
+	Offline bool
+	// Static plans modes without running anything, deriving paths from the
+	// environment and the defaults of the tools alone, e.g. before the
+	// tools are installed. It implies Offline.
+	Static bool
 }
 
 // EnabledModes returns the set of enabled cache modes based on the request.
 // It performs detection as necessary, based on the detect modes specified.
 func (req MountRequest) EnabledModes(ctx context.Context, available mode.Modes) (mode.Modes, error) {
+	return req.enabledModes(ctx, available, mode.DefaultExecutor{}, NopObserver{})
+}
+
+func (req MountRequest) enabledModes(ctx context.Context, available mode.Modes, exec mode.Executor, obs Observer) (mode.Modes, error) {
+	dirs, err := req.enabledModesByDir(ctx, available, exec, obs)
+	if err != nil {
+		return nil, err
+	}
+
+	var enabled []string
+	for _, dir := range dirs {
+		for _, m := range dir.Modes {
+			if !slices.Contains(enabled, m.Name()) {
+				enabled = append(enabled, m.Name())
+			}
+		}
+	}
+	return available.Filter(enabled)
+}
+
+// workDirModes are the modes enabled in a working directory.
+type workDirModes struct {
+	Dir   string
+	Modes mode.Modes
+	// Detect maps the modes detected in the directory to how long detecting
+	// them took.
+	Detect map[string]time.Duration
+}
+
+// enabledModesByDir detects modes in each working directory of the request.
+// Manual modes are enabled in all of them. obs is notified of each detection.
+func (req MountRequest) enabledModesByDir(ctx context.Context, available mode.Modes, exec mode.Executor, obs Observer) ([]workDirModes, error) {
 	if !req.DetectAllModes && len(req.DetectModes) == 0 && len(req.ManualModes) == 0 && len(req.ManualPaths) == 0 {
 		return nil, errors.New("at least one cache mode or path must be specified")
 	}
 
-	enabled := req.ManualModes
 	detect := req.DetectModes
 	if req.DetectAllModes {
 		detect = available.Names()
 	}
-	if len(detect) > 0 {
-		filtered, err := available.Filter(detect)
-		if err != nil {
-			return nil, err
+	if len(req.ExcludeModes) > 0 {
+		detect = slices.DeleteFunc(slices.Clone(detect), func(m string) bool {
+			return slices.Contains(req.ExcludeModes, m)
+		})
+	}
+
+	workDirs, err := req.findWorkDirs(ctx, available, exec, detect)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []workDirModes
+	for _, dir := range workDirs {
+		enabled := req.ManualModes
+		timings := map[string]time.Duration{}
+		if len(detect) > 0 {
+			filtered, err := available.Filter(detect)
+			if err != nil {
+				return nil, err
+			}
+
+			obs.OnDetectStart(dir, filtered.Names())
+			detectCtx, span := tracing.Start(ctx, "detect", tracing.String("spacectl.workdir", dir), tracing.Strings("spacectl.modes", filtered.Names()))
+			results, err := filtered.DetectAll(detectCtx, mode.DetectRequest{
+				Exec:    exec,
+				WorkDir: dir,
+			})
+			if err != nil {
+				span.End(err)
+				return nil, err
+			}
+
+			enabled = slices.Clip(enabled)
+			var detected []string
+			for i, result := range results {
+				obs.OnDetectResult(dir, filtered[i].Name(), result)
+				timings[filtered[i].Name()] = time.Duration(result.DurationMs) * time.Millisecond
+				if result.Detected {
+					enabled = append(enabled, filtered[i].Name())
+					detected = append(detected, filtered[i].Name())
+				}
+			}
+			span.SetAttributes(tracing.Strings("spacectl.detected", detected))
+			span.End(nil)
 		}
 
-		detected, err := filtered.Detect(ctx, mode.DetectRequest{
-			Exec: mode.DefaultExecutor{},
-		})
+		modes, err := available.Filter(enabled)
 		if err != nil {
 			return nil, err
 		}
+		dirs = append(dirs, workDirModes{Dir: dir, Modes: modes, Detect: timings})
+	}
+	return dirs, nil
+}
 
-		enabled = append(enabled, detected.Names()...)
+func (req MountRequest) workDirs() []string {
+	if len(req.WorkDirs) == 0 {
+		return []string{""}
 	}
+	return req.WorkDirs
+}
 
-	return available.Filter(enabled)
+// findWorkDirs returns the working directories of the request and, when
+// recursive, the subprojects beneath them that use the detected or manual
+// modes.
+func (req MountRequest) findWorkDirs(ctx context.Context, available mode.Modes, exec mode.Executor, detect []string) ([]string, error) {
+	if !req.Recursive {
+		return req.workDirs(), nil
+	}
+
+	modes, err := available.Filter(slices.Compact(slices.Sorted(slices.Values(slices.Concat(detect, req.ManualModes)))))
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := modes.FindProjects(ctx, mode.FindProjectsRequest{Exec: exec, WorkDirs: req.WorkDirs, MaxDepth: req.MaxDepth})
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("found projects", slog.Any("dirs", dirs))
+	return dirs, nil
 }
 
 type MountResponse struct {
@@ -63,30 +191,82 @@ type MountResponseInput struct {
 }
 
 type MountResponseOutput struct {
-	DestructiveMode bool              `json:"destructive_mode"`
-	AddEnvs         map[string]string `json:"add_envs,omitzero"`
-	DiskUsage       *DiskUsage        `json:"disk_usage,omitzero"` // lookup can fail, so inclusion is optional
-	Mounts          []MountResult     `json:"mounts,omitzero"`
-	RemovedPaths    []string          `json:"removed_paths,omitzero"`
+	DestructiveMode      bool              `json:"destructive_mode"`
+	Scope                string            `json:"scope,omitzero"`
+	ModeOrder            []string          `json:"mode_order,omitzero"`    // modes in the order they are mounted
+	SkippedModes         map[string]string `json:"skipped_modes,omitzero"` // mode to the enabled mode it conflicts with
+	AddEnvs              map[string]string `json:"add_envs,omitzero"`
+	DiskUsage            *DiskUsage        `json:"disk_usage,omitzero"`             // lookup can fail, so inclusion is optional
+	DurationMs           int64             `json:"duration_ms,omitzero"`            // the whole operation, including planning for Mount
+	Timings              []ModeTiming      `json:"timings,omitzero"`                // how long modes took to detect and plan
+	EstimatedGrowthBytes int64             `json:"estimated_growth_bytes,omitzero"` // sum of the estimated growth of the mounts
+	Mounts               []MountResult     `json:"mounts,omitzero"`
+	Evicted              []PruneEntry      `json:"evicted,omitzero"`      // caches evicted to free the volume
+	Deduplicated         []DedupedPath     `json:"deduplicated,omitzero"` // paths covered by another mount
+	RemovedPaths         []string          `json:"removed_paths,omitzero"`
+	Hooks                []HookResult      `json:"hooks,omitzero"`
+	Warnings             []MountIssue      `json:"warnings,omitzero"` // steps that degraded without failing
+	Errors               []MountIssue      `json:"errors,omitzero"`   // steps that failed
 }
 
-type MountResult struct {
-	Mode      string `json:"mode,omitzero"`
-	CachePath string `json:"cache_path"`
-	MountPath string `json:"mount_path"`
-	CacheHit  bool   `json:"cache_hit"`
+// MountIssue describes a cache step that failed or degraded. Warnings are
+// recoverable: they only degrade the job, e.g. its reporting. Errors are not,
+// as a path that failed to mount or be removed may be left in an unknown
+// state, and fail the mount.
+type MountIssue struct {
+	Mode        string `json:"mode,omitzero"`
+	Path        string `json:"path,omitzero"`
+	Message     string `json:"message"`
+	Recoverable bool   `json:"recoverable"`
 }
 
-type CacheMetadata struct {
-	UpdatedAt   string                        `json:"updatedAt"`
-	Version     int                           `json:"version"`
-	UserRequest map[string]CacheMetadataEntry `json:"userRequest"`
+// DedupedPath is a planned path that was not mounted because it is the same
+// as, or nested inside, a path that was.
+type DedupedPath struct {
+	Mode          string `json:"mode,omitzero"`
+	Path          string `json:"path"`
+	CoveredByMode string `json:"covered_by_mode,omitzero"`
+	CoveredBy     string `json:"covered_by"`
 }
 
-type CacheMetadataEntry struct {
-	CacheFramework *string  `json:"cacheFramework"`
-	MountTarget    []string `json:"mountTarget"`
-	Source         string   `json:"source"`
+type MountResult struct {
+	Mode      string `json:"mode,omitzero"`
+	CachePath string `json:"cache_path"`
+	MountPath string `json:"mount_path"`
+	// ResolvedPath is the absolute path that a relative mount path was
+	// resolved to in the project directory.
+	ResolvedPath string `json:"resolved_path,omitzero"`
+	CacheHit     bool   `json:"cache_hit"`
+	// Key is the cache key of the mode, for keyed caches.
+	Key string `json:"key,omitzero"`
+	// RestoredFrom is the cache path that a missing cache was seeded from,
+	// an earlier key or the default scope.
+	RestoredFrom string `json:"restored_from,omitzero"`
+	// Exclude lists the glob patterns dropped from the cache on save.
+	Exclude []string `json:"exclude,omitzero"`
+	// Strategy is how the cache path was mounted. It is empty for cache
+	// directories, and in dry-run mode unless a strategy was requested.
+	Strategy MountStrategy `json:"strategy,omitzero"`
+	// DurationMs is how long mounting took, in milliseconds.
+	DurationMs int64 `json:"duration_ms,omitzero"`
+	// ReadOnly is set for caches mounted read-only, which the job cannot
+	// modify.
+	ReadOnly bool `json:"read_only,omitzero"`
+	// Owner is the owner and mode of the mount path that were applied to
+	// the cache path, when ownership is preserved.
+	Owner *FileOwner `json:"owner,omitzero"`
+	// Assumed is set for paths of modes planned offline from the default
+	// paths of their tools.
+	Assumed bool `json:"assumed,omitzero"`
+	// Source is where the mount path came from, e.g. an environment
+	// variable or the default of the tool.
+	Source mode.Source `json:"source,omitzero"`
+	// EstimatedGrowthBytes is how much the cache is expected to grow during
+	// the job, from the stats of the last `cache save`.
+	EstimatedGrowthBytes int64 `json:"estimated_growth_bytes,omitzero"`
+	// Decompressed is set for caches that were compressed as they were
+	// rarely used, and decompressed to be mounted.
+	Decompressed bool `json:"decompressed,omitzero"`
 }
 
 func NewMounter(cacheRoot string) (Mounter, error) {
@@ -99,6 +279,7 @@ func NewMounter(cacheRoot string) (Mounter, error) {
 		CacheRoot: cacheRoot,
 		Exec:      DefaultExecutor{},
 		Modes:     mode.DefaultModes(),
+		LockRoot:  true,
 	}, nil
 }
 
@@ -107,105 +288,408 @@ type Mounter struct {
 	CacheRoot       string
 	Exec            Executor
 	Modes           mode.Modes
+	// Keyed stores the mount paths of modes with key files under
+	// <root>/<mode>/<key>, seeding a new key from the latest one on a miss.
+	Keyed bool
+	// Scope namespaces caches, typically per branch. Caches of scopes other
+	// than DefaultScope live under <root>/scopes/<scope> and are seeded from
+	// the default scope on a miss.
+	Scope        string
+	DefaultScope string
+	// Strategy is how mount paths are mounted. Empty means MountAuto.
+	Strategy MountStrategy
+	// ModeStrategies overrides Strategy for the mount paths of some modes,
+	// e.g. to share toolchains read-only with MountOverlay.
+	ModeStrategies map[string]MountStrategy
+	// Concurrency bounds how many paths are mounted at once. Zero means
+	// GOMAXPROCS.
+	Concurrency int
+	// CommandTimeout bounds each command run by mode providers. Zero means
+	// no limit.
+	CommandTimeout time.Duration
+	// Retries is how many times a failed mount, removal or directory
+	// creation is retried, with exponential backoff.
+	Retries int
+	// ToolVersion is the version of spacectl, recorded in the metadata.
+	ToolVersion string
+	// ReadOnly lists mode names, "*" for every mount, or mount paths whose
+	// caches are mounted read-only.
+	ReadOnly []string
+	// PreserveOwnership applies the owner and mode of each mount path to its
+	// cache path before mounting.
+	PreserveOwnership OwnershipMode
+	// AllowUnsafePaths disables the checks that refuse to mount over or
+	// remove system directories, the home directory and filesystem roots.
+	AllowUnsafePaths bool
+	// RequireVolume fails mounting when the cache root is a plain directory
+	// rather than a mounted volume, instead of warning.
+	RequireVolume bool
+	// MinFree is the space, in bytes, that the cache volume should have
+	// available before mounting, and after the caches grow as estimated.
+	// Mounting warns about less. Zero disables the check.
+	MinFree int64
+	// RequireFree fails mounting when the cache volume has less than MinFree
+	// bytes available, instead of warning.
+	RequireFree bool
+	// EvictHighWater is the usage of the cache volume, in percent, above
+	// which the least recently used caches are evicted before mounting,
+	// down to EvictLowWater. Zero disables eviction.
+	EvictHighWater float64
+	// EvictLowWater defaults to 10 points below EvictHighWater.
+	EvictLowWater float64
+	// AllowedRoots, when set, restricts the paths that are mounted over or
+	// removed to those beneath one of them.
+	AllowedRoots []string
+	// LockRoot holds an exclusive lock on the cache root while mounting,
+	// saving, importing or deleting caches, so that jobs sharing a volume
+	// take turns.
+	LockRoot bool
+	// LockTimeout bounds how long to wait for another job's lock on the
+	// cache root. Zero means wait indefinitely.
+	LockTimeout time.Duration
+	// Observer is notified of progress while mounting and pruning. Nil
+	// means no notifications.
+	Observer Observer
+	// ProjectDir is the directory that relative mount paths, e.g. ./target,
+	// are resolved against. Defaults to the current directory. Caches of
+	// relative paths are kept by the relative path, so that checkouts in
+	// different directories share them.
+	ProjectDir string
 }
 
 // Mount mounts the cache paths based on the given request.
 func (m Mounter) Mount(ctx context.Context, req MountRequest) (MountResponse, error) {
+	start := time.Now()
+	resp, err := m.mount(ctx, req)
+	resp.Output.DurationMs = time.Since(start).Milliseconds()
+	m.observer().OnComplete(resp, err)
+	return resp, err
+}
+
+func (m Mounter) mount(ctx context.Context, req MountRequest) (MountResponse, error) {
+	for _, patterns := range req.Exclude {
+		if err := ValidateExcludes(patterns); err != nil {
+			return MountResponse{}, err
+		}
+	}
+
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return MountResponse{}, err
+	}
+	defer unlock()
+
+	plan, err := m.plan(ctx, req)
+	if err != nil {
+		return MountResponse{}, err
+	}
+	return m.apply(ctx, plan)
+}
+
+// apply mounts the paths of a plan and removes the paths it removes.
+func (m Mounter) apply(ctx context.Context, plan Plan) (_ MountResponse, err error) {
+	ctx, span := tracing.Start(ctx, "apply", tracing.Bool("spacectl.dry_run", !m.DestructiveMode))
+	defer func() { span.End(err) }()
+
+	m.ProjectDir = cmp.Or(plan.ProjectDir, m.ProjectDir)
+
 	result := MountResponse{
+		Input: plan.Input,
 		Output: MountResponseOutput{
 			DestructiveMode: m.DestructiveMode,
+			Scope:           m.Scope,
+			ModeOrder:       plan.ModeOrder,
+			SkippedModes:    plan.SkippedModes,
+			AddEnvs:         plan.AddEnvs,
+			Timings:         plan.Timings,
+			Deduplicated:    plan.Deduplicated,
+			Warnings:        slices.Clone(plan.Warnings),
 		},
 	}
 
-	// Mount modes
-	modes, err := req.EnabledModes(ctx, m.Modes)
-	if err != nil {
+	if err := m.checkVolume(&result); err != nil {
 		return MountResponse{}, err
 	}
-	if err := m.mountModes(ctx, modes, &result); err != nil {
+	if err := m.evict(ctx, plan, &result); err != nil {
 		return MountResponse{}, err
 	}
-
-	// Mount manual paths
-	if err := m.mountPaths(ctx, req.ManualPaths, &result); err != nil {
+	if err := m.checkFreeSpace(ctx, &result); err != nil {
 		return MountResponse{}, err
 	}
 
+	failedHooks := m.runHooks(ctx, plan, HookPreMount, &result)
+
+	root := m.scopeRoot(m.Scope)
+	jobs := make([]mountJob, 0, len(plan.Mounts))
+	for _, mount := range plan.Mounts {
+		jobs = append(jobs, m.mountJob(root, mount))
+	}
+	m.runMounts(ctx, jobs, &result)
+
+	for _, path := range plan.RemovePaths {
+		removeCtx, span := tracing.Start(ctx, "remove", tracing.String("spacectl.path", path))
+		err := m.removePath(removeCtx, path, &result)
+		span.End(err)
+		m.observer().OnRemove(path, err)
+		if err != nil {
+			result.Output.Errors = append(result.Output.Errors, MountIssue{
+				Path:    path,
+				Message: fmt.Sprintf("removing mode path %q: %v", path, err),
+			})
+		}
+	}
+
+	failedHooks = append(failedHooks, m.runHooks(ctx, plan, HookPostMount, &result)...)
+
+	if m.DestructiveMode {
+		// Caches only grow when mounted. Estimates are read from the stats
+		// before the metadata is updated.
+		if err := m.estimateGrowth(result.Output.Mounts); err != nil {
+			slog.Debug("cannot estimate the growth of caches", slog.Any("error", err))
+		}
+		// Metadata only feeds reporting commands, so failing to record it must not fail the mount.
+		if err := m.updateMetadata(ctx, result.Output.Mounts); err != nil {
+			result.Output.Warnings = append(result.Output.Warnings, MountIssue{
+				Message:     fmt.Sprintf("failed to update cache metadata: %v", err),
+				Recoverable: true,
+			})
+		}
+	}
+
 	// Get disk usage (allowed to fail)
 	if usage, err := m.Exec.DiskUsage(ctx, m.CacheRoot); err == nil {
 		result.Output.DiskUsage = &usage
+		m.checkGrowth(usage, &result)
+	} else {
+		result.Output.Warnings = append(result.Output.Warnings, MountIssue{
+			Path:        m.CacheRoot,
+			Message:     fmt.Sprintf("failed to get disk usage: %v", err),
+			Recoverable: true,
+		})
 	}
 
-	return result, nil
-}
-
-func (m Mounter) mountModes(ctx context.Context, modes mode.Modes, result *MountResponse) error {
-	result.Input.Modes = modes.Names()
+	var failed []string
+	for _, issue := range result.Output.Errors {
+		if !issue.Recoverable && !slices.Contains(failedHooks, issue.Message) {
+			failed = append(failed, issue.Message)
+		}
+	}
+	hits, total := result.Hits()
+	span.SetAttributes(tracing.Int("spacectl.mounts", total), tracing.Int("spacectl.hits", hits), tracing.Int("spacectl.errors", len(result.Output.Errors)))
 
-	plan, err := modes.Plan(ctx, mode.PlanRequest{CacheRoot: m.CacheRoot})
-	if err != nil {
-		return err
+	var errs []error
+	if len(failed) > 0 {
+		errs = append(errs, fmt.Errorf("%d cache path(s) failed to mount or be removed: %s", len(failed), strings.Join(failed, "; ")))
+	}
+	if len(failedHooks) > 0 {
+		errs = append(errs, fmt.Errorf("%d hook(s) failed: %s", len(failedHooks), strings.Join(failedHooks, "; ")))
 	}
 
-	for modeName, p := range plan {
-		for k, v := range p.AddEnvs {
-			if result.Output.AddEnvs == nil {
-				result.Output.AddEnvs = make(map[string]string)
-			}
-			result.Output.AddEnvs[k] = v
-		}
+	return result, errors.Join(errs...)
+}
+
+// retryDelay is the delay before the first retry of a failed operation.
+var retryDelay = 200 * time.Millisecond
+
+// mountJob mounts a single cache path.
+type mountJob struct {
+	mode, path string
+	// after lists the modes whose jobs must finish before this one starts.
+	after []string
+	// auto is set for paths mounted with MountAuto.
+	auto bool
+	run  func(ctx context.Context) (MountResult, error)
+}
 
-		for _, subdir := range p.CacheDirs {
-			mount, err := m.cacheDir(modeName, subdir)
+// mountJob creates the job that mounts a planned path under root.
+func (m Mounter) mountJob(root string, planned PlannedMount) mountJob {
+	job := mountJob{mode: planned.Mode, path: planned.Path, after: planned.After}
+	switch {
+	case planned.CacheDir:
+		job.run = func(ctx context.Context) (MountResult, error) {
+			mount, err := m.cacheDir(ctx, root, planned.Mode, planned.Path)
 			if err != nil {
-				return fmt.Errorf("creating cache dir %q: %w", subdir, err)
+				return MountResult{}, fmt.Errorf("creating cache dir %q: %w", planned.Path, err)
 			}
-			result.Output.Mounts = append(result.Output.Mounts, mount)
+			mount.Exclude = planned.Exclude
+			mount.Assumed = planned.Assumed
+			return mount, nil
 		}
-
-		for _, path := range p.MountPaths {
-			mount, err := m.mountPath(ctx, modeName, path)
+	default:
+		job.auto = cmp.Or(planned.Strategy, MountAuto) == MountAuto
+		what := "mounting mode path"
+		if planned.Mode == "" {
+			what = "mounting path"
+		}
+		job.run = func(ctx context.Context) (MountResult, error) {
+			mount, err := m.mountPath(ctx, root, planned)
 			if err != nil {
-				return fmt.Errorf("mounting mode path %q: %w", path, err)
+				return MountResult{}, fmt.Errorf("%s %q: %w", what, planned.Path, err)
 			}
-			result.Output.Mounts = append(result.Output.Mounts, mount)
+			mount.Exclude = planned.Exclude
+			return mount, nil
+		}
+	}
+	return job
+}
+
+// projectPath resolves a relative path in the project directory. Other
+// paths are returned as they are.
+func (m Mounter) projectPath(path string) string {
+	if m.ProjectDir == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return path
+	}
+	return filepath.Join(m.ProjectDir, path)
+}
+
+// projectDir returns the directory that relative paths are resolved in.
+func (m Mounter) projectDir() (string, error) {
+	if m.ProjectDir != "" {
+		return filepath.Abs(m.ProjectDir)
+	}
+	return os.Getwd()
+}
+
+// mountTarget resolves path for comparison with other mount paths. It is
+// empty when path cannot be resolved, which mounting it reports.
+func mountTarget(path string) string {
+	path, err := resolveHome(path)
+	if err != nil {
+		return ""
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// runMounts runs up to Concurrency jobs at once. A failed job does not stop
+// the others, and is reported in the errors of the result. Mounts and errors
+// keep the order of the jobs, regardless of which finishes first.
+//
+// Jobs wait for the jobs of the modes they come after before they take a
+// slot, so that waiting jobs do not keep the jobs they wait for from running,
+// e.g. when --recursive plans the modes of each workdir after the last.
+func (m Mounter) runMounts(ctx context.Context, jobs []mountJob, result *MountResponse) {
+	mounts := make([]MountResult, len(jobs))
+	errs := make([]error, len(jobs))
+
+	pending := make(map[string]*sync.WaitGroup)
+	for _, job := range jobs {
+		if pending[job.mode] == nil {
+			pending[job.mode] = &sync.WaitGroup{}
 		}
+		pending[job.mode].Add(1)
+	}
 
-		for _, path := range p.RemovePaths {
-			if err := m.removePath(path, result); err != nil {
-				return fmt.Errorf("removing mode path %q: %w", path, err)
+	slots := make(chan struct{}, m.concurrency())
+	var running sync.WaitGroup
+	for i, job := range jobs {
+		running.Go(func() {
+			for _, before := range job.after {
+				if wg := pending[before]; wg != nil {
+					wg.Wait()
+				}
 			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			m.observer().OnMountStart(job.mode, job.path)
+			mountCtx, span := tracing.Start(ctx, "mount", tracing.String("spacectl.mode", job.mode), tracing.String("spacectl.path", job.path))
+			mounts[i], errs[i] = job.run(mountCtx)
+			span.SetAttributes(tracing.Bool("spacectl.cache_hit", mounts[i].CacheHit), tracing.String("spacectl.strategy", string(mounts[i].Strategy)))
+			span.End(errs[i])
+			m.observer().OnMountResult(job.mode, job.path, mounts[i], errs[i])
+			pending[job.mode].Done()
+		})
+	}
+	running.Wait()
+
+	for i, job := range jobs {
+		if errs[i] == nil {
+			result.Output.Mounts = append(result.Output.Mounts, mounts[i])
+			// Only linux bind mounts, so elsewhere symlinks are expected.
+			if job.auto && mounts[i].Strategy == MountSymlink && runtime.GOOS == "linux" {
+				result.Output.Warnings = append(result.Output.Warnings, MountIssue{
+					Mode:        job.mode,
+					Path:        job.path,
+					Message:     "bind mounts are not permitted, so the path was replaced by a symlink to its cache, which tools that resolve symlinks see through",
+					Recoverable: true,
+				})
+			}
+			continue
 		}
+
+		result.Output.Errors = append(result.Output.Errors, MountIssue{
+			Mode:    job.mode,
+			Path:    job.path,
+			Message: errs[i].Error(),
+		})
 	}
+}
 
-	return nil
+// modeExec runs the commands of mode providers.
+func (m Mounter) modeExec() mode.Executor {
+	return mode.DefaultExecutor{CommandTimeout: m.CommandTimeout}
 }
 
-func (m Mounter) mountPaths(ctx context.Context, paths []string, result *MountResponse) error {
-	result.Input.Paths = append(result.Input.Paths, paths...)
+// retry runs op until it succeeds or Retries retries have failed, doubling
+// the delay between attempts.
+func (m Mounter) retry(ctx context.Context, what string, op func() error) error {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= m.Retries {
+			return err
+		}
 
-	for _, path := range paths {
-		mount, err := m.mountPath(ctx, "", path)
-		if err != nil {
-			return fmt.Errorf("mounting path %q: %w", path, err)
+		slog.Debug(fmt.Sprintf("%s failed, retrying", what), slog.Any("error", err), slog.Duration("delay", delay))
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
 		}
-		result.Output.Mounts = append(result.Output.Mounts, mount)
+		delay *= 2
 	}
-	return nil
 }
 
-func (m Mounter) mountPath(ctx context.Context, modeName, path string) (MountResult, error) {
-	path, err := resolveHome(path)
+func (m Mounter) concurrency() int {
+	if m.Concurrency > 0 {
+		return m.Concurrency
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (m Mounter) mountPath(ctx context.Context, root string, planned PlannedMount) (MountResult, error) {
+	modeName, key := planned.Mode, planned.Key
+	if err := m.checkPathSafe(planned.Path); err != nil {
+		return MountResult{}, err
+	}
+
+	path, err := resolveHome(planned.Path)
 	if err != nil {
 		return MountResult{}, fmt.Errorf("resolving path: %w", err)
 	}
 
-	cachePath := filepath.Join(m.CacheRoot, RootSubpath(path))
+	subpath := m.cacheSubpath(path)
+	cachePath := cacheLocation(root, modeName, key, subpath)
+	if !isWithin(root, cachePath) {
+		return MountResult{}, fmt.Errorf("cache path %q of %q is outside the cache root %q", cachePath, path, root)
+	}
 
 	mount := MountResult{
 		Mode:      modeName,
 		CachePath: cachePath,
 		MountPath: path,
+		Key:       key,
+		ReadOnly:  planned.ReadOnly,
+		Assumed:   planned.Assumed,
+		Source:    planned.Source,
+	}
+	if target := m.projectPath(path); target != path {
+		mount.ResolvedPath = target
+		path = target
 	}
 
 	_, err = m.Exec.Stat(cachePath)
@@ -214,22 +698,95 @@ func (m Mounter) mountPath(ctx context.Context, modeName, path string) (MountRes
 	}
 	mount.CacheHit = err == nil
 
+	if !mount.CacheHit {
+		if err := m.restore(ctx, root, modeName, key, subpath, &mount); err != nil {
+			return MountResult{}, err
+		}
+	}
+	// Caches restored from a compressed one are compressed too.
+	if err := m.thaw(ctx, cachePath, &mount); err != nil {
+		return MountResult{}, err
+	}
+
+	// The mount path is about to be replaced, so its owner is read first.
+	owner, err := m.mountOwner(path)
+	if err != nil {
+		return MountResult{}, err
+	}
+	mount.Owner = owner
+
 	logAttrs := []any{slog.String("from", cachePath), slog.String("to", path)}
+	strategy := cmp.Or(planned.Strategy, MountAuto)
 	if !m.DestructiveMode {
+		mount.Strategy = planned.Strategy
 		slog.Debug("dry-run: would mount cache path", logAttrs...)
 		return mount, nil
 	}
 
 	slog.Debug("mounting cache path", logAttrs...)
 
-	if err := m.Exec.Mount(ctx, cachePath, path); err != nil {
+	if owner != nil {
+		if err := m.Exec.MkdirAll(cachePath, 0o755); err != nil {
+			return MountResult{}, fmt.Errorf("creating cache path %q: %w", cachePath, err)
+		}
+		if err := m.Exec.Chown(ctx, cachePath, *owner, m.PreserveOwnership == OwnershipRecursive); err != nil {
+			return MountResult{}, fmt.Errorf("preserving ownership of %q: %w", path, err)
+		}
+	}
+
+	start := time.Now()
+	err = m.retry(ctx, "mount", func() (err error) {
+		mount.Strategy, err = m.Exec.Mount(ctx, cachePath, path, strategy, mount.ReadOnly)
+		return err
+	})
+	mount.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
 		return MountResult{}, fmt.Errorf("mounting %q to %q: %w", cachePath, path, err)
 	}
 	return mount, nil
 }
 
-func (m Mounter) cacheDir(modeName, subdir string) (MountResult, error) {
-	cachePath := filepath.Join(m.CacheRoot, subdir)
+func (m Mounter) readOnly(modeName, path string) bool {
+	target := mountTarget(path)
+	return slices.ContainsFunc(m.ReadOnly, func(entry string) bool {
+		return entry == AllModes || (modeName != "" && entry == modeName) || (target != "" && mountTarget(entry) == target)
+	})
+}
+
+// mountOwner returns the owner of an existing mount path when ownership is
+// preserved, or nil.
+func (m Mounter) mountOwner(path string) (*FileOwner, error) {
+	if m.PreserveOwnership == "" || m.PreserveOwnership == OwnershipNone {
+		return nil, nil
+	}
+
+	info, err := m.Exec.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("stat mount path %q: %w", path, err)
+	}
+
+	owner, ok := fileOwner(info)
+	if !ok {
+		return nil, nil
+	}
+	return &owner, nil
+}
+
+func (m Mounter) strategy(modeName string) MountStrategy {
+	if s, ok := m.ModeStrategies[modeName]; ok && modeName != "" {
+		return s
+	}
+	if m.Strategy == "" {
+		return MountAuto
+	}
+	return m.Strategy
+}
+
+func (m Mounter) cacheDir(ctx context.Context, root, modeName, subdir string) (MountResult, error) {
+	cachePath := filepath.Join(root, subdir)
 
 	mount := MountResult{
 		Mode:      modeName,
@@ -243,6 +800,16 @@ func (m Mounter) cacheDir(modeName, subdir string) (MountResult, error) {
 	}
 	mount.CacheHit = err == nil
 
+	if !mount.CacheHit {
+		if err := m.restore(ctx, root, modeName, "", subdir, &mount); err != nil {
+			return MountResult{}, err
+		}
+	}
+	// Caches restored from a compressed one are compressed too.
+	if err := m.thaw(ctx, cachePath, &mount); err != nil {
+		return MountResult{}, err
+	}
+
 	if !m.DestructiveMode {
 		slog.Debug("dry-run: would create cache dir", slog.String("path", cachePath))
 		return mount, nil
@@ -250,13 +817,79 @@ func (m Mounter) cacheDir(modeName, subdir string) (MountResult, error) {
 
 	slog.Debug("creating cache dir", slog.String("path", cachePath))
 
-	if err := m.Exec.MkdirAll(cachePath, 0o755); err != nil {
+	if err := m.retry(ctx, "mkdir", func() error { return m.Exec.MkdirAll(cachePath, 0o755) }); err != nil {
 		return MountResult{}, fmt.Errorf("creating cache dir %q: %w", cachePath, err)
 	}
 	return mount, nil
 }
 
-func (m Mounter) removePath(path string, result *MountResponse) error {
+// restore seeds a missing cache from the closest existing one, if any.
+func (m Mounter) restore(ctx context.Context, root, modeName, key, subpath string, mount *MountResult) error {
+	source, err := m.restoreSource(root, modeName, key, subpath)
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return nil
+	}
+	mount.RestoredFrom = source
+
+	logAttrs := []any{slog.String("from", mount.RestoredFrom), slog.String("to", mount.CachePath)}
+	if !m.DestructiveMode {
+		slog.Debug("dry-run: would restore cache", logAttrs...)
+		return nil
+	}
+
+	slog.Debug("restoring cache", logAttrs...)
+
+	if err := m.Exec.MkdirAll(filepath.Dir(mount.CachePath), 0o755); err != nil {
+		return fmt.Errorf("creating cache path parent: %w", err)
+	}
+	// The copy is moved in once complete, as an interrupted copy left at the
+	// cache path would be taken for a cache hit by the next mount.
+	tmp := mount.CachePath + ".restoring"
+	if err := m.Exec.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("removing %q: %w", tmp, err)
+	}
+	if err := m.Exec.CopyDir(ctx, mount.RestoredFrom, tmp); err != nil {
+		return errors.Join(fmt.Errorf("restoring %q from %q: %w", mount.CachePath, mount.RestoredFrom, err), m.Exec.RemoveAll(tmp))
+	}
+	if err := m.Exec.Rename(tmp, mount.CachePath); err != nil {
+		return errors.Join(fmt.Errorf("moving %q to %q: %w", tmp, mount.CachePath, err), m.Exec.RemoveAll(tmp))
+	}
+	return nil
+}
+
+// restoreSource looks for the same key in the default scope, then the latest
+// key of the scope, then the latest key of the default scope. This mirrors
+// the restore order of actions/cache.
+func (m Mounter) restoreSource(root, modeName, key, subpath string) (string, error) {
+	if root != m.CacheRoot {
+		source := cacheLocation(m.CacheRoot, modeName, key, subpath)
+		if _, err := m.Exec.Stat(source); err == nil {
+			return source, nil
+		}
+	}
+	if key == "" {
+		return "", nil
+	}
+
+	for _, r := range slices.Compact([]string{root, m.CacheRoot}) {
+		latest, err := m.latestKey(r, modeName, key, subpath)
+		if err != nil {
+			return "", fmt.Errorf("looking up previous keys of mode %q: %w", modeName, err)
+		}
+		if latest != "" {
+			return cacheLocation(r, modeName, latest, subpath), nil
+		}
+	}
+	return "", nil
+}
+
+func (m Mounter) removePath(ctx context.Context, path string, result *MountResponse) error {
+	if err := m.checkPathSafe(path); err != nil {
+		return err
+	}
 	result.Output.RemovedPaths = append(result.Output.RemovedPaths, path)
 
 	if !m.DestructiveMode {
@@ -266,46 +899,121 @@ func (m Mounter) removePath(path string, result *MountResponse) error {
 
 	slog.Debug("removing path", slog.String("path", path))
 
-	if err := m.Exec.RemoveAll(path); err != nil {
+	if err := m.retry(ctx, "remove", func() error { return m.Exec.RemoveAll(m.projectPath(path)) }); err != nil {
 		return fmt.Errorf("removing %q: %w", path, err)
 	}
 	return nil
 }
 
 type Executor interface {
+	// Chown applies owner to path, and to everything beneath it when
+	// recursive. The mode is only applied to path itself.
+	Chown(ctx context.Context, path string, owner FileOwner, recursive bool) error
+	CopyDir(ctx context.Context, from, to string) error
 	DiskUsage(ctx context.Context, path string) (DiskUsage, error)
 	MkdirAll(path string, perm os.FileMode) error
-	Mount(ctx context.Context, from, to string) error
+	// Lock takes an exclusive lock on the file at path, creating it, and
+	// waits for other holders until ctx is done. The returned function
+	// releases the lock.
+	Lock(ctx context.Context, path string) (func() error, error)
+	// Mount makes from available at to with the given strategy, and returns
+	// the strategy that MountAuto resolved to. Read-only mounts need a bind
+	// or overlay mount.
+	Mount(ctx context.Context, from, to string, strategy MountStrategy, readOnly bool) (MountStrategy, error)
+	ReadFile(name string) ([]byte, error)
 	RemoveAll(name string) error
+	Rename(from, to string) error
+	// Shell runs a shell command in dir with env added to the environment,
+	// and returns what it wrote to stdout and stderr.
+	Shell(ctx context.Context, dir, command string, env []string) (stdout, stderr []byte, err error)
 	Stat(name string) (os.FileInfo, error)
+	Sync(ctx context.Context) error
 	WriteFile(name string, data []byte, perm os.FileMode) error
 }
 
 type DiskUsage struct {
-	Total string `json:"total"`
-	Used  string `json:"used"`
+	Total          string  `json:"total"`
+	Used           string  `json:"used"`
+	Available      string  `json:"available"`
+	TotalBytes     uint64  `json:"total_bytes"`
+	UsedBytes      uint64  `json:"used_bytes"`
+	AvailableBytes uint64  `json:"available_bytes"`
+	UsagePercent   float64 `json:"usage_percent"`
 }
 
-type DefaultExecutor struct{}
+// newDiskUsage computes the usage of a volume like df does: space reserved
+// for root is neither used nor available, so it does not count towards the
+// usage percentage.
+func newDiskUsage(total, free, available uint64) DiskUsage {
+	used := total - free
+	var percent float64
+	if used+available > 0 {
+		percent = math.Round(float64(used)/float64(used+available)*1000) / 10
+	}
+
+	return DiskUsage{
+		Total:          humanizeBytes(total),
+		Used:           humanizeBytes(used),
+		Available:      humanizeBytes(available),
+		TotalBytes:     total,
+		UsedBytes:      used,
+		AvailableBytes: available,
+		UsagePercent:   percent,
+	}
+}
+
+type DefaultExecutor struct {
+	// Rootless runs without sudo, for containers and runners where it is
+	// unavailable. Mount paths are then bound with bindfs when it is
+	// installed, or replaced by symlinks to the cache path.
+	Rootless bool
+}
+
+func (e DefaultExecutor) Mount(ctx context.Context, from, to string, strategy MountStrategy, readOnly bool) (MountStrategy, error) {
+	if readOnly && (strategy == MountSymlink || strategy == MountCopy) {
+		return "", fmt.Errorf("%s mounts cannot be read-only", strategy)
+	}
 
-func (e DefaultExecutor) Mount(ctx context.Context, from, to string) error {
 	exists, err := MountTargetExists(to)
 	if err != nil {
-		return fmt.Errorf("checking mount target: %w", err)
+		return "", fmt.Errorf("checking mount target: %w", err)
 	}
 	if exists {
 		slog.Debug("mount target will be overwritten", slog.String("path", to))
 	}
 
-	slog.Debug("mounting path", slog.String("from", from), slog.String("to", to))
+	slog.Debug("mounting path", slog.String("from", from), slog.String("to", to), slog.String("strategy", string(strategy)))
 
 	// create cache path, this is noop if it already exists
 	if err := os.MkdirAll(from, 0o755); err != nil {
-		return fmt.Errorf("creating from path %q: %w", from, err)
+		return "", fmt.Errorf("creating from path %q: %w", from, err)
 	}
 
 	// os specific mount logic
-	return mount(ctx, from, to)
+	switch strategy {
+	case MountAuto:
+		return e.mountAuto(ctx, from, to, readOnly)
+	case MountBind:
+		return MountBind, e.bind(ctx, from, to, readOnly)
+	case MountSymlink:
+		return MountSymlink, e.symlink(ctx, from, to)
+	case MountCopy:
+		return MountCopy, e.copyTo(ctx, from, to)
+	case MountOverlay:
+		return MountOverlay, e.overlay(ctx, from, to, readOnly)
+	default:
+		return "", fmt.Errorf("unknown mount strategy %q", strategy)
+	}
+}
+
+func (e DefaultExecutor) Shell(ctx context.Context, dir, command string, env []string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
 }
 
 func (e DefaultExecutor) Stat(name string) (os.FileInfo, error) {
@@ -316,8 +1024,32 @@ func (e DefaultExecutor) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
 
+func (e DefaultExecutor) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile replaces name atomically: data is written to a temporary file in
+// the same directory, which is then renamed over name.
 func (e DefaultExecutor) WriteFile(name string, data []byte, perm os.FileMode) error {
-	return os.WriteFile(name, data, perm)
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // fails harmlessly once renamed
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
 }
 
 func absDir(path string) (string, error) {
@@ -441,6 +1173,23 @@ func resolveHome(path string) (string, error) {
 	return path, nil
 }
 
+// cacheSubpath returns where the cache of a home-resolved mount path lives
+// beneath the cache root. It is computed before relative paths are made
+// absolute, so that the cache of a relative path, like node_modules, is
+// shared by all project directories. Relative paths that leave the project
+// directory, like those of a --workdir next to it, are made absolute first,
+// as they would otherwise lead out of the cache root.
+func (m Mounter) cacheSubpath(path string) string {
+	if !filepath.IsAbs(path) {
+		if rel := filepath.Clean(path); rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			if abs, err := filepath.Abs(m.projectPath(path)); err == nil {
+				path = abs
+			}
+		}
+	}
+	return RootSubpath(path)
+}
+
 // RootSubpath returns where a workload path lives beneath the cache root.
 //
 // Unix paths have no volume and are returned unchanged. On Windows the volume
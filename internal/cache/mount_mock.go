@@ -19,21 +19,42 @@ var _ Executor = &ExecutorMock{}
 //
 //		// make and configure a mocked Executor
 //		mockedExecutor := &ExecutorMock{
+//			ChownFunc: func(ctx context.Context, path string, owner FileOwner, recursive bool) error {
+//				panic("mock out the Chown method")
+//			},
+//			CopyDirFunc: func(ctx context.Context, from string, to string) error {
+//				panic("mock out the CopyDir method")
+//			},
 //			DiskUsageFunc: func(ctx context.Context, path string) (DiskUsage, error) {
 //				panic("mock out the DiskUsage method")
 //			},
+//			LockFunc: func(ctx context.Context, path string) (func() error, error) {
+//				panic("mock out the Lock method")
+//			},
 //			MkdirAllFunc: func(path string, perm os.FileMode) error {
 //				panic("mock out the MkdirAll method")
 //			},
-//			MountFunc: func(ctx context.Context, from string, to string) error {
+//			MountFunc: func(ctx context.Context, from string, to string, strategy MountStrategy, readOnly bool) (MountStrategy, error) {
 //				panic("mock out the Mount method")
 //			},
+//			ReadFileFunc: func(name string) ([]byte, error) {
+//				panic("mock out the ReadFile method")
+//			},
 //			RemoveAllFunc: func(name string) error {
 //				panic("mock out the RemoveAll method")
 //			},
+//			RenameFunc: func(from string, to string) error {
+//				panic("mock out the Rename method")
+//			},
+//			ShellFunc: func(ctx context.Context, dir string, command string, env []string) ([]byte, []byte, error) {
+//				panic("mock out the Shell method")
+//			},
 //			StatFunc: func(name string) (os.FileInfo, error) {
 //				panic("mock out the Stat method")
 //			},
+//			SyncFunc: func(ctx context.Context) error {
+//				panic("mock out the Sync method")
+//			},
 //			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 //				panic("mock out the WriteFile method")
 //			},
@@ -44,26 +65,67 @@ var _ Executor = &ExecutorMock{}
 //
 //	}
 type ExecutorMock struct {
+	// ChownFunc mocks the Chown method.
+	ChownFunc func(ctx context.Context, path string, owner FileOwner, recursive bool) error
+
+	// CopyDirFunc mocks the CopyDir method.
+	CopyDirFunc func(ctx context.Context, from string, to string) error
+
 	// DiskUsageFunc mocks the DiskUsage method.
 	DiskUsageFunc func(ctx context.Context, path string) (DiskUsage, error)
 
+	// LockFunc mocks the Lock method.
+	LockFunc func(ctx context.Context, path string) (func() error, error)
+
 	// MkdirAllFunc mocks the MkdirAll method.
 	MkdirAllFunc func(path string, perm os.FileMode) error
 
 	// MountFunc mocks the Mount method.
-	MountFunc func(ctx context.Context, from string, to string) error
+	MountFunc func(ctx context.Context, from string, to string, strategy MountStrategy, readOnly bool) (MountStrategy, error)
+
+	// ReadFileFunc mocks the ReadFile method.
+	ReadFileFunc func(name string) ([]byte, error)
 
 	// RemoveAllFunc mocks the RemoveAll method.
 	RemoveAllFunc func(name string) error
 
+	// RenameFunc mocks the Rename method.
+	RenameFunc func(from string, to string) error
+
+	// ShellFunc mocks the Shell method.
+	ShellFunc func(ctx context.Context, dir string, command string, env []string) ([]byte, []byte, error)
+
 	// StatFunc mocks the Stat method.
 	StatFunc func(name string) (os.FileInfo, error)
 
+	// SyncFunc mocks the Sync method.
+	SyncFunc func(ctx context.Context) error
+
 	// WriteFileFunc mocks the WriteFile method.
 	WriteFileFunc func(name string, data []byte, perm os.FileMode) error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// Chown holds details about calls to the Chown method.
+		Chown []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Path is the path argument value.
+			Path string
+			// Owner is the owner argument value.
+			Owner FileOwner
+			// Recursive is the recursive argument value.
+			Recursive bool
+		}
+		// CopyDir holds details about calls to the CopyDir method.
+		CopyDir []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// From is the from argument value.
+			From string
+			// To is the to argument value.
+			To string
+		}
 		// DiskUsage holds details about calls to the DiskUsage method.
 		DiskUsage []struct {
 			// Ctx is the ctx argument value.
@@ -71,6 +133,13 @@ type ExecutorMock struct {
 			// Path is the path argument value.
 			Path string
 		}
+		// Lock holds details about calls to the Lock method.
+		Lock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Path is the path argument value.
+			Path string
+		}
 		// MkdirAll holds details about calls to the MkdirAll method.
 		MkdirAll []struct {
 			// Path is the path argument value.
@@ -86,17 +155,49 @@ type ExecutorMock struct {
 			From string
 			// To is the to argument value.
 			To string
+			// Strategy is the strategy argument value.
+			Strategy MountStrategy
+			// ReadOnly is the readOnly argument value.
+			ReadOnly bool
+		}
+		// ReadFile holds details about calls to the ReadFile method.
+		ReadFile []struct {
+			// Name is the name argument value.
+			Name string
 		}
 		// RemoveAll holds details about calls to the RemoveAll method.
 		RemoveAll []struct {
 			// Name is the name argument value.
 			Name string
 		}
+		// Rename holds details about calls to the Rename method.
+		Rename []struct {
+			// From is the from argument value.
+			From string
+			// To is the to argument value.
+			To string
+		}
+		// Shell holds details about calls to the Shell method.
+		Shell []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Dir is the dir argument value.
+			Dir string
+			// Command is the command argument value.
+			Command string
+			// Env is the env argument value.
+			Env []string
+		}
 		// Stat holds details about calls to the Stat method.
 		Stat []struct {
 			// Name is the name argument value.
 			Name string
 		}
+		// Sync holds details about calls to the Sync method.
+		Sync []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// WriteFile holds details about calls to the WriteFile method.
 		WriteFile []struct {
 			// Name is the name argument value.
@@ -107,14 +208,105 @@ type ExecutorMock struct {
 			Perm os.FileMode
 		}
 	}
+	lockChown     sync.RWMutex
+	lockCopyDir   sync.RWMutex
 	lockDiskUsage sync.RWMutex
+	lockLock      sync.RWMutex
 	lockMkdirAll  sync.RWMutex
 	lockMount     sync.RWMutex
+	lockReadFile  sync.RWMutex
 	lockRemoveAll sync.RWMutex
+	lockRename    sync.RWMutex
+	lockShell     sync.RWMutex
 	lockStat      sync.RWMutex
+	lockSync      sync.RWMutex
 	lockWriteFile sync.RWMutex
 }
 
+// Chown calls ChownFunc.
+func (mock *ExecutorMock) Chown(ctx context.Context, path string, owner FileOwner, recursive bool) error {
+	if mock.ChownFunc == nil {
+		panic("ExecutorMock.ChownFunc: method is nil but Executor.Chown was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Path      string
+		Owner     FileOwner
+		Recursive bool
+	}{
+		Ctx:       ctx,
+		Path:      path,
+		Owner:     owner,
+		Recursive: recursive,
+	}
+	mock.lockChown.Lock()
+	mock.calls.Chown = append(mock.calls.Chown, callInfo)
+	mock.lockChown.Unlock()
+	return mock.ChownFunc(ctx, path, owner, recursive)
+}
+
+// ChownCalls gets all the calls that were made to Chown.
+// Check the length with:
+//
+//	len(mockedExecutor.ChownCalls())
+func (mock *ExecutorMock) ChownCalls() []struct {
+	Ctx       context.Context
+	Path      string
+	Owner     FileOwner
+	Recursive bool
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Path      string
+		Owner     FileOwner
+		Recursive bool
+	}
+	mock.lockChown.RLock()
+	calls = mock.calls.Chown
+	mock.lockChown.RUnlock()
+	return calls
+}
+
+// CopyDir calls CopyDirFunc.
+func (mock *ExecutorMock) CopyDir(ctx context.Context, from string, to string) error {
+	if mock.CopyDirFunc == nil {
+		panic("ExecutorMock.CopyDirFunc: method is nil but Executor.CopyDir was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		From string
+		To   string
+	}{
+		Ctx:  ctx,
+		From: from,
+		To:   to,
+	}
+	mock.lockCopyDir.Lock()
+	mock.calls.CopyDir = append(mock.calls.CopyDir, callInfo)
+	mock.lockCopyDir.Unlock()
+	return mock.CopyDirFunc(ctx, from, to)
+}
+
+// CopyDirCalls gets all the calls that were made to CopyDir.
+// Check the length with:
+//
+//	len(mockedExecutor.CopyDirCalls())
+func (mock *ExecutorMock) CopyDirCalls() []struct {
+	Ctx  context.Context
+	From string
+	To   string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		From string
+		To   string
+	}
+	mock.lockCopyDir.RLock()
+	calls = mock.calls.CopyDir
+	mock.lockCopyDir.RUnlock()
+	return calls
+}
+
 // DiskUsage calls DiskUsageFunc.
 func (mock *ExecutorMock) DiskUsage(ctx context.Context, path string) (DiskUsage, error) {
 	if mock.DiskUsageFunc == nil {
@@ -151,6 +343,42 @@ func (mock *ExecutorMock) DiskUsageCalls() []struct {
 	return calls
 }
 
+// Lock calls LockFunc.
+func (mock *ExecutorMock) Lock(ctx context.Context, path string) (func() error, error) {
+	if mock.LockFunc == nil {
+		panic("ExecutorMock.LockFunc: method is nil but Executor.Lock was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Path string
+	}{
+		Ctx:  ctx,
+		Path: path,
+	}
+	mock.lockLock.Lock()
+	mock.calls.Lock = append(mock.calls.Lock, callInfo)
+	mock.lockLock.Unlock()
+	return mock.LockFunc(ctx, path)
+}
+
+// LockCalls gets all the calls that were made to Lock.
+// Check the length with:
+//
+//	len(mockedExecutor.LockCalls())
+func (mock *ExecutorMock) LockCalls() []struct {
+	Ctx  context.Context
+	Path string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Path string
+	}
+	mock.lockLock.RLock()
+	calls = mock.calls.Lock
+	mock.lockLock.RUnlock()
+	return calls
+}
+
 // MkdirAll calls MkdirAllFunc.
 func (mock *ExecutorMock) MkdirAll(path string, perm os.FileMode) error {
 	if mock.MkdirAllFunc == nil {
@@ -188,23 +416,27 @@ func (mock *ExecutorMock) MkdirAllCalls() []struct {
 }
 
 // Mount calls MountFunc.
-func (mock *ExecutorMock) Mount(ctx context.Context, from string, to string) error {
+func (mock *ExecutorMock) Mount(ctx context.Context, from string, to string, strategy MountStrategy, readOnly bool) (MountStrategy, error) {
 	if mock.MountFunc == nil {
 		panic("ExecutorMock.MountFunc: method is nil but Executor.Mount was just called")
 	}
 	callInfo := struct {
-		Ctx  context.Context
-		From string
-		To   string
+		Ctx      context.Context
+		From     string
+		To       string
+		Strategy MountStrategy
+		ReadOnly bool
 	}{
-		Ctx:  ctx,
-		From: from,
-		To:   to,
+		Ctx:      ctx,
+		From:     from,
+		To:       to,
+		Strategy: strategy,
+		ReadOnly: readOnly,
 	}
 	mock.lockMount.Lock()
 	mock.calls.Mount = append(mock.calls.Mount, callInfo)
 	mock.lockMount.Unlock()
-	return mock.MountFunc(ctx, from, to)
+	return mock.MountFunc(ctx, from, to, strategy, readOnly)
 }
 
 // MountCalls gets all the calls that were made to Mount.
@@ -212,14 +444,18 @@ func (mock *ExecutorMock) Mount(ctx context.Context, from string, to string) err
 //
 //	len(mockedExecutor.MountCalls())
 func (mock *ExecutorMock) MountCalls() []struct {
-	Ctx  context.Context
-	From string
-	To   string
+	Ctx      context.Context
+	From     string
+	To       string
+	Strategy MountStrategy
+	ReadOnly bool
 } {
 	var calls []struct {
-		Ctx  context.Context
-		From string
-		To   string
+		Ctx      context.Context
+		From     string
+		To       string
+		Strategy MountStrategy
+		ReadOnly bool
 	}
 	mock.lockMount.RLock()
 	calls = mock.calls.Mount
@@ -227,6 +463,38 @@ func (mock *ExecutorMock) MountCalls() []struct {
 	return calls
 }
 
+// ReadFile calls ReadFileFunc.
+func (mock *ExecutorMock) ReadFile(name string) ([]byte, error) {
+	if mock.ReadFileFunc == nil {
+		panic("ExecutorMock.ReadFileFunc: method is nil but Executor.ReadFile was just called")
+	}
+	callInfo := struct {
+		Name string
+	}{
+		Name: name,
+	}
+	mock.lockReadFile.Lock()
+	mock.calls.ReadFile = append(mock.calls.ReadFile, callInfo)
+	mock.lockReadFile.Unlock()
+	return mock.ReadFileFunc(name)
+}
+
+// ReadFileCalls gets all the calls that were made to ReadFile.
+// Check the length with:
+//
+//	len(mockedExecutor.ReadFileCalls())
+func (mock *ExecutorMock) ReadFileCalls() []struct {
+	Name string
+} {
+	var calls []struct {
+		Name string
+	}
+	mock.lockReadFile.RLock()
+	calls = mock.calls.ReadFile
+	mock.lockReadFile.RUnlock()
+	return calls
+}
+
 // RemoveAll calls RemoveAllFunc.
 func (mock *ExecutorMock) RemoveAll(name string) error {
 	if mock.RemoveAllFunc == nil {
@@ -259,6 +527,86 @@ func (mock *ExecutorMock) RemoveAllCalls() []struct {
 	return calls
 }
 
+// Rename calls RenameFunc.
+func (mock *ExecutorMock) Rename(from string, to string) error {
+	if mock.RenameFunc == nil {
+		panic("ExecutorMock.RenameFunc: method is nil but Executor.Rename was just called")
+	}
+	callInfo := struct {
+		From string
+		To   string
+	}{
+		From: from,
+		To:   to,
+	}
+	mock.lockRename.Lock()
+	mock.calls.Rename = append(mock.calls.Rename, callInfo)
+	mock.lockRename.Unlock()
+	return mock.RenameFunc(from, to)
+}
+
+// RenameCalls gets all the calls that were made to Rename.
+// Check the length with:
+//
+//	len(mockedExecutor.RenameCalls())
+func (mock *ExecutorMock) RenameCalls() []struct {
+	From string
+	To   string
+} {
+	var calls []struct {
+		From string
+		To   string
+	}
+	mock.lockRename.RLock()
+	calls = mock.calls.Rename
+	mock.lockRename.RUnlock()
+	return calls
+}
+
+// Shell calls ShellFunc.
+func (mock *ExecutorMock) Shell(ctx context.Context, dir string, command string, env []string) ([]byte, []byte, error) {
+	if mock.ShellFunc == nil {
+		panic("ExecutorMock.ShellFunc: method is nil but Executor.Shell was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Dir     string
+		Command string
+		Env     []string
+	}{
+		Ctx:     ctx,
+		Dir:     dir,
+		Command: command,
+		Env:     env,
+	}
+	mock.lockShell.Lock()
+	mock.calls.Shell = append(mock.calls.Shell, callInfo)
+	mock.lockShell.Unlock()
+	return mock.ShellFunc(ctx, dir, command, env)
+}
+
+// ShellCalls gets all the calls that were made to Shell.
+// Check the length with:
+//
+//	len(mockedExecutor.ShellCalls())
+func (mock *ExecutorMock) ShellCalls() []struct {
+	Ctx     context.Context
+	Dir     string
+	Command string
+	Env     []string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Dir     string
+		Command string
+		Env     []string
+	}
+	mock.lockShell.RLock()
+	calls = mock.calls.Shell
+	mock.lockShell.RUnlock()
+	return calls
+}
+
 // Stat calls StatFunc.
 func (mock *ExecutorMock) Stat(name string) (os.FileInfo, error) {
 	if mock.StatFunc == nil {
@@ -291,6 +639,38 @@ func (mock *ExecutorMock) StatCalls() []struct {
 	return calls
 }
 
+// Sync calls SyncFunc.
+func (mock *ExecutorMock) Sync(ctx context.Context) error {
+	if mock.SyncFunc == nil {
+		panic("ExecutorMock.SyncFunc: method is nil but Executor.Sync was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockSync.Lock()
+	mock.calls.Sync = append(mock.calls.Sync, callInfo)
+	mock.lockSync.Unlock()
+	return mock.SyncFunc(ctx)
+}
+
+// SyncCalls gets all the calls that were made to Sync.
+// Check the length with:
+//
+//	len(mockedExecutor.SyncCalls())
+func (mock *ExecutorMock) SyncCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockSync.RLock()
+	calls = mock.calls.Sync
+	mock.lockSync.RUnlock()
+	return calls
+}
+
 // WriteFile calls WriteFileFunc.
 func (mock *ExecutorMock) WriteFile(name string, data []byte, perm os.FileMode) error {
 	if mock.WriteFileFunc == nil {
@@ -2,10 +2,17 @@ package cache_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -32,12 +39,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 		})
 		require.NoError(t, err)
@@ -52,16 +63,22 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "golangci-lint" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "golangci-lint" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.NoError(t, err)
@@ -76,12 +93,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.NoError(t, err)
@@ -95,16 +116,22 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "golangci-lint" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "golangci-lint" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.NoError(t, err)
@@ -112,6 +139,46 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 		require.ElementsMatch(t, []string{"apt", "go"}, modes.Names())
 	})
 
+	t.Run("detect all modes with exclusions", func(t *testing.T) {
+		req := cache.MountRequest{
+			DetectAllModes: true,
+			ExcludeModes:   []string{"apt"},
+		}
+
+		modes, err := req.EnabledModes(t.Context(), mode.Modes{
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					t.Fatal("excluded mode must not be detected")
+					return mode.DetectResult{Detected: true}, nil
+				},
+			},
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"go"}, modes.Names())
+	})
+
+	t.Run("exclusions do not affect manual modes", func(t *testing.T) {
+		req := cache.MountRequest{
+			ManualModes:  []string{"apt"},
+			ExcludeModes: []string{"apt"},
+		}
+
+		modes, err := req.EnabledModes(t.Context(), mode.Modes{
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "apt" },
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"apt"}, modes.Names())
+	})
+
 	t.Run("manual and detect combined", func(t *testing.T) {
 		req := cache.MountRequest{
 			ManualModes: []string{"apt"},
@@ -120,16 +187,22 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "golangci-lint" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "golangci-lint" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.NoError(t, err)
@@ -142,12 +215,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		_, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.Error(t, err)
@@ -160,12 +237,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.Error(t, err)
@@ -180,12 +261,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.Error(t, err)
@@ -200,12 +285,16 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: false}, nil
+				},
 			},
 		})
 		require.Error(t, err)
@@ -220,13 +309,15 @@ func TestMountRequest_EnabledModes(t *testing.T) {
 
 		modes, err := req.EnabledModes(t.Context(), mode.Modes{
 			&mode.ModeProviderMock{
-				NameFunc:   func() string { return "apt" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return true, nil },
+				NameFunc: func() string { return "apt" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
 			},
 			&mode.ModeProviderMock{
 				NameFunc: func() string { return "go" },
-				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-					return false, fmt.Errorf("detection failed")
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{}, fmt.Errorf("detection failed")
 				},
 			},
 		})
@@ -243,8 +334,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -252,6 +343,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -266,8 +361,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -302,8 +397,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -311,6 +406,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -325,8 +424,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return true, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: true}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -335,8 +434,10 @@ func TestMount(t *testing.T) {
 					},
 				},
 				&mode.ModeProviderMock{
-					NameFunc:   func() string { return "go" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+					NameFunc: func() string { return "go" },
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
+					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{}, nil
 					},
@@ -361,8 +462,8 @@ func TestMount(t *testing.T) {
 		require.NoError(t, os.MkdirAll(cachePath, 0o755))
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				if name == cachePath {
@@ -373,6 +474,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -413,8 +518,8 @@ func TestMount(t *testing.T) {
 		require.NoError(t, os.MkdirAll(cachePath1, 0o755))
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				if name == cachePath1 {
@@ -426,6 +531,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -440,8 +549,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -480,8 +589,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -489,6 +598,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -503,8 +616,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -533,8 +646,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -542,6 +655,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -555,8 +672,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "go" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -586,8 +703,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -595,6 +712,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -624,8 +745,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -633,6 +754,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -659,8 +784,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -668,6 +793,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -681,8 +810,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -708,8 +837,8 @@ func TestMount(t *testing.T) {
 		removePath := "/var/lib/apt/lists"
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			RemoveAllFunc: func(name string) error {
 				return nil
@@ -720,6 +849,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -734,8 +867,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -763,8 +896,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			RemoveAllFunc: func(name string) error {
 				return nil
@@ -775,6 +908,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -789,8 +926,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -817,8 +954,8 @@ func TestMount(t *testing.T) {
 		removePaths := []string{"/var/lib/apt/lists", "/tmp/cache", "/var/cache/apt"}
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			RemoveAllFunc: func(name string) error {
 				return nil
@@ -829,6 +966,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -843,8 +984,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -871,8 +1012,8 @@ func TestMount(t *testing.T) {
 		mountPath := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			RemoveAllFunc: func(name string) error {
 				return fmt.Errorf("remove failed")
@@ -883,9 +1024,16 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
 		}
 		m := cache.Mounter{
 			DestructiveMode: true,
@@ -894,8 +1042,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -907,11 +1055,17 @@ func TestMount(t *testing.T) {
 			},
 		}
 
-		_, err := m.Mount(t.Context(), cache.MountRequest{
+		result, err := m.Mount(t.Context(), cache.MountRequest{
 			ManualModes: []string{"apt"},
 		})
 		require.Error(t, err)
 		require.ErrorContains(t, err, "remove failed")
+
+		// The response still tells which step failed.
+		require.Len(t, result.Output.Mounts, 1)
+		require.Len(t, result.Output.Errors, 1)
+		require.Equal(t, "/var/lib/apt/lists", result.Output.Errors[0].Path)
+		require.False(t, result.Output.Errors[0].Recoverable)
 	})
 
 	t.Run("multiple modes combined", func(t *testing.T) {
@@ -920,8 +1074,8 @@ func TestMount(t *testing.T) {
 		mountPath2 := t.TempDir()
 
 		exec := &cache.ExecutorMock{
-			MountFunc: func(ctx context.Context, from, to string) error {
-				return nil
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
 			},
 			StatFunc: func(name string) (os.FileInfo, error) {
 				return nil, os.ErrNotExist
@@ -929,6 +1083,10 @@ func TestMount(t *testing.T) {
 			MkdirAllFunc: func(path string, perm os.FileMode) error {
 				return nil
 			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 				return nil
 			},
@@ -943,8 +1101,8 @@ func TestMount(t *testing.T) {
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -955,8 +1113,8 @@ func TestMount(t *testing.T) {
 				},
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "go" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -986,8 +1144,8 @@ func TestMount(t *testing.T) {
 			DestructiveMode: true,
 			CacheRoot:       cacheRoot,
 			Exec: &cache.ExecutorMock{
-				MountFunc: func(ctx context.Context, from, to string) error {
-					return fmt.Errorf("mount failed")
+				MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+					return "", fmt.Errorf("mount failed")
 				},
 				StatFunc: func(name string) (os.FileInfo, error) {
 					return nil, os.ErrNotExist
@@ -995,15 +1153,22 @@ func TestMount(t *testing.T) {
 				MkdirAllFunc: func(path string, perm os.FileMode) error {
 					return nil
 				},
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+				LockFunc: noLock,
 				WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
 					return nil
 				},
+				DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+					return cache.DiskUsage{}, errors.New("statfs failed")
+				},
 			},
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
 					NameFunc: func() string { return "apt" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) {
-						return false, nil
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
 					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{
@@ -1014,11 +1179,22 @@ func TestMount(t *testing.T) {
 			},
 		}
 
-		_, err := m.Mount(t.Context(), cache.MountRequest{
+		result, err := m.Mount(t.Context(), cache.MountRequest{
 			ManualModes: []string{"apt"},
 		})
 		require.Error(t, err)
 		require.ErrorContains(t, err, "mount failed")
+
+		// The response still tells which step failed.
+		require.Equal(t, []cache.MountIssue{{
+			Mode:    "apt",
+			Path:    mountPath,
+			Message: result.Output.Errors[0].Message,
+		}}, result.Output.Errors)
+		require.Contains(t, result.Output.Errors[0].Message, "mount failed")
+		require.Len(t, result.Output.Warnings, 1)
+		require.True(t, result.Output.Warnings[0].Recoverable)
+		require.Contains(t, result.Output.Warnings[0].Message, "statfs failed")
 	})
 
 	t.Run("tilde path expansion", func(t *testing.T) {
@@ -1027,9 +1203,15 @@ func TestMount(t *testing.T) {
 
 		cacheRoot := t.TempDir()
 		exec := &cache.ExecutorMock{
-			MountFunc:     func(ctx context.Context, from, to string) error { return nil },
-			StatFunc:      func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist },
-			MkdirAllFunc:  func(path string, perm os.FileMode) error { return nil },
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
+			},
+			StatFunc:     func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist },
+			MkdirAllFunc: func(path string, perm os.FileMode) error { return nil },
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc:      noLock,
 			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error { return nil },
 			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
 				return cache.DiskUsage{}, fmt.Errorf("not implemented")
@@ -1042,8 +1224,10 @@ func TestMount(t *testing.T) {
 			Exec:            exec,
 			Modes: mode.Modes{
 				&mode.ModeProviderMock{
-					NameFunc:   func() string { return "test" },
-					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (bool, error) { return false, nil },
+					NameFunc: func() string { return "test" },
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
+					},
 					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
 						return mode.PlanResult{MountPaths: []string{"~/.cache/test"}}, nil
 					},
@@ -1059,6 +1243,101 @@ func TestMount(t *testing.T) {
 		require.Equal(t, filepath.Join(cacheRoot, cache.RootSubpath(homeDir), ".cache/test"), mountCalls[0].From)
 		require.Equal(t, filepath.Join(homeDir, ".cache/test"), mountCalls[0].To)
 	})
+
+	t.Run("records mounts in metadata", func(t *testing.T) {
+		cacheRoot := t.TempDir()
+		mountPath := t.TempDir()
+		previousPath := filepath.Join(t.TempDir(), "previous")
+
+		previous := fmt.Sprintf(`{"version":1,"userRequest":{%q:{"source":"/cache/previous"}}}`, previousPath)
+		exec := &cache.ExecutorMock{
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
+			},
+			StatFunc:     func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist },
+			MkdirAllFunc: func(path string, perm os.FileMode) error { return nil },
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return []byte(previous), nil
+			},
+			LockFunc:      noLock,
+			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error { return nil },
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, fmt.Errorf("not implemented")
+			},
+		}
+
+		m := cache.Mounter{
+			DestructiveMode: true,
+			CacheRoot:       cacheRoot,
+			Exec:            exec,
+			ToolVersion:     "v1.2.3",
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "apt" },
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
+					},
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{mountPath}}, nil
+					},
+				},
+			},
+		}
+
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"apt"}})
+		require.NoError(t, err)
+
+		require.Len(t, exec.LockCalls(), 1)
+		require.Equal(t, filepath.Join(cacheRoot, ".ns", "cache-metadata.lock"), exec.LockCalls()[0].Path)
+
+		writeCalls := exec.WriteFileCalls()
+		require.Len(t, writeCalls, 1)
+		require.Equal(t, filepath.Join(cacheRoot, cache.MetadataPath), writeCalls[0].Name)
+
+		// Version 1 metadata is upgraded in place.
+		var metadata cache.CacheMetadata
+		require.NoError(t, json.Unmarshal(writeCalls[0].Data, &metadata))
+		require.Equal(t, 2, metadata.Version)
+		require.Equal(t, "v1.2.3", metadata.ToolVersion)
+		require.NotEmpty(t, metadata.UpdatedAt)
+		require.Len(t, metadata.UserRequest, 2)
+		require.Equal(t, "/cache/previous", metadata.UserRequest[previousPath].Source)
+
+		entry := metadata.UserRequest[mountPath]
+		require.Equal(t, "apt", *entry.CacheFramework)
+		require.Equal(t, []string{mountPath}, entry.MountTarget)
+		require.Equal(t, filepath.Join(cacheRoot, cache.RootSubpath(mountPath)), entry.Source)
+		require.NotEmpty(t, entry.MountedAt)
+	})
+
+	t.Run("dry run does not record metadata", func(t *testing.T) {
+		exec := &cache.ExecutorMock{
+			StatFunc: func(name string) (os.FileInfo, error) { return nil, os.ErrNotExist },
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, fmt.Errorf("not implemented")
+			},
+		}
+
+		m := cache.Mounter{
+			CacheRoot: t.TempDir(),
+			Exec:      exec,
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "apt" },
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: false}, nil
+					},
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{t.TempDir()}}, nil
+					},
+				},
+			},
+		}
+
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"apt"}})
+		require.NoError(t, err)
+		require.Empty(t, exec.WriteFileCalls())
+	})
 }
 
 func filterMounts(mounts []cache.MountResult) []cache.MountResult {
@@ -1153,6 +1432,368 @@ func TestMountTargetExists(t *testing.T) {
 	})
 }
 
+func TestMounter_ModeStrategies(t *testing.T) {
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			return strategy, nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Exec:            exec,
+		Strategy:        cache.MountSymlink,
+		ModeStrategies:  map[string]cache.MountStrategy{"toolchain": cache.MountOverlay},
+		Modes: mode.Modes{&mode.ModeProviderMock{
+			NameFunc: func() string { return "toolchain" },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{MountPaths: []string{"/opt/toolchain"}}, nil
+			},
+		}},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"toolchain"}, ManualPaths: []string{"/work/out"}})
+	require.NoError(t, err)
+
+	strategies := map[string]cache.MountStrategy{}
+	for _, mount := range result.Output.Mounts {
+		strategies[mount.MountPath] = mount.Strategy
+	}
+	require.Equal(t, map[string]cache.MountStrategy{
+		"/opt/toolchain": cache.MountOverlay,
+		"/work/out":      cache.MountSymlink,
+	}, strategies)
+	// Symlinks that were asked for are not a fallback.
+	require.Empty(t, result.Output.Warnings)
+
+	t.Run("warns when auto falls back to a symlink", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("only linux bind mounts")
+		}
+		exec.MountFunc = func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			return cache.MountSymlink, nil
+		}
+		m.Strategy, m.ModeStrategies = "", nil
+
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"/work/out"}})
+		require.NoError(t, err)
+		require.Len(t, result.Output.Warnings, 1)
+		require.Equal(t, "/work/out", result.Output.Warnings[0].Path)
+		require.Contains(t, result.Output.Warnings[0].Message, "bind mounts are not permitted")
+	})
+}
+
+func TestMounter_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// Later paths finish first.
+			i, _ := strconv.Atoi(filepath.Base(to))
+			time.Sleep(time.Duration(12-i) * time.Millisecond)
+			return cache.MountBind, nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	var paths []string
+	for i := range 12 {
+		paths = append(paths, fmt.Sprintf("/work/%d", i))
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Exec:            exec,
+		Concurrency:     4,
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: paths})
+	require.NoError(t, err)
+	require.Equal(t, int32(4), maxInFlight.Load())
+
+	var mounted []string
+	for _, mount := range result.Output.Mounts {
+		mounted = append(mounted, mount.MountPath)
+	}
+	require.Equal(t, paths, mounted)
+}
+
+// orderedMode mounts a single path and declares ordering and conflicts.
+type orderedMode struct {
+	mode.ModeProviderMock
+	after, conflicts []string
+}
+
+func (m *orderedMode) After() []string         { return m.after }
+func (m *orderedMode) ConflictsWith() []string { return m.conflicts }
+
+func newOrderedMode(name, path string, after, conflicts []string) *orderedMode {
+	return &orderedMode{
+		ModeProviderMock: mode.ModeProviderMock{
+			NameFunc: func() string { return name },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{MountPaths: []string{path}}, nil
+			},
+		},
+		after:     after,
+		conflicts: conflicts,
+	}
+}
+
+func TestMounter_ModeOrder(t *testing.T) {
+	var mu sync.Mutex
+	var mounted []string
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			if to == "/work/first" {
+				// Without ordering, the second mount would finish first.
+				time.Sleep(20 * time.Millisecond)
+			}
+			mu.Lock()
+			mounted = append(mounted, to)
+			mu.Unlock()
+			return cache.MountBind, nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Exec:            exec,
+		Concurrency:     4,
+		Modes: mode.Modes{
+			newOrderedMode("second", "/work/second", []string{"first"}, nil),
+			newOrderedMode("first", "/work/first", nil, nil),
+			newOrderedMode("legacy", "/work/legacy", nil, []string{"first"}),
+		},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"second", "first", "legacy"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/work/first", "/work/second"}, mounted)
+	require.Equal(t, []string{"first", "second"}, result.Output.ModeOrder)
+	require.Equal(t, map[string]string{"legacy": "first"}, result.Output.SkippedModes)
+}
+
+func TestMounter_DedupPaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var mounted []string
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			mu.Lock()
+			mounted = append(mounted, to)
+			mu.Unlock()
+			return cache.MountBind, nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Exec:            exec,
+		Modes: mode.Modes{
+			newOrderedMode("lint", "~/.cache/golangci-lint", nil, nil),
+			newOrderedMode("tools", "~/.cache", nil, nil),
+		},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{
+		ManualModes: []string{"lint", "tools"},
+		ManualPaths: []string{filepath.Join(home, ".cache"), "/opt/a", "/opt/a/../a/b"},
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{filepath.Join(home, ".cache"), "/opt/a"}, mounted)
+	require.Equal(t, []cache.DedupedPath{
+		{Mode: "lint", Path: "~/.cache/golangci-lint", CoveredByMode: "tools", CoveredBy: "~/.cache"},
+		{Path: filepath.Join(home, ".cache"), CoveredByMode: "tools", CoveredBy: "~/.cache"},
+		{Path: "/opt/a/../a/b", CoveredBy: "/opt/a"},
+	}, result.Output.Deduplicated)
+}
+
+func TestMounter_ReadOnly(t *testing.T) {
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			return cache.MountBind, nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Exec:            exec,
+		Modes:           mode.Modes{newOrderedMode("toolchain", "/work/toolchain", nil, nil)},
+		ReadOnly:        []string{"toolchain", "/work/mirror/../mirror"},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{
+		ManualModes: []string{"toolchain"},
+		ManualPaths: []string{"/work/mirror", "/work/build"},
+	})
+	require.NoError(t, err)
+
+	readOnly := map[string]bool{}
+	for _, call := range exec.MountCalls() {
+		readOnly[call.To] = call.ReadOnly
+	}
+	require.Equal(t, map[string]bool{"/work/toolchain": true, "/work/mirror": true, "/work/build": false}, readOnly)
+
+	for _, mount := range result.Output.Mounts {
+		require.Equal(t, readOnly[mount.MountPath], mount.ReadOnly, mount.MountPath)
+	}
+}
+
+func TestMounter_Retries(t *testing.T) {
+	setup := func(failures int) (cache.Mounter, *cache.ExecutorMock) {
+		exec := &cache.ExecutorMock{
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				if failures > 0 {
+					failures--
+					return "", errors.New("device busy")
+				}
+				return cache.MountBind, nil
+			},
+			StatFunc: func(name string) (os.FileInfo, error) {
+				return nil, os.ErrNotExist
+			},
+			MkdirAllFunc: func(path string, perm os.FileMode) error {
+				return nil
+			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
+			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+				return nil
+			},
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+		}
+		return cache.Mounter{DestructiveMode: true, CacheRoot: "/cache", Exec: exec, Retries: 1}, exec
+	}
+
+	t.Run("retries failed mounts", func(t *testing.T) {
+		m, exec := setup(1)
+
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"/work/out"}})
+		require.NoError(t, err)
+		require.Len(t, exec.MountCalls(), 2)
+	})
+
+	t.Run("gives up after the last retry", func(t *testing.T) {
+		m, exec := setup(2)
+
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"/work/out"}})
+		require.ErrorContains(t, err, "device busy")
+		require.Len(t, exec.MountCalls(), 2)
+	})
+}
+
+func TestDefaultExecutor_DiskUsage(t *testing.T) {
+	usage, err := cache.DefaultExecutor{}.DiskUsage(t.Context(), t.TempDir())
+	require.NoError(t, err)
+
+	require.NotZero(t, usage.TotalBytes)
+	require.LessOrEqual(t, usage.UsedBytes+usage.AvailableBytes, usage.TotalBytes)
+	require.InDelta(t, 100*float64(usage.UsedBytes)/float64(usage.UsedBytes+usage.AvailableBytes), usage.UsagePercent, 0.05)
+	require.NotEmpty(t, usage.Total)
+	require.NotEmpty(t, usage.Available)
+
+	_, err = cache.DefaultExecutor{}.DiskUsage(t.Context(), filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
 // mountCachePath dry-run mounts a single manual path and returns the computed
 // CachePath, exercising the real mountPath composition.
 func mountCachePath(t *testing.T, cacheRoot, path string) string {
@@ -1177,3 +1818,43 @@ func mountCachePath(t *testing.T, cacheRoot, path string) string {
 
 	return res.Output.Mounts[0].CachePath
 }
+
+// noLock stands in for Executor.Lock in tests that do not run concurrently.
+func noLock(context.Context, string) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func TestDefaultExecutor_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	e := cache.DefaultExecutor{}
+
+	unlock, err := e.Lock(t.Context(), path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err = e.Lock(ctx, path)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, unlock())
+	unlock, err = e.Lock(t.Context(), path)
+	require.NoError(t, err)
+	require.NoError(t, unlock())
+}
+
+func TestDefaultExecutor_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metadata.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+	require.NoError(t, cache.DefaultExecutor{}.WriteFile(path, []byte("new"), 0o644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(data))
+
+	// The temporary file was renamed into place.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
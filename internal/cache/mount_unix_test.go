@@ -3,11 +3,17 @@
 package cache_test
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
 )
 
 // TestMount_CacheLayoutUnix guards against regressions: on Unix the cache path
@@ -41,3 +47,146 @@ func TestMount_CacheLayoutUnix(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultExecutor_Rootless(t *testing.T) {
+	if _, err := exec.LookPath("bindfs"); err == nil {
+		t.Skip("bindfs would leave a FUSE mount behind")
+	}
+
+	e := cache.DefaultExecutor{Rootless: true}
+	from := filepath.Join(t.TempDir(), "cache")
+	to := filepath.Join(t.TempDir(), "work", "target")
+
+	// An existing file at the mount path is replaced.
+	require.NoError(t, os.MkdirAll(filepath.Dir(to), 0o755))
+	require.NoError(t, os.WriteFile(to, []byte("stale"), 0o644))
+
+	strategy, err := e.Mount(t.Context(), from, to, cache.MountAuto, false)
+	require.NoError(t, err)
+	require.Equal(t, cache.MountSymlink, strategy)
+	require.NoError(t, os.WriteFile(filepath.Join(to, "a"), []byte("hello"), 0o644))
+
+	data, err := os.ReadFile(filepath.Join(from, "a"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	copied := filepath.Join(t.TempDir(), "copy")
+	require.NoError(t, e.CopyDir(t.Context(), from, copied))
+	require.FileExists(t, filepath.Join(copied, "a"))
+
+	moved := filepath.Join(t.TempDir(), "moved")
+	require.NoError(t, e.Rename(copied, moved))
+	require.NoError(t, e.RemoveAll(moved))
+	require.NoDirExists(t, moved)
+
+	// A copy is independent of the cache path.
+	copyTo := filepath.Join(t.TempDir(), "work", "copy")
+	strategy, err = e.Mount(t.Context(), from, copyTo, cache.MountCopy, false)
+	require.NoError(t, err)
+	require.Equal(t, cache.MountCopy, strategy)
+	require.FileExists(t, filepath.Join(copyTo, "a"))
+	require.NoError(t, os.WriteFile(filepath.Join(copyTo, "b"), []byte("world"), 0o644))
+	require.NoFileExists(t, filepath.Join(from, "b"))
+}
+
+func TestMounter_PreserveOwnership(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "archives")
+	require.NoError(t, os.Mkdir(target, 0o700))
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	st := info.Sys().(*syscall.Stat_t)
+	want := cache.FileOwner{UID: int(st.Uid), GID: int(st.Gid), Mode: 0o700}
+
+	setup := func(ownership cache.OwnershipMode) (cache.Mounter, *cache.ExecutorMock) {
+		exec := &cache.ExecutorMock{
+			ChownFunc: func(ctx context.Context, path string, owner cache.FileOwner, recursive bool) error {
+				return nil
+			},
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
+			},
+			StatFunc: func(name string) (os.FileInfo, error) {
+				if name == target {
+					return info, nil
+				}
+				return nil, os.ErrNotExist
+			},
+			MkdirAllFunc: func(path string, perm os.FileMode) error {
+				return nil
+			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
+			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+				return nil
+			},
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+		}
+		return cache.Mounter{DestructiveMode: true, CacheRoot: "/cache", Exec: exec, PreserveOwnership: ownership}, exec
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		m, exec := setup("")
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{target}})
+		require.NoError(t, err)
+		require.Empty(t, exec.ChownCalls())
+		require.Nil(t, result.Output.Mounts[0].Owner)
+	})
+
+	t.Run("applies owner of mount path to cache path", func(t *testing.T) {
+		m, exec := setup(cache.OwnershipRecursive)
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{target, "/missing"}})
+		require.NoError(t, err)
+
+		require.Len(t, exec.ChownCalls(), 1)
+		call := exec.ChownCalls()[0]
+		require.Equal(t, filepath.Join("/cache", target), call.Path)
+		require.Equal(t, want, call.Owner)
+		require.True(t, call.Recursive)
+		require.Equal(t, &want, result.Output.Mounts[0].Owner)
+		require.Nil(t, result.Output.Mounts[1].Owner)
+	})
+
+	t.Run("chown failure skips the path", func(t *testing.T) {
+		m, exec := setup(cache.OwnershipTop)
+		exec.ChownFunc = func(ctx context.Context, path string, owner cache.FileOwner, recursive bool) error {
+			return errors.New("operation not permitted")
+		}
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{target}})
+		require.ErrorContains(t, err, "operation not permitted")
+		require.Empty(t, exec.MountCalls())
+		require.Len(t, result.Output.Errors, 1)
+		require.Contains(t, result.Output.Errors[0].Message, "preserving ownership")
+	})
+}
+
+func TestDefaultExecutor_ReadOnly(t *testing.T) {
+	from := t.TempDir()
+	to := filepath.Join(t.TempDir(), "target")
+
+	for _, strategy := range []cache.MountStrategy{cache.MountSymlink, cache.MountCopy} {
+		_, err := cache.DefaultExecutor{Rootless: true}.Mount(t.Context(), from, to, strategy, true)
+		require.ErrorContains(t, err, "cannot be read-only")
+	}
+
+	_, err := os.Lstat(to)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestDefaultExecutor_Shell(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr, err := cache.DefaultExecutor{}.Shell(t.Context(), dir, `pwd -P; echo "$HOOK_VAR" >&2`, []string{"HOOK_VAR=value"})
+	require.NoError(t, err)
+	wd, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+	require.Equal(t, wd+"\n", string(stdout))
+	require.Equal(t, "value\n", string(stderr))
+
+	_, _, err = cache.DefaultExecutor{}.Shell(t.Context(), dir, "exit 3", nil)
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 3, exitErr.ExitCode())
+}
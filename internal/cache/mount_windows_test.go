@@ -49,8 +49,9 @@ func TestMount_RelativeForwardSlashTargetWindows(t *testing.T) {
 	// how the cache action runs from the workspace root.
 	t.Chdir(t.TempDir())
 
-	err := cache.DefaultExecutor{}.Mount(t.Context(), from, "./target")
+	strategy, err := cache.DefaultExecutor{}.Mount(t.Context(), from, "./target", cache.MountAuto, false)
 	require.NoError(t, err)
+	require.Equal(t, cache.MountSymlink, strategy)
 
 	data, err := os.ReadFile(filepath.Join("target", "marker"))
 	require.NoError(t, err)
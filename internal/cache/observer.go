@@ -0,0 +1,49 @@
+package cache
+
+import "github.com/namespacelabs/spacectl/internal/cache/mode"
+
+// Observer is notified as Mount, Apply and Prune progress, so that callers
+// can report progress before the response is complete. Mounts run
+// concurrently, so methods may be called from several goroutines at once,
+// and should return quickly. Embed NopObserver to implement only some of
+// the methods.
+type Observer interface {
+	// OnDetectStart is called before modes are detected in a working
+	// directory, which is empty for the current one.
+	OnDetectStart(workDir string, modes []string)
+	// OnDetectResult is called with the result of detecting a mode.
+	OnDetectResult(workDir, modeName string, result mode.DetectResult)
+	// OnMountStart is called before a path, or a cache directory of a mode,
+	// is mounted.
+	OnMountStart(modeName, path string)
+	// OnMountResult is called once a path is mounted, or failed to mount.
+	OnMountResult(modeName, path string, result MountResult, err error)
+	// OnRemove is called once a path that a mode wants removed is removed,
+	// or failed to be.
+	OnRemove(path string, err error)
+	// OnComplete is called with the outcome of Mount or Apply.
+	OnComplete(resp MountResponse, err error)
+	// OnPruneStart is called before Prune deletes a cache entry.
+	OnPruneStart(entry PruneEntry)
+	// OnPruneResult is called once a cache entry is deleted, or failed to be.
+	OnPruneResult(entry PruneEntry, err error)
+}
+
+// NopObserver ignores all events.
+type NopObserver struct{}
+
+func (NopObserver) OnDetectStart(string, []string)                   {}
+func (NopObserver) OnDetectResult(string, string, mode.DetectResult) {}
+func (NopObserver) OnMountStart(string, string)                      {}
+func (NopObserver) OnMountResult(string, string, MountResult, error) {}
+func (NopObserver) OnRemove(string, error)                           {}
+func (NopObserver) OnComplete(MountResponse, error)                  {}
+func (NopObserver) OnPruneStart(PruneEntry)                          {}
+func (NopObserver) OnPruneResult(PruneEntry, error)                  {}
+
+func (m Mounter) observer() Observer {
+	if m.Observer == nil {
+		return NopObserver{}
+	}
+	return m.Observer
+}
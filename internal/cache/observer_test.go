@@ -0,0 +1,113 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+type recordingObserver struct {
+	cache.NopObserver
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnDetectStart(workDir string, modes []string) {
+	o.record("detect start %q %v", workDir, modes)
+}
+
+func (o *recordingObserver) OnDetectResult(workDir, modeName string, result mode.DetectResult) {
+	o.record("detect %s %v", modeName, result.Detected)
+}
+
+func (o *recordingObserver) OnMountStart(modeName, path string) {
+	o.record("mount start %s %s", modeName, path)
+}
+
+func (o *recordingObserver) OnMountResult(modeName, path string, result cache.MountResult, err error) {
+	o.record("mount %s %s %v", modeName, path, err)
+}
+
+func (o *recordingObserver) OnRemove(path string, err error) {
+	o.record("remove %s %v", path, err)
+}
+
+func (o *recordingObserver) OnComplete(resp cache.MountResponse, err error) {
+	o.record("complete %d %v", len(resp.Output.Mounts), err)
+}
+
+func TestMounter_Observer(t *testing.T) {
+	observer := &recordingObserver{}
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       "/cache",
+		Observer:        observer,
+		Exec: &cache.ExecutorMock{
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
+			},
+			RemoveAllFunc: func(name string) error { return nil },
+			StatFunc: func(name string) (os.FileInfo, error) {
+				return nil, os.ErrNotExist
+			},
+			MkdirAllFunc: func(path string, perm os.FileMode) error { return nil },
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error { return nil },
+			LockFunc:      noLock,
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+		},
+		Modes: mode.Modes{
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "go" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{Detected: true}, nil
+				},
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					return mode.PlanResult{MountPaths: []string{"/go/pkg/mod"}, RemovePaths: []string{"/go/pkg/mod/cache/lock"}}, nil
+				},
+			},
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "rust" },
+				DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+					return mode.DetectResult{}, nil
+				},
+			},
+		},
+	}
+
+	_, err := m.Mount(t.Context(), cache.MountRequest{DetectAllModes: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`detect start "" [go rust]`,
+		"detect go true",
+		"detect rust false",
+		"mount start go /go/pkg/mod",
+		"mount go /go/pkg/mod <nil>",
+		"remove /go/pkg/mod/cache/lock <nil>",
+		"complete 1 <nil>",
+	}, observer.events)
+
+	t.Run("failures complete", func(t *testing.T) {
+		observer.events = nil
+		_, err := m.Mount(t.Context(), cache.MountRequest{})
+		require.Error(t, err)
+		require.Equal(t, []string{"complete 0 " + err.Error()}, observer.events)
+	})
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// OwnershipMode is whether cache paths take on the ownership and mode of the
+// mount paths they replace. Caches are otherwise owned by the current user,
+// which breaks tools that expect root-owned directories, like apt.
+type OwnershipMode string
+
+const (
+	// OwnershipNone leaves cache paths owned by the current user.
+	OwnershipNone OwnershipMode = "none"
+	// OwnershipTop applies the owner and mode of the mount path to the top
+	// of the cache path.
+	OwnershipTop OwnershipMode = "top"
+	// OwnershipRecursive also applies the owner to everything beneath the
+	// cache path. The mode is only applied to the top.
+	OwnershipRecursive OwnershipMode = "recursive"
+)
+
+var ownershipModes = []OwnershipMode{OwnershipNone, OwnershipTop, OwnershipRecursive}
+
+// ParseOwnershipMode validates an ownership mode name. An empty name is
+// OwnershipNone.
+func ParseOwnershipMode(name string) (OwnershipMode, error) {
+	if name == "" {
+		return OwnershipNone, nil
+	}
+	if o := OwnershipMode(name); slices.Contains(ownershipModes, o) {
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown ownership mode %q, expected one of %v", name, ownershipModes)
+}
+
+// FileOwner is the owner and permissions of a path.
+type FileOwner struct {
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+	Mode os.FileMode `json:"mode"`
+}
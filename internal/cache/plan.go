@@ -0,0 +1,421 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+	"github.com/namespacelabs/spacectl/internal/tracing"
+)
+
+// PlanVersion is the version of the plans written by Plan. Apply refuses
+// plans of other versions.
+const PlanVersion = 1
+
+// Plan is what Mount would do, resolved without changing anything, so that
+// it can be reviewed and applied later, possibly with other privileges.
+type Plan struct {
+	Version   int    `json:"version"`
+	CacheRoot string `json:"cache_root"`
+	// ProjectDir is the absolute directory that relative mount paths are
+	// resolved in when applied.
+	ProjectDir   string             `json:"project_dir,omitzero"`
+	Scope        string             `json:"scope,omitzero"`
+	DefaultScope string             `json:"default_scope,omitzero"`
+	Input        MountResponseInput `json:"input,omitzero"`
+	ModeOrder    []string           `json:"mode_order,omitzero"`    // modes in the order they are mounted
+	SkippedModes map[string]string  `json:"skipped_modes,omitzero"` // mode to the enabled mode it conflicts with
+	AddEnvs      map[string]string  `json:"add_envs,omitzero"`
+	Mounts       []PlannedMount     `json:"mounts,omitzero"`
+	Deduplicated []DedupedPath      `json:"deduplicated,omitzero"` // paths covered by another mount
+	RemovePaths  []string           `json:"remove_paths,omitzero"`
+	// Warnings are problems that modes worked around while planning.
+	Warnings []MountIssue `json:"warnings,omitzero"`
+	// Checks maps modes to the binaries, paths and commands they checked
+	// while planning, for review.
+	Checks map[string][]mode.DetectCheck `json:"checks,omitzero"`
+	// Hooks are the commands run before and after mounting, in order.
+	Hooks []PlannedHook `json:"hooks,omitzero"`
+	// Timings are how long modes took to detect and plan, by mode.
+	Timings []ModeTiming `json:"timings,omitzero"`
+}
+
+// ModeTiming is how long a mode took to detect and plan, summed over the
+// working directories it was planned in. Modes that are enabled without
+// detection take no time to detect.
+type ModeTiming struct {
+	Mode     string `json:"mode"`
+	DetectMs int64  `json:"detect_ms,omitzero"`
+	PlanMs   int64  `json:"plan_ms,omitzero"`
+}
+
+// addTiming adds to the timing of a mode.
+func (p *Plan) addTiming(modeName string, detect, plan time.Duration) {
+	i := slices.IndexFunc(p.Timings, func(t ModeTiming) bool { return t.Mode == modeName })
+	if i < 0 {
+		p.Timings = append(p.Timings, ModeTiming{Mode: modeName})
+		i = len(p.Timings) - 1
+	}
+	p.Timings[i].DetectMs += detect.Milliseconds()
+	p.Timings[i].PlanMs += plan.Milliseconds()
+}
+
+// PlannedMount is a path to mount over with its cache, or a cache directory
+// to create.
+type PlannedMount struct {
+	Mode string `json:"mode,omitzero"`
+	// Path is the mount path, or for cache directories the directory
+	// relative to the cache root.
+	Path string `json:"path"`
+	// CacheDir is set for cache directories, which mount nothing.
+	CacheDir bool `json:"cache_dir,omitzero"`
+	// CachePath is where the cache is kept, for review. Apply computes it
+	// again from the other fields.
+	CachePath string `json:"cache_path,omitzero"`
+	Key       string `json:"key,omitzero"`
+	// After lists the modes whose paths are mounted before this one.
+	After    []string      `json:"after,omitzero"`
+	Exclude  []string      `json:"exclude,omitzero"`
+	Strategy MountStrategy `json:"strategy,omitzero"`
+	ReadOnly bool          `json:"read_only,omitzero"`
+	// Assumed is set when the mode was planned offline, from the default
+	// paths of its tool.
+	Assumed bool `json:"assumed,omitzero"`
+	// Source is where the path came from, for review. It is unset for
+	// cache directories.
+	Source mode.Source `json:"source,omitzero"`
+}
+
+// Plan resolves what Mount would do for req: it detects and plans modes and
+// computes keys, but neither mounts nor removes anything.
+func (m Mounter) Plan(ctx context.Context, req MountRequest) (Plan, error) {
+	for _, patterns := range req.Exclude {
+		if err := ValidateExcludes(patterns); err != nil {
+			return Plan{}, err
+		}
+	}
+	return m.plan(ctx, req)
+}
+
+// Apply executes a plan written by Plan. The cache root and scopes are taken
+// from the plan, while how paths are mounted, e.g. DestructiveMode, Retries
+// and the safety checks, still follow the mounter.
+func (m Mounter) Apply(ctx context.Context, plan Plan) (MountResponse, error) {
+	start := time.Now()
+	resp, err := m.applyPlan(ctx, plan)
+	resp.Output.DurationMs = time.Since(start).Milliseconds()
+	m.observer().OnComplete(resp, err)
+	return resp, err
+}
+
+func (m Mounter) applyPlan(ctx context.Context, plan Plan) (MountResponse, error) {
+	if err := plan.Validate(); err != nil {
+		return MountResponse{}, err
+	}
+
+	m.CacheRoot, m.Scope, m.DefaultScope = plan.CacheRoot, plan.Scope, plan.DefaultScope
+
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return MountResponse{}, err
+	}
+	defer unlock()
+
+	return m.apply(ctx, plan)
+}
+
+// Validate checks that a plan read from a file can be applied.
+func (p Plan) Validate() error {
+	if p.Version != PlanVersion {
+		return fmt.Errorf("plan version %d is not supported, expected %d", p.Version, PlanVersion)
+	}
+	if p.CacheRoot == "" {
+		return errors.New("plan has no cache root")
+	}
+	for _, mount := range p.Mounts {
+		if err := ValidateExcludes(mount.Exclude); err != nil {
+			return err
+		}
+	}
+	return p.validateOrder()
+}
+
+// validateOrder checks that the modes that mounts come after are modes of
+// the plan and have an order, as mounts would otherwise wait for each other
+// forever.
+func (p Plan) validateOrder() error {
+	known := map[string]bool{}
+	for _, name := range p.ModeOrder {
+		known[name] = true
+	}
+	for _, mount := range p.Mounts {
+		if mount.Mode != "" {
+			known[mount.Mode] = true
+		}
+	}
+
+	after := map[string][]string{}
+	for _, mount := range p.Mounts {
+		for _, before := range mount.After {
+			switch {
+			case before == mount.Mode:
+				return fmt.Errorf("mount %q comes after its own mode %q", mount.Path, before)
+			case !known[before]:
+				return fmt.Errorf("mount %q comes after unknown mode %q", mount.Path, before)
+			case mount.Mode == "":
+				return fmt.Errorf("mount %q comes after mode %q but has no mode", mount.Path, before)
+			}
+			if !slices.Contains(after[mount.Mode], before) {
+				after[mount.Mode] = append(after[mount.Mode], before)
+			}
+		}
+	}
+
+	if _, err := mode.SortAfter(slices.Sorted(maps.Keys(known)), after); err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+	return nil
+}
+
+func (m Mounter) plan(ctx context.Context, req MountRequest) (_ Plan, err error) {
+	ctx, span := tracing.Start(ctx, "plan")
+	defer func() { span.End(err) }()
+
+	if err := validateModeConfigs(req.ModeConfig, m.Modes); err != nil {
+		return Plan{}, err
+	}
+	if err := validateHooks(req.Hooks, m.Modes); err != nil {
+		return Plan{}, err
+	}
+
+	projectDir, err := m.projectDir()
+	if err != nil {
+		return Plan{}, fmt.Errorf("resolving project dir: %w", err)
+	}
+
+	plan := Plan{
+		ProjectDir:   projectDir,
+		Version:      PlanVersion,
+		CacheRoot:    m.CacheRoot,
+		Scope:        m.Scope,
+		DefaultScope: m.DefaultScope,
+		Input:        MountResponseInput{Modes: []string{}},
+	}
+
+	dirs, err := req.enabledModesByDir(ctx, m.Modes, m.modeExec(), m.observer())
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, dir := range dirs {
+		for _, name := range slices.Sorted(maps.Keys(dir.Detect)) {
+			plan.addTiming(name, dir.Detect[name], 0)
+		}
+		if err := m.planModes(ctx, dir, req, &plan); err != nil {
+			return Plan{}, err
+		}
+	}
+	slices.Sort(plan.Input.Modes)
+	slices.SortFunc(plan.Timings, func(a, b ModeTiming) int { return strings.Compare(a.Mode, b.Mode) })
+	m.planPaths(req.ManualPaths, req.Exclude[AllModes], &plan)
+	dedupMounts(&plan)
+	plan.Hooks = planHooks(req.Hooks, plan.ModeOrder)
+
+	return plan, nil
+}
+
+// planModes plans the modes enabled in a working directory: the paths they
+// mount, the cache directories they use and the paths they want removed.
+// Modes enabled in several directories are ordered where they are first
+// planned.
+func (m Mounter) planModes(ctx context.Context, dir workDirModes, req MountRequest, plan *Plan) error {
+	for _, name := range dir.Modes.Names() {
+		if !slices.Contains(plan.Input.Modes, name) {
+			plan.Input.Modes = append(plan.Input.Modes, name)
+		}
+	}
+
+	// Planning against the scope's root also scopes the cache directories
+	// that providers point their tools at.
+	root := m.scopeRoot(m.Scope)
+	modesPlan, err := dir.Modes.Plan(ctx, mode.PlanRequest{
+		CacheRoot: root,
+		Exec:      m.modeExec(),
+		WorkDir:   dir.Dir,
+		ModeEnv:   modeEnv(req.ModeConfig),
+		Offline:   req.Offline,
+		Static:    req.Static,
+	})
+	if err != nil {
+		if dir.Dir != "" {
+			return fmt.Errorf("in %s: %w", dir.Dir, err)
+		}
+		return err
+	}
+
+	for _, name := range modesPlan.Order {
+		if !slices.Contains(plan.ModeOrder, name) {
+			plan.ModeOrder = append(plan.ModeOrder, name)
+		}
+	}
+	for _, skipped := range slices.Sorted(maps.Keys(modesPlan.Skipped)) {
+		if _, ok := plan.SkippedModes[skipped]; ok {
+			continue
+		}
+		if plan.SkippedModes == nil {
+			plan.SkippedModes = make(map[string]string)
+		}
+		plan.SkippedModes[skipped] = modesPlan.Skipped[skipped]
+		slog.Info("skipping mode covered by another mode", slog.String("mode", skipped), slog.String("by", modesPlan.Skipped[skipped]))
+	}
+
+	for _, modeName := range modesPlan.Order {
+		plan.addTiming(modeName, 0, modesPlan.Results[modeName].Duration)
+		p := req.ModeConfig[modeName].apply(modesPlan.Results[modeName])
+		after := modesPlan.After[modeName]
+		excludes := slices.Concat(req.Exclude[AllModes], req.Exclude[modeName])
+
+		for k, v := range p.AddEnvs {
+			if plan.AddEnvs == nil {
+				plan.AddEnvs = make(map[string]string)
+			}
+			plan.AddEnvs[k] = v
+		}
+
+		for _, subdir := range p.CacheDirs {
+			plan.addMount(PlannedMount{
+				Mode:      modeName,
+				Path:      subdir,
+				CacheDir:  true,
+				CachePath: filepath.Join(root, subdir),
+				After:     after,
+				Exclude:   excludes,
+				Assumed:   p.Assumed,
+			})
+		}
+
+		var key string
+		if m.Keyed {
+			if key, _, err = ComputeKey(m.Exec, p.KeyFiles); err != nil {
+				return fmt.Errorf("computing key of mode %q: %w", modeName, err)
+			}
+		}
+
+		for _, path := range p.MountPaths {
+			mount := m.plannedPath(root, modeName, key, path, after, excludes)
+			mount.Assumed = p.Assumed
+			mount.Source = p.Sources[path]
+			plan.addMount(mount)
+		}
+
+		for _, path := range p.RemovePaths {
+			if !slices.Contains(plan.RemovePaths, path) {
+				plan.RemovePaths = append(plan.RemovePaths, path)
+			}
+		}
+
+		for _, warning := range p.Warnings {
+			slog.Debug("mode planned with a warning", slog.String("mode", modeName), slog.String("warning", warning))
+			plan.Warnings = append(plan.Warnings, MountIssue{Mode: modeName, Message: warning, Recoverable: true})
+		}
+		if len(p.Checks) > 0 {
+			if plan.Checks == nil {
+				plan.Checks = make(map[string][]mode.DetectCheck)
+			}
+			plan.Checks[modeName] = append(plan.Checks[modeName], p.Checks...)
+		}
+	}
+
+	return nil
+}
+
+// addMount adds a mount to the plan, unless a mode planned the same path in
+// another working directory already, e.g. a shared module cache.
+func (p *Plan) addMount(mount PlannedMount) {
+	for _, other := range p.Mounts {
+		if other.Mode == mount.Mode && other.Path == mount.Path && other.CacheDir == mount.CacheDir {
+			return
+		}
+	}
+	p.Mounts = append(p.Mounts, mount)
+}
+
+func (m Mounter) planPaths(paths, excludes []string, plan *Plan) {
+	plan.Input.Paths = append(plan.Input.Paths, paths...)
+
+	root := m.scopeRoot(m.Scope)
+	for _, path := range paths {
+		mount := m.plannedPath(root, "", "", path, nil, excludes)
+		mount.Source = mode.Source{Kind: mode.SourceConfig}
+		plan.Mounts = append(plan.Mounts, mount)
+	}
+}
+
+func (m Mounter) plannedPath(root, modeName, key, path string, after, excludes []string) PlannedMount {
+	mount := PlannedMount{
+		Mode:     modeName,
+		Path:     path,
+		Key:      key,
+		After:    after,
+		Exclude:  excludes,
+		ReadOnly: m.readOnly(modeName, path),
+	}
+	if strategy := m.strategy(modeName); strategy != MountAuto {
+		mount.Strategy = strategy
+	}
+	// Paths that cannot be resolved fail when applied.
+	if resolved, err := resolveHome(path); err == nil {
+		mount.CachePath = cacheLocation(root, modeName, key, m.cacheSubpath(resolved))
+	}
+	return mount
+}
+
+// dedupMounts drops mounts whose path is the same as, or nested inside, the
+// path of another mount, which already caches it. Mounting both would put
+// the same files into two caches, and mount one cache over the other.
+func dedupMounts(plan *Plan) {
+	targets := make([]string, len(plan.Mounts))
+	for i, mount := range plan.Mounts {
+		// Cache directories mount nothing.
+		if !mount.CacheDir {
+			targets[i] = mountTarget(mount.Path)
+		}
+	}
+
+	var kept []PlannedMount
+	for i, mount := range plan.Mounts {
+		cover := -1
+		for j, other := range targets {
+			if targets[i] == "" || other == "" || i == j {
+				continue
+			}
+			// Of two identical paths, the first is kept.
+			if (other == targets[i] && j < i) || isWithin(other, targets[i]) {
+				if cover < 0 || len(other) < len(targets[cover]) {
+					cover = j
+				}
+			}
+		}
+		if cover < 0 {
+			kept = append(kept, mount)
+			continue
+		}
+
+		coveredBy := plan.Mounts[cover]
+		slog.Info("skipping path covered by another mount",
+			slog.String("mode", mount.Mode), slog.String("path", mount.Path), slog.String("covered_by", coveredBy.Path))
+		plan.Deduplicated = append(plan.Deduplicated, DedupedPath{
+			Mode:          mount.Mode,
+			Path:          mount.Path,
+			CoveredByMode: coveredBy.Mode,
+			CoveredBy:     coveredBy.Path,
+		})
+	}
+	plan.Mounts = kept
+}
@@ -0,0 +1,484 @@
+package cache_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestMounter_PlanApply(t *testing.T) {
+	var mu sync.Mutex
+	var mounted, removed []string
+	exec := &cache.ExecutorMock{
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			mounted = append(mounted, from+" -> "+to)
+			return cache.MountBind, nil
+		},
+		RemoveAllFunc: func(name string) error {
+			removed = append(removed, name)
+			return nil
+		},
+		StatFunc: func(name string) (os.FileInfo, error) {
+			return nil, os.ErrNotExist
+		},
+		MkdirAllFunc: func(path string, perm os.FileMode) error {
+			return nil
+		},
+		ReadFileFunc: func(name string) ([]byte, error) {
+			return nil, os.ErrNotExist
+		},
+		LockFunc: noLock,
+		WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+			return nil
+		},
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+	}
+
+	m := cache.Mounter{
+		CacheRoot:  "/cache",
+		ProjectDir: "/work",
+		Exec:       exec,
+		ReadOnly:   []string{"/work/out"},
+		Modes: mode.Modes{&mode.ModeProviderMock{
+			NameFunc: func() string { return "go" },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{
+					MountPaths:  []string{"/go/pkg/mod"},
+					CacheDirs:   []string{"go-build"},
+					AddEnvs:     map[string]string{"GOCACHE": req.CacheRoot + "/go-build"},
+					RemovePaths: []string{"/go/pkg/mod/cache/lock"},
+				}, nil
+			},
+		}},
+	}
+
+	plan, err := m.Plan(t.Context(), cache.MountRequest{
+		ManualModes: []string{"go"},
+		ManualPaths: []string{"/work/out", "/go/pkg/mod/sub"},
+		Exclude:     map[string][]string{cache.AllModes: {"*.log"}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, mounted, "planning must not mount")
+	require.Empty(t, removed, "planning must not remove")
+
+	// Durations vary between runs.
+	require.Equal(t, []string{"go"}, timingModes(plan.Timings))
+	timings := plan.Timings
+	plan.Timings = nil
+
+	require.Equal(t, cache.Plan{
+		Version:    cache.PlanVersion,
+		CacheRoot:  "/cache",
+		ProjectDir: "/work",
+		Input:      cache.MountResponseInput{Modes: []string{"go"}, Paths: []string{"/work/out", "/go/pkg/mod/sub"}},
+		ModeOrder:  []string{"go"},
+		AddEnvs:    map[string]string{"GOCACHE": "/cache/go-build"},
+		Mounts: []cache.PlannedMount{
+			{Mode: "go", Path: "go-build", CacheDir: true, CachePath: "/cache/go-build", Exclude: []string{"*.log"}},
+			{Mode: "go", Path: "/go/pkg/mod", CachePath: "/cache/go/pkg/mod", Exclude: []string{"*.log"}, Source: mode.Source{Kind: mode.SourceDefault}},
+			{Path: "/work/out", CachePath: "/cache/work/out", Exclude: []string{"*.log"}, ReadOnly: true, Source: mode.Source{Kind: mode.SourceConfig}},
+		},
+		Deduplicated: []cache.DedupedPath{{Path: "/go/pkg/mod/sub", CoveredByMode: "go", CoveredBy: "/go/pkg/mod"}},
+		RemovePaths:  []string{"/go/pkg/mod/cache/lock"},
+	}, plan)
+
+	plan.Timings = timings
+
+	// Plans are applied from files.
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	var loaded cache.Plan
+	require.NoError(t, json.Unmarshal(data, &loaded))
+
+	applier := cache.Mounter{DestructiveMode: true, CacheRoot: "/elsewhere", Exec: exec}
+	result, err := applier.Apply(t.Context(), loaded)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"/cache/go/pkg/mod -> /go/pkg/mod", "/cache/work/out -> /work/out"}, mounted)
+	require.Equal(t, []string{"/go/pkg/mod/cache/lock"}, removed)
+	require.Equal(t, []string{"go"}, result.Output.ModeOrder)
+	require.Equal(t, plan.AddEnvs, result.Output.AddEnvs)
+	require.Equal(t, plan.Deduplicated, result.Output.Deduplicated)
+	require.Equal(t, timings, result.Output.Timings)
+	require.Len(t, result.Output.Mounts, 3)
+	for _, mount := range result.Output.Mounts {
+		require.Equal(t, mount.MountPath == "/work/out", mount.ReadOnly, mount.MountPath)
+	}
+
+	t.Run("mode warnings", func(t *testing.T) {
+		m := cache.Mounter{
+			CacheRoot: "/cache",
+			Exec:      exec,
+			Modes: mode.Modes{&mode.ModeProviderMock{
+				NameFunc: func() string { return "yarn" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					return mode.PlanResult{
+						MountPaths: []string{"/yarn/cache"},
+						Warnings:   []string{"yarn version could not be determined"},
+					}, nil
+				},
+			}},
+		}
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{ManualModes: []string{"yarn"}})
+		require.NoError(t, err)
+		warning := cache.MountIssue{Mode: "yarn", Message: "yarn version could not be determined", Recoverable: true}
+		require.Equal(t, []cache.MountIssue{warning}, plan.Warnings)
+
+		result, err := applier.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Contains(t, result.Output.Warnings, warning)
+	})
+
+	t.Run("work dirs", func(t *testing.T) {
+		m := cache.Mounter{
+			CacheRoot: "/cache",
+			Exec:      exec,
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "cmake" },
+					DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+						return mode.DetectResult{Detected: req.WorkDir != "web"}, nil
+					},
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{"build", "/cpm"}}, nil
+					},
+				},
+			},
+		}
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{DetectAllModes: true, WorkDirs: []string{"api", "web", "cli"}})
+		require.NoError(t, err)
+		require.Equal(t, []string{"cmake"}, plan.Input.Modes)
+		require.Equal(t, []string{"cmake"}, plan.ModeOrder)
+
+		var paths []string
+		for _, mount := range plan.Mounts {
+			paths = append(paths, mount.Path)
+		}
+		// Paths planned in several directories are mounted once.
+		require.Equal(t, []string{filepath.Join("api", "build"), "/cpm", filepath.Join("cli", "build")}, paths)
+		require.Empty(t, plan.Deduplicated)
+	})
+
+	t.Run("sibling work dirs", func(t *testing.T) {
+		mounted = nil
+		m := cache.Mounter{
+			DestructiveMode: true,
+			CacheRoot:       "/cache",
+			ProjectDir:      "/work/repo",
+			Exec:            exec,
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "turbo" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{"./.turbo/cache"}}, nil
+					},
+				},
+			},
+		}
+
+		// The path leaves the project directory, and would lead out of the
+		// cache root if it was joined to it as it is.
+		plan, err := m.Plan(t.Context(), cache.MountRequest{ManualModes: []string{"turbo"}, WorkDirs: []string{"../sib"}})
+		require.NoError(t, err)
+		require.Len(t, plan.Mounts, 1)
+		require.Equal(t, filepath.Join("..", "sib", ".turbo", "cache"), plan.Mounts[0].Path)
+		abs, err := filepath.Abs(filepath.Join("/work", "sib", ".turbo", "cache"))
+		require.NoError(t, err)
+		want := filepath.Join("/cache", cache.RootSubpath(abs))
+		require.Equal(t, want, plan.Mounts[0].CachePath)
+
+		result, err := m.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Len(t, result.Output.Mounts, 1)
+		require.Equal(t, want, result.Output.Mounts[0].CachePath)
+		require.Equal(t, []string{want + " -> " + filepath.Join("/work", "sib", ".turbo", "cache")}, mounted)
+	})
+
+	t.Run("ordered modes of work dirs at concurrency 1", func(t *testing.T) {
+		mounted = nil
+		applier := cache.Mounter{DestructiveMode: true, CacheRoot: "/cache", Exec: exec, Concurrency: 1}
+		// --recursive plans the modes of each work dir after the last, so
+		// a second job waits for a first job that is planned after it.
+		plan := cache.Plan{
+			Version:   cache.PlanVersion,
+			CacheRoot: "/cache",
+			ModeOrder: []string{"first", "second"},
+			Mounts: []cache.PlannedMount{
+				{Mode: "first", Path: "/work/api/first"},
+				{Mode: "second", Path: "/work/api/second", After: []string{"first"}},
+				{Mode: "first", Path: "/work/cli/first"},
+				{Mode: "second", Path: "/work/cli/second", After: []string{"first"}},
+			},
+		}
+
+		done := make(chan error)
+		go func() {
+			_, err := applier.Apply(t.Context(), plan)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("apply did not finish")
+		}
+		require.ElementsMatch(t, []string{"/cache/work/api/first -> /work/api/first", "/cache/work/cli/first -> /work/cli/first"}, mounted[:2])
+		require.Len(t, mounted, 4)
+	})
+
+	t.Run("mode config", func(t *testing.T) {
+		m := cache.Mounter{
+			CacheRoot: "/cache",
+			Exec:      exec,
+			Modes: mode.Modes{&mode.ModeProviderMock{
+				NameFunc: func() string { return "go" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					return mode.PlanResult{
+						MountPaths: []string{"/go-build", cmp.Or(req.Getenv("GOMODCACHE"), "/go/pkg/mod")},
+						AddEnvs:    map[string]string{"GOFLAGS": "-mod=mod"},
+					}, nil
+				},
+			}},
+		}
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{
+			ManualModes: []string{"go"},
+			ModeConfig:  map[string]cache.ModeConfig{"go": {Env: map[string]string{"GOMODCACHE": "/mnt/gomod"}, DropPaths: []string{"/go-build"}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, plan.Mounts, 1)
+		require.Equal(t, "/mnt/gomod", plan.Mounts[0].Path)
+		require.Equal(t, map[string]string{"GOFLAGS": "-mod=mod", "GOMODCACHE": "/mnt/gomod"}, plan.AddEnvs)
+
+		plan, err = m.Plan(t.Context(), cache.MountRequest{
+			ManualModes: []string{"go"},
+			ModeConfig:  map[string]cache.ModeConfig{"go": {Paths: []string{"/mnt/go"}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, plan.Mounts, 1)
+		require.Equal(t, "/mnt/go", plan.Mounts[0].Path)
+
+		_, err = m.Plan(t.Context(), cache.MountRequest{
+			ManualModes: []string{"go"},
+			ModeConfig:  map[string]cache.ModeConfig{"rust": {Paths: []string{"/mnt/target"}}},
+		})
+		require.ErrorContains(t, err, "mode config: unknown mode: rust")
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		m := cache.Mounter{
+			CacheRoot: "/cache",
+			Exec:      exec,
+			Modes: mode.Modes{&mode.ModeProviderMock{
+				NameFunc: func() string { return "npm" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					out, err := req.Exec.Run(ctx, mode.CommandSpec{Name: "npm", Args: []string{"config", "get", "cache"}})
+					if errors.Is(err, mode.ErrOffline) {
+						out, err = []byte("/home/user/.npm"), nil
+					}
+					return mode.PlanResult{MountPaths: []string{string(out)}}, err
+				},
+			}},
+		}
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{ManualModes: []string{"npm"}, Offline: true})
+		require.NoError(t, err)
+		require.Len(t, plan.Mounts, 1)
+		require.Equal(t, "/home/user/.npm", plan.Mounts[0].Path)
+		require.True(t, plan.Mounts[0].Assumed)
+		require.Equal(t, []mode.DetectCheck{{Kind: mode.CheckCommand, Name: "npm config get cache"}}, plan.Checks["npm"])
+	})
+
+	t.Run("static", func(t *testing.T) {
+		m := cache.Mounter{
+			CacheRoot: "/cache",
+			Exec:      exec,
+			Modes: mode.Modes{&mode.ModeProviderMock{
+				NameFunc: func() string { return "pip" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					if _, err := req.Exec.LookPath("pip"); err != nil {
+						return mode.PlanResult{}, err
+					}
+					if _, err := req.Exec.Run(ctx, mode.CommandSpec{Name: "pip", Args: []string{"cache", "dir"}}); !errors.Is(err, mode.ErrOffline) {
+						return mode.PlanResult{}, fmt.Errorf("expected the command to be refused, got %v", err)
+					}
+					return mode.PlanResult{MountPaths: []string{req.Getenv("PIP_CACHE_DIR") + "/http", "~/.cache/pip"}}, nil
+				},
+			}},
+		}
+		t.Setenv("PIP_CACHE_DIR", "/pip")
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{
+			ManualModes: []string{"pip"},
+			Static:      true,
+		})
+		require.NoError(t, err)
+		require.Len(t, plan.Mounts, 2)
+		require.Equal(t, mode.Source{Kind: mode.SourceEnv, Name: "PIP_CACHE_DIR"}, plan.Mounts[0].Source)
+		require.Equal(t, mode.Source{Kind: mode.SourceDefault}, plan.Mounts[1].Source)
+		require.True(t, plan.Mounts[0].Assumed)
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		var ran []string
+		exec.ShellFunc = func(ctx context.Context, dir, command string, env []string) ([]byte, []byte, error) {
+			require.Equal(t, "/work", dir)
+			require.Equal(t, []string{"GOCACHE=/cache/go-build"}, env)
+			ran = append(ran, command)
+			if command == "false" {
+				return nil, []byte("exit status 1\n"), errors.New("exit status 1")
+			}
+			return []byte("ok\n"), nil, nil
+		}
+		defer func() { exec.ShellFunc = nil }()
+
+		plan, err := m.Plan(t.Context(), cache.MountRequest{
+			ManualModes: []string{"go"},
+			Hooks: map[string]cache.Hooks{
+				cache.AllModes: {PreMount: []string{"echo pre"}, PostMount: []string{"false"}},
+				"go":           {PreMount: []string{"go env -w GOFLAGS=-mod=mod"}, PostMount: []string{"go mod download"}},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []cache.PlannedHook{
+			{Stage: cache.HookPreMount, Command: "echo pre"},
+			{Mode: "go", Stage: cache.HookPreMount, Command: "go env -w GOFLAGS=-mod=mod"},
+			{Mode: "go", Stage: cache.HookPostMount, Command: "go mod download"},
+			{Stage: cache.HookPostMount, Command: "false"},
+		}, plan.Hooks)
+		require.Empty(t, ran, "planning must not run hooks")
+
+		result, err := applier.Apply(t.Context(), plan)
+		require.ErrorContains(t, err, `1 hook(s) failed: post_mount hook "false"`)
+		require.Equal(t, []string{"echo pre", "go env -w GOFLAGS=-mod=mod", "go mod download", "false"}, ran)
+		require.Len(t, result.Output.Hooks, 4)
+		require.Equal(t, "ok\n", result.Output.Hooks[0].Stdout)
+		require.Equal(t, "exit status 1\n", result.Output.Hooks[3].Stderr)
+		require.NotZero(t, result.Output.Hooks[3].ExitCode)
+
+		_, err = m.Plan(t.Context(), cache.MountRequest{ManualModes: []string{"go"}, Hooks: map[string]cache.Hooks{"rust": {}}})
+		require.ErrorContains(t, err, "hooks: unknown mode: rust")
+
+		// Dry runs do not run hooks.
+		ran = nil
+		dryRun := cache.Mounter{CacheRoot: "/cache", ProjectDir: "/work", Exec: exec}
+		result, err = dryRun.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Empty(t, ran)
+		require.Empty(t, result.Output.Hooks)
+	})
+
+	t.Run("relative paths", func(t *testing.T) {
+		mounted = nil
+		m := cache.Mounter{CacheRoot: "/cache", ProjectDir: "/work", Exec: exec}
+		plan, err := m.Plan(t.Context(), cache.MountRequest{ManualPaths: []string{"./target"}})
+		require.NoError(t, err)
+
+		// Plans are applied in the project directory they were made in.
+		applier := cache.Mounter{DestructiveMode: true, CacheRoot: "/cache", ProjectDir: "/elsewhere", Exec: exec}
+		result, err := applier.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/cache/target -> " + filepath.Join("/work", "target")}, mounted)
+		require.Equal(t, "./target", result.Output.Mounts[0].MountPath)
+		require.Equal(t, filepath.Join("/work", "target"), result.Output.Mounts[0].ResolvedPath)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := applier.Apply(t.Context(), cache.Plan{Version: cache.PlanVersion + 1, CacheRoot: "/cache"})
+		require.ErrorContains(t, err, "plan version 2 is not supported")
+	})
+
+	t.Run("mounts that wait for each other are refused", func(t *testing.T) {
+		cases := map[string][]cache.PlannedMount{
+			"comes after its own mode": {{Mode: "a", Path: "/work/a", After: []string{"a"}}},
+			"comes after unknown mode": {{Mode: "a", Path: "/work/a", After: []string{"b"}}},
+			"cyclic order: a, b": {
+				{Mode: "a", Path: "/work/a", After: []string{"b"}},
+				{Mode: "b", Path: "/work/b", After: []string{"a"}},
+			},
+		}
+		for msg, mounts := range cases {
+			_, err := applier.Apply(t.Context(), cache.Plan{Version: cache.PlanVersion, CacheRoot: "/cache", Mounts: mounts})
+			require.ErrorContains(t, err, msg)
+		}
+	})
+
+	t.Run("unsafe paths are refused when applied", func(t *testing.T) {
+		result, err := applier.Apply(t.Context(), cache.Plan{
+			Version:   cache.PlanVersion,
+			CacheRoot: "/cache",
+			Mounts:    []cache.PlannedMount{{Path: "/etc"}},
+		})
+		require.ErrorContains(t, err, cache.ErrUnsafePath.Error())
+		require.Len(t, result.Output.Errors, 1)
+		require.Contains(t, result.Output.Errors[0].Message, cache.ErrUnsafePath.Error())
+		require.NotContains(t, mounted, "/cache/etc -> /etc")
+	})
+}
+
+func timingModes(timings []cache.ModeTiming) []string {
+	var modes []string
+	for _, t := range timings {
+		modes = append(modes, t.Mode)
+	}
+	return modes
+}
+
+func TestMounter_Timings(t *testing.T) {
+	sleeping := func(name string, detected bool) *mode.ModeProviderMock {
+		return &mode.ModeProviderMock{
+			NameFunc: func() string { return name },
+			DetectFunc: func(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+				time.Sleep(10 * time.Millisecond)
+				return mode.DetectResult{Detected: detected}, nil
+			},
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				time.Sleep(20 * time.Millisecond)
+				return mode.PlanResult{}, nil
+			},
+		}
+	}
+
+	m := cache.Mounter{
+		CacheRoot: t.TempDir(),
+		Exec: &cache.ExecutorMock{
+			StatFunc:     os.Stat,
+			ReadFileFunc: os.ReadFile,
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+		},
+		Modes: mode.Modes{sleeping("gradle", true), sleeping("maven", false)},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{DetectAllModes: true})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.Output.DurationMs, int64(30))
+
+	require.Len(t, result.Output.Timings, 2)
+	gradle, maven := result.Output.Timings[0], result.Output.Timings[1]
+	require.Equal(t, "gradle", gradle.Mode)
+	require.GreaterOrEqual(t, gradle.DetectMs, int64(10))
+	require.GreaterOrEqual(t, gradle.PlanMs, int64(20))
+	// Modes that are not detected are not planned.
+	require.Equal(t, "maven", maven.Mode)
+	require.GreaterOrEqual(t, maven.DetectMs, int64(10))
+	require.Zero(t, maven.PlanMs)
+}
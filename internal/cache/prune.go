@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// PruneRequest describes which cache entries to delete. Entries are the cache
+// paths recorded in the metadata file, and the caches of the same mount paths
+// under other keys and scopes; with both policies unset nothing is deleted.
+type PruneRequest struct {
+	// MaxAge deletes entries that have not been used for longer than this.
+	MaxAge time.Duration
+	// MaxSize deletes the least recently used entries until the remaining
+	// ones fit within this many bytes.
+	MaxSize int64
+	// Free deletes the least recently used entries until at least this many
+	// bytes are freed.
+	Free int64
+	// CompressAfter compresses the entries that are kept but have not been
+	// used for longer than this, to be decompressed on their next mount.
+	CompressAfter time.Duration
+	// Compression is how entries are compressed. Defaults to zstd.
+	Compression Compression
+	// Keep lists the mount paths or cache paths of entries that are never
+	// deleted, e.g. those about to be mounted.
+	Keep []string
+	// InUse keeps the entries mounted within this long, or for entries no
+	// longer recorded, used within this long, as a job on another runner
+	// sharing the volume may still have them mounted.
+	InUse time.Duration
+}
+
+// inUseWindow is how long eviction and compression, which run as part of a
+// job, assume that other jobs use the caches they mounted. It is the longest
+// that a GitHub Actions job runs.
+const inUseWindow = 6 * time.Hour
+
+type PruneResponse struct {
+	CacheRoot  string       `json:"cache_root"`
+	DryRun     bool         `json:"dry_run"`
+	TotalBytes int64        `json:"total_bytes"` // size of all entries before pruning
+	FreedBytes int64        `json:"freed_bytes"`
+	Deleted    []PruneEntry `json:"deleted,omitzero"`
+	// Compressed lists the entries that were compressed rather than deleted.
+	Compressed []PruneEntry `json:"compressed,omitzero"`
+}
+
+type PruneEntry struct {
+	Mode      string    `json:"mode,omitzero"`
+	CachePath string    `json:"cache_path"`
+	MountPath string    `json:"mount_path"`
+	LastUsed  time.Time `json:"last_used"`
+	Size      DirSize   `json:"size"`
+	Reason    string    `json:"reason"` // "max_age", "max_size", "free" or "compress"
+	// CompressedBytes is the size of a compressed entry, once compressed.
+	CompressedBytes int64 `json:"compressed_bytes,omitzero"`
+	// compressed is set for entries that already are.
+	compressed bool
+	// mountedAt is when a recorded entry was last mounted.
+	mountedAt time.Time
+}
+
+const (
+	pruneReasonMaxAge   = "max_age"
+	pruneReasonMaxSize  = "max_size"
+	pruneReasonFree     = "free"
+	pruneReasonCompress = "compress"
+)
+
+// Prune deletes cache entries according to the request. Without
+// DestructiveMode it only reports what would be deleted.
+func (m Mounter) Prune(ctx context.Context, req PruneRequest, now time.Time) (PruneResponse, error) {
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return PruneResponse{}, err
+	}
+	defer unlock()
+
+	return m.prune(ctx, req, now)
+}
+
+// prune is Prune for callers that hold the lock on the cache root.
+func (m Mounter) prune(ctx context.Context, req PruneRequest, now time.Time) (PruneResponse, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return PruneResponse{}, err
+	}
+
+	result := PruneResponse{
+		CacheRoot: m.CacheRoot,
+		DryRun:    !m.DestructiveMode,
+	}
+
+	var candidates []PruneEntry
+	for mountPath, entry := range metadata.UserRequest {
+		if slices.Contains(req.Keep, mountPath) {
+			continue
+		}
+		e := PruneEntry{CachePath: entry.Source, MountPath: mountPath}
+		if entry.CacheFramework != nil {
+			e.Mode = *entry.CacheFramework
+		}
+		// Entries written before mount times were recorded fall back to
+		// their last use.
+		e.mountedAt, _ = time.Parse(time.RFC3339, entry.MountedAt)
+		candidates = append(candidates, e)
+	}
+	untracked, err := m.untrackedEntries(metadata)
+	if err != nil {
+		return PruneResponse{}, err
+	}
+	candidates = append(candidates, untracked...)
+
+	var entries []PruneEntry
+	for _, e := range candidates {
+		if err := ctx.Err(); err != nil {
+			return PruneResponse{}, err
+		}
+		if slices.Contains(req.Keep, e.CachePath) {
+			continue
+		}
+
+		e.Size, e.LastUsed, err = dirUsage(e.CachePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return PruneResponse{}, err
+		}
+		if req.InUse > 0 && now.Sub(cmp.Or(e.mountedAt, e.LastUsed)) < req.InUse {
+			slog.Debug("keeping cache entry that may be in use", slog.String("path", e.CachePath))
+			continue
+		}
+		if req.CompressAfter > 0 {
+			if _, e.compressed, err = m.coldArchive(e.CachePath); err != nil {
+				return PruneResponse{}, err
+			}
+		}
+		entries = append(entries, e)
+		result.TotalBytes += e.Size.Bytes
+	}
+
+	// Least recently used first.
+	slices.SortFunc(entries, func(a, b PruneEntry) int {
+		return cmp.Or(a.LastUsed.Compare(b.LastUsed), cmp.Compare(a.CachePath, b.CachePath))
+	})
+
+	remaining := result.TotalBytes
+	for _, e := range entries {
+		switch {
+		case req.MaxAge > 0 && now.Sub(e.LastUsed) > req.MaxAge:
+			e.Reason = pruneReasonMaxAge
+		case req.MaxSize > 0 && remaining > req.MaxSize:
+			e.Reason = pruneReasonMaxSize
+		case req.Free > 0 && result.FreedBytes < req.Free:
+			e.Reason = pruneReasonFree
+		case req.CompressAfter > 0 && now.Sub(e.LastUsed) > req.CompressAfter && !e.compressed:
+			e.Reason = pruneReasonCompress
+			result.Compressed = append(result.Compressed, e)
+			continue
+		default:
+			continue
+		}
+
+		remaining -= e.Size.Bytes
+		result.FreedBytes += e.Size.Bytes
+		result.Deleted = append(result.Deleted, e)
+	}
+
+	if !m.DestructiveMode {
+		for _, e := range result.Deleted {
+			slog.Debug("dry-run: would delete cache entry", slog.String("path", e.CachePath), slog.String("reason", e.Reason))
+		}
+		for _, e := range result.Compressed {
+			slog.Debug("dry-run: would compress cache entry", slog.String("path", e.CachePath))
+		}
+		return result, nil
+	}
+
+	if err := m.compressEntries(ctx, cmp.Or(req.Compression, CompressionZstd), &result); err != nil {
+		return PruneResponse{}, err
+	}
+
+	var forget []string
+	var removeErr error
+	for _, e := range result.Deleted {
+		slog.Debug("deleting cache entry", slog.String("path", e.CachePath), slog.String("reason", e.Reason))
+
+		m.observer().OnPruneStart(e)
+		err := m.Exec.RemoveAll(e.CachePath)
+		m.observer().OnPruneResult(e, err)
+		if err != nil {
+			removeErr = fmt.Errorf("removing %q: %w", e.CachePath, err)
+			break
+		}
+		forget = append(forget, e.CachePath)
+	}
+
+	// Entries deleted before a failure are gone either way, so keep the
+	// metadata in sync with them.
+	if err := errors.Join(removeErr, m.forgetMetadata(ctx, forget)); err != nil {
+		return PruneResponse{}, err
+	}
+
+	return result, nil
+}
+
+// compressEntries compresses the entries of result.Compressed, and records
+// them in the metadata. Entries compressed before a failure are recorded.
+func (m Mounter) compressEntries(ctx context.Context, compression Compression, result *PruneResponse) error {
+	compressed := map[string]CompressedCache{}
+	var compressErr error
+	for i := range result.Compressed {
+		e := &result.Compressed[i]
+		slog.Debug("compressing cache entry", slog.String("path", e.CachePath))
+
+		c, err := m.compress(ctx, e.CachePath, compression, e.LastUsed)
+		if err != nil {
+			compressErr = fmt.Errorf("compressing %q: %w", e.CachePath, err)
+			result.Compressed = result.Compressed[:i]
+			break
+		}
+		e.CompressedBytes = c.Bytes
+		result.FreedBytes += max(e.Size.Bytes-c.Bytes, 0)
+		compressed[e.CachePath] = c
+	}
+	if len(compressed) == 0 {
+		return compressErr
+	}
+
+	return errors.Join(compressErr, m.modifyMetadata(ctx, func(metadata *CacheMetadata) error {
+		for mountPath, entry := range metadata.UserRequest {
+			if c, ok := compressed[entry.Source]; ok {
+				entry.Compressed = &c
+				metadata.UserRequest[mountPath] = entry
+			}
+		}
+		return nil
+	}))
+}
+
+// untrackedEntries finds the caches that the metadata lost track of. It
+// records the latest cache of each mount path, so the caches that the same
+// path was mounted from under other keys and scopes are found by looking for
+// them under every scope and every key of the mode.
+func (m Mounter) untrackedEntries(metadata CacheMetadata) ([]PruneEntry, error) {
+	roots := []string{m.CacheRoot}
+	scopes, err := os.ReadDir(filepath.Join(m.CacheRoot, ScopesDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("listing scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if scope.IsDir() {
+			roots = append(roots, filepath.Join(m.CacheRoot, ScopesDir, scope.Name()))
+		}
+	}
+
+	var tracked []string
+	for _, entry := range metadata.UserRequest {
+		tracked = append(tracked, entry.Source)
+	}
+	// Nested or enclosing caches are part of another entry.
+	isTracked := func(path string) bool {
+		return slices.ContainsFunc(tracked, func(source string) bool {
+			return source == path || isWithin(source, path) || isWithin(path, source)
+		})
+	}
+
+	var entries []PruneEntry
+	for _, mountPath := range slices.Sorted(maps.Keys(metadata.UserRequest)) {
+		var modeName string
+		if f := metadata.UserRequest[mountPath].CacheFramework; f != nil {
+			modeName = *f
+		}
+		subpath := m.cacheSubpath(mountPath)
+
+		for _, root := range roots {
+			locations := []string{cacheLocation(root, "", "", subpath)}
+			if modeName != "" {
+				keys, err := keyDirs(filepath.Join(root, modeName))
+				if err != nil {
+					return nil, fmt.Errorf("listing keys of mode %q: %w", modeName, err)
+				}
+				for _, key := range keys {
+					locations = append(locations, cacheLocation(root, modeName, key, subpath))
+				}
+			}
+
+			for _, location := range locations {
+				// Locations that do not exist are skipped with the others.
+				if isTracked(location) || !isWithin(root, location) {
+					continue
+				}
+				tracked = append(tracked, location)
+				entries = append(entries, PruneEntry{Mode: modeName, CachePath: location, MountPath: mountPath})
+			}
+		}
+	}
+	return entries, nil
+}
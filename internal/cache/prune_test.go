@@ -0,0 +1,347 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Prune(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// setup creates cache entries of the given sizes, last used the given
+	// number of days before now, and records them in the metadata file.
+	setup := func(t *testing.T, entries map[string][2]int) (string, *cache.ExecutorMock) {
+		cacheRoot := t.TempDir()
+
+		metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{}}
+		for name, e := range entries {
+			size, days := e[0], e[1]
+			source := filepath.Join(cacheRoot, name)
+			require.NoError(t, os.MkdirAll(source, 0o755))
+
+			file := filepath.Join(source, "data")
+			require.NoError(t, os.WriteFile(file, make([]byte, size), 0o644))
+
+			used := now.Add(-time.Duration(days) * 24 * time.Hour)
+			require.NoError(t, os.Chtimes(file, used, used))
+			require.NoError(t, os.Chtimes(source, used, used))
+
+			mode := name
+			metadata.UserRequest["/work/"+name] = cache.CacheMetadataEntry{
+				CacheFramework: &mode,
+				MountTarget:    []string{"/work/" + name},
+				Source:         source,
+			}
+		}
+
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		path := filepath.Join(cacheRoot, cache.MetadataPath)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+
+		return cacheRoot, &cache.ExecutorMock{
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+			MkdirAllFunc:  os.MkdirAll,
+			ReadFileFunc:  os.ReadFile,
+			RemoveAllFunc: os.RemoveAll,
+			LockFunc:      noLock,
+			WriteFileFunc: os.WriteFile,
+		}
+	}
+
+	deleted := func(result cache.PruneResponse) map[string]string {
+		reasons := map[string]string{}
+		for _, e := range result.Deleted {
+			reasons[e.Mode] = e.Reason
+		}
+		return reasons
+	}
+
+	t.Run("deletes entries older than max age", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {10, 30},
+			"go":  {20, 1},
+		})
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: 7 * 24 * time.Hour}, now)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apt": "max_age"}, deleted(result))
+		require.Equal(t, int64(30), result.TotalBytes)
+		require.Equal(t, int64(10), result.FreedBytes)
+		require.False(t, result.DryRun)
+
+		require.NoDirExists(t, filepath.Join(cacheRoot, "apt"))
+		require.DirExists(t, filepath.Join(cacheRoot, "go"))
+
+		metadata, err := cache.ReadMetadata(exec, cacheRoot)
+		require.NoError(t, err)
+		require.Len(t, metadata.UserRequest, 1)
+		require.Contains(t, metadata.UserRequest, "/work/go")
+	})
+
+	t.Run("deletes least recently used entries above max size", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt":    {100, 3},
+			"go":     {100, 1},
+			"gradle": {100, 2},
+		})
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxSize: 150}, now)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apt": "max_size", "gradle": "max_size"}, deleted(result))
+		require.Equal(t, int64(200), result.FreedBytes)
+		require.Equal(t, "apt", result.Deleted[0].Mode)
+
+		require.DirExists(t, filepath.Join(cacheRoot, "go"))
+	})
+
+	t.Run("age is applied before size", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {100, 30},
+			"go":  {100, 1},
+		})
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: 7 * 24 * time.Hour, MaxSize: 150}, now)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apt": "max_age"}, deleted(result))
+	})
+
+	t.Run("dry run keeps everything", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {10, 30},
+		})
+		m := cache.Mounter{CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: 24 * time.Hour}, now)
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Equal(t, map[string]string{"apt": "max_age"}, deleted(result))
+
+		require.DirExists(t, filepath.Join(cacheRoot, "apt"))
+		require.Empty(t, exec.RemoveAllCalls())
+		require.Empty(t, exec.WriteFileCalls())
+	})
+
+	t.Run("no policies deletes nothing", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {10, 30},
+		})
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{}, now)
+		require.NoError(t, err)
+		require.Empty(t, result.Deleted)
+		require.Empty(t, exec.RemoveAllCalls())
+	})
+
+	t.Run("frees least recently used entries except kept ones", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt":    {100, 2},
+			"go":     {100, 3},
+			"gradle": {100, 1},
+		})
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{Free: 150, Keep: []string{"/work/go"}}, now)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apt": "free", "gradle": "free"}, deleted(result))
+		require.DirExists(t, filepath.Join(cacheRoot, "go"))
+	})
+
+	t.Run("mounting evicts when the volume is nearly full", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt":    {100, 2},
+			"go":     {100, 3},
+			"gradle": {100, 1},
+		})
+		exec.StatFunc = os.Stat
+		exec.DiskUsageFunc = func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{UsedBytes: 950, AvailableBytes: 50, UsagePercent: 95}, nil
+		}
+		m := cache.Mounter{CacheRoot: cacheRoot, Exec: exec, EvictHighWater: 90}
+		plan := cache.Plan{Version: cache.PlanVersion, CacheRoot: cacheRoot, Mounts: []cache.PlannedMount{
+			{Mode: "go", Path: "/work/go", CachePath: filepath.Join(cacheRoot, "go")},
+		}}
+
+		// Down to 80% of the volume, with the cache of go about to be mounted.
+		result, err := m.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Len(t, result.Output.Evicted, 2)
+		require.Equal(t, "apt", result.Output.Evicted[0].Mode)
+		require.Equal(t, "gradle", result.Output.Evicted[1].Mode)
+		for _, issue := range result.Output.Warnings {
+			require.NotContains(t, issue.Message, "evicting")
+		}
+		// Eviction is reported, but not done, in dry-run mode.
+		require.DirExists(t, filepath.Join(cacheRoot, "apt"))
+	})
+
+	t.Run("eviction keeps caches that other jobs may have mounted", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt":    {100, 2},
+			"gradle": {100, 3},
+		})
+		exec.StatFunc = os.Stat
+		exec.DiskUsageFunc = func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{UsedBytes: 950, AvailableBytes: 50, UsagePercent: 95}, nil
+		}
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec, EvictHighWater: 90}
+
+		// A job on another runner mounted gradle an hour ago.
+		metadata, err := cache.ReadMetadata(exec, cacheRoot)
+		require.NoError(t, err)
+		entry := metadata.UserRequest["/work/gradle"]
+		entry.MountedAt = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		metadata.UserRequest["/work/gradle"] = entry
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		result, err := m.Apply(t.Context(), cache.Plan{Version: cache.PlanVersion, CacheRoot: cacheRoot})
+		require.NoError(t, err)
+		require.Len(t, result.Output.Evicted, 1)
+		require.Equal(t, "apt", result.Output.Evicted[0].Mode)
+		require.DirExists(t, filepath.Join(cacheRoot, "gradle"))
+	})
+
+	t.Run("compresses entries not used for a while until mounted", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {1000, 30},
+			"go":  {1000, 1},
+		})
+		exec.StatFunc = os.Stat
+		exec.RenameFunc = os.Rename
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{CompressAfter: 7 * 24 * time.Hour, Compression: cache.CompressionGzip}, now)
+		require.NoError(t, err)
+		require.Empty(t, result.Deleted)
+		require.Len(t, result.Compressed, 1)
+		require.Equal(t, "apt", result.Compressed[0].Mode)
+		require.Equal(t, "compress", result.Compressed[0].Reason)
+		require.Less(t, result.Compressed[0].CompressedBytes, int64(1000))
+		require.NoFileExists(t, filepath.Join(cacheRoot, "apt", "data"))
+
+		// Compressed entries are reported by stats, and are not compressed
+		// again.
+		stats, err := m.Stats(t.Context(), cache.StatsRequest{})
+		require.NoError(t, err)
+		require.NotNil(t, stats.Compressed)
+		require.Equal(t, 1, stats.Compressed.Entries)
+		require.Equal(t, int64(1000), stats.Compressed.OriginalBytes)
+
+		again, err := m.Prune(t.Context(), cache.PruneRequest{CompressAfter: 7 * 24 * time.Hour, Compression: cache.CompressionGzip}, now)
+		require.NoError(t, err)
+		require.Empty(t, again.Compressed)
+
+		// The entry is decompressed when it is mounted next.
+		mounted, err := m.Apply(t.Context(), cache.Plan{Version: cache.PlanVersion, CacheRoot: cacheRoot, Mounts: []cache.PlannedMount{
+			{Mode: "apt", Path: "apt", CacheDir: true},
+		}})
+		require.NoError(t, err)
+		require.Len(t, mounted.Output.Mounts, 1)
+		require.True(t, mounted.Output.Mounts[0].CacheHit)
+		require.True(t, mounted.Output.Mounts[0].Decompressed)
+
+		data, err := os.ReadFile(filepath.Join(cacheRoot, "apt", "data"))
+		require.NoError(t, err)
+		require.Len(t, data, 1000)
+		entries, err := os.ReadDir(filepath.Join(cacheRoot, "apt"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("deletes caches of other keys and scopes", func(t *testing.T) {
+		cacheRoot, exec := setup(t, nil)
+		subpath := cache.RootSubpath("/work/target")
+		current := filepath.Join(cacheRoot, "cargo", "aaaaaaaaaaaaaaaa", subpath)
+		previous := filepath.Join(cacheRoot, "cargo", "bbbbbbbbbbbbbbbb", subpath)
+		scoped := filepath.Join(cacheRoot, cache.ScopesDir, "feature", subpath)
+		scopedKey := filepath.Join(cacheRoot, cache.ScopesDir, "feature", "cargo", "cccccccccccccccc", subpath)
+		for path, days := range map[string]int{current: 1, previous: 30, scoped: 30, scopedKey: 30} {
+			require.NoError(t, os.MkdirAll(path, 0o755))
+			file := filepath.Join(path, "data")
+			require.NoError(t, os.WriteFile(file, make([]byte, 10), 0o644))
+			used := now.Add(-time.Duration(days) * 24 * time.Hour)
+			require.NoError(t, os.Chtimes(file, used, used))
+			require.NoError(t, os.Chtimes(path, used, used))
+		}
+
+		// Each mount of /work/target replaced the entry of the one before.
+		mode := "cargo"
+		data, err := json.Marshal(cache.CacheMetadata{Version: 2, UserRequest: map[string]cache.CacheMetadataEntry{
+			"/work/target": {CacheFramework: &mode, MountTarget: []string{"/work/target"}, Source: current},
+		}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: 7 * 24 * time.Hour, Keep: []string{"/work/target"}}, now)
+		require.NoError(t, err)
+		var paths []string
+		for _, e := range result.Deleted {
+			require.Equal(t, "cargo", e.Mode)
+			require.Equal(t, "/work/target", e.MountPath)
+			paths = append(paths, e.CachePath)
+		}
+		require.ElementsMatch(t, []string{previous, scoped, scopedKey}, paths)
+		require.Equal(t, int64(30), result.TotalBytes)
+
+		require.DirExists(t, current)
+		require.NoDirExists(t, previous)
+		metadata, err := cache.ReadMetadata(exec, cacheRoot)
+		require.NoError(t, err)
+		require.Equal(t, current, metadata.UserRequest["/work/target"].Source)
+	})
+
+	t.Run("skips entries whose cache path is gone", func(t *testing.T) {
+		cacheRoot, exec := setup(t, map[string][2]int{
+			"apt": {10, 30},
+		})
+		require.NoError(t, os.RemoveAll(filepath.Join(cacheRoot, "apt")))
+		m := cache.Mounter{DestructiveMode: true, CacheRoot: cacheRoot, Exec: exec}
+
+		result, err := m.Prune(t.Context(), cache.PruneRequest{MaxAge: time.Hour}, now)
+		require.NoError(t, err)
+		require.Empty(t, result.Deleted)
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"512K", 512 << 10},
+		{"10G", 10 << 30},
+		{"10GB", 10 << 30},
+		{"10GiB", 10 << 30},
+		{"1.5M", 3 << 19},
+		{"2t", 2 << 40},
+	} {
+		got, err := cache.ParseSize(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.want, got, tc.in)
+	}
+
+	for _, in := range []string{"", "G", "-1G", "ten"} {
+		_, err := cache.ParseSize(in)
+		require.Error(t, err, in)
+	}
+}
@@ -0,0 +1,240 @@
+// Package remote transfers cache archives to and from object storage.
+//
+// Transfers go through each provider's own CLI, which already handles
+// authentication, multipart transfers and their parallelism.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage uploads and downloads a single archive at a remote URL.
+type Storage interface {
+	Upload(ctx context.Context, localPath string) error
+	Download(ctx context.Context, localPath string) error
+}
+
+type Options struct {
+	// Concurrency is the number of parts transferred at once. Zero keeps the
+	// CLI's default. The aws CLI only reads it from its own configuration, so
+	// it cannot be set for S3.
+	Concurrency int
+	// Attempts is how often a transfer is tried before giving up.
+	Attempts int
+	// RetryDelay is the delay before the first retry, doubling after each.
+	RetryDelay time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Attempts:   3,
+		RetryDelay: 2 * time.Second,
+	}
+}
+
+// Schemes lists the supported URL schemes.
+var Schemes = []string{"azblob", "file", "gs", "s3"}
+
+// New returns the storage for a URL:
+//
+//   - s3://bucket/key uses the aws CLI
+//   - gs://bucket/object uses the gcloud CLI
+//   - azblob://account/container/blob uses the az CLI
+//   - file:///path copies locally, which is mostly useful for testing
+func New(rawURL string, opts Options) (Storage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote URL: %w", err)
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid S3 URL %q: expected s3://bucket/key", rawURL)
+		}
+		if opts.Concurrency > 0 {
+			return nil, fmt.Errorf("concurrency cannot be set for S3 URL %q: set s3.max_concurrent_requests in the aws CLI configuration instead", rawURL)
+		}
+		return cliStorage{
+			url:      rawURL,
+			opts:     opts,
+			bin:      "aws",
+			upload:   func(local string) []string { return []string{"s3", "cp", "--only-show-errors", local, rawURL} },
+			download: func(local string) []string { return []string{"s3", "cp", "--only-show-errors", rawURL, local} },
+		}, nil
+
+	case "gs":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid GCS URL %q: expected gs://bucket/object", rawURL)
+		}
+		s := cliStorage{
+			url:      rawURL,
+			opts:     opts,
+			bin:      "gcloud",
+			upload:   func(local string) []string { return []string{"storage", "cp", "--quiet", local, rawURL} },
+			download: func(local string) []string { return []string{"storage", "cp", "--quiet", rawURL, local} },
+		}
+		if opts.Concurrency > 0 {
+			s.env = []string{"CLOUDSDK_STORAGE_THREAD_COUNT=" + strconv.Itoa(opts.Concurrency)}
+		}
+		return s, nil
+
+	case "azblob":
+		container, blob, ok := strings.Cut(key, "/")
+		if u.Host == "" || !ok || container == "" || blob == "" {
+			return nil, fmt.Errorf("invalid Azure Blob URL %q: expected azblob://account/container/blob", rawURL)
+		}
+		args := func(fileFlag, local string) []string {
+			args := []string{"--account-name", u.Host, "--container-name", container, "--name", blob, fileFlag, local, "--only-show-errors"}
+			if opts.Concurrency > 0 {
+				args = append(args, "--max-connections", strconv.Itoa(opts.Concurrency))
+			}
+			return args
+		}
+		return cliStorage{
+			url:  rawURL,
+			opts: opts,
+			bin:  "az",
+			upload: func(local string) []string {
+				return append([]string{"storage", "blob", "upload", "--overwrite"}, args("--file", local)...)
+			},
+			download: func(local string) []string {
+				return append([]string{"storage", "blob", "download"}, args("--file", local)...)
+			},
+		}, nil
+
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid file URL %q: expected file:///path", rawURL)
+		}
+		return fileStorage{path: filepath.FromSlash(u.Path), opts: opts}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported remote URL %q: scheme must be one of %s", rawURL, strings.Join(Schemes, ", "))
+	}
+}
+
+type cliStorage struct {
+	url      string
+	opts     Options
+	bin      string
+	env      []string
+	upload   func(localPath string) []string
+	download func(localPath string) []string
+}
+
+func (s cliStorage) Upload(ctx context.Context, localPath string) error {
+	return retry(ctx, s.opts, "uploading to "+s.url, func() error {
+		return s.run(ctx, s.upload(localPath))
+	})
+}
+
+func (s cliStorage) Download(ctx context.Context, localPath string) error {
+	return retry(ctx, s.opts, "downloading "+s.url, func() error {
+		return s.run(ctx, s.download(localPath))
+	})
+}
+
+func (s cliStorage) run(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, s.bin, args...)
+	cmd.Env = append(os.Environ(), s.env...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			// Retrying will not make the CLI appear.
+			return permanent{fmt.Errorf("%s CLI is required for %s: %w", s.bin, s.url, err)}
+		}
+		return fmt.Errorf("%s %s: %w: %s", s.bin, strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+type fileStorage struct {
+	path string
+	opts Options
+}
+
+func (s fileStorage) Upload(ctx context.Context, localPath string) error {
+	return retry(ctx, s.opts, "copying to "+s.path, func() error {
+		if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+			return err
+		}
+		return copyFile(localPath, s.path)
+	})
+}
+
+func (s fileStorage) Download(ctx context.Context, localPath string) error {
+	return retry(ctx, s.opts, "copying from "+s.path, func() error {
+		if err := copyFile(s.path, localPath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return permanent{err}
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// permanent marks errors that retrying cannot fix.
+type permanent struct{ error }
+
+func (p permanent) Unwrap() error { return p.error }
+
+func retry(ctx context.Context, opts Options, what string, fn func() error) error {
+	attempts := max(opts.Attempts, 1)
+	delay := opts.RetryDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		var p permanent
+		if errors.As(err, &p) || attempt == attempts {
+			break
+		}
+
+		slog.Warn(fmt.Sprintf("%s failed, retrying in %s", what, delay), slog.Int("attempt", attempt), slog.Any("error", err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	return fmt.Errorf("%s: %w", what, err)
+}
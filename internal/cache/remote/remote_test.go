@@ -0,0 +1,160 @@
+package remote_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/remote"
+)
+
+func TestNew(t *testing.T) {
+	for _, u := range []string{
+		"s3://bucket/cache.tar.zst",
+		"gs://bucket/dir/cache.tgz",
+		"azblob://account/container/cache.tgz",
+		"file:///tmp/cache.tgz",
+	} {
+		_, err := remote.New(u, remote.DefaultOptions())
+		require.NoError(t, err, u)
+	}
+
+	_, err := remote.New("s3://bucket/cache.tgz", remote.Options{Concurrency: 8})
+	require.ErrorContains(t, err, "concurrency cannot be set for S3")
+
+	for u, msg := range map[string]string{
+		"s3://bucket":                "expected s3://bucket/key",
+		"gs:///object":               "expected gs://bucket/object",
+		"azblob://account/container": "expected azblob://account/container/blob",
+		"https://example.com/x.tgz":  "scheme must be one of azblob, file, gs, s3",
+		"/local/path/without/scheme": "scheme must be one of",
+	} {
+		_, err := remote.New(u, remote.DefaultOptions())
+		require.ErrorContains(t, err, msg, u)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "cache.tgz")
+	require.NoError(t, os.WriteFile(local, []byte("archive"), 0o644))
+
+	s, err := remote.New("file://"+filepath.ToSlash(filepath.Join(dir, "remote", "cache.tgz")), remote.DefaultOptions())
+	require.NoError(t, err)
+
+	require.NoError(t, s.Upload(t.Context(), local))
+
+	downloaded := filepath.Join(dir, "downloaded.tgz")
+	require.NoError(t, s.Download(t.Context(), downloaded))
+	data, err := os.ReadFile(downloaded)
+	require.NoError(t, err)
+	require.Equal(t, "archive", string(data))
+
+	missing, err := remote.New("file://"+filepath.ToSlash(filepath.Join(dir, "missing.tgz")), remote.DefaultOptions())
+	require.NoError(t, err)
+	require.ErrorIs(t, missing.Download(t.Context(), downloaded), os.ErrNotExist)
+}
+
+func TestCLIStorage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLIs are shell scripts")
+	}
+
+	// fakeCLI installs a CLI that logs its arguments and environment, and
+	// fails the given number of times before succeeding.
+	fakeCLI := func(t *testing.T, name string, failures int) string {
+		dir := t.TempDir()
+		log := filepath.Join(dir, "log")
+		script := `#!/bin/sh
+echo "$@ threads=$CLOUDSDK_STORAGE_THREAD_COUNT" >> ` + log + `
+n=$(wc -l < ` + log + `)
+if [ "$n" -le ` + strconv.Itoa(failures) + ` ]; then
+  echo "transient failure" >&2
+  exit 1
+fi
+`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755))
+		t.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+		return log
+	}
+
+	readLog := func(t *testing.T, log string) []string {
+		data, err := os.ReadFile(log)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	opts := remote.Options{Attempts: 3, RetryDelay: time.Millisecond}
+	parallel := opts
+	parallel.Concurrency = 8
+
+	t.Run("s3", func(t *testing.T) {
+		log := fakeCLI(t, "aws", 0)
+		s, err := remote.New("s3://bucket/cache.tgz", opts)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Upload(t.Context(), "/tmp/cache.tgz"))
+		require.NoError(t, s.Download(t.Context(), "/tmp/out.tgz"))
+		require.Equal(t, []string{
+			"s3 cp --only-show-errors /tmp/cache.tgz s3://bucket/cache.tgz threads=",
+			"s3 cp --only-show-errors s3://bucket/cache.tgz /tmp/out.tgz threads=",
+		}, readLog(t, log))
+	})
+
+	t.Run("gcs", func(t *testing.T) {
+		log := fakeCLI(t, "gcloud", 0)
+		s, err := remote.New("gs://bucket/cache.tgz", parallel)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Upload(t.Context(), "/tmp/cache.tgz"))
+		require.Equal(t, []string{
+			"storage cp --quiet /tmp/cache.tgz gs://bucket/cache.tgz threads=8",
+		}, readLog(t, log))
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		log := fakeCLI(t, "az", 0)
+		s, err := remote.New("azblob://account/container/dir/cache.tgz", parallel)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Download(t.Context(), "/tmp/out.tgz"))
+		require.Equal(t, []string{
+			"storage blob download --account-name account --container-name container --name dir/cache.tgz --file /tmp/out.tgz --only-show-errors --max-connections 8 threads=",
+		}, readLog(t, log))
+	})
+
+	t.Run("retries transient failures", func(t *testing.T) {
+		log := fakeCLI(t, "aws", 2)
+		s, err := remote.New("s3://bucket/cache.tgz", opts)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Upload(t.Context(), "/tmp/cache.tgz"))
+		require.Len(t, readLog(t, log), 3)
+	})
+
+	t.Run("gives up after all attempts", func(t *testing.T) {
+		log := fakeCLI(t, "aws", 5)
+		s, err := remote.New("s3://bucket/cache.tgz", opts)
+		require.NoError(t, err)
+
+		err = s.Upload(t.Context(), "/tmp/cache.tgz")
+		require.ErrorContains(t, err, "uploading to s3://bucket/cache.tgz")
+		require.ErrorContains(t, err, "transient failure")
+		require.Len(t, readLog(t, log), 3)
+	})
+
+	t.Run("missing CLI is not retried", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		s, err := remote.New("s3://bucket/cache.tgz", opts)
+		require.NoError(t, err)
+
+		err = s.Upload(t.Context(), "/tmp/cache.tgz")
+		require.ErrorContains(t, err, "aws CLI is required")
+	})
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// ErrUnsafePath is returned for paths that caches must not be mounted over
+// or removed, unless Mounter.AllowUnsafePaths is set.
+var ErrUnsafePath = errors.New("unsafe path")
+
+// systemPaths are directories that mounting over or removing would break the
+// system. Paths beneath them, like /var/cache/apt, are fine.
+var systemPaths = []string{
+	"/bin", "/boot", "/etc", "/home", "/lib", "/lib32", "/lib64", "/opt", "/root",
+	"/sbin", "/srv", "/tmp", "/usr", "/usr/bin", "/usr/lib", "/usr/local", "/var",
+	// macOS
+	"/Applications", "/Library", "/System", "/Users", "/Volumes", "/private",
+}
+
+// pseudoFilesystems are kernel filesystems that no path beneath may be
+// mounted over or removed.
+var pseudoFilesystems = []string{"/dev", "/proc", "/sys"}
+
+// checkPathSafe refuses path when mounting over or removing it is likely a
+// mistake: a filesystem root, a system directory, the home directory itself,
+// or a directory holding the cache root. When AllowedRoots is set, path must
+// also be beneath one of them.
+func (m Mounter) checkPathSafe(path string) error {
+	if m.AllowUnsafePaths {
+		return nil
+	}
+
+	target := mountTarget(m.projectPath(path))
+	if target == "" {
+		return fmt.Errorf("%w: cannot resolve %q", ErrUnsafePath, path)
+	}
+
+	if reason := unsafeReason(target, m.CacheRoot); reason != "" {
+		return fmt.Errorf("%w: %q is %s", ErrUnsafePath, path, reason)
+	}
+
+	if len(m.AllowedRoots) > 0 && !slices.ContainsFunc(m.AllowedRoots, func(root string) bool {
+		root = mountTarget(root)
+		return root != "" && isWithin(root, target)
+	}) {
+		return fmt.Errorf("%w: %q is outside the allowed roots", ErrUnsafePath, path)
+	}
+
+	return nil
+}
+
+func unsafeReason(target, cacheRoot string) string {
+	if filepath.Dir(target) == target {
+		return "a filesystem root"
+	}
+
+	if home, err := os.UserHomeDir(); err == nil && target == filepath.Clean(home) {
+		return "the home directory"
+	}
+
+	if cacheRoot != "" && (target == cacheRoot || isWithin(target, cacheRoot)) {
+		return "the cache root or holds it"
+	}
+
+	if runtime.GOOS == "windows" {
+		for _, env := range []string{"SystemRoot", "ProgramFiles", "ProgramFiles(x86)", "ProgramData"} {
+			if dir := os.Getenv(env); dir != "" && target == filepath.Clean(dir) {
+				return "a system directory"
+			}
+		}
+		if drive := os.Getenv("SystemDrive"); drive != "" && target == filepath.Join(drive+`\`, "Users") {
+			return "a system directory"
+		}
+		return ""
+	}
+
+	if slices.Contains(systemPaths, target) {
+		return "a system directory"
+	}
+	for _, dir := range pseudoFilesystems {
+		if target == dir || isWithin(dir, target) {
+			return "on a kernel filesystem"
+		}
+	}
+
+	return ""
+}
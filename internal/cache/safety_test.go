@@ -0,0 +1,100 @@
+//go:build !windows
+
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_UnsafePaths(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	setup := func() (cache.Mounter, *cache.ExecutorMock) {
+		exec := &cache.ExecutorMock{
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				return cache.MountBind, nil
+			},
+			StatFunc: func(name string) (os.FileInfo, error) {
+				return nil, os.ErrNotExist
+			},
+			MkdirAllFunc: func(path string, perm os.FileMode) error {
+				return nil
+			},
+			ReadFileFunc: func(name string) ([]byte, error) {
+				return nil, os.ErrNotExist
+			},
+			LockFunc: noLock,
+			WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+				return nil
+			},
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+		}
+		return cache.Mounter{DestructiveMode: true, CacheRoot: "/mnt/cache", Exec: exec}, exec
+	}
+
+	t.Run("refuses dangerous paths", func(t *testing.T) {
+		cases := map[string]string{
+			"/":             "a filesystem root",
+			"/usr":          "a system directory",
+			"/home/":        "a system directory",
+			"~":             "the home directory",
+			home:            "the home directory",
+			"/proc/self":    "on a kernel filesystem",
+			"/mnt":          "the cache root or holds it",
+			"/mnt/cache":    "the cache root or holds it",
+			"/usr/../etc/.": "a system directory",
+		}
+		for path, reason := range cases {
+			t.Run(path, func(t *testing.T) {
+				m, exec := setup()
+				result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{path}})
+				require.ErrorContains(t, err, reason)
+				require.Empty(t, exec.MountCalls())
+				require.Len(t, result.Output.Errors, 1)
+				require.Contains(t, result.Output.Errors[0].Message, reason)
+				require.False(t, result.Output.Errors[0].Recoverable)
+			})
+		}
+	})
+
+	t.Run("allows paths beneath system directories", func(t *testing.T) {
+		m, exec := setup()
+		result, err := m.Mount(t.Context(), cache.MountRequest{
+			ManualPaths: []string{"/var/cache/apt/archives", "/nix", "~/.cache/go-build"},
+		})
+		require.NoError(t, err)
+		require.Empty(t, result.Output.Errors)
+		require.Len(t, exec.MountCalls(), 3)
+	})
+
+	t.Run("allow unsafe paths", func(t *testing.T) {
+		m, exec := setup()
+		m.AllowUnsafePaths = true
+		result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"/usr"}})
+		require.NoError(t, err)
+		require.Empty(t, result.Output.Errors)
+		require.Len(t, exec.MountCalls(), 1)
+	})
+
+	t.Run("allowed roots", func(t *testing.T) {
+		m, exec := setup()
+		m.AllowedRoots = []string{"~", "/var/cache"}
+		result, err := m.Mount(t.Context(), cache.MountRequest{
+			ManualPaths: []string{"/var/cache/apt", filepath.Join(home, ".npm"), "/nix"},
+		})
+		require.ErrorContains(t, err, "outside the allowed roots")
+		require.Len(t, exec.MountCalls(), 2)
+		require.Len(t, result.Output.Errors, 1)
+		require.Contains(t, result.Output.Errors[0].Message, `"/nix" is outside the allowed roots`)
+	})
+}
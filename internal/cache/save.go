@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// SaveRequest configures the end-of-job save.
+type SaveRequest struct {
+	// Trim lists the modes whose caches are trimmed by their tools before
+	// saving. "*" trims every mounted mode that supports it.
+	Trim []string
+	// CompressAfter compresses the entries that are not mounted here and
+	// have not been used for longer than this, like PruneRequest does.
+	CompressAfter time.Duration
+	// Compression is how entries are compressed. Defaults to zstd.
+	Compression Compression
+	// Dedupe hard links identical files across all cache entries once they
+	// are saved, like Mounter.Dedupe.
+	Dedupe bool
+}
+
+type SaveResponse struct {
+	CacheRoot string      `json:"cache_root"`
+	DryRun    bool        `json:"dry_run"`
+	Total     DirSize     `json:"total"`
+	Hits      int         `json:"hits"`
+	Misses    int         `json:"misses"`
+	Trimmed   []string    `json:"trimmed,omitzero"`
+	Entries   []SaveEntry `json:"entries,omitzero"`
+	// Compressed lists the rarely used entries that were compressed.
+	Compressed []PruneEntry `json:"compressed,omitzero"`
+	// Dedupe is set when identical files were linked after saving.
+	Dedupe *DedupeResponse `json:"dedupe,omitzero"`
+}
+
+type SaveEntry struct {
+	Mode      string  `json:"mode,omitzero"`
+	CachePath string  `json:"cache_path"`
+	MountPath string  `json:"mount_path"`
+	CacheHit  bool    `json:"cache_hit"`
+	Size      DirSize `json:"size"`
+	Excluded  int     `json:"excluded,omitzero"` // files and directories dropped by exclude patterns
+	// Strategy is how the entry was mounted. Copies are saved back to the
+	// cache path.
+	Strategy MountStrategy `json:"strategy,omitzero"`
+}
+
+// Save finalizes the caches mounted by this job: it trims the requested
+// modes, copies mounted copies back to the cache, drops paths matching the
+// exclude patterns of each entry, flushes written data to the volume, and
+// records the final size and hit or miss of each mounted entry in the
+// metadata file. Overlays are not saved: their writes are discarded. Entries
+// recorded by other jobs, which are not mounted on this machine, are left
+// alone, unless they are compressed with CompressAfter or linked with Dedupe.
+// Without DestructiveMode it only reports on the mounted entries.
+func (m Mounter) Save(ctx context.Context, req SaveRequest) (SaveResponse, error) {
+	unlock, err := m.acquireRootLock(ctx)
+	if err != nil {
+		return SaveResponse{}, err
+	}
+	defer unlock()
+
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return SaveResponse{}, err
+	}
+
+	result := SaveResponse{
+		CacheRoot: m.CacheRoot,
+		DryRun:    !m.DestructiveMode,
+	}
+
+	result.Entries, err = m.mountedEntries(metadata)
+	if err != nil {
+		return SaveResponse{}, err
+	}
+
+	trimmers, err := m.trimmers(req.Trim, result.Entries)
+	if err != nil {
+		return SaveResponse{}, err
+	}
+	for name := range trimmers {
+		result.Trimmed = append(result.Trimmed, name)
+	}
+	slices.Sort(result.Trimmed)
+
+	if m.DestructiveMode {
+		for _, name := range result.Trimmed {
+			slog.Debug("trimming cache", slog.String("mode", name))
+
+			// A cache that could not be trimmed is still worth saving.
+			if err := trimmers[name].Trim(ctx, mode.TrimRequest{Exec: m.modeExec()}); err != nil {
+				slog.Warn(fmt.Sprintf("failed to trim %s cache", name), slog.Any("error", err))
+			}
+		}
+	}
+
+	for _, e := range result.Entries {
+		if e.Strategy != MountCopy {
+			continue
+		}
+		if !m.DestructiveMode {
+			slog.Debug("dry-run: would copy back", slog.String("from", e.MountPath), slog.String("to", e.CachePath))
+			continue
+		}
+		if err := m.copyBack(ctx, e.MountPath, e.CachePath); err != nil {
+			return SaveResponse{}, err
+		}
+	}
+
+	for i := range result.Entries {
+		e := &result.Entries[i]
+		// Overlays share their cache read-only, so it is left as is.
+		if e.Strategy == MountOverlay {
+			continue
+		}
+
+		excluded, err := excludedPaths(e.CachePath, metadata.UserRequest[e.MountPath].Exclude)
+		if err != nil {
+			return SaveResponse{}, err
+		}
+		e.Excluded = len(excluded)
+
+		for _, path := range excluded {
+			if !m.DestructiveMode {
+				slog.Debug("dry-run: would drop excluded path", slog.String("path", path))
+				continue
+			}
+			slog.Debug("dropping excluded path", slog.String("path", path))
+			if err := m.Exec.RemoveAll(path); err != nil {
+				return SaveResponse{}, fmt.Errorf("removing excluded %q: %w", path, err)
+			}
+		}
+	}
+
+	if m.DestructiveMode {
+		if err := m.Exec.Sync(ctx); err != nil {
+			return SaveResponse{}, fmt.Errorf("syncing cache volume: %w", err)
+		}
+	}
+
+	paths := make([]string, len(result.Entries))
+	for i, e := range result.Entries {
+		paths[i] = e.CachePath
+	}
+	sizes, err := dirSizes(ctx, paths, runtime.GOMAXPROCS(0))
+	if err != nil {
+		return SaveResponse{}, err
+	}
+
+	for i := range result.Entries {
+		e := &result.Entries[i]
+		e.Size = sizes[i]
+		result.Total.Bytes += e.Size.Bytes
+		result.Total.Files += e.Size.Files
+		if e.CacheHit {
+			result.Hits++
+		} else {
+			result.Misses++
+		}
+	}
+
+	if req.CompressAfter > 0 {
+		keep := make([]string, len(result.Entries))
+		for i, e := range result.Entries {
+			keep[i] = e.MountPath
+		}
+		pruned, err := m.prune(ctx, PruneRequest{CompressAfter: req.CompressAfter, Compression: req.Compression, Keep: keep, InUse: inUseWindow}, time.Now())
+		if err != nil {
+			return SaveResponse{}, err
+		}
+		result.Compressed = pruned.Compressed
+	}
+
+	if req.Dedupe {
+		deduped, err := m.dedupe(ctx, DedupeRequest{})
+		if err != nil {
+			return SaveResponse{}, err
+		}
+		result.Dedupe = &deduped
+	}
+
+	if !m.DestructiveMode || len(result.Entries) == 0 {
+		return result, nil
+	}
+
+	// Other jobs may have mounted caches since the metadata was read, so the
+	// stats are applied to the latest metadata.
+	savedAt := time.Now().UTC().Format(time.RFC3339)
+	err = m.modifyMetadata(ctx, func(metadata *CacheMetadata) error {
+		for _, e := range result.Entries {
+			entry, ok := metadata.UserRequest[e.MountPath]
+			if !ok {
+				continue
+			}
+			if entry.Stats.SavedAt != "" {
+				entry.Stats.GrowthBytes = e.Size.Bytes - entry.Stats.Bytes
+			}
+			entry.Stats.SavedAt = savedAt
+			entry.Stats.Bytes = e.Size.Bytes
+			entry.Stats.Files = e.Size.Files
+			if e.CacheHit {
+				entry.Stats.Hits++
+			} else {
+				entry.Stats.Misses++
+			}
+			metadata.UserRequest[e.MountPath] = entry
+		}
+		return nil
+	})
+	if err != nil {
+		return SaveResponse{}, err
+	}
+
+	return result, nil
+}
+
+// mountedEntries returns the entries of the metadata that are mounted on this
+// machine, sorted by mount path.
+func (m Mounter) mountedEntries(metadata CacheMetadata) ([]SaveEntry, error) {
+	var entries []SaveEntry
+	for mountPath, entry := range metadata.UserRequest {
+		mounted, err := m.isMounted(mountPath, entry)
+		if err != nil {
+			return nil, err
+		}
+		if !mounted {
+			continue
+		}
+
+		e := SaveEntry{CachePath: entry.Source, MountPath: mountPath, CacheHit: entry.CacheHit, Strategy: entry.Strategy}
+		if entry.CacheFramework != nil {
+			e.Mode = *entry.CacheFramework
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b SaveEntry) int {
+		return strings.Compare(a.MountPath, b.MountPath)
+	})
+	return entries, nil
+}
+
+// mountedModes returns the modes of entries, in order.
+func mountedModes(entries []SaveEntry) []string {
+	var mounted []string
+	for _, e := range entries {
+		if e.Mode != "" && !slices.Contains(mounted, e.Mode) {
+			mounted = append(mounted, e.Mode)
+		}
+	}
+	return mounted
+}
+
+// trimmers resolves the modes to trim among the mounted entries.
+func (m Mounter) trimmers(names []string, entries []SaveEntry) (map[string]mode.Trimmer, error) {
+	mounted := mountedModes(entries)
+
+	all := slices.Equal(names, []string{"*"})
+	if all {
+		names = mounted
+	}
+
+	modes, err := m.Modes.Filter(names)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmers := map[string]mode.Trimmer{}
+	for _, p := range modes {
+		t, ok := p.(mode.Trimmer)
+		if !ok {
+			if all {
+				continue
+			}
+			return nil, fmt.Errorf("mode %q does not support trimming", p.Name())
+		}
+		if slices.Contains(mounted, p.Name()) {
+			trimmers[p.Name()] = t
+		}
+	}
+	return trimmers, nil
+}
+
+// copyBack replaces the cache path with a copy of the mount path. The copy is
+// made next to the cache path first, so that a failed copy keeps the cache.
+func (m Mounter) copyBack(ctx context.Context, mountPath, cachePath string) error {
+	slog.Debug("copying back", slog.String("from", mountPath), slog.String("to", cachePath))
+
+	tmp := cachePath + ".saving"
+	if err := m.Exec.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("removing %q: %w", tmp, err)
+	}
+	if err := m.Exec.CopyDir(ctx, mountPath, tmp); err != nil {
+		return fmt.Errorf("copying %q back to the cache: %w", mountPath, err)
+	}
+	if err := m.Exec.RemoveAll(cachePath); err != nil {
+		return fmt.Errorf("removing %q: %w", cachePath, err)
+	}
+	if err := m.Exec.Rename(tmp, cachePath); err != nil {
+		return fmt.Errorf("moving %q to %q: %w", tmp, cachePath, err)
+	}
+	return nil
+}
+
+// isMounted reports whether the entry is mounted at mountPath. Bind mounts
+// and symlinks both make it resolve to the cache path itself, and copies
+// and overlays only need to exist.
+func (m Mounter) isMounted(mountPath string, entry CacheMetadataEntry) (bool, error) {
+	cachePath := entry.Source
+	cacheInfo, err := m.Exec.Stat(cachePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat cache path %q: %w", cachePath, err)
+	}
+
+	mountInfo, err := m.Exec.Stat(mountPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("stat mount path %q: %w", mountPath, err)
+	}
+
+	if entry.Strategy == MountCopy || entry.Strategy == MountOverlay {
+		return mountInfo.IsDir(), nil
+	}
+	return os.SameFile(cacheInfo, mountInfo), nil
+}
@@ -0,0 +1,236 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// trimmingProvider is a mode whose cache can be trimmed.
+type trimmingProvider struct {
+	*mode.ModeProviderMock
+	trimmed *int
+}
+
+func (p trimmingProvider) Trim(ctx context.Context, req mode.TrimRequest) error {
+	*p.trimmed++
+	return nil
+}
+
+func TestMounter_Save(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+
+	// setup mounts a go and an apt entry by symlink, and records a third
+	// entry from another job that is not mounted here.
+	setup := func(t *testing.T) (cache.Mounter, *cache.ExecutorMock, *int) {
+		cacheRoot := t.TempDir()
+		workDir := t.TempDir()
+
+		var trimmed int
+		exec := &cache.ExecutorMock{
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+			MkdirAllFunc:  os.MkdirAll,
+			ReadFileFunc:  os.ReadFile,
+			StatFunc:      os.Stat,
+			SyncFunc:      func(ctx context.Context) error { return nil },
+			LockFunc:      noLock,
+			WriteFileFunc: os.WriteFile,
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				if err := os.MkdirAll(from, 0o755); err != nil {
+					return "", err
+				}
+				return cache.MountSymlink, os.Symlink(from, to)
+			},
+		}
+		m := cache.Mounter{
+			DestructiveMode: true,
+			CacheRoot:       cacheRoot,
+			Exec:            exec,
+			Modes: mode.Modes{
+				trimmingProvider{&mode.ModeProviderMock{
+					NameFunc: func() string { return "go" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{filepath.Join(workDir, "go")}}, nil
+					},
+				}, &trimmed},
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "apt" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{MountPaths: []string{filepath.Join(workDir, "apt")}}, nil
+					},
+				},
+			},
+		}
+
+		// A previous job recorded a cache that this job does not mount.
+		rustMode := "rust"
+		data, err := json.Marshal(cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{
+			"/work/cargo": {CacheFramework: &rustMode, Source: t.TempDir()},
+		}})
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		// Only the go cache exists before mounting.
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, cache.RootSubpath(workDir), "go"), 0o755))
+
+		_, err = m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go", "apt"}})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "go", "a"), []byte("hello"), 0o644))
+
+		return m, exec, &trimmed
+	}
+
+	t.Run("records sizes and hit stats of mounted entries", func(t *testing.T) {
+		m, exec, trimmed := setup(t)
+
+		result, err := m.Save(t.Context(), cache.SaveRequest{Trim: []string{"*"}})
+		require.NoError(t, err)
+		require.Len(t, result.Entries, 2)
+		require.Equal(t, 1, result.Hits)
+		require.Equal(t, 1, result.Misses)
+		require.Equal(t, cache.DirSize{Bytes: 5, Files: 1}, result.Total)
+		require.Equal(t, []string{"go"}, result.Trimmed)
+		require.Equal(t, 1, *trimmed)
+		require.Len(t, exec.SyncCalls(), 1)
+
+		// The go cache grows before the next save.
+		goEntry := result.Entries[slices.IndexFunc(result.Entries, func(e cache.SaveEntry) bool { return e.Mode == "go" })]
+		require.NoError(t, os.WriteFile(filepath.Join(goEntry.MountPath, "b"), []byte("abc"), 0o644))
+		_, err = m.Save(t.Context(), cache.SaveRequest{})
+		require.NoError(t, err)
+
+		metadata, err := cache.ReadMetadata(m.Exec, m.CacheRoot)
+		require.NoError(t, err)
+		require.Len(t, metadata.UserRequest, 3)
+		for _, entry := range metadata.UserRequest {
+			switch *entry.CacheFramework {
+			case "go":
+				require.Equal(t, int64(8), entry.Stats.Bytes)
+				require.Equal(t, int64(3), entry.Stats.GrowthBytes)
+				require.Equal(t, 2, entry.Stats.Hits)
+				require.NotEmpty(t, entry.Stats.SavedAt)
+			case "apt":
+				require.Equal(t, 2, entry.Stats.Misses)
+			case "rust":
+				require.Zero(t, entry.Stats)
+			}
+		}
+
+		// Stats survive the next mount, which estimates that the go cache
+		// grows as much as it did last time.
+		for mountPath := range metadata.UserRequest {
+			os.Remove(mountPath)
+		}
+		mounted, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go", "apt"}})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), mounted.Output.EstimatedGrowthBytes)
+		for _, mount := range mounted.Output.Mounts {
+			require.True(t, mount.CacheHit)
+			if mount.Mode == "go" {
+				require.Equal(t, int64(3), mount.EstimatedGrowthBytes)
+			}
+		}
+		metadata, err = cache.ReadMetadata(m.Exec, m.CacheRoot)
+		require.NoError(t, err)
+		for _, entry := range metadata.UserRequest {
+			if *entry.CacheFramework == "apt" {
+				require.True(t, entry.CacheHit)
+				require.Equal(t, 2, entry.Stats.Misses)
+			}
+		}
+	})
+
+	t.Run("dry run records nothing", func(t *testing.T) {
+		m, exec, trimmed := setup(t)
+		m.DestructiveMode = false
+
+		result, err := m.Save(t.Context(), cache.SaveRequest{Trim: []string{"go"}})
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Equal(t, []string{"go"}, result.Trimmed)
+		require.Zero(t, *trimmed)
+		require.Empty(t, exec.SyncCalls())
+
+		metadata, err := cache.ReadMetadata(m.Exec, m.CacheRoot)
+		require.NoError(t, err)
+		for _, entry := range metadata.UserRequest {
+			require.Zero(t, entry.Stats)
+		}
+	})
+
+	t.Run("rejects modes that cannot be trimmed", func(t *testing.T) {
+		m, _, _ := setup(t)
+
+		_, err := m.Save(t.Context(), cache.SaveRequest{Trim: []string{"apt"}})
+		require.ErrorContains(t, err, `mode "apt" does not support trimming`)
+	})
+}
+
+func TestMounter_SaveCopies(t *testing.T) {
+	cacheRoot := t.TempDir()
+	mountPath := filepath.Join(t.TempDir(), "target")
+	copyDir := func(ctx context.Context, from, to string) error {
+		return os.CopyFS(to, os.DirFS(from))
+	}
+
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       cacheRoot,
+		Strategy:        cache.MountCopy,
+		Exec: &cache.ExecutorMock{
+			CopyDirFunc: copyDir,
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, nil
+			},
+			MkdirAllFunc:  os.MkdirAll,
+			ReadFileFunc:  os.ReadFile,
+			RemoveAllFunc: os.RemoveAll,
+			RenameFunc:    os.Rename,
+			StatFunc:      os.Stat,
+			SyncFunc:      func(ctx context.Context) error { return nil },
+			LockFunc:      noLock,
+			WriteFileFunc: os.WriteFile,
+			MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+				require.Equal(t, cache.MountCopy, strategy)
+				if err := os.MkdirAll(from, 0o755); err != nil {
+					return "", err
+				}
+				return strategy, copyDir(ctx, from, to)
+			},
+		},
+		Modes: mode.Modes{},
+	}
+
+	result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{mountPath}})
+	require.NoError(t, err)
+	require.Equal(t, cache.MountCopy, result.Output.Mounts[0].Strategy)
+	cachePath := result.Output.Mounts[0].CachePath
+
+	require.NoError(t, os.WriteFile(filepath.Join(mountPath, "a"), []byte("hello"), 0o644))
+	require.NoFileExists(t, filepath.Join(cachePath, "a"))
+
+	saved, err := m.Save(t.Context(), cache.SaveRequest{})
+	require.NoError(t, err)
+	require.Len(t, saved.Entries, 1)
+	require.Equal(t, cache.MountCopy, saved.Entries[0].Strategy)
+	require.Equal(t, cache.DirSize{Bytes: 5, Files: 1}, saved.Total)
+
+	data, err := os.ReadFile(filepath.Join(cachePath, "a"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	require.NoDirExists(t, cachePath+".saving")
+}
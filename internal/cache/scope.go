@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ScopesDir holds the caches of every scope other than the default one,
+// which uses the cache root directly.
+const ScopesDir = "scopes"
+
+// AutoScope detects the scope from the current branch.
+const AutoScope = "auto"
+
+var unsafeScopeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ScopeDir returns the directory name of a scope. Names that are not safe
+// as a path element, like "feature/x", are sanitized and suffixed with a
+// hash of the original so that they cannot collide.
+func ScopeDir(scope string) string {
+	dir := unsafeScopeChars.ReplaceAllString(scope, "-")
+	if dir == scope && dir != "." && dir != ".." {
+		return dir
+	}
+	sum := sha256.Sum256([]byte(scope))
+	hash := hex.EncodeToString(sum[:4])
+	if dir = strings.Trim(dir, ".-"); dir == "" {
+		return hash
+	}
+	return dir + "-" + hash
+}
+
+// scopeRoot returns the directory that holds the caches of a scope.
+func (m Mounter) scopeRoot(scope string) string {
+	if scope == "" || scope == m.DefaultScope {
+		return m.CacheRoot
+	}
+	return filepath.Join(m.CacheRoot, ScopesDir, ScopeDir(scope))
+}
+
+// DetectScope returns the branch being built, preferring what the CI
+// system reports over the git checkout, which is often a detached HEAD.
+// It returns an empty scope when no branch can be determined.
+func DetectScope(ctx context.Context) string {
+	for _, env := range []string{
+		"GITHUB_HEAD_REF", // pull requests
+		"GITHUB_REF_NAME",
+		"CI_COMMIT_REF_NAME", // GitLab
+		"BUILDKITE_BRANCH",
+		"CIRCLE_BRANCH",
+	} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+
+	out, err := run(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return ""
+	}
+	if branch := strings.TrimSpace(string(out)); branch != "HEAD" {
+		return branch
+	}
+	return ""
+}
+
+// DetectDefaultScope returns the repository's default branch, falling back
+// to "main".
+func DetectDefaultScope(ctx context.Context) string {
+	for _, env := range []string{
+		"CI_DEFAULT_BRANCH", // GitLab
+		"BUILDKITE_PIPELINE_DEFAULT_BRANCH",
+	} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+
+	out, err := run(ctx, "git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err == nil {
+		if _, branch, ok := strings.Cut(strings.TrimSpace(string(out)), "/"); ok && branch != "" {
+			return branch
+		}
+	}
+	return "main"
+}
@@ -0,0 +1,160 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func TestScopeDir(t *testing.T) {
+	require.Equal(t, "main", cache.ScopeDir("main"))
+	require.Equal(t, "release-1.2", cache.ScopeDir("release-1.2"))
+
+	sanitized := cache.ScopeDir("feature/x")
+	require.Regexp(t, `^feature-x-[0-9a-f]{8}$`, sanitized)
+	require.NotEqual(t, sanitized, cache.ScopeDir("feature-x"))
+	require.NotEqual(t, sanitized, cache.ScopeDir("feature:x"))
+
+	require.Regexp(t, `^[0-9a-f]{8}$`, cache.ScopeDir(".."))
+}
+
+func TestMounter_Scoped(t *testing.T) {
+	cacheRoot := t.TempDir()
+	mountPath := t.TempDir()
+
+	newMounter := func(scope string) cache.Mounter {
+		return cache.Mounter{
+			DestructiveMode: true,
+			CacheRoot:       cacheRoot,
+			Scope:           scope,
+			DefaultScope:    "main",
+			Exec: &cache.ExecutorMock{
+				CopyDirFunc: func(ctx context.Context, from, to string) error {
+					return os.CopyFS(to, os.DirFS(from))
+				},
+				DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+					return cache.DiskUsage{}, nil
+				},
+				MkdirAllFunc: os.MkdirAll,
+				MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+					return cache.MountBind, os.MkdirAll(from, 0o755)
+				},
+				ReadFileFunc:  os.ReadFile,
+				RemoveAllFunc: os.RemoveAll,
+				RenameFunc:    os.Rename,
+				StatFunc:      os.Stat,
+				LockFunc:      noLock,
+				WriteFileFunc: os.WriteFile,
+			},
+			Modes: mode.Modes{
+				&mode.ModeProviderMock{
+					NameFunc: func() string { return "pnpm" },
+					PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+						return mode.PlanResult{
+							AddEnvs:    map[string]string{"STORE": filepath.Join(req.CacheRoot, "pnpm-store")},
+							CacheDirs:  []string{"pnpm-store"},
+							MountPaths: []string{mountPath},
+						}, nil
+					},
+				},
+			},
+		}
+	}
+
+	mount := func(t *testing.T, scope string) cache.MountResponse {
+		result, err := newMounter(scope).Mount(t.Context(), cache.MountRequest{ManualModes: []string{"pnpm"}})
+		require.NoError(t, err)
+		return result
+	}
+
+	// The default scope uses the cache root as before.
+	base := mount(t, "main")
+	require.Equal(t, filepath.Join(cacheRoot, "pnpm-store"), base.Output.AddEnvs["STORE"])
+	require.Equal(t, filepath.Join(cacheRoot, cache.RootSubpath(mountPath)), base.Output.Mounts[1].CachePath)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, "pnpm-store", "index"), []byte("main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(base.Output.Mounts[1].CachePath, "file"), []byte("main"), 0o644))
+
+	t.Run("other scopes are seeded from the default scope", func(t *testing.T) {
+		result := mount(t, "feature/x")
+
+		scopeRoot := filepath.Join(cacheRoot, cache.ScopesDir, cache.ScopeDir("feature/x"))
+		require.Equal(t, "feature/x", result.Output.Scope)
+		require.Equal(t, filepath.Join(scopeRoot, "pnpm-store"), result.Output.AddEnvs["STORE"])
+
+		for _, m := range result.Output.Mounts {
+			require.True(t, filepath.IsAbs(m.RestoredFrom))
+			require.False(t, m.CacheHit)
+			require.Contains(t, m.CachePath, scopeRoot)
+		}
+		require.FileExists(t, filepath.Join(scopeRoot, "pnpm-store", "index"))
+
+		// Writes to the scope do not affect the default scope.
+		require.NoError(t, os.WriteFile(filepath.Join(scopeRoot, "pnpm-store", "index"), []byte("feature"), 0o644))
+		data, err := os.ReadFile(filepath.Join(cacheRoot, "pnpm-store", "index"))
+		require.NoError(t, err)
+		require.Equal(t, "main", string(data))
+
+		again := mount(t, "feature/x")
+		require.True(t, again.Output.Mounts[0].CacheHit)
+		require.Empty(t, again.Output.Mounts[0].RestoredFrom)
+	})
+
+	t.Run("relative paths are seeded from the default scope", func(t *testing.T) {
+		mountRelative := func(scope string) cache.MountResponse {
+			m := newMounter(scope)
+			m.ProjectDir = t.TempDir()
+			result, err := m.Mount(t.Context(), cache.MountRequest{ManualPaths: []string{"node_modules"}})
+			require.NoError(t, err)
+			require.Len(t, result.Output.Mounts, 1)
+			return result
+		}
+
+		base := mountRelative("main")
+		require.NoError(t, os.WriteFile(filepath.Join(base.Output.Mounts[0].CachePath, "file"), []byte("main"), 0o644))
+
+		result := mountRelative("feature/y")
+		require.Equal(t, base.Output.Mounts[0].CachePath, result.Output.Mounts[0].RestoredFrom)
+		require.FileExists(t, filepath.Join(result.Output.Mounts[0].CachePath, "file"))
+	})
+
+	t.Run("interrupted restores are not taken for caches", func(t *testing.T) {
+		m := newMounter("feature/z")
+		exec := m.Exec.(*cache.ExecutorMock)
+		copyDir := exec.CopyDirFunc
+		exec.CopyDirFunc = func(ctx context.Context, from, to string) error {
+			require.NoError(t, os.MkdirAll(to, 0o755))
+			return errors.New("no space left on device")
+		}
+		_, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"pnpm"}})
+		require.ErrorContains(t, err, "no space left on device")
+
+		scopeRoot := filepath.Join(cacheRoot, cache.ScopesDir, cache.ScopeDir("feature/z"))
+		require.NoDirExists(t, filepath.Join(scopeRoot, "pnpm-store"))
+		require.NoDirExists(t, filepath.Join(scopeRoot, "pnpm-store.restoring"))
+
+		exec.CopyDirFunc = copyDir
+		result := mount(t, "feature/z")
+		require.False(t, result.Output.Mounts[0].CacheHit)
+		require.FileExists(t, filepath.Join(scopeRoot, "pnpm-store", "index"))
+	})
+
+	t.Run("clean deletes a scope", func(t *testing.T) {
+		m := newMounter("")
+
+		result, err := m.Clean(t.Context(), cache.CleanRequest{Scopes: []string{"feature/x"}})
+		require.NoError(t, err)
+		require.Len(t, result.Deleted, 1)
+		require.NoDirExists(t, filepath.Join(cacheRoot, cache.ScopesDir, cache.ScopeDir("feature/x")))
+		require.DirExists(t, filepath.Join(cacheRoot, "pnpm-store"))
+
+		_, err = m.Clean(t.Context(), cache.CleanRequest{Scopes: []string{"main"}})
+		require.ErrorContains(t, err, "refusing to delete default scope")
+	})
+}
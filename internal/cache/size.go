@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DirSize is the apparent size of a directory tree.
+type DirSize struct {
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+}
+
+// dirSize sums the sizes of all regular files beneath path. Symlinks are not
+// followed, so links into other caches are not counted twice.
+func dirSize(path string) (DirSize, error) {
+	size, _, err := dirUsage(path)
+	return size, err
+}
+
+// dirUsage is like dirSize, but also reports when anything beneath path was
+// last read or written. Filesystems mounted with noatime only track writes.
+func dirUsage(path string) (DirSize, time.Time, error) {
+	var size DirSize
+	var lastUsed time.Time
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		lastUsed = latest(lastUsed, info.ModTime())
+		if d.IsDir() {
+			// Walking reads directories, which would bump their access time.
+			return nil
+		}
+
+		lastUsed = latest(lastUsed, accessTime(info))
+		size.Bytes += info.Size()
+		size.Files++
+		return nil
+	})
+	if err != nil {
+		return DirSize{}, time.Time{}, fmt.Errorf("walking %q: %w", path, err)
+	}
+	return size, lastUsed, nil
+}
+
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, c := range times {
+		if c.After(t) {
+			t = c
+		}
+	}
+	return t
+}
+
+func humanizeBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	val := float64(b) / float64(div)
+	suffix := []string{"K", "M", "G", "T", "P", "E"}[exp]
+	if val < 10 {
+		return fmt.Sprintf("%.1f%s", val, suffix)
+	}
+	return fmt.Sprintf("%.0f%s", val, suffix)
+}
+
+// ParseSize parses a size such as "512M" or "10GiB" in the binary units that
+// humanizeBytes prints. A plain number is a count of bytes.
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+	if i := strings.IndexAny(str, "KMGTPE"); i >= 0 && i == len(str)-1 {
+		multiplier = int64(1) << (10 * (strings.IndexByte("KMGTPE", str[i]) + 1))
+		str = str[:i]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatSize formats a byte count in the same units as DiskUsage.
+func FormatSize(b int64) string {
+	return humanizeBytes(uint64(max(b, 0)))
+}
+
+// dirSizes sizes several directory trees at once. Subdirectories are handed
+// to idle workers as they are found, so a single large tree is walked in
+// parallel too. Files that disappear during the walk are skipped, as caches
+// may be in use while they are sized.
+func dirSizes(ctx context.Context, paths []string, workers int) ([]DirSize, error) {
+	type counter struct {
+		bytes, files atomic.Int64
+	}
+	counters := make([]counter, len(paths))
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(workers, 1))
+
+	var walk func(dir string, c *counter) error
+	walk = func(dir string, c *counter) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("reading %q: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			switch {
+			case e.IsDir():
+				if !eg.TryGo(func() error { return walk(path, c) }) {
+					if err := walk(path, c); err != nil {
+						return err
+					}
+				}
+			case e.Type().IsRegular():
+				info, err := e.Info()
+				if err != nil {
+					if errors.Is(err, os.ErrNotExist) {
+						continue
+					}
+					return fmt.Errorf("stat %q: %w", path, err)
+				}
+				c.bytes.Add(info.Size())
+				c.files.Add(1)
+			}
+		}
+		return nil
+	}
+
+	for i, path := range paths {
+		c := &counters[i]
+		eg.Go(func() error {
+			info, err := os.Lstat(path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return fmt.Errorf("stat %q: %w", path, err)
+			}
+			if !info.IsDir() {
+				if info.Mode().IsRegular() {
+					c.bytes.Add(info.Size())
+					c.files.Add(1)
+				}
+				return nil
+			}
+			return walk(path, c)
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	sizes := make([]DirSize, len(paths))
+	for i := range counters {
+		sizes[i] = DirSize{Bytes: counters[i].bytes.Load(), Files: int(counters[i].files.Load())}
+	}
+	return sizes, nil
+}
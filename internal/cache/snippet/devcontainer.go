@@ -0,0 +1,237 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// DevcontainerFile is where devcontainers are configured, relative to
+	// the repository.
+	DevcontainerFile = ".devcontainer/devcontainer.json"
+
+	// devcontainerVolume is the Docker volume that holds the caches of all
+	// devcontainers on a machine, as the cache volume does for CI jobs.
+	devcontainerVolume    = "spacectl-cache"
+	devcontainerCacheRoot = "/var/cache/spacectl"
+	devcontainerImage     = "mcr.microsoft.com/devcontainers/base:ubuntu"
+	devcontainerEvalFile  = "$HOME/.spacectl-cache.env"
+)
+
+// devcontainer patches existing, a devcontainer.json that may be empty, to
+// mount the cache volume and mount the caches when the container is
+// created. Top-level keys keep their order, and settings that are already
+// there are kept.
+func devcontainer(existing []byte, req Request) (string, error) {
+	config, err := parseObject(existing)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", DevcontainerFile, err)
+	}
+	if len(config.keys) == 0 {
+		config.set("image", devcontainerImage)
+	}
+
+	var mounts []any
+	if err := config.get("mounts", &mounts); err != nil {
+		return "", err
+	}
+	mount := fmt.Sprintf("source=%s,target=%s,type=volume", devcontainerVolume, devcontainerCacheRoot)
+	if !slices.ContainsFunc(mounts, mountsCacheRoot) {
+		mounts = append(mounts, mount)
+	}
+	config.set("mounts", mounts)
+
+	env := map[string]string{}
+	if err := config.get("containerEnv", &env); err != nil {
+		return "", err
+	}
+	env["NSC_CACHE_PATH"] = devcontainerCacheRoot
+	config.set("containerEnv", env)
+
+	// postCreateCommand takes a shell command, a command without a shell,
+	// or named commands that run in parallel.
+	command := mountCommand(req, req.Modes, []string{"--dry_run=false", "--eval_file=" + devcontainerEvalFile}) +
+		fmt.Sprintf(" && echo '. %s' >> ~/.bashrc", devcontainerEvalFile)
+	var postCreate any
+	if err := config.get("postCreateCommand", &postCreate); err != nil {
+		return "", err
+	}
+	switch existing := postCreate.(type) {
+	case nil:
+		config.set("postCreateCommand", command)
+	case string:
+		if !strings.Contains(existing, "spacectl cache mount") {
+			config.set("postCreateCommand", command+" && "+existing)
+		}
+	case map[string]any:
+		if _, ok := existing["spacectl-cache"]; !ok {
+			existing["spacectl-cache"] = command
+			config.set("postCreateCommand", existing)
+		}
+	default:
+		return "", fmt.Errorf("postCreateCommand of %s runs without a shell, add %q to it by hand", DevcontainerFile, command)
+	}
+
+	return config.encode()
+}
+
+// mountsCacheRoot reports whether a devcontainer mount, given as a string or
+// an object, already mounts the cache root.
+func mountsCacheRoot(mount any) bool {
+	switch mount := mount.(type) {
+	case string:
+		for field := range strings.SplitSeq(mount, ",") {
+			key, value, _ := strings.Cut(field, "=")
+			if (key == "target" || key == "destination" || key == "dst") && filepath.Clean(value) == devcontainerCacheRoot {
+				return true
+			}
+		}
+	case map[string]any:
+		return mount["target"] == devcontainerCacheRoot
+	}
+	return false
+}
+
+// object is a JSON object that keeps the order of its keys.
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func parseObject(data []byte) (*object, error) {
+	o := &object{values: map[string]json.RawMessage{}}
+	data, _ = stripJSONC(data)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return o, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		o.set(tok.(string), value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *object) get(key string, v any) error {
+	raw, ok := o.values[key]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("parsing %s of %s: %w", key, DevcontainerFile, err)
+	}
+	return nil
+}
+
+func (o *object) set(key string, v any) {
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		raw = marshal(v)
+	}
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = raw
+}
+
+func (o *object) encode() (string, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.Write(marshal(key))
+		b.WriteString(":")
+		b.Write(o.values[key])
+	}
+	b.WriteString("}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b.Bytes(), "", "  "); err != nil {
+		return "", fmt.Errorf("encoding %s: %w", DevcontainerFile, err)
+	}
+	out.WriteString("\n")
+	return out.String(), nil
+}
+
+// marshal encodes v without escaping the shell operators in commands.
+func marshal(v any) json.RawMessage {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// HasComments reports whether a devcontainer.json has comments, which are
+// dropped when it is patched.
+func HasComments(data []byte) bool {
+	_, comments := stripJSONC(data)
+	return comments
+}
+
+// stripJSONC turns the JSON with comments that devcontainer.json is written
+// in into plain JSON, by dropping comments and trailing commas.
+func stripJSONC(data []byte) ([]byte, bool) {
+	var out []byte
+	comments := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			// Copy strings as is, with their escapes.
+			start := i
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			out = append(out, data[start:min(i+1, len(data))]...)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			comments = true
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			comments = true
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		case c == ']' || c == '}':
+			// Drop a trailing comma before the closing bracket.
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, comments
+}
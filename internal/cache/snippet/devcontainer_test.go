@@ -0,0 +1,78 @@
+package snippet_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/snippet"
+)
+
+func TestGenerate_Devcontainer(t *testing.T) {
+	t.Run("new file", func(t *testing.T) {
+		got, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Modes: []string{"go", "npm"}})
+		require.NoError(t, err)
+		require.Equal(t, `{
+  "image": "mcr.microsoft.com/devcontainers/base:ubuntu",
+  "mounts": [
+    "source=spacectl-cache,target=/var/cache/spacectl,type=volume"
+  ],
+  "containerEnv": {
+    "NSC_CACHE_PATH": "/var/cache/spacectl"
+  },
+  "postCreateCommand": "spacectl cache mount --mode=go,npm --dry_run=false --eval_file=$HOME/.spacectl-cache.env && echo '. $HOME/.spacectl-cache.env' >> ~/.bashrc"
+}
+`, got)
+	})
+
+	t.Run("patches existing file", func(t *testing.T) {
+		existing := []byte(`// For format details, see https://aka.ms/devcontainer.json.
+{
+	"name": "app", /* the project */
+	"build": {"dockerfile": "Dockerfile"},
+	"containerEnv": {"FOO": "http://bar"},
+	"postCreateCommand": "make setup",
+}`)
+		require.True(t, snippet.HasComments(existing))
+
+		got, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Existing: existing})
+		require.NoError(t, err)
+		require.Equal(t, `{
+  "name": "app",
+  "build": {
+    "dockerfile": "Dockerfile"
+  },
+  "containerEnv": {
+    "FOO": "http://bar",
+    "NSC_CACHE_PATH": "/var/cache/spacectl"
+  },
+  "postCreateCommand": "spacectl cache mount --detect='*' --dry_run=false --eval_file=$HOME/.spacectl-cache.env && echo '. $HOME/.spacectl-cache.env' >> ~/.bashrc && make setup",
+  "mounts": [
+    "source=spacectl-cache,target=/var/cache/spacectl,type=volume"
+  ]
+}
+`, got)
+		require.False(t, snippet.HasComments([]byte(got)))
+
+		again, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Existing: []byte(got)})
+		require.NoError(t, err)
+		require.Equal(t, got, again)
+	})
+
+	t.Run("named commands", func(t *testing.T) {
+		got, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Existing: []byte(`{"postCreateCommand": {"setup": "make setup"}}`)})
+		require.NoError(t, err)
+		require.Contains(t, got, `"spacectl-cache": "spacectl cache mount --detect='*'`)
+		require.Contains(t, got, `"setup": "make setup"`)
+	})
+
+	t.Run("command without a shell", func(t *testing.T) {
+		_, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Existing: []byte(`{"postCreateCommand": ["make", "setup"]}`)})
+		require.ErrorContains(t, err, "runs without a shell")
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := snippet.Generate(snippet.Request{Target: snippet.Devcontainer, Existing: []byte(`[]`)})
+		require.ErrorContains(t, err, "expected an object")
+	})
+}
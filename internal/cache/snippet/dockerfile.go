@@ -0,0 +1,191 @@
+package snippet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// ContainerHome is the home directory of root, which container builds run
+// as unless the Dockerfile sets a USER.
+const ContainerHome = "/root"
+
+// CacheMount is a BuildKit cache mount of a planned cache path.
+type CacheMount struct {
+	Mode   string `json:"mode"`
+	Target string `json:"target"`
+	// Sharing is set for tools that cannot share their cache with
+	// concurrent builds.
+	Sharing string `json:"sharing,omitzero"`
+}
+
+// Directive returns the flag of a RUN instruction that mounts the cache.
+func (m CacheMount) Directive() string {
+	d := "--mount=type=cache,target=" + m.Target
+	if m.Sharing != "" {
+		d += ",sharing=" + m.Sharing
+	}
+	return d
+}
+
+// SkippedMount is a planned path that is not mounted in container builds.
+type SkippedMount struct {
+	Mode   string `json:"mode,omitzero"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// CacheMounts translates the mounts of plan into BuildKit cache mounts.
+// Paths in home are moved to containerHome. Paths in the project, like
+// node_modules, are skipped, as cache mounts are left out of the image.
+func CacheMounts(plan cache.Plan, home, containerHome string) ([]CacheMount, []SkippedMount) {
+	var mounts []CacheMount
+	var skipped []SkippedMount
+	for _, m := range plan.Mounts {
+		if m.CacheDir {
+			continue
+		}
+
+		target := m.Path
+		switch {
+		case !filepath.IsAbs(target) || plan.ProjectDir != "" && isWithin(plan.ProjectDir, target):
+			skipped = append(skipped, SkippedMount{Mode: m.Mode, Path: m.Path, Reason: "in the project, which cache mounts would leave out of the image"})
+			continue
+		case home != "" && isWithin(home, target):
+			rel, _ := filepath.Rel(home, target)
+			target = path.Join(containerHome, filepath.ToSlash(rel))
+		default:
+			target = path.Clean(filepath.ToSlash(target))
+		}
+
+		mount := CacheMount{Mode: m.Mode, Target: target}
+		// apt locks its caches, and fails when another build holds them.
+		if m.Mode == "apt" {
+			mount.Sharing = "locked"
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, skipped
+}
+
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// DockerfileChange adds cache mounts to a RUN instruction of a Dockerfile.
+type DockerfileChange struct {
+	// Line is the first line of the instruction, from 1.
+	Line   int      `json:"line"`
+	Modes  []string `json:"modes"`
+	Before string   `json:"before"`
+	After  string   `json:"after"`
+}
+
+// AnnotateDockerfile finds the RUN instructions of a Dockerfile that run the
+// tools of mounted modes, and adds the cache mounts of those modes that they
+// lack. Tools are told by the mode name, like go, and by the binaries the
+// mode checked while planning, including names that start with them, like
+// apt-get for apt.
+func AnnotateDockerfile(r io.Reader, plan cache.Plan, mounts []CacheMount) ([]DockerfileChange, error) {
+	var changes []DockerfileChange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	line, start := 0, 0
+	var instruction []string
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if len(instruction) == 0 {
+			start = line
+		}
+		instruction = append(instruction, text)
+		// Instructions continue on the next line after a backslash.
+		if strings.HasSuffix(strings.TrimRight(text, " \t"), `\`) {
+			continue
+		}
+
+		if change, ok := annotateRun(instruction, plan, mounts); ok {
+			change.Line = start
+			changes = append(changes, change)
+		}
+		instruction = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading Dockerfile: %w", err)
+	}
+	return changes, nil
+}
+
+func annotateRun(lines []string, plan cache.Plan, mounts []CacheMount) (DockerfileChange, bool) {
+	first := strings.TrimLeft(lines[0], " \t")
+	keyword, rest, _ := strings.Cut(first, " ")
+	if !strings.EqualFold(keyword, "RUN") {
+		return DockerfileChange{}, false
+	}
+
+	words := strings.FieldsFunc(strings.Join(lines, " "), func(r rune) bool {
+		return strings.ContainsRune(" \t\\;&|()[]\",", r)
+	})
+
+	var modes, directives []string
+	for _, m := range mounts {
+		if !slices.ContainsFunc(words, func(w string) bool { return runsTool(w, m.Mode, plan.Checks[m.Mode]) }) {
+			continue
+		}
+		if slices.ContainsFunc(words, func(w string) bool { return mountsTarget(w, m.Target) }) {
+			continue
+		}
+		if !slices.Contains(modes, m.Mode) {
+			modes = append(modes, m.Mode)
+		}
+		directives = append(directives, m.Directive())
+	}
+	if len(directives) == 0 {
+		return DockerfileChange{}, false
+	}
+
+	indent := lines[0][:len(lines[0])-len(first)]
+	after := slices.Clone(lines)
+	after[0] = indent + keyword + " " + strings.Join(directives, " ") + " " + rest
+	return DockerfileChange{
+		Modes:  modes,
+		Before: strings.Join(lines, "\n"),
+		After:  strings.Join(after, "\n"),
+	}, true
+}
+
+// mountsTarget reports whether an option of a --mount flag mounts target.
+func mountsTarget(option, target string) bool {
+	key, value, ok := strings.Cut(option, "=")
+	return ok && (key == "target" || key == "dst" || key == "destination") && path.Clean(value) == target
+}
+
+func runsTool(word, modeName string, checks []mode.DetectCheck) bool {
+	// Flags and their options, like the targets of mounts, run nothing.
+	if strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return false
+	}
+	word = path.Base(word)
+	tools := []string{modeName}
+	for _, c := range checks {
+		switch c.Kind {
+		case mode.CheckBinary:
+			tools = append(tools, path.Base(filepath.ToSlash(c.Name)))
+		case mode.CheckCommand:
+			if name, _, _ := strings.Cut(c.Name, " "); name != "" {
+				tools = append(tools, path.Base(filepath.ToSlash(name)))
+			}
+		}
+	}
+	return slices.ContainsFunc(tools, func(tool string) bool {
+		return word == tool || strings.HasPrefix(word, tool+"-")
+	})
+}
@@ -0,0 +1,79 @@
+package snippet_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+	"github.com/namespacelabs/spacectl/internal/cache/snippet"
+)
+
+func TestCacheMounts(t *testing.T) {
+	plan := cache.Plan{
+		ProjectDir: "/work",
+		Mounts: []cache.PlannedMount{
+			{Mode: "apt", Path: "/var/cache/apt/archives"},
+			{Mode: "go", Path: "/home/alice/.cache/go-build"},
+			{Mode: "go", Path: "/home/alice/go/pkg/mod"},
+			{Mode: "npm", Path: "/work/node_modules"},
+			{Mode: "pnpm", Path: "pnpm-store", CacheDir: true},
+		},
+	}
+
+	mounts, skipped := snippet.CacheMounts(plan, "/home/alice", snippet.ContainerHome)
+	require.Equal(t, []snippet.CacheMount{
+		{Mode: "apt", Target: "/var/cache/apt/archives", Sharing: "locked"},
+		{Mode: "go", Target: "/root/.cache/go-build"},
+		{Mode: "go", Target: "/root/go/pkg/mod"},
+	}, mounts)
+	require.Equal(t, []snippet.SkippedMount{
+		{Mode: "npm", Path: "/work/node_modules", Reason: "in the project, which cache mounts would leave out of the image"},
+	}, skipped)
+	require.Equal(t, "--mount=type=cache,target=/var/cache/apt/archives,sharing=locked", mounts[0].Directive())
+}
+
+func TestAnnotateDockerfile(t *testing.T) {
+	plan := cache.Plan{Checks: map[string][]mode.DetectCheck{
+		"apt": {{Kind: mode.CheckCommand, Name: "apt-config dump", Found: true}},
+	}}
+	mounts := []snippet.CacheMount{
+		{Mode: "apt", Target: "/var/cache/apt/archives", Sharing: "locked"},
+		{Mode: "go", Target: "/root/.cache/go-build"},
+		{Mode: "go", Target: "/root/go/pkg/mod"},
+	}
+
+	dockerfile := `FROM golang:1.26
+RUN apt-get update && \
+    apt-get install -y git
+COPY . .
+RUN --mount=type=cache,target=/root/go/pkg/mod go mod download
+  run CGO_ENABLED=0 /usr/local/go/bin/go build ./...
+RUN --mount=type=cache,target=/root/.cache/go-build echo done
+`
+
+	changes, err := snippet.AnnotateDockerfile(strings.NewReader(dockerfile), plan, mounts)
+	require.NoError(t, err)
+	require.Equal(t, []snippet.DockerfileChange{
+		{
+			Line:   2,
+			Modes:  []string{"apt"},
+			Before: "RUN apt-get update && \\\n    apt-get install -y git",
+			After:  "RUN --mount=type=cache,target=/var/cache/apt/archives,sharing=locked apt-get update && \\\n    apt-get install -y git",
+		},
+		{
+			Line:   5,
+			Modes:  []string{"go"},
+			Before: "RUN --mount=type=cache,target=/root/go/pkg/mod go mod download",
+			After:  "RUN --mount=type=cache,target=/root/.cache/go-build --mount=type=cache,target=/root/go/pkg/mod go mod download",
+		},
+		{
+			Line:   6,
+			Modes:  []string{"go"},
+			Before: "  run CGO_ENABLED=0 /usr/local/go/bin/go build ./...",
+			After:  "  run --mount=type=cache,target=/root/.cache/go-build --mount=type=cache,target=/root/go/pkg/mod CGO_ENABLED=0 /usr/local/go/bin/go build ./...",
+		},
+	}, changes)
+}
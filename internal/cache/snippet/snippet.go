@@ -0,0 +1,133 @@
+// Package snippet generates the CI configuration that mounts caches with
+// spacectl, for users to paste into their pipelines, and the devcontainer
+// configuration that mounts them the same way locally.
+package snippet
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Target is the CI system, or devcontainers, that a snippet is generated
+// for.
+type Target string
+
+const (
+	GitHub       Target = "github"
+	GitLab       Target = "gitlab"
+	Buildkite    Target = "buildkite"
+	Devcontainer Target = "devcontainer"
+)
+
+// Targets lists the supported targets.
+var Targets = []Target{GitHub, GitLab, Buildkite, Devcontainer}
+
+// evalFile is where the GitLab and Buildkite snippets write the environment
+// variables of the mounted caches, for the job's shell to source.
+const evalFile = ".spacectl-cache.env"
+
+// Request describes the snippet to generate.
+type Request struct {
+	Target Target
+	// Modes are the modes detected in the repository. CI snippets name
+	// them in a comment and detect modes again when the job runs, while
+	// devcontainers mount them.
+	Modes []string
+	// WorkDirs and Recursive are passed on to `spacectl cache mount`.
+	WorkDirs  []string
+	Recursive bool
+	// Existing is the devcontainer.json to patch, if there is one.
+	Existing []byte
+}
+
+// Generate returns the snippet for req.Target. For devcontainers, it is the
+// patched devcontainer.json.
+func Generate(req Request) (string, error) {
+	if req.Target == Devcontainer {
+		return devcontainer(req.Existing, req)
+	}
+
+	var b strings.Builder
+	b.WriteString(modesComment(req.Modes))
+
+	switch req.Target {
+	case GitHub:
+		// spacectl exports the variables to later steps through $GITHUB_ENV,
+		// and turns off dry runs in GitHub Actions by itself.
+		fmt.Fprintf(&b, `# Run the job on a Namespace runner with a cache volume, which sets
+# $NSC_CACHE_PATH. spacectl exports the environment of the caches, like
+# GOCACHE, to later steps through $GITHUB_ENV.
+runs-on: namespace-profile-default
+steps:
+  - uses: actions/checkout@v4
+  - name: Mount caches
+    run: %s
+  # Your build steps go here.
+  - name: Save caches
+    run: spacectl cache save
+`, mountCommand(req, nil, nil))
+
+	case GitLab:
+		// before_script and script share a shell, after_script does not.
+		fmt.Fprintf(&b, `# Run the job on a runner with a Namespace cache volume, which sets
+# $NSC_CACHE_PATH.
+before_script:
+  - %s
+  - source %s
+after_script:
+  - spacectl cache save
+`, mountCommand(req, nil, []string{"--eval_file=" + evalFile}), evalFile)
+
+	case Buildkite:
+		// Buildkite is not detected as CI, so dry runs are turned off
+		// explicitly. The commands of a step run in one shell.
+		fmt.Fprintf(&b, `steps:
+  - label: "Build"
+    # Run on an agent with a Namespace cache volume, which sets
+    # $NSC_CACHE_PATH.
+    command:
+      - %s
+      - source %s
+      # Your build commands go here.
+      - spacectl cache save --dry_run=false
+`, mountCommand(req, nil, []string{"--dry_run=false", "--eval_file=" + evalFile}), evalFile)
+
+	default:
+		return "", fmt.Errorf("unknown target %q, expected one of %s", req.Target, strings.Join(targetNames(), ", "))
+	}
+
+	return b.String(), nil
+}
+
+// mountCommand returns the command that mounts the caches of modes, or of
+// the modes detected when it runs if there are none.
+func mountCommand(req Request, modes []string, extra []string) string {
+	args := []string{"spacectl", "cache", "mount", "--detect='*'"}
+	if len(modes) > 0 {
+		args[3] = "--mode=" + strings.Join(modes, ",")
+	}
+	for _, dir := range req.WorkDirs {
+		args = append(args, "--workdir="+dir)
+	}
+	if req.Recursive {
+		args = append(args, "--recursive")
+	}
+	return strings.Join(append(args, extra...), " ")
+}
+
+func modesComment(modes []string) string {
+	if len(modes) == 0 {
+		return "# No cache modes were detected in this repository yet.\n"
+	}
+	modes = slices.Sorted(slices.Values(modes))
+	return fmt.Sprintf("# Caches the detected modes: %s.\n", strings.Join(modes, ", "))
+}
+
+func targetNames() []string {
+	names := make([]string, len(Targets))
+	for i, t := range Targets {
+		names[i] = string(t)
+	}
+	return names
+}
@@ -0,0 +1,48 @@
+package snippet_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/namespacelabs/spacectl/internal/cache/snippet"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("github", func(t *testing.T) {
+		got, err := snippet.Generate(snippet.Request{Target: snippet.GitHub, Modes: []string{"npm", "go"}})
+		require.NoError(t, err)
+		require.Contains(t, got, "# Caches the detected modes: go, npm.\n")
+		require.Contains(t, got, "    run: spacectl cache mount --detect='*'\n")
+		require.NotContains(t, got, "--eval_file")
+	})
+
+	t.Run("gitlab sources the eval file", func(t *testing.T) {
+		got, err := snippet.Generate(snippet.Request{Target: snippet.GitLab, WorkDirs: []string{"web"}, Recursive: true})
+		require.NoError(t, err)
+		require.Contains(t, got, "# No cache modes were detected")
+		require.Contains(t, got, "  - spacectl cache mount --detect='*' --workdir=web --recursive --eval_file=.spacectl-cache.env\n  - source .spacectl-cache.env\n")
+	})
+
+	t.Run("buildkite turns off dry runs", func(t *testing.T) {
+		got, err := snippet.Generate(snippet.Request{Target: snippet.Buildkite, Modes: []string{"go"}})
+		require.NoError(t, err)
+		require.Contains(t, got, "spacectl cache mount --detect='*' --dry_run=false --eval_file=.spacectl-cache.env\n")
+		require.Contains(t, got, "spacectl cache save --dry_run=false\n")
+	})
+
+	t.Run("snippets are valid yaml", func(t *testing.T) {
+		for _, target := range snippet.Targets {
+			got, err := snippet.Generate(snippet.Request{Target: target, Modes: []string{"go"}})
+			require.NoError(t, err)
+			var v map[string]any
+			require.NoError(t, yaml.Unmarshal([]byte(got), &v), target)
+		}
+	})
+
+	t.Run("unknown target", func(t *testing.T) {
+		_, err := snippet.Generate(snippet.Request{Target: "jenkins"})
+		require.ErrorContains(t, err, `unknown target "jenkins", expected one of github, gitlab, buildkite, devcontainer`)
+	})
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrLowDiskSpace is returned by Mount and Apply with RequireFree set when
+// the cache volume has less than MinFree bytes available.
+var ErrLowDiskSpace = errors.New("cache volume is low on disk space")
+
+// checkFreeSpace warns when the cache volume has less than MinFree bytes
+// available before mounting, as jobs that fill it die mid-build. With
+// RequireFree, it fails instead. Volumes whose usage cannot be read pass.
+func (m Mounter) checkFreeSpace(ctx context.Context, result *MountResponse) error {
+	if m.MinFree <= 0 {
+		return nil
+	}
+	usage, err := m.Exec.DiskUsage(ctx, m.CacheRoot)
+	if err != nil {
+		slog.Debug("cannot read the disk usage of the cache root", slog.String("cache_root", m.CacheRoot), slog.Any("error", err))
+		return nil
+	}
+	if usage.AvailableBytes >= uint64(m.MinFree) {
+		return nil
+	}
+
+	if m.RequireFree {
+		return fmt.Errorf("%w: %s available, below the minimum of %s", ErrLowDiskSpace, usage.Available, FormatSize(m.MinFree))
+	}
+	message := fmt.Sprintf("cache volume has %s available, below the minimum of %s: the job may fill it", usage.Available, FormatSize(m.MinFree))
+	slog.Warn(message)
+	result.Output.Warnings = append(result.Output.Warnings, MountIssue{Path: m.CacheRoot, Message: message, Recoverable: true})
+	return nil
+}
+
+// estimateGrowth sets how much each mounted cache is expected to grow during
+// the job, from the stats of the last job that saved it: a cache that missed
+// is rebuilt to its last saved size, while one that hit grows as much as it
+// did last time. Caches that were never saved have no estimate.
+func (m Mounter) estimateGrowth(mounts []MountResult) error {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return err
+	}
+	for i := range mounts {
+		stats := metadata.UserRequest[mounts[i].MountPath].Stats
+		if stats.SavedAt == "" {
+			continue
+		}
+		if mounts[i].CacheHit {
+			mounts[i].EstimatedGrowthBytes = max(stats.GrowthBytes, 0)
+		} else {
+			mounts[i].EstimatedGrowthBytes = stats.Bytes
+		}
+	}
+	return nil
+}
+
+// checkGrowth warns when the estimated growth of the mounted caches leaves
+// less than MinFree bytes of usage available on the cache volume.
+func (m Mounter) checkGrowth(usage DiskUsage, result *MountResponse) {
+	var growth int64
+	for _, mount := range result.Output.Mounts {
+		growth += mount.EstimatedGrowthBytes
+	}
+	result.Output.EstimatedGrowthBytes = growth
+	if m.MinFree <= 0 || growth == 0 {
+		return
+	}
+
+	left := int64(usage.AvailableBytes) - growth
+	if left >= m.MinFree {
+		return
+	}
+	message := fmt.Sprintf("caches are expected to grow by %s, leaving %s of the minimum of %s available", FormatSize(growth), FormatSize(left), FormatSize(m.MinFree))
+	slog.Warn(message)
+	result.Output.Warnings = append(result.Output.Warnings, MountIssue{Path: m.CacheRoot, Message: message, Recoverable: true})
+}
@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_MinFree(t *testing.T) {
+	m := cache.Mounter{
+		Exec: &cache.ExecutorMock{
+			StatFunc:      os.Stat,
+			ReadFileFunc:  os.ReadFile,
+			MkdirAllFunc:  os.MkdirAll,
+			WriteFileFunc: os.WriteFile,
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{Available: "1.0G", AvailableBytes: 1 << 30}, nil
+			},
+		},
+	}
+	plan := cache.Plan{Version: cache.PlanVersion, CacheRoot: t.TempDir()}
+
+	t.Run("enough space", func(t *testing.T) {
+		m := m
+		m.MinFree = 512 << 20
+		result, err := m.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		for _, issue := range result.Output.Warnings {
+			require.NotContains(t, issue.Message, "below the minimum")
+		}
+	})
+
+	t.Run("warns", func(t *testing.T) {
+		m := m
+		m.MinFree = 5 << 30
+		result, err := m.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Contains(t, result.Output.Warnings, cache.MountIssue{
+			Path:        plan.CacheRoot,
+			Message:     "cache volume has 1.0G available, below the minimum of 5.0G: the job may fill it",
+			Recoverable: true,
+		})
+	})
+
+	t.Run("fails when required", func(t *testing.T) {
+		m := m
+		m.MinFree = 5 << 30
+		m.RequireFree = true
+		_, err := m.Apply(t.Context(), plan)
+		require.ErrorIs(t, err, cache.ErrLowDiskSpace)
+	})
+}
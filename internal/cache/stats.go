@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+)
+
+type StatsRequest struct {
+	// Largest is how many of the largest entries to report.
+	Largest int
+	// Workers bounds how many directories are read concurrently. Defaults to
+	// GOMAXPROCS.
+	Workers int
+}
+
+// StatsResponse breaks down the size of the cache entries recorded in the
+// metadata file by mode.
+type StatsResponse struct {
+	CacheRoot string       `json:"cache_root"`
+	Total     DirSize      `json:"total"`
+	Entries   int          `json:"entries"`
+	DiskUsage *DiskUsage   `json:"disk_usage,omitzero"` // lookup can fail, so inclusion is optional
+	Modes     []ModeStats  `json:"modes,omitzero"`
+	Largest   []StatsEntry `json:"largest,omitzero"`
+	// Compressed sums the entries that were compressed as they were rarely
+	// used. Their size in Total is the size of their archives.
+	Compressed *CompressionStats `json:"compressed,omitzero"`
+}
+
+// CompressionStats sums compressed entries.
+type CompressionStats struct {
+	Entries       int   `json:"entries"`
+	Bytes         int64 `json:"bytes"`
+	OriginalBytes int64 `json:"original_bytes"`
+	// SavedBytes is how much space compression saves.
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// ModeStats sums the entries of a mode. Paths mounted with --path have no mode.
+type ModeStats struct {
+	Mode    string  `json:"mode"`
+	Size    DirSize `json:"size"`
+	Entries int     `json:"entries"`
+}
+
+type StatsEntry struct {
+	Mode      string  `json:"mode,omitzero"`
+	CachePath string  `json:"cache_path"`
+	MountPath string  `json:"mount_path"`
+	Size      DirSize `json:"size"`
+	// Compressed is set for entries compressed as they were rarely used.
+	Compressed bool `json:"compressed,omitzero"`
+}
+
+// Stats sizes every cache entry recorded in the metadata file. Entries whose
+// cache path no longer exists are left out.
+func (m Mounter) Stats(ctx context.Context, req StatsRequest) (StatsResponse, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return StatsResponse{}, err
+	}
+
+	var entries []StatsEntry
+	var compressed *CompressionStats
+	for mountPath, entry := range metadata.UserRequest {
+		if _, err := m.Exec.Stat(entry.Source); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return StatsResponse{}, fmt.Errorf("stat cache path %q: %w", entry.Source, err)
+		}
+
+		// The metadata of entries mounted from elsewhere may be stale, so
+		// only entries that hold an archive count as compressed.
+		_, cold, err := m.coldArchive(entry.Source)
+		if err != nil {
+			return StatsResponse{}, err
+		}
+		e := StatsEntry{CachePath: entry.Source, MountPath: mountPath, Compressed: cold && entry.Compressed != nil}
+		if entry.CacheFramework != nil {
+			e.Mode = *entry.CacheFramework
+		}
+		entries = append(entries, e)
+
+		if c := entry.Compressed; e.Compressed {
+			if compressed == nil {
+				compressed = &CompressionStats{}
+			}
+			compressed.Entries++
+			compressed.Bytes += c.Bytes
+			compressed.OriginalBytes += c.OriginalBytes
+			compressed.SavedBytes += max(c.OriginalBytes-c.Bytes, 0)
+		}
+	}
+
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.CachePath
+	}
+
+	workers := req.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	sizes, err := dirSizes(ctx, paths, workers)
+	if err != nil {
+		return StatsResponse{}, err
+	}
+
+	result := StatsResponse{
+		CacheRoot:  m.CacheRoot,
+		Entries:    len(entries),
+		Compressed: compressed,
+	}
+
+	modes := map[string]*ModeStats{}
+	for i := range entries {
+		entries[i].Size = sizes[i]
+		result.Total.Bytes += sizes[i].Bytes
+		result.Total.Files += sizes[i].Files
+
+		stats, ok := modes[entries[i].Mode]
+		if !ok {
+			stats = &ModeStats{Mode: entries[i].Mode}
+			modes[entries[i].Mode] = stats
+		}
+		stats.Size.Bytes += sizes[i].Bytes
+		stats.Size.Files += sizes[i].Files
+		stats.Entries++
+	}
+
+	for _, stats := range modes {
+		result.Modes = append(result.Modes, *stats)
+	}
+	slices.SortFunc(result.Modes, func(a, b ModeStats) int {
+		return cmp.Or(cmp.Compare(b.Size.Bytes, a.Size.Bytes), cmp.Compare(a.Mode, b.Mode))
+	})
+
+	slices.SortFunc(entries, func(a, b StatsEntry) int {
+		return cmp.Or(cmp.Compare(b.Size.Bytes, a.Size.Bytes), cmp.Compare(a.CachePath, b.CachePath))
+	})
+	result.Largest = entries[:min(max(req.Largest, 0), len(entries))]
+
+	// Get disk usage (allowed to fail)
+	if usage, err := m.Exec.DiskUsage(ctx, m.CacheRoot); err == nil {
+		result.DiskUsage = &usage
+	}
+
+	return result, nil
+}
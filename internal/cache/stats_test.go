@@ -0,0 +1,100 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Stats(t *testing.T) {
+	cacheRoot := t.TempDir()
+
+	// writeTree creates files of the given size spread over nested
+	// directories, so that the sizer has to fan out.
+	writeTree := func(dir string, files, size int) {
+		for i := range files {
+			sub := filepath.Join(dir, fmt.Sprintf("d%d", i%3), fmt.Sprintf("e%d", i%2))
+			require.NoError(t, os.MkdirAll(sub, 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(sub, fmt.Sprintf("f%d", i)), make([]byte, size), 0o644))
+		}
+	}
+
+	goBuild := filepath.Join(cacheRoot, "go-build")
+	goMod := filepath.Join(cacheRoot, "go-mod")
+	apt := filepath.Join(cacheRoot, "apt")
+	custom := filepath.Join(cacheRoot, "custom")
+	writeTree(goBuild, 10, 100)
+	writeTree(goMod, 5, 10)
+	writeTree(apt, 4, 50)
+	writeTree(custom, 1, 1)
+
+	goMode, aptMode := "go", "apt"
+	metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{
+		"/root/.cache/go-build": {CacheFramework: &goMode, Source: goBuild},
+		"/root/go/pkg/mod":      {CacheFramework: &goMode, Source: goMod},
+		"/var/cache/apt":        {CacheFramework: &aptMode, Source: apt},
+		"/opt/custom":           {Source: custom},
+		"/opt/gone":             {Source: filepath.Join(cacheRoot, "gone")},
+	}}
+	data, err := json.Marshal(metadata)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+	m := cache.Mounter{
+		CacheRoot: cacheRoot,
+		Exec: &cache.ExecutorMock{
+			DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+				return cache.DiskUsage{}, fmt.Errorf("not implemented")
+			},
+			ReadFileFunc: os.ReadFile,
+			StatFunc:     os.Stat,
+		},
+	}
+
+	t.Run("groups entries by mode", func(t *testing.T) {
+		result, err := m.Stats(t.Context(), cache.StatsRequest{Largest: 2, Workers: 2})
+		require.NoError(t, err)
+
+		require.Equal(t, cacheRoot, result.CacheRoot)
+		require.Equal(t, 4, result.Entries)
+		require.Equal(t, cache.DirSize{Bytes: 1251, Files: 20}, result.Total)
+		require.Nil(t, result.DiskUsage)
+
+		require.Equal(t, []cache.ModeStats{
+			{Mode: "go", Size: cache.DirSize{Bytes: 1050, Files: 15}, Entries: 2},
+			{Mode: "apt", Size: cache.DirSize{Bytes: 200, Files: 4}, Entries: 1},
+			{Mode: "", Size: cache.DirSize{Bytes: 1, Files: 1}, Entries: 1},
+		}, result.Modes)
+
+		require.Equal(t, []cache.StatsEntry{
+			{Mode: "go", CachePath: goBuild, MountPath: "/root/.cache/go-build", Size: cache.DirSize{Bytes: 1000, Files: 10}},
+			{Mode: "apt", CachePath: apt, MountPath: "/var/cache/apt", Size: cache.DirSize{Bytes: 200, Files: 4}},
+		}, result.Largest)
+	})
+
+	t.Run("single worker gives the same totals", func(t *testing.T) {
+		result, err := m.Stats(t.Context(), cache.StatsRequest{Workers: 1})
+		require.NoError(t, err)
+		require.Equal(t, cache.DirSize{Bytes: 1251, Files: 20}, result.Total)
+		require.Empty(t, result.Largest)
+	})
+
+	t.Run("empty cache root", func(t *testing.T) {
+		m := m
+		m.CacheRoot = t.TempDir()
+
+		result, err := m.Stats(t.Context(), cache.StatsRequest{Largest: 10})
+		require.NoError(t, err)
+		require.Zero(t, result.Entries)
+		require.Empty(t, result.Modes)
+		require.Empty(t, result.Largest)
+	})
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// StatusResponse reports on the cache paths recorded for a cache root.
+type StatusResponse struct {
+	CacheRoot string        `json:"cache_root"`
+	UpdatedAt string        `json:"updated_at,omitzero"`
+	DiskUsage *DiskUsage    `json:"disk_usage,omitzero"` // lookup can fail, so inclusion is optional
+	Mounts    []MountStatus `json:"mounts,omitzero"`
+}
+
+// MountStatus describes a cache path recorded in the metadata file.
+type MountStatus struct {
+	Mode      string  `json:"mode,omitzero"`
+	CachePath string  `json:"cache_path"`
+	MountPath string  `json:"mount_path"`
+	CacheHit  bool    `json:"cache_hit"`
+	Mounted   bool    `json:"mounted"`       // the mount path currently resolves to the cache path
+	Stale     bool    `json:"stale"`         // the cache path no longer exists
+	Size      DirSize `json:"size,omitzero"` // only computed for existing cache paths
+	SizeHuman string  `json:"size_human,omitzero"`
+}
+
+// Status inspects the paths recorded in the metadata file of the cache root
+// and reports whether they are currently mounted on this machine.
+func (m Mounter) Status(ctx context.Context) (StatusResponse, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+
+	result := StatusResponse{
+		CacheRoot: m.CacheRoot,
+		UpdatedAt: metadata.UpdatedAt,
+	}
+
+	for mountPath, entry := range metadata.UserRequest {
+		status, err := m.mountStatus(mountPath, entry)
+		if err != nil {
+			return StatusResponse{}, err
+		}
+		result.Mounts = append(result.Mounts, status)
+	}
+	slices.SortFunc(result.Mounts, func(a, b MountStatus) int {
+		return strings.Compare(a.MountPath, b.MountPath)
+	})
+
+	// Get disk usage (allowed to fail)
+	if usage, err := m.Exec.DiskUsage(ctx, m.CacheRoot); err == nil {
+		result.DiskUsage = &usage
+	}
+
+	return result, nil
+}
+
+func (m Mounter) mountStatus(mountPath string, entry CacheMetadataEntry) (MountStatus, error) {
+	status := MountStatus{
+		CachePath: entry.Source,
+		MountPath: mountPath,
+		CacheHit:  entry.CacheHit,
+	}
+	if entry.CacheFramework != nil {
+		status.Mode = *entry.CacheFramework
+	}
+
+	cacheInfo, err := m.Exec.Stat(entry.Source)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			status.Stale = true
+			return status, nil
+		}
+		return MountStatus{}, fmt.Errorf("stat cache path %q: %w", entry.Source, err)
+	}
+
+	// Bind mounts and symlinks both make the mount path resolve to the
+	// cache path itself.
+	mountInfo, err := m.Exec.Stat(mountPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return MountStatus{}, fmt.Errorf("stat mount path %q: %w", mountPath, err)
+	}
+	status.Mounted = err == nil && os.SameFile(cacheInfo, mountInfo)
+
+	if cacheInfo.IsDir() {
+		size, err := dirSize(entry.Source)
+		if err != nil {
+			return MountStatus{}, err
+		}
+		status.Size = size
+	} else {
+		status.Size = DirSize{Bytes: cacheInfo.Size(), Files: 1}
+	}
+	status.SizeHuman = FormatSize(status.Size.Bytes)
+
+	return status, nil
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Status(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+
+	newMounter := func(cacheRoot string) cache.Mounter {
+		return cache.Mounter{
+			CacheRoot: cacheRoot,
+			Exec: &cache.ExecutorMock{
+				DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+					return cache.DiskUsage{Total: "100G", Used: "10G"}, nil
+				},
+				ReadFileFunc: os.ReadFile,
+				StatFunc:     os.Stat,
+			},
+		}
+	}
+
+	writeMetadata := func(t *testing.T, cacheRoot string, metadata cache.CacheMetadata) {
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+
+		path := filepath.Join(cacheRoot, cache.MetadataPath)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+	}
+
+	t.Run("no metadata", func(t *testing.T) {
+		cacheRoot := t.TempDir()
+
+		result, err := newMounter(cacheRoot).Status(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, cacheRoot, result.CacheRoot)
+		require.Empty(t, result.Mounts)
+		require.Equal(t, &cache.DiskUsage{Total: "100G", Used: "10G"}, result.DiskUsage)
+	})
+
+	t.Run("reports mounted, unmounted and stale paths", func(t *testing.T) {
+		cacheRoot := t.TempDir()
+		workDir := t.TempDir()
+
+		goCache := filepath.Join(cacheRoot, "go")
+		require.NoError(t, os.MkdirAll(goCache, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(goCache, "a"), []byte("hello"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(goCache, "b"), []byte("world!"), 0o644))
+		goMount := filepath.Join(workDir, "go")
+		require.NoError(t, os.Symlink(goCache, goMount))
+
+		aptCache := filepath.Join(cacheRoot, "apt")
+		require.NoError(t, os.MkdirAll(aptCache, 0o755))
+		aptMount := filepath.Join(workDir, "apt")
+		require.NoError(t, os.MkdirAll(aptMount, 0o755))
+
+		npmMount := filepath.Join(workDir, "npm")
+
+		goMode, aptMode := "go", "apt"
+		writeMetadata(t, cacheRoot, cache.CacheMetadata{
+			UpdatedAt: "2025-01-01T00:00:00Z",
+			Version:   1,
+			UserRequest: map[string]cache.CacheMetadataEntry{
+				goMount:  {CacheFramework: &goMode, MountTarget: []string{goMount}, Source: goCache, CacheHit: true},
+				aptMount: {CacheFramework: &aptMode, MountTarget: []string{aptMount}, Source: aptCache},
+				npmMount: {MountTarget: []string{npmMount}, Source: filepath.Join(cacheRoot, "npm")},
+			},
+		})
+
+		result, err := newMounter(cacheRoot).Status(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "2025-01-01T00:00:00Z", result.UpdatedAt)
+		require.Equal(t, []cache.MountStatus{
+			{Mode: "apt", CachePath: aptCache, MountPath: aptMount, SizeHuman: "0B"},
+			{
+				Mode:      "go",
+				CachePath: goCache,
+				MountPath: goMount,
+				CacheHit:  true,
+				Mounted:   true,
+				Size:      cache.DirSize{Bytes: 11, Files: 2},
+				SizeHuman: "11B",
+			},
+			{CachePath: filepath.Join(cacheRoot, "npm"), MountPath: npmMount, Stale: true},
+		}, result.Mounts)
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		cacheRoot := t.TempDir()
+		path := filepath.Join(cacheRoot, cache.MetadataPath)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+		_, err := newMounter(cacheRoot).Status(t.Context())
+		require.ErrorContains(t, err, "parsing cache metadata")
+	})
+}
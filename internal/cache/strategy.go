@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// MountStrategy is how a cache path is made available at its mount path.
+type MountStrategy string
+
+const (
+	// MountAuto picks the best strategy the platform and privileges allow.
+	MountAuto MountStrategy = "auto"
+	// MountBind bind mounts the cache path, with sudo or bindfs.
+	MountBind MountStrategy = "bind"
+	// MountSymlink replaces the mount path with a symlink, or a junction on
+	// Windows, to the cache path.
+	MountSymlink MountStrategy = "symlink"
+	// MountCopy copies the cache path to the mount path, and `cache save`
+	// copies it back. It works anywhere, at the cost of copying.
+	MountCopy MountStrategy = "copy"
+	// MountOverlay mounts the cache path as the read-only lower layer of an
+	// overlayfs, with a job-local upper layer that receives all writes. It
+	// suits caches shared across concurrent jobs, such as toolchains, which
+	// must not be modified by any single job. Writes are discarded.
+	MountOverlay MountStrategy = "overlay"
+)
+
+var mountStrategies = []MountStrategy{MountAuto, MountBind, MountSymlink, MountCopy, MountOverlay}
+
+// ParseMountStrategy validates a strategy name. An empty name is MountAuto.
+func ParseMountStrategy(name string) (MountStrategy, error) {
+	if name == "" {
+		return MountAuto, nil
+	}
+	if s := MountStrategy(name); slices.Contains(mountStrategies, s) {
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown mount strategy %q, expected one of %v", name, mountStrategies)
+}
+
+// overlayDir is the job-local directory holding the upper and work layers of
+// an overlay mounted at mountPath.
+func overlayDir(mountPath string) string {
+	sum := sha256.Sum256([]byte(mountPath))
+	return filepath.Join(os.TempDir(), "spacectl-overlay", hex.EncodeToString(sum[:])[:keyLength])
+}
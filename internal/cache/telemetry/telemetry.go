@@ -0,0 +1,143 @@
+// Package telemetry uploads anonymized metrics of cache mounts, so that the
+// effectiveness of caches can be compared across runner pools, and writes
+// them for Prometheus to scrape.
+//
+// Reports only hold counts, sizes and durations per mode. Paths, cache keys,
+// scopes, environment variables and host names are never included.
+package telemetry
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+// DefaultTimeout bounds an upload, which must not hold up the job.
+const DefaultTimeout = 5 * time.Second
+
+// Report is the anonymized outcome of a mount.
+type Report struct {
+	// Version is the version of spacectl that mounted the caches.
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// Pool names the runner pool the job ran in, as configured.
+	Pool string `json:"pool,omitzero"`
+	// DurationMs is how long the command took, in milliseconds.
+	DurationMs int64        `json:"duration_ms"`
+	Mounts     int          `json:"mounts"`
+	Hits       int          `json:"hits"`
+	Evicted    int          `json:"evicted,omitzero"`
+	Warnings   int          `json:"warnings,omitzero"`
+	Errors     int          `json:"errors,omitzero"`
+	Volume     *Volume      `json:"volume,omitzero"`
+	Modes      []ModeReport `json:"modes,omitzero"`
+}
+
+// Volume is the usage of the cache volume after mounting.
+type Volume struct {
+	TotalBytes uint64 `json:"total_bytes"`
+	UsedBytes  uint64 `json:"used_bytes"`
+}
+
+// ModeReport sums the mounts of a mode. Paths mounted with --path have no
+// mode.
+type ModeReport struct {
+	Mode   string `json:"mode"`
+	Mounts int    `json:"mounts"`
+	Hits   int    `json:"hits"`
+	// Bytes is the size of the caches when they were last saved.
+	Bytes                int64 `json:"bytes,omitzero"`
+	EstimatedGrowthBytes int64 `json:"estimated_growth_bytes,omitzero"`
+	DurationMs           int64 `json:"duration_ms"`
+}
+
+// NewReport summarizes a mount. sizes maps cache paths to their size when
+// last saved, and may be nil.
+func NewReport(resp cache.MountResponse, sizes map[string]int64, elapsed time.Duration) Report {
+	r := Report{
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		DurationMs: elapsed.Milliseconds(),
+		Evicted:    len(resp.Output.Evicted),
+		Warnings:   len(resp.Output.Warnings),
+		Errors:     len(resp.Output.Errors),
+	}
+	r.Hits, r.Mounts = resp.Hits()
+	if usage := resp.Output.DiskUsage; usage != nil {
+		r.Volume = &Volume{TotalBytes: usage.TotalBytes, UsedBytes: usage.UsedBytes}
+	}
+
+	modes := map[string]*ModeReport{}
+	for _, mount := range resp.Output.Mounts {
+		m, ok := modes[mount.Mode]
+		if !ok {
+			m = &ModeReport{Mode: mount.Mode}
+			modes[mount.Mode] = m
+		}
+		m.Mounts++
+		if mount.CacheHit {
+			m.Hits++
+		}
+		m.Bytes += sizes[mount.CachePath]
+		m.EstimatedGrowthBytes += mount.EstimatedGrowthBytes
+		m.DurationMs += mount.DurationMs
+	}
+	for _, m := range modes {
+		r.Modes = append(r.Modes, *m)
+	}
+	slices.SortFunc(r.Modes, func(a, b ModeReport) int { return cmp.Compare(a.Mode, b.Mode) })
+	return r
+}
+
+// Client posts reports to an endpoint of the Namespace API.
+type Client struct {
+	Endpoint string
+	// Token is sent as a bearer token, when set.
+	Token string
+	// HTTPClient defaults to a client that gives up after DefaultTimeout.
+	HTTPClient *http.Client
+}
+
+// Send posts a report as JSON.
+func (c Client) Send(ctx context.Context, r Report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "spacectl/"+cmp.Or(r.Version, "unknown"))
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("uploading telemetry: %s", resp.Status)
+	}
+	return nil
+}
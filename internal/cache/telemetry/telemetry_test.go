@@ -0,0 +1,68 @@
+package telemetry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/telemetry"
+)
+
+func TestNewReport(t *testing.T) {
+	resp := cache.MountResponse{Output: cache.MountResponseOutput{
+		Scope:     "feature-branch",
+		AddEnvs:   map[string]string{"GOCACHE": "/secret/gocache"},
+		DiskUsage: &cache.DiskUsage{TotalBytes: 100, UsedBytes: 40},
+		Mounts: []cache.MountResult{
+			{Mode: "go", CachePath: "/cache/go-build", MountPath: "/home/alice/.cache/go-build", CacheHit: true, DurationMs: 10},
+			{Mode: "go", CachePath: "/cache/go-mod", MountPath: "/home/alice/go/pkg/mod", DurationMs: 20, EstimatedGrowthBytes: 5},
+			{CachePath: "/cache/custom", MountPath: "/work/out", CacheHit: true, Key: "abc123", DurationMs: 1},
+		},
+		Warnings: []cache.MountIssue{{Message: "low disk space"}},
+	}}
+
+	report := telemetry.NewReport(resp, map[string]int64{"/cache/go-build": 100, "/cache/go-mod": 50}, 2*time.Second)
+	require.Equal(t, 3, report.Mounts)
+	require.Equal(t, 2, report.Hits)
+	require.Equal(t, 1, report.Warnings)
+	require.Equal(t, int64(2000), report.DurationMs)
+	require.Equal(t, &telemetry.Volume{TotalBytes: 100, UsedBytes: 40}, report.Volume)
+	require.Equal(t, []telemetry.ModeReport{
+		{Mode: "", Mounts: 1, Hits: 1, DurationMs: 1},
+		{Mode: "go", Mounts: 2, Hits: 1, Bytes: 150, EstimatedGrowthBytes: 5, DurationMs: 30},
+	}, report.Modes)
+
+	// Nothing that identifies the job or its machine is reported.
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	for _, s := range []string{"/cache", "alice", "/work", "abc123", "feature-branch", "secret", "low disk space"} {
+		require.NotContains(t, string(data), s)
+	}
+}
+
+func TestClient_Send(t *testing.T) {
+	var got telemetry.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "spacectl/v1.2.3", r.Header.Get("User-Agent"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			http.Error(w, "nope", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	report := telemetry.Report{Version: "v1.2.3", Pool: "linux-large", Mounts: 2, Hits: 1}
+	require.NoError(t, telemetry.Client{Endpoint: server.URL + "/metrics", Token: "token"}.Send(t.Context(), report))
+	require.Equal(t, report, got)
+
+	err := telemetry.Client{Endpoint: server.URL + "/fail", Token: "token"}.Send(t.Context(), report)
+	require.ErrorContains(t, err, "403 Forbidden")
+}
@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metric is a family of the textfile.
+type metric struct {
+	name, kind, help string
+	value            func(ModeReport) float64
+}
+
+var modeMetrics = []metric{
+	{"spacectl_cache_hit_total", "counter", "Paths mounted from an existing cache.", func(m ModeReport) float64 { return float64(m.Hits) }},
+	{"spacectl_cache_miss_total", "counter", "Paths mounted with an empty cache.", func(m ModeReport) float64 { return float64(m.Mounts - m.Hits) }},
+	{"spacectl_mount_duration_seconds", "gauge", "How long the last mount took to mount the paths of a mode.", func(m ModeReport) float64 { return float64(m.DurationMs) / 1000 }},
+	{"spacectl_cache_bytes", "gauge", "Size of the caches of a mode when they were last saved.", func(m ModeReport) float64 { return float64(m.Bytes) }},
+}
+
+// WriteTextfile writes the metrics of a report to path in the Prometheus
+// text format, for the textfile collector of node_exporter. Counters add to
+// those in the file that is replaced, so that they count every mount on the
+// host. The file is written next to path and renamed over it, so that it is
+// never scraped half written.
+func WriteTextfile(path string, r Report, now time.Time) error {
+	previous, err := readCounters(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, m := range modeMetrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		values := map[string]float64{}
+		if m.kind == "counter" {
+			// Modes that were not mounted this time keep their counts.
+			for series, v := range previous {
+				if strings.HasPrefix(series, m.name+"{") {
+					values[series] = v
+				}
+			}
+		}
+		for _, mode := range r.Modes {
+			values[fmt.Sprintf("%s{mode=%q}", m.name, mode.Mode)] += m.value(mode)
+		}
+		for _, series := range slices.Sorted(maps.Keys(values)) {
+			fmt.Fprintf(&buf, "%s %s\n", series, strconv.FormatFloat(values[series], 'g', -1, 64))
+		}
+	}
+	fmt.Fprintf(&buf, "# HELP spacectl_mount_timestamp_seconds When caches were last mounted.\n# TYPE spacectl_mount_timestamp_seconds gauge\n")
+	fmt.Fprintf(&buf, "spacectl_mount_timestamp_seconds %d\n", now.Unix())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	// node_exporter may run as another user than the job.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing metrics file: %w", err)
+	}
+	return nil
+}
+
+// readCounters reads the counters of a textfile written by WriteTextfile,
+// keyed by series. A missing file has none.
+func readCounters(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading metrics file: %w", err)
+	}
+
+	counters := map[string]float64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		series, value, ok := strings.Cut(scanner.Text(), " ")
+		name, _, _ := strings.Cut(series, "{")
+		if !ok || !strings.HasSuffix(name, "_total") {
+			continue
+		}
+		// A corrupt counter starts over rather than failing the mount.
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			counters[series] = v
+		}
+	}
+	return counters, nil
+}
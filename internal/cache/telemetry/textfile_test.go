@@ -0,0 +1,52 @@
+package telemetry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache/telemetry"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spacectl.prom")
+	now := time.Unix(1700000000, 0)
+
+	require.NoError(t, telemetry.WriteTextfile(path, telemetry.Report{Modes: []telemetry.ModeReport{
+		{Mode: "go", Mounts: 2, Hits: 1, Bytes: 1024, DurationMs: 1500},
+		{Mode: "pnpm", Mounts: 1, Hits: 1, DurationMs: 250},
+	}}, now))
+
+	// Counters add up across mounts, also for modes not mounted again.
+	require.NoError(t, telemetry.WriteTextfile(path, telemetry.Report{Modes: []telemetry.ModeReport{
+		{Mode: "go", Mounts: 2, Hits: 2, Bytes: 2048, DurationMs: 500},
+	}}, now.Add(time.Minute)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `# HELP spacectl_cache_hit_total Paths mounted from an existing cache.
+# TYPE spacectl_cache_hit_total counter
+spacectl_cache_hit_total{mode="go"} 3
+spacectl_cache_hit_total{mode="pnpm"} 1
+# HELP spacectl_cache_miss_total Paths mounted with an empty cache.
+# TYPE spacectl_cache_miss_total counter
+spacectl_cache_miss_total{mode="go"} 1
+spacectl_cache_miss_total{mode="pnpm"} 0
+# HELP spacectl_mount_duration_seconds How long the last mount took to mount the paths of a mode.
+# TYPE spacectl_mount_duration_seconds gauge
+spacectl_mount_duration_seconds{mode="go"} 0.5
+# HELP spacectl_cache_bytes Size of the caches of a mode when they were last saved.
+# TYPE spacectl_cache_bytes gauge
+spacectl_cache_bytes{mode="go"} 2048
+# HELP spacectl_mount_timestamp_seconds When caches were last mounted.
+# TYPE spacectl_mount_timestamp_seconds gauge
+spacectl_mount_timestamp_seconds 1700000060
+`, string(data))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files are cleaned up")
+}
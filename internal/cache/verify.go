@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const manifestVersion = 1
+
+var (
+	// ManifestsDir holds the checksum manifests of cache entries, relative to
+	// the cache root.
+	ManifestsDir = filepath.Join(".ns", "manifests")
+	// QuarantineDir is where corrupt cache entries are moved, relative to the
+	// cache root.
+	QuarantineDir = filepath.Join(".ns", "quarantine")
+)
+
+const (
+	VerifyStatusRecorded    = "recorded"
+	VerifyStatusOK          = "ok"
+	VerifyStatusCorrupt     = "corrupt"
+	VerifyStatusUnrecorded  = "unrecorded"
+	VerifyStatusQuarantined = "quarantined"
+)
+
+// Manifest records the size and checksum of every regular file in a cache
+// entry, so that later runs can detect files that were truncated or changed.
+type Manifest struct {
+	Version   int                     `json:"version"`
+	CachePath string                  `json:"cache_path"`
+	CreatedAt string                  `json:"created_at"`
+	Files     map[string]ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// VerifyRequest selects the cache entries to record or verify. Without modes,
+// all entries in the metadata file are used.
+type VerifyRequest struct {
+	Modes []string
+	// Quarantine moves corrupt entries out of the way, so that the next mount
+	// starts from an empty cache.
+	Quarantine bool
+}
+
+type VerifyResponse struct {
+	CacheRoot string        `json:"cache_root"`
+	Entries   []VerifyEntry `json:"entries,omitzero"`
+}
+
+// Corrupt returns the entries that failed verification.
+func (r VerifyResponse) Corrupt() []VerifyEntry {
+	return slices.DeleteFunc(slices.Clone(r.Entries), func(e VerifyEntry) bool {
+		return e.Status != VerifyStatusCorrupt && e.Status != VerifyStatusQuarantined
+	})
+}
+
+type VerifyEntry struct {
+	Mode           string   `json:"mode,omitzero"`
+	CachePath      string   `json:"cache_path"`
+	MountPath      string   `json:"mount_path"`
+	Status         string   `json:"status"`
+	Files          int      `json:"files"`
+	Problems       []string `json:"problems,omitzero"`
+	QuarantinePath string   `json:"quarantine_path,omitzero"`
+}
+
+// RecordManifests writes a checksum manifest for every selected cache entry,
+// replacing earlier ones. Run it once the cache is in the state to be saved.
+func (m Mounter) RecordManifests(ctx context.Context, req VerifyRequest) (VerifyResponse, error) {
+	entries, err := m.verifyEntries(req)
+	if err != nil {
+		return VerifyResponse{}, err
+	}
+
+	result := VerifyResponse{CacheRoot: m.CacheRoot}
+	for _, e := range entries {
+		files, err := checksumTree(ctx, e.CachePath)
+		if err != nil {
+			return VerifyResponse{}, err
+		}
+
+		manifest := Manifest{
+			Version:   manifestVersion,
+			CachePath: e.CachePath,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Files:     files,
+		}
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			return VerifyResponse{}, fmt.Errorf("encoding manifest: %w", err)
+		}
+
+		path := m.manifestPath(e.CachePath)
+		if err := m.Exec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return VerifyResponse{}, fmt.Errorf("creating manifests dir: %w", err)
+		}
+		if err := m.Exec.WriteFile(path, data, 0o644); err != nil {
+			return VerifyResponse{}, fmt.Errorf("writing manifest for %q: %w", e.CachePath, err)
+		}
+
+		e.Status = VerifyStatusRecorded
+		e.Files = len(files)
+		result.Entries = append(result.Entries, e)
+	}
+
+	return result, nil
+}
+
+// Verify checks the selected cache entries against their manifests. Files
+// added since the manifest was recorded are not reported, as caches only
+// grow between saves; missing, resized or changed files are.
+func (m Mounter) Verify(ctx context.Context, req VerifyRequest) (VerifyResponse, error) {
+	entries, err := m.verifyEntries(req)
+	if err != nil {
+		return VerifyResponse{}, err
+	}
+
+	result := VerifyResponse{CacheRoot: m.CacheRoot}
+	for _, e := range entries {
+		data, err := m.Exec.ReadFile(m.manifestPath(e.CachePath))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				e.Status = VerifyStatusUnrecorded
+				result.Entries = append(result.Entries, e)
+				continue
+			}
+			return VerifyResponse{}, fmt.Errorf("reading manifest for %q: %w", e.CachePath, err)
+		}
+
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return VerifyResponse{}, fmt.Errorf("parsing manifest for %q: %w", e.CachePath, err)
+		}
+
+		files, err := checksumTree(ctx, e.CachePath)
+		if err != nil {
+			return VerifyResponse{}, err
+		}
+
+		e.Files = len(manifest.Files)
+		e.Problems = compareManifest(manifest.Files, files)
+		e.Status = VerifyStatusOK
+		if len(e.Problems) > 0 {
+			e.Status = VerifyStatusCorrupt
+		}
+
+		if e.Status == VerifyStatusCorrupt && req.Quarantine {
+			if err := m.quarantine(ctx, &e); err != nil {
+				return VerifyResponse{}, err
+			}
+		}
+
+		result.Entries = append(result.Entries, e)
+	}
+
+	return result, nil
+}
+
+// verifyEntries returns the existing cache entries from the metadata file,
+// restricted to the requested modes.
+func (m Mounter) verifyEntries(req VerifyRequest) ([]VerifyEntry, error) {
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []VerifyEntry
+	for mountPath, entry := range metadata.UserRequest {
+		var modeName string
+		if entry.CacheFramework != nil {
+			modeName = *entry.CacheFramework
+		}
+		if len(req.Modes) > 0 && !slices.Contains(req.Modes, modeName) {
+			continue
+		}
+
+		if _, err := m.Exec.Stat(entry.Source); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("stat cache path %q: %w", entry.Source, err)
+		}
+		// Compressed entries are checked once they are decompressed.
+		if _, cold, err := m.coldArchive(entry.Source); err != nil || cold {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		entries = append(entries, VerifyEntry{
+			Mode:      modeName,
+			CachePath: entry.Source,
+			MountPath: mountPath,
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b VerifyEntry) int {
+		return cmp.Compare(a.CachePath, b.CachePath)
+	})
+	return entries, nil
+}
+
+// quarantine moves a corrupt entry aside and forgets about it, keeping its
+// contents around for inspection.
+func (m Mounter) quarantine(ctx context.Context, e *VerifyEntry) error {
+	name := fmt.Sprintf("%s-%s-%s", filepath.Base(e.CachePath), time.Now().UTC().Format("20060102T150405"), m.manifestKey(e.CachePath)[:8])
+	dest := filepath.Join(m.CacheRoot, QuarantineDir, name)
+
+	if err := m.Exec.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("creating quarantine dir: %w", err)
+	}
+	if err := m.Exec.Rename(e.CachePath, dest); err != nil {
+		return fmt.Errorf("quarantining %q: %w", e.CachePath, err)
+	}
+	slog.Debug("quarantined cache entry", slog.String("path", e.CachePath), slog.String("to", dest))
+
+	e.Status = VerifyStatusQuarantined
+	e.QuarantinePath = dest
+
+	return m.forgetMetadata(ctx, []string{e.CachePath})
+}
+
+// manifestPath returns where the manifest of a cache entry lives. Manifests
+// are keyed by the entry's path relative to the cache root, so they stay valid
+// when the volume is attached elsewhere.
+func (m Mounter) manifestPath(cachePath string) string {
+	return filepath.Join(m.CacheRoot, ManifestsDir, m.manifestKey(cachePath)+".json")
+}
+
+func (m Mounter) manifestKey(cachePath string) string {
+	if rel, err := filepath.Rel(m.CacheRoot, cachePath); err == nil {
+		cachePath = filepath.ToSlash(rel)
+	}
+	sum := sha256.Sum256([]byte(cachePath))
+	return hex.EncodeToString(sum[:])
+}
+
+// compareManifest lists how files differ from what the manifest recorded.
+func compareManifest(recorded, current map[string]ManifestFile) []string {
+	var problems []string
+	for _, name := range slices.Sorted(maps.Keys(recorded)) {
+		want := recorded[name]
+		got, ok := current[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: missing", name))
+		case got.Size != want.Size:
+			problems = append(problems, fmt.Sprintf("%s: size %d, expected %d", name, got.Size, want.Size))
+		case got.SHA256 != want.SHA256:
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch", name))
+		}
+	}
+	return problems
+}
+
+// checksumTree hashes every regular file beneath root in parallel, keyed by
+// slash-separated path relative to root.
+func checksumTree(ctx context.Context, root string) (map[string]ManifestFile, error) {
+	var mu sync.Mutex
+	files := map[string]ManifestFile{}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.GOMAXPROCS(0))
+
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		eg.Go(func() error {
+			f, err := checksumFile(path)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			files[filepath.ToSlash(rel)] = f
+			mu.Unlock()
+			return nil
+		})
+		return nil
+	})
+
+	if err := errors.Join(walkErr, eg.Wait()); err != nil {
+		return nil, fmt.Errorf("checksumming %q: %w", root, err)
+	}
+	return files, nil
+}
+
+func checksumFile(path string) (ManifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("reading %q: %w", path, err)
+	}
+	return ManifestFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,139 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+func TestMounter_Verify(t *testing.T) {
+	newMounter := func(t *testing.T) cache.Mounter {
+		cacheRoot := t.TempDir()
+		for _, dir := range []string{"go", "apt"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, dir, "sub"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, dir, "a"), []byte("hello"), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, dir, "sub", "b"), []byte("world"), 0o644))
+		}
+
+		goMode, aptMode := "go", "apt"
+		metadata := cache.CacheMetadata{Version: 1, UserRequest: map[string]cache.CacheMetadataEntry{
+			"/work/go":  {CacheFramework: &goMode, Source: filepath.Join(cacheRoot, "go")},
+			"/work/apt": {CacheFramework: &aptMode, Source: filepath.Join(cacheRoot, "apt")},
+		}}
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheRoot, ".ns"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cacheRoot, cache.MetadataPath), data, 0o644))
+
+		return cache.Mounter{
+			CacheRoot: cacheRoot,
+			Exec: &cache.ExecutorMock{
+				MkdirAllFunc:  os.MkdirAll,
+				ReadFileFunc:  os.ReadFile,
+				RemoveAllFunc: os.RemoveAll,
+				RenameFunc:    os.Rename,
+				StatFunc:      os.Stat,
+				LockFunc:      noLock,
+				WriteFileFunc: os.WriteFile,
+			},
+		}
+	}
+
+	statuses := func(result cache.VerifyResponse) map[string]string {
+		s := map[string]string{}
+		for _, e := range result.Entries {
+			s[e.Mode] = e.Status
+		}
+		return s
+	}
+
+	t.Run("unrecorded entries", func(t *testing.T) {
+		m := newMounter(t)
+
+		result, err := m.Verify(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "unrecorded", "apt": "unrecorded"}, statuses(result))
+		require.Empty(t, result.Corrupt())
+	})
+
+	t.Run("recorded entries verify", func(t *testing.T) {
+		m := newMounter(t)
+
+		recorded, err := m.RecordManifests(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "recorded", "apt": "recorded"}, statuses(recorded))
+		require.Equal(t, 2, recorded.Entries[0].Files)
+
+		// New files are expected as caches grow.
+		require.NoError(t, os.WriteFile(filepath.Join(m.CacheRoot, "go", "c"), []byte("new"), 0o644))
+
+		result, err := m.Verify(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "ok", "apt": "ok"}, statuses(result))
+	})
+
+	t.Run("detects truncated, changed and missing files", func(t *testing.T) {
+		m := newMounter(t)
+
+		_, err := m.RecordManifests(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+
+		require.NoError(t, os.Truncate(filepath.Join(m.CacheRoot, "go", "a"), 2))
+		require.NoError(t, os.WriteFile(filepath.Join(m.CacheRoot, "go", "sub", "b"), []byte("WORLD"), 0o644))
+		require.NoError(t, os.Remove(filepath.Join(m.CacheRoot, "apt", "a")))
+
+		result, err := m.Verify(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "corrupt", "apt": "corrupt"}, statuses(result))
+		require.Len(t, result.Corrupt(), 2)
+
+		require.Equal(t, []string{"a: missing"}, result.Entries[0].Problems)
+		require.Equal(t, []string{"a: size 2, expected 5", "sub/b: checksum mismatch"}, result.Entries[1].Problems)
+	})
+
+	t.Run("quarantines corrupt entries", func(t *testing.T) {
+		m := newMounter(t)
+
+		_, err := m.RecordManifests(t.Context(), cache.VerifyRequest{})
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(filepath.Join(m.CacheRoot, "go", "a"), 0))
+
+		result, err := m.Verify(t.Context(), cache.VerifyRequest{Quarantine: true})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "quarantined", "apt": "ok"}, statuses(result))
+
+		quarantined := result.Entries[1]
+		require.NoDirExists(t, filepath.Join(m.CacheRoot, "go"))
+		require.FileExists(t, filepath.Join(quarantined.QuarantinePath, "sub", "b"))
+		require.True(t, filepath.IsAbs(quarantined.QuarantinePath))
+
+		metadata, err := cache.ReadMetadata(m.Exec, m.CacheRoot)
+		require.NoError(t, err)
+		require.NotContains(t, metadata.UserRequest, "/work/go")
+
+		// A fresh cache at the same path starts out unrecorded.
+		require.NoError(t, os.MkdirAll(filepath.Join(m.CacheRoot, "go"), 0o755))
+		goMode := "go"
+		metadata.UserRequest["/work/go"] = cache.CacheMetadataEntry{CacheFramework: &goMode, Source: filepath.Join(m.CacheRoot, "go")}
+		data, err := json.Marshal(metadata)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(m.CacheRoot, cache.MetadataPath), data, 0o644))
+
+		result, err = m.Verify(t.Context(), cache.VerifyRequest{Modes: []string{"go"}})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"go": "unrecorded"}, statuses(result))
+	})
+
+	t.Run("filters by mode", func(t *testing.T) {
+		m := newMounter(t)
+
+		result, err := m.RecordManifests(t.Context(), cache.VerifyRequest{Modes: []string{"apt"}})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apt": "recorded"}, statuses(result))
+	})
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+)
+
+// ErrNotVolume is returned by Mount and Apply with RequireVolume set when
+// the cache root is not a mounted volume.
+var ErrNotVolume = errors.New("cache root is not a mounted volume")
+
+// isVolume reports whether the cache root is on a mounted volume rather than
+// on the disk of the filesystem root. It fails with errors.ErrUnsupported
+// where devices cannot be told apart.
+func (m Mounter) isVolume() (bool, error) {
+	return onVolume(m.Exec, m.CacheRoot)
+}
+
+// onVolume reports whether path is on another device than the filesystem
+// root. The path is on the device of the mount point that contains it, so a
+// subdirectory of a volume, e.g. /cache/spacectl, is on the volume too.
+func onVolume(exec Executor, path string) (bool, error) {
+	root := filepath.VolumeName(path) + string(filepath.Separator)
+
+	info, err := exec.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	rootInfo, err := exec.Stat(root)
+	if err != nil {
+		return false, err
+	}
+
+	dev, ok := fileDevice(info)
+	rootDev, rootOk := fileDevice(rootInfo)
+	if !ok || !rootOk {
+		return false, errors.ErrUnsupported
+	}
+	return dev != rootDev, nil
+}
+
+// checkVolume warns when the cache root is a plain directory, e.g. when
+// NSC_CACHE_PATH points at the root disk, as caches kept there do not outlive
+// the runner and fill its disk. With RequireVolume, it fails instead.
+func (m Mounter) checkVolume(result *MountResponse) error {
+	volume, err := m.isVolume()
+	if err != nil {
+		slog.Debug("cannot tell whether the cache root is a mounted volume", slog.String("cache_root", m.CacheRoot), slog.Any("error", err))
+		return nil
+	}
+	if volume {
+		return nil
+	}
+
+	if m.RequireVolume {
+		return fmt.Errorf("%w: %s is a plain directory", ErrNotVolume, m.CacheRoot)
+	}
+	message := fmt.Sprintf("cache root %s is not a mounted volume: caches do not outlive this runner and take up its disk", m.CacheRoot)
+	slog.Warn(message)
+	result.Output.Warnings = append(result.Output.Warnings, MountIssue{Message: message, Recoverable: true})
+	return nil
+}
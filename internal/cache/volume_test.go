@@ -0,0 +1,81 @@
+//go:build !windows
+
+package cache_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+// deviceInfo is stat info of a directory on another device.
+type deviceInfo struct {
+	os.FileInfo
+	st syscall.Stat_t
+}
+
+func (i deviceInfo) Sys() any { return &i.st }
+
+func TestMounter_RequireVolume(t *testing.T) {
+	root, err := os.Stat("/")
+	require.NoError(t, err)
+	volume := deviceInfo{FileInfo: root, st: *root.Sys().(*syscall.Stat_t)}
+	volume.st.Dev++
+
+	// setup stats the directories in volumes on another device than /.
+	setup := func(volumes ...string) cache.Mounter {
+		return cache.Mounter{
+			Exec: &cache.ExecutorMock{
+				StatFunc: func(name string) (os.FileInfo, error) {
+					for _, v := range volumes {
+						if name == v || strings.HasPrefix(name, v+"/") {
+							return volume, nil
+						}
+					}
+					return root, nil
+				},
+				ReadFileFunc: func(name string) ([]byte, error) {
+					return nil, os.ErrNotExist
+				},
+				MkdirAllFunc: func(path string, perm os.FileMode) error {
+					return nil
+				},
+				WriteFileFunc: func(name string, data []byte, perm os.FileMode) error {
+					return nil
+				},
+				DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+					return cache.DiskUsage{}, nil
+				},
+			},
+		}
+	}
+	plan := cache.Plan{Version: cache.PlanVersion, CacheRoot: "/cache/spacectl"}
+
+	t.Run("warns", func(t *testing.T) {
+		result, err := setup().Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Len(t, result.Output.Warnings, 1)
+		require.Contains(t, result.Output.Warnings[0].Message, "is not a mounted volume")
+	})
+
+	t.Run("fails when required", func(t *testing.T) {
+		m := setup()
+		m.RequireVolume = true
+		_, err := m.Apply(t.Context(), plan)
+		require.ErrorIs(t, err, cache.ErrNotVolume)
+	})
+
+	t.Run("subdirectories of volumes", func(t *testing.T) {
+		m := setup("/cache")
+		m.RequireVolume = true
+		result, err := m.Apply(t.Context(), plan)
+		require.NoError(t, err)
+		require.Empty(t, result.Output.Warnings)
+	})
+}
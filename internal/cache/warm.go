@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// WarmRequest configures the warming of mounted caches.
+type WarmRequest struct {
+	// Modes lists the modes to warm. Empty or "*" warms every mounted mode
+	// that supports it.
+	Modes []string
+	// Concurrency is the maximum number of modes warmed at once. Defaults to
+	// the concurrency of the mounter.
+	Concurrency int
+	// Timeout bounds each warm command, which is not bound by the
+	// CommandTimeout of the mounter as warming downloads whole dependency
+	// trees. Zero means no limit.
+	Timeout time.Duration
+}
+
+type WarmResponse struct {
+	CacheRoot  string       `json:"cache_root"`
+	DryRun     bool         `json:"dry_run"`
+	Modes      []WarmedMode `json:"modes,omitzero"`
+	DurationMs int64        `json:"duration_ms"`
+}
+
+// WarmedMode reports on warming the caches of a mode.
+type WarmedMode struct {
+	Mode string `json:"mode"`
+	// Commands lists the commands run, or that would run in dry-run mode.
+	// Modes without commands had nothing to warm, e.g. no lockfile.
+	Commands   []string `json:"commands,omitzero"`
+	DurationMs int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitzero"`
+}
+
+// Failed returns the modes that failed to warm.
+func (r WarmResponse) Failed() []string {
+	var failed []string
+	for _, m := range r.Modes {
+		if m.Error != "" {
+			failed = append(failed, m.Mode)
+		}
+	}
+	return failed
+}
+
+// Warm fills the mounted caches ahead of builds by running the warm commands
+// of their modes, e.g. `go mod download`, in the project dir. It runs with the
+// environment of the process, so it is meant to run after the environment
+// exported by mount is applied. A mode that fails to warm is reported and
+// does not stop the others. Without DestructiveMode it only reports the
+// commands that would run.
+func (m Mounter) Warm(ctx context.Context, req WarmRequest) (WarmResponse, error) {
+	start := time.Now()
+	result := WarmResponse{
+		CacheRoot: m.CacheRoot,
+		DryRun:    !m.DestructiveMode,
+	}
+
+	metadata, err := ReadMetadata(m.Exec, m.CacheRoot)
+	if err != nil {
+		return WarmResponse{}, err
+	}
+	entries, err := m.mountedEntries(metadata)
+	if err != nil {
+		return WarmResponse{}, err
+	}
+	modes, err := m.warmers(req.Modes, mountedModes(entries))
+	if err != nil {
+		return WarmResponse{}, err
+	}
+
+	projectDir, err := m.projectDir()
+	if err != nil {
+		return WarmResponse{}, fmt.Errorf("resolving project dir: %w", err)
+	}
+
+	var exec mode.Executor = mode.DefaultExecutor{CommandTimeout: req.Timeout}
+	if !m.DestructiveMode {
+		exec = dryRunExecutor{exec}
+	}
+
+	warmed := modes.Warm(ctx, mode.WarmRequest{
+		Exec:        exec,
+		WorkDir:     projectDir,
+		Concurrency: cmp.Or(req.Concurrency, m.concurrency()),
+	})
+	for _, name := range slices.Sorted(maps.Keys(warmed)) {
+		w := warmed[name]
+		wm := WarmedMode{Mode: name, DurationMs: w.Duration.Milliseconds()}
+		for _, check := range w.Checks {
+			if check.Kind == mode.CheckCommand {
+				wm.Commands = append(wm.Commands, check.Name)
+			}
+		}
+		if w.Err != nil {
+			wm.Error = w.Err.Error()
+		}
+		result.Modes = append(result.Modes, wm)
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// warmers resolves the modes to warm among the mounted modes.
+func (m Mounter) warmers(names, mounted []string) (mode.Modes, error) {
+	all := len(names) == 0 || slices.Equal(names, []string{"*"})
+	if all {
+		names = mounted
+	}
+
+	modes, err := m.Modes.Filter(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var warmers mode.Modes
+	for _, p := range modes {
+		if _, ok := p.(mode.Warmer); !ok {
+			if all {
+				continue
+			}
+			return nil, fmt.Errorf("mode %q does not support warming", p.Name())
+		}
+		if !slices.Contains(mounted, p.Name()) {
+			if all {
+				continue
+			}
+			return nil, fmt.Errorf("mode %q is not mounted", p.Name())
+		}
+		warmers = append(warmers, p)
+	}
+	return warmers, nil
+}
+
+// dryRunExecutor runs no commands, so that dry runs report what would run.
+type dryRunExecutor struct {
+	mode.Executor
+}
+
+func (e dryRunExecutor) Run(ctx context.Context, spec mode.CommandSpec) ([]byte, error) {
+	slog.Debug("dry-run: would run", slog.String("command", spec.String()))
+	return nil, nil
+}
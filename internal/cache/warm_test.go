@@ -0,0 +1,119 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+// warmingProvider is a mode whose cache can be warmed with a command.
+type warmingProvider struct {
+	*mode.ModeProviderMock
+	command string
+}
+
+func (p warmingProvider) Warm(ctx context.Context, req mode.WarmRequest) error {
+	_, err := req.Exec.Run(ctx, mode.CommandSpec{Name: p.command})
+	return err
+}
+
+func TestMounter_Warm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on windows")
+	}
+
+	cacheRoot := t.TempDir()
+	workDir := t.TempDir()
+	exec := &cache.ExecutorMock{
+		MkdirAllFunc:  os.MkdirAll,
+		ReadFileFunc:  os.ReadFile,
+		StatFunc:      os.Stat,
+		LockFunc:      noLock,
+		WriteFileFunc: os.WriteFile,
+		DiskUsageFunc: func(ctx context.Context, path string) (cache.DiskUsage, error) {
+			return cache.DiskUsage{}, nil
+		},
+		MountFunc: func(ctx context.Context, from, to string, strategy cache.MountStrategy, readOnly bool) (cache.MountStrategy, error) {
+			if err := os.MkdirAll(from, 0o755); err != nil {
+				return "", err
+			}
+			return cache.MountSymlink, os.Symlink(from, to)
+		},
+	}
+	provider := func(name, command string) mode.ModeProvider {
+		return warmingProvider{&mode.ModeProviderMock{
+			NameFunc: func() string { return name },
+			PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+				return mode.PlanResult{MountPaths: []string{filepath.Join(workDir, name)}}, nil
+			},
+		}, command}
+	}
+	m := cache.Mounter{
+		DestructiveMode: true,
+		CacheRoot:       cacheRoot,
+		ProjectDir:      workDir,
+		Exec:            exec,
+		Modes: mode.Modes{
+			provider("go", "true"),
+			provider("pnpm", "false"),
+			provider("rust", "true"),
+			&mode.ModeProviderMock{
+				NameFunc: func() string { return "apt" },
+				PlanFunc: func(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+					return mode.PlanResult{MountPaths: []string{filepath.Join(workDir, "apt")}}, nil
+				},
+			},
+		},
+	}
+	_, err := m.Mount(t.Context(), cache.MountRequest{ManualModes: []string{"go", "pnpm", "apt"}})
+	require.NoError(t, err)
+
+	t.Run("warms mounted modes that support it", func(t *testing.T) {
+		result, err := m.Warm(t.Context(), cache.WarmRequest{Concurrency: 1})
+		require.NoError(t, err)
+		require.False(t, result.DryRun)
+		require.Len(t, result.Modes, 2)
+		require.Equal(t, "go", result.Modes[0].Mode)
+		require.Equal(t, []string{"true"}, result.Modes[0].Commands)
+		require.Empty(t, result.Modes[0].Error)
+		require.Equal(t, "pnpm", result.Modes[1].Mode)
+		require.Contains(t, result.Modes[1].Error, "exit status 1")
+		require.Equal(t, []string{"pnpm"}, result.Failed())
+	})
+
+	t.Run("dry run runs nothing", func(t *testing.T) {
+		m := m
+		m.DestructiveMode = false
+		m.Modes = mode.Modes{warmingProvider{&mode.ModeProviderMock{
+			NameFunc: func() string { return "go" },
+		}, "false"}}
+		result, err := m.Warm(t.Context(), cache.WarmRequest{Modes: []string{"go"}})
+		require.NoError(t, err)
+		// The command would fail if it ran.
+		require.True(t, result.DryRun)
+		require.Equal(t, []cache.WarmedMode{{Mode: "go", Commands: []string{"false"}, DurationMs: result.Modes[0].DurationMs}}, result.Modes)
+	})
+
+	t.Run("rejects modes that cannot be warmed", func(t *testing.T) {
+		_, err := m.Warm(t.Context(), cache.WarmRequest{Modes: []string{"apt"}})
+		require.ErrorContains(t, err, `mode "apt" does not support warming`)
+
+		_, err = m.Warm(t.Context(), cache.WarmRequest{Modes: []string{"rust"}})
+		require.ErrorContains(t, err, `mode "rust" is not mounted`)
+	})
+
+	t.Run("missing metadata warms nothing", func(t *testing.T) {
+		m := m
+		m.CacheRoot = t.TempDir()
+		result, err := m.Warm(t.Context(), cache.WarmRequest{})
+		require.NoError(t, err)
+		require.Empty(t, result.Modes)
+	})
+}
@@ -1,22 +1,37 @@
 package cmd
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"maps"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/namespacelabs/spacectl/internal/cache"
 	"github.com/namespacelabs/spacectl/internal/cache/mode"
+	"github.com/namespacelabs/spacectl/internal/cache/remote"
+	"github.com/namespacelabs/spacectl/internal/cache/snippet"
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+	"github.com/namespacelabs/spacectl/internal/log"
 )
 
-const defaultCacheRootEnv = "NSC_CACHE_PATH"
+const (
+	defaultCacheRootEnv = "NSC_CACHE_PATH"
+	modesFileEnv        = "SPACECTL_MODES_FILE"
+)
 
 func NewCacheCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -24,189 +39,2557 @@ func NewCacheCmd() *cobra.Command {
 		Short: "Take full advantage of Namespace volumes and caching infrastructure",
 	}
 
+	cmd.PersistentFlags().Duration("command_timeout", 5*time.Minute, "Kill commands run by cache modes, e.g. to locate their caches, after this long. Zero disables the timeout.")
+	cmd.PersistentFlags().Bool("no_sudo", false, "Operate without sudo, using bindfs or symlinks instead of bind mounts. Enabled automatically when sudo is unavailable.")
+	cmd.PersistentFlags().Duration("lock_timeout", 10*time.Minute, "Wait this long for other jobs sharing the cache root to release its lock. Zero waits indefinitely.")
+	cmd.PersistentFlags().Bool("no_lock", false, "Change the cache root without locking it against other jobs.")
+
+	cmd.AddCommand(newCacheApplyCmd())
+	cmd.AddCommand(newCacheCleanCmd())
+	cmd.AddCommand(newCacheConfigCmd())
+	cmd.AddCommand(newCacheDedupeCmd())
+	cmd.AddCommand(newCacheDetectCmd())
+	cmd.AddCommand(newCacheDockerfileCmd())
+	cmd.AddCommand(newCacheDoctorCmd())
+	cmd.AddCommand(newCacheExportCmd())
+	cmd.AddCommand(newCacheImportCmd())
+	cmd.AddCommand(newCacheInitCmd())
+	cmd.AddCommand(newCacheKeyCmd())
+	cmd.AddCommand(newCacheMetadataCmd())
 	cmd.AddCommand(newCacheModesCmd())
 	cmd.AddCommand(newCacheMountCmd())
+	cmd.AddCommand(newCachePlanCmd())
+	cmd.AddCommand(newCachePruneCmd())
+	cmd.AddCommand(newCachePullCmd())
+	cmd.AddCommand(newCachePushCmd())
+	cmd.AddCommand(newCacheSaveCmd())
+	cmd.AddCommand(newCacheStatsCmd())
+	cmd.AddCommand(newCacheStatusCmd())
+	cmd.AddCommand(newCacheVerifyCmd())
+	cmd.AddCommand(newCacheWarmCmd())
+
+	registerModeCompletions(cmd)
 
 	return cmd
 }
 
-func newCacheModesCmd() *cobra.Command {
+func newCacheCleanCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "modes",
-		Short: "List available cache modes",
+		Use:   "clean",
+		Short: "Delete the cache of specific modes, paths or scopes",
 	}
 
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is deleted and only reports what would be done.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) whose cache to delete.")
+	paths := cmd.Flags().StringSlice("path", []string{}, "Mounted path(s) whose cache to delete.")
+	scopes := cmd.Flags().StringSlice("scope", []string{}, "Scope(s) whose caches to delete, e.g. merged branches.")
+	defaultScope := cmd.Flags().String("default_scope", "", "Scope whose caches live directly in the cache root. Detected from git if empty.")
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		modes := mode.DefaultModes()
-		detected, err := modes.Detect(cmd.Context(), mode.DetectRequest{})
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+		mounter.Modes, err = availableModes(*modesFile)
 		if err != nil {
 			return err
 		}
+		if len(*scopes) > 0 {
+			_, mounter.DefaultScope = resolveScopes(cmd.Context(), "", *defaultScope)
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
 
-		var w io.Writer = os.Stdout
-		if output, _ := cmd.Flags().GetString("output"); output == "json" {
-			return outputModesJSON(w, modes, detected)
+		result, err := mounter.Clean(cmd.Context(), cache.CleanRequest{
+			Modes:  *modes,
+			Paths:  *paths,
+			Scopes: *scopes,
+		})
+		if err != nil {
+			return err
 		}
 
-		outputModesText(w, modes, detected)
-		return nil
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputCleanText(os.Stdout, result) },
+		})
 	}
 
 	return cmd
 }
 
-func newCacheMountCmd() *cobra.Command {
+func newCacheConfigCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "mount",
-		Short: "Restore cache paths from a Namespace volume",
+		Use:   "config",
+		Short: "Manage the project cache configuration",
+	}
+
+	cmd.AddCommand(newCacheConfigValidateCmd())
+
+	return cmd
+}
+
+func newCacheConfigValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Validate a project cache configuration file",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path := cache.DefaultProjectConfigFile
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		cfg, err := cache.LoadProjectConfig(path, false)
+		if err != nil {
+			return err
+		}
+
+		// Modes are loaded like mount does without --modes_file.
+		modes, err := availableModes(cmp.Or(cfg.ModesFile, os.Getenv(modesFileEnv)))
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.Validate(modes); err != nil {
+			// Annotate each problem separately on the config file.
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range joined.Unwrap() {
+					slog.Error(e.Error(), slog.String(log.FileKey, path), slog.String(log.TitleKey, "Invalid cache config"))
+				}
+				return fmt.Errorf("%s is invalid", path)
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  map[string]any{"path": path, "valid": true},
+			Plain: func() { slog.Info(fmt.Sprintf("%s is valid", path)) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheDedupeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Hard link identical files across cache entries to reclaim space",
 	}
 
-	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, mounting of paths is skipped.")
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is linked and only reports what would be done.")
 	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
-	detectModes := cmd.Flags().StringSlice("detect", []string{}, "Detects cache mode(s) based on environment. Supply '*' to enable all detectors.")
-	manualModes := cmd.Flags().StringSlice("mode", []string{}, "Explicit cache mode(s) to enable.")
-	manualPaths := cmd.Flags().StringSlice("path", []string{}, "Explicit cache path(s) to enable.")
-	evalFile := cmd.Flags().String("eval_file", "", "Write a file that can be sourced to export environment variables.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) whose entries to deduplicate. Defaults to all entries.")
+	minSize := cmd.Flags().String("min_size", "4K", "Skip files smaller than this size (e.g. 64K), which save little when linked.")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		mounter, err := cache.NewMounter(*cacheRoot)
+		req := cache.DedupeRequest{Modes: *modes}
+		var err error
+		if req.MinSize, err = cache.ParseSize(*minSize); err != nil {
+			return fmt.Errorf("--min_size: %w", err)
+		}
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
 		if err != nil {
 			return err
 		}
 
-		// In dry-run mode, we skip mounting and only report what would be done.
 		mounter.DestructiveMode = !*dryRun
 		if !mounter.DestructiveMode {
 			slog.Info("Dry Run mode enabled.")
 		}
 
-		result, err := mounter.Mount(cmd.Context(), cache.MountRequest{
-			DetectAllModes: len(*detectModes) == 1 && (*detectModes)[0] == "*",
-			DetectModes:    *detectModes,
-			ManualModes:    *manualModes,
-			ManualPaths:    *manualPaths,
+		result, err := mounter.Dedupe(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Table: func() output.Table { return dedupeTable(result) },
+			Items: output.Items(result.Modes),
+			Plain: func() { outputDedupeText(os.Stdout, result) },
 		})
+	}
+
+	return cmd
+}
+
+func newCacheDockerfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dockerfile [Dockerfile]",
+		Short: "Print the BuildKit cache mounts of the planned cache paths, or add them to the RUN instructions of a Dockerfile",
+		Long:  "Plan the cache paths like `cache plan`, and translate them into `RUN --mount=type=cache` flags for container builds. Given a Dockerfile, print a diff that adds the cache mounts of each mode to the RUN instructions that run its tools.",
+		Args:  cobra.MaximumNArgs(1),
+	}
+
+	planFlags := addMountPlanFlags(cmd.Flags())
+	configFile := cmd.Flags().String("config", cache.DefaultProjectConfigFile, "Project config file with defaults for these flags.")
+	containerHome := cmd.Flags().String("container_home", snippet.ContainerHome, "Home directory of the user that the container build runs as, which cache paths in the home directory are moved to.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := cache.LoadProjectConfig(*configFile, !cmd.Flags().Changed("config"))
 		if err != nil {
 			return err
 		}
 
-		if *evalFile != "" {
-			if err := writeEvalFile(*evalFile, result); err != nil {
-				return fmt.Errorf("writing eval file: %w", err)
-			}
+		mounter, req, err := planFlags.resolve(cmd, cfg)
+		if err != nil {
+			return err
+		}
+
+		plan, err := mounter.Plan(cmd.Context(), req)
+		if err != nil {
+			return err
 		}
 
-		var w io.Writer = os.Stdout
-		if output, _ := cmd.Flags().GetString("output"); output == "json" {
-			return outputMountJSON(w, result)
+		home, _ := os.UserHomeDir()
+		mounts, skipped := snippet.CacheMounts(plan, home, *containerHome)
+		result := dockerfileResult{Mounts: mounts, Skipped: skipped}
+
+		if len(args) > 0 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("opening Dockerfile: %w", err)
+			}
+			defer f.Close()
+
+			result.Dockerfile = args[0]
+			if result.Changes, err = snippet.AnnotateDockerfile(f, plan, mounts); err != nil {
+				return err
+			}
 		}
 
-		outputMountText(w, result)
-		return nil
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Items: output.Items(result.Mounts),
+			Plain: func() { outputDockerfileText(os.Stdout, result) },
+		})
 	}
 
 	return cmd
 }
 
-func outputModesJSON(w io.Writer, modes, detected mode.Modes) error {
-	detectedSet := make(map[string]bool, len(detected))
-	for _, m := range detected {
-		detectedSet[m.Name()] = true
+// dockerfileResult is the output of `cache dockerfile`.
+type dockerfileResult struct {
+	Mounts  []snippet.CacheMount   `json:"mounts"`
+	Skipped []snippet.SkippedMount `json:"skipped,omitzero"`
+	// Dockerfile and Changes are set when a Dockerfile is annotated.
+	Dockerfile string                     `json:"dockerfile,omitzero"`
+	Changes    []snippet.DockerfileChange `json:"changes,omitzero"`
+}
+
+func newCacheDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that caches can be mounted in this environment, and suggest fixes",
 	}
 
-	result := make(map[string]map[string]bool, len(modes))
-	for _, m := range modes {
-		result[m.Name()] = map[string]bool{
-			"detected": detectedSet[m.Name()],
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		root, err := projectCacheRoot(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+		mounter, err := newMounter(cmd, root)
+		if err != nil {
+			// The doctor reports on cache roots that cannot be used.
+			mounter = cache.Mounter{CacheRoot: root, Exec: newExecutor(cmd)}
+			mounter.CommandTimeout, _ = cmd.Flags().GetDuration("command_timeout")
+			if root != "" {
+				mounter.CacheRoot, _ = filepath.Abs(root)
+			}
+		}
+		mounter.Modes, err = availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		result := mounter.Doctor(cmd.Context())
+		if err := writeOutput(cmd, output.Result{
+			Data:  result,
+			Table: func() output.Table { return doctorTable(result) },
+			Items: output.Items(result.Checks),
+			Plain: func() { outputDoctorText(os.Stdout, result) },
+		}); err != nil {
+			return err
+		}
+
+		if failed := result.Failed(); len(failed) > 0 {
+			return fmt.Errorf("%d doctor check(s) failed", len(failed))
 		}
+		return nil
 	}
 
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(map[string]any{"modes": result})
+	return cmd
 }
 
-func outputModesText(_ io.Writer, modes, detected mode.Modes) {
-	detectedSet := make(map[string]bool, len(detected))
-	for _, m := range detected {
-		detectedSet[m.Name()] = true
+func newCacheExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export <archive>",
+		Short: "Export cache entries to a tarball",
+		Long:  "Export cache entries and their metadata to a tarball. The compression is picked from the file extension: .tar, .tar.gz/.tgz or .tar.zst/.tzst (requires zstd).",
+		Args:  cobra.ExactArgs(1),
 	}
 
-	undetectedSet := make(map[string]bool, len(modes)-len(detected))
-	for _, name := range modes.Names() {
-		if !detectedSet[name] {
-			undetectedSet[name] = true
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to export. Defaults to all entries.")
+	paths := cmd.Flags().StringSlice("path", []string{}, "Mounted path(s) to export. Defaults to all entries.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		compression, err := cache.CompressionFromPath(args[0])
+		if err != nil {
+			return err
+		}
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		result, err := mounter.Export(cmd.Context(), args[0], compression, cache.ArchiveRequest{
+			Modes: *modes,
+			Paths: *paths,
+		})
+		if err != nil {
+			return err
 		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputArchiveText(os.Stdout, "Exported", result) },
+		})
 	}
 
-	slog.Info("Detected:")
-	if len(detectedSet) == 0 {
-		slog.Info("None")
-	} else {
-		keys := slices.Collect(maps.Keys(detectedSet))
-		slices.Sort(keys)
-		slog.Info(fmt.Sprintf("- %s", strings.Join(keys, "\n- ")))
+	return cmd
+}
+
+func newCacheImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Import cache entries from a tarball created by export",
+		Args:  cobra.ExactArgs(1),
 	}
 
-	slog.Info("Undetected:")
-	if len(undetectedSet) == 0 {
-		slog.Info("None")
-	} else {
-		keys := slices.Collect(maps.Keys(undetectedSet))
-		slices.Sort(keys)
-		slog.Info(fmt.Sprintf("- %s", strings.Join(keys, "\n- ")))
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is imported and only reports what would be done.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to import. Defaults to all entries.")
+	paths := cmd.Flags().StringSlice("path", []string{}, "Mounted path(s) to import. Defaults to all entries.")
+	overwrite := cmd.Flags().Bool("overwrite", false, "Replace entries that already exist in the cache root instead of skipping them.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
+
+		result, err := mounter.Import(cmd.Context(), args[0], cache.ArchiveRequest{
+			Modes:     *modes,
+			Paths:     *paths,
+			Overwrite: *overwrite,
+		})
+		if err != nil {
+			return err
+		}
+
+		verb := "Imported"
+		if result.DryRun {
+			verb = "Would import"
+		}
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputArchiveText(os.Stdout, verb, result) },
+		})
 	}
+
+	return cmd
 }
 
-func writeEvalFile(path string, result cache.MountResponse) error {
-	if len(result.Output.AddEnvs) == 0 {
-		return nil
+func newCacheInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "init <github|gitlab|buildkite|devcontainer>",
+		Short:     "Print the CI or devcontainer configuration that mounts the caches detected in this repository",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{string(snippet.GitHub), string(snippet.GitLab), string(snippet.Buildkite), string(snippet.Devcontainer)},
 	}
 
-	var b strings.Builder
-	keys := slices.Sorted(maps.Keys(result.Output.AddEnvs))
-	for _, k := range keys {
-		fmt.Fprintf(&b, "export %s=%q\n", k, result.Output.AddEnvs[k])
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+	write := cmd.Flags().Bool("write", false, "devcontainer only: patch "+snippet.DevcontainerFile+" instead of printing it.")
+	workDirs := addWorkDirFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		modes, err := availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		timeout, _ := cmd.Flags().GetDuration("command_timeout")
+		exec := mode.DefaultExecutor{CommandTimeout: timeout}
+		dirs, err := workDirs.find(cmd.Context(), modes, exec)
+		if err != nil {
+			return err
+		}
+
+		target := snippet.Target(args[0])
+		if *write && target != snippet.Devcontainer {
+			return fmt.Errorf("--write is only supported for devcontainer")
+		}
+
+		var detected []string
+		for _, dir := range dirs {
+			results, err := modes.DetectAll(cmd.Context(), mode.DetectRequest{Exec: exec, WorkDir: dir})
+			if err != nil {
+				return err
+			}
+			for i, m := range modes {
+				// Tools found on this machine tell nothing about the
+				// devcontainer, only project files do.
+				if target == snippet.Devcontainer && results[i].Confidence == mode.ConfidenceLow {
+					continue
+				}
+				if results[i].Detected && !slices.Contains(detected, m.Name()) {
+					detected = append(detected, m.Name())
+				}
+			}
+		}
+		slices.Sort(detected)
+
+		var existing []byte
+		if target == snippet.Devcontainer {
+			existing, err = os.ReadFile(snippet.DevcontainerFile)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("reading %s: %w", snippet.DevcontainerFile, err)
+			}
+		}
+
+		text, err := snippet.Generate(snippet.Request{
+			Target:    target,
+			Modes:     detected,
+			WorkDirs:  *workDirs.dirs,
+			Recursive: *workDirs.recursive,
+			Existing:  existing,
+		})
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{"target": target, "modes": detected, "snippet": text}
+		if !*write {
+			return writeOutput(cmd, output.Result{
+				Data:  data,
+				Plain: func() { fmt.Fprint(os.Stdout, text) },
+			})
+		}
+
+		if snippet.HasComments(existing) {
+			slog.Warn(fmt.Sprintf("Comments in %s are dropped", snippet.DevcontainerFile))
+		}
+		if err := os.MkdirAll(filepath.Dir(snippet.DevcontainerFile), 0o755); err != nil {
+			return fmt.Errorf("creating %s: %w", filepath.Dir(snippet.DevcontainerFile), err)
+		}
+		if err := os.WriteFile(snippet.DevcontainerFile, []byte(text), 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", snippet.DevcontainerFile, err)
+		}
+
+		data["path"] = snippet.DevcontainerFile
+		return writeOutput(cmd, output.Result{
+			Data:  data,
+			Plain: func() { slog.Info(fmt.Sprintf("Wrote %s", snippet.DevcontainerFile)) },
+		})
 	}
-	return os.WriteFile(path, []byte(b.String()), 0o644)
-}
 
-func outputMountJSON(w io.Writer, result cache.MountResponse) error {
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(result)
+	return cmd
 }
 
-func outputMountText(_ io.Writer, result cache.MountResponse) {
-	if len(result.Input.Modes) > 0 {
-		slog.Info(fmt.Sprintf("Used modes: %v", strings.Join(result.Input.Modes, " ")))
-	} else {
-		slog.Info("No modes used")
+func newCacheKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Print the cache keys computed from each mode's lockfiles",
 	}
 
-	if len(result.Input.Paths) > 0 {
-		slog.Info(fmt.Sprintf("Used paths: %v", strings.Join(result.Input.Paths, ", ")))
-	} else {
-		slog.Info("No paths used")
-	}
+	detectModes := cmd.Flags().StringSlice("detect", []string{}, "Detects cache mode(s) based on environment. Supply '*' to enable all detectors.")
+	excludeModes := cmd.Flags().StringSlice("exclude_mode", []string{}, "Cache mode(s) to skip during detection.")
+	manualModes := cmd.Flags().StringSlice("mode", []string{}, "Explicit cache mode(s) to compute keys for.")
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+	workDirs := addWorkDirFlags(cmd.Flags())
+	configFile := cmd.Flags().String("config", cache.DefaultProjectConfigFile, "Project config file with defaults for these flags.")
 
-	if len(result.Output.Mounts) > 0 {
-		slog.Info(fmt.Sprintf("%d directorie(s) mounted", len(result.Output.Mounts)))
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := cache.LoadProjectConfig(*configFile, !cmd.Flags().Changed("config"))
+		if err != nil {
+			return err
+		}
 
-		var cacheHits int
-		for _, mount := range result.Output.Mounts {
-			if mount.CacheHit {
-				cacheHits++
-			}
+		flags := cmd.Flags()
+		if !flags.Changed("detect") && !flags.Changed("mode") {
+			*detectModes = cfg.Detect
+			*manualModes = cfg.Modes
+		}
+		if !flags.Changed("exclude_mode") && len(cfg.ExcludeModes) > 0 {
+			*excludeModes = cfg.ExcludeModes
+		}
+		if !flags.Changed("modes_file") && cfg.ModesFile != "" {
+			*modesFile = cfg.ModesFile
+		}
+		workDirs.applyConfig(flags, cfg)
+
+		modes, err := availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		timeout, _ := cmd.Flags().GetDuration("command_timeout")
+		mounter := cache.Mounter{Exec: cache.DefaultExecutor{}, Modes: modes, CommandTimeout: timeout}
+		req := cache.MountRequest{
+			DetectAllModes: len(*detectModes) == 1 && (*detectModes)[0] == "*",
+			DetectModes:    *detectModes,
+			ExcludeModes:   *excludeModes,
+			ManualModes:    *manualModes,
 		}
-		slog.Info(fmt.Sprintf("Cache hit rate: %d/%d", cacheHits, len(result.Output.Mounts)))
+		workDirs.apply(&req)
+		result, err := mounter.Keys(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputKeysText(os.Stdout, result) },
+		})
 	}
 
-	slog.Info(fmt.Sprintf("%s of %s used", result.Output.DiskUsage.Used, result.Output.DiskUsage.Total))
+	return cmd
 }
 
-// isCI returns true if running in a CI environment.
-// Currently supports Github Actions and GitLab CI.
-func isCI() bool {
-	return os.Getenv("GITHUB_ACTIONS") == "true" || os.Getenv("GITLAB_CI") == "true"
+func newCacheModesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "modes",
+		Short: "List available cache modes",
+	}
+
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		modes, err := availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		detected, err := modes.Detect(cmd.Context(), mode.DetectRequest{})
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  modesData(modes, detected),
+			Table: func() output.Table { return modesTable(modes, detected) },
+			Items: output.Items(modeItems(modes, detected)),
+			Plain: func() { outputModesText(os.Stdout, modes, detected) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheDetectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "detect",
+		Short: "Report which cache modes are detected, and why the others are not",
+	}
+
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+	only := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to detect. Defaults to all modes.")
+	workDirs := addWorkDirFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		modes, err := availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+		if len(*only) > 0 {
+			if modes, err = modes.Filter(*only); err != nil {
+				return err
+			}
+		}
+
+		timeout, _ := cmd.Flags().GetDuration("command_timeout")
+		exec := mode.DefaultExecutor{CommandTimeout: timeout}
+		dirs, err := workDirs.find(cmd.Context(), modes, exec)
+		if err != nil {
+			return err
+		}
+
+		var detections []modeDetection
+		for _, dir := range dirs {
+			results, err := modes.DetectAll(cmd.Context(), mode.DetectRequest{Exec: exec, WorkDir: dir})
+			if err != nil {
+				return err
+			}
+			for i, m := range modes {
+				detections = append(detections, modeDetection{Mode: m.Name(), WorkDir: dir, DetectResult: results[i]})
+			}
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  map[string]any{"modes": detections},
+			Table: func() output.Table { return detectTable(detections) },
+			Items: output.Items(detections),
+			Plain: func() { outputDetectText(os.Stdout, detections) },
+		})
+	}
+
+	return cmd
+}
+
+// modeDetection is the detection result of a mode, as `cache detect`
+// reports it.
+type modeDetection struct {
+	Mode    string `json:"mode"`
+	WorkDir string `json:"work_dir,omitzero"`
+	mode.DetectResult
+}
+
+func newCacheMountCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mount",
+		Short: "Restore cache paths from a Namespace volume",
+	}
+
+	planFlags := addMountPlanFlags(cmd.Flags())
+	applyFlags := addMountApplyFlags(cmd.Flags())
+	configFile := cmd.Flags().String("config", cache.DefaultProjectConfigFile, "Project config file with defaults for these flags.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := cache.LoadProjectConfig(*configFile, !cmd.Flags().Changed("config"))
+		if err != nil {
+			return err
+		}
+
+		mounter, req, err := planFlags.resolve(cmd, cfg)
+		if err != nil {
+			return err
+		}
+		if err := applyFlags.configure(cmd, cfg, &mounter); err != nil {
+			return err
+		}
+
+		if mounter.DestructiveMode {
+			mounter.Observer = newProgressObserver("Mounting caches")
+		}
+		result, err := mounter.Mount(cmd.Context(), req)
+		return applyFlags.report(cmd, mounter, result, err)
+	}
+
+	return cmd
+}
+
+func newCachePlanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print what cache mount would do, without changing anything",
+		Long:  "Detect modes and resolve the paths to mount, the environment variables to export and the paths to remove, like `cache mount`, without changing anything. Save the plan with `-o json > plan.json` to review it, and execute it with `cache apply plan.json`.",
+	}
+
+	planFlags := addMountPlanFlags(cmd.Flags())
+	configFile := cmd.Flags().String("config", cache.DefaultProjectConfigFile, "Project config file with defaults for these flags.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := cache.LoadProjectConfig(*configFile, !cmd.Flags().Changed("config"))
+		if err != nil {
+			return err
+		}
+
+		mounter, req, err := planFlags.resolve(cmd, cfg)
+		if err != nil {
+			return err
+		}
+
+		plan, err := mounter.Plan(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  plan,
+			Table: func() output.Table { return planTable(plan) },
+			Items: output.Items(plan.Mounts),
+			Plain: func() { outputPlanText(os.Stdout, plan) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <plan.json>",
+		Short: "Mount cache paths as planned by cache plan",
+		Long:  "Execute a plan written by `cache plan -o json`, mounting its paths and removing the paths it removes. The cache root and scopes are taken from the plan. Use - to read the plan from stdin.",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	applyFlags := addMountApplyFlags(cmd.Flags())
+	configFile := cmd.Flags().String("config", cache.DefaultProjectConfigFile, "Project config file with defaults for these flags.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cfg, err := cache.LoadProjectConfig(*configFile, !cmd.Flags().Changed("config"))
+		if err != nil {
+			return err
+		}
+
+		plan, err := readPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		mounter, err := newMounter(cmd, plan.CacheRoot)
+		if err != nil {
+			return err
+		}
+		if err := applyFlags.configure(cmd, cfg, &mounter); err != nil {
+			return err
+		}
+
+		if mounter.DestructiveMode {
+			mounter.Observer = newProgressObserver("Mounting caches")
+		}
+		result, err := mounter.Apply(cmd.Context(), plan)
+		return applyFlags.report(cmd, mounter, result, err)
+	}
+
+	return cmd
+}
+
+// readPlan reads a plan written by `cache plan -o json`, from stdin for "-".
+func readPlan(path string) (cache.Plan, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return cache.Plan{}, fmt.Errorf("reading plan: %w", err)
+	}
+
+	var plan cache.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return cache.Plan{}, fmt.Errorf("parsing plan %s: %w", path, err)
+	}
+	if err := plan.Validate(); err != nil {
+		return cache.Plan{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// mountPlanFlags are the flags of `cache mount` and `cache plan` that decide
+// what is mounted.
+type mountPlanFlags struct {
+	cacheRoot     *string
+	detectModes   *[]string
+	excludeModes  *[]string
+	manualModes   *[]string
+	manualPaths   *[]string
+	exclude       *[]string
+	modesFile     *string
+	keyed         *bool
+	scope         *string
+	defaultScope  *string
+	mountStrategy *string
+	readOnly      *[]string
+	modeConfig    *[]string
+	preMountHook  *[]string
+	postMountHook *[]string
+	offline       *bool
+	static        *bool
+	workDirs      *workDirFlags
+}
+
+func addMountPlanFlags(flags *pflag.FlagSet) *mountPlanFlags {
+	return &mountPlanFlags{
+		cacheRoot:     flags.String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted."),
+		detectModes:   flags.StringSlice("detect", []string{}, "Detects cache mode(s) based on environment. Supply '*' to enable all detectors."),
+		excludeModes:  flags.StringSlice("exclude_mode", []string{}, "Cache mode(s) to skip during detection."),
+		manualModes:   flags.StringSlice("mode", []string{}, "Explicit cache mode(s) to enable."),
+		manualPaths:   flags.StringSlice("path", []string{}, "Explicit cache path(s) to enable."),
+		exclude:       flags.StringSlice("exclude", []string{}, "Glob pattern(s), relative to each cached path, to drop from the cache by cache save (e.g. '**/*.log')."),
+		modesFile:     flags.String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file."),
+		keyed:         flags.Bool("keyed", false, "Key caches by the contents of each mode's lockfiles."),
+		scope:         flags.String("scope", "", "Namespace caches by this scope, e.g. a branch name. Supply 'auto' to use the current branch."),
+		defaultScope:  flags.String("default_scope", "", "Scope whose caches live directly in the cache root and seed other scopes. Detected from git if empty."),
+		mountStrategy: flags.String("mount_strategy", string(cache.MountAuto), "How to mount cache paths: auto, bind, symlink, copy or overlay."),
+		readOnly:      flags.StringSlice("read_only", []string{}, "Mode(s) or path(s) whose caches are mounted read-only. Supply '*' for every mount."),
+		modeConfig:    flags.StringArray("mode_config", []string{}, "Override what a mode plans, as mode.ENV=value, mode.path=<path> or mode.drop_path=<path> (e.g. 'go.GOMODCACHE=/mnt/gomod'). Can be specified multiple times."),
+		preMountHook:  flags.StringArray("pre_mount_hook", []string{}, "Shell command to run before mounting. Can be specified multiple times."),
+		postMountHook: flags.StringArray("post_mount_hook", []string{}, "Shell command to run after mounting, with the exported environment (e.g. to warm a cache). Can be specified multiple times."),
+		offline:       flags.Bool("offline", false, "Plan cache modes without running their tools, which may reach the network, assuming the tools' default paths instead."),
+		static:        flags.Bool("static", false, "Plan cache modes without running anything, deriving paths from the environment and the tools' defaults, e.g. before the tools are installed. Implies --offline."),
+		workDirs:      addWorkDirFlags(flags),
+	}
+}
+
+// workDirFlags are the flags that choose the directories that modes are
+// detected and planned in.
+type workDirFlags struct {
+	dirs      *[]string
+	recursive *bool
+	maxDepth  *int
+}
+
+func addWorkDirFlags(flags *pflag.FlagSet) *workDirFlags {
+	return &workDirFlags{
+		dirs:      flags.StringSlice("workdir", []string{}, "Detect and plan cache modes in this directory instead of the current one, e.g. a subproject of a monorepo. Can be specified multiple times."),
+		recursive: flags.Bool("recursive", false, "Also detect and plan cache modes in the subprojects found beneath the working directories, skipping directories that git ignores."),
+		maxDepth:  flags.Int("max_depth", mode.DefaultMaxDepth, "How many directories deep --recursive looks for subprojects."),
+	}
+}
+
+func (f *workDirFlags) applyConfig(flags *pflag.FlagSet, cfg cache.ProjectConfig) {
+	if !flags.Changed("workdir") && len(cfg.WorkDirs) > 0 {
+		*f.dirs = cfg.WorkDirs
+	}
+	if !flags.Changed("recursive") && cfg.Recursive {
+		*f.recursive = cfg.Recursive
+	}
+	if !flags.Changed("max_depth") && cfg.MaxDepth > 0 {
+		*f.maxDepth = cfg.MaxDepth
+	}
+}
+
+// find returns the working directories to detect modes in, including the
+// subprojects found beneath them when recursive.
+func (f *workDirFlags) find(ctx context.Context, modes mode.Modes, exec mode.Executor) ([]string, error) {
+	dirs := *f.dirs
+	if len(dirs) == 0 {
+		dirs = []string{""}
+	}
+	if !*f.recursive {
+		return dirs, nil
+	}
+
+	return modes.FindProjects(ctx, mode.FindProjectsRequest{Exec: exec, WorkDirs: *f.dirs, MaxDepth: *f.maxDepth})
+}
+
+// apply sets the working directories of req.
+func (f *workDirFlags) apply(req *cache.MountRequest) {
+	req.WorkDirs = *f.dirs
+	req.Recursive = *f.recursive
+	req.MaxDepth = *f.maxDepth
+}
+
+// resolve returns the mounter and request that plan the mounts. Flags given
+// on the command line take precedence over the project config.
+func (f *mountPlanFlags) resolve(cmd *cobra.Command, cfg cache.ProjectConfig) (cache.Mounter, cache.MountRequest, error) {
+	flags := cmd.Flags()
+	if !flags.Changed("keyed") && cfg.Keyed {
+		*f.keyed = cfg.Keyed
+	}
+	if !flags.Changed("scope") && cfg.Scope != "" {
+		*f.scope = cfg.Scope
+	}
+	if !flags.Changed("default_scope") && cfg.DefaultScope != "" {
+		*f.defaultScope = cfg.DefaultScope
+	}
+	if !flags.Changed("mount_strategy") && cfg.MountStrategy != "" {
+		*f.mountStrategy = cfg.MountStrategy
+	}
+	if !flags.Changed("read_only") && len(cfg.ReadOnly) > 0 {
+		*f.readOnly = cfg.ReadOnly
+	}
+	if !flags.Changed("cache_root") && cfg.CacheRoot != "" {
+		*f.cacheRoot = cfg.CacheRoot
+	}
+	if !flags.Changed("detect") && len(cfg.Detect) > 0 {
+		*f.detectModes = cfg.Detect
+	}
+	if !flags.Changed("exclude_mode") && len(cfg.ExcludeModes) > 0 {
+		*f.excludeModes = cfg.ExcludeModes
+	}
+	if !flags.Changed("mode") && len(cfg.Modes) > 0 {
+		*f.manualModes = cfg.Modes
+	}
+	if !flags.Changed("path") && len(cfg.Paths) > 0 {
+		*f.manualPaths = cfg.Paths
+	}
+	if !flags.Changed("modes_file") && cfg.ModesFile != "" {
+		*f.modesFile = cfg.ModesFile
+	}
+	f.workDirs.applyConfig(flags, cfg)
+
+	mounter, err := newMounter(cmd, *f.cacheRoot)
+	if err != nil {
+		return cache.Mounter{}, cache.MountRequest{}, err
+	}
+	mounter.Modes, err = availableModes(*f.modesFile)
+	if err != nil {
+		return cache.Mounter{}, cache.MountRequest{}, err
+	}
+
+	mounter.Keyed = *f.keyed
+	mounter.ReadOnly = *f.readOnly
+	mounter.Strategy, err = cache.ParseMountStrategy(*f.mountStrategy)
+	if err != nil {
+		return cache.Mounter{}, cache.MountRequest{}, err
+	}
+	mounter.ModeStrategies, err = cfg.ModeStrategies()
+	if err != nil {
+		return cache.Mounter{}, cache.MountRequest{}, err
+	}
+	mounter.Scope, mounter.DefaultScope = resolveScopes(cmd.Context(), *f.scope, *f.defaultScope)
+
+	// Patterns from the command line apply on top of the project's.
+	excludes := maps.Clone(cfg.Exclude)
+	if len(*f.exclude) > 0 {
+		if excludes == nil {
+			excludes = map[string][]string{}
+		}
+		excludes[cache.AllModes] = append(excludes[cache.AllModes], *f.exclude...)
+	}
+
+	// Hooks from the command line run after the project's.
+	hooks := maps.Clone(cfg.Hooks)
+	if len(*f.preMountHook) > 0 || len(*f.postMountHook) > 0 {
+		if hooks == nil {
+			hooks = map[string]cache.Hooks{}
+		}
+		all := hooks[cache.AllModes]
+		all.PreMount = slices.Concat(all.PreMount, *f.preMountHook)
+		all.PostMount = slices.Concat(all.PostMount, *f.postMountHook)
+		hooks[cache.AllModes] = all
+	}
+
+	// Overrides from the command line apply on top of the project's.
+	modeConfig, err := cache.ParseModeConfig(*f.modeConfig)
+	if err != nil {
+		return cache.Mounter{}, cache.MountRequest{}, err
+	}
+
+	req := cache.MountRequest{
+		DetectAllModes: len(*f.detectModes) == 1 && (*f.detectModes)[0] == "*",
+		DetectModes:    *f.detectModes,
+		ExcludeModes:   *f.excludeModes,
+		ManualModes:    *f.manualModes,
+		ManualPaths:    *f.manualPaths,
+		Exclude:        excludes,
+		ModeConfig:     cache.MergeModeConfigs(cfg.ModeConfig, modeConfig),
+		Hooks:          hooks,
+		Offline:        *f.offline,
+		Static:         *f.static,
+	}
+	f.workDirs.apply(&req)
+	return mounter, req, nil
+}
+
+// mountApplyFlags are the flags of `cache mount` and `cache apply` that
+// decide how paths are mounted, and where the environment is exported.
+type mountApplyFlags struct {
+	dryRun            *bool
+	retries           *int
+	concurrency       *int
+	preserveOwnership *string
+	allowUnsafePaths  *bool
+	evalFile          *string
+	evalFormat        *string
+	noGitHubEnv       *bool
+	requireVolume     *bool
+	failOnMiss        *bool
+	minHitRate        *float64
+	minFree           *string
+	requireFree       *bool
+	evictHighWater    *float64
+	evictLowWater     *float64
+	telemetry         *telemetryFlags
+
+	format cache.EvalFormat
+	hits   cache.HitPolicy
+}
+
+func addMountApplyFlags(flags *pflag.FlagSet) *mountApplyFlags {
+	return &mountApplyFlags{
+		dryRun:            flags.Bool("dry_run", !isCI(), "If true, mounting of paths is skipped."),
+		retries:           flags.Int("mount_retries", 2, "Times to retry a failed mount, removal or directory creation, with exponential backoff."),
+		concurrency:       flags.Int("concurrency", 0, "Maximum number of paths to mount at once. Defaults to the number of CPUs."),
+		preserveOwnership: flags.String("preserve_ownership", string(cache.OwnershipNone), "Apply the owner and mode of each mount path to its cache: none, top or recursive."),
+		allowUnsafePaths:  flags.Bool("allow_unsafe_paths", false, "Allow mounting over and removing system directories, the home directory and filesystem roots."),
+		evalFile:          flags.String("eval_file", "", "Write a file that can be sourced to export environment variables."),
+		evalFormat:        flags.String("eval_format", string(cache.EvalBash), "Syntax of the eval file: bash, fish, powershell, dotenv or github_env."),
+		noGitHubEnv:       flags.Bool("no_github_env", false, "Do not export environment variables to later steps through $GITHUB_ENV when running in GitHub Actions."),
+		requireVolume:     flags.Bool("require_volume", false, "Fail instead of warning when the cache root is a plain directory rather than a mounted volume."),
+		minFree:           flags.String("min_free", "", "Warn when the cache volume has less space than this available before mounting, e.g. 5G, or after caches grow as estimated."),
+		requireFree:       flags.Bool("require_free", false, "Fail instead of warning when the cache volume has less space than --min_free available before mounting."),
+		evictHighWater:    flags.Float64("evict_high_water", 0, "Evict the least recently used caches before mounting when the cache volume is fuller than this percentage."),
+		evictLowWater:     flags.Float64("evict_low_water", 0, "Percentage that eviction frees the cache volume down to. Defaults to 10 points below --evict_high_water."),
+		failOnMiss:        flags.Bool("fail_on_miss", false, "Exit with status 3 when any mounted path missed its cache."),
+		minHitRate:        flags.Float64("min_hit_rate", 0, "Exit with status 3 when fewer than this fraction of the mounted paths, from 0 to 1, hit their cache."),
+		telemetry:         addTelemetryFlags(flags),
+	}
+}
+
+// configure sets how mounter mounts paths. Flags given on the command line
+// take precedence over the project config.
+func (f *mountApplyFlags) configure(cmd *cobra.Command, cfg cache.ProjectConfig, mounter *cache.Mounter) error {
+	flags := cmd.Flags()
+	if !flags.Changed("preserve_ownership") && cfg.PreserveOwnership != "" {
+		*f.preserveOwnership = cfg.PreserveOwnership
+	}
+	if !flags.Changed("eval_file") && cfg.EvalFile != "" {
+		*f.evalFile = cfg.EvalFile
+	}
+	if !flags.Changed("eval_format") && cfg.EvalFormat != "" {
+		*f.evalFormat = cfg.EvalFormat
+	}
+
+	var err error
+	mounter.Concurrency = *f.concurrency
+	mounter.Retries = *f.retries
+	mounter.AllowUnsafePaths = *f.allowUnsafePaths
+	if !flags.Changed("require_volume") && cfg.RequireVolume {
+		*f.requireVolume = cfg.RequireVolume
+	}
+	mounter.RequireVolume = *f.requireVolume
+	if !flags.Changed("min_free") && cfg.MinFree != "" {
+		*f.minFree = cfg.MinFree
+	}
+	if !flags.Changed("require_free") && cfg.RequireFree {
+		*f.requireFree = cfg.RequireFree
+	}
+	if *f.minFree != "" {
+		if mounter.MinFree, err = cache.ParseSize(*f.minFree); err != nil {
+			return fmt.Errorf("--min_free: %w", err)
+		}
+	}
+	mounter.RequireFree = *f.requireFree
+	if !flags.Changed("evict_high_water") && cfg.EvictHighWater != 0 {
+		*f.evictHighWater = cfg.EvictHighWater
+	}
+	if !flags.Changed("evict_low_water") && cfg.EvictLowWater != 0 {
+		*f.evictLowWater = cfg.EvictLowWater
+	}
+	mounter.EvictHighWater, mounter.EvictLowWater = *f.evictHighWater, *f.evictLowWater
+	if err := mounter.ValidateEviction(); err != nil {
+		return err
+	}
+	mounter.AllowedRoots = cfg.AllowedRoots
+	mounter.PreserveOwnership, err = cache.ParseOwnershipMode(*f.preserveOwnership)
+	if err != nil {
+		return err
+	}
+	f.format, err = cache.ParseEvalFormat(*f.evalFormat)
+	if err != nil {
+		return err
+	}
+	f.hits = cache.HitPolicy{FailOnMiss: *f.failOnMiss, MinHitRate: *f.minHitRate}
+	if err := f.hits.Validate(); err != nil {
+		return err
+	}
+	if err := f.telemetry.configure(cmd, cfg); err != nil {
+		return err
+	}
+
+	// In dry-run mode, we skip mounting and only report what would be done.
+	mounter.DestructiveMode = !*f.dryRun
+	if !mounter.DestructiveMode {
+		slog.Info("Dry Run mode enabled.")
+	}
+	return nil
+}
+
+// report exports the environment of a mount, prints its result and exports
+// its metrics when asked to. Paths that failed to mount are reported along
+// with the others, before mountErr is returned. A mount that succeeded fails
+// when its caches were colder than the hit policy allows.
+func (f *mountApplyFlags) report(cmd *cobra.Command, mounter cache.Mounter, result cache.MountResponse, mountErr error) error {
+	if mountErr != nil && len(result.Output.Errors) == 0 {
+		return mountErr
+	}
+
+	if *f.evalFile != "" {
+		if err := writeEvalFile(*f.evalFile, f.format, result); err != nil {
+			return fmt.Errorf("writing eval file: %w", err)
+		}
+	}
+
+	// Later steps of a GitHub Actions job only see variables written to
+	// $GITHUB_ENV, unless the eval file already was.
+	githubEnv := githubEnvFile()
+	if *f.evalFile == githubEnv && f.format == cache.EvalGitHubEnv {
+		githubEnv = ""
+	}
+	if githubEnv != "" && mounter.DestructiveMode && !*f.noGitHubEnv {
+		if err := writeEvalFile(githubEnv, cache.EvalGitHubEnv, result); err != nil {
+			return fmt.Errorf("writing $GITHUB_ENV: %w", err)
+		}
+	}
+
+	if err := writeOutput(cmd, output.Result{
+		Data:  result,
+		Table: func() output.Table { return mountTable(result) },
+		Items: output.Items(result.Output.Mounts),
+		Plain: func() { outputMountText(os.Stdout, result) },
+	}); err != nil {
+		return err
+	}
+	f.telemetry.export(cmd.Context(), cmd, mounter, result)
+	if mountErr != nil {
+		return mountErr
+	}
+	if err := f.hits.Check(result); err != nil {
+		return exitError{err: err, code: exitColdCache}
+	}
+	return nil
+}
+
+func newCachePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete or compress cache entries that are unused or exceed a size budget",
+	}
+
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is deleted and only reports what would be done.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	maxAgeDays := cmd.Flags().Int("max_age_days", 0, "Delete cache entries not used in this many days.")
+	maxSize := cmd.Flags().String("max_size", "", "Delete least recently used cache entries until the cache fits this size (e.g. 20G).")
+	compress := addCompressFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var req cache.PruneRequest
+		var err error
+		if req.CompressAfter, req.Compression, err = compress.parse(); err != nil {
+			return err
+		}
+		if *maxAgeDays < 0 {
+			return fmt.Errorf("--max_age_days must not be negative")
+		}
+		req.MaxAge = time.Duration(*maxAgeDays) * 24 * time.Hour
+		if *maxSize != "" {
+			size, err := cache.ParseSize(*maxSize)
+			if err != nil {
+				return fmt.Errorf("--max_size: %w", err)
+			}
+			req.MaxSize = size
+		}
+		if req.MaxAge == 0 && req.MaxSize == 0 && req.CompressAfter == 0 {
+			return fmt.Errorf("at least one of --max_age_days, --max_size or --compress_after_days is required")
+		}
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
+
+		// Sizing and deleting large caches takes a while.
+		progress := newProgressObserver("Pruning caches")
+		mounter.Observer = progress
+		result, err := mounter.Prune(cmd.Context(), req, time.Now())
+		progress.progress.Stop()
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputPruneText(os.Stdout, result) },
+		})
+	}
+
+	return cmd
+}
+
+// compressFlags are the flags of `cache prune` and `cache save` that compress
+// rarely used cache entries.
+type compressFlags struct {
+	afterDays   *int
+	compression *string
+}
+
+func addCompressFlags(flags *pflag.FlagSet) compressFlags {
+	return compressFlags{
+		afterDays:   flags.Int("compress_after_days", 0, "Compress cache entries not used in this many days, to be decompressed on their next mount."),
+		compression: flags.String("compression", string(cache.CompressionZstd), "Compression of rarely used cache entries: zstd, which requires the zstd binary, or gzip."),
+	}
+}
+
+func (f compressFlags) parse() (time.Duration, cache.Compression, error) {
+	if *f.afterDays < 0 {
+		return 0, "", fmt.Errorf("--compress_after_days must not be negative")
+	}
+	compression := cache.Compression(*f.compression)
+	if compression != cache.CompressionZstd && compression != cache.CompressionGzip {
+		return 0, "", fmt.Errorf("--compression: expected zstd or gzip, got %q", compression)
+	}
+	return time.Duration(*f.afterDays) * 24 * time.Hour, compression, nil
+}
+
+func newCachePushCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push <url>",
+		Short: "Export cache entries and upload them to remote storage",
+		Long:  "Export cache entries and upload them to s3://bucket/key, gs://bucket/object or azblob://account/container/blob, using the aws, gcloud or az CLI respectively. The compression is picked from the file extension.",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to push. Defaults to all entries.")
+	paths := cmd.Flags().StringSlice("path", []string{}, "Mounted path(s) to push. Defaults to all entries.")
+	opts := remoteFlags(cmd)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		storage, archive, err := remoteArchive(args[0], *opts)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(filepath.Dir(archive))
+
+		compression, err := cache.CompressionFromPath(archive)
+		if err != nil {
+			return err
+		}
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		result, err := mounter.Export(cmd.Context(), archive, compression, cache.ArchiveRequest{
+			Modes: *modes,
+			Paths: *paths,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := storage.Upload(cmd.Context(), archive); err != nil {
+			return err
+		}
+		result.Archive = args[0]
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputArchiveText(os.Stdout, "Pushed", result) },
+		})
+	}
+
+	return cmd
+}
+
+func newCachePullCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pull <url>",
+		Short: "Download cache entries from remote storage and import them",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is imported and only reports what would be done.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to import. Defaults to all entries.")
+	paths := cmd.Flags().StringSlice("path", []string{}, "Mounted path(s) to import. Defaults to all entries.")
+	overwrite := cmd.Flags().Bool("overwrite", false, "Replace entries that already exist in the cache root instead of skipping them.")
+	opts := remoteFlags(cmd)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		storage, archive, err := remoteArchive(args[0], *opts)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(filepath.Dir(archive))
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
+
+		if err := storage.Download(cmd.Context(), archive); err != nil {
+			return err
+		}
+
+		result, err := mounter.Import(cmd.Context(), archive, cache.ArchiveRequest{
+			Modes:     *modes,
+			Paths:     *paths,
+			Overwrite: *overwrite,
+		})
+		if err != nil {
+			return err
+		}
+		result.Archive = args[0]
+
+		verb := "Pulled"
+		if result.DryRun {
+			verb = "Would pull"
+		}
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputArchiveText(os.Stdout, verb, result) },
+		})
+	}
+
+	return cmd
+}
+
+func remoteFlags(cmd *cobra.Command) *remote.Options {
+	opts := remote.DefaultOptions()
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 0, "Number of parts to transfer in parallel. Not supported for s3:// URLs.")
+	cmd.Flags().IntVar(&opts.Attempts, "attempts", opts.Attempts, "Number of times to try the transfer before giving up.")
+	return &opts
+}
+
+// remoteArchive returns the storage for rawURL and a local path to stage the
+// archive at, named like the remote object so that its extension is kept.
+func remoteArchive(rawURL string, opts remote.Options) (remote.Storage, string, error) {
+	storage, err := remote.New(rawURL, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing remote URL: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "spacectl-cache-")
+	if err != nil {
+		return nil, "", err
+	}
+	return storage, filepath.Join(dir, path.Base(u.Path)), nil
+}
+
+func newCacheSaveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "Finalize the caches mounted by this job, to run at the end of a job",
+	}
+
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is trimmed or recorded and only reports what would be done.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	trim := cmd.Flags().StringSlice("trim", []string{}, "Cache mode(s) to trim with their own tools before saving. Supply '*' to trim all mounted modes that support it.")
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+	compress := addCompressFlags(cmd.Flags())
+	dedupe := cmd.Flags().Bool("dedupe", false, "Hard link identical files across all cache entries after saving.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		req := cache.SaveRequest{Trim: *trim, Dedupe: *dedupe}
+		var err error
+		if req.CompressAfter, req.Compression, err = compress.parse(); err != nil {
+			return err
+		}
+
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+		mounter.Modes, err = availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
+
+		result, err := mounter.Save(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputSaveText(os.Stdout, result) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheWarmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "warm",
+		Short: "Fill the mounted caches by downloading dependencies, e.g. in a dedicated job",
+	}
+
+	dryRun := cmd.Flags().Bool("dry_run", !isCI(), "If true, nothing is run and only reports the commands that would run.")
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to warm. Defaults to all mounted modes that support it.")
+	concurrency := cmd.Flags().Int("concurrency", 0, "Maximum number of modes to warm at once. Defaults to the number of CPUs.")
+	timeout := cmd.Flags().Duration("timeout", 30*time.Minute, "Kill each warm command after this long. Zero disables the timeout.")
+	modesFile := cmd.Flags().String("modes_file", os.Getenv(modesFileEnv), "Load custom cache modes from this file.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+		mounter.Modes, err = availableModes(*modesFile)
+		if err != nil {
+			return err
+		}
+
+		mounter.DestructiveMode = !*dryRun
+		if !mounter.DestructiveMode {
+			slog.Info("Dry Run mode enabled.")
+		}
+
+		result, err := mounter.Warm(cmd.Context(), cache.WarmRequest{Modes: *modes, Concurrency: *concurrency, Timeout: *timeout})
+		if err != nil {
+			return err
+		}
+
+		if err := writeOutput(cmd, output.Result{
+			Data:  result,
+			Table: func() output.Table { return warmTable(result) },
+			Items: output.Items(result.Modes),
+			Plain: func() { outputWarmText(os.Stdout, result) },
+		}); err != nil {
+			return err
+		}
+
+		if failed := result.Failed(); len(failed) > 0 {
+			return fmt.Errorf("failed to warm %s", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+
+	return cmd
+}
+
+func newCacheStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how much space each cache mode uses",
+	}
+
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	largest := cmd.Flags().Int("largest", 10, "Number of largest cache entries to list.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		result, err := mounter.Stats(cmd.Context(), cache.StatsRequest{Largest: *largest})
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Table: func() output.Table { return statsTable(result) },
+			Items: output.Items(result.Modes),
+			Plain: func() { outputStatsText(os.Stdout, result) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show which cache paths are mounted and how much space they use",
+	}
+
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		result, err := mounter.Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Table: func() output.Table { return statusTable(result) },
+			Items: output.Items(result.Mounts),
+			Plain: func() { outputStatusText(os.Stdout, result) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheMetadataCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metadata",
+		Short: "Show the cache metadata recorded by previous jobs",
+	}
+
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		result, err := mounter.Metadata()
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputMetadataText(os.Stdout, result) },
+		})
+	}
+
+	return cmd
+}
+
+func newCacheVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check cache entries against checksums recorded when they were saved",
+	}
+
+	cacheRoot := cmd.Flags().String("cache_root", os.Getenv(defaultCacheRootEnv), "Override the root path where cache volumes are mounted.")
+	modes := cmd.Flags().StringSlice("mode", []string{}, "Cache mode(s) to check. Defaults to all recorded entries.")
+	record := cmd.Flags().Bool("record", false, "Record checksums of the current cache contents instead of verifying them.")
+	quarantine := cmd.Flags().Bool("quarantine", false, "Move corrupt cache entries aside instead of failing.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		mounter, err := newProjectMounter(cmd, *cacheRoot)
+		if err != nil {
+			return err
+		}
+
+		req := cache.VerifyRequest{Modes: *modes, Quarantine: *quarantine}
+
+		var result cache.VerifyResponse
+		if *record {
+			result, err = mounter.RecordManifests(cmd.Context(), req)
+		} else {
+			result, err = mounter.Verify(cmd.Context(), req)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := writeOutput(cmd, output.Result{
+			Data:  result,
+			Plain: func() { outputVerifyText(os.Stdout, result) },
+		}); err != nil {
+			return err
+		}
+
+		if corrupt := result.Corrupt(); len(corrupt) > 0 && !*quarantine {
+			return fmt.Errorf("%d corrupt cache entrie(s)", len(corrupt))
+		}
+		return nil
+	}
+
+	return cmd
+}
+
+// newProjectMounter creates a mounter for commands that only need a cache
+// root, which falls back to the project config when the flag is not set.
+func newProjectMounter(cmd *cobra.Command, cacheRoot string) (cache.Mounter, error) {
+	cacheRoot, err := projectCacheRoot(cmd, cacheRoot)
+	if err != nil {
+		return cache.Mounter{}, err
+	}
+	return newMounter(cmd, cacheRoot)
+}
+
+// projectCacheRoot returns the cache root of the project configuration,
+// unless --cache_root is set.
+func projectCacheRoot(cmd *cobra.Command, cacheRoot string) (string, error) {
+	if cmd.Flags().Changed("cache_root") {
+		return cacheRoot, nil
+	}
+	cfg, err := cache.LoadProjectConfig(cache.DefaultProjectConfigFile, true)
+	if err != nil {
+		return "", err
+	}
+	return cmp.Or(cfg.CacheRoot, cacheRoot), nil
+}
+
+// newMounter creates a mounter configured by the flags shared by all cache
+// commands.
+func newMounter(cmd *cobra.Command, cacheRoot string) (cache.Mounter, error) {
+	mounter, err := cache.NewMounter(cacheRoot)
+	if err != nil {
+		return cache.Mounter{}, err
+	}
+	mounter.Exec = newExecutor(cmd)
+	mounter.CommandTimeout, _ = cmd.Flags().GetDuration("command_timeout")
+	mounter.ToolVersion = cmd.Root().Version
+	noLock, _ := cmd.Flags().GetBool("no_lock")
+	mounter.LockRoot = !noLock
+	mounter.LockTimeout, _ = cmd.Flags().GetDuration("lock_timeout")
+	return mounter, nil
+}
+
+// writeOutput prints the result of a command in the format of --output.
+func writeOutput(cmd *cobra.Command, r output.Result) error {
+	return output.Write(os.Stdout, output.FromFlags(cmd.Flags()), r)
+}
+
+// newExecutor runs rootless with --no_sudo, or when sudo is unavailable.
+func newExecutor(cmd *cobra.Command) cache.DefaultExecutor {
+	noSudo, _ := cmd.Flags().GetBool("no_sudo")
+	if !noSudo && cache.DetectRootless(cmd.Context()) {
+		slog.Debug("sudo is unavailable, operating rootless")
+		noSudo = true
+	}
+	return cache.DefaultExecutor{Rootless: noSudo}
+}
+
+// resolveScopes detects the current branch for the "auto" scope, and the
+// default branch unless a default scope is given.
+func resolveScopes(ctx context.Context, scope, defaultScope string) (string, string) {
+	if scope == cache.AutoScope {
+		if scope = cache.DetectScope(ctx); scope == "" {
+			slog.Warn("could not detect the current branch, caches are not scoped")
+		}
+	}
+	if defaultScope == "" {
+		defaultScope = cache.DetectDefaultScope(ctx)
+	}
+	return scope, defaultScope
+}
+
+// availableModes returns the built-in modes plus custom modes and any
+// installed mode plugins. Without an explicit modes file, the default one is
+// loaded if it exists.
+func availableModes(modesFile string) (mode.Modes, error) {
+	allowMissing := modesFile == ""
+	if allowMissing {
+		modesFile = mode.DefaultCustomModesFile()
+	}
+
+	modes := mode.DefaultModes()
+	if modesFile != "" {
+		custom, err := mode.LoadCustomModes(modesFile, allowMissing)
+		if err != nil {
+			return nil, err
+		}
+
+		modes, err = modes.WithCustomModes(custom)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return modes.WithPlugins(mode.DiscoverPlugins(mode.PluginDirs())), nil
+}
+
+// modesData is the JSON output of `cache modes`.
+func modesData(modes, detected mode.Modes) map[string]any {
+	detectedSet := make(map[string]bool, len(detected))
+	for _, m := range detected {
+		detectedSet[m.Name()] = true
+	}
+
+	result := make(map[string]map[string]bool, len(modes))
+	for _, m := range modes {
+		result[m.Name()] = map[string]bool{
+			"detected": detectedSet[m.Name()],
+		}
+	}
+	return map[string]any{"modes": result}
+}
+
+// modeItems lists modes for ndjson output, one object per mode.
+func modeItems(modes, detected mode.Modes) []map[string]any {
+	var items []map[string]any
+	for _, name := range modes.Names() {
+		items = append(items, map[string]any{"name": name, "detected": slices.Contains(detected.Names(), name)})
+	}
+	return items
+}
+
+func modesTable(modes, detected mode.Modes) output.Table {
+	t := output.Table{Header: []string{"MODE", "DETECTED"}}
+	for _, name := range modes.Names() {
+		t.Rows = append(t.Rows, []string{name, fmt.Sprint(slices.Contains(detected.Names(), name))})
+	}
+	return t
+}
+
+func outputModesText(_ io.Writer, modes, detected mode.Modes) {
+	detectedSet := make(map[string]bool, len(detected))
+	for _, m := range detected {
+		detectedSet[m.Name()] = true
+	}
+
+	undetectedSet := make(map[string]bool, len(modes)-len(detected))
+	for _, name := range modes.Names() {
+		if !detectedSet[name] {
+			undetectedSet[name] = true
+		}
+	}
+
+	outputModeList("Detected:", slices.Sorted(maps.Keys(detectedSet)))
+	outputModeList("Undetected:", slices.Sorted(maps.Keys(undetectedSet)))
+}
+
+// modeNames highlights mode names for terminals.
+func modeNames(names []string) []string {
+	highlighted := make([]string, len(names))
+	for i, name := range names {
+		highlighted[i] = log.Mode(name)
+	}
+	return highlighted
+}
+
+// outputModeList logs a titled list of modes, folded in CI logs.
+func outputModeList(title string, names []string) {
+	defer log.StartGroup(title)()
+	if len(names) == 0 {
+		slog.Info("None")
+	} else {
+		slog.Info(fmt.Sprintf("- %s", strings.Join(modeNames(names), "\n- ")))
+	}
+}
+
+func detectTable(detections []modeDetection) output.Table {
+	withDirs := slices.ContainsFunc(detections, func(d modeDetection) bool { return d.WorkDir != "" })
+
+	t := output.Table{Header: []string{"MODE", "DETECTED", "CONFIDENCE", "REASON", "MISSING"}}
+	if withDirs {
+		t.Header = append([]string{"WORKDIR"}, t.Header...)
+	}
+	for _, d := range detections {
+		row := []string{d.Mode, fmt.Sprint(d.Detected), cmp.Or(string(d.Confidence), "-"), cmp.Or(string(d.Reason), "-"), cmp.Or(d.Missing, "-")}
+		if withDirs {
+			row = append([]string{d.WorkDir}, row...)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func outputDetectText(_ io.Writer, detections []modeDetection) {
+	var dirs []string
+	for _, d := range detections {
+		if !slices.Contains(dirs, d.WorkDir) {
+			dirs = append(dirs, d.WorkDir)
+		}
+	}
+	for _, dir := range dirs {
+		if dir != "" {
+			slog.Info(fmt.Sprintf("In %s:", dir))
+		}
+		outputDirDetections(slices.DeleteFunc(slices.Clone(detections), func(d modeDetection) bool { return d.WorkDir != dir }))
+	}
+}
+
+// outputDirDetections prints the detections of a working directory.
+func outputDirDetections(detections []modeDetection) {
+	var detected []string
+	for _, d := range detections {
+		if d.Detected {
+			detected = append(detected, d.Mode)
+		}
+	}
+	outputModeList("Detected:", detected)
+	for _, d := range detections {
+		if d.Confidence == mode.ConfidenceLow {
+			slog.Info(fmt.Sprintf("%s was detected from its tools alone, the project may not use it", log.Mode(d.Mode)))
+		}
+	}
+
+	func() {
+		defer log.StartGroup("Not detected:")()
+		for _, d := range detections {
+			if !d.Detected {
+				slog.Info(fmt.Sprintf("- %s: %s", log.Mode(d.Mode), detectionText(d.DetectResult)))
+			}
+		}
+	}()
+
+	for _, d := range detections {
+		for _, warning := range d.Warnings {
+			slog.Warn(warning, slog.String("mode", d.Mode))
+		}
+	}
+}
+
+// detectionText explains in words why a mode was not detected.
+func detectionText(result mode.DetectResult) string {
+	var text string
+	switch result.Reason {
+	case mode.ReasonMissingBinary:
+		text = fmt.Sprintf("%s is not on PATH", result.Missing)
+	case mode.ReasonMissingFile:
+		var checked []string
+		for _, check := range result.Checks {
+			if check.Kind == mode.CheckFile && !check.Found {
+				checked = append(checked, cmp.Or(check.Path, check.Name))
+			}
+		}
+		text = fmt.Sprintf("no project file found, checked %s", strings.Join(checked, ", "))
+	case mode.ReasonCommandFailed:
+		text = fmt.Sprintf("%q failed", result.Missing)
+	default:
+		text = "the project does not use it"
+	}
+	if result.Details != "" {
+		text = result.Details
+	}
+	return text
+}
+
+func planTable(plan cache.Plan) output.Table {
+	t := output.Table{Header: []string{"MODE", "PATH", "CACHE PATH", "SOURCE", "STRATEGY", "READ ONLY"}}
+	for _, m := range plan.Mounts {
+		path := m.Path
+		if m.CacheDir {
+			path = "-"
+		}
+		t.Rows = append(t.Rows, []string{cmp.Or(m.Mode, "-"), path, m.CachePath, cmp.Or(m.Source.String(), "-"), cmp.Or(string(m.Strategy), "-"), fmt.Sprint(m.ReadOnly)})
+	}
+	return t
+}
+
+// outputDockerfileText prints the RUN flags that mount the caches of each
+// mode or, for a Dockerfile, a diff that adds them.
+func outputDockerfileText(w io.Writer, result dockerfileResult) {
+	for _, m := range result.Skipped {
+		slog.Info(fmt.Sprintf("Not mounting %s in container builds: %s", m.Path, m.Reason))
+	}
+	if len(result.Mounts) == 0 {
+		slog.Info("No cache paths to mount in container builds.")
+		return
+	}
+
+	if result.Dockerfile == "" {
+		var modes []string
+		byMode := map[string][]string{}
+		for _, m := range result.Mounts {
+			if _, ok := byMode[m.Mode]; !ok {
+				modes = append(modes, m.Mode)
+			}
+			byMode[m.Mode] = append(byMode[m.Mode], m.Directive())
+		}
+		for _, m := range modes {
+			fmt.Fprintf(w, "# %s\nRUN %s <command>\n", cmp.Or(m, "custom"), strings.Join(byMode[m], " "))
+		}
+		return
+	}
+
+	if len(result.Changes) == 0 {
+		slog.Info(fmt.Sprintf("The RUN instructions of %s already mount the caches of the tools they run.", result.Dockerfile))
+		return
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", result.Dockerfile, result.Dockerfile)
+	for _, c := range result.Changes {
+		before := strings.Split(c.Before, "\n")
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@ %s\n", c.Line, len(before), c.Line, len(before), strings.Join(c.Modes, ", "))
+		for _, l := range before {
+			fmt.Fprintf(w, "-%s\n", l)
+		}
+		for l := range strings.SplitSeq(c.After, "\n") {
+			fmt.Fprintf(w, "+%s\n", l)
+		}
+	}
+}
+
+func outputPlanText(_ io.Writer, plan cache.Plan) {
+	outputModeList("Planned modes:", plan.ModeOrder)
+	if plan.Scope != "" {
+		slog.Info(fmt.Sprintf("Cache scope: %s", plan.Scope))
+	}
+
+	for _, m := range plan.Mounts {
+		switch {
+		case m.CacheDir:
+			slog.Info(fmt.Sprintf("Would create cache dir %s", m.CachePath))
+		case m.Mode != "":
+			details := []string{log.Mode(m.Mode)}
+			if m.Source.Kind != "" {
+				details = append(details, m.Source.String())
+			}
+			if m.Assumed {
+				details = append(details, "assumed offline")
+			}
+			slog.Info(fmt.Sprintf("Would mount %s at %s (%s)", m.CachePath, m.Path, strings.Join(details, ", ")))
+		default:
+			slog.Info(fmt.Sprintf("Would mount %s at %s", m.CachePath, m.Path))
+		}
+	}
+	for _, d := range plan.Deduplicated {
+		slog.Info(fmt.Sprintf("Would skip %s, covered by %s", d.Path, d.CoveredBy))
+	}
+	for _, path := range plan.RemovePaths {
+		slog.Info(fmt.Sprintf("Would remove %s", path))
+	}
+	for _, k := range slices.Sorted(maps.Keys(plan.AddEnvs)) {
+		slog.Info(fmt.Sprintf("Would export %s=%s", k, plan.AddEnvs[k]))
+	}
+	for _, hook := range plan.Hooks {
+		slog.Info(fmt.Sprintf("Would run %s hook: %s", hook.Stage, hook.Command))
+	}
+	for _, issue := range plan.Warnings {
+		slog.Warn(issue.Message, issueAttrs(issue)...)
+	}
+}
+
+func writeEvalFile(path string, format cache.EvalFormat, result cache.MountResponse) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if format.Appends() {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := cache.WriteEval(f, format, result.EvalEnvs()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func mountTable(result cache.MountResponse) output.Table {
+	t := output.Table{Header: []string{"MODE", "MOUNT PATH", "CACHE PATH", "HIT", "STRATEGY", "DURATION", "GROWTH"}}
+	for _, m := range result.Output.Mounts {
+		growth := "-"
+		if m.EstimatedGrowthBytes > 0 {
+			growth = cache.FormatSize(m.EstimatedGrowthBytes)
+		}
+		t.Rows = append(t.Rows, []string{
+			cmp.Or(m.Mode, "-"), cmp.Or(m.MountPath, "-"), m.CachePath, fmt.Sprint(m.CacheHit),
+			cmp.Or(string(m.Strategy), "-"), (time.Duration(m.DurationMs) * time.Millisecond).String(), growth,
+		})
+	}
+	return t
+}
+
+func outputMountText(_ io.Writer, result cache.MountResponse) {
+	if len(result.Input.Modes) > 0 {
+		slog.Info(fmt.Sprintf("Used modes: %v", strings.Join(modeNames(result.Input.Modes), " ")))
+	} else {
+		slog.Info("No modes used")
+	}
+
+	if len(result.Input.Paths) > 0 {
+		slog.Info(fmt.Sprintf("Used paths: %v", strings.Join(result.Input.Paths, ", ")))
+	} else {
+		slog.Info("No paths used")
+	}
+
+	if len(result.Output.Mounts) > 0 {
+		slog.Info(fmt.Sprintf("%d directorie(s) mounted", len(result.Output.Mounts)))
+
+		hits, total := result.Hits()
+		slog.Info(fmt.Sprintf("Cache hit rate: %d/%d", hits, total))
+		if result.Output.Scope != "" {
+			slog.Info(fmt.Sprintf("Cache scope: %s", result.Output.Scope))
+		}
+
+		strategies := map[cache.MountStrategy]int{}
+		for _, mount := range result.Output.Mounts {
+			if mount.Strategy != "" {
+				strategies[mount.Strategy]++
+			}
+		}
+		for _, s := range slices.Sorted(maps.Keys(strategies)) {
+			slog.Info(fmt.Sprintf("%d path(s) mounted with %s", strategies[s], s))
+		}
+
+		for _, mount := range result.Output.Mounts {
+			if mount.RestoredFrom != "" {
+				slog.Info(fmt.Sprintf("Restored %s from %s", mount.MountPath, mount.RestoredFrom))
+			}
+			if mount.Decompressed {
+				slog.Info(fmt.Sprintf("Decompressed the rarely used cache of %s", mount.MountPath))
+			}
+		}
+
+		var assumed []string
+		for _, mount := range result.Output.Mounts {
+			if mount.Assumed && !slices.Contains(assumed, mount.Mode) {
+				assumed = append(assumed, mount.Mode)
+			}
+		}
+		if len(assumed) > 0 {
+			slog.Info(fmt.Sprintf("Assumed the default paths of %s offline", strings.Join(assumed, ", ")))
+		}
+	}
+
+	for _, hook := range result.Output.Hooks {
+		if hook.ExitCode == 0 {
+			slog.Info(fmt.Sprintf("Ran %s hook: %s", hook.Stage, hook.Command))
+		}
+		if out := strings.TrimSpace(hook.Stdout); out != "" {
+			slog.Debug(fmt.Sprintf("%s hook output: %s", hook.Stage, out))
+		}
+	}
+
+	if result.Output.DiskUsage != nil {
+		outputDiskUsageText(*result.Output.DiskUsage)
+	}
+	for _, mount := range result.Output.Mounts {
+		if mount.EstimatedGrowthBytes > 0 {
+			slog.Debug(fmt.Sprintf("%s is expected to grow by %s", mount.MountPath, cache.FormatSize(mount.EstimatedGrowthBytes)))
+		}
+	}
+	if len(result.Output.Evicted) > 0 {
+		var freed int64
+		for _, e := range result.Output.Evicted {
+			freed += e.Size.Bytes
+			slog.Debug(fmt.Sprintf("Evicting %s (%s)", e.CachePath, cache.FormatSize(e.Size.Bytes)))
+		}
+		verb := "Evicted"
+		if !result.Output.DestructiveMode {
+			verb = "Would evict"
+		}
+		slog.Info(fmt.Sprintf("%s %d cache(s) of %s to free the cache volume", verb, len(result.Output.Evicted), cache.FormatSize(freed)))
+	}
+	if growth := result.Output.EstimatedGrowthBytes; growth > 0 {
+		slog.Info(fmt.Sprintf("Caches are expected to grow by %s", cache.FormatSize(growth)))
+	}
+	outputTimingText(result)
+
+	for _, issue := range result.Output.Warnings {
+		slog.Warn(issue.Message, issueAttrs(issue)...)
+	}
+	for _, issue := range result.Output.Errors {
+		slog.Error(issue.Message, issueAttrs(issue)...)
+	}
+}
+
+// outputTimingText summarizes how long the mount took, and which modes took
+// the longest to detect, plan and mount.
+func outputTimingText(result cache.MountResponse) {
+	if result.Output.DurationMs == 0 {
+		return
+	}
+
+	type modeTime struct {
+		mode                string
+		detect, plan, mount time.Duration
+	}
+	byMode := map[string]*modeTime{}
+	get := func(name string) *modeTime {
+		if byMode[name] == nil {
+			byMode[name] = &modeTime{mode: name}
+		}
+		return byMode[name]
+	}
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+	for _, t := range result.Output.Timings {
+		get(t.Mode).detect, get(t.Mode).plan = ms(t.DetectMs), ms(t.PlanMs)
+	}
+	for _, mount := range result.Output.Mounts {
+		slog.Debug(fmt.Sprintf("Mounted %s in %s", mount.MountPath, ms(mount.DurationMs)))
+		if mount.Mode != "" {
+			get(mount.Mode).mount += ms(mount.DurationMs)
+		}
+	}
+
+	modes := slices.Collect(maps.Values(byMode))
+	total := func(t *modeTime) time.Duration { return t.detect + t.plan + t.mount }
+	slices.SortFunc(modes, func(a, b *modeTime) int {
+		return cmp.Or(cmp.Compare(total(b), total(a)), strings.Compare(a.mode, b.mode))
+	})
+
+	var slowest []string
+	for _, t := range modes[:min(len(modes), 3)] {
+		if total(t) == 0 {
+			break
+		}
+		var parts []string
+		for _, p := range []struct {
+			name string
+			d    time.Duration
+		}{{"detect", t.detect}, {"plan", t.plan}, {"mount", t.mount}} {
+			if p.d > 0 {
+				parts = append(parts, fmt.Sprintf("%s %s", p.name, p.d))
+			}
+		}
+		slowest = append(slowest, fmt.Sprintf("%s %s (%s)", t.mode, total(t), strings.Join(parts, ", ")))
+	}
+
+	msg := fmt.Sprintf("Took %s", ms(result.Output.DurationMs))
+	if len(slowest) > 0 {
+		msg += "; slowest modes: " + strings.Join(slowest, ", ")
+	}
+	slog.Info(msg)
+}
+
+func issueAttrs(issue cache.MountIssue) []any {
+	var attrs []any
+	if issue.Mode != "" {
+		attrs = append(attrs, slog.String("mode", issue.Mode))
+	}
+	if issue.Path != "" {
+		attrs = append(attrs, slog.String("path", issue.Path))
+	}
+	return attrs
+}
+
+func outputDiskUsageText(usage cache.DiskUsage) {
+	slog.Info(fmt.Sprintf("%s of %s used (%.1f%%), %s available", usage.Used, usage.Total, usage.UsagePercent, usage.Available))
+}
+
+func outputKeysText(w io.Writer, result cache.KeysResponse) {
+	for _, k := range result.Keys {
+		name := k.Mode
+		if k.WorkDir != "" {
+			name = k.WorkDir + ":" + k.Mode
+		}
+		if k.Key == "" {
+			fmt.Fprintf(w, "%s\t-\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, k.Key, strings.Join(k.Files, ","))
+	}
+}
+
+func outputArchiveText(_ io.Writer, verb string, result cache.ArchiveResponse) {
+	var imported int
+	var size cache.DirSize
+	for _, e := range result.Entries {
+		name := e.MountPath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.MountPath, e.Mode)
+		}
+		if e.Skipped {
+			slog.Info(fmt.Sprintf("- %s: skipped, already cached", name))
+			continue
+		}
+
+		imported++
+		size.Bytes += e.Size.Bytes
+		size.Files += e.Size.Files
+		slog.Info(fmt.Sprintf("- %s: %s in %d file(s)", name, cache.FormatSize(e.Size.Bytes), e.Size.Files))
+	}
+
+	slog.Info(fmt.Sprintf("%s %d entrie(s), %s in %d file(s)", verb, imported, cache.FormatSize(size.Bytes), size.Files))
+}
+
+func outputCleanText(_ io.Writer, result cache.CleanResponse) {
+	if len(result.Deleted) == 0 {
+		slog.Info("No cache entries to delete")
+		return
+	}
+
+	verb := "Deleted"
+	if result.DryRun {
+		verb = "Would delete"
+	}
+
+	for _, e := range result.Deleted {
+		name := e.CachePath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.CachePath, e.Mode)
+		}
+		slog.Info(fmt.Sprintf("- %s: %s", name, cache.FormatSize(e.Size.Bytes)))
+	}
+
+	slog.Info(fmt.Sprintf("%s %d entrie(s), freeing %s", verb, len(result.Deleted), cache.FormatSize(result.FreedBytes)))
+}
+
+func outputPruneText(_ io.Writer, result cache.PruneResponse) {
+	verb := "Deleted"
+	if result.DryRun {
+		verb = "Would delete"
+	}
+
+	outputCompressedText(result.Compressed, result.DryRun)
+	if len(result.Deleted) == 0 {
+		if len(result.Compressed) == 0 {
+			slog.Info("No cache entries to delete")
+		}
+		return
+	}
+
+	for _, e := range result.Deleted {
+		name := e.CachePath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.CachePath, e.Mode)
+		}
+		slog.Info(fmt.Sprintf("- %s: %s, last used %s, %s", name, cache.FormatSize(e.Size.Bytes), e.LastUsed.Format(time.DateTime), e.Reason))
+	}
+
+	slog.Info(fmt.Sprintf("%s %d entrie(s), freeing %s of %s", verb, len(result.Deleted), cache.FormatSize(result.FreedBytes), cache.FormatSize(result.TotalBytes)))
+}
+
+// outputCompressedText lists the cache entries that prune or save
+// compressed.
+func outputCompressedText(entries []cache.PruneEntry, dryRun bool) {
+	if len(entries) == 0 {
+		return
+	}
+	var before, after int64
+	for _, e := range entries {
+		name := e.CachePath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.CachePath, e.Mode)
+		}
+		line := fmt.Sprintf("- %s: %s, last used %s", name, cache.FormatSize(e.Size.Bytes), e.LastUsed.Format(time.DateTime))
+		if !dryRun {
+			line += fmt.Sprintf(", compressed to %s", cache.FormatSize(e.CompressedBytes))
+		}
+		slog.Info(line)
+		before += e.Size.Bytes
+		after += e.CompressedBytes
+	}
+	if dryRun {
+		slog.Info(fmt.Sprintf("Would compress %d entrie(s) of %s", len(entries), cache.FormatSize(before)))
+		return
+	}
+	slog.Info(fmt.Sprintf("Compressed %d entrie(s) from %s to %s", len(entries), cache.FormatSize(before), cache.FormatSize(after)))
+}
+
+func dedupeTable(result cache.DedupeResponse) output.Table {
+	t := output.Table{Header: []string{"MODE", "LINKED", "RECLAIMED"}}
+	for _, m := range result.Modes {
+		t.Rows = append(t.Rows, []string{cmp.Or(m.Mode, "-"), fmt.Sprint(m.Linked), cache.FormatSize(m.ReclaimedBytes)})
+	}
+	return t
+}
+
+func outputDedupeText(_ io.Writer, result cache.DedupeResponse) {
+	if result.Linked == 0 {
+		slog.Info(fmt.Sprintf("No duplicate files among %d file(s)", result.Files))
+		return
+	}
+
+	for _, m := range result.Modes {
+		name := m.Mode
+		if name == "" {
+			name = "(paths)"
+		}
+		slog.Info(fmt.Sprintf("- %s: %d file(s), %s", name, m.Linked, cache.FormatSize(m.ReclaimedBytes)))
+	}
+
+	verb := "Linked"
+	if result.DryRun {
+		verb = "Would link"
+	}
+	slog.Info(fmt.Sprintf("%s %d duplicate file(s) of %d, reclaiming %s", verb, result.Linked, result.Files, cache.FormatSize(result.ReclaimedBytes)))
+}
+
+func doctorTable(result cache.DoctorResponse) output.Table {
+	t := output.Table{Header: []string{"CHECK", "STATUS", "MESSAGE", "FIX"}}
+	for _, c := range result.Checks {
+		t.Rows = append(t.Rows, []string{c.Name, string(c.Status), c.Message, cmp.Or(c.Fix, "-")})
+	}
+	return t
+}
+
+func outputDoctorText(_ io.Writer, result cache.DoctorResponse) {
+	for _, c := range result.Checks {
+		var attrs []any
+		if c.Fix != "" {
+			attrs = append(attrs, slog.String("fix", c.Fix))
+		}
+		line := fmt.Sprintf("%s: %s", c.Name, c.Message)
+		switch c.Status {
+		case cache.DoctorFail:
+			slog.Error(line, attrs...)
+		case cache.DoctorWarn:
+			slog.Warn(line, attrs...)
+		default:
+			slog.Info(line, attrs...)
+		}
+	}
+}
+
+func warmTable(result cache.WarmResponse) output.Table {
+	t := output.Table{Header: []string{"MODE", "COMMANDS", "DURATION", "ERROR"}}
+	for _, m := range result.Modes {
+		t.Rows = append(t.Rows, []string{
+			m.Mode, cmp.Or(strings.Join(m.Commands, "; "), "-"),
+			(time.Duration(m.DurationMs) * time.Millisecond).String(), cmp.Or(m.Error, "-"),
+		})
+	}
+	return t
+}
+
+func outputWarmText(_ io.Writer, result cache.WarmResponse) {
+	if len(result.Modes) == 0 {
+		slog.Info("No mounted cache modes to warm")
+		return
+	}
+
+	verb := "Warmed"
+	if result.DryRun {
+		verb = "Would warm"
+	}
+	for _, m := range result.Modes {
+		duration := time.Duration(m.DurationMs) * time.Millisecond
+		switch {
+		case m.Error != "":
+			slog.Error(fmt.Sprintf("Failed to warm %s after %s: %s", log.Mode(m.Mode), duration, m.Error))
+		case len(m.Commands) == 0:
+			slog.Info(fmt.Sprintf("Nothing to warm for %s", log.Mode(m.Mode)))
+		case result.DryRun:
+			slog.Info(fmt.Sprintf("%s %s with %s", verb, log.Mode(m.Mode), strings.Join(m.Commands, "; ")))
+		default:
+			slog.Info(fmt.Sprintf("%s %s with %s in %s", verb, log.Mode(m.Mode), strings.Join(m.Commands, "; "), duration))
+		}
+	}
+	slog.Info(fmt.Sprintf("Warming took %s", time.Duration(result.DurationMs)*time.Millisecond))
+}
+
+func outputSaveText(_ io.Writer, result cache.SaveResponse) {
+	if len(result.Entries) == 0 {
+		slog.Info("No mounted cache entries to save")
+		outputCompressedText(result.Compressed, result.DryRun)
+		if result.Dedupe != nil {
+			outputDedupeText(os.Stdout, *result.Dedupe)
+		}
+		return
+	}
+
+	for _, e := range result.Entries {
+		name := e.MountPath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.MountPath, log.Mode(e.Mode))
+		}
+		outcome := "miss"
+		if e.CacheHit {
+			outcome = "hit"
+		}
+		line := fmt.Sprintf("- %s: %s, %s in %d file(s)", name, log.Hit(e.CacheHit, outcome), cache.FormatSize(e.Size.Bytes), e.Size.Files)
+		if e.Excluded > 0 {
+			line += fmt.Sprintf(", %d excluded path(s) dropped", e.Excluded)
+		}
+		slog.Info(line)
+	}
+
+	trimVerb, saveVerb := "Trimmed", "Saved"
+	if result.DryRun {
+		trimVerb, saveVerb = "Would trim", "Would save"
+	}
+	if len(result.Trimmed) > 0 {
+		slog.Info(fmt.Sprintf("%s modes: %s", trimVerb, strings.Join(result.Trimmed, " ")))
+	}
+
+	slog.Info(fmt.Sprintf("Cache hit rate: %d/%d", result.Hits, result.Hits+result.Misses))
+	slog.Info(fmt.Sprintf("%s %d entrie(s), %s in total", saveVerb, len(result.Entries), cache.FormatSize(result.Total.Bytes)))
+	outputCompressedText(result.Compressed, result.DryRun)
+	if result.Dedupe != nil {
+		outputDedupeText(os.Stdout, *result.Dedupe)
+	}
+}
+
+func statsTable(result cache.StatsResponse) output.Table {
+	t := output.Table{Header: []string{"MODE", "ENTRIES", "SIZE", "FILES"}}
+	for _, m := range result.Modes {
+		t.Rows = append(t.Rows, []string{cmp.Or(m.Mode, "-"), fmt.Sprint(m.Entries), cache.FormatSize(m.Size.Bytes), fmt.Sprint(m.Size.Files)})
+	}
+	return t
+}
+
+func outputStatsText(_ io.Writer, result cache.StatsResponse) {
+	if result.Entries == 0 {
+		slog.Info(fmt.Sprintf("No cache entries recorded in %s", result.CacheRoot))
+		return
+	}
+
+	slog.Info(fmt.Sprintf("%d cache entrie(s) in %s: %s in %d file(s)", result.Entries, result.CacheRoot, cache.FormatSize(result.Total.Bytes), result.Total.Files))
+
+	slog.Info("By mode:")
+	for _, m := range result.Modes {
+		name := m.Mode
+		if name == "" {
+			name = "(paths)"
+		}
+		slog.Info(fmt.Sprintf("- %s: %s in %d file(s), %d entrie(s)", name, cache.FormatSize(m.Size.Bytes), m.Size.Files, m.Entries))
+	}
+
+	if len(result.Largest) > 0 {
+		slog.Info("Largest entries:")
+		for _, e := range result.Largest {
+			line := fmt.Sprintf("- %s: %s in %d file(s)", e.MountPath, cache.FormatSize(e.Size.Bytes), e.Size.Files)
+			if e.Compressed {
+				line += ", compressed"
+			}
+			slog.Info(line)
+		}
+	}
+
+	if c := result.Compressed; c != nil {
+		slog.Info(fmt.Sprintf("%d compressed entrie(s): %s, from %s, saving %s", c.Entries, cache.FormatSize(c.Bytes), cache.FormatSize(c.OriginalBytes), cache.FormatSize(c.SavedBytes)))
+	}
+
+	if result.DiskUsage != nil {
+		outputDiskUsageText(*result.DiskUsage)
+	}
+}
+
+func outputMetadataText(_ io.Writer, result cache.MetadataResponse) {
+	metadata := result.Metadata
+	if result.SourceVersion == 0 {
+		slog.Info(fmt.Sprintf("No cache metadata at %s", result.Path))
+		return
+	}
+
+	header := fmt.Sprintf("Cache metadata at %s: version %d", result.Path, result.SourceVersion)
+	if result.SourceVersion != metadata.Version {
+		header += fmt.Sprintf(" (read as version %d)", metadata.Version)
+	}
+	if metadata.ToolVersion != "" {
+		header += fmt.Sprintf(", written by spacectl %s", metadata.ToolVersion)
+	}
+	slog.Info(fmt.Sprintf("%s, last updated %s", header, metadata.UpdatedAt))
+
+	for _, mountPath := range slices.Sorted(maps.Keys(metadata.UserRequest)) {
+		entry := metadata.UserRequest[mountPath]
+
+		name := mountPath
+		if entry.CacheFramework != nil {
+			name = fmt.Sprintf("%s (%s)", mountPath, log.Mode(*entry.CacheFramework))
+		}
+		slog.Info(fmt.Sprintf("- %s", name))
+		slog.Info(fmt.Sprintf("    source: %s", entry.Source))
+
+		mount := log.Hit(entry.CacheHit, fmt.Sprintf("cache hit: %t", entry.CacheHit))
+		if entry.Strategy != "" {
+			mount += fmt.Sprintf(", strategy: %s", entry.Strategy)
+		}
+		if entry.MountedAt != "" {
+			mount += fmt.Sprintf(", mounted at %s in %dms", entry.MountedAt, entry.MountDurationMs)
+		}
+		slog.Info(fmt.Sprintf("    %s", mount))
+
+		if len(entry.Exclude) > 0 {
+			slog.Info(fmt.Sprintf("    exclude: %s", strings.Join(entry.Exclude, ", ")))
+		}
+		if stats := entry.Stats; stats.SavedAt != "" {
+			slog.Info(fmt.Sprintf("    saved at %s: %s in %d file(s), %d hit(s), %d miss(es)",
+				stats.SavedAt, cache.FormatSize(stats.Bytes), stats.Files, stats.Hits, stats.Misses))
+		}
+	}
+}
+
+func statusTable(result cache.StatusResponse) output.Table {
+	t := output.Table{Header: []string{"MODE", "MOUNT PATH", "CACHE PATH", "STATE", "SIZE", "HIT"}}
+	for _, m := range result.Mounts {
+		state := "not mounted"
+		switch {
+		case m.Stale:
+			state = "stale"
+		case m.Mounted:
+			state = "mounted"
+		}
+		t.Rows = append(t.Rows, []string{cmp.Or(m.Mode, "-"), m.MountPath, m.CachePath, state, cmp.Or(m.SizeHuman, "-"), fmt.Sprint(m.CacheHit)})
+	}
+	return t
+}
+
+func outputStatusText(_ io.Writer, result cache.StatusResponse) {
+	if len(result.Mounts) == 0 {
+		slog.Info(fmt.Sprintf("No cache paths recorded in %s", result.CacheRoot))
+	} else {
+		slog.Info(fmt.Sprintf("Cache paths recorded in %s (last updated %s):", result.CacheRoot, result.UpdatedAt))
+	}
+
+	var mounted, stale int
+	for _, m := range result.Mounts {
+		var state string
+		switch {
+		case m.Stale:
+			stale++
+			state = "stale"
+		case m.Mounted:
+			mounted++
+			state = "mounted"
+		default:
+			state = "not mounted"
+		}
+
+		name := m.MountPath
+		if m.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", m.MountPath, log.Mode(m.Mode))
+		}
+
+		if m.Stale {
+			slog.Info(fmt.Sprintf("- %s: %s", name, state))
+		} else {
+			slog.Info(fmt.Sprintf("- %s: %s, %s in %d file(s), %s", name, state, m.SizeHuman, m.Size.Files,
+				log.Hit(m.CacheHit, fmt.Sprintf("cache hit: %t", m.CacheHit))))
+		}
+	}
+
+	if len(result.Mounts) > 0 {
+		slog.Info(fmt.Sprintf("%d/%d path(s) mounted, %d stale", mounted, len(result.Mounts), stale))
+	}
+
+	if result.DiskUsage != nil {
+		outputDiskUsageText(*result.DiskUsage)
+	}
+}
+
+func outputVerifyText(_ io.Writer, result cache.VerifyResponse) {
+	if len(result.Entries) == 0 {
+		slog.Info(fmt.Sprintf("No cache entries recorded in %s", result.CacheRoot))
+		return
+	}
+
+	for _, e := range result.Entries {
+		name := e.MountPath
+		if e.Mode != "" {
+			name = fmt.Sprintf("%s (%s)", e.MountPath, e.Mode)
+		}
+
+		switch e.Status {
+		case cache.VerifyStatusCorrupt, cache.VerifyStatusQuarantined:
+			msg := fmt.Sprintf("- %s: %s, %d problem(s)", name, e.Status, len(e.Problems))
+			if e.QuarantinePath != "" {
+				msg += fmt.Sprintf(", moved to %s", e.QuarantinePath)
+			}
+			slog.Warn(msg)
+			for _, p := range e.Problems {
+				slog.Warn(fmt.Sprintf("  %s", p))
+			}
+		default:
+			slog.Info(fmt.Sprintf("- %s: %s, %d file(s)", name, e.Status, e.Files))
+		}
+	}
+}
+
+// isCI returns true if running in a CI environment.
+// Currently supports Github Actions and GitLab CI.
+func isCI() bool {
+	return isGitHubActions() || os.Getenv("GITLAB_CI") == "true"
+}
+
+func isGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// githubEnvFile returns the file that GitHub Actions reads the environment
+// of later steps from, or "" outside GitHub Actions.
+func githubEnvFile() string {
+	if !isGitHubActions() {
+		return ""
+	}
+	return os.Getenv("GITHUB_ENV")
 }
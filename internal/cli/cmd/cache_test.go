@@ -2,6 +2,7 @@ package cmd_test
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -106,6 +107,19 @@ func TestIntegration_CacheMount(t *testing.T) {
 			t.Fatal("expected mounts in response")
 		}
 	})
+
+	// Verifies that a cold cache exits with its own status, which pipelines
+	// rely on to tell it from a failure.
+	t.Run("fail on miss", func(t *testing.T) {
+		t.Setenv("NSC_CACHE_PATH", t.TempDir())
+
+		cmd := exec.Command(binary, "cache", "mount", "--dry_run=true", "-o=json", "--fail_on_miss", "--path="+t.TempDir())
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			t.Fatalf("--fail_on_miss with a cold cache: got %v, want exit status 3", err)
+		}
+	})
 }
 
 type mountResponse struct {
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func NewCompletionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion <bash|zsh|fish|powershell>",
+		Short: "Print the shell completion script",
+		Long: `Print the script that completes commands, flags and cache mode names in a shell.
+
+  bash:       source <(spacectl completion bash)
+  zsh:        spacectl completion zsh > "${fpath[1]}/_spacectl"
+  fish:       spacectl completion fish > ~/.config/fish/completions/spacectl.fish
+  powershell: spacectl completion powershell | Out-String | Invoke-Expression`,
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		root := cmd.Root()
+		switch args[0] {
+		case "bash":
+			return root.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return root.GenZshCompletion(os.Stdout)
+		case "fish":
+			return root.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return root.GenPowerShellCompletionWithDesc(os.Stdout)
+		default:
+			return fmt.Errorf("unknown shell %q", args[0])
+		}
+	}
+
+	return cmd
+}
+
+// modeFlags are the flags that take mode names, and whether they also take
+// '*' for all modes.
+var modeFlags = map[string]bool{
+	"mode":         false,
+	"detect":       true,
+	"exclude_mode": false,
+	"trim":         true,
+}
+
+// registerModeCompletions completes mode names for the mode flags of cmd and
+// its subcommands.
+func registerModeCompletions(cmd *cobra.Command) {
+	for name, wildcard := range modeFlags {
+		if cmd.LocalFlags().Lookup(name) != nil {
+			_ = cmd.RegisterFlagCompletionFunc(name, completeModes(wildcard))
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		registerModeCompletions(sub)
+	}
+}
+
+// completeModes completes the last of a comma separated list of mode names,
+// including the custom modes of --modes_file.
+func completeModes(wildcard bool) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		var modesFile string
+		if f := cmd.Flags().Lookup("modes_file"); f != nil {
+			modesFile = f.Value.String()
+		}
+		modes, err := availableModes(modesFile)
+		if err != nil {
+			modes = mode.DefaultModes()
+		}
+
+		i := strings.LastIndex(toComplete, ",")
+		prefix, partial := toComplete[:i+1], toComplete[i+1:]
+		given := strings.Split(prefix, ",")
+
+		names := modes.Names()
+		if wildcard && prefix == "" {
+			names = append([]string{"*"}, names...)
+		}
+
+		var completions []cobra.Completion
+		for _, name := range names {
+			if strings.HasPrefix(name, partial) && !slices.Contains(given, name) {
+				completions = append(completions, prefix+name)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
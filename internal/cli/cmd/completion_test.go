@@ -0,0 +1,56 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cli/cmd"
+)
+
+func TestModeCompletion(t *testing.T) {
+	t.Setenv("SPACECTL_MODES_FILE", "")
+
+	complete := func(t *testing.T, args ...string) []string {
+		t.Helper()
+		root := &cobra.Command{Use: "spacectl"}
+		root.AddCommand(cmd.NewCacheCmd(), cmd.NewCompletionCmd())
+
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetErr(io.Discard)
+		root.SetArgs(append([]string{cobra.ShellCompRequestCmd}, args...))
+		require.NoError(t, root.Execute())
+
+		// The last line is the directive.
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		return lines[:len(lines)-1]
+	}
+
+	t.Run("mode names", func(t *testing.T) {
+		require.Equal(t, []string{"pnpm"}, complete(t, "cache", "mount", "--mode", "pn"))
+	})
+
+	t.Run("last of a list", func(t *testing.T) {
+		got := complete(t, "cache", "plan", "--detect", "go,np")
+		require.Equal(t, []string{"go,npm"}, got)
+	})
+
+	t.Run("unreadable modes file", func(t *testing.T) {
+		require.Equal(t, []string{"pnpm"}, complete(t, "cache", "detect", "--modes_file", "/nonexistent", "--mode", "pn"))
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		require.Contains(t, complete(t, "cache", "save", "--trim", ""), "*")
+		require.NotContains(t, complete(t, "cache", "save", "--trim", "go,"), "go,*")
+		require.NotContains(t, complete(t, "cache", "mount", "--mode", ""), "*")
+	})
+
+	t.Run("shells", func(t *testing.T) {
+		require.Equal(t, []string{"bash", "zsh", "fish", "powershell"}, complete(t, "completion", ""))
+	})
+}
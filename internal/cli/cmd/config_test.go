@@ -0,0 +1,59 @@
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cli/cmd"
+)
+
+func TestCacheConfigValidate(t *testing.T) {
+	validate := func(t *testing.T, path string) error {
+		t.Helper()
+		root := &cobra.Command{Use: "spacectl", SilenceErrors: true, SilenceUsage: true}
+		root.AddCommand(cmd.NewCacheCmd())
+		root.SetOut(io.Discard)
+		root.SetErr(io.Discard)
+		root.SetArgs([]string{"cache", "config", "validate", path})
+		return root.Execute()
+	}
+
+	// writeProject writes a config that uses the custom mode of a modes
+	// file next to it.
+	writeProject := func(t *testing.T, modesFile string) string {
+		dir := filepath.Join(t.TempDir(), ".spacectl")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "modes.yaml"), []byte("modes:\n  - name: acme\n    mount_paths: [~/.acme]\n"), 0o644))
+		config := "modes: [acme]\n"
+		if modesFile != "" {
+			config += "modes_file: " + modesFile + "\n"
+		}
+		path := filepath.Join(dir, "cache.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+		return path
+	}
+
+	t.Run("modes file relative to the config", func(t *testing.T) {
+		t.Setenv("SPACECTL_MODES_FILE", "")
+		require.NoError(t, validate(t, writeProject(t, "modes.yaml")))
+	})
+
+	t.Run("modes file from the environment", func(t *testing.T) {
+		path := writeProject(t, "")
+		t.Setenv("SPACECTL_MODES_FILE", "")
+		require.ErrorContains(t, validate(t, path), "is invalid")
+
+		t.Setenv("SPACECTL_MODES_FILE", filepath.Join(filepath.Dir(path), "modes.yaml"))
+		require.NoError(t, validate(t, path))
+	})
+
+	t.Run("config takes precedence over the environment", func(t *testing.T) {
+		t.Setenv("SPACECTL_MODES_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+		require.NoError(t, validate(t, writeProject(t, "modes.yaml")))
+	})
+}
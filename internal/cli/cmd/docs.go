@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cpuguy83/go-md2man/v2/md2man"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+
+	"github.com/namespacelabs/spacectl/internal/cache/mode"
+)
+
+func NewDocsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate reference documentation of the commands and cache modes",
+	}
+
+	cmd.AddCommand(newDocsManCmd())
+	cmd.AddCommand(newDocsMarkdownCmd())
+
+	return cmd
+}
+
+func newDocsManCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "man <dir>",
+		Short: "Write a man page for every command, and spacectl-modes(7) for the cache modes",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating %s: %w", dir, err)
+		}
+
+		root := cmd.Root()
+		// Generated files must not change with the date they are generated.
+		root.DisableAutoGenTag = true
+		header := &doc.GenManHeader{Section: "1", Source: "spacectl " + root.Version, Manual: "spacectl manual"}
+		if err := doc.GenManTree(root, header, dir); err != nil {
+			return fmt.Errorf("generating man pages: %w", err)
+		}
+
+		var md bytes.Buffer
+		fmt.Fprintf(&md, "%% SPACECTL-MODES 7 \"\" %q %q\n\n# NAME\n\nspacectl-modes - cache modes of spacectl\n\n# DESCRIPTION\n\n", header.Source, header.Manual)
+		writeModeCatalog(&md, mode.DefaultModes().Catalog(cmd.Context()), 1)
+		path := filepath.Join(dir, "spacectl-modes.7")
+		if err := os.WriteFile(path, md2man.Render(md.Bytes()), 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+
+		slog.Info(fmt.Sprintf("Wrote man pages to %s", dir))
+		return nil
+	}
+
+	return cmd
+}
+
+func newDocsMarkdownCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "markdown <dir>",
+		Short: "Write a Markdown reference page for every command, and modes.md for the cache modes",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating %s: %w", dir, err)
+		}
+
+		root := cmd.Root()
+		root.DisableAutoGenTag = true
+		if err := doc.GenMarkdownTree(root, dir); err != nil {
+			return fmt.Errorf("generating Markdown reference: %w", err)
+		}
+
+		var md bytes.Buffer
+		md.WriteString("# Cache modes\n\n")
+		writeModeCatalog(&md, mode.DefaultModes().Catalog(cmd.Context()), 2)
+		path := filepath.Join(dir, "modes.md")
+		if err := os.WriteFile(path, md.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+
+		slog.Info(fmt.Sprintf("Wrote Markdown reference to %s", dir))
+		return nil
+	}
+
+	return cmd
+}
+
+// writeModeCatalog writes the catalog of modes as Markdown, with a heading
+// of the given level for every mode.
+func writeModeCatalog(w io.Writer, catalog []mode.CatalogEntry, level int) {
+	fmt.Fprintf(w, "What detects each cache mode with `--detect`, and the paths it mounts by default, as planned with `--static` in an empty environment. `~` is the home directory, and cache directories and paths in the environment are relative to the cache root. Tools configured otherwise, e.g. with environment variables, are mounted where they are configured.\n\n")
+
+	code := func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = "`" + v + "`"
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	heading := strings.Repeat("#", level)
+	for _, e := range catalog {
+		fmt.Fprintf(w, "%s %s\n\n", heading, e.Name)
+		if len(e.Binaries) > 0 {
+			fmt.Fprintf(w, "- Binaries looked up: %s\n", code(e.Binaries))
+		}
+		if len(e.Files) > 0 {
+			fmt.Fprintf(w, "- Project files checked: %s\n", code(e.Files))
+		}
+		if len(e.Paths) > 0 {
+			fmt.Fprintf(w, "- Paths: %s\n", code(e.Paths))
+		}
+		if len(e.CacheDirs) > 0 {
+			fmt.Fprintf(w, "- Cache directories: %s\n", code(e.CacheDirs))
+		}
+		if len(e.Env) > 0 {
+			var env []string
+			for _, k := range slices.Sorted(maps.Keys(e.Env)) {
+				env = append(env, k+"="+e.Env[k])
+			}
+			fmt.Fprintf(w, "- Environment: %s\n", code(env))
+		}
+		if e.PlanError != "" {
+			fmt.Fprintf(w, "- Paths: asks its tools, as they cannot be assumed\n")
+		}
+		switch {
+		case e.Trims && e.Warms:
+			fmt.Fprintf(w, "- Supports `cache save --trim` and `cache warm`\n")
+		case e.Trims:
+			fmt.Fprintf(w, "- Supports `cache save --trim`\n")
+		case e.Warms:
+			fmt.Fprintf(w, "- Supports `cache warm`\n")
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cli/cmd"
+)
+
+func TestDocs(t *testing.T) {
+	run := func(t *testing.T, args ...string) {
+		t.Helper()
+		root := &cobra.Command{Use: "spacectl", Version: "1.2.3"}
+		root.AddCommand(cmd.NewCacheCmd(), cmd.NewDocsCmd())
+		root.SetOut(io.Discard)
+		root.SetArgs(args)
+		require.NoError(t, root.Execute())
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		dir := t.TempDir()
+		run(t, "docs", "markdown", dir)
+
+		ref, err := os.ReadFile(filepath.Join(dir, "spacectl_cache_mount.md"))
+		require.NoError(t, err)
+		require.Contains(t, string(ref), "--detect strings")
+		// Backquotes in a usage would name the value of the flag instead.
+		require.Contains(t, string(ref), "--exclude strings")
+
+		modes, err := os.ReadFile(filepath.Join(dir, "modes.md"))
+		require.NoError(t, err)
+		require.Contains(t, string(modes), "## go\n\n- Binaries looked up: `go`\n- Project files checked: `go.mod`, `go.work`\n")
+	})
+
+	t.Run("man", func(t *testing.T) {
+		dir := t.TempDir()
+		run(t, "docs", "man", dir)
+
+		page, err := os.ReadFile(filepath.Join(dir, "spacectl-cache-mount.1"))
+		require.NoError(t, err)
+		require.Contains(t, string(page), `"spacectl 1.2.3"`)
+
+		modes, err := os.ReadFile(filepath.Join(dir, "spacectl-modes.7"))
+		require.NoError(t, err)
+		require.Contains(t, string(modes), ".TH SPACECTL-MODES 7")
+		require.Contains(t, string(modes), ".SH go\n")
+	})
+}
@@ -0,0 +1,22 @@
+package cmd
+
+// exitColdCache is the exit status of mounts whose caches were colder than
+// --fail_on_miss or --min_hit_rate allow, so that pipelines can tell them
+// from failures, e.g. to trigger a warm job.
+const exitColdCache = 3
+
+// exitUnsupportedVersion is the exit status of `version check` when spacectl
+// is older than the minimum version that the Namespace API supports.
+const exitUnsupportedVersion = 4
+
+// exitError is an error that exits with a status other than 1.
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e exitError) Error() string { return e.err.Error() }
+func (e exitError) Unwrap() error { return e.err }
+
+// ExitCode is the status that the CLI exits with.
+func (e exitError) ExitCode() int { return e.code }
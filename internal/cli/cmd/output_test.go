@@ -0,0 +1,27 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/namespacelabs/spacectl/internal/cli/cmd"
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+)
+
+// Commands must use the global --output flag: one of their own would shadow
+// it, and the logger set up from the global flag would disagree with them.
+func TestCommandsDoNotShadowOutputFlag(t *testing.T) {
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		if c.LocalFlags().Lookup(output.FlagName) != nil || c.LocalFlags().ShorthandLookup("o") != nil {
+			t.Errorf("%s defines its own --output or -o flag", c.CommandPath())
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+
+	walk(cmd.NewCacheCmd())
+	walk(cmd.NewVersionCmd("dev", "none", "unknown"))
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+// progressObserver shows the paths being mounted or the entries being
+// pruned live on terminals, and logs a line as each finishes, which is all
+// that is shown in CI.
+type progressObserver struct {
+	cache.NopObserver
+	progress *log.Progress
+}
+
+func newProgressObserver(title string) *progressObserver {
+	return &progressObserver{progress: log.NewProgress(title)}
+}
+
+func (o *progressObserver) OnMountStart(modeName, path string) {
+	label := path
+	if modeName != "" {
+		label = fmt.Sprintf("%s (%s)", path, modeName)
+	}
+	o.progress.Start(modeName+"\x00"+path, label)
+}
+
+func (o *progressObserver) OnMountResult(modeName, path string, result cache.MountResult, err error) {
+	o.progress.Finish(modeName + "\x00" + path)
+	if err != nil {
+		// Failures are reported with the result.
+		return
+	}
+
+	hit := "miss"
+	if result.CacheHit {
+		hit = "hit"
+	}
+	duration := time.Duration(result.DurationMs) * time.Millisecond
+	slog.Info(fmt.Sprintf("Mounted %s (%s, %s)", mountLabel(modeName, cmp.Or(result.MountPath, path)), log.Hit(result.CacheHit, hit), duration))
+}
+
+func (o *progressObserver) OnComplete(cache.MountResponse, error) {
+	o.progress.Stop()
+}
+
+func (o *progressObserver) OnPruneStart(entry cache.PruneEntry) {
+	o.progress.Start(entry.CachePath, fmt.Sprintf("%s (%s)", entry.CachePath, cache.FormatSize(entry.Size.Bytes)))
+}
+
+func (o *progressObserver) OnPruneResult(entry cache.PruneEntry, err error) {
+	o.progress.Finish(entry.CachePath)
+	if err == nil {
+		slog.Debug("deleted cache entry", slog.String("path", entry.CachePath))
+	}
+}
+
+func mountLabel(modeName, path string) string {
+	if modeName == "" {
+		return path
+	}
+	return fmt.Sprintf("%s (%s)", path, log.Mode(modeName))
+}
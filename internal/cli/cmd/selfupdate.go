@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+	"github.com/namespacelabs/spacectl/internal/selfupdate"
+)
+
+// noUpdateNoticeEnv opts out of the notice that a newer version is available.
+const noUpdateNoticeEnv = "SPACECTL_NO_UPDATE_NOTICE"
+
+type selfUpdateResult struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	Updated bool   `json:"updated"`
+	// Path is the replaced binary, when it is updated.
+	Path string `json:"path,omitzero"`
+}
+
+func NewSelfUpdateCmd(version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace spacectl with its latest release, after verifying its checksum",
+		Args:  cobra.NoArgs,
+	}
+
+	check := cmd.Flags().Bool("check", false, "Only report whether a newer version is available.")
+	force := cmd.Flags().Bool("force", false, "Install the latest release even if it is not newer, e.g. over a development build.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		client := selfupdate.Client{UserAgent: "spacectl/" + version}
+		release, err := client.Latest(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		res := selfUpdateResult{Current: version, Latest: release.Version}
+		if !*check && (*force || selfupdate.Newer(release.Version, version)) {
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("finding the spacectl binary: %w", err)
+			}
+			// Replace the binary, not a symlink to it, e.g. from a package
+			// manager.
+			if exe, err = filepath.EvalSymlinks(exe); err != nil {
+				return fmt.Errorf("finding the spacectl binary: %w", err)
+			}
+
+			binary, err := client.Download(cmd.Context(), release, runtime.GOOS, runtime.GOARCH)
+			if err != nil {
+				return err
+			}
+			if err := selfupdate.Replace(exe, binary); err != nil {
+				return err
+			}
+			res.Updated, res.Path = true, exe
+		}
+
+		return writeOutput(cmd, output.Result{
+			Data: res,
+			Table: func() output.Table {
+				return output.Table{
+					Header: []string{"CURRENT", "LATEST", "UPDATED", "PATH"},
+					Rows:   [][]string{{res.Current, res.Latest, fmt.Sprint(res.Updated), res.Path}},
+				}
+			},
+			Plain: func() { outputSelfUpdateText(res, release.URL) },
+		})
+	}
+
+	return cmd
+}
+
+func outputSelfUpdateText(res selfUpdateResult, url string) {
+	switch {
+	case res.Updated:
+		slog.Info(fmt.Sprintf("Updated %s from %s to %s", res.Path, res.Current, res.Latest))
+	case selfupdate.Newer(res.Latest, res.Current):
+		slog.Info(fmt.Sprintf("spacectl %s is available (current %s): %s", res.Latest, res.Current, url))
+	default:
+		slog.Info(fmt.Sprintf("spacectl %s is the latest version", res.Current))
+	}
+}
+
+// StartUpdateNotice looks up the latest release in the background, at most
+// once a day, for the command c. The returned function logs a notice when a
+// newer version than version is available; it never waits for the lookup.
+// Development builds, commands that generate files, and $SPACECTL_NO_UPDATE_NOTICE
+// turn the notice off.
+func StartUpdateNotice(c *cobra.Command, version string) func() {
+	if v := os.Getenv(noUpdateNoticeEnv); v != "" && v != "0" {
+		return func() {}
+	}
+	if !selfupdate.Released(version) {
+		return func() {}
+	}
+	for p := c; p != nil; p = p.Parent() {
+		if slices.Contains([]string{"self-update", "completion", "docs", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd}, p.Name()) {
+			return func() {}
+		}
+	}
+
+	latest := selfupdate.Notice{Client: selfupdate.Client{UserAgent: "spacectl/" + version}}.Start(c.Context(), version)
+	return func() {
+		if v, newer := latest(); newer {
+			slog.Info(fmt.Sprintf("A new version of spacectl is available: %s (current %s). Run `spacectl self-update` to update.", v, version))
+		}
+	}
+}
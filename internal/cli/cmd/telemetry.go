@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+	"github.com/namespacelabs/spacectl/internal/cache/telemetry"
+)
+
+const (
+	telemetryEnv         = "SPACECTL_TELEMETRY"
+	telemetryEndpointEnv = "SPACECTL_TELEMETRY_ENDPOINT"
+	telemetryTokenEnv    = "SPACECTL_TELEMETRY_TOKEN"
+	telemetryPoolEnv     = "SPACECTL_TELEMETRY_POOL"
+	// doNotTrackEnv is the de facto standard for opting out of telemetry.
+	doNotTrackEnv = "DO_NOT_TRACK"
+)
+
+// telemetryFlags opt a mount in to uploading anonymized metrics, or writing
+// them to a Prometheus textfile.
+type telemetryFlags struct {
+	telemetry   *string
+	endpoint    *string
+	pool        *string
+	metricsFile *string
+
+	enabled bool
+	start   time.Time
+}
+
+func addTelemetryFlags(flags *pflag.FlagSet) *telemetryFlags {
+	return &telemetryFlags{
+		telemetry:   flags.String("telemetry", os.Getenv(telemetryEnv), "Upload anonymized mount metrics to --telemetry_endpoint: on or off. Defaults to off."),
+		endpoint:    flags.String("telemetry_endpoint", os.Getenv(telemetryEndpointEnv), "Namespace API endpoint that mount metrics are uploaded to."),
+		pool:        flags.String("telemetry_pool", os.Getenv(telemetryPoolEnv), "Runner pool that uploaded mount metrics are grouped by."),
+		metricsFile: flags.String("metrics_file", "", "Write mount metrics to this file in the Prometheus text format, e.g. for the textfile collector of node_exporter."),
+	}
+}
+
+// configure resolves whether metrics are uploaded. The flag and
+// $SPACECTL_TELEMETRY take precedence over $DO_NOT_TRACK, which takes
+// precedence over the project config.
+func (f *telemetryFlags) configure(cmd *cobra.Command, cfg cache.ProjectConfig) error {
+	f.start = time.Now()
+
+	flags := cmd.Flags()
+	setting := *f.telemetry
+	if !flags.Changed("telemetry") && setting == "" {
+		if v := os.Getenv(doNotTrackEnv); v != "" && v != "0" {
+			setting = "off"
+		} else {
+			setting = cfg.Telemetry
+		}
+	}
+	switch setting {
+	case "", "off":
+		f.enabled = false
+	case "on":
+		f.enabled = true
+	default:
+		return fmt.Errorf("--telemetry: %q is not on or off", setting)
+	}
+
+	if !flags.Changed("telemetry_endpoint") && *f.endpoint == "" {
+		*f.endpoint = cfg.TelemetryEndpoint
+	}
+	if !flags.Changed("telemetry_pool") && *f.pool == "" {
+		*f.pool = cfg.TelemetryPool
+	}
+	if !flags.Changed("metrics_file") && cfg.MetricsFile != "" {
+		*f.metricsFile = cfg.MetricsFile
+	}
+	if f.enabled && *f.endpoint == "" {
+		return fmt.Errorf("--telemetry=on requires --telemetry_endpoint")
+	}
+	return nil
+}
+
+// export writes the metrics of a mount to the metrics file and uploads
+// them. Metrics are best effort: failing to export them is logged and does
+// not fail the mount.
+func (f *telemetryFlags) export(ctx context.Context, cmd *cobra.Command, mounter cache.Mounter, result cache.MountResponse) {
+	if (!f.enabled && *f.metricsFile == "") || !mounter.DestructiveMode {
+		return
+	}
+
+	// The size of each cache is known from its last save.
+	sizes := map[string]int64{}
+	if metadata, err := cache.ReadMetadata(mounter.Exec, mounter.CacheRoot); err == nil {
+		for _, entry := range metadata.UserRequest {
+			sizes[entry.Source] = entry.Stats.Bytes
+		}
+	}
+
+	report := telemetry.NewReport(result, sizes, time.Since(f.start))
+	report.Version = cmp.Or(cmd.Root().Version, "unknown")
+	report.Pool = *f.pool
+
+	if *f.metricsFile != "" {
+		if err := telemetry.WriteTextfile(*f.metricsFile, report, time.Now()); err != nil {
+			slog.Warn("failed to write metrics file", slog.Any("error", err))
+		}
+	}
+	if !f.enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.DefaultTimeout)
+	defer cancel()
+
+	client := telemetry.Client{Endpoint: *f.endpoint, Token: os.Getenv(telemetryTokenEnv)}
+	if err := client.Send(ctx, report); err != nil {
+		slog.Warn("failed to upload telemetry", slog.Any("error", err))
+		return
+	}
+	slog.Debug("uploaded telemetry", slog.String("endpoint", *f.endpoint))
+}
@@ -1,13 +1,16 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+	"github.com/namespacelabs/spacectl/internal/selfupdate"
+	"github.com/namespacelabs/spacectl/internal/versioncheck"
 )
 
 func NewVersionCmd(version, commit, date string) *cobra.Command {
@@ -17,26 +20,102 @@ func NewVersionCmd(version, commit, date string) *cobra.Command {
 	}
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		var w io.Writer = os.Stdout
-		if output, _ := cmd.Flags().GetString("output"); output == "json" {
-			return outputVersionJSON(w, version, commit, date)
+		return writeOutput(cmd, output.Result{
+			Data: map[string]string{
+				"version": version,
+				"commit":  commit,
+				"date":    date,
+			},
+			Table: func() output.Table {
+				return output.Table{
+					Header: []string{"VERSION", "COMMIT", "DATE"},
+					Rows:   [][]string{{version, commit, date}},
+				}
+			},
+			Plain: func() { outputVersionText(os.Stdout, version, commit, date) },
+		})
+	}
+
+	cmd.AddCommand(newVersionCheckCmd(version))
+
+	return cmd
+}
+
+const (
+	versionCheckEndpointEnv = "SPACECTL_VERSION_CHECK_ENDPOINT"
+	versionCheckTokenEnv    = "SPACECTL_VERSION_CHECK_TOKEN"
+)
+
+type versionCheckResult struct {
+	Version        string `json:"version"`
+	MinimumVersion string `json:"minimum_version"`
+	Supported      bool   `json:"supported"`
+	Message        string `json:"message,omitzero"`
+}
+
+func newVersionCheckCmd(version string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Fail if this version is older than the minimum version supported by the Namespace API",
+		Args:  cobra.NoArgs,
+	}
+
+	endpoint := cmd.Flags().String("endpoint", os.Getenv(versionCheckEndpointEnv), "Namespace API endpoint that returns the minimum supported version.")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *endpoint == "" {
+			return fmt.Errorf("--endpoint or $%s is required", versionCheckEndpointEnv)
+		}
+
+		client := versioncheck.Client{Endpoint: *endpoint, Token: os.Getenv(versionCheckTokenEnv)}
+		policy, err := client.Policy(cmd.Context(), version)
+		if err != nil {
+			return err
+		}
+		if !selfupdate.Released(version) {
+			slog.Warn(fmt.Sprintf("spacectl %s is a development build, which is not checked against the minimum version", version))
 		}
 
-		outputVersionText(w, version, commit, date)
+		res := versionCheckResult{
+			Version:        version,
+			MinimumVersion: policy.MinimumVersion,
+			Supported:      policy.Supported(version),
+			Message:        policy.Message,
+		}
+		if err := writeOutput(cmd, output.Result{
+			Data: res,
+			Table: func() output.Table {
+				return output.Table{
+					Header: []string{"VERSION", "MINIMUM", "SUPPORTED"},
+					Rows:   [][]string{{res.Version, res.MinimumVersion, fmt.Sprint(res.Supported)}},
+				}
+			},
+			Plain: func() { outputVersionCheckText(res) },
+		}); err != nil {
+			return err
+		}
+
+		if !res.Supported {
+			// The flags were right, so usage would only bury the error.
+			cmd.SilenceUsage = true
+			err := fmt.Errorf("spacectl %s is older than the minimum supported version %s; run `spacectl self-update` to update", version, policy.MinimumVersion)
+			return exitError{err: err, code: exitUnsupportedVersion}
+		}
 		return nil
 	}
 
 	return cmd
 }
 
-func outputVersionJSON(w io.Writer, version, commit, date string) error {
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(map[string]string{
-		"version": version,
-		"commit":  commit,
-		"date":    date,
-	})
+func outputVersionCheckText(res versionCheckResult) {
+	if res.Message != "" {
+		slog.Info(res.Message)
+	}
+	if res.Supported && res.MinimumVersion != "" {
+		slog.Info(fmt.Sprintf("spacectl %s is supported (minimum %s)", res.Version, res.MinimumVersion))
+	} else if res.Supported {
+		slog.Info(fmt.Sprintf("spacectl %s is supported", res.Version))
+	}
 }
 
 func outputVersionText(_ io.Writer, version, commit, date string) {
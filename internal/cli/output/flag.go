@@ -0,0 +1,65 @@
+package output
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// FlagName is the name of the flag picking the output format.
+const FlagName = "output"
+
+// Flag is the value of --output. Formats are parsed as the flag is set, so
+// that an unknown format fails before the command runs rather than after.
+type Flag struct {
+	value string
+	spec  Spec
+}
+
+func (f *Flag) String() string {
+	if f.value == "" {
+		return string(FormatPlain)
+	}
+	return f.value
+}
+
+func (f *Flag) Set(value string) error {
+	spec, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	f.value, f.spec = value, spec
+	return nil
+}
+
+func (f *Flag) Type() string {
+	return "format"
+}
+
+// Spec returns the parsed format.
+func (f *Flag) Spec() Spec {
+	if f.spec.Format == "" {
+		return Spec{Format: FormatPlain}
+	}
+	return f.spec
+}
+
+// AddFlag registers --output, -o. The root command registers it as a
+// persistent flag, and commands must not define their own: both the logger
+// set up before a command runs and the command itself read it with
+// FromFlags, so they always agree on the format.
+func AddFlag(flags *pflag.FlagSet) *Flag {
+	f := &Flag{}
+	flags.VarP(f, FlagName, "o", "Output format: plain, json, yaml, ndjson, table or go-template=<template>.")
+	return f
+}
+
+// FromFlags returns the output format of a command from its flags, which
+// include the persistent flags of its parents once parsed, e.g. cmd.Flags().
+// Commands without --output print plain output.
+func FromFlags(flags *pflag.FlagSet) Spec {
+	if fl := flags.Lookup(FlagName); fl != nil {
+		if f, ok := fl.Value.(*Flag); ok {
+			return f.Spec()
+		}
+	}
+	return Spec{Format: FormatPlain}
+}
@@ -0,0 +1,54 @@
+package output_test
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+)
+
+func TestFlag(t *testing.T) {
+	t.Run("defaults to plain", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		output.AddFlag(flags)
+		require.NoError(t, flags.Parse(nil))
+		require.Equal(t, output.Spec{Format: output.FormatPlain}, output.FromFlags(flags))
+		require.Equal(t, "plain", flags.Lookup(output.FlagName).DefValue)
+	})
+
+	t.Run("without the flag", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		require.Equal(t, output.Spec{Format: output.FormatPlain}, output.FromFlags(flags))
+	})
+
+	t.Run("rejects unknown formats when parsed", func(t *testing.T) {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		output.AddFlag(flags)
+		require.ErrorContains(t, flags.Parse([]string{"-o", "xml"}), `unknown output format "xml"`)
+	})
+
+	t.Run("is shared with subcommands", func(t *testing.T) {
+		var hook, run output.Spec
+		root := &cobra.Command{
+			Use: "root",
+			PersistentPreRun: func(c *cobra.Command, _ []string) {
+				hook = output.FromFlags(c.Flags())
+			},
+		}
+		output.AddFlag(root.PersistentFlags())
+		root.AddCommand(&cobra.Command{
+			Use: "sub",
+			Run: func(c *cobra.Command, _ []string) { run = output.FromFlags(c.Flags()) },
+		})
+
+		root.SetArgs([]string{"sub", "--output", "go-template={{.a}}"})
+		require.NoError(t, root.Execute())
+
+		want := output.Spec{Format: output.FormatGoTemplate, Template: "{{.a}}"}
+		require.Equal(t, want, hook)
+		require.Equal(t, want, run)
+	})
+}
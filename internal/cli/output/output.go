@@ -0,0 +1,265 @@
+// Package output prints the results of commands in the format picked with
+// --output.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is how a command prints its result.
+type Format string
+
+const (
+	// FormatPlain logs the result for humans.
+	FormatPlain Format = "plain"
+	// FormatJSON prints the result as indented JSON.
+	FormatJSON Format = "json"
+	// FormatYAML prints the result as YAML, with the field names of JSON.
+	FormatYAML Format = "yaml"
+	// FormatNDJSON prints the items of the result as one JSON object per
+	// line, or the whole result on one line for commands without items.
+	FormatNDJSON Format = "ndjson"
+	// FormatTable prints the rows of the result in aligned columns.
+	FormatTable Format = "table"
+	// FormatGoTemplate renders a Go template given as go-template=<template>
+	// against the result.
+	FormatGoTemplate Format = "go-template"
+)
+
+// Spec is a parsed --output value.
+type Spec struct {
+	Format Format
+	// Template is the template source of FormatGoTemplate.
+	Template string
+}
+
+// Parse parses an --output value. An empty value is FormatPlain.
+func Parse(value string) (Spec, error) {
+	if tmpl, ok := strings.CutPrefix(value, string(FormatGoTemplate)+"="); ok {
+		if tmpl == "" {
+			return Spec{}, errors.New("go-template output needs a template, e.g. -o go-template='{{.version}}'")
+		}
+		return Spec{Format: FormatGoTemplate, Template: tmpl}, nil
+	}
+
+	switch f := Format(value); f {
+	case "":
+		return Spec{Format: FormatPlain}, nil
+	case FormatPlain, FormatTable:
+		return Spec{Format: f}, nil
+	case FormatGoTemplate:
+		return Spec{}, errors.New("go-template output needs a template, e.g. -o go-template='{{.version}}'")
+	default:
+		if _, ok := encoders[f]; ok {
+			return Spec{Format: f}, nil
+		}
+	}
+
+	names := []string{string(FormatPlain), string(FormatTable)}
+	for f := range encoders {
+		names = append(names, string(f))
+	}
+	slices.Sort(names)
+	return Spec{}, fmt.Errorf("unknown output format %q, expected one of %s or go-template=<template>", value, strings.Join(names, ", "))
+}
+
+// IsData reports whether the format prints data for programs rather than
+// logs, so that logs must go elsewhere.
+func (s Spec) IsData() bool {
+	return s.Format != FormatPlain
+}
+
+// Table is a result as rows of columns.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Result is what a command prints, for every format.
+type Result struct {
+	// Data is encoded by FormatJSON and rendered by templates, which see it
+	// as JSON does, e.g. {{.output.mounts}}.
+	Data any
+	// Table returns the rows of FormatTable. Commands without a table
+	// leave it nil.
+	Table func() Table
+	// Plain logs the result for FormatPlain.
+	Plain func()
+	// Items returns the records that FormatNDJSON prints one per line, like
+	// the mounts of `cache mount`. Commands without records leave it nil.
+	Items func() []any
+}
+
+// Items returns a Result.Items func listing records.
+func Items[T any](records []T) func() []any {
+	return func() []any {
+		items := make([]any, len(records))
+		for i, r := range records {
+			items[i] = r
+		}
+		return items
+	}
+}
+
+// Encoder prints the data of a result in a format.
+type Encoder func(w io.Writer, r Result) error
+
+// encoders holds the formats that encode the data of results, as opposed
+// to the formats that render it for humans.
+var encoders = map[Format]Encoder{
+	FormatJSON:   encodeJSON,
+	FormatYAML:   encodeYAML,
+	FormatNDJSON: encodeNDJSON,
+}
+
+// Register adds an encoder for a format, or replaces the one registered.
+// It must be called before flags are parsed, e.g. from an init func.
+func Register(f Format, e Encoder) {
+	encoders[f] = e
+}
+
+// Write prints r to w in the format of spec.
+func Write(w io.Writer, spec Spec, r Result) error {
+	switch spec.Format {
+	case FormatPlain, "":
+		r.Plain()
+		return nil
+	case FormatTable:
+		if r.Table == nil {
+			return errors.New("table output is not supported by this command")
+		}
+		return writeTable(w, r.Table())
+	case FormatGoTemplate:
+		return writeTemplate(w, spec.Template, r.Data)
+	}
+
+	encode, ok := encoders[spec.Format]
+	if !ok {
+		return fmt.Errorf("unknown output format %q", spec.Format)
+	}
+	return encode(w, r)
+}
+
+func encodeJSON(w io.Writer, r Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Data)
+}
+
+func encodeYAML(w io.Writer, r Result) error {
+	data, err := generic(r.Data)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func encodeNDJSON(w io.Writer, r Result) error {
+	enc := json.NewEncoder(w)
+	if r.Items == nil {
+		return enc.Encode(r.Data)
+	}
+	for _, item := range r.Items() {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, t Table) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Header, "\t"))
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// templateFuncs are available to templates besides the builtins.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": func(sep string, elems []any) string {
+		parts := make([]string, len(elems))
+		for i, e := range elems {
+			parts[i] = fmt.Sprint(e)
+		}
+		return strings.Join(parts, sep)
+	},
+}
+
+func writeTemplate(w io.Writer, text string, data any) error {
+	tmpl, err := template.New("output").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("parsing output template: %w", err)
+	}
+
+	generic, err := generic(data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, generic); err != nil {
+		return fmt.Errorf("executing output template: %w", err)
+	}
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// generic round-trips data through JSON, so that templates and YAML use the
+// same field names as JSON output. Numbers become int64 where they fit.
+func generic(data any) (any, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encoding output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("encoding output: %w", err)
+	}
+	return numbers(v), nil
+}
+
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package output_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/cli/output"
+)
+
+type mount struct {
+	MountPath string `json:"mount_path"`
+	CacheHit  bool   `json:"cache_hit"`
+}
+
+var result = output.Result{
+	Data: map[string]any{"mounts": []mount{{"/a", true}, {"/b", false}}, "size": 1 << 40},
+	Table: func() output.Table {
+		return output.Table{
+			Header: []string{"MOUNT PATH", "HIT"},
+			Rows:   [][]string{{"/a", "true"}, {"/long/path", "false"}},
+		}
+	},
+	Plain: func() {},
+}
+
+func TestParse(t *testing.T) {
+	for value, want := range map[string]output.Spec{
+		"":                          {Format: output.FormatPlain},
+		"plain":                     {Format: output.FormatPlain},
+		"json":                      {Format: output.FormatJSON},
+		"yaml":                      {Format: output.FormatYAML},
+		"ndjson":                    {Format: output.FormatNDJSON},
+		"table":                     {Format: output.FormatTable},
+		"go-template={{.version}}":  {Format: output.FormatGoTemplate, Template: "{{.version}}"},
+		"go-template={{.a}}={{.b}}": {Format: output.FormatGoTemplate, Template: "{{.a}}={{.b}}"},
+	} {
+		spec, err := output.Parse(value)
+		require.NoError(t, err, value)
+		require.Equal(t, want, spec, value)
+	}
+
+	_, err := output.Parse("xml")
+	require.ErrorContains(t, err, `unknown output format "xml"`)
+	require.ErrorContains(t, err, "ndjson")
+
+	_, err = output.Parse("go-template")
+	require.ErrorContains(t, err, "needs a template")
+}
+
+func TestWrite(t *testing.T) {
+	write := func(t *testing.T, value string, r output.Result) (string, error) {
+		spec, err := output.Parse(value)
+		require.NoError(t, err)
+		var b strings.Builder
+		err = output.Write(&b, spec, r)
+		return b.String(), err
+	}
+
+	t.Run("json", func(t *testing.T) {
+		got, err := write(t, "json", result)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"mounts":[{"mount_path":"/a","cache_hit":true},{"mount_path":"/b","cache_hit":false}],"size":1099511627776}`, got)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		got, err := write(t, "yaml", result)
+		require.NoError(t, err)
+		require.Equal(t, "mounts:\n  - cache_hit: true\n    mount_path: /a\n  - cache_hit: false\n    mount_path: /b\nsize: 1099511627776\n", got)
+	})
+
+	t.Run("ndjson items", func(t *testing.T) {
+		r := result
+		r.Items = func() []any { return []any{mount{"/a", true}, mount{"/b", false}} }
+		got, err := write(t, "ndjson", r)
+		require.NoError(t, err)
+		require.Equal(t, `{"mount_path":"/a","cache_hit":true}`+"\n"+`{"mount_path":"/b","cache_hit":false}`+"\n", got)
+	})
+
+	t.Run("ndjson without items", func(t *testing.T) {
+		got, err := write(t, "ndjson", result)
+		require.NoError(t, err)
+		require.Equal(t, `{"mounts":[{"mount_path":"/a","cache_hit":true},{"mount_path":"/b","cache_hit":false}],"size":1099511627776}`+"\n", got)
+	})
+
+	t.Run("registered encoder", func(t *testing.T) {
+		output.Register("count", func(w io.Writer, r output.Result) error {
+			_, err := fmt.Fprintln(w, len(r.Items()))
+			return err
+		})
+		r := result
+		r.Items = func() []any { return []any{1, 2, 3} }
+		got, err := write(t, "count", r)
+		require.NoError(t, err)
+		require.Equal(t, "3\n", got)
+	})
+
+	t.Run("table", func(t *testing.T) {
+		got, err := write(t, "table", result)
+		require.NoError(t, err)
+		require.Equal(t, "MOUNT PATH  HIT\n/a          true\n/long/path  false\n", got)
+	})
+
+	t.Run("table unsupported", func(t *testing.T) {
+		_, err := write(t, "table", output.Result{Data: 1, Plain: func() {}})
+		require.ErrorContains(t, err, "table output is not supported")
+	})
+
+	t.Run("template uses JSON field names", func(t *testing.T) {
+		got, err := write(t, `go-template={{range .mounts}}{{.mount_path}}={{.cache_hit}} {{end}}{{.size}}`, result)
+		require.NoError(t, err)
+		require.Equal(t, "/a=true /b=false 1099511627776\n", got)
+	})
+
+	t.Run("template functions", func(t *testing.T) {
+		got, err := write(t, `go-template={{json (index .mounts 0)}}`, result)
+		require.NoError(t, err)
+		require.Equal(t, `{"cache_hit":true,"mount_path":"/a"}`+"\n", got)
+	})
+
+	t.Run("template missing key", func(t *testing.T) {
+		_, err := write(t, "go-template={{.nope}}", result)
+		require.ErrorContains(t, err, "executing output template")
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		var called bool
+		got, err := write(t, "plain", output.Result{Plain: func() { called = true }})
+		require.NoError(t, err)
+		require.Empty(t, got)
+		require.True(t, called)
+	})
+}
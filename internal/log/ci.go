@@ -0,0 +1,273 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CIHandlerOptions are options for the handlers of CI systems without
+// dedicated ones: Buildkite, CircleCI and Azure Pipelines.
+type CIHandlerOptions struct {
+	// Level is the minimum level to log. If nil, defaults to slog.LevelInfo.
+	Level slog.Leveler
+}
+
+// ciStyle renders the parts of a log that differ between CI systems.
+type ciStyle interface {
+	// groupStart and groupEnd return the lines around a section started
+	// with StartGroup, or "" to print nothing.
+	groupStart(title string) string
+	groupEnd() string
+	// line formats a message with its attrs already appended. params holds
+	// the annotation attrs (FileKey, LineKey, ...) when the style consumes
+	// them.
+	line(level slog.Level, msg string, params []slog.Attr) string
+	// annotates reports whether the annotation attrs of level are passed to
+	// line instead of being printed.
+	annotates(level slog.Level) bool
+}
+
+// ciHandler is a slog.Handler that outputs plain text like PlainHandler,
+// with levels and sections in the syntax of a CI system.
+type ciHandler struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	level  slog.Leveler
+	groups []string
+	attrs  []slog.Attr
+	style  ciStyle
+}
+
+func newCIHandler(w io.Writer, opts *CIHandlerOptions, style ciStyle) *ciHandler {
+	h := &ciHandler{
+		out:   w,
+		mu:    &sync.Mutex{},
+		style: style,
+	}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+// NewBuildkiteHandler creates a handler for Buildkite job logs, which folds
+// sections started with StartGroup into collapsed groups and expands the
+// current group when an error is logged.
+func NewBuildkiteHandler(w io.Writer, opts *CIHandlerOptions) slog.Handler {
+	return newCIHandler(w, opts, buildkiteStyle{})
+}
+
+// NewCircleCIHandler creates a handler for CircleCI job logs, which colors
+// levels other than info. CircleCI cannot fold sections, so their titles
+// are printed in bold.
+func NewCircleCIHandler(w io.Writer, opts *CIHandlerOptions) slog.Handler {
+	return newCIHandler(w, opts, circleCIStyle{})
+}
+
+// NewAzurePipelinesHandler creates a handler for Azure Pipelines job logs,
+// which folds sections into groups and reports warnings and errors with
+// ##vso[task.logissue] commands, attached to the file of FileKey if set.
+func NewAzurePipelinesHandler(w io.Writer, opts *CIHandlerOptions) slog.Handler {
+	return newCIHandler(w, opts, azureStyle{})
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *ciHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.level != nil {
+		minLevel = h.level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle formats the record in the style of the CI system and writes it.
+func (h *ciHandler) Handle(_ context.Context, r slog.Record) error {
+	var out string
+	switch groupMarker(r) {
+	case groupStart:
+		out = h.style.groupStart(r.Message)
+	case groupEnd:
+		out = h.style.groupEnd()
+	default:
+		var params []slog.Attr
+		annotates := h.style.annotates(r.Level) && len(h.groups) == 0
+		isParam := func(a slog.Attr) bool {
+			if annotates && slices.Contains(annotationKeys, a.Key) {
+				params = append(params, a)
+				return true
+			}
+			return false
+		}
+
+		buf := make([]byte, 0, 256)
+		buf = append(buf, r.Message...)
+		for _, a := range h.attrs {
+			if !isParam(a) {
+				buf = h.appendAttr(buf, a)
+			}
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			if !isParam(a) {
+				buf = h.appendAttr(buf, a)
+			}
+			return true
+		})
+		out = h.style.line(r.Level, string(buf), params)
+	}
+	if out == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, out+"\n")
+	return err
+}
+
+// WithAttrs returns a new handler with the given attributes added.
+func (h *ciHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(newAttrs, h.attrs)
+	newAttrs = append(newAttrs, attrs...)
+	return &ciHandler{
+		out:    h.out,
+		mu:     h.mu,
+		level:  h.level,
+		groups: h.groups,
+		attrs:  newAttrs,
+		style:  h.style,
+	}
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *ciHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	newGroups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(newGroups, h.groups)
+	newGroups = append(newGroups, name)
+	return &ciHandler{
+		out:    h.out,
+		mu:     h.mu,
+		level:  h.level,
+		groups: newGroups,
+		attrs:  h.attrs,
+		style:  h.style,
+	}
+}
+
+// appendAttr appends a single attribute to the buffer in key=value format.
+func (h *ciHandler) appendAttr(buf []byte, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	buf = append(buf, ' ')
+
+	// Prepend group names if any
+	for _, g := range h.groups {
+		buf = append(buf, g...)
+		buf = append(buf, '.')
+	}
+
+	buf = append(buf, a.Key...)
+	buf = append(buf, '=')
+	buf = appendValue(buf, a.Value)
+	return buf
+}
+
+// coloredLine prefixes msg with the level in color, except for info.
+func coloredLine(level slog.Level, msg string) string {
+	color := levelColor(level)
+	if color == "" {
+		return msg
+	}
+	return color + "[" + level.String() + "]" + ansiReset + " " + msg
+}
+
+// buildkiteStyle uses "--- title" to start a collapsed group. Groups last
+// until the next one starts, so ends print nothing.
+type buildkiteStyle struct{}
+
+func (buildkiteStyle) groupStart(title string) string { return "--- " + title }
+func (buildkiteStyle) groupEnd() string               { return "" }
+func (buildkiteStyle) annotates(slog.Level) bool      { return false }
+
+func (buildkiteStyle) line(level slog.Level, msg string, _ []slog.Attr) string {
+	line := coloredLine(level, msg)
+	if level >= slog.LevelError {
+		// Expand the group the error is in.
+		line = "^^^ +++\n" + line
+	}
+	return line
+}
+
+type circleCIStyle struct{}
+
+const ansiBold = "\x1b[1m"
+
+func (circleCIStyle) groupStart(title string) string { return ansiBold + title + ansiReset }
+func (circleCIStyle) groupEnd() string               { return "" }
+func (circleCIStyle) annotates(slog.Level) bool      { return false }
+
+func (circleCIStyle) line(level slog.Level, msg string, _ []slog.Attr) string {
+	return coloredLine(level, msg)
+}
+
+type azureStyle struct{}
+
+func (azureStyle) groupStart(title string) string { return "##[group]" + title }
+func (azureStyle) groupEnd() string               { return "##[endgroup]" }
+
+func (azureStyle) annotates(level slog.Level) bool { return level >= slog.LevelWarn }
+
+// azureIssueProperties maps annotation attrs to task.logissue properties.
+var azureIssueProperties = map[string]string{
+	FileKey: "sourcepath",
+	LineKey: "linenumber",
+	ColKey:  "columnnumber",
+}
+
+func (azureStyle) line(level slog.Level, msg string, params []slog.Attr) string {
+	var command string
+	switch {
+	case level < slog.LevelInfo:
+		return "##[debug]" + msg
+	case level < slog.LevelWarn:
+		return msg
+	case level < slog.LevelError:
+		command = "##vso[task.logissue type=warning"
+	default:
+		command = "##vso[task.logissue type=error"
+	}
+
+	var b strings.Builder
+	b.WriteString(command)
+	for _, a := range params {
+		if prop, ok := azureIssueProperties[a.Key]; ok {
+			b.WriteString(";" + prop + "=" + escapeAzureProperty(a.Value.Resolve().String()))
+		} else if a.Key == TitleKey {
+			// Issues have no title, so it leads the message.
+			msg = a.Value.Resolve().String() + ": " + msg
+		}
+	}
+	b.WriteString("]")
+	b.WriteString(escapeAzureData(msg))
+	return b.String()
+}
+
+// escapeAzureData escapes the message of a logging command.
+func escapeAzureData(s string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeAzureProperty escapes a property value of a logging command.
+func escapeAzureProperty(s string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D").Replace(s)
+}
@@ -0,0 +1,90 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+// logSection logs a section holding a line, then an error, with the default
+// logger set to the handler.
+func logSection(h slog.Handler) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(h))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Info("- go", slog.String("path", "/cache"))
+	end()
+	slog.Error("bad config", slog.String(log.FileKey, "cache.yaml"), slog.Int(log.LineKey, 3), slog.String(log.TitleKey, "Invalid config"))
+}
+
+func TestCIHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		newHandler func(io.Writer, *log.CIHandlerOptions) slog.Handler
+		want       string
+	}{
+		{
+			name:       "buildkite",
+			newHandler: log.NewBuildkiteHandler,
+			want: "--- Detected:\n" +
+				"- go path=/cache\n" +
+				"^^^ +++\n\x1b[31m[ERROR]\x1b[0m bad config file=cache.yaml line=3 title=Invalid config\n",
+		},
+		{
+			name:       "circleci",
+			newHandler: log.NewCircleCIHandler,
+			want: "\x1b[1mDetected:\x1b[0m\n" +
+				"- go path=/cache\n" +
+				"\x1b[31m[ERROR]\x1b[0m bad config file=cache.yaml line=3 title=Invalid config\n",
+		},
+		{
+			name:       "azure",
+			newHandler: log.NewAzurePipelinesHandler,
+			want: "##[group]Detected:\n" +
+				"- go path=/cache\n" +
+				"##[endgroup]\n" +
+				"##vso[task.logissue type=error;sourcepath=cache.yaml;linenumber=3]Invalid config: bad config\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logSection(tt.newHandler(&buf, nil))
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzurePipelinesHandler_Escapes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewAzurePipelinesHandler(&buf, &log.CIHandlerOptions{Level: slog.LevelDebug}))
+
+	logger.Debug("probing")
+	logger.Warn("2 paths failed:\n- a 100%", slog.String(log.FileKey, "a;b]"))
+
+	got := buf.String()
+	want := "##[debug]probing\n##vso[task.logissue type=warning;sourcepath=a%3Bb%5D]2 paths failed:%0A- a 100%AZP25\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCIHandlers_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewBuildkiteHandler(&buf, nil))
+
+	logger.Debug("hidden")
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+const (
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// color enables the colors added to messages by Mode and Hit.
+var color atomic.Bool
+
+// SetColor enables or disables the colors added to messages by Mode and Hit.
+// They are disabled by default, so that messages stay plain when piped.
+func SetColor(enabled bool) {
+	color.Store(enabled)
+}
+
+// ShouldColor reports whether output to w should be colored: w must be a
+// terminal, and neither NO_COLOR nor TERM=dumb set.
+func ShouldColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return IsTerminal(w)
+}
+
+// Mode highlights the name of a cache mode in messages.
+func Mode(name string) string {
+	return colored(ansiCyan, name)
+}
+
+// Hit colors text describing a cache hit green, and a miss yellow.
+func Hit(hit bool, text string) string {
+	if hit {
+		return colored(ansiGreen, text)
+	}
+	return colored(ansiYellow, text)
+}
+
+func colored(code, text string) string {
+	if !color.Load() || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
@@ -0,0 +1,55 @@
+package log_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+func TestColor(t *testing.T) {
+	if got := log.Mode("go"); got != "go" {
+		t.Errorf("colors are disabled by default, got %q", got)
+	}
+
+	log.SetColor(true)
+	defer log.SetColor(false)
+
+	tests := []struct {
+		got, want string
+	}{
+		{log.Mode("go"), "\x1b[36mgo\x1b[0m"},
+		{log.Hit(true, "hit"), "\x1b[32mhit\x1b[0m"},
+		{log.Hit(false, "miss"), "\x1b[33mmiss\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+
+	// Structured logs drop the colors.
+	var buf bytes.Buffer
+	slog.New(log.NewJSONHandler(&buf, nil)).Info("mounted " + log.Mode("go"))
+	if bytes.Contains(buf.Bytes(), []byte(`\u001b`)) {
+		t.Errorf("expected no colors, got %q", buf.String())
+	}
+}
+
+func TestShouldColor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if log.ShouldColor(f) {
+		t.Error("expected no colors for regular files")
+	}
+	if log.ShouldColor(&bytes.Buffer{}) {
+		t.Error("expected no colors for buffers")
+	}
+}
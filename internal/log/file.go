@@ -0,0 +1,129 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser that appends to a file, moving it aside
+// to <path>.1, <path>.2 and so on once it grows past MaxSize. Only Backups
+// old files are kept.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, rotating it once it exceeds
+// maxSize bytes and keeping up to backups rotated files.
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would not fit.
+// Writes larger than the limit are written whole to a fresh file.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts <path>.N to <path>.N+1, dropping the oldest, and moves the
+// current file to <path>.1.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+
+	if rf.backups > 0 {
+		for i := rf.backups - 1; i >= 1; i-- {
+			// Missing backups are fine, there may not be that many yet.
+			_ = os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+
+	return rf.open()
+}
+
+// Close closes the file.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
+
+// NewFileHandler creates a handler for log files, which records every level
+// with timestamps in logfmt, so that failures can be debugged after the
+// console output is gone.
+func NewFileHandler(w io.Writer) slog.Handler {
+	return plainRecords{slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})}
+}
+
+// plainRecords wraps a handler for machine-read logs, so that sections
+// started with StartGroup log their title only, and messages lose the colors
+// added by Mode and Hit.
+type plainRecords struct {
+	slog.Handler
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func (h plainRecords) Handle(ctx context.Context, r slog.Record) error {
+	switch groupMarker(r) {
+	case groupStart:
+		r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	case groupEnd:
+		return nil
+	}
+	r.Message = ansiEscape.ReplaceAllString(r.Message, "")
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h plainRecords) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return plainRecords{h.Handler.WithAttrs(attrs)}
+}
+
+func (h plainRecords) WithGroup(name string) slog.Handler {
+	return plainRecords{h.Handler.WithGroup(name)}
+}
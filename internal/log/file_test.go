@@ -0,0 +1,72 @@
+package log_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spacectl.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := log.OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	// The existing file counts towards the limit, so each write but the
+	// first rotates.
+	for _, line := range []string{"one\n", "two 2222\n", "three 333\n", "four\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"spacectl.log":   "four\n",
+		"spacectl.log.1": "three 333\n",
+		"spacectl.log.2": "two 2222\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, got %v", err)
+	}
+}
+
+func TestFileHandler(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(log.NewFileHandler(&buf)))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Debug("probing", slog.String("mode", "go"))
+	end()
+
+	got := buf.String()
+	for _, want := range []string{"level=INFO msg=Detected:\n", "level=DEBUG msg=probing mode=go\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Count(got, "\n") != 2 {
+		t.Errorf("expected 2 lines, got %q", got)
+	}
+}
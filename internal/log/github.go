@@ -4,12 +4,29 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 )
 
+// Attribute keys that GithubHandler turns into the parameters of warning and
+// error annotations, so that they are attached to a file in the Checks UI.
+// Other handlers and levels print them like any other attribute.
+const (
+	FileKey      = "file"
+	LineKey      = "line"
+	EndLineKey   = "endLine"
+	ColKey       = "col"
+	EndColumnKey = "endColumn"
+	TitleKey     = "title"
+)
+
+var annotationKeys = []string{FileKey, LineKey, EndLineKey, ColKey, EndColumnKey, TitleKey}
+
 // GithubHandler is a slog.Handler that outputs log messages using GitHub Actions
 // workflow command format. Debug, warning, and error levels use the ::command::
-// syntax, while info level outputs plain text.
+// syntax, while info level outputs plain text. Sections started with
+// StartGroup are folded into ::group:: blocks.
 type GithubHandler struct {
 	out    io.Writer
 	mu     *sync.Mutex
@@ -35,40 +52,99 @@ func (h *GithubHandler) Enabled(_ context.Context, _ slog.Level) bool {
 func (h *GithubHandler) Handle(_ context.Context, r slog.Record) error {
 	buf := make([]byte, 0, 256)
 
+	switch groupMarker(r) {
+	case groupStart:
+		buf = append(buf, "::group::"...)
+		buf = append(buf, escapeData(r.Message)...)
+		return h.write(append(buf, '\n'))
+	case groupEnd:
+		return h.write(append(buf, "::endgroup::\n"...))
+	}
+
 	// Format based on level
+	var command string
 	switch {
 	case r.Level < slog.LevelInfo:
-		buf = append(buf, "::debug::"...)
+		command = "debug"
 	case r.Level < slog.LevelWarn:
 		// Info level: plain text, no prefix
 	case r.Level < slog.LevelError:
-		buf = append(buf, "::warning::"...)
+		command = "warning"
 	default:
-		buf = append(buf, "::error::"...)
+		command = "error"
 	}
 
-	// Write the message
-	buf = append(buf, r.Message...)
+	// Annotation parameters are only understood by warnings and errors, and
+	// only without groups, which would qualify their keys.
+	var params []slog.Attr
+	isParam := func(a slog.Attr) bool {
+		if (command == "warning" || command == "error") && len(h.groups) == 0 && slices.Contains(annotationKeys, a.Key) {
+			params = append(params, a)
+			return true
+		}
+		return false
+	}
 
-	// Write pre-collected attrs from WithAttrs
+	// Collect pre-collected attrs from WithAttrs, then record attrs
+	var attrs []slog.Attr
 	for _, a := range h.attrs {
-		buf = h.appendAttr(buf, a)
+		if !isParam(a) {
+			attrs = append(attrs, a)
+		}
 	}
-
-	// Write record attrs
 	r.Attrs(func(a slog.Attr) bool {
-		buf = h.appendAttr(buf, a)
+		if !isParam(a) {
+			attrs = append(attrs, a)
+		}
 		return true
 	})
 
-	buf = append(buf, '\n')
+	var msg []byte
+	msg = append(msg, r.Message...)
+	for _, a := range attrs {
+		msg = h.appendAttr(msg, a)
+	}
+
+	if command == "" {
+		buf = append(buf, msg...)
+	} else {
+		buf = append(buf, "::"...)
+		buf = append(buf, command...)
+		for i, a := range params {
+			if i == 0 {
+				buf = append(buf, ' ')
+			} else {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, a.Key...)
+			buf = append(buf, '=')
+			buf = append(buf, escapeProperty(a.Value.Resolve().String())...)
+		}
+		buf = append(buf, "::"...)
+		// Commands end at the first newline, which must be escaped.
+		buf = append(buf, escapeData(string(msg))...)
+	}
+
+	return h.write(append(buf, '\n'))
+}
 
+func (h *GithubHandler) write(buf []byte) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	_, err := h.out.Write(buf)
 	return err
 }
 
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a parameter value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
 // WithAttrs returns a new handler with the given attributes added.
 func (h *GithubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	newAttrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
@@ -126,3 +126,63 @@ func TestGithubHandler_ErrorWithAttrs(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestGithubHandler_AnnotationParams(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewGithubHandler(&buf))
+
+	logger.Warn("unknown mode",
+		slog.String(log.FileKey, ".spacectl/cache.yaml"),
+		slog.Int(log.LineKey, 3),
+		slog.String(log.TitleKey, "Invalid config: modes"),
+		slog.String("mode", "rust"))
+
+	got := buf.String()
+	want := "::warning file=.spacectl/cache.yaml,line=3,title=Invalid config%3A modes::unknown mode mode=rust\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGithubHandler_AnnotationParamsOnlyForWarningsAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewGithubHandler(&buf))
+
+	logger.Info("loaded", slog.String(log.FileKey, "cache.yaml"))
+
+	got := buf.String()
+	want := "loaded file=cache.yaml\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGithubHandler_EscapesMultiLineCommands(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewGithubHandler(&buf))
+
+	logger.Error("2 paths failed:\n- a\n- b 100%")
+
+	got := buf.String()
+	want := "::error::2 paths failed:%0A- a%0A- b 100%25\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGithubHandler_Group(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(log.NewGithubHandler(&buf)))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Info("- go")
+	end()
+
+	got := buf.String()
+	want := "::group::Detected:\n- go\n::endgroup::\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ANSI escape sequences used by GitlabHandler. GitLab renders colors in job
+// logs, and \x1b[0K clears the line around section markers.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiGray      = "\x1b[90m"
+	ansiYellow    = "\x1b[33m"
+	ansiRed       = "\x1b[31m"
+	ansiClearLine = "\x1b[0K"
+)
+
+// GitlabHandler is a slog.Handler for GitLab CI job logs. It outputs plain
+// text like PlainHandler, with levels other than info colored, and folds
+// sections started with StartGroup into collapsible sections.
+type GitlabHandler struct {
+	out    io.Writer
+	mu     *sync.Mutex
+	level  slog.Leveler
+	groups []string
+	attrs  []slog.Attr
+	// sections is the stack of open section names, shared by all handlers
+	// derived from this one.
+	sections *sectionStack
+}
+
+type sectionStack struct {
+	names []string
+	count int
+}
+
+// GitlabHandlerOptions are options for a GitlabHandler.
+type GitlabHandlerOptions struct {
+	// Level is the minimum level to log. If nil, defaults to slog.LevelInfo.
+	Level slog.Leveler
+}
+
+// NewGitlabHandler creates a new GitlabHandler that writes to w.
+func NewGitlabHandler(w io.Writer, opts *GitlabHandlerOptions) *GitlabHandler {
+	h := &GitlabHandler{
+		out:      w,
+		mu:       &sync.Mutex{},
+		sections: &sectionStack{},
+	}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *GitlabHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.level != nil {
+		minLevel = h.level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle formats the record as plain text, or as a section marker, and
+// writes it.
+func (h *GitlabHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch groupMarker(r) {
+	case groupStart:
+		h.sections.count++
+		name := fmt.Sprintf("spacectl_%d_%s", h.sections.count, sectionName(r.Message))
+		h.sections.names = append(h.sections.names, name)
+		_, err := fmt.Fprintf(h.out, "%ssection_start:%d:%s[collapsed=true]\r%s%s\n", ansiClearLine, r.Time.Unix(), name, ansiClearLine, r.Message)
+		return err
+	case groupEnd:
+		if len(h.sections.names) == 0 {
+			return nil
+		}
+		name := h.sections.names[len(h.sections.names)-1]
+		h.sections.names = h.sections.names[:len(h.sections.names)-1]
+		_, err := fmt.Fprintf(h.out, "%ssection_end:%d:%s\r%s\n", ansiClearLine, r.Time.Unix(), name, ansiClearLine)
+		return err
+	}
+
+	buf := make([]byte, 0, 256)
+
+	// Write a colored level prefix for non-info levels
+	if color := levelColor(r.Level); color != "" {
+		buf = append(buf, color...)
+		buf = append(buf, '[')
+		buf = append(buf, r.Level.String()...)
+		buf = append(buf, ']')
+		buf = append(buf, ansiReset...)
+		buf = append(buf, ' ')
+	}
+
+	// Write the message
+	buf = append(buf, r.Message...)
+
+	// Write pre-collected attrs from WithAttrs
+	for _, a := range h.attrs {
+		buf = h.appendAttr(buf, a)
+	}
+
+	// Write record attrs
+	r.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, a)
+		return true
+	})
+
+	buf = append(buf, '\n')
+
+	_, err := h.out.Write(buf)
+	return err
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return ansiGray
+	case level < slog.LevelWarn:
+		return ""
+	case level < slog.LevelError:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+var sectionNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// sectionName turns a title into the characters GitLab allows in section
+// names.
+func sectionName(title string) string {
+	return strings.Trim(strings.ToLower(sectionNameInvalid.ReplaceAllString(title, "_")), "_")
+}
+
+// WithAttrs returns a new handler with the given attributes added.
+func (h *GitlabHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	newAttrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(newAttrs, h.attrs)
+	newAttrs = append(newAttrs, attrs...)
+	return &GitlabHandler{
+		out:      h.out,
+		mu:       h.mu,
+		level:    h.level,
+		groups:   h.groups,
+		attrs:    newAttrs,
+		sections: h.sections,
+	}
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *GitlabHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	newGroups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(newGroups, h.groups)
+	newGroups = append(newGroups, name)
+	return &GitlabHandler{
+		out:      h.out,
+		mu:       h.mu,
+		level:    h.level,
+		groups:   newGroups,
+		attrs:    h.attrs,
+		sections: h.sections,
+	}
+}
+
+// appendAttr appends a single attribute to the buffer in key=value format.
+func (h *GitlabHandler) appendAttr(buf []byte, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	buf = append(buf, ' ')
+
+	// Prepend group names if any
+	for _, g := range h.groups {
+		buf = append(buf, g...)
+		buf = append(buf, '.')
+	}
+
+	buf = append(buf, a.Key...)
+	buf = append(buf, '=')
+	buf = appendValue(buf, a.Value)
+	return buf
+}
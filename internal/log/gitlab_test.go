@@ -0,0 +1,80 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+func TestGitlabHandler_InfoPlainText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewGitlabHandler(&buf, nil))
+
+	logger.Info("mounting path", slog.String("to", "/target"))
+
+	got := buf.String()
+	want := "mounting path to=/target\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGitlabHandler_ColoredLevels(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, "\x1b[90m[DEBUG]\x1b[0m test\n"},
+		{slog.LevelWarn, "\x1b[33m[WARN]\x1b[0m test\n"},
+		{slog.LevelError, "\x1b[31m[ERROR]\x1b[0m test\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(log.NewGitlabHandler(&buf, &log.GitlabHandlerOptions{
+				Level: slog.LevelDebug,
+			}))
+
+			logger.Log(context.Background(), tt.level, "test")
+
+			got := buf.String()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitlabHandler_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewGitlabHandler(&buf, nil))
+
+	logger.Debug("hidden")
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestGitlabHandler_Sections(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(log.NewGitlabHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Info("- go")
+	end()
+
+	want := regexp.MustCompile(`^\x1b\[0Ksection_start:\d+:spacectl_1_detected\[collapsed=true\]\r\x1b\[0KDetected:\n` +
+		`- go\n` +
+		`\x1b\[0Ksection_end:\d+:spacectl_1_detected\r\x1b\[0K\n$`)
+	if got := buf.String(); !want.MatchString(got) {
+		t.Errorf("got %q, want match for %q", got, want)
+	}
+}
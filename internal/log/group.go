@@ -0,0 +1,44 @@
+package log
+
+import "log/slog"
+
+// Attribute keys that mark the records logged by StartGroup.
+const (
+	groupStartKey = "spacectl.group"
+	groupEndKey   = "spacectl.endgroup"
+)
+
+type marker int
+
+const (
+	noGroup marker = iota
+	groupStart
+	groupEnd
+)
+
+// StartGroup logs title as the heading of a multi-line section, and returns a
+// func that ends the section. GithubHandler folds the lines logged in between
+// into a collapsible group, while other handlers print the title as is.
+func StartGroup(title string) (end func()) {
+	slog.Info(title, slog.Bool(groupStartKey, true))
+	return func() {
+		slog.Info("", slog.Bool(groupEndKey, true))
+	}
+}
+
+// groupMarker reports whether r starts or ends a section.
+func groupMarker(r slog.Record) marker {
+	m := noGroup
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case groupStartKey:
+			m = groupStart
+		case groupEndKey:
+			m = groupEnd
+		default:
+			return true
+		}
+		return false
+	})
+	return m
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// JSONHandler is a slog.Handler that outputs one JSON object per record, with
+// the time, level, message and attributes, for platforms that ingest
+// structured logs. Sections started with StartGroup log their title only.
+type JSONHandler struct {
+	slog.Handler
+}
+
+// JSONHandlerOptions are options for a JSONHandler.
+type JSONHandlerOptions struct {
+	// Level is the minimum level to log. If nil, defaults to slog.LevelInfo.
+	Level slog.Leveler
+}
+
+// NewJSONHandler creates a new JSONHandler that writes to w.
+func NewJSONHandler(w io.Writer, opts *JSONHandlerOptions) *JSONHandler {
+	var handlerOpts slog.HandlerOptions
+	if opts != nil {
+		handlerOpts.Level = opts.Level
+	}
+	return &JSONHandler{Handler: slog.NewJSONHandler(w, &handlerOpts)}
+}
+
+// Handle writes the record as a JSON object.
+func (h *JSONHandler) Handle(ctx context.Context, r slog.Record) error {
+	return plainRecords{h.Handler}.Handle(ctx, r)
+}
+
+// WithAttrs returns a new handler with the given attributes added.
+func (h *JSONHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &JSONHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *JSONHandler) WithGroup(name string) slog.Handler {
+	return &JSONHandler{Handler: h.Handler.WithGroup(name)}
+}
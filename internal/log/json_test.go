@@ -0,0 +1,67 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+func TestJSONHandler_Format(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewJSONHandler(&buf, nil)).With(slog.String("component", "cache"))
+
+	logger.Warn("mount failed", slog.String("path", "/cache"))
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if _, ok := got["time"]; !ok {
+		t.Errorf("missing time in %v", got)
+	}
+	delete(got, "time")
+
+	want := map[string]any{"level": "WARN", "msg": "mount failed", "component": "cache", "path": "/cache"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %v, want %v", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONHandler_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewJSONHandler(&buf, &log.JSONHandlerOptions{Level: slog.LevelWarn}))
+
+	logger.Info("hidden")
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+func TestJSONHandler_Group(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(log.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Info("- go")
+	end()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if strings.Contains(lines[0], "spacectl.group") || !strings.Contains(lines[0], `"msg":"Detected:"`) {
+		t.Errorf("unexpected section title %q", lines[0])
+	}
+}
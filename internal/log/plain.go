@@ -14,6 +14,7 @@ type PlainHandler struct {
 	out    io.Writer
 	mu     *sync.Mutex
 	level  slog.Leveler
+	color  bool
 	groups []string
 	attrs  []slog.Attr
 }
@@ -22,6 +23,8 @@ type PlainHandler struct {
 type PlainHandlerOptions struct {
 	// Level is the minimum level to log. If nil, defaults to slog.LevelInfo.
 	Level slog.Leveler
+	// Color colors the level prefixes, for terminals.
+	Color bool
 }
 
 // NewPlainHandler creates a new PlainHandler that writes to w.
@@ -30,8 +33,9 @@ func NewPlainHandler(w io.Writer, opts *PlainHandlerOptions) *PlainHandler {
 		out: w,
 		mu:  &sync.Mutex{},
 	}
-	if opts != nil && opts.Level != nil {
+	if opts != nil {
 		h.level = opts.Level
+		h.color = opts.Color
 	}
 	return h
 }
@@ -49,11 +53,26 @@ func (h *PlainHandler) Enabled(_ context.Context, level slog.Level) bool {
 func (h *PlainHandler) Handle(_ context.Context, r slog.Record) error {
 	buf := make([]byte, 0, 256)
 
+	// Sections print their title only.
+	switch groupMarker(r) {
+	case groupStart:
+		r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	case groupEnd:
+		return nil
+	}
+
 	// Write level prefix for non-info levels
 	if r.Level != slog.LevelInfo {
+		if h.color {
+			buf = append(buf, levelColor(r.Level)...)
+		}
 		buf = append(buf, '[')
 		buf = append(buf, r.Level.String()...)
-		buf = append(buf, "] "...)
+		buf = append(buf, ']')
+		if h.color {
+			buf = append(buf, ansiReset...)
+		}
+		buf = append(buf, ' ')
 	}
 
 	// Write the message
@@ -87,6 +106,7 @@ func (h *PlainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 		out:    h.out,
 		mu:     h.mu,
 		level:  h.level,
+		color:  h.color,
 		groups: h.groups,
 		attrs:  newAttrs,
 	}
@@ -104,6 +124,7 @@ func (h *PlainHandler) WithGroup(name string) slog.Handler {
 		out:    h.out,
 		mu:     h.mu,
 		level:  h.level,
+		color:  h.color,
 		groups: newGroups,
 		attrs:  h.attrs,
 	}
@@ -142,3 +142,34 @@ func TestPlainHandler_LevelPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestPlainHandler_Group(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(log.NewPlainHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	end := log.StartGroup("Detected:")
+	slog.Info("- go")
+	end()
+
+	got := buf.String()
+	want := "Detected:\n- go\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPlainHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(log.NewPlainHandler(&buf, &log.PlainHandlerOptions{Color: true}))
+
+	logger.Info("plain")
+	logger.Warn("careful")
+
+	got := buf.String()
+	want := "plain\n\x1b[33m[WARN]\x1b[0m careful\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// terminal is where logs are written when it is an interactive terminal, so
+// that progress is shown live below them.
+var terminal atomic.Pointer[Terminal]
+
+// SetTerminal makes t the terminal that Progress draws on. Nil disables live
+// progress, which is then left to the log lines of the caller.
+func SetTerminal(t *Terminal) {
+	terminal.Store(t)
+}
+
+// IsTerminal reports whether w is an interactive terminal that can redraw
+// lines, i.e. not a pipe, a file or TERM=dumb.
+func IsTerminal(w io.Writer) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Terminal is a writer to a terminal that keeps a block of status lines
+// below what is written to it. Writes must be whole lines, as log handlers
+// write them.
+type Terminal struct {
+	mu     sync.Mutex
+	out    io.Writer
+	status []string
+}
+
+// NewTerminal creates a Terminal writing to w.
+func NewTerminal(w io.Writer) *Terminal {
+	return &Terminal{out: w}
+}
+
+// Write writes p above the status lines.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.clear()
+	n, err := t.out.Write(p)
+	t.draw()
+	return n, err
+}
+
+// SetStatus replaces the status lines. Lines are cut to the width of the
+// terminal, so that they can be erased again.
+func (t *Terminal) SetStatus(lines []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.clear()
+	t.status = lines
+	t.draw()
+}
+
+func (t *Terminal) clear() {
+	if len(t.status) > 0 {
+		fmt.Fprintf(t.out, "\x1b[%dF\x1b[J", len(t.status))
+	}
+}
+
+func (t *Terminal) draw() {
+	width := terminalWidth()
+	var b strings.Builder
+	for _, line := range t.status {
+		if r := []rune(line); len(r) >= width {
+			line = string(r[:width-1])
+		}
+		b.WriteString(line + "\n")
+	}
+	io.WriteString(t.out, b.String())
+}
+
+// terminalWidth is the width of the terminal from COLUMNS, or 80.
+func terminalWidth() int {
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 1 {
+		return width
+	}
+	return 80
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// maxProgressTasks is how many running tasks are listed below the spinner.
+const maxProgressTasks = 8
+
+// Progress shows the tasks that are running, e.g. the paths being mounted,
+// with a spinner below the logs of a Terminal set with SetTerminal. Without
+// one, e.g. in CI, it shows nothing, and callers log each task as it
+// finishes instead.
+type Progress struct {
+	title string
+	term  *Terminal
+	start time.Time
+
+	mu      sync.Mutex
+	running []progressTask
+	done    int
+	frame   int
+	closed  bool
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+type progressTask struct {
+	key, label string
+	start      time.Time
+}
+
+// NewProgress starts showing progress under title, e.g. "Mounting caches".
+// Stop must be called once all tasks are done.
+func NewProgress(title string) *Progress {
+	p := &Progress{title: title, term: terminal.Load(), start: time.Now(), stop: make(chan struct{})}
+	if p.term == nil {
+		return p
+	}
+
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.mu.Unlock()
+				p.redraw()
+			}
+		}
+	}()
+	return p
+}
+
+// Start shows a task as running.
+func (p *Progress) Start(key, label string) {
+	p.mu.Lock()
+	p.running = append(p.running, progressTask{key: key, label: label, start: time.Now()})
+	p.mu.Unlock()
+	p.redraw()
+}
+
+// Finish shows a task as done.
+func (p *Progress) Finish(key string) {
+	p.mu.Lock()
+	p.running = slices.DeleteFunc(p.running, func(t progressTask) bool { return t.key == key })
+	p.done++
+	p.mu.Unlock()
+	p.redraw()
+}
+
+// Stop removes the progress from the terminal.
+func (p *Progress) Stop() {
+	if p.term == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	p.mu.Unlock()
+
+	p.stopped.Wait()
+	p.term.SetStatus(nil)
+}
+
+func (p *Progress) redraw() {
+	if p.term == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.term.SetStatus(p.lines(time.Now()))
+	}
+}
+
+// lines renders the spinner line and the running tasks.
+func (p *Progress) lines(now time.Time) []string {
+	lines := []string{fmt.Sprintf("%s %s: %d done, %d running (%s)",
+		spinnerFrames[p.frame%len(spinnerFrames)], p.title, p.done, len(p.running), now.Sub(p.start).Truncate(time.Second))}
+	for i, t := range p.running {
+		if i == maxProgressTasks {
+			lines = append(lines, fmt.Sprintf("  … and %d more", len(p.running)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s (%s)", t.label, now.Sub(t.start).Truncate(100*time.Millisecond)))
+	}
+	return lines
+}
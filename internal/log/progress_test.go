@@ -0,0 +1,66 @@
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/namespacelabs/spacectl/internal/log"
+)
+
+func TestTerminal(t *testing.T) {
+	t.Setenv("COLUMNS", "12")
+
+	var buf bytes.Buffer
+	term := log.NewTerminal(&buf)
+	term.SetStatus([]string{"mounting", "a very long status line"})
+	if _, err := term.Write([]byte("mounted\n")); err != nil {
+		t.Fatal(err)
+	}
+	term.SetStatus(nil)
+
+	want := "mounting\na very long\n" +
+		// Logs are written above the status lines, which are drawn again.
+		"\x1b[2F\x1b[Jmounted\nmounting\na very long\n" +
+		"\x1b[2F\x1b[J"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Run("without a terminal", func(t *testing.T) {
+		p := log.NewProgress("Mounting caches")
+		p.Start("go", "/go/pkg/mod")
+		p.Finish("go")
+		p.Stop()
+	})
+
+	t.Run("on a terminal", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetTerminal(log.NewTerminal(&buf))
+		defer log.SetTerminal(nil)
+
+		p := log.NewProgress("Mounting caches")
+		p.Start("go", "/go/pkg/mod (go)")
+		p.Start("rust", "./target (rust)")
+		p.Finish("go")
+		p.Stop()
+		p.Stop()
+
+		out := buf.String()
+		for _, want := range []string{
+			"Mounting caches: 0 done, 2 running",
+			"  /go/pkg/mod (go) (",
+			"Mounting caches: 1 done, 1 running",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in %q", want, out)
+			}
+		}
+		// The progress is erased when stopped.
+		if !strings.HasSuffix(out, "\x1b[2F\x1b[J") {
+			t.Errorf("expected the progress to be erased, got %q", out)
+		}
+	})
+}
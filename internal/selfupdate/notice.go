@@ -0,0 +1,117 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCheckInterval is how often the notice looks up the latest release.
+const DefaultCheckInterval = 24 * time.Hour
+
+// Notice tells when a newer release is available. Lookups are rate limited
+// by recording the latest release in a state file, and never hold up the
+// command: a lookup runs while the command does, and the notice falls back
+// to the recorded release when it is not done yet.
+type Notice struct {
+	Client Client
+	// StatePath defaults to DefaultStatePath.
+	StatePath string
+	// Interval defaults to DefaultCheckInterval.
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+type noticeState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest,omitzero"`
+}
+
+// DefaultStatePath is in the user cache directory, or empty when there is
+// none.
+func DefaultStatePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "spacectl", "update-check.json")
+}
+
+// Start looks up the latest release in the background when the recorded one
+// is older than the interval. The returned function reports the latest
+// release known when it is called, and whether it is newer than current.
+func (n Notice) Start(ctx context.Context, current string) func() (string, bool) {
+	statePath := n.StatePath
+	if statePath == "" {
+		statePath = DefaultStatePath()
+	}
+	now := time.Now
+	if n.Now != nil {
+		now = n.Now
+	}
+	interval := n.Interval
+	if interval == 0 {
+		interval = DefaultCheckInterval
+	}
+
+	var state noticeState
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &state)
+	}
+
+	latest := func(s noticeState) (string, bool) {
+		return s.Latest, Newer(s.Latest, current)
+	}
+	if now().Sub(state.CheckedAt) < interval {
+		return func() (string, bool) { return latest(state) }
+	}
+
+	done := make(chan noticeState, 1)
+	go func() {
+		// Failed lookups are recorded too, so that they are not retried on
+		// every run while offline.
+		checked := noticeState{CheckedAt: now(), Latest: state.Latest}
+		if r, err := n.Client.Latest(ctx); err == nil {
+			checked.Latest = r.Version
+		}
+		if statePath != "" {
+			_ = writeState(statePath, checked)
+		}
+		done <- checked
+	}()
+
+	return func() (string, bool) {
+		select {
+		case checked := <-done:
+			return latest(checked)
+		default:
+			return latest(state)
+		}
+	}
+}
+
+// writeState replaces the state file atomically, as concurrent runs read it.
+func writeState(path string, state noticeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".update-check-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
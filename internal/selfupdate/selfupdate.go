@@ -0,0 +1,270 @@
+// Package selfupdate finds the latest release of spacectl, and replaces the
+// running binary with it.
+//
+// Releases are published on GitHub by goreleaser, as a tar.gz archive per
+// platform with a checksums.txt listing their SHA-256. Archives are verified
+// against it before they are unpacked.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// DefaultBaseURL is the GitHub API of the spacectl repository.
+const DefaultBaseURL = "https://api.github.com/repos/namespacelabs/spacectl"
+
+// DefaultTimeout bounds looking up the latest release.
+const DefaultTimeout = 10 * time.Second
+
+// checksumsAsset is the name of the checksum file of a release.
+const checksumsAsset = "checksums.txt"
+
+// maxBinarySize bounds the binary read from an archive, to not fill memory
+// and the disk with a broken download.
+const maxBinarySize = 512 << 20
+
+// Release is a published version of spacectl.
+type Release struct {
+	Version string  `json:"tag_name"`
+	URL     string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Client looks up and downloads releases.
+type Client struct {
+	// BaseURL defaults to DefaultBaseURL.
+	BaseURL string
+	// UserAgent identifies the running version.
+	UserAgent  string
+	HTTPClient *http.Client
+}
+
+// Latest returns the latest release, leaving out prereleases.
+func (c Client) Latest(ctx context.Context) (Release, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	body, err := c.get(ctx, cmp.Or(c.BaseURL, DefaultBaseURL)+"/releases/latest")
+	if err != nil {
+		return Release{}, fmt.Errorf("looking up the latest release: %w", err)
+	}
+
+	var r Release
+	if err := json.Unmarshal(body, &r); err != nil {
+		return Release{}, fmt.Errorf("parsing the latest release: %w", err)
+	}
+	if !semver.IsValid(r.Version) {
+		return Release{}, fmt.Errorf("latest release has an invalid version %q", r.Version)
+	}
+	return r, nil
+}
+
+// AssetName is the name of the archive of version for a platform, as
+// goreleaser names it.
+func AssetName(version, goos, goarch string) string {
+	return fmt.Sprintf("spacectl_%s_%s_%s.tar.gz", strings.TrimPrefix(version, "v"), goos, goarch)
+}
+
+// Download downloads the binary of r for a platform, after checking the
+// archive against the checksums of the release.
+func (c Client) Download(ctx context.Context, r Release, goos, goarch string) ([]byte, error) {
+	name := AssetName(r.Version, goos, goarch)
+	archive, ok := r.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no archive for %s/%s", r.Version, goos, goarch)
+	}
+	checksums, ok := r.asset(checksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s", r.Version, checksumsAsset)
+	}
+
+	sums, err := c.get(ctx, checksums.URL)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", checksumsAsset, err)
+	}
+	want, err := checksum(sums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.get(ctx, archive.URL)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum of %s does not match %s", name, checksumsAsset)
+	}
+
+	binary := "spacectl"
+	if goos == "windows" {
+		binary += ".exe"
+	}
+	return extract(data, binary)
+}
+
+// checksum finds the SHA-256 of name in a checksum file, which has a
+// "<hex>  <name>" line per file.
+func checksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// extract reads the file named binary from a tar.gz archive.
+func extract(archive []byte, binary string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("archive has no %s", binary)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != binary {
+			continue
+		}
+		if hdr.Size > maxBinarySize {
+			return nil, fmt.Errorf("%s in archive is %d bytes, more than the %d bytes expected at most", binary, hdr.Size, maxBinarySize)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from archive: %w", binary, err)
+		}
+		if int64(len(data)) != hdr.Size {
+			return nil, fmt.Errorf("reading %s from archive: read %d of %d bytes", binary, len(data), hdr.Size)
+		}
+		return data, nil
+	}
+}
+
+func (c Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	client := cmp.Or(c.HTTPClient, http.DefaultClient)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Newer reports whether latest is a newer version than current. Versions
+// that are not semantic versions, like those of development builds, are
+// never older.
+func Newer(latest, current string) bool {
+	latest, current = canonical(latest), canonical(current)
+	if !semver.IsValid(latest) || !semver.IsValid(current) {
+		return false
+	}
+	return semver.Compare(latest, current) > 0
+}
+
+// Released reports whether version is that of a release, as opposed to a
+// development build.
+func Released(version string) bool {
+	return semver.IsValid(canonical(version))
+}
+
+// canonical adds the v prefix that goreleaser drops from versions.
+func canonical(version string) string {
+	if version != "" && !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+// Replace replaces the executable at exe with binary. The new binary is
+// written next to it and renamed over it, so that exe is never partially
+// written. Windows does not replace running executables, which are moved
+// aside to <exe>.old first.
+func Replace(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", exe, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".spacectl-update-*")
+	if err != nil {
+		return fmt.Errorf("writing the new binary next to %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing the new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing the new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return fmt.Errorf("making the new binary executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("moving %s aside: %w", exe, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("replacing %s: %w", exe, err)
+	}
+	return nil
+}
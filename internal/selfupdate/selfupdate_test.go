@@ -0,0 +1,180 @@
+package selfupdate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/selfupdate"
+)
+
+func archive(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for file, data := range map[string][]byte{"README.md": []byte("readme"), name: content} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// releaseServer serves a release of version with data as the archive for
+// linux/amd64. The checksum of the archive is corrupted when corrupt is set.
+func releaseServer(t *testing.T, version string, data []byte, corrupt bool, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	asset := selfupdate.AssetName(version, "linux", "amd64")
+	sum := sha256.Sum256(data)
+	if corrupt {
+		sum[0]++
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests.Add(1)
+		}
+		require.Equal(t, "spacectl/v1.0.0", r.Header.Get("User-Agent"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"tag_name": version,
+			"html_url": srv.URL + "/releases/" + version,
+			"assets": []map[string]string{
+				{"name": asset, "browser_download_url": srv.URL + "/download/" + asset},
+				{"name": "checksums.txt", "browser_download_url": srv.URL + "/download/checksums.txt"},
+			},
+		})
+	})
+	mux.HandleFunc("/download/"+asset, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(data) })
+	mux.HandleFunc("/download/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  spacectl_other_linux_arm64.tar.gz\n%s  %s\n", hex.EncodeToString(make([]byte, 32)), hex.EncodeToString(sum[:]), asset)
+	})
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	t.Run("downloads verified binary", func(t *testing.T) {
+		srv := releaseServer(t, "v1.2.0", archive(t, "spacectl", []byte("new binary")), false, nil)
+		client := selfupdate.Client{BaseURL: srv.URL, UserAgent: "spacectl/v1.0.0"}
+
+		r, err := client.Latest(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "v1.2.0", r.Version)
+
+		binary, err := client.Download(t.Context(), r, "linux", "amd64")
+		require.NoError(t, err)
+		require.Equal(t, "new binary", string(binary))
+
+		_, err = client.Download(t.Context(), r, "plan9", "amd64")
+		require.ErrorContains(t, err, "no archive for plan9/amd64")
+	})
+
+	t.Run("refuses checksum mismatch", func(t *testing.T) {
+		srv := releaseServer(t, "v1.2.0", archive(t, "spacectl", []byte("new binary")), true, nil)
+		client := selfupdate.Client{BaseURL: srv.URL, UserAgent: "spacectl/v1.0.0"}
+
+		r, err := client.Latest(t.Context())
+		require.NoError(t, err)
+		_, err = client.Download(t.Context(), r, "linux", "amd64")
+		require.ErrorContains(t, err, "checksum of spacectl_1.2.0_linux_amd64.tar.gz does not match checksums.txt")
+	})
+
+	t.Run("refuses oversized binary", func(t *testing.T) {
+		// The header is all that is read of a binary that is too large.
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "spacectl", Mode: 0o755, Size: 1 << 30, Typeflag: tar.TypeReg}))
+		require.NoError(t, gz.Close())
+
+		srv := releaseServer(t, "v1.2.0", buf.Bytes(), false, nil)
+		client := selfupdate.Client{BaseURL: srv.URL, UserAgent: "spacectl/v1.0.0"}
+
+		r, err := client.Latest(t.Context())
+		require.NoError(t, err)
+		_, err = client.Download(t.Context(), r, "linux", "amd64")
+		require.ErrorContains(t, err, "spacectl in archive is 1073741824 bytes")
+	})
+}
+
+func TestReplace(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "spacectl")
+	require.NoError(t, os.WriteFile(exe, []byte("old binary"), 0o755))
+
+	require.NoError(t, selfupdate.Replace(exe, []byte("new binary")))
+
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	require.Equal(t, "new binary", string(data))
+	entries, err := os.ReadDir(filepath.Dir(exe))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestNewer(t *testing.T) {
+	require.True(t, selfupdate.Newer("v1.2.0", "1.1.9"))
+	require.False(t, selfupdate.Newer("v1.2.0", "v1.2.0"))
+	require.False(t, selfupdate.Newer("v1.1.0", "v1.2.0"))
+	require.False(t, selfupdate.Newer("v1.2.0", "dev"))
+	require.False(t, selfupdate.Newer("", "v1.2.0"))
+
+	require.True(t, selfupdate.Released("1.2.0"))
+	require.False(t, selfupdate.Released("dev"))
+}
+
+func TestNotice(t *testing.T) {
+	var requests atomic.Int32
+	srv := releaseServer(t, "v1.2.0", archive(t, "spacectl", []byte("new binary")), false, &requests)
+	statePath := filepath.Join(t.TempDir(), "spacectl", "update-check.json")
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	notice := selfupdate.Notice{
+		Client:    selfupdate.Client{BaseURL: srv.URL, UserAgent: "spacectl/v1.0.0"},
+		StatePath: statePath,
+		Now:       func() time.Time { return now },
+	}
+
+	// The first run looks up the release in the background.
+	notice.Start(t.Context(), "v1.0.0")
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(statePath)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 1, requests.Load())
+
+	// Later runs within the interval use the recorded release.
+	now = now.Add(time.Hour)
+	latest, newer := notice.Start(t.Context(), "v1.0.0")()
+	require.Equal(t, "v1.2.0", latest)
+	require.True(t, newer)
+	_, newer = notice.Start(t.Context(), "v1.2.0")()
+	require.False(t, newer)
+	require.EqualValues(t, 1, requests.Load())
+
+	// Once it passes, the release is looked up again.
+	now = now.Add(selfupdate.DefaultCheckInterval)
+	notice.Start(t.Context(), "v1.0.0")
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(statePath)
+		return bytes.Contains(data, []byte(now.Format(time.RFC3339)))
+	}, 5*time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 2, requests.Load())
+}
@@ -0,0 +1,242 @@
+package tracing
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds an export, which must not hold up the job.
+const DefaultTimeout = 5 * time.Second
+
+// Exporter sends spans to an OTLP/HTTP endpoint.
+type Exporter struct {
+	// Endpoint is the full URL that spans are posted to, e.g.
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+	Headers  map[string]string
+	// ServiceName is the service.name of the spans.
+	ServiceName    string
+	ServiceVersion string
+	// HTTPClient defaults to a client that gives up after DefaultTimeout.
+	HTTPClient *http.Client
+}
+
+// ExporterFromEnv configures an exporter from the standard OpenTelemetry
+// environment variables. It reports false when no endpoint is set, or when
+// tracing is turned off.
+func ExporterFromEnv(getenv func(string) string) (Exporter, bool, error) {
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") || getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return Exporter{}, false, nil
+	}
+
+	e := Exporter{ServiceName: getenv("OTEL_SERVICE_NAME")}
+	if endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		e.Endpoint = endpoint
+	} else if endpoint := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		e.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	} else {
+		return Exporter{}, false, nil
+	}
+
+	// Collectors take JSON on the same endpoint as protobuf, but not grpc.
+	if cmp.Or(getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"), getenv("OTEL_EXPORTER_OTLP_PROTOCOL")) == "grpc" {
+		return Exporter{}, false, fmt.Errorf("OTLP over grpc is not supported, use http/json")
+	}
+
+	headers, err := parseHeaders(getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return Exporter{}, false, fmt.Errorf("OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	traceHeaders, err := parseHeaders(getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS"))
+	if err != nil {
+		return Exporter{}, false, fmt.Errorf("OTEL_EXPORTER_OTLP_TRACES_HEADERS: %w", err)
+	}
+	maps.Copy(headers, traceHeaders)
+	e.Headers = headers
+	return e, true, nil
+}
+
+// parseHeaders parses a list of key=value pairs with URL encoded values.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for pair := range strings.SplitSeq(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %w", key, err)
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers, nil
+}
+
+// Export posts the ended spans of t.
+func (e Exporter) Export(ctx context.Context, t *Tracer) error {
+	body, err := json.Marshal(e.encode(t))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("exporting spans: %s", resp.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding, which writes ids in hex and 64 bit integers as
+// strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitzero"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitzero"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitzero"`
+		Status            otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitzero"`
+		Message string `json:"message,omitzero"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string         `json:"stringValue,omitempty"`
+		BoolValue   *bool           `json:"boolValue,omitempty"`
+		IntValue    *string         `json:"intValue,omitempty"`
+		ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	}
+	otlpArrayValue struct {
+		Values []otlpValue `json:"values"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusError      = 2
+)
+
+func (e Exporter) encode(t *Tracer) otlpRequest {
+	service := e.ServiceName
+	if service == "" {
+		service = "spacectl"
+	}
+	resource := []otlpAttr{encodeAttr(String("service.name", service))}
+	if e.ServiceVersion != "" {
+		resource = append(resource, encodeAttr(String("service.version", e.ServiceVersion)))
+	}
+
+	var spans []otlpSpan
+	for _, s := range t.Spans() {
+		s.mu.Lock()
+		if !s.end.IsZero() {
+			span := otlpSpan{
+				TraceID:           hex.EncodeToString(t.traceID[:]),
+				SpanID:            hex.EncodeToString(s.id[:]),
+				Name:              s.name,
+				Kind:              spanKindInternal,
+				StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+				EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			}
+			if s.parentID != [8]byte{} {
+				span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+			}
+			for _, a := range s.attrs {
+				span.Attributes = append(span.Attributes, encodeAttr(a))
+			}
+			if s.err != nil {
+				span.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+			}
+			spans = append(spans, span)
+		}
+		s.mu.Unlock()
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "spacectl", Version: e.ServiceVersion}, Spans: spans}},
+	}}}
+}
+
+func encodeAttr(a Attr) otlpAttr {
+	return otlpAttr{Key: a.Key, Value: encodeValue(a.Value)}
+}
+
+func encodeValue(v any) otlpValue {
+	switch v := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case []string:
+		values := make([]otlpValue, len(v))
+		for i, s := range v {
+			values[i] = encodeValue(s)
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
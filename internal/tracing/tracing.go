@@ -0,0 +1,155 @@
+// Package tracing records spans of spacectl commands, so that the time spent
+// setting up caches shows up in the trace views of CI systems next to build
+// steps.
+//
+// Spans are kept in memory and exported once the command completes, to an
+// OpenTelemetry collector over OTLP/HTTP with the JSON encoding. Commands
+// are short and record few spans, which does not warrant a full SDK.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer collects the spans of a command, which all belong to one trace.
+type Tracer struct {
+	traceID [16]byte
+	// parentID is the span that the root spans of the command belong to,
+	// when the command runs within a trace started by the CI system.
+	parentID [8]byte
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewTracer starts a trace. A W3C traceparent, as CI systems that trace jobs
+// pass in $TRACEPARENT, makes the spans of the command part of its trace.
+func NewTracer(traceparent string) *Tracer {
+	t := &Tracer{}
+	if traceID, parentID, ok := parseTraceparent(traceparent); ok {
+		t.traceID, t.parentID = traceID, parentID
+	} else {
+		_, _ = rand.Read(t.traceID[:])
+	}
+	return t
+}
+
+// parseTraceparent parses a version 00 traceparent:
+// 00-<32 hex trace id>-<16 hex parent id>-<2 hex flags>.
+func parseTraceparent(s string) (traceID [16]byte, parentID [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false
+	}
+	return traceID, parentID, traceID != [16]byte{} && parentID != [8]byte{}
+}
+
+// Spans returns the spans recorded so far, in the order they started.
+func (t *Tracer) Spans() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Span(nil), t.spans...)
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+
+// WithTracer returns a context that records spans with t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Span is a timed operation. A nil Span records nothing, so that code can
+// be traced without checking whether tracing is on.
+type Span struct {
+	tracer   *Tracer
+	id       [8]byte
+	parentID [8]byte
+
+	mu    sync.Mutex
+	name  string
+	start time.Time
+	end   time.Time
+	attrs []Attr
+	err   error
+}
+
+// Start starts a span as a child of the span in ctx, and returns a context
+// holding it. Without a tracer in ctx, the span is nil.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, start: time.Now(), attrs: attrs, parentID: t.parentID}
+	if parent, _ := ctx.Value(spanKey{}).(*Span); parent != nil {
+		s.parentID = parent.id
+	}
+	_, _ = rand.Read(s.id[:])
+
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetName renames the span, e.g. once the operation is known better.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End ends the span. A non-nil err marks the operation as failed.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.end.IsZero() {
+		s.end = time.Now()
+		s.err = err
+	}
+}
+
+// Attr is an attribute of a span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+func Strings(key string, value []string) Attr { return Attr{Key: key, Value: value} }
+
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+func Int64(key string, value int64) Attr { return Attr{Key: key, Value: value} }
+
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
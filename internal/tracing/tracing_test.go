@@ -0,0 +1,166 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/tracing"
+)
+
+func TestStart_withoutTracer(t *testing.T) {
+	ctx, span := tracing.Start(t.Context(), "noop", tracing.String("k", "v"))
+	require.Nil(t, span)
+	require.Equal(t, t.Context(), ctx)
+
+	// Nil spans can be used like others.
+	span.SetName("renamed")
+	span.SetAttributes(tracing.Int("n", 1))
+	span.End(errors.New("failed"))
+}
+
+func TestExporterFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	_, ok, err := tracing.ExporterFromEnv(env(nil))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	e, ok, err := tracing.ExporterFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":       "http://collector:4318/",
+		"OTEL_EXPORTER_OTLP_HEADERS":        "authorization=Bearer%20abc, x-team=ci",
+		"OTEL_EXPORTER_OTLP_TRACES_HEADERS": "x-team=builds",
+		"OTEL_SERVICE_NAME":                 "runner",
+	}))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "http://collector:4318/v1/traces", e.Endpoint)
+	require.Equal(t, map[string]string{"authorization": "Bearer abc", "x-team": "builds"}, e.Headers)
+	require.Equal(t, "runner", e.ServiceName)
+
+	e, ok, err = tracing.ExporterFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://collector:4318",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://traces:4318/custom",
+	}))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "http://traces:4318/custom", e.Endpoint)
+
+	_, ok, err = tracing.ExporterFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+		"OTEL_SDK_DISABLED":           "true",
+	}))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = tracing.ExporterFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317",
+		"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+	}))
+	require.ErrorContains(t, err, "grpc is not supported")
+
+	_, _, err = tracing.ExporterFromEnv(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "novalue",
+	}))
+	require.ErrorContains(t, err, "is not a key=value pair")
+}
+
+type exported struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []attr `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+				Attributes   []attr `json:"attributes"`
+				Status       struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type attr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func TestExporter_Export(t *testing.T) {
+	var got exported
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("x-api-key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	tracer := tracing.NewTracer("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx := tracing.WithTracer(context.Background(), tracer)
+
+	ctx, root := tracing.Start(ctx, "spacectl")
+	_, child := tracing.Start(ctx, "mount", tracing.String("spacectl.mode", "go"), tracing.Bool("spacectl.cache_hit", true), tracing.Int("n", 2))
+	child.End(errors.New("mount failed"))
+	_, _ = tracing.Start(ctx, "unfinished")
+	root.SetName("spacectl cache mount")
+	root.End(nil)
+
+	e := tracing.Exporter{Endpoint: server.URL + "/v1/traces", Headers: map[string]string{"x-api-key": "secret"}, ServiceVersion: "v1.0.0"}
+	require.NoError(t, e.Export(t.Context(), tracer))
+
+	require.Len(t, got.ResourceSpans, 1)
+	require.Equal(t, []attr{
+		{Key: "service.name", Value: map[string]any{"stringValue": "spacectl"}},
+		{Key: "service.version", Value: map[string]any{"stringValue": "v1.0.0"}},
+	}, got.ResourceSpans[0].Resource.Attributes)
+
+	// Spans that did not end are not exported.
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	require.Equal(t, "spacectl cache mount", spans[0].Name)
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[0].TraceID)
+	require.Equal(t, "b7ad6b7169203331", spans[0].ParentSpanID)
+
+	require.Equal(t, "mount", spans[1].Name)
+	require.Equal(t, spans[0].TraceID, spans[1].TraceID)
+	require.Equal(t, spans[0].SpanID, spans[1].ParentSpanID)
+	require.Equal(t, 2, spans[1].Status.Code)
+	require.Equal(t, "mount failed", spans[1].Status.Message)
+	require.Equal(t, []attr{
+		{Key: "spacectl.mode", Value: map[string]any{"stringValue": "go"}},
+		{Key: "spacectl.cache_hit", Value: map[string]any{"boolValue": true}},
+		{Key: "n", Value: map[string]any{"intValue": "2"}},
+	}, spans[1].Attributes)
+}
+
+func TestNewTracer_invalidTraceparent(t *testing.T) {
+	for _, traceparent := range []string{"", "garbage", "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "00-00000000000000000000000000000000-b7ad6b7169203331-01"} {
+		tracer := tracing.NewTracer(traceparent)
+		_, span := tracing.Start(tracing.WithTracer(t.Context(), tracer), "root")
+		span.End(nil)
+
+		var got exported
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		}))
+		require.NoError(t, tracing.Exporter{Endpoint: server.URL}.Export(t.Context(), tracer))
+		server.Close()
+
+		span0 := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		require.Empty(t, span0.ParentSpanID, traceparent)
+		require.NotEqual(t, "00000000000000000000000000000000", span0.TraceID, traceparent)
+	}
+}
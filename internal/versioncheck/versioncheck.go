@@ -0,0 +1,92 @@
+// Package versioncheck asks the Namespace API for the minimum version of
+// spacectl that an organization supports, so that pipelines can enforce
+// upgrades.
+package versioncheck
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/namespacelabs/spacectl/internal/selfupdate"
+)
+
+// DefaultTimeout bounds a check, which must not hold up the job.
+const DefaultTimeout = 10 * time.Second
+
+// Policy is the version policy returned by the API.
+type Policy struct {
+	MinimumVersion string `json:"minimum_version"`
+	// Message explains the policy, e.g. why older versions are not
+	// supported.
+	Message string `json:"message,omitzero"`
+}
+
+// Supported reports whether version satisfies the policy. Development
+// builds, whose versions cannot be compared, are always supported, as is
+// any version when the policy sets no minimum.
+func (p Policy) Supported(version string) bool {
+	if p.MinimumVersion == "" {
+		return true
+	}
+	return !selfupdate.Newer(p.MinimumVersion, version)
+}
+
+// Client gets the version policy from an endpoint of the Namespace API.
+type Client struct {
+	Endpoint string
+	// Token is sent as a bearer token, when set.
+	Token string
+	// HTTPClient defaults to a client that gives up after DefaultTimeout.
+	HTTPClient *http.Client
+}
+
+// Policy gets the policy for the running version, which is sent along.
+func (c Client) Policy(ctx context.Context, version string) (Policy, error) {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return Policy{}, fmt.Errorf("parsing version check endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("version", version)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Policy{}, fmt.Errorf("creating version check request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "spacectl/"+cmp.Or(version, "unknown"))
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Policy{}, fmt.Errorf("checking version: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return Policy{}, fmt.Errorf("checking version: %s", resp.Status)
+	}
+
+	var p Policy
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return Policy{}, fmt.Errorf("parsing version policy: %w", err)
+	}
+	if p.MinimumVersion != "" && !selfupdate.Released(p.MinimumVersion) {
+		return Policy{}, fmt.Errorf("version policy has an invalid minimum version %q", p.MinimumVersion)
+	}
+	return p, nil
+}
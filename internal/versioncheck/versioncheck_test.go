@@ -0,0 +1,51 @@
+package versioncheck_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/internal/versioncheck"
+)
+
+func TestClient_Policy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		require.Equal(t, "spacectl/v1.2.3", r.Header.Get("User-Agent"))
+		require.Equal(t, "v1.2.3", r.URL.Query().Get("version"))
+		require.Equal(t, "acme", r.URL.Query().Get("org"))
+		switch r.URL.Path {
+		case "/invalid":
+			_, _ = w.Write([]byte(`{"minimum_version": "latest"}`))
+		case "/fail":
+			http.Error(w, "nope", http.StatusForbidden)
+		default:
+			_, _ = w.Write([]byte(`{"minimum_version": "1.3.0", "message": "1.2 leaks caches"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := versioncheck.Client{Endpoint: server.URL + "/policy?org=acme", Token: "token"}
+	p, err := client.Policy(t.Context(), "v1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, versioncheck.Policy{MinimumVersion: "1.3.0", Message: "1.2 leaks caches"}, p)
+
+	client.Endpoint = server.URL + "/invalid?org=acme"
+	_, err = client.Policy(t.Context(), "v1.2.3")
+	require.ErrorContains(t, err, `invalid minimum version "latest"`)
+
+	client.Endpoint = server.URL + "/fail?org=acme"
+	_, err = client.Policy(t.Context(), "v1.2.3")
+	require.ErrorContains(t, err, "403 Forbidden")
+}
+
+func TestPolicy_Supported(t *testing.T) {
+	p := versioncheck.Policy{MinimumVersion: "v1.3.0"}
+	require.False(t, p.Supported("v1.2.9"))
+	require.True(t, p.Supported("1.3.0"))
+	require.True(t, p.Supported("v1.4.0"))
+	require.True(t, p.Supported("dev"))
+	require.True(t, versioncheck.Policy{}.Supported("v0.1.0"))
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,7 +13,9 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/namespacelabs/spacectl/internal/cli/cmd"
+	"github.com/namespacelabs/spacectl/internal/cli/output"
 	"github.com/namespacelabs/spacectl/internal/log"
+	"github.com/namespacelabs/spacectl/internal/tracing"
 )
 
 type errorResponse struct {
@@ -19,7 +23,14 @@ type errorResponse struct {
 	Message string `json:"message"`
 }
 
-const defaultLogLevel = "info"
+const (
+	defaultLogLevel = "info"
+	logFileEnv      = "SPACECTL_LOG_FILE"
+
+	// Log files are rotated at this size, keeping logFileBackups old ones.
+	logFileMaxSize = 10 << 20
+	logFileBackups = 3
+)
 
 var (
 	Version = "dev"
@@ -32,25 +43,66 @@ func main() {
 		Use:   "spacectl",
 		Short: "CLI used for powering various Namespace functionality",
 		Long:  `A CLI tool for powering various Namespace functionality.`,
+		// Recorded in the cache metadata, so that it tells which version wrote it.
+		Version: Version,
+		// Replaced by `spacectl completion`, which documents how to load
+		// the scripts.
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
 
+	// Set once the command runs, and called once it is done.
+	updateNotice := func() {}
+
 	loglvl := cli.PersistentFlags().String("log_level", defaultLogLevel, "Log level (debug, info, warn, error)")
-	outputFlag := cli.PersistentFlags().StringP("output", "o", "plain", "Output format: plain or json.")
+	output.AddFlag(cli.PersistentFlags())
+	logFormat := cli.PersistentFlags().String("log_format", "text", "Log format: text, adapted to the CI system if one is detected, or json on stderr.")
+	noColor := cli.PersistentFlags().Bool("no_color", false, "Do not color logs, which are colored on terminals unless NO_COLOR is set.")
+	logFile := cli.PersistentFlags().String("log_file", os.Getenv(logFileEnv), "Also write logs of all levels to this file, rotated at 10MiB.")
 
 	cli.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		// Logs must not mix with data printed for programs. The format is
+		// read from c, the command being run, exactly as it reads it.
+		spec := output.FromFlags(c.Flags())
 		logDest := io.Writer(os.Stdout)
-		if *outputFlag == "json" {
+		if spec.IsData() {
 			logDest = os.Stderr
+		}
+		if spec.Format == output.FormatJSON {
 			cli.SilenceErrors = true
 			cli.SilenceUsage = true
 		}
-		return setLogger(*loglvl, logDest)
+
+		var err error
+		switch *logFormat {
+		case "text":
+			err = setLogger(*loglvl, logDest, !*noColor && log.ShouldColor(logDest))
+		case "json":
+			// Structured logs never mix with the output.
+			err = withJSONLogger(*loglvl, os.Stderr)
+		default:
+			err = fmt.Errorf("unknown log format %q, expected text or json", *logFormat)
+		}
+		if err != nil {
+			return err
+		}
+		updateNotice = cmd.StartUpdateNotice(c, Version)
+		if *logFile == "" {
+			return nil
+		}
+		return teeLogFile(*logFile, os.Args)
 	}
 
 	cli.AddCommand(cmd.NewCacheCmd())
+	cli.AddCommand(cmd.NewCompletionCmd())
+	cli.AddCommand(cmd.NewDocsCmd())
+	cli.AddCommand(cmd.NewSelfUpdateCmd(Version))
 	cli.AddCommand(cmd.NewVersionCmd(Version, Commit, Date))
 
-	if err := cli.Execute(); err != nil {
+	ctx, finishTrace := startTrace()
+	executed, err := cli.ExecuteContextC(ctx)
+	finishTrace(executed, err)
+	updateNotice()
+	if err != nil {
 		if cli.SilenceErrors {
 			enc := json.NewEncoder(os.Stdout)
 			enc.SetIndent("", "  ")
@@ -58,16 +110,80 @@ func main() {
 		} else {
 			slog.Error(err.Error())
 		}
-		os.Exit(1)
+		// Some errors, e.g. cold caches, exit with a status of their own.
+		code := 1
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		os.Exit(code)
+	}
+}
+
+// startTrace traces the command when an OTLP endpoint is set through the
+// standard OpenTelemetry environment variables. The returned function ends
+// the trace and exports it.
+func startTrace() (context.Context, func(*cobra.Command, error)) {
+	ctx := context.Background()
+	exporter, ok, err := tracing.ExporterFromEnv(os.Getenv)
+	if err != nil {
+		return ctx, func(*cobra.Command, error) {
+			slog.Warn("tracing is disabled", slog.Any("error", err))
+		}
+	}
+	if !ok {
+		return ctx, func(*cobra.Command, error) {}
+	}
+
+	exporter.ServiceVersion = Version
+	tracer := tracing.NewTracer(os.Getenv("TRACEPARENT"))
+	ctx, span := tracing.Start(tracing.WithTracer(ctx, tracer), "spacectl")
+	return ctx, func(c *cobra.Command, err error) {
+		if c != nil {
+			span.SetName(c.CommandPath())
+		}
+		span.End(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), tracing.DefaultTimeout)
+		defer cancel()
+		if err := exporter.Export(ctx, tracer); err != nil {
+			slog.Warn("failed to export traces", slog.Any("error", err))
+		}
 	}
 }
 
-func setLogger(lvl string, w io.Writer) error {
-	if strings.ToLower(os.Getenv("GITHUB_ACTIONS")) == "true" {
+func setLogger(lvl string, w io.Writer, color bool) error {
+	switch {
+	case envTrue("GITHUB_ACTIONS"):
 		return withGithubLogger(w)
+	case envTrue("GITLAB_CI"):
+		return withGitlabLogger(lvl, w)
+	case envTrue("BUILDKITE"):
+		return withCILogger(lvl, w, log.NewBuildkiteHandler)
+	case envTrue("CIRCLECI"):
+		return withCILogger(lvl, w, log.NewCircleCIHandler)
+	case envTrue("TF_BUILD"): // Azure Pipelines
+		return withCILogger(lvl, w, log.NewAzurePipelinesHandler)
 	}
 
-	return withDefaultLogger(lvl, w)
+	return withDefaultLogger(lvl, w, color)
+}
+
+// teeLogFile also sends logs to path. The file is left open until exit:
+// writes are not buffered, so nothing is lost.
+func teeLogFile(path string, args []string) error {
+	f, err := log.OpenRotatingFile(path, logFileMaxSize, logFileBackups)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(slog.NewMultiHandler(slog.Default().Handler(), log.NewFileHandler(f))))
+	slog.Debug("Running spacectl", "version", Version, "args", args[1:])
+	return nil
+}
+
+func envTrue(name string) bool {
+	return strings.ToLower(os.Getenv(name)) == "true"
 }
 
 func withGithubLogger(w io.Writer) error {
@@ -76,16 +192,62 @@ func withGithubLogger(w io.Writer) error {
 	return nil
 }
 
-func withDefaultLogger(lvl string, w io.Writer) error {
+func withGitlabLogger(lvl string, w io.Writer) error {
+	slogLvl, err := parseLogLevel(lvl)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	logger := slog.New(log.NewGitlabHandler(w, &log.GitlabHandlerOptions{
+		Level: slogLvl,
+	}))
+	slog.SetDefault(logger)
+	return nil
+}
+
+func withCILogger(lvl string, w io.Writer, newHandler func(io.Writer, *log.CIHandlerOptions) slog.Handler) error {
+	slogLvl, err := parseLogLevel(lvl)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	slog.SetDefault(slog.New(newHandler(w, &log.CIHandlerOptions{
+		Level: slogLvl,
+	})))
+	return nil
+}
+
+func withJSONLogger(lvl string, w io.Writer) error {
 	slogLvl, err := parseLogLevel(lvl)
 	if err != nil {
 		return fmt.Errorf("invalid log level: %w", err)
 	}
 
+	slog.SetDefault(slog.New(log.NewJSONHandler(w, &log.JSONHandlerOptions{
+		Level: slogLvl,
+	})))
+	return nil
+}
+
+func withDefaultLogger(lvl string, w io.Writer, color bool) error {
+	slogLvl, err := parseLogLevel(lvl)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	// Progress is shown live below the logs on interactive terminals.
+	if log.IsTerminal(w) {
+		t := log.NewTerminal(w)
+		log.SetTerminal(t)
+		w = t
+	}
+
 	logger := slog.New(log.NewPlainHandler(w, &log.PlainHandlerOptions{
 		Level: slogLvl,
+		Color: color,
 	}))
 	slog.SetDefault(logger)
+	log.SetColor(color)
 	return nil
 }
 
@@ -0,0 +1,136 @@
+// Package cache is the public API for mounting spacectl's caches from Go
+// programs, without shelling out to the CLI. It exposes the types and
+// functions that `spacectl cache` is built on; the names listed here are kept
+// stable across releases, while everything else in the implementation may
+// change.
+package cache
+
+import (
+	"context"
+
+	"github.com/namespacelabs/spacectl/internal/cache"
+)
+
+// AllModes names every mount in the per-mode settings of a MountRequest,
+// e.g. Exclude and Hooks.
+const AllModes = cache.AllModes
+
+type (
+	// Mounter mounts caches under a cache root. Create one with NewMounter.
+	Mounter = cache.Mounter
+	// Executor performs the filesystem operations of a Mounter.
+	Executor        = cache.Executor
+	DefaultExecutor = cache.DefaultExecutor
+
+	MountRequest        = cache.MountRequest
+	MountResponse       = cache.MountResponse
+	MountResponseInput  = cache.MountResponseInput
+	MountResponseOutput = cache.MountResponseOutput
+	MountResult         = cache.MountResult
+	MountIssue          = cache.MountIssue
+	DedupedPath         = cache.DedupedPath
+	DiskUsage           = cache.DiskUsage
+	// HitPolicy fails mounts whose caches are colder than expected.
+	HitPolicy = cache.HitPolicy
+
+	// Plan is what Mount would do, as returned by Mounter.Plan and
+	// executed by Mounter.Apply.
+	Plan         = cache.Plan
+	PlannedMount = cache.PlannedMount
+
+	ModeConfig  = cache.ModeConfig
+	Hooks       = cache.Hooks
+	HookStage   = cache.HookStage
+	PlannedHook = cache.PlannedHook
+	HookResult  = cache.HookResult
+
+	// Observer is notified as mounts and prunes progress.
+	Observer    = cache.Observer
+	NopObserver = cache.NopObserver
+
+	MountStrategy = cache.MountStrategy
+	OwnershipMode = cache.OwnershipMode
+	FileOwner     = cache.FileOwner
+
+	KeysResponse  = cache.KeysResponse
+	ModeKey       = cache.ModeKey
+	PruneRequest  = cache.PruneRequest
+	PruneResponse = cache.PruneResponse
+	PruneEntry    = cache.PruneEntry
+	DirSize       = cache.DirSize
+	// Compression is how PruneRequest compresses rarely used entries.
+	Compression = cache.Compression
+
+	DedupeRequest  = cache.DedupeRequest
+	DedupeResponse = cache.DedupeResponse
+	ModeDedupe     = cache.ModeDedupe
+
+	WarmRequest  = cache.WarmRequest
+	WarmResponse = cache.WarmResponse
+	WarmedMode   = cache.WarmedMode
+
+	DoctorResponse = cache.DoctorResponse
+	DoctorCheck    = cache.DoctorCheck
+	DoctorStatus   = cache.DoctorStatus
+
+	// ProjectConfig is a project's .spacectl/cache.yaml.
+	ProjectConfig = cache.ProjectConfig
+)
+
+const (
+	HookPreMount  = cache.HookPreMount
+	HookPostMount = cache.HookPostMount
+
+	MountAuto    = cache.MountAuto
+	MountBind    = cache.MountBind
+	MountSymlink = cache.MountSymlink
+	MountCopy    = cache.MountCopy
+	MountOverlay = cache.MountOverlay
+
+	OwnershipNone      = cache.OwnershipNone
+	OwnershipTop       = cache.OwnershipTop
+	OwnershipRecursive = cache.OwnershipRecursive
+
+	CompressionZstd = cache.CompressionZstd
+	CompressionGzip = cache.CompressionGzip
+
+	DoctorOK   = cache.DoctorOK
+	DoctorWarn = cache.DoctorWarn
+	DoctorFail = cache.DoctorFail
+	DoctorSkip = cache.DoctorSkip
+)
+
+// ErrNotVolume is returned by mounting with Mounter.RequireVolume set when
+// the cache root is not a mounted volume.
+var ErrNotVolume = cache.ErrNotVolume
+
+// ErrLowDiskSpace is returned by mounting with Mounter.RequireFree set when
+// the cache volume has less than Mounter.MinFree bytes available.
+var ErrLowDiskSpace = cache.ErrLowDiskSpace
+
+// ErrColdCache is returned by HitPolicy.Check when caches missed more often
+// than the policy allows.
+var ErrColdCache = cache.ErrColdCache
+
+// NewMounter returns a Mounter for cacheRoot with the default modes, those
+// added with mode.Register included.
+func NewMounter(cacheRoot string) (Mounter, error) {
+	return cache.NewMounter(cacheRoot)
+}
+
+// DetectRootless reports whether mounting has to do without sudo, so that
+// DefaultExecutor.Rootless should be set.
+func DetectRootless(ctx context.Context) bool {
+	return cache.DetectRootless(ctx)
+}
+
+// ParseMountStrategy parses the name of a mount strategy.
+func ParseMountStrategy(name string) (MountStrategy, error) {
+	return cache.ParseMountStrategy(name)
+}
+
+// LoadProjectConfig reads a project config from path. A missing file yields
+// an empty config when allowMissing is set.
+func LoadProjectConfig(path string, allowMissing bool) (ProjectConfig, error) {
+	return cache.LoadProjectConfig(path, allowMissing)
+}
@@ -0,0 +1,81 @@
+// Package mode is the public API for detecting the tools a project uses and
+// planning their caches, and for adding modes of your own with Register.
+// The names listed here are kept stable across releases.
+package mode
+
+import "github.com/namespacelabs/spacectl/internal/cache/mode"
+
+type (
+	// ModeProvider detects and plans the caches of a tool.
+	ModeProvider = mode.ModeProvider
+	// Trimmer, Warmer, Orderer and Conflicter are optional interfaces of
+	// providers.
+	Trimmer    = mode.Trimmer
+	Warmer     = mode.Warmer
+	Orderer    = mode.Orderer
+	Conflicter = mode.Conflicter
+
+	Modes = mode.Modes
+
+	DetectRequest = mode.DetectRequest
+	DetectResult  = mode.DetectResult
+	DetectReason  = mode.DetectReason
+	DetectCheck   = mode.DetectCheck
+	CheckKind     = mode.CheckKind
+	Confidence    = mode.Confidence
+
+	PlanRequest = mode.PlanRequest
+	PlanResult  = mode.PlanResult
+	Plan        = mode.Plan
+	Ordering    = mode.Ordering
+	TrimRequest = mode.TrimRequest
+	WarmRequest = mode.WarmRequest
+	WarmResult  = mode.WarmResult
+	// Source is where a planned path came from.
+	Source     = mode.Source
+	SourceKind = mode.SourceKind
+
+	// Executor runs the commands and reads the files that providers look
+	// at, so that they can be faked in tests.
+	Executor        = mode.Executor
+	CommandSpec     = mode.CommandSpec
+	DefaultExecutor = mode.DefaultExecutor
+
+	FindProjectsRequest = mode.FindProjectsRequest
+)
+
+const (
+	ReasonMissingBinary = mode.ReasonMissingBinary
+	ReasonMissingFile   = mode.ReasonMissingFile
+	ReasonCommandFailed = mode.ReasonCommandFailed
+	ReasonNotUsed       = mode.ReasonNotUsed
+
+	ConfidenceHigh = mode.ConfidenceHigh
+	ConfidenceLow  = mode.ConfidenceLow
+
+	CheckBinary  = mode.CheckBinary
+	CheckFile    = mode.CheckFile
+	CheckCommand = mode.CheckCommand
+
+	SourceEnv     = mode.SourceEnv
+	SourceCommand = mode.SourceCommand
+	SourceDefault = mode.SourceDefault
+	SourceConfig  = mode.SourceConfig
+)
+
+// ErrOffline is returned by the executor of requests with Offline set for
+// every command, so that providers assume default paths instead.
+var ErrOffline = mode.ErrOffline
+
+// DefaultModes returns the built-in modes, followed by the modes added with
+// Register.
+func DefaultModes() Modes {
+	return mode.DefaultModes()
+}
+
+// Register adds a provider to the modes returned by DefaultModes, and so to
+// every Mounter created afterwards, typically from an init function. It
+// fails when a mode of the same name exists.
+func Register(p ModeProvider) error {
+	return mode.Register(p)
+}
@@ -0,0 +1,46 @@
+package mode_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/namespacelabs/spacectl/pkg/cache/mode"
+)
+
+type bazel struct{}
+
+func (bazel) Name() string { return "example-bazel" }
+
+func (bazel) Detect(ctx context.Context, req mode.DetectRequest) (mode.DetectResult, error) {
+	return mode.DetectResult{Detected: true, Confidence: mode.ConfidenceHigh}, nil
+}
+
+func (bazel) Plan(ctx context.Context, req mode.PlanRequest) (mode.PlanResult, error) {
+	return mode.PlanResult{
+		CacheDirs:  []string{"bazel"},
+		MountPaths: []string{req.Getenv("HOME") + "/.cache/bazel"},
+	}, nil
+}
+
+// Registered once per process, as from an init function.
+var registerErr = mode.Register(bazel{})
+
+func TestRegister(t *testing.T) {
+	require.NoError(t, registerErr)
+	require.Error(t, mode.Register(bazel{}))
+
+	modes, err := mode.DefaultModes().Filter([]string{"example-bazel"})
+	require.NoError(t, err)
+
+	plan, err := modes.Plan(t.Context(), mode.PlanRequest{
+		CacheRoot:    "/cache",
+		EnabledModes: []string{"example-bazel"},
+		Exec:         mode.DefaultExecutor{},
+		Env:          map[string]string{"HOME": "/home/runner"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"example-bazel"}, plan.Order)
+	require.Equal(t, []string{"/home/runner/.cache/bazel"}, plan.Results["example-bazel"].MountPaths)
+}